@@ -0,0 +1,840 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Site is the request body for creating a site
+type Site struct {
+	Name     string   `json:"name"`
+	Image    string   `json:"image,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	Commit   string   `json:"commit,omitempty"` // Git commit the image was built from, recorded as deploy metadata
+	Domains  []string `json:"domains,omitempty"`
+	Template string   `json:"template,omitempty"`
+	TTL      string   `json:"ttl,omitempty"`    // Delete the site after this duration (e.g. "2h")
+	Region   string   `json:"region,omitempty"` // App Platform region (default: nyc)
+
+	// Runtime settings of the site's container, overriding the template's
+	Port      int               `json:"port,omitempty"`      // HTTP port the container listens on (default: 80)
+	Instances int               `json:"instances,omitempty"` // Number of instances (default: 1)
+	Size      string            `json:"size,omitempty"`      // Instance size slug (default: apps-s-1vcpu-0.5gb)
+	Env       map[string]string `json:"env,omitempty"`       // Environment variables
+
+	// Labels describe the site to the operator's policies (e.g. team=shop), which can require them
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// RandomSuffix allocates name-xxxx.lightspeed.ee if name.lightspeed.ee is taken
+	RandomSuffix bool `json:"random_suffix,omitempty"`
+
+	// BaseDomain allocates the subdomain under a registered tenant domain instead of lightspeed.ee
+	BaseDomain string `json:"base_domain,omitempty"`
+
+	// Service is a second container deployed alongside the site (e.g. an API)
+	Service *SiteService `json:"service,omitempty"`
+
+	// Ingress routes path prefixes to the site's components (default: the service's path and /)
+	Ingress []IngressRule `json:"ingress,omitempty"`
+}
+
+// SiteService is a containerized service that runs next to a site's PHP container as its
+// own component, receiving the requests under its path (e.g. /api)
+type SiteService struct {
+	Name      string `json:"name,omitempty"` // Component name, prefixed with the site name (default: api)
+	Image     string `json:"image"`          // Repository in the Lightspeed registry
+	Tag       string `json:"tag,omitempty"`  // Image tag (default: latest)
+	Port      int    `json:"port,omitempty"` // HTTP port the service listens on (default: 8080)
+	Path      string `json:"path,omitempty"` // Path prefix routed to the service (default: /api)
+	Instances int    `json:"instances,omitempty"`
+	Size      string `json:"size,omitempty"` // Instance size slug (default: the site's size)
+}
+
+// SiteResponse represents a site in responses
+type SiteResponse struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Region    string   `json:"region,omitempty"`
+	URLs      []string `json:"urls,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Domain    string   `json:"domain,omitempty"` // Allocated site domain
+	ExpiresAt string   `json:"expires_at,omitempty"`
+	Tag       string   `json:"tag,omitempty"`     // Image tag the site runs
+	Release   string   `json:"release,omitempty"` // Release ID of the image the site runs
+
+	Commit     string `json:"commit,omitempty"`      // Git commit the running image was built from
+	DeployedAt string `json:"deployed_at,omitempty"` // When the running version was deployed
+	Serving    string `json:"serving,omitempty"`     // Version the uptime monitor last saw served at /__lightspeed
+
+	DeploymentID string      `json:"deployment_id,omitempty"` // Active deployment
+	InProgress   *Deployment `json:"in_progress,omitempty"`   // Deployment being built or rolled out
+	Instances    int         `json:"instances,omitempty"`
+	Size         string      `json:"size,omitempty"` // Instance size slug
+
+	Service *SiteService `json:"service,omitempty"` // Service deployed alongside the site
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SiteUpdate is the request body for changing a site's instance count or size
+// Zero values are left unchanged
+type SiteUpdate struct {
+	Instances int    `json:"instances,omitempty"`
+	Size      string `json:"size,omitempty"`
+}
+
+// SiteList is the response body for listing sites
+type SiteList struct {
+	Sites []SiteResponse `json:"sites"`
+}
+
+// Deployment is the response body for deployment actions (deploy, cancel)
+type Deployment struct {
+	DeploymentID string `json:"deployment_id"`
+	Status       string `json:"status"`
+	Tag          string `json:"tag,omitempty"`
+}
+
+// DryRun is the response body of a site request made with ?dry_run=true (create, deploy, scale
+// and delete): the request was validated and nothing was changed
+// Specs are plain JSON with the values of secret environment variables replaced
+type DryRun struct {
+	Action   string      `json:"action"`             // create, update (a spec change, which deploys), deploy (the same spec) or delete
+	Site     string      `json:"site"`               // Site name
+	Domain   string      `json:"domain,omitempty"`   // Domain a new site would be given
+	Current  interface{} `json:"current,omitempty"`  // App spec the site has now (not for create)
+	Spec     interface{} `json:"spec,omitempty"`     // App spec the site would have (not for delete)
+	Deletes  []string    `json:"deletes,omitempty"`  // What a delete would remove
+	Warnings []string    `json:"warnings,omitempty"` // Problems the request would only run into later, e.g. an image not pushed yet
+}
+
+// Policy is an organization rule the operator checks site specs against before creating or
+// changing a site. Empty fields aren't checked. Names in Sites and BannedEnv can use * for any
+// characters (e.g. shop-* or AWS_*).
+type Policy struct {
+	Name           string   `json:"name"`
+	Description    string   `json:"description,omitempty"`
+	Sites          []string `json:"sites,omitempty"`           // Sites the policy applies to (default: all)
+	Regions        []string `json:"regions,omitempty"`         // Regions sites may run in
+	MaxSize        string   `json:"max_size,omitempty"`        // Largest instance size slug, by vCPUs and memory
+	MaxInstances   int      `json:"max_instances,omitempty"`   // Most instances a component may run
+	RequiredLabels []string `json:"required_labels,omitempty"` // Labels every site must have
+	BannedEnv      []string `json:"banned_env,omitempty"`      // Environment variable names sites may not set
+}
+
+// PolicyList is the response body for listing policies
+type PolicyList struct {
+	Policies []Policy `json:"policies"`
+}
+
+// NotificationDestination is a webhook the operator posts events to
+// Events limits the kinds of events it gets (all if empty). Events during quiet hours are held
+// and sent as one summary when the quiet hours end; in digest mode every event is held for a
+// daily summary instead.
+type NotificationDestination struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events,omitempty"`      // deploy, prune and incident
+	QuietHours string   `json:"quiet_hours,omitempty"` // HH:MM-HH:MM, e.g. 22:00-07:00
+	Digest     bool     `json:"digest,omitempty"`
+	DigestAt   string   `json:"digest_at,omitempty"` // HH:MM the daily digest is sent (default 09:00)
+	Timezone   string   `json:"timezone,omitempty"`  // IANA time zone of the quiet hours and digest time (default UTC)
+	Held       int      `json:"held,omitempty"`      // Events waiting for the next summary (read-only)
+}
+
+// NotificationDestinationList is the response body for listing notification destinations
+type NotificationDestinationList struct {
+	Destinations []NotificationDestination `json:"destinations"`
+}
+
+// Kinds of notifications
+const (
+	NotificationDeploy   = "deploy"
+	NotificationPrune    = "prune"
+	NotificationIncident = "incident"
+	NotificationDigest   = "digest" // Summary of held events
+)
+
+// Notification is posted to notification destinations
+// The text summary lets Slack-compatible webhooks take it as is; a digest also carries the
+// events it sums up
+type Notification struct {
+	Event  string         `json:"event"`
+	Site   string         `json:"site,omitempty"`
+	Text   string         `json:"text"`
+	Time   string         `json:"time"`
+	Events []Notification `json:"events,omitempty"`
+}
+
+// DeployRequest is the request body for deploying a site
+// An empty tag redeploys the tag the site runs; any other tag pins the site to it
+// A service is added to the site, or updated if it has one; without it the site's service is left as is
+type DeployRequest struct {
+	Tag     string        `json:"tag,omitempty"`
+	Commit  string        `json:"commit,omitempty"` // Git commit the image was built from (unset if empty)
+	Service *SiteService  `json:"service,omitempty"`
+	Ingress []IngressRule `json:"ingress,omitempty"` // Routing rules to apply with the deploy (unchanged if empty)
+}
+
+// IngressRule routes requests under a path prefix to a component of a site
+type IngressRule struct {
+	Path               string `json:"path"`                           // Path prefix, e.g. /blog
+	Component          string `json:"component"`                      // Component name; "site" or a service name are expanded
+	PreservePathPrefix bool   `json:"preserve_path_prefix,omitempty"` // Pass the prefix on instead of stripping it
+	Rewrite            string `json:"rewrite,omitempty"`              // Replace the prefix with this path
+}
+
+// IngressRules is the request and response body of a site's routing rules
+type IngressRules struct {
+	Rules []IngressRule `json:"rules"`
+}
+
+// EnvVar is an environment variable of a site
+// Values of SECRET variables are returned encrypted
+type EnvVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Type  string `json:"type,omitempty"` // GENERAL (default) or SECRET
+}
+
+// EnvList is the response body for a site's environment variables
+type EnvList struct {
+	Vars []EnvVar `json:"vars"`
+}
+
+// EnvUpdate is the request body for changing a site's environment variables
+type EnvUpdate struct {
+	Set   []EnvVar `json:"set,omitempty"`
+	Unset []string `json:"unset,omitempty"`
+}
+
+// DatabaseRequest is the request body for provisioning a site's managed database
+// Zero values use the operator defaults
+type DatabaseRequest struct {
+	Engine  string `json:"engine,omitempty"`  // pg (default), mysql or valkey
+	Version string `json:"version,omitempty"` // Engine version (default: the latest)
+	Size    string `json:"size,omitempty"`    // Node size slug (default: db-s-1vcpu-1gb)
+	Nodes   int    `json:"nodes,omitempty"`   // Node count, 1-3 (default: 1)
+}
+
+// Database is a site's managed database with its connection details
+type Database struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Engine    string   `json:"engine"`
+	Version   string   `json:"version,omitempty"`
+	Size      string   `json:"size,omitempty"`
+	Nodes     int      `json:"nodes,omitempty"`
+	Region    string   `json:"region,omitempty"`
+	Status    string   `json:"status"` // creating, online, ...
+	Host      string   `json:"host"`
+	Port      int      `json:"port"`
+	Database  string   `json:"database,omitempty"`
+	User      string   `json:"user,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	URI       string   `json:"uri,omitempty"`
+	Env       []string `json:"env,omitempty"` // Site variables set from the connection details
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+// CacheRequest is the request body for provisioning a site's cache
+// Zero values use the operator defaults
+type CacheRequest struct {
+	Shared  bool   `json:"shared,omitempty"`  // Use the operator's shared instance instead of a dedicated one
+	Version string `json:"version,omitempty"` // Valkey version of a dedicated instance (default: the latest)
+	Size    string `json:"size,omitempty"`    // Node size slug of a dedicated instance (default: db-s-1vcpu-1gb)
+}
+
+// Cache is a site's Redis-compatible cache with its connection details
+// Sites on the shared instance have a user that can only use keys starting with Prefix
+type Cache struct {
+	Name      string   `json:"name"`
+	Shared    bool     `json:"shared,omitempty"`
+	Engine    string   `json:"engine"`
+	Version   string   `json:"version,omitempty"`
+	Size      string   `json:"size,omitempty"`
+	Region    string   `json:"region,omitempty"`
+	Status    string   `json:"status"` // creating, online, ...
+	Host      string   `json:"host"`
+	Port      int      `json:"port"`
+	User      string   `json:"user,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	URI       string   `json:"uri,omitempty"`
+	Prefix    string   `json:"prefix,omitempty"` // Key prefix of a site on the shared instance
+	Env       []string `json:"env,omitempty"`    // Site variables set from the connection details
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+// QueueJob is a job in one of a site's queues
+type QueueJob struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"` // Times the job was popped
+	CreatedAt   string          `json:"created_at"`
+	AvailableAt string          `json:"available_at,omitempty"` // When a delayed or reserved job can be popped
+	Error       string          `json:"error,omitempty"`        // Why a failed job failed
+}
+
+// QueuePush is the request body for pushing a job
+type QueuePush struct {
+	Payload json.RawMessage `json:"payload"`
+	Delay   int             `json:"delay,omitempty"` // Seconds before the job can be popped
+}
+
+// QueuePop is the request body for popping a job
+// A popped job is reserved for Timeout seconds; if it isn't acknowledged by then it's popped again
+type QueuePop struct {
+	Timeout int `json:"timeout,omitempty"` // Default: 60
+}
+
+// QueueStats are the job counts of a queue
+type QueueStats struct {
+	Name     string `json:"name"`
+	Ready    int    `json:"ready"`
+	Delayed  int    `json:"delayed"`
+	Reserved int    `json:"reserved"`
+	Failed   int    `json:"failed"`
+}
+
+// QueueList is the response body for listing a site's queues
+type QueueList struct {
+	Site   string       `json:"site"`
+	Queues []QueueStats `json:"queues"`
+}
+
+// QueueJobList is the response body for listing jobs
+type QueueJobList struct {
+	Jobs []QueueJob `json:"jobs"`
+}
+
+// SiteWorker is the request and response body for a site's queue worker: a component that
+// runs a PHP script of the site's image in the background
+// Zero values of a request use the defaults
+type SiteWorker struct {
+	Script    string `json:"script,omitempty"`    // Path in the project (default: worker.php)
+	Instances int    `json:"instances,omitempty"` // Default: 1
+	Size      string `json:"size,omitempty"`      // Default: the site's size
+	Tag       string `json:"tag,omitempty"`       // Tag the worker runs (always the site's)
+}
+
+// CronJob is a site URL the operator requests on a schedule (webcron)
+// Requests are signed with the site's LIGHTSPEED_CRON_KEY, so the site can check they come from the operator
+type CronJob struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`         // Cron expression in UTC (e.g. "*/15 * * * *") or a macro (e.g. "@daily")
+	Path     string `json:"path"`             // Path of the site to request
+	Method   string `json:"method,omitempty"` // GET or POST (default: GET)
+}
+
+// CronResult is the outcome of the last run of a cron job
+type CronResult struct {
+	Name       string `json:"name"`
+	Status     int    `json:"status,omitempty"` // Response status (0 if the request failed)
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	RanAt      string `json:"ran_at"`
+}
+
+// SiteCron is the request and response body for a site's cron jobs
+type SiteCron struct {
+	Site    string       `json:"site,omitempty"`
+	Jobs    []CronJob    `json:"jobs"`
+	Results []CronResult `json:"results,omitempty"`
+}
+
+// SiteCronList is the cron store's saved state
+type SiteCronList struct {
+	Sites []SiteCron `json:"sites"`
+}
+
+// TagAllocation is the response body for allocating a site's next tag
+type TagAllocation struct {
+	Tag    string `json:"tag"`
+	Number int    `json:"number"` // Build number (the suffix of dated tags)
+}
+
+// SiteDomain is a domain routed to a site
+type SiteDomain struct {
+	Domain  string `json:"domain"`
+	Type    string `json:"type,omitempty"`    // PRIMARY or ALIAS
+	Target  string `json:"target,omitempty"`  // Hostname the domain's CNAME points to
+	Managed bool   `json:"managed,omitempty"` // The operator manages the domain's DNS record
+
+	// The domain's record in the operator's zone, listed with ?records=true for managed domains
+	Record  string `json:"record,omitempty"`  // Hostname the zone's CNAME points to ("" if the zone has none)
+	Proxied bool   `json:"proxied,omitempty"` // The record is served through the CDN
+}
+
+// SiteDomainList is the response body for listing a site's domains
+type SiteDomainList struct {
+	Domains []SiteDomain `json:"domains"`
+}
+
+// Certificate statuses of a site's domain
+const (
+	CertificatePending  = "pending"  // Waiting for the domain to validate and its certificate to be issued
+	CertificateIssued   = "issued"   // Issued and not close to expiring
+	CertificateExpiring = "expiring" // Issued, but expires soon (renewal is failing)
+	CertificateError    = "error"    // Provisioning failed
+)
+
+// SiteCertificate is the TLS certificate state of one of a site's domains
+type SiteCertificate struct {
+	Domain    string `json:"domain"`
+	Type      string `json:"type,omitempty"`       // PRIMARY or ALIAS
+	Status    string `json:"status"`               // pending, issued, expiring or error
+	Phase     string `json:"phase,omitempty"`      // DigitalOcean's provisioning phase of the domain
+	ExpiresAt string `json:"expires_at,omitempty"` // RFC 3339, once issued
+	Message   string `json:"message,omitempty"`    // Why it's pending or failed, if known
+}
+
+// SiteCertificateList is the response body for the certificates of a site's domains
+type SiteCertificateList struct {
+	Certificates []SiteCertificate `json:"certificates"`
+}
+
+// Release is the response body for the release a site runs
+// The ID is the start of the image digest, recorded when the operator deploys the image
+type Release struct {
+	ID           string `json:"id"`
+	Tag          string `json:"tag,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+}
+
+// SiteDeployment is a deployment of a site, as the deployments list shows it
+// Duration is only set once the deployment finished (active, failed, canceled or superseded)
+type SiteDeployment struct {
+	ID         string `json:"id"`
+	Phase      string `json:"phase"`
+	Tag        string `json:"tag,omitempty"`
+	Release    string `json:"release,omitempty"` // Release ID of the image it deployed
+	Cause      string `json:"cause,omitempty"`
+	Active     bool   `json:"active,omitempty"` // The deployment the site runs
+	CreatedAt  string `json:"created_at"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// SiteDeploymentList is the response body for listing a site's deployments (newest first)
+type SiteDeploymentList struct {
+	Deployments []SiteDeployment `json:"deployments"`
+}
+
+// ImageTag is a tag of a site's image repository
+type ImageTag struct {
+	Tag       string `json:"tag"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// TagList is the response body for listing a site's image tags (newest first)
+type TagList struct {
+	Repository string     `json:"repository"`
+	Current    string     `json:"current,omitempty"` // Tag the site runs
+	Tags       []ImageTag `json:"tags"`
+}
+
+// ErrorResponse is the body of an error response
+// Code identifies the kind of failure (one of the ErrorCode constants) so clients can act on it
+type ErrorResponse struct {
+	Error      string   `json:"error"`
+	Code       string   `json:"code,omitempty"`
+	Violations []string `json:"violations,omitempty"` // Policies a site spec violates (code policy_violation)
+}
+
+// Error codes of error responses
+const (
+	ErrorCodeBadRequest     = "bad_request"
+	ErrorCodeUnauthorized   = "unauthorized"
+	ErrorCodeForbidden      = "forbidden"
+	ErrorCodeNotFound       = "not_found"
+	ErrorCodeSiteNotFound   = "site_not_found"
+	ErrorCodeTagNotFound    = "tag_not_found"
+	ErrorCodePolicy         = "policy_violation"
+	ErrorCodeConflict       = "conflict"
+	ErrorCodeRateLimited    = "rate_limited"
+	ErrorCodeProviderAuth   = "provider_unauthorized"
+	ErrorCodeProviderFailed = "provider_error"
+	ErrorCodeInternal       = "internal_error"
+)
+
+// ErrorCodeForStatus returns the generic error code of an HTTP status
+func ErrorCodeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case status == http.StatusForbidden:
+		return ErrorCodeForbidden
+	case status == http.StatusNotFound:
+		return ErrorCodeNotFound
+	case status == http.StatusConflict:
+		return ErrorCodeConflict
+	case status == http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout:
+		return ErrorCodeProviderFailed
+	case status >= 500:
+		return ErrorCodeInternal
+	case status >= 400:
+		return ErrorCodeBadRequest
+	}
+	return ""
+}
+
+// Platform is an image OS/architecture pair
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Layer is an image layer
+type Layer struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// Image is the response body for inspecting an image
+type Image struct {
+	Repository string            `json:"repository"`
+	Tag        string            `json:"tag"`
+	Digest     string            `json:"digest"`
+	MediaType  string            `json:"media_type"`
+	Platforms  []Platform        `json:"platforms"`
+	Layers     []Layer           `json:"layers"`
+	Size       int64             `json:"size"`
+	Created    string            `json:"created,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// GarbageCollection is the state of a registry garbage collection run
+type GarbageCollection struct {
+	Active    bool   `json:"active"`
+	Status    string `json:"status,omitempty"`
+	StartedAt string `json:"started_at,omitempty"`
+}
+
+// Health is the response body of the operator health check
+type Health struct {
+	Name              string             `json:"name"`
+	Status            string             `json:"status"`
+	GarbageCollection *GarbageCollection `json:"garbage_collection,omitempty"`
+	Disk              *DiskStatus        `json:"disk,omitempty"`
+	Features          []string           `json:"features,omitempty"` // Optional API features, e.g. dry_run
+}
+
+// FeatureDryRun is the Health feature of operators that answer ?dry_run=true without changing anything
+const FeatureDryRun = "dry_run"
+
+// DiskStatus is the free space where the operator saves its state files
+// Status is "ok", "low" or "critical" (state-changing requests are rejected until space is freed)
+type DiskStatus struct {
+	Status           string       `json:"status"`
+	Volumes          []DiskVolume `json:"volumes"`
+	RejectedRequests int64        `json:"rejected_requests"`
+	TempFilesRemoved int64        `json:"temp_files_removed"`
+	CheckedAt        string       `json:"checked_at,omitempty"`
+}
+
+// DiskVolume is the free space of a directory's filesystem
+type DiskVolume struct {
+	Path        string  `json:"path"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreePercent float64 `json:"free_percent"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Template is a curated site configuration registered by an operator admin
+// Image is the base image projects build on (the site.properties image property);
+// Env, Size and Instances are applied when a site is created from the template
+type Template struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Size        string            `json:"size,omitempty"`
+	Instances   int               `json:"instances,omitempty"`
+}
+
+// TemplateList is the response body for listing templates
+type TemplateList struct {
+	Templates []Template `json:"templates"`
+}
+
+// PullSecret is a login for a private registry that builds of images based on its images get
+// Password is only sent when registering and to builds; listing never returns it
+type PullSecret struct {
+	Registry     string   `json:"registry"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password,omitempty"`
+	Repositories []string `json:"repositories,omitempty"` // Repository prefixes it's handed out for (all if empty)
+	Tokens       []string `json:"tokens,omitempty"`       // Names of the access tokens it's handed to (only the admin token if empty)
+	TokenHashes  []string `json:"token_hashes,omitempty"` // Hashes of the tokens the names belonged to when it was registered (saved state only)
+}
+
+// PullSecretList is the response body for listing pull secrets
+type PullSecretList struct {
+	PullSecrets []PullSecret `json:"pull_secrets"`
+}
+
+// PullSecretRequest asks for the pull secret of a build's base image
+type PullSecretRequest struct {
+	Image string `json:"image"`
+}
+
+// BaseDomain is a tenant's own domain that site subdomains can be allocated under
+// Token is only sent when registering; it's never returned
+type BaseDomain struct {
+	Domain       string   `json:"domain"`
+	Provider     string   `json:"provider,omitempty"` // DNS provider (default: cloudflare)
+	Token        string   `json:"token,omitempty"`
+	NameServers  []string `json:"name_servers,omitempty"`
+	StatusDomain string   `json:"status_domain,omitempty"` // Custom domain of the tenant's status page
+}
+
+// BaseDomainList is the response body for listing base domains
+type BaseDomainList struct {
+	BaseDomains []BaseDomain `json:"base_domains"`
+}
+
+// BaseDomainSetup describes how to delegate a domain so it can be registered
+type BaseDomainSetup struct {
+	Domain string   `json:"domain"`
+	Steps  []string `json:"steps"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// DNSRecord is a DNS record in a site's zone
+type DNSRecord struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Priority int    `json:"priority,omitempty"` // MX only
+	Proxied  bool   `json:"proxied,omitempty"`  // Served through the CDN (read-only, see dns proxy)
+}
+
+// DNSRecordList is the response body for listing a site's DNS records
+type DNSRecordList struct {
+	Domain  string      `json:"domain"`
+	Records []DNSRecord `json:"records"`
+}
+
+// Proxy is the CDN proxy mode of a site's domain
+type Proxy struct {
+	Enabled bool   `json:"enabled"`
+	Domain  string `json:"domain,omitempty"`
+	SSLMode string `json:"ssl_mode,omitempty"` // SSL mode between the CDN and the app
+}
+
+// CachePurge is the result of purging the CDN cache of a site's domain
+type CachePurge struct {
+	Domain string `json:"domain"`
+}
+
+// EmailSetup is the request body for provisioning email DNS records for a site
+// Postmark needs DKIMSelector and DKIMKey, SES needs DKIMTokens and Region
+type EmailSetup struct {
+	Provider     string   `json:"provider"`
+	DKIMSelector string   `json:"dkim_selector,omitempty"`
+	DKIMKey      string   `json:"dkim_key,omitempty"`
+	DKIMTokens   []string `json:"dkim_tokens,omitempty"`
+	Region       string   `json:"region,omitempty"`
+	DMARCEmail   string   `json:"dmarc_email,omitempty"` // Aggregate report address (rua)
+}
+
+// EmailSetupResponse lists the email DNS records provisioned for a site
+type EmailSetupResponse struct {
+	Domain  string      `json:"domain"`
+	Records []DNSRecord `json:"records"`
+}
+
+// UptimeDay counts the uptime checks of a site on a day (UTC)
+type UptimeDay struct {
+	Date   string `json:"date"` // YYYY-MM-DD
+	Checks int    `json:"checks"`
+	Up     int    `json:"up"`
+}
+
+// SiteUptime is the uptime monitor's record of a site
+type SiteUptime struct {
+	Site        string      `json:"site"`
+	Domain      string      `json:"domain"`
+	Domains     []string    `json:"domains,omitempty"` // All domains routed to the site
+	Up          bool        `json:"up"`
+	Status      int         `json:"status,omitempty"` // HTTP status of the last check (0 if it failed to connect)
+	Error       string      `json:"error,omitempty"`
+	Version     string      `json:"version,omitempty"` // Version the site served at /__lightspeed on the last check
+	Commit      string      `json:"commit,omitempty"`  // Commit the site served at /__lightspeed on the last check
+	LastChecked string      `json:"last_checked,omitempty"`
+	Days        []UptimeDay `json:"days"`
+}
+
+// SiteUptimeList is the uptime monitor's saved state
+type SiteUptimeList struct {
+	Sites []SiteUptime `json:"sites"`
+}
+
+// SyntheticCheck is a multi-step transaction the uptime monitor runs against a site
+// Steps share cookies, so a check can log in with a form and then load a page
+type SyntheticCheck struct {
+	Name  string          `json:"name"`
+	Steps []SyntheticStep `json:"steps"`
+}
+
+// SyntheticStep is a request of a synthetic check and what its response must contain
+type SyntheticStep struct {
+	Method   string            `json:"method"` // GET or POST
+	Path     string            `json:"path"`
+	Form     map[string]string `json:"form,omitempty"`     // Form fields to POST
+	Status   int               `json:"status,omitempty"`   // Expected status (default: any below 400)
+	Contains string            `json:"contains,omitempty"` // Text the response body must contain
+}
+
+// SyntheticResult is the outcome of the last run of a synthetic check
+type SyntheticResult struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Step       int    `json:"step,omitempty"` // Failed step (1-based)
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	CheckedAt  string `json:"checked_at"`
+}
+
+// SiteChecks is the request and response body for a site's synthetic checks
+type SiteChecks struct {
+	Site    string            `json:"site,omitempty"`
+	Checks  []SyntheticCheck  `json:"checks"`
+	Results []SyntheticResult `json:"results,omitempty"`
+}
+
+// SiteChecksList is the synthetic check store's saved state
+type SiteChecksList struct {
+	Sites []SiteChecks `json:"sites"`
+}
+
+// SiteEdge is the request and response body for a site's edge mode settings
+// Sites in edge mode are served through the operator's edge proxy instead of directly by
+// App Platform, which adds the access rules App Platform can't apply per site
+type SiteEdge struct {
+	Site        string   `json:"site,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	Domain      string   `json:"domain,omitempty"`      // Domain served through the edge (set by the operator)
+	Ingress     string   `json:"ingress,omitempty"`     // App Platform ingress requests are proxied to (set by the operator)
+	Auth        string   `json:"auth,omitempty"`        // user:password required with HTTP basic auth (requests only)
+	AuthUser    string   `json:"auth_user,omitempty"`   // User of the basic auth credentials
+	AuthHash    string   `json:"auth_hash,omitempty"`   // bcrypt hash of the basic auth credentials (saved state only)
+	Maintenance bool     `json:"maintenance,omitempty"` // Serve a maintenance page (503) instead of the site
+	Allow       []string `json:"allow,omitempty"`       // IPs or CIDR ranges allowed (everyone if empty; set with SiteFirewall)
+	Deny        []string `json:"deny,omitempty"`        // IPs or CIDR ranges rejected (set with SiteFirewall)
+	Log         bool     `json:"log,omitempty"`         // Log every request in the operator log
+}
+
+// SiteEdgeList is the edge proxy's saved state
+type SiteEdgeList struct {
+	Sites []SiteEdge `json:"sites"`
+}
+
+// SiteFirewall is the request and response body for the IP rules of a site in edge mode
+// Denied IPs are rejected first; with an allow list, only IPs on it get through
+type SiteFirewall struct {
+	Site  string   `json:"site,omitempty"`
+	Allow []string `json:"allow"` // IPs or CIDR ranges allowed (everyone if empty)
+	Deny  []string `json:"deny"`  // IPs or CIDR ranges rejected
+}
+
+// SiteSLO is the response body for a site's availability against its SLO this month
+type SiteSLO struct {
+	Site            string  `json:"site"`
+	Month           string  `json:"month"`            // YYYY-MM (UTC)
+	Target          float64 `json:"target"`           // Availability target in percent
+	Availability    float64 `json:"availability"`     // Percent of checks passed this month (-1 if there were none)
+	Checks          int     `json:"checks"`           // Uptime checks this month
+	FailedChecks    int     `json:"failed_checks"`    // Uptime checks the site failed this month
+	BudgetMinutes   float64 `json:"budget_minutes"`   // Downtime the target allows over the whole month
+	DowntimeMinutes float64 `json:"downtime_minutes"` // Downtime so far this month
+	BudgetRemaining float64 `json:"budget_remaining"` // Percent of the error budget left
+	Status          string  `json:"status"`           // ok, at_risk or exhausted
+}
+
+// StatusPage is the response body for a tenant's public status page
+type StatusPage struct {
+	Tenant    string       `json:"tenant"`
+	Status    string       `json:"status"` // operational, degraded or down
+	Sites     []StatusSite `json:"sites"`
+	Incidents []Incident   `json:"incidents"` // Incidents of the last 30 days, newest first
+	UpdatedAt string       `json:"updated_at,omitempty"`
+}
+
+// StatusSite is a site on a status page
+// Uptime percentages are -1 when there are no checks in the period yet
+type StatusSite struct {
+	Name      string      `json:"name"`
+	Domain    string      `json:"domain"`
+	Up        bool        `json:"up"`
+	Uptime7d  float64     `json:"uptime_7d"`
+	Uptime30d float64     `json:"uptime_30d"`
+	Uptime90d float64     `json:"uptime_90d"`
+	Days      []UptimeDay `json:"days"`
+}
+
+// Incident is a period of sustained downtime of a site
+type Incident struct {
+	ID           int    `json:"id"`
+	Site         string `json:"site"`
+	Domain       string `json:"domain"`
+	Status       string `json:"status"` // open or resolved
+	StartedAt    string `json:"started_at"`
+	EndedAt      string `json:"ended_at,omitempty"`
+	Cause        string `json:"cause"`                   // Probable cause
+	DeploymentID string `json:"deployment_id,omitempty"` // Deployment the downtime is blamed on
+}
+
+// IncidentList is the response body for listing incidents
+type IncidentList struct {
+	Incidents []Incident `json:"incidents"`
+}
+
+// IncidentEvent is posted to the incident webhook when an incident opens or resolves
+type IncidentEvent struct {
+	Event    string   `json:"event"` // opened or resolved
+	Text     string   `json:"text"`  // Summary for chat webhooks
+	Incident Incident `json:"incident"`
+}
+
+// AccessToken is a token the operator issued to the CLI
+// Token is only returned when the token is issued; the operator stores its hash
+type AccessToken struct {
+	Token      string `json:"token,omitempty"`
+	Name       string `json:"name"`
+	Hash       string `json:"hash,omitempty"`
+	Admin      bool   `json:"admin,omitempty"` // The admin token itself
+	CreatedAt  string `json:"created_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// AccessTokenList is the operator's saved access tokens
+type AccessTokenList struct {
+	Tokens []AccessToken `json:"tokens"`
+}
+
+// DeviceCodeRequest is the request body for starting a login, or issuing a token
+type DeviceCodeRequest struct {
+	Name string `json:"name,omitempty"` // Who the token is for (e.g. user@hostname)
+}
+
+// DeviceCode is the response body for starting a device code login
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURL         string `json:"verification_url"`
+	VerificationURLComplete string `json:"verification_url_complete"`
+	ExpiresIn               int    `json:"expires_in"` // Seconds
+	Interval                int    `json:"interval"`   // Seconds between polls
+}
+
+// DeviceTokenRequest is the request body for polling a device code login
+type DeviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
@@ -0,0 +1,362 @@
+package digitalocean
+
+import (
+	"context"
+	"time"
+)
+
+// App is a DigitalOcean App Platform app
+type App struct {
+	ID                   string      `json:"id"`
+	OwnerUUID            string      `json:"owner_uuid,omitempty"`
+	Spec                 AppSpec     `json:"spec"`
+	DefaultIngress       string      `json:"default_ingress,omitempty"`
+	LiveURL              string      `json:"live_url,omitempty"`
+	ActiveDeployment     *Deployment `json:"active_deployment,omitempty"`
+	InProgressDeployment *Deployment `json:"in_progress_deployment,omitempty"`
+	PendingDeployment    *Deployment `json:"pending_deployment,omitempty"`
+	Domains              []AppDomain `json:"domains,omitempty"` // Provisioning state of the spec's domains
+	CreatedAt            time.Time   `json:"created_at"`
+	UpdatedAt            time.Time   `json:"updated_at"`
+}
+
+// AppSpec holds the app spec fields the platform reads back
+type AppSpec struct {
+	Name     string        `json:"name"`
+	Region   string        `json:"region,omitempty"`
+	Domains  []DomainSpec  `json:"domains,omitempty"`
+	Services []ServiceSpec `json:"services,omitempty"`
+	Workers  []ServiceSpec `json:"workers,omitempty"` // Worker components, which take no requests
+	Ingress  *IngressSpec  `json:"ingress,omitempty"`
+}
+
+// IngressSpec routes requests to an app's components
+type IngressSpec struct {
+	Rules []IngressRule `json:"rules,omitempty"`
+}
+
+// IngressRule routes requests under a path prefix to a component
+type IngressRule struct {
+	Component struct {
+		Name               string `json:"name"`
+		PreservePathPrefix bool   `json:"preserve_path_prefix,omitempty"`
+		Rewrite            string `json:"rewrite,omitempty"`
+	} `json:"component"`
+	Match struct {
+		Path struct {
+			Prefix string `json:"prefix"`
+		} `json:"path"`
+	} `json:"match"`
+}
+
+// DomainSpec is a domain routed to an app
+type DomainSpec struct {
+	Domain string `json:"domain"`
+	Type   string `json:"type,omitempty"` // PRIMARY or ALIAS
+}
+
+// AppDomain is the provisioning state of an app's domain: its DNS validation and TLS certificate
+type AppDomain struct {
+	ID    string     `json:"id"`
+	Spec  DomainSpec `json:"spec"`
+	Phase string     `json:"phase"` // UNKNOWN, PENDING, CONFIGURING, ACTIVE or ERROR
+
+	// Progress lists the provisioning steps; failed or pending ones carry the reason
+	Progress *struct {
+		Steps []AppDomainStep `json:"steps,omitempty"`
+	} `json:"progress,omitempty"`
+
+	// CertificateExpiresAt is when the domain's certificate expires (once one is issued)
+	CertificateExpiresAt *time.Time `json:"certificate_expires_at,omitempty"`
+}
+
+// AppDomainStep is a step of provisioning an app domain
+type AppDomainStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // PENDING, RUNNING, ERROR or SUCCESS
+	Reason *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"reason,omitempty"`
+}
+
+// ServiceSpec is a service component of an app spec
+type ServiceSpec struct {
+	Name          string     `json:"name"`
+	Image         *ImageSpec `json:"image,omitempty"`
+	HTTPPort      int        `json:"http_port,omitempty"`
+	Envs          []EnvVar   `json:"envs,omitempty"`
+	InstanceCount int        `json:"instance_count,omitempty"`
+	InstanceSize  string     `json:"instance_size_slug,omitempty"`
+}
+
+// EnvVar is an app environment variable (secret values are returned encrypted)
+type EnvVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// ImageSpec is the container image a service runs
+type ImageSpec struct {
+	RegistryType string        `json:"registry_type,omitempty"`
+	Registry     string        `json:"registry,omitempty"`
+	Repository   string        `json:"repository"`
+	Tag          string        `json:"tag,omitempty"`
+	DeployOnPush *DeployOnPush `json:"deploy_on_push,omitempty"`
+}
+
+// DeployOnPush is whether pushing the image's tag deploys the component
+type DeployOnPush struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Deployment is an app deployment
+type Deployment struct {
+	ID        string    `json:"id"`
+	Phase     string    `json:"phase"`
+	Cause     string    `json:"cause,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+
+	// Spec is the app spec the deployment rolled out (only in deployment lists)
+	Spec *AppSpec `json:"spec,omitempty"`
+}
+
+// ActivePhase returns the phase of the active deployment (empty if none)
+func (a *App) ActivePhase() string {
+	if a.ActiveDeployment == nil {
+		return ""
+	}
+	return a.ActiveDeployment.Phase
+}
+
+// Site returns the app's site component: the service named after the app, or else its
+// first service (nil if it has none)
+// Apps can run other services next to the site (see Services), which are left out
+func (a *App) Site() *ServiceSpec {
+	for i := range a.Spec.Services {
+		if a.Spec.Services[i].Name == a.Spec.Name {
+			return &a.Spec.Services[i]
+		}
+	}
+	if len(a.Spec.Services) > 0 {
+		return &a.Spec.Services[0]
+	}
+	return nil
+}
+
+// Image returns the image of the app's site component (nil if it doesn't run an image)
+func (a *App) Image() *ImageSpec {
+	if site := a.Site(); site != nil && site.Image != nil {
+		return site.Image
+	}
+	for _, service := range a.Spec.Services {
+		if service.Image != nil {
+			return service.Image
+		}
+	}
+	return nil
+}
+
+// DeploysOnPush checks if pushing the image of the site component or a worker deploys it
+func (a *App) DeploysOnPush() bool {
+	components := []ServiceSpec{}
+	if site := a.Site(); site != nil {
+		components = append(components, *site)
+	}
+	for _, component := range append(components, a.Spec.Workers...) {
+		if component.Image != nil && component.Image.DeployOnPush != nil && component.Image.DeployOnPush.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutePrefix returns the path prefix the ingress routes to a component (empty if none)
+func (a *App) RoutePrefix(component string) string {
+	if a.Spec.Ingress == nil {
+		return ""
+	}
+	for _, rule := range a.Spec.Ingress.Rules {
+		if rule.Component.Name == component {
+			return rule.Match.Path.Prefix
+		}
+	}
+	return ""
+}
+
+// PrimaryDomain returns the app's primary domain (empty if none)
+func (a *App) PrimaryDomain() string {
+	for _, d := range a.Spec.Domains {
+		if d.Type == "PRIMARY" {
+			return d.Domain
+		}
+	}
+	return ""
+}
+
+// Env returns the value of an environment variable of the app's services (empty if unset)
+func (a *App) Env(key string) string {
+	for _, service := range a.Spec.Services {
+		for _, env := range service.Envs {
+			if env.Key == key {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Instances returns the number of instances the app's site component runs
+func (a *App) Instances() int {
+	site := a.Site()
+	if site == nil {
+		return 0
+	}
+	if site.InstanceCount > 0 {
+		return site.InstanceCount
+	}
+	return 1
+}
+
+// Size returns the instance size slug of the app's site component
+func (a *App) Size() string {
+	if site := a.Site(); site != nil {
+		return site.InstanceSize
+	}
+	return ""
+}
+
+// URLs returns the live URL and default ingress of the app
+func (a *App) URLs() []string {
+	urls := []string{}
+	if a.LiveURL != "" {
+		urls = append(urls, a.LiveURL)
+	}
+	if a.DefaultIngress != "" {
+		urls = append(urls, a.DefaultIngress)
+	}
+	return urls
+}
+
+// ListApps lists all apps, following pagination
+func (c *Client) ListApps(ctx context.Context) ([]App, error) {
+	var apps []App
+
+	path := withPaging("/apps", 200)
+	for path != "" {
+		var result struct {
+			Apps  []App `json:"apps"`
+			Links links `json:"links"`
+		}
+		if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+			return nil, err
+		}
+		apps = append(apps, result.Apps...)
+		path = result.Links.Pages.Next
+	}
+
+	return apps, nil
+}
+
+// GetApp gets an app by ID
+func (c *Client) GetApp(ctx context.Context, id string) (*App, error) {
+	var result struct {
+		App App `json:"app"`
+	}
+	if err := c.Do(ctx, "GET", "/apps/"+id, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.App, nil
+}
+
+// FindAppByName finds an app by its spec name (nil if not found)
+func (c *Client) FindAppByName(ctx context.Context, name string) (*App, error) {
+	apps, err := c.ListApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range apps {
+		if apps[i].Spec.Name == name {
+			return &apps[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CreateApp creates an app from a spec
+func (c *Client) CreateApp(ctx context.Context, spec interface{}) (*App, error) {
+	var result struct {
+		App App `json:"app"`
+	}
+	payload := map[string]interface{}{"spec": spec}
+	if err := c.Do(ctx, "POST", "/apps", payload, &result); err != nil {
+		return nil, err
+	}
+	return &result.App, nil
+}
+
+// GetAppSpec gets the full spec of an app as raw JSON fields
+// AppSpec only holds the fields the platform reads; updates must send the whole spec back
+func (c *Client) GetAppSpec(ctx context.Context, id string) (map[string]interface{}, error) {
+	var result struct {
+		App struct {
+			Spec map[string]interface{} `json:"spec"`
+		} `json:"app"`
+	}
+	if err := c.Do(ctx, "GET", "/apps/"+id, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.App.Spec, nil
+}
+
+// UpdateApp replaces an app's spec, which starts a new deployment
+func (c *Client) UpdateApp(ctx context.Context, id string, spec interface{}) (*App, error) {
+	var result struct {
+		App App `json:"app"`
+	}
+	payload := map[string]interface{}{"spec": spec}
+	if err := c.Do(ctx, "PUT", "/apps/"+id, payload, &result); err != nil {
+		return nil, err
+	}
+	return &result.App, nil
+}
+
+// DeleteApp deletes an app
+func (c *Client) DeleteApp(ctx context.Context, id string) error {
+	return c.Do(ctx, "DELETE", "/apps/"+id, nil, nil)
+}
+
+// CreateDeployment starts a new deployment of an app
+func (c *Client) CreateDeployment(ctx context.Context, appID string, forceBuild bool) (*Deployment, error) {
+	var result struct {
+		Deployment Deployment `json:"deployment"`
+	}
+	payload := map[string]interface{}{"force_build": forceBuild}
+	if err := c.Do(ctx, "POST", "/apps/"+appID+"/deployments", payload, &result); err != nil {
+		return nil, err
+	}
+	return &result.Deployment, nil
+}
+
+// ListDeployments lists an app's most recent deployments, newest first
+func (c *Client) ListDeployments(ctx context.Context, appID string, limit int) ([]Deployment, error) {
+	var result struct {
+		Deployments []Deployment `json:"deployments"`
+	}
+	if err := c.Do(ctx, "GET", withPaging("/apps/"+appID+"/deployments", limit), nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Deployments) > limit {
+		result.Deployments = result.Deployments[:limit]
+	}
+	return result.Deployments, nil
+}
+
+// CancelDeployment cancels an in-progress deployment
+func (c *Client) CancelDeployment(ctx context.Context, appID, deploymentID string) error {
+	return c.Do(ctx, "POST", "/apps/"+appID+"/deployments/"+deploymentID+"/cancel", nil, nil)
+}
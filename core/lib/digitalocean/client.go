@@ -0,0 +1,191 @@
+package digitalocean
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the DigitalOcean API base URL
+const DefaultBaseURL = "https://api.digitalocean.com/v2"
+
+// defaultBaseURL is the base URL of new clients, overridden to point at a fake or proxy API
+var defaultBaseURL = DefaultBaseURL
+
+// Client is a DigitalOcean API client
+type Client struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryDelay time.Duration
+}
+
+// NewClient creates a new DigitalOcean API client
+func NewClient(token string) *Client {
+	return &Client{
+		token:      strings.TrimPrefix(token, "Bearer "),
+		baseURL:    defaultBaseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		retryDelay: time.Second,
+	}
+}
+
+// WithToken returns a copy of the client using a different API token
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = strings.TrimPrefix(token, "Bearer ")
+	return &clone
+}
+
+// SetBaseURL overrides the API base URL
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetDefaultBaseURL overrides the API base URL of clients created afterwards (empty restores the default)
+func SetDefaultBaseURL(baseURL string) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	defaultBaseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// APIError is returned when the API responds with an unsuccessful status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+}
+
+// Message returns the message of the error response ({"id": ..., "message": ...}), or its body
+func (e *APIError) Message() string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(e.Body), &body); err == nil && body.Message != "" {
+		return body.Message
+	}
+	return strings.TrimSpace(e.Body)
+}
+
+// links holds pagination links from list responses
+type links struct {
+	Pages struct {
+		Next string `json:"next"`
+	} `json:"pages"`
+}
+
+// Do makes an API request, retrying on network errors, rate limits and server errors
+// The response body is decoded into out if it's non-nil; expected lists the accepted
+// status codes (any 2xx if empty)
+func (c *Client) Do(ctx context.Context, method, path string, payload interface{}, out interface{}, expected ...int) error {
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = c.baseURL + path
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryDelay * time.Duration(1<<(attempt-1))):
+			}
+		}
+
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBody)}
+			continue
+		}
+
+		if !statusAccepted(resp.StatusCode, expected) {
+			return &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBody)}
+		}
+
+		if out != nil && len(respBody) > 0 {
+			if err := json.Unmarshal(respBody, out); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+// statusAccepted checks a status code against the expected codes (any 2xx if none given)
+func statusAccepted(status int, expected []int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, code := range expected {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withPaging adds per_page to a list path
+func withPaging(path string, perPage int) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%sper_page=%d", path, separator, perPage)
+}
+
+// escapeRepository escapes a repository name for use in a path (it may contain slashes)
+func escapeRepository(name string) string {
+	return url.PathEscape(name)
+}
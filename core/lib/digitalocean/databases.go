@@ -0,0 +1,89 @@
+package digitalocean
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Database is a DigitalOcean managed database cluster
+type Database struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Engine     string             `json:"engine"`
+	Version    string             `json:"version"`
+	Size       string             `json:"size"`
+	Region     string             `json:"region"`
+	NumNodes   int                `json:"num_nodes"`
+	Status     string             `json:"status"` // creating, online, resizing, migrating or forking
+	Tags       []string           `json:"tags,omitempty"`
+	Connection DatabaseConnection `json:"connection"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// DatabaseConnection holds the public connection details of a database cluster
+type DatabaseConnection struct {
+	URI      string `json:"uri"`
+	Database string `json:"database"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	SSL      bool   `json:"ssl"`
+}
+
+// DatabaseCreate is the request body for creating a database cluster
+type DatabaseCreate struct {
+	Name     string   `json:"name"`
+	Engine   string   `json:"engine"`
+	Version  string   `json:"version,omitempty"`
+	Size     string   `json:"size"`
+	Region   string   `json:"region"`
+	NumNodes int      `json:"num_nodes"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// DatabaseFirewallRule is a trusted source of a database cluster
+type DatabaseFirewallRule struct {
+	Type  string `json:"type"` // ip_addr, droplet, k8s, tag or app
+	Value string `json:"value"`
+}
+
+// ListDatabases lists the database clusters with a tag (all clusters if tag is empty)
+func (c *Client) ListDatabases(ctx context.Context, tag string) ([]Database, error) {
+	path := "/databases"
+	if tag != "" {
+		path += "?tag_name=" + url.QueryEscape(tag)
+	}
+
+	var result struct {
+		Databases []Database `json:"databases"`
+	}
+	if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Databases, nil
+}
+
+// CreateDatabase creates a database cluster
+// The cluster is provisioned in the background; its connection details are set right away
+func (c *Client) CreateDatabase(ctx context.Context, create DatabaseCreate) (*Database, error) {
+	var result struct {
+		Database Database `json:"database"`
+	}
+	if err := c.Do(ctx, "POST", "/databases", create, &result); err != nil {
+		return nil, err
+	}
+	return &result.Database, nil
+}
+
+// DeleteDatabase deletes a database cluster and its data
+func (c *Client) DeleteDatabase(ctx context.Context, id string) error {
+	return c.Do(ctx, "DELETE", "/databases/"+id, nil, nil)
+}
+
+// SetDatabaseFirewall replaces the trusted sources of a database cluster
+func (c *Client) SetDatabaseFirewall(ctx context.Context, id string, rules []DatabaseFirewallRule) error {
+	payload := map[string]interface{}{"rules": rules}
+	return c.Do(ctx, "PUT", "/databases/"+id+"/firewall", payload, nil)
+}
@@ -0,0 +1,57 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Log types of an app deployment
+const (
+	LogTypeBuild  = "BUILD"
+	LogTypeDeploy = "DEPLOY"
+	LogTypeRun    = "RUN"
+)
+
+// Logs holds the URLs a deployment's logs can be downloaded from
+type Logs struct {
+	LiveURL      string   `json:"live_url"`
+	HistoricURLs []string `json:"historic_urls"`
+}
+
+// GetLogs gets the log URLs of a deployment, aggregated across its components
+// With follow set, LiveURL streams new log lines as they're written
+func (c *Client) GetLogs(ctx context.Context, appID, deploymentID, logType string, follow bool) (*Logs, error) {
+	query := url.Values{}
+	query.Set("type", logType)
+	query.Set("follow", fmt.Sprintf("%t", follow))
+
+	var logs Logs
+	path := "/apps/" + appID + "/deployments/" + deploymentID + "/logs?" + query.Encode()
+	if err := c.Do(ctx, "GET", path, nil, &logs); err != nil {
+		return nil, err
+	}
+	return &logs, nil
+}
+
+// OpenLogs opens a log URL returned by GetLogs
+// Log URLs are pre-signed and live URLs stream until closed, so the client's
+// credentials and timeout aren't used
+func OpenLogs(ctx context.Context, logURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", logURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open logs: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,148 @@
+package digitalocean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Repository is a container registry repository
+type Repository struct {
+	Name string `json:"name"`
+}
+
+// Tag is a repository tag
+type Tag struct {
+	Tag       string    `json:"tag"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListRepositories lists all repositories in a registry, following pagination
+func (c *Client) ListRepositories(ctx context.Context, registry string) ([]Repository, error) {
+	var repos []Repository
+
+	path := withPaging(fmt.Sprintf("/registry/%s/repositoriesV2", registry), 200)
+	for path != "" {
+		var result struct {
+			Repositories []Repository `json:"repositories"`
+			Links        links        `json:"links"`
+		}
+		if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+			return nil, err
+		}
+		repos = append(repos, result.Repositories...)
+		path = result.Links.Pages.Next
+	}
+
+	return repos, nil
+}
+
+// ListTags lists all tags of a repository, following pagination
+func (c *Client) ListTags(ctx context.Context, registry, repository string) ([]Tag, error) {
+	var tags []Tag
+
+	path := withPaging(fmt.Sprintf("/registry/%s/repositories/%s/tags", registry, escapeRepository(repository)), 200)
+	for path != "" {
+		var result struct {
+			Tags  []Tag `json:"tags"`
+			Links links `json:"links"`
+		}
+		if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+			return nil, err
+		}
+		tags = append(tags, result.Tags...)
+		path = result.Links.Pages.Next
+	}
+
+	return tags, nil
+}
+
+// DeleteTag deletes a tag from a repository
+func (c *Client) DeleteTag(ctx context.Context, registry, repository, tag string) error {
+	path := fmt.Sprintf("/registry/%s/repositories/%s/tags/%s", registry, escapeRepository(repository), tag)
+	return c.Do(ctx, "DELETE", path, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// DeleteRepository deletes a repository
+func (c *Client) DeleteRepository(ctx context.Context, registry, repository string) error {
+	path := fmt.Sprintf("/registry/%s/repositories/%s", registry, escapeRepository(repository))
+	return c.Do(ctx, "DELETE", path, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// StartGarbageCollection starts garbage collection of a registry
+// A collection that is already running (409 Conflict) is not an error
+func (c *Client) StartGarbageCollection(ctx context.Context, registry string) error {
+	path := fmt.Sprintf("/registry/%s/garbage-collection", registry)
+	return c.Do(ctx, "POST", path, nil, nil, http.StatusCreated, http.StatusConflict)
+}
+
+// DockerCredentials returns base64 "username:password" credentials for registry.digitalocean.com
+func (c *Client) DockerCredentials(ctx context.Context, readWrite bool) (string, error) {
+	var result struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+
+	path := fmt.Sprintf("/registry/docker-credentials?read_write=%t", readWrite)
+	if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+		return "", err
+	}
+
+	registryAuth, ok := result.Auths["registry.digitalocean.com"]
+	if !ok || registryAuth.Auth == "" {
+		return "", fmt.Errorf("no auth credentials in response")
+	}
+
+	return registryAuth.Auth, nil
+}
+
+// RegistryTokenURL returns the token endpoint URL for a registry scope
+// Token requests authenticate with docker credentials (Basic auth) rather than the API token
+func (c *Client) RegistryTokenURL(scope string) string {
+	return fmt.Sprintf("%s/registry/auth?service=registry.digitalocean.com&scope=%s", c.baseURL, url.QueryEscape(scope))
+}
+
+// GarbageCollection is a registry garbage collection run
+type GarbageCollection struct {
+	UUID         string    `json:"uuid"`
+	RegistryName string    `json:"registry_name"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	BlobsDeleted int64     `json:"blobs_deleted"`
+	FreedBytes   int64     `json:"freed_bytes"`
+}
+
+// Active checks if the garbage collection is still running (registry writes are blocked)
+func (gc *GarbageCollection) Active() bool {
+	switch gc.Status {
+	case "succeeded", "failed", "cancelled":
+		return false
+	}
+	return true
+}
+
+// ActiveGarbageCollection gets the running garbage collection of a registry (nil if none)
+func (c *Client) ActiveGarbageCollection(ctx context.Context, registry string) (*GarbageCollection, error) {
+	var result struct {
+		GarbageCollection GarbageCollection `json:"garbage_collection"`
+	}
+
+	path := fmt.Sprintf("/registry/%s/garbage-collection", registry)
+	if err := c.Do(ctx, "GET", path, nil, &result); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !result.GarbageCollection.Active() {
+		return nil, nil
+	}
+	return &result.GarbageCollection, nil
+}
@@ -0,0 +1,264 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotResolved is returned when a hostname does not resolve to any address yet
+var ErrNotResolved = errors.New("hostname not resolved")
+
+// Resolver resolves hostnames using the system resolver or an explicit list of nameservers
+type Resolver struct {
+	servers []string
+	timeout time.Duration
+}
+
+// NewResolver creates a resolver
+// An empty server list uses the system resolver; otherwise each server is tried in order
+func NewResolver(servers []string) *Resolver {
+	var normalized []string
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		normalized = append(normalized, server)
+	}
+
+	return &Resolver{
+		servers: normalized,
+		timeout: 10 * time.Second,
+	}
+}
+
+// ParseServers parses a comma-separated list of nameservers
+func ParseServers(value string) []string {
+	var servers []string
+	for _, server := range strings.Split(value, ",") {
+		server = strings.TrimSpace(server)
+		if server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// Servers returns the configured nameservers (empty when using the system resolver)
+func (r *Resolver) Servers() []string {
+	return r.servers
+}
+
+// Describe returns a human-readable description of the resolver
+func (r *Resolver) Describe() string {
+	if len(r.servers) == 0 {
+		return "system"
+	}
+	return strings.Join(r.servers, ", ")
+}
+
+// LookupHost resolves a hostname to its addresses
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	var addrs []string
+	err := r.each(func(resolver *net.Resolver) error {
+		var err error
+		addrs, err = r.lookup(ctx, resolver, host)
+		return err
+	})
+	return addrs, err
+}
+
+// LookupCNAME returns the canonical name of a hostname, without the trailing dot
+// A hostname without a CNAME record is its own canonical name
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	var cname string
+	err := r.each(func(resolver *net.Resolver) error {
+		ctx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+
+		name, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNotResolved, err)
+		}
+		cname = strings.TrimSuffix(name, ".")
+		return nil
+	})
+	return cname, err
+}
+
+// each runs a lookup with the system resolver, or with each nameserver in turn until one succeeds
+func (r *Resolver) each(lookup func(resolver *net.Resolver) error) error {
+	if len(r.servers) == 0 {
+		return lookup(net.DefaultResolver)
+	}
+
+	var lastErr error
+	for _, server := range r.servers {
+		server := server
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: r.timeout}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+
+		if lastErr = lookup(resolver); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (r *Resolver) lookup(ctx context.Context, resolver *net.Resolver, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotResolved, err)
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNotResolved
+	}
+	return addrs, nil
+}
+
+// HTTPClient returns an HTTP client that resolves hostnames through this resolver
+// TLS verification is skipped because certificates may still be provisioning
+func (r *Resolver) HTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(address)
+				if err != nil {
+					return nil, err
+				}
+				addrs, err := r.LookupHost(ctx, host)
+				if err != nil {
+					return nil, err
+				}
+				return dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0], port))
+			},
+		},
+	}
+}
+
+// CheckURL performs a single readiness check and returns the HTTP status code
+// Returns an error wrapping ErrNotResolved if the hostname does not resolve yet
+func (r *Resolver) CheckURL(ctx context.Context, siteURL string) (int, error) {
+	parsed, err := url.Parse(siteURL)
+	if err != nil {
+		return 0, err
+	}
+	if parsed.Hostname() == "" {
+		return 0, fmt.Errorf("invalid URL: %s", siteURL)
+	}
+
+	// Resolve first so DNS failures can be reported separately from connection errors
+	if _, err := r.LookupHost(ctx, parsed.Hostname()); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", siteURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := r.HTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// DomainStatus holds the readiness state of a single domain
+type DomainStatus struct {
+	Domain     string
+	Addresses  []string
+	DNS        bool
+	TLS        bool
+	StatusCode int
+	Err        error
+}
+
+// Ready returns true if the domain resolves, completes a TLS handshake and responds successfully
+func (s *DomainStatus) Ready() bool {
+	return s.DNS && s.TLS && s.StatusCode >= 200 && s.StatusCode < 400
+}
+
+// CheckDomain checks DNS resolution, a verified TLS handshake and an HTTPS request for a domain
+func (r *Resolver) CheckDomain(ctx context.Context, domain string) *DomainStatus {
+	status := &DomainStatus{Domain: domain}
+
+	addrs, err := r.LookupHost(ctx, domain)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.DNS = true
+	status.Addresses = addrs
+
+	// Verify the certificate is valid for the domain (custom domain certs are issued asynchronously)
+	if _, err := r.checkTLS(ctx, domain, addrs[0]); err != nil {
+		status.Err = err
+		return status
+	}
+	status.TLS = true
+
+	statusCode, err := r.CheckURL(ctx, "https://"+domain+"/")
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.StatusCode = statusCode
+	if !status.Ready() {
+		status.Err = fmt.Errorf("returned status %d", statusCode)
+	}
+
+	return status
+}
+
+// CheckTLS completes a verified TLS handshake with a domain and returns the certificate it serves
+func (r *Resolver) CheckTLS(ctx context.Context, domain string) (*x509.Certificate, error) {
+	addrs, err := r.LookupHost(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return r.checkTLS(ctx, domain, addrs[0])
+}
+
+func (r *Resolver) checkTLS(ctx context.Context, domain, addr string) (*x509.Certificate, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: r.timeout},
+		Config:    &tls.Config{ServerName: domain},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, "443"))
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("TLS handshake failed: no certificate")
+	}
+	return certs[0], nil
+}
@@ -0,0 +1,184 @@
+package properties
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PropertiesFile is the flat project file
+	PropertiesFile = "site.properties"
+
+	// ManifestFile is the structured project file, used instead of site.properties when present
+	ManifestFile = "lightspeed.yaml"
+)
+
+// ProjectFile returns the path of a project's settings: lightspeed.yaml if the project has
+// one, else site.properties (which may not exist either)
+func ProjectFile(dir string) string {
+	if manifest := filepath.Join(dir, ManifestFile); FileExists(manifest) {
+		return manifest
+	}
+	return filepath.Join(dir, PropertiesFile)
+}
+
+// IsManifest checks if a path is a lightspeed.yaml manifest
+func IsManifest(path string) bool {
+	return filepath.Base(path) == ManifestFile
+}
+
+// ParseFile parses a project file, as a manifest or a properties file by its name
+func ParseFile(path string) (Properties, error) {
+	if IsManifest(path) {
+		return ParseManifest(path)
+	}
+	return ParseProperties(path)
+}
+
+// ParseManifest parses a lightspeed.yaml manifest into the keys of site.properties
+// Sections are flattened into dotted keys (env: {DEBUG: "1"} is env.DEBUG) and lists of
+// values into comma-separated ones (domains: [a.com, b.com] is domains=a.com,b.com), so
+// everything reading site.properties reads a manifest the same way
+func ParseManifest(path string) (Properties, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var manifest map[string]interface{}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	props := make(Properties)
+	if err := flatten(props, "", manifest); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return props, nil
+}
+
+// flatten adds the values of a manifest section to props, with its keys prefixed
+func flatten(props Properties, prefix string, section map[string]interface{}) error {
+	for key, value := range section {
+		key = prefix + key
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(props, key+".", v); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}:
+					return fmt.Errorf("%s: list items must be values", key)
+				}
+				items = append(items, Properties{"item": item}.Get("item"))
+			}
+			value = strings.Join(items, ",")
+		}
+		if _, ok := props[key]; ok {
+			return fmt.Errorf("%s is set twice", key)
+		}
+		props[key] = value
+	}
+	return nil
+}
+
+// FormatProperties writes properties as a site.properties file, sorted by key with the name first
+func FormatProperties(props Properties) (string, error) {
+	var b strings.Builder
+	for _, key := range sortedKeys(props) {
+		value := strings.Join(props.GetList(key), ",")
+		if _, isList := props[key].([]interface{}); !isList {
+			value = props.Get(key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("%s: values of site.properties can't span lines", key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+	return b.String(), nil
+}
+
+// FormatManifest writes properties as a lightspeed.yaml manifest, sorted by key with the name first
+// Dotted keys are grouped into sections by their first part (env.DEBUG goes in env), unless that
+// part is a key of its own (edge and edge.auth stay as they are). Booleans, whole numbers and
+// lists keep their type; other values are written as the strings site.properties reads.
+func FormatManifest(props Properties) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{}
+
+	for _, key := range sortedKeys(props) {
+		value := manifestValue(props, key)
+		section, name, found := strings.Cut(key, ".")
+		if _, isKey := props[section]; !found || isKey {
+			root.Content = append(root.Content, scalarNode(key), value)
+			continue
+		}
+		node, ok := sections[section]
+		if !ok {
+			node = &yaml.Node{Kind: yaml.MappingNode}
+			sections[section] = node
+			root.Content = append(root.Content, scalarNode(section), node)
+		}
+		node.Content = append(node.Content, scalarNode(name), value)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// manifestValue returns the YAML node of a property's value
+func manifestValue(props Properties, key string) *yaml.Node {
+	switch v := props[key].(type) {
+	case bool, int:
+		node := &yaml.Node{}
+		node.Encode(v)
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range props.GetList(key) {
+			node.Content = append(node.Content, scalarNode(item))
+		}
+		return node
+	}
+	return scalarNode(props.Get(key))
+}
+
+// scalarNode returns a string node, quoted by the encoder where YAML would read it as another type
+func scalarNode(value string) *yaml.Node {
+	node := &yaml.Node{}
+	node.Encode(value)
+	return node
+}
+
+// sortedKeys returns the keys of properties in order, with the name first
+func sortedKeys(props Properties) []string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "name") != (keys[j] == "name") {
+			return keys[i] == "name"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,269 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to one registry, answering Bearer token challenges (anonymous without credentials)
+type Client struct {
+	host     string
+	baseURL  string
+	username string
+	password string
+	token    string
+	client   *http.Client
+}
+
+// NewClient creates a client for a registry host
+// With credentials, requests use Basic auth until the registry asks for a token
+func NewClient(host, username, password string) *Client {
+	if host == "docker.io" || host == "index.docker.io" {
+		host = dockerHub
+	}
+	return &Client{
+		host:     host,
+		baseURL:  BaseURL(host),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+// Error is an unsuccessful registry response
+type Error struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("registry error: %s - %s", e.Status, e.Body)
+}
+
+// responseError reads an unsuccessful response into an Error
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &Error{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+}
+
+// do makes a request, fetching a token and retrying once if the registry challenges it
+// The caller closes the response body
+func (c *Client) do(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	target := path
+	if strings.HasPrefix(path, "/") {
+		target = c.baseURL + path
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		c.authorize(req)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.fetchToken(ctx, challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authorize adds the token, or the credentials until there is one
+func (c *Client) authorize(req *http.Request) {
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// fetchToken answers a Bearer challenge with a token from the registry's token service
+func (c *Client) fetchToken(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return &Error{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", Body: "authentication required"}
+	}
+
+	fields := parseChallenge(params)
+	realm, err := url.Parse(fields["realm"])
+	if err != nil || fields["realm"] == "" {
+		return fmt.Errorf("invalid auth challenge: %s", challenge)
+	}
+	query := realm.Query()
+	if service := fields["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope := fields["scope"]; scope != "" {
+		query.Set("scope", scope)
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token response: %w", err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	return nil
+}
+
+// parseChallenge parses the key="value" parameters of a WWW-Authenticate header
+func parseChallenge(params string) map[string]string {
+	fields := map[string]string{}
+	for params != "" {
+		key, rest, found := strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if !found {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, params = rest[1:end+1], rest[end+2:]
+		} else {
+			value, params, _ = strings.Cut(rest, ",")
+		}
+		fields[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return fields
+}
+
+// BlobExists checks if a repository has a blob
+func (c *Client) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repository+"/blobs/"+digest, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// GetBlob opens a blob of a repository (redirects to blob storage are followed)
+func (c *Client) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/blobs/"+digest, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// ReadBlob reads a small blob (such as an image config) of a repository
+func (c *Client) ReadBlob(ctx context.Context, repository, digest string) ([]byte, error) {
+	body, err := c.GetBlob(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// UploadBlob uploads a blob of known digest and size in one request
+func (c *Client) UploadBlob(ctx context.Context, repository, digest string, size int64, content io.Reader) error {
+	// Starting the upload answers any auth challenge, so the content is only sent once
+	resp, err := c.do(ctx, http.MethodPost, "/v2/"+repository+"/blobs/uploads/", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return &Error{StatusCode: resp.StatusCode, Status: resp.Status, Body: "failed to start upload"}
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.authorize(req)
+
+	resp, err = c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// PutManifest uploads a manifest under a tag
+func (c *Client) PutManifest(ctx context.Context, repository, tag, mediaType string, manifest []byte) error {
+	header := http.Header{"Content-Type": {mediaType}}
+	resp, err := c.do(ctx, http.MethodPut, "/v2/"+repository+"/manifests/"+tag, manifest, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+	return nil
+}
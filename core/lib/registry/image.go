@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Manifest media types
+const (
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerConfig   = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIConfig      = "application/vnd.oci.image.config.v1+json"
+	MediaTypeOCILayer       = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// Platform is the os and architecture of an image
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Descriptor points to a blob or manifest by digest
+type Descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+// Manifest is an image manifest, or an index of per-platform manifests
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        *Descriptor  `json:"config,omitempty"`
+	Layers        []Descriptor `json:"layers,omitempty"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+}
+
+// Image is a pulled image manifest with its config
+type Image struct {
+	Reference Reference
+	Manifest  *Manifest
+	Config    []byte
+}
+
+// acceptManifests lists the manifest types requested from registries
+var acceptManifests = []string{MediaTypeOCIIndex, MediaTypeOCIManifest, MediaTypeDockerList, MediaTypeDockerManifest}
+
+// GetImage fetches the manifest and config of an image, choosing the platform's manifest from an index
+func (c *Client) GetImage(ctx context.Context, ref Reference, platform Platform) (*Image, error) {
+	manifest, err := c.getManifest(ctx, ref.Repository, ref.Reference())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Manifests) > 0 {
+		var found *Descriptor
+		for i := range manifest.Manifests {
+			m := &manifest.Manifests[i]
+			if m.Platform != nil && m.Platform.OS == platform.OS && m.Platform.Architecture == platform.Architecture &&
+				(platform.Variant == "" || m.Platform.Variant == platform.Variant) {
+				found = m
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("image %s has no %s/%s variant", ref, platform.OS, platform.Architecture)
+		}
+		if manifest, err = c.getManifest(ctx, ref.Repository, found.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest.Config == nil {
+		return nil, fmt.Errorf("image %s has an unsupported manifest (%s)", ref, manifest.MediaType)
+	}
+	config, err := c.ReadBlob(ctx, ref.Repository, manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config of %s: %w", ref, err)
+	}
+	return &Image{Reference: ref, Manifest: manifest, Config: config}, nil
+}
+
+// getManifest fetches a manifest by tag or digest
+func (c *Client) getManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	header := http.Header{"Accept": acceptManifests}
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = resp.Header.Get("Content-Type")
+	}
+	return &manifest, nil
+}
+
+// ReadManifest fetches a manifest by tag or digest as the registry stores it, with its media type
+// Uploading the same bytes elsewhere keeps its digest
+func (c *Client) ReadManifest(ctx context.Context, repository, reference string) ([]byte, string, error) {
+	header := http.Header{"Accept": acceptManifests}
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, nil, header)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp)
+	}
+
+	manifest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, resp.Header.Get("Content-Type"), nil
+}
+
+// AppendLayer returns a new image with a layer added on top: the manifest lists the layer after
+// the base layers (in the base's manifest format) and the config records its diff ID and history.
+// The config descriptor of the returned manifest points to the returned config.
+func (img *Image) AppendLayer(layer *Layer, createdBy string) (*Manifest, []byte, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(img.Config, &config); err != nil {
+		return nil, nil, fmt.Errorf("invalid image config: %w", err)
+	}
+
+	rootfs, _ := config["rootfs"].(map[string]interface{})
+	if rootfs == nil {
+		rootfs = map[string]interface{}{"type": "layers"}
+	}
+	diffIDs, _ := rootfs["diff_ids"].([]interface{})
+	rootfs["diff_ids"] = append(diffIDs, layer.DiffID)
+	config["rootfs"] = rootfs
+
+	created := time.Now().UTC().Format(time.RFC3339)
+	history, _ := config["history"].([]interface{})
+	config["history"] = append(history, map[string]interface{}{"created": created, "created_by": createdBy})
+	config["created"] = created
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configType, layerType := MediaTypeDockerConfig, MediaTypeDockerLayer
+	manifestType := img.Manifest.MediaType
+	if manifestType == MediaTypeOCIManifest {
+		configType, layerType = MediaTypeOCIConfig, MediaTypeOCILayer
+	} else {
+		manifestType = MediaTypeDockerManifest
+	}
+
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestType,
+		Config:        &Descriptor{MediaType: configType, Digest: Digest(data), Size: int64(len(data))},
+		Layers:        append(append([]Descriptor{}, img.Manifest.Layers...), Descriptor{MediaType: layerType, Digest: layer.Digest, Size: layer.Size}),
+	}
+	return manifest, data, nil
+}
+
+// NewIndex returns a manifest list of platform manifests, in the manifests' format (OCI or Docker)
+func NewIndex(manifests []Descriptor) *Manifest {
+	mediaType := MediaTypeDockerList
+	for _, m := range manifests {
+		if m.MediaType == MediaTypeOCIManifest {
+			mediaType = MediaTypeOCIIndex
+		}
+	}
+	return &Manifest{SchemaVersion: 2, MediaType: mediaType, Manifests: manifests}
+}
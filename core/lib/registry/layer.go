@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+)
+
+// Digest returns the sha256 digest of content
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Layer is a gzipped tar layer written to a temporary file
+type Layer struct {
+	Path   string
+	Digest string
+	DiffID string
+	Size   int64
+}
+
+// Open opens the layer's content for upload
+func (l *Layer) Open() (*os.File, error) {
+	return os.Open(l.Path)
+}
+
+// Remove deletes the layer's temporary file
+func (l *Layer) Remove() {
+	os.Remove(l.Path)
+}
+
+// LayerWriter writes a layer, hashing the tar (diff ID) and the gzipped tar (digest) as it goes
+type LayerWriter struct {
+	*tar.Writer
+	file      *os.File
+	gzip      *gzip.Writer
+	tarHash   hash.Hash
+	gzipHash  hash.Hash
+	gzipCount *countWriter
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// NewLayerWriter creates a layer in a temporary file
+// Entries are added with the embedded tar.Writer; Close finishes the layer
+func NewLayerWriter() (*LayerWriter, error) {
+	file, err := os.CreateTemp("", "lightspeed-layer-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LayerWriter{file: file, tarHash: sha256.New(), gzipHash: sha256.New(), gzipCount: &countWriter{}}
+	w.gzip, _ = gzip.NewWriterLevel(io.MultiWriter(file, w.gzipHash, w.gzipCount), gzip.BestCompression)
+	w.Writer = tar.NewWriter(io.MultiWriter(w.gzip, w.tarHash))
+	return w, nil
+}
+
+// Close finishes the layer and returns it
+// The layer's file is removed if it can't be finished
+func (w *LayerWriter) Close() (*Layer, error) {
+	err := w.Writer.Close()
+	if err == nil {
+		err = w.gzip.Close()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(w.file.Name())
+		return nil, err
+	}
+
+	return &Layer{
+		Path:   w.file.Name(),
+		Digest: "sha256:" + hex.EncodeToString(w.gzipHash.Sum(nil)),
+		DiffID: "sha256:" + hex.EncodeToString(w.tarHash.Sum(nil)),
+		Size:   w.gzipCount.n,
+	}, nil
+}
+
+// Abort discards an unfinished layer
+func (w *LayerWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,101 @@
+// Package registry pulls and pushes images with the registry HTTP API, without Docker
+package registry
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// dockerHub is the registry of image names without a registry host
+const dockerHub = "registry-1.docker.io"
+
+// Reference is a parsed image reference (registry/repository:tag or @digest)
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference, filling in Docker Hub and the latest tag
+func ParseReference(ref string) (Reference, error) {
+	if ref == "" || strings.ContainsAny(ref, " \t") {
+		return Reference{}, fmt.Errorf("invalid image reference '%s'", ref)
+	}
+
+	var r Reference
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.Digest = name[:i], name[i+1:]
+	}
+	// A tag follows the last colon after the last slash (a colon before it is a registry port)
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:i], name[i+1:]
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	} else {
+		r.Registry, r.Repository = dockerHub, name
+		if !found {
+			r.Repository = "library/" + name
+		}
+	}
+
+	if r.Repository == "" {
+		return Reference{}, fmt.Errorf("invalid image reference '%s'", ref)
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	return r, nil
+}
+
+// Reference returns the tag or digest the reference points to (the digest if it has both)
+func (r Reference) Reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String returns the reference as registry/repository:tag (or @digest)
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// NormalizeHost returns the name a registry host is known by: lowercase, with Docker Hub's
+// aliases as docker.io, so credentials can be looked up by host
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	switch host {
+	case dockerHub, "index.docker.io":
+		return "docker.io"
+	}
+	return host
+}
+
+// BaseURL returns the URL of a registry host
+// Local hosts and explicit ports other than 443 and 8443 use HTTP, like the operator API
+func BaseURL(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return "https://" + host
+	}
+	if hostname == "localhost" || hostname == "127.0.0.1" || hostname == "::1" {
+		return "http://" + host
+	}
+	if port == "443" || port == "8443" {
+		return "https://" + host
+	}
+	return "http://" + host
+}
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Level is how much detail is printed
+type Level int
+
+const (
+	LevelQuiet   Level = -1 // Results, warnings and errors only
+	LevelNormal  Level = 0  // Also the banner and info lines
+	LevelVerbose Level = 1  // Also debug lines, such as commands run and API calls
+)
+
+var level = LevelNormal
+
+// plain turns off colors and animation, even on a terminal
+var plain bool
+
+// SetLevel sets how much detail is printed
+func SetLevel(l Level) {
+	level = l
+}
+
+// SetPlain prints output without colors or animated spinners, like it is in CI logs
+func SetPlain() {
+	plain = true
+	lipgloss.SetColorProfile(termenv.Ascii)
+}
+
+// Quiet checks if info lines are suppressed
+func Quiet() bool {
+	return level < LevelNormal
+}
+
+// Verbose checks if debug lines are printed
+func Verbose() bool {
+	return level > LevelNormal
+}
+
+// PrintDebug prints a debug line to stderr when verbose, so it never mixes with a command's results
+func PrintDebug(format string, a ...interface{}) {
+	if !Verbose() {
+		return
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	beforePrint()
+	defer afterPrint()
+	fmt.Fprintln(os.Stderr, MutedStyle.Render("· "+fmt.Sprintf(format, a...)))
+}
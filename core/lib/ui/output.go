@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"sync"
+)
+
+// outputMu serializes writes so concurrent outputs don't interleave within a line
+var outputMu sync.Mutex
+
+// Output prints styled messages with an optional prefix
+// Used to multiplex progress from concurrent operations onto one terminal
+type Output struct {
+	prefix string
+}
+
+// Stdout is the default unprefixed output
+var Stdout = &Output{}
+
+// NewOutput creates an output that prefixes every line with the given label
+func NewOutput(prefix string) *Output {
+	return &Output{prefix: prefix}
+}
+
+// Prefix returns the output prefix (empty for unprefixed output)
+func (o *Output) Prefix() string {
+	return o.prefix
+}
+
+func (o *Output) println(line string) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	beforePrint()
+	defer afterPrint()
+
+	if o.prefix != "" {
+		fmt.Println(HighlightStyle.Render("["+o.prefix+"]") + " " + line)
+		return
+	}
+	fmt.Println(line)
+}
+
+// Println prints a plain line
+func (o *Output) Println(line string) {
+	o.println(line)
+}
+
+// PrintSuccess prints a success message with checkmark
+func (o *Output) PrintSuccess(format string, a ...interface{}) {
+	o.println(SuccessStyle.Render("✓ " + fmt.Sprintf(format, a...)))
+}
+
+// PrintError prints an error message with X mark
+func (o *Output) PrintError(format string, a ...interface{}) {
+	o.println(ErrorStyle.Render("✗ " + fmt.Sprintf(format, a...)))
+}
+
+// PrintWarning prints a warning message
+func (o *Output) PrintWarning(format string, a ...interface{}) {
+	o.println(WarningStyle.Render("⚠ " + fmt.Sprintf(format, a...)))
+}
+
+// PrintInfo prints an info message (not when quiet)
+func (o *Output) PrintInfo(format string, a ...interface{}) {
+	if Quiet() {
+		return
+	}
+	o.println(InfoStyle.Render("• " + fmt.Sprintf(format, a...)))
+}
+
+// PrintKeyValue prints a formatted key-value pair
+func (o *Output) PrintKeyValue(key, value string) {
+	o.println(fmt.Sprintf("%s: %s", KeyStyle.Render(key), ValueStyle.Render(value)))
+}
+
+// Blank prints an empty line (skipped for prefixed or quiet output to keep logs compact)
+func (o *Output) Blank() {
+	if o.prefix != "" || Quiet() {
+		return
+	}
+	o.println("")
+}
@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// spinnerFrames are the frames of a spinner's animation
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// spinnerInterval is how often a spinner is redrawn
+const spinnerInterval = 100 * time.Millisecond
+
+// progressWidth is the number of cells of a progress bar
+const progressWidth = 24
+
+// activeSpinner is the spinner drawn on the last line of the terminal (guarded by outputMu)
+var activeSpinner *Spinner
+
+// Spinner shows that a long operation is running: an animated line with the time it has taken,
+// and a progress bar once its progress is known. Lines printed while it runs appear above it.
+// Without a terminal (CI logs, quiet or prefixed output) only the message is printed, once.
+type Spinner struct {
+	out     *Output
+	message string
+	done    int64
+	total   int64
+	start   time.Time
+	frame   int
+	animate bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// StartSpinner starts a spinner with a message
+func StartSpinner(format string, a ...interface{}) *Spinner {
+	return Stdout.StartSpinner(format, a...)
+}
+
+// StartSpinner starts a spinner with a message
+// Prefixed outputs are multiplexed with other outputs, so they only print the message
+func (o *Output) StartSpinner(format string, a ...interface{}) *Spinner {
+	s := &Spinner{
+		out:     o,
+		message: fmt.Sprintf(format, a...),
+		start:   time.Now(),
+		animate: o.Animated(),
+	}
+	if !s.animate {
+		o.PrintInfo("%s...", s.message)
+		return s
+	}
+
+	outputMu.Lock()
+	if activeSpinner != nil {
+		// Only one line can be animated; a nested operation takes it over
+		activeSpinner.animate = false
+		close(activeSpinner.stop)
+	}
+	activeSpinner = s
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.render()
+	outputMu.Unlock()
+
+	go s.run()
+	return s
+}
+
+// Interactive checks if stdout is a terminal, where output can be redrawn in place
+// Plain output is never redrawn
+func Interactive() bool {
+	info, err := os.Stdout.Stat()
+	return !plain && err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Animated checks if spinners of the output are animated
+func (o *Output) Animated() bool {
+	return o.prefix == "" && !Quiet() && Interactive()
+}
+
+// Animated checks if the spinner is drawn, rather than printed once
+func (s *Spinner) Animated() bool {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return s.animate
+}
+
+// Update changes the spinner's message
+// Without a terminal nothing is printed, so callers print the changes worth logging themselves
+func (s *Spinner) Update(format string, a ...interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	s.message = fmt.Sprintf(format, a...)
+	if s.animate {
+		s.render()
+	}
+}
+
+// Restart changes the spinner's message and restarts its timer, for the next phase of an operation
+func (s *Spinner) Restart(format string, a ...interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	s.message = fmt.Sprintf(format, a...)
+	s.start = time.Now()
+	s.done, s.total = 0, 0
+	if s.animate {
+		s.render()
+	}
+}
+
+// Progress sets how much of the operation is done, shown as a progress bar
+func (s *Spinner) Progress(done, total int64) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	s.done, s.total = done, total
+	if s.animate && activeSpinner == s {
+		s.render()
+	}
+}
+
+// Elapsed returns the time since the spinner started
+func (s *Spinner) Elapsed() time.Duration {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return time.Since(s.start)
+}
+
+// Stop stops the spinner and clears its line
+func (s *Spinner) Stop() {
+	outputMu.Lock()
+	if !s.animate || activeSpinner != s {
+		outputMu.Unlock()
+		return
+	}
+	activeSpinner = nil
+	close(s.stop)
+	clearLine()
+	outputMu.Unlock()
+
+	<-s.stopped
+}
+
+// run redraws the spinner until it's stopped
+func (s *Spinner) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			outputMu.Lock()
+			if activeSpinner == s {
+				s.frame = (s.frame + 1) % len(spinnerFrames)
+				s.render()
+			}
+			outputMu.Unlock()
+		}
+	}
+}
+
+// render draws the spinner over the current line (caller must hold outputMu)
+func (s *Spinner) render() {
+	line := InfoStyle.Render(spinnerFrames[s.frame]+" "+s.message) + " " + MutedStyle.Render(FormatElapsed(time.Since(s.start)))
+	if s.total > 0 {
+		line += " " + ProgressBar(s.done, s.total, progressWidth) + " " + MutedStyle.Render(fmt.Sprintf("%d/%d", s.done, s.total))
+	}
+	fmt.Fprint(os.Stdout, "\r\033[K"+line)
+}
+
+// clearLine clears the line the spinner is drawn on (caller must hold outputMu)
+func clearLine() {
+	fmt.Fprint(os.Stdout, "\r\033[K")
+}
+
+// beforePrint clears the spinner's line so a line can be printed in its place (caller must hold outputMu)
+func beforePrint() {
+	if activeSpinner != nil {
+		clearLine()
+	}
+}
+
+// afterPrint draws the spinner again below the printed line (caller must hold outputMu)
+func afterPrint() {
+	if activeSpinner != nil {
+		activeSpinner.render()
+	}
+}
+
+// ProgressBar returns a bar of width cells filled in proportion to done out of total
+func ProgressBar(done, total int64, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = int(done * int64(width) / total)
+	}
+	if filled > width {
+		filled = width
+	}
+	return HighlightStyle.Render(strings.Repeat("█", filled)) + MutedStyle.Render(strings.Repeat("░", width-filled))
+}
+
+// FormatElapsed formats a duration as m:ss (or h:mm:ss)
+func FormatElapsed(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -79,8 +79,11 @@ func PrintVersion(version string) {
 	fmt.Println(VersionLine(version))
 }
 
-// PrintHeader prints the full header with banner, dividers, and version
+// PrintHeader prints the full header with banner, dividers, and version (not when quiet)
 func PrintHeader(version string) {
+	if Quiet() {
+		return
+	}
 	fmt.Println()
 	fmt.Println(Divider())
 	fmt.Println(Banner())
@@ -97,31 +100,27 @@ func Header(text string) string {
 
 // PrintSuccess prints a success message with checkmark
 func PrintSuccess(format string, a ...interface{}) {
-	msg := fmt.Sprintf(format, a...)
-	fmt.Println(SuccessStyle.Render("✓ " + msg))
+	Stdout.PrintSuccess(format, a...)
 }
 
 // PrintError prints an error message with X mark
 func PrintError(format string, a ...interface{}) {
-	msg := fmt.Sprintf(format, a...)
-	fmt.Println(ErrorStyle.Render("✗ " + msg))
+	Stdout.PrintError(format, a...)
 }
 
 // PrintWarning prints a warning message
 func PrintWarning(format string, a ...interface{}) {
-	msg := fmt.Sprintf(format, a...)
-	fmt.Println(WarningStyle.Render("⚠ " + msg))
+	Stdout.PrintWarning(format, a...)
 }
 
-// PrintInfo prints an info message
+// PrintInfo prints an info message (not when quiet)
 func PrintInfo(format string, a ...interface{}) {
-	msg := fmt.Sprintf(format, a...)
-	fmt.Println(InfoStyle.Render("• " + msg))
+	Stdout.PrintInfo(format, a...)
 }
 
 // PrintKeyValue prints a formatted key-value pair
 func PrintKeyValue(key, value string) {
-	fmt.Printf("%s: %s\n", KeyStyle.Render(key), ValueStyle.Render(value))
+	Stdout.PrintKeyValue(key, value)
 }
 
 // Highlight returns highlighted text
@@ -84,3 +84,24 @@ func IsGitRepo(dir string) bool {
 	cmd.Dir = dir
 	return cmd.Run() == nil
 }
+
+// GetCommit gets the short SHA of the checked out commit
+// A timestamp is appended if there are uncommitted changes, as in GetFromGit
+func GetCommit(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit: %w", err)
+	}
+	sha := strings.TrimSpace(string(output))
+
+	cmd = exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	output, err = cmd.Output()
+	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
+		sha = fmt.Sprintf("%s-%s", sha, time.Now().Format("01021504"))
+	}
+
+	return sha, nil
+}
@@ -14,7 +14,9 @@ RED='\033[0;31m'
 NC='\033[0m'
 
 # Parse arguments
+# App Platform runs linux/amd64; add linux/arm64 to run the operator image on ARM hosts too
 COMPONENTS="site,operator"
+PLATFORMS="${PLATFORMS:-linux/amd64}"
 while [[ $# -gt 0 ]]; do
     case $1 in
         --components=*)
@@ -25,9 +27,17 @@ while [[ $# -gt 0 ]]; do
             COMPONENTS="$2"
             shift 2
             ;;
+        --platforms=*)
+            PLATFORMS="${1#*=}"
+            shift
+            ;;
+        --platforms)
+            PLATFORMS="$2"
+            shift 2
+            ;;
         *)
             echo -e "${RED}Unknown argument: $1${NC}"
-            echo "Usage: $0 [--components=site,operator]"
+            echo "Usage: $0 [--components=site,operator] [--platforms=linux/amd64,linux/arm64]"
             exit 1
             ;;
     esac
@@ -61,6 +71,7 @@ LATEST_TAG="${REGISTRY}/${REPO}/${IMAGE}:latest"
 echo -e "${BLUE}Version:${NC}  ${VERSION}"
 echo -e "${BLUE}Registry:${NC} ${REGISTRY}/${REPO}"
 echo -e "${BLUE}Image:${NC}    ${IMAGE}"
+echo -e "${BLUE}Platforms:${NC} ${PLATFORMS}"
 echo ""
 
 # Deploy lightspeed website first (before operator deployment)
@@ -104,7 +115,7 @@ if [[ "$COMPONENTS" == *"operator"* ]]; then
     # Generate Dockerfile
     echo -e "${BLUE}Generating Dockerfile...${NC}"
     cat > "$WORK_DIR/Dockerfile" << 'DOCKERFILE'
-FROM golang:1.21-alpine AS builder
+FROM --platform=$BUILDPLATFORM golang:1.21-alpine AS builder
 
 WORKDIR /app
 
@@ -116,9 +127,11 @@ RUN go mod download
 COPY core/ core/
 COPY platform/operator/ platform/operator/
 
-# Build operator
+# Build operator (cross-compiled for the target platform, so no emulation is needed)
 ARG VERSION=dev
-RUN CGO_ENABLED=0 GOOS=linux go build \
+ARG TARGETOS=linux
+ARG TARGETARCH=amd64
+RUN CGO_ENABLED=0 GOOS=${TARGETOS} GOARCH=${TARGETARCH} go build \
     -ldflags "-X main.Version=${VERSION}" \
     -o /operator \
     ./platform/operator
@@ -135,22 +148,6 @@ EXPOSE 8080
 ENTRYPOINT ["operator"]
 DOCKERFILE
 
-# Build the Docker image
-echo -e "${YELLOW}Building Docker image...${NC}"
-echo ""
-
-docker build \
-    --platform linux/amd64 \
-    --build-arg VERSION="${VERSION}" \
-    -t "${VERSION_TAG}" \
-    -t "${LATEST_TAG}" \
-    -f "$WORK_DIR/Dockerfile" \
-    .
-
-echo ""
-echo -e "${GREEN}✓ Built: ${VERSION_TAG}${NC}"
-echo ""
-
 # Login to registry
 TOKEN="${DIGITALOCEAN_TOKEN:-$TOKEN}"  # Support both names for backwards compatibility
 if [ -n "$TOKEN" ]; then
@@ -161,16 +158,51 @@ else
     echo -e "${GRAY}No DIGITALOCEAN_TOKEN env var set, assuming already logged in${NC}"
 fi
 
-# Push to registry
-echo -e "${YELLOW}Pushing to registry...${NC}"
-echo ""
+if [[ "$PLATFORMS" == *","* ]]; then
+    # A multi-arch image can't be loaded locally, so buildx pushes it as it builds
+    echo -e "${YELLOW}Building and pushing multi-arch Docker image...${NC}"
+    echo ""
 
-docker push "${VERSION_TAG}"
-docker push "${LATEST_TAG}"
+    docker buildx build \
+        --platform "${PLATFORMS}" \
+        --build-arg VERSION="${VERSION}" \
+        -t "${VERSION_TAG}" \
+        -t "${LATEST_TAG}" \
+        -f "$WORK_DIR/Dockerfile" \
+        --push \
+        .
 
-echo ""
-echo -e "${GREEN}✓ Pushed images${NC}"
-echo ""
+    echo ""
+    echo -e "${GREEN}✓ Built and pushed: ${VERSION_TAG} (${PLATFORMS})${NC}"
+    echo ""
+else
+    # Build the Docker image
+    echo -e "${YELLOW}Building Docker image...${NC}"
+    echo ""
+
+    docker build \
+        --platform "${PLATFORMS}" \
+        --build-arg VERSION="${VERSION}" \
+        -t "${VERSION_TAG}" \
+        -t "${LATEST_TAG}" \
+        -f "$WORK_DIR/Dockerfile" \
+        .
+
+    echo ""
+    echo -e "${GREEN}✓ Built: ${VERSION_TAG}${NC}"
+    echo ""
+
+    # Push to registry
+    echo -e "${YELLOW}Pushing to registry...${NC}"
+    echo ""
+
+    docker push "${VERSION_TAG}"
+    docker push "${LATEST_TAG}"
+
+    echo ""
+    echo -e "${GREEN}✓ Pushed images${NC}"
+    echo ""
+fi
 
 # Check/create DigitalOcean App
 APP_NAME="lightspeed-operator"
@@ -0,0 +1,1550 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// Backend is the platform the CLI manages sites on
+// Commands talk to a Backend instead of making API calls directly, so site
+// management is implemented once regardless of where sites are hosted
+type Backend interface {
+	// ListSites lists every site
+	ListSites(ctx context.Context) (*api.SiteList, error)
+	// SiteExists checks if a site exists
+	SiteExists(ctx context.Context, name string) (bool, error)
+	// CreateSite creates a new site running the given image tag
+	CreateSite(ctx context.Context, site api.Site) (*api.SiteResponse, error)
+	// GetSiteStatus gets the current status of a site
+	GetSiteStatus(ctx context.Context, name string) (*api.SiteResponse, error)
+	// DeleteSite deletes a site, and optionally its image repository and DNS records
+	DeleteSite(ctx context.Context, name string, opts DeleteOptions) error
+	// TriggerDeploy starts a new deployment of a site, pinned to the request's tag if it's set
+	// and adding or updating the request's service if it has one
+	TriggerDeploy(ctx context.Context, name string, deploy api.DeployRequest) (*api.Deployment, error)
+	// PlanCreateSite, PlanDeploy, PlanUpdateSite and PlanDeleteSite make their request as a dry run,
+	// returning what it would do without changing anything
+	PlanCreateSite(ctx context.Context, site api.Site) (*api.DryRun, error)
+	PlanDeploy(ctx context.Context, name string, deploy api.DeployRequest) (*api.DryRun, error)
+	PlanUpdateSite(ctx context.Context, name string, update api.SiteUpdate) (*api.DryRun, error)
+	PlanDeleteSite(ctx context.Context, name string, opts DeleteOptions) (*api.DryRun, error)
+	// ListTags lists the tags of a site's image repository, newest first
+	ListTags(ctx context.Context, name string) (*api.TagList, error)
+	// ListDeployments lists a site's recent deployments, newest first (0 for the operator's default limit)
+	ListDeployments(ctx context.Context, name string, limit int) (*api.SiteDeploymentList, error)
+	// AllocateTag allocates the next build number (strategy build) or dated tag (strategy date) of a site
+	AllocateTag(ctx context.Context, name, strategy string) (*api.TagAllocation, error)
+	// ListDomains lists the domains routed to a site
+	ListDomains(ctx context.Context, name string) (*api.SiteDomainList, error)
+	// ListDomainRecords lists the domains of a site with the records the operator's zones hold for them
+	ListDomainRecords(ctx context.Context, name string) (*api.SiteDomainList, error)
+	// AddDomain adds a custom domain to a site, creating its DNS record if the operator manages the zone
+	AddDomain(ctx context.Context, name, domain string) (*api.SiteDomain, error)
+	// RemoveDomain removes a custom domain from a site
+	RemoveDomain(ctx context.Context, name, domain string) error
+	// ListEnv lists a site's environment variables
+	ListEnv(ctx context.Context, name string) (*api.EnvList, error)
+	// UpdateEnv sets and unsets a site's environment variables, which redeploys it
+	UpdateEnv(ctx context.Context, name string, update api.EnvUpdate) (*api.EnvList, error)
+	// GetIngress gets a site's routing rules
+	GetIngress(ctx context.Context, name string) (*api.IngressRules, error)
+	// SetIngress replaces a site's routing rules, which redeploys it
+	SetIngress(ctx context.Context, name string, rules []api.IngressRule) (*api.IngressRules, error)
+	// GetDatabase gets a site's managed database with its connection details
+	GetDatabase(ctx context.Context, name string) (*api.Database, error)
+	// CreateDatabase provisions a site's managed database and sets its connection variables, which redeploys it
+	CreateDatabase(ctx context.Context, name string, req api.DatabaseRequest) (*api.Database, error)
+	// DeleteDatabase deletes a site's managed database and its data, and removes its connection variables
+	DeleteDatabase(ctx context.Context, name string) error
+	// GetCache gets a site's Redis/Valkey cache with its connection details
+	GetCache(ctx context.Context, name string) (*api.Cache, error)
+	// CreateCache provisions a site's cache and sets its connection variables, which redeploys it
+	CreateCache(ctx context.Context, name string, req api.CacheRequest) (*api.Cache, error)
+	// DeleteCache deletes a site's cache and its data, and removes its connection variables
+	DeleteCache(ctx context.Context, name string) error
+	// ListQueues gets the job counts of a site's queues
+	ListQueues(ctx context.Context, name string) (*api.QueueList, error)
+	// PushJob pushes a job to one of a site's queues
+	PushJob(ctx context.Context, name, queue string, push api.QueuePush) (*api.QueueJob, error)
+	// ListFailedJobs gets the failed jobs of one of a site's queues
+	ListFailedJobs(ctx context.Context, name, queue string) ([]api.QueueJob, error)
+	// RetryJobs makes the failed jobs of one of a site's queues ready again, returning how many
+	RetryJobs(ctx context.Context, name, queue string) (int, error)
+	// PurgeQueue deletes every job of one of a site's queues
+	PurgeQueue(ctx context.Context, name, queue string) error
+	// GetWorker gets a site's queue worker
+	GetWorker(ctx context.Context, name string) (*api.SiteWorker, error)
+	// SetWorker adds or updates a site's queue worker, which redeploys it
+	SetWorker(ctx context.Context, name string, worker api.SiteWorker) (*api.SiteWorker, error)
+	// DeleteWorker removes a site's queue worker, which redeploys it
+	DeleteWorker(ctx context.Context, name string) error
+	// UpdateSite changes a site's instance count or size, which redeploys it
+	UpdateSite(ctx context.Context, name string, update api.SiteUpdate) (*api.SiteResponse, error)
+	// GetSiteSLO gets a site's availability this month against an SLO target (0 for the operator default)
+	GetSiteSLO(ctx context.Context, name string, target float64) (*api.SiteSLO, error)
+	// GetCertificates gets the TLS certificate state of each of a site's domains
+	GetCertificates(ctx context.Context, name string) (*api.SiteCertificateList, error)
+	// GetChecks gets a site's synthetic checks and their last results
+	GetChecks(ctx context.Context, name string) (*api.SiteChecks, error)
+	// SetChecks replaces a site's synthetic checks (removing them if empty)
+	SetChecks(ctx context.Context, name string, checks []api.SyntheticCheck) (*api.SiteChecks, error)
+	// GetCron gets a site's cron jobs and their last results
+	GetCron(ctx context.Context, name string) (*api.SiteCron, error)
+	// SetCron replaces a site's cron jobs (removing them if empty)
+	SetCron(ctx context.Context, name string, jobs []api.CronJob) (*api.SiteCron, error)
+	// RunCronJob runs one of a site's cron jobs now
+	RunCronJob(ctx context.Context, name, job string) (*api.CronResult, error)
+	// SetEdge replaces a site's edge mode settings (turning edge mode off if not enabled)
+	SetEdge(ctx context.Context, name string, edge api.SiteEdge) (*api.SiteEdge, error)
+	// GetFirewall gets the IP rules of a site in edge mode
+	GetFirewall(ctx context.Context, name string) (*api.SiteFirewall, error)
+	// SetFirewall replaces the IP rules of a site in edge mode (removing them if both lists are empty)
+	SetFirewall(ctx context.Context, name string, firewall api.SiteFirewall) (*api.SiteFirewall, error)
+	// CancelDeployment cancels the in-progress deployment of a site
+	CancelDeployment(ctx context.Context, name string) error
+	// StreamLogs opens a stream of a site's build, deploy or run logs
+	StreamLogs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error)
+	// SetProxy turns CDN proxy mode of a site's domain on or off
+	SetProxy(ctx context.Context, name string, enabled bool) (*api.Proxy, error)
+	// PurgeCache purges the CDN cache of a site's domain
+	PurgeCache(ctx context.Context, name string) (*api.CachePurge, error)
+	// SetupEmail provisions the SPF, DKIM and DMARC records for sending mail from a site's domain
+	SetupEmail(ctx context.Context, name string, setup api.EmailSetup) (*api.EmailSetupResponse, error)
+	// ListDNSRecords lists the DNS records in a site's domain
+	ListDNSRecords(ctx context.Context, name string) (*api.DNSRecordList, error)
+	// AddDNSRecord adds a DNS record in a site's domain
+	AddDNSRecord(ctx context.Context, name string, record api.DNSRecord) (*api.DNSRecord, error)
+	// DeleteDNSRecord deletes the DNS record matching type, name and (if set) content
+	DeleteDNSRecord(ctx context.Context, name string, record api.DNSRecord) error
+	// InspectImage gets the manifest details of a pushed image
+	InspectImage(ctx context.Context, repository, tag string) (*api.Image, error)
+	// ListTemplates lists the site templates in the catalog
+	ListTemplates(ctx context.Context) (*api.TemplateList, error)
+	// GetTemplate gets a site template from the catalog
+	GetTemplate(ctx context.Context, name string) (*api.Template, error)
+	// GetPullSecret gets the operator's login for the registry of a base image (nil if it has none)
+	GetPullSecret(ctx context.Context, image string) (*api.PullSecret, error)
+	// Health gets the platform health, including registry garbage collection state
+	Health(ctx context.Context) (*api.Health, error)
+	// RegisterBaseDomain registers a tenant base domain with its DNS provider token
+	RegisterBaseDomain(ctx context.Context, domain api.BaseDomain) (*api.BaseDomain, error)
+	// GetBaseDomainSetup gets the steps to delegate a domain so it can be registered
+	GetBaseDomainSetup(ctx context.Context, domain string) (*api.BaseDomainSetup, error)
+	// StartLogin starts a device code login
+	StartLogin(ctx context.Context, name string) (*api.DeviceCode, error)
+	// PollLogin gets the access token of an approved device code (errLoginPending until approved)
+	PollLogin(ctx context.Context, deviceCode string) (*api.AccessToken, error)
+	// WhoAmI describes the backend's access token
+	WhoAmI(ctx context.Context) (*api.AccessToken, error)
+	// Logout revokes the backend's access token
+	Logout(ctx context.Context) error
+}
+
+// errLoginPending is returned while a device code login waits for approval
+var errLoginPending = errors.New("login not approved yet")
+
+// errDryRunUnsupported is returned by the Plan methods for operators without dry runs
+var errDryRunUnsupported = errors.New("the operator doesn't support dry runs (update it)")
+
+// LogOptions selects which site logs to stream
+type LogOptions struct {
+	Type   string // build, deploy or run
+	Tail   int    // last N lines of the existing logs (0 for all)
+	Follow bool   // keep streaming new lines
+}
+
+// DeleteOptions selects what is deleted along with a site
+type DeleteOptions struct {
+	Image bool // Registry repository of the site's image
+	DNS   bool // DNS records on the site's domain
+}
+
+// newBackend returns the backend for the configured API host, authenticated with the
+// access token from lightspeed login
+func newBackend() Backend {
+	return newOperatorBackend(getAPIURL(), accessToken())
+}
+
+// operatorBackend manages sites through the Lightspeed operator API
+type operatorBackend struct {
+	url    string
+	token  string
+	client *http.Client
+
+	dryRunChecked bool // The operator answers dry runs (checked once)
+}
+
+// newOperatorBackend creates a backend for the operator at the given URL
+func newOperatorBackend(url, token string) *operatorBackend {
+	client := &http.Client{}
+
+	// Local operators use self-signed certificates
+	if isLocalAPIURL(url) {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	client.Transport = operatorTransport(client.Transport)
+
+	return &operatorBackend{
+		url:    url,
+		token:  token,
+		client: client,
+	}
+}
+
+// isLocalAPIURL checks if the API URL points to a local development operator
+func isLocalAPIURL(url string) bool {
+	host := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	return strings.HasPrefix(host, "localhost") ||
+		strings.HasPrefix(host, "127.0.0.1") ||
+		strings.HasPrefix(host, "[::1]") ||
+		strings.HasPrefix(host, "host.docker.internal")
+}
+
+// request makes a request to the operator API
+func (b *operatorBackend) request(ctx context.Context, method, path string, payload interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	start := time.Now()
+	resp, err := b.client.Do(req)
+	if err != nil {
+		ui.PrintDebug("%s %s: %v", method, req.URL, err)
+		return nil, err
+	}
+	ui.PrintDebug("%s %s: %s (%v)", method, req.URL, resp.Status, time.Since(start).Round(time.Millisecond))
+	return resp, nil
+}
+
+// apiError builds an error from an unsuccessful API response
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	apiErr := &operatorError{Status: resp.Status, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+
+	var errResp api.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		apiErr.Message = errResp.Error
+		apiErr.Code = errResp.Code
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = api.ErrorCodeForStatus(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// operatorError is an error response from the operator
+// Code is the operator's error code (derived from the status for operators without codes)
+type operatorError struct {
+	Status     string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *operatorError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Status, e.Message)
+}
+
+// ListSites lists every site via the operator API
+func (b *operatorBackend) ListSites(ctx context.Context) (*api.SiteList, error) {
+	resp, err := b.request(ctx, "GET", "/sites", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.SiteList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// SiteExists checks if a site exists via the operator API
+func (b *operatorBackend) SiteExists(ctx context.Context, name string) (bool, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// CreateSite creates a new site via the operator API
+func (b *operatorBackend) CreateSite(ctx context.Context, site api.Site) (*api.SiteResponse, error) {
+	resp, err := b.request(ctx, "POST", "/sites", site)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var created api.SiteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetSiteStatus gets the current status of a site
+func (b *operatorBackend) GetSiteStatus(ctx context.Context, name string) (*api.SiteResponse, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var status api.SiteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// DeleteSite deletes a site via the operator API
+func (b *operatorBackend) DeleteSite(ctx context.Context, name string, opts DeleteOptions) error {
+	query := url.Values{}
+	query.Set("image", strconv.FormatBool(opts.Image))
+	query.Set("dns", strconv.FormatBool(opts.DNS))
+
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// TriggerDeploy triggers a deployment via the operator API
+func (b *operatorBackend) TriggerDeploy(ctx context.Context, name string, deploy api.DeployRequest) (*api.Deployment, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/deploy", deploy)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var deployment api.Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deployment); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+// ListTags lists the tags of a site's image repository via the operator API
+func (b *operatorBackend) ListTags(ctx context.Context, name string) (*api.TagList, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.TagList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ListDeployments lists a site's recent deployments via the operator API
+func (b *operatorBackend) ListDeployments(ctx context.Context, name string, limit int) (*api.SiteDeploymentList, error) {
+	path := "/sites/" + name + "/deployments"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	resp, err := b.request(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.SiteDeploymentList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// AllocateTag allocates a site's next tag via the operator API
+func (b *operatorBackend) AllocateTag(ctx context.Context, name, strategy string) (*api.TagAllocation, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/tags/next?strategy="+url.QueryEscape(strategy), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var allocation api.TagAllocation
+	if err := json.NewDecoder(resp.Body).Decode(&allocation); err != nil {
+		return nil, err
+	}
+
+	return &allocation, nil
+}
+
+// ListDomains lists the domains of a site via the operator API
+func (b *operatorBackend) ListDomains(ctx context.Context, name string) (*api.SiteDomainList, error) {
+	return b.listDomains(ctx, "/sites/"+name+"/domains")
+}
+
+// ListDomainRecords lists the domains of a site with their managed DNS records via the operator API
+func (b *operatorBackend) ListDomainRecords(ctx context.Context, name string) (*api.SiteDomainList, error) {
+	return b.listDomains(ctx, "/sites/"+name+"/domains?records=true")
+}
+
+func (b *operatorBackend) listDomains(ctx context.Context, path string) (*api.SiteDomainList, error) {
+	resp, err := b.request(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.SiteDomainList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// AddDomain adds a custom domain to a site via the operator API
+func (b *operatorBackend) AddDomain(ctx context.Context, name, domain string) (*api.SiteDomain, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/domains", api.SiteDomain{Domain: domain})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var added api.SiteDomain
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return nil, err
+	}
+
+	return &added, nil
+}
+
+// RemoveDomain removes a custom domain from a site via the operator API
+func (b *operatorBackend) RemoveDomain(ctx context.Context, name, domain string) error {
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/domains?domain="+url.QueryEscape(domain), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// ListEnv lists a site's environment variables via the operator API
+func (b *operatorBackend) ListEnv(ctx context.Context, name string) (*api.EnvList, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/env", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.EnvList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// UpdateEnv changes a site's environment variables via the operator API
+func (b *operatorBackend) UpdateEnv(ctx context.Context, name string, update api.EnvUpdate) (*api.EnvList, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/env", update)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.EnvList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetIngress gets a site's routing rules via the operator API
+func (b *operatorBackend) GetIngress(ctx context.Context, name string) (*api.IngressRules, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/ingress", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var rules api.IngressRules
+	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+// SetIngress replaces a site's routing rules via the operator API
+func (b *operatorBackend) SetIngress(ctx context.Context, name string, rules []api.IngressRule) (*api.IngressRules, error) {
+	resp, err := b.request(ctx, "PUT", "/sites/"+name+"/ingress", api.IngressRules{Rules: rules})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var updated api.IngressRules
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// GetDatabase gets a site's managed database via the operator API
+func (b *operatorBackend) GetDatabase(ctx context.Context, name string) (*api.Database, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/db", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var db api.Database
+	if err := json.NewDecoder(resp.Body).Decode(&db); err != nil {
+		return nil, err
+	}
+
+	return &db, nil
+}
+
+// CreateDatabase provisions a site's managed database via the operator API
+// A site that already has one gets its connection variables set again (200 instead of 201)
+func (b *operatorBackend) CreateDatabase(ctx context.Context, name string, req api.DatabaseRequest) (*api.Database, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/db", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var db api.Database
+	if err := json.NewDecoder(resp.Body).Decode(&db); err != nil {
+		return nil, err
+	}
+
+	return &db, nil
+}
+
+// DeleteDatabase deletes a site's managed database via the operator API
+func (b *operatorBackend) DeleteDatabase(ctx context.Context, name string) error {
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/db", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// GetCache gets a site's cache via the operator API
+func (b *operatorBackend) GetCache(ctx context.Context, name string) (*api.Cache, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/cache", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var cache api.Cache
+	if err := json.NewDecoder(resp.Body).Decode(&cache); err != nil {
+		return nil, err
+	}
+
+	return &cache, nil
+}
+
+// CreateCache provisions a site's cache via the operator API
+// A site that already has one gets its connection variables set again (200 instead of 201)
+func (b *operatorBackend) CreateCache(ctx context.Context, name string, req api.CacheRequest) (*api.Cache, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/cache", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var cache api.Cache
+	if err := json.NewDecoder(resp.Body).Decode(&cache); err != nil {
+		return nil, err
+	}
+
+	return &cache, nil
+}
+
+// DeleteCache deletes a site's cache via the operator API
+func (b *operatorBackend) DeleteCache(ctx context.Context, name string) error {
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/cache", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// ListQueues gets the job counts of a site's queues via the operator API
+func (b *operatorBackend) ListQueues(ctx context.Context, name string) (*api.QueueList, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/queues", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.QueueList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// PushJob pushes a job to a site's queue via the operator API
+func (b *operatorBackend) PushJob(ctx context.Context, name, queue string, push api.QueuePush) (*api.QueueJob, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/queues/"+queue+"/jobs", push)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var job api.QueueJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ListFailedJobs gets the failed jobs of a site's queue via the operator API
+func (b *operatorBackend) ListFailedJobs(ctx context.Context, name, queue string) ([]api.QueueJob, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/queues/"+queue+"/failed", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.QueueJobList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return list.Jobs, nil
+}
+
+// RetryJobs retries the failed jobs of a site's queue via the operator API
+func (b *operatorBackend) RetryJobs(ctx context.Context, name, queue string) (int, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/queues/"+queue+"/retry", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, apiError(resp)
+	}
+
+	var result struct {
+		Retried int `json:"retried"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	return result.Retried, nil
+}
+
+// PurgeQueue deletes every job of a site's queue via the operator API
+func (b *operatorBackend) PurgeQueue(ctx context.Context, name, queue string) error {
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/queues/"+queue, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// GetWorker gets a site's queue worker via the operator API
+func (b *operatorBackend) GetWorker(ctx context.Context, name string) (*api.SiteWorker, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/worker", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var worker api.SiteWorker
+	if err := json.NewDecoder(resp.Body).Decode(&worker); err != nil {
+		return nil, err
+	}
+
+	return &worker, nil
+}
+
+// SetWorker adds or updates a site's queue worker via the operator API
+func (b *operatorBackend) SetWorker(ctx context.Context, name string, worker api.SiteWorker) (*api.SiteWorker, error) {
+	resp, err := b.request(ctx, "PUT", "/sites/"+name+"/worker", worker)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var updated api.SiteWorker
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteWorker removes a site's queue worker via the operator API
+func (b *operatorBackend) DeleteWorker(ctx context.Context, name string) error {
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/worker", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// UpdateSite changes a site's instance count or size via the operator API
+func (b *operatorBackend) UpdateSite(ctx context.Context, name string, update api.SiteUpdate) (*api.SiteResponse, error) {
+	resp, err := b.request(ctx, "PATCH", "/sites/"+name, update)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var site api.SiteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&site); err != nil {
+		return nil, err
+	}
+
+	return &site, nil
+}
+
+// PlanCreateSite validates a new site and returns the spec it would be created with
+func (b *operatorBackend) PlanCreateSite(ctx context.Context, site api.Site) (*api.DryRun, error) {
+	return b.dryRun(ctx, "POST", "/sites", nil, site)
+}
+
+// PlanDeploy returns the spec a deploy would give a site
+func (b *operatorBackend) PlanDeploy(ctx context.Context, name string, deploy api.DeployRequest) (*api.DryRun, error) {
+	return b.dryRun(ctx, "POST", "/sites/"+name+"/deploy", nil, deploy)
+}
+
+// PlanUpdateSite returns the spec scaling a site would give it
+func (b *operatorBackend) PlanUpdateSite(ctx context.Context, name string, update api.SiteUpdate) (*api.DryRun, error) {
+	return b.dryRun(ctx, "PATCH", "/sites/"+name, nil, update)
+}
+
+// PlanDeleteSite returns what deleting a site would remove
+func (b *operatorBackend) PlanDeleteSite(ctx context.Context, name string, opts DeleteOptions) (*api.DryRun, error) {
+	query := url.Values{}
+	query.Set("image", strconv.FormatBool(opts.Image))
+	query.Set("dns", strconv.FormatBool(opts.DNS))
+	return b.dryRun(ctx, "DELETE", "/sites/"+name, query, nil)
+}
+
+// dryRun makes a request with dry_run=true
+// Operators without dry runs would carry the request out, so their health must list the feature first
+func (b *operatorBackend) dryRun(ctx context.Context, method, path string, query url.Values, payload interface{}) (*api.DryRun, error) {
+	if !b.dryRunChecked {
+		health, err := b.Health(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the operator: %w", err)
+		}
+		if !slices.Contains(health.Features, api.FeatureDryRun) {
+			return nil, errDryRunUnsupported
+		}
+		b.dryRunChecked = true
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("dry_run", "true")
+	resp, err := b.request(ctx, method, path+"?"+query.Encode(), payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var plan api.DryRun
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// GetSiteSLO gets a site's availability against an SLO target via the operator API
+func (b *operatorBackend) GetSiteSLO(ctx context.Context, name string, target float64) (*api.SiteSLO, error) {
+	path := "/sites/" + name + "/slo"
+	if target > 0 {
+		path += "?target=" + strconv.FormatFloat(target, 'f', -1, 64)
+	}
+
+	resp, err := b.request(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var slo api.SiteSLO
+	if err := json.NewDecoder(resp.Body).Decode(&slo); err != nil {
+		return nil, err
+	}
+
+	return &slo, nil
+}
+
+// GetCertificates gets the certificates of a site's domains via the operator API
+func (b *operatorBackend) GetCertificates(ctx context.Context, name string) (*api.SiteCertificateList, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/certs", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.SiteCertificateList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetChecks gets a site's synthetic checks via the operator API
+func (b *operatorBackend) GetChecks(ctx context.Context, name string) (*api.SiteChecks, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/checks", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var checks api.SiteChecks
+	if err := json.NewDecoder(resp.Body).Decode(&checks); err != nil {
+		return nil, err
+	}
+
+	return &checks, nil
+}
+
+// SetChecks replaces a site's synthetic checks via the operator API
+func (b *operatorBackend) SetChecks(ctx context.Context, name string, checks []api.SyntheticCheck) (*api.SiteChecks, error) {
+	method := "PUT"
+	var payload interface{} = api.SiteChecks{Checks: checks}
+	if len(checks) == 0 {
+		method, payload = "DELETE", nil
+	}
+
+	resp, err := b.request(ctx, method, "/sites/"+name+"/checks", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.SiteChecks
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCron gets a site's cron jobs via the operator API
+func (b *operatorBackend) GetCron(ctx context.Context, name string) (*api.SiteCron, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/cron", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var cron api.SiteCron
+	if err := json.NewDecoder(resp.Body).Decode(&cron); err != nil {
+		return nil, err
+	}
+
+	return &cron, nil
+}
+
+// SetCron replaces a site's cron jobs via the operator API
+func (b *operatorBackend) SetCron(ctx context.Context, name string, jobs []api.CronJob) (*api.SiteCron, error) {
+	method := "PUT"
+	var payload interface{} = api.SiteCron{Jobs: jobs}
+	if len(jobs) == 0 {
+		method, payload = "DELETE", nil
+	}
+
+	resp, err := b.request(ctx, method, "/sites/"+name+"/cron", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.SiteCron
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RunCronJob runs a site's cron job via the operator API
+func (b *operatorBackend) RunCronJob(ctx context.Context, name, job string) (*api.CronResult, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/cron/"+job, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.CronResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SetEdge replaces a site's edge mode settings via the operator API
+func (b *operatorBackend) SetEdge(ctx context.Context, name string, edge api.SiteEdge) (*api.SiteEdge, error) {
+	method := "PUT"
+	var payload interface{} = edge
+	if !edge.Enabled {
+		method, payload = "DELETE", nil
+	}
+
+	resp, err := b.request(ctx, method, "/sites/"+name+"/edge", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.SiteEdge
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetFirewall gets the IP rules of a site in edge mode via the operator API
+func (b *operatorBackend) GetFirewall(ctx context.Context, name string) (*api.SiteFirewall, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/firewall", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var firewall api.SiteFirewall
+	if err := json.NewDecoder(resp.Body).Decode(&firewall); err != nil {
+		return nil, err
+	}
+
+	return &firewall, nil
+}
+
+// SetFirewall replaces the IP rules of a site in edge mode via the operator API
+func (b *operatorBackend) SetFirewall(ctx context.Context, name string, firewall api.SiteFirewall) (*api.SiteFirewall, error) {
+	method := "PUT"
+	var payload interface{} = firewall
+	if len(firewall.Allow) == 0 && len(firewall.Deny) == 0 {
+		method, payload = "DELETE", nil
+	}
+
+	resp, err := b.request(ctx, method, "/sites/"+name+"/firewall", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.SiteFirewall
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CancelDeployment cancels the in-progress deployment of a site via the operator API
+func (b *operatorBackend) CancelDeployment(ctx context.Context, name string) error {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/cancel", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// StreamLogs opens a stream of a site's logs via the operator API
+// The caller must close the returned stream
+func (b *operatorBackend) StreamLogs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("type", opts.Type)
+	query.Set("tail", strconv.Itoa(opts.Tail))
+	query.Set("follow", strconv.FormatBool(opts.Follow))
+
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// SetProxy turns CDN proxy mode of a site's domain on or off via the operator API
+func (b *operatorBackend) SetProxy(ctx context.Context, name string, enabled bool) (*api.Proxy, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/proxy", api.Proxy{Enabled: enabled})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var proxy api.Proxy
+	if err := json.NewDecoder(resp.Body).Decode(&proxy); err != nil {
+		return nil, err
+	}
+
+	return &proxy, nil
+}
+
+// PurgeCache purges the CDN cache of a site's domain via the operator API
+func (b *operatorBackend) PurgeCache(ctx context.Context, name string) (*api.CachePurge, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/purge", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var purge api.CachePurge
+	if err := json.NewDecoder(resp.Body).Decode(&purge); err != nil {
+		return nil, err
+	}
+
+	return &purge, nil
+}
+
+// SetupEmail provisions email DNS records for a site via the operator API
+func (b *operatorBackend) SetupEmail(ctx context.Context, name string, setup api.EmailSetup) (*api.EmailSetupResponse, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/email", setup)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result api.EmailSetupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListDNSRecords lists the DNS records in a site's domain via the operator API
+func (b *operatorBackend) ListDNSRecords(ctx context.Context, name string) (*api.DNSRecordList, error) {
+	resp, err := b.request(ctx, "GET", "/sites/"+name+"/dns", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.DNSRecordList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// AddDNSRecord adds a DNS record in a site's domain via the operator API
+func (b *operatorBackend) AddDNSRecord(ctx context.Context, name string, record api.DNSRecord) (*api.DNSRecord, error) {
+	resp, err := b.request(ctx, "POST", "/sites/"+name+"/dns", record)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var created api.DNSRecord
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// DeleteDNSRecord deletes a DNS record in a site's domain via the operator API
+func (b *operatorBackend) DeleteDNSRecord(ctx context.Context, name string, record api.DNSRecord) error {
+	query := url.Values{}
+	query.Set("type", record.Type)
+	query.Set("name", record.Name)
+	if record.Content != "" {
+		query.Set("content", record.Content)
+	}
+
+	resp, err := b.request(ctx, "DELETE", "/sites/"+name+"/dns?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
+// InspectImage gets the manifest details of a pushed image via the operator API
+func (b *operatorBackend) InspectImage(ctx context.Context, repository, tag string) (*api.Image, error) {
+	resp, err := b.request(ctx, "GET", "/images/"+repository+"/"+tag, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var image api.Image
+	if err := json.NewDecoder(resp.Body).Decode(&image); err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+// Health gets the operator health via the operator API
+func (b *operatorBackend) Health(ctx context.Context) (*api.Health, error) {
+	resp, err := b.request(ctx, "GET", "/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var health api.Health
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, err
+	}
+
+	return &health, nil
+}
+
+// ListTemplates lists the site templates via the operator API
+func (b *operatorBackend) ListTemplates(ctx context.Context) (*api.TemplateList, error) {
+	resp, err := b.request(ctx, "GET", "/templates", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var list api.TemplateList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetTemplate gets a site template via the operator API
+func (b *operatorBackend) GetTemplate(ctx context.Context, name string) (*api.Template, error) {
+	resp, err := b.request(ctx, "GET", "/templates/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var template api.Template
+	if err := json.NewDecoder(resp.Body).Decode(&template); err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// GetPullSecret gets the login for a base image's registry via the operator API
+func (b *operatorBackend) GetPullSecret(ctx context.Context, image string) (*api.PullSecret, error) {
+	resp, err := b.request(ctx, "POST", "/pull-secrets/resolve", api.PullSecretRequest{Image: image})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var secret api.PullSecret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// RegisterBaseDomain registers a tenant base domain via the operator API
+func (b *operatorBackend) RegisterBaseDomain(ctx context.Context, domain api.BaseDomain) (*api.BaseDomain, error) {
+	resp, err := b.request(ctx, "POST", "/base-domains", domain)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+
+	var registered api.BaseDomain
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return nil, err
+	}
+
+	return &registered, nil
+}
+
+// GetBaseDomainSetup gets the DNS delegation steps for a domain via the operator API
+func (b *operatorBackend) GetBaseDomainSetup(ctx context.Context, domain string) (*api.BaseDomainSetup, error) {
+	resp, err := b.request(ctx, "GET", "/base-domains/"+domain+"/setup", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var setup api.BaseDomainSetup
+	if err := json.NewDecoder(resp.Body).Decode(&setup); err != nil {
+		return nil, err
+	}
+
+	return &setup, nil
+}
+
+// StartLogin starts a device code login via the operator API
+func (b *operatorBackend) StartLogin(ctx context.Context, name string) (*api.DeviceCode, error) {
+	resp, err := b.request(ctx, "POST", "/auth/device", api.DeviceCodeRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var code api.DeviceCode
+	if err := json.NewDecoder(resp.Body).Decode(&code); err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// PollLogin polls a device code login via the operator API
+func (b *operatorBackend) PollLogin(ctx context.Context, deviceCode string) (*api.AccessToken, error) {
+	resp, err := b.request(ctx, "POST", "/auth/token", api.DeviceTokenRequest{DeviceCode: deviceCode})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp api.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil {
+			switch errResp.Error {
+			case "authorization_pending":
+				return nil, errLoginPending
+			case "expired_token":
+				return nil, fmt.Errorf("the login code expired")
+			}
+		}
+		return nil, fmt.Errorf("API error: %s", resp.Status)
+	}
+
+	var token api.AccessToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// WhoAmI describes the access token via the operator API
+func (b *operatorBackend) WhoAmI(ctx context.Context) (*api.AccessToken, error) {
+	resp, err := b.request(ctx, "GET", "/auth/whoami", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var token api.AccessToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Logout revokes the access token via the operator API
+func (b *operatorBackend) Logout(ctx context.Context) error {
+	resp, err := b.request(ctx, "DELETE", "/auth/token", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+	return nil
+}
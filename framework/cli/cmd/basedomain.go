@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	baseDomainToken        string
+	baseDomainProvider     string
+	baseDomainStatusDomain string
+)
+
+var baseDomainCmd = &cobra.Command{
+	Use:   "base-domain <domain>",
+	Short: "Register your own domain for site subdomains",
+	Long:  "Register a domain you own so sites are created as <name>.<domain> instead of <name>.lightspeed.ee. Without --token, prints the steps to delegate the domain's DNS.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+		domain := strings.ToLower(args[0])
+
+		if baseDomainToken == "" {
+			printBaseDomainSetup(ctx, backend, domain)
+			return
+		}
+
+		ui.PrintInfo("Verifying DNS access for %s...", domain)
+		registered, err := backend.RegisterBaseDomain(ctx, api.BaseDomain{
+			Domain:       domain,
+			Provider:     baseDomainProvider,
+			Token:        baseDomainToken,
+			StatusDomain: baseDomainStatusDomain,
+		})
+		if err != nil {
+			ui.PrintError("Failed to register %s: %v", domain, err)
+			fmt.Println()
+			printBaseDomainSetup(ctx, backend, domain)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Registered base domain %s", registered.Domain)
+		fmt.Println()
+		ui.PrintKeyValue("Provider", registered.Provider)
+		if len(registered.NameServers) > 0 {
+			ui.PrintKeyValue("Nameservers", strings.Join(registered.NameServers, ", "))
+			ui.PrintInfo("Make sure your registrar delegates %s to these nameservers", registered.Domain)
+		}
+		if registered.StatusDomain != "" {
+			ui.PrintKeyValue("Status page", "https://"+registered.StatusDomain)
+		}
+		fmt.Println()
+		ui.PrintInfo("Add to site.properties to create sites under it:")
+		fmt.Printf("  base_domain=%s\n", registered.Domain)
+		fmt.Println()
+	},
+}
+
+// printBaseDomainSetup prints the steps to delegate a domain so it can be registered
+func printBaseDomainSetup(ctx context.Context, backend Backend, domain string) {
+	setup, err := backend.GetBaseDomainSetup(ctx, domain)
+	if err != nil {
+		ui.PrintError("Failed to get setup steps for %s: %v", domain, err)
+		os.Exit(1)
+	}
+
+	ui.PrintInfo("To use %s as a base domain:", setup.Domain)
+	for i, step := range setup.Steps {
+		fmt.Printf("  %d. %s\n", i+1, step)
+	}
+	fmt.Println()
+}
+
+func init() {
+	baseDomainCmd.Flags().StringVar(&baseDomainToken, "token", "", "DNS provider API token with DNS edit access to the domain's zone")
+	baseDomainCmd.Flags().StringVar(&baseDomainProvider, "provider", "cloudflare", "DNS provider hosting the domain's zone")
+	baseDomainCmd.Flags().StringVar(&baseDomainStatusDomain, "status-domain", "", "Subdomain to serve the public status page of the domain's sites on (e.g. status.example.com)")
+
+	rootCmd.AddCommand(baseDomainCmd)
+}
@@ -1,16 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"lightspeed/core/lib/properties"
 	"lightspeed/core/lib/ui"
-	"lightspeed/core/lib/version"
 )
 
 var (
@@ -33,12 +34,14 @@ var buildCmd = &cobra.Command{
 	Long:  "Build and tag a Docker container with the PHP project",
 	Run: func(cmd *cobra.Command, args []string) {
 		ui.PrintHeader(Version)
+		timer := newPhaseTimer("build")
 
 		dir, err := os.Getwd()
 		if err != nil {
 			ui.PrintError("Failed to get current directory: %v", err)
 			os.Exit(1)
 		}
+		checkSiteProperties(dir)
 
 		projectName := filepath.Base(dir)
 		imageName := sanitizeContainerName(projectName)
@@ -61,22 +64,25 @@ var buildCmd = &cobra.Command{
 		}
 
 		// Determine tag
-		tag := buildTag
-		if tag == "" {
-			// Try to get version from git
-			if version.IsGitRepo(dir) {
-				v, err := version.GetFromGit(dir)
-				if err == nil {
-					tag = v.String()
-				}
-			}
-			if tag == "" {
-				tag = "latest"
-			}
+		tag, err := resolveTag(cmd.Context(), dir, siteName, buildTag)
+		if err != nil {
+			ui.PrintError("Failed to determine tag: %v", err)
+			os.Exit(1)
 		}
 
 		fullImageName := fmt.Sprintf("%s:%s", siteName, tag)
 
+		// Multi-platform images can't be kept locally, only pushed
+		if _, err := buildPlatforms(); err != nil {
+			ui.PrintError("Invalid --platform: %v", err)
+			os.Exit(1)
+		}
+		if multiPlatform() {
+			ui.PrintError("Images for several platforms can't be stored locally")
+			ui.PrintInfo("Run 'lightspeed publish --platform %s' to build and push them", buildPlatform)
+			os.Exit(1)
+		}
+
 		printSiteInfo(siteName, tag, domains)
 
 		// Get site image for Dockerfile
@@ -85,78 +91,245 @@ var buildCmd = &cobra.Command{
 			siteImage = siteInfo.Image
 		}
 
-		// Check if Dockerfile exists, create if not
-		dockerfilePath := filepath.Join(dir, "Dockerfile")
-		createdDockerfile := false
-		if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
-			ui.PrintInfo("Creating Dockerfile...")
-			if err := createDockerfile(dockerfilePath, siteImage); err != nil {
-				ui.PrintError("Failed to create Dockerfile: %v", err)
-				os.Exit(1)
+		ui.PrintInfo("Building Docker image...")
+		timer.Start("build")
+
+		if err := buildDockerImage(cmd.Context(), dir, siteImage, []string{fullImageName}); err != nil {
+			if interrupted(cmd.Context()) {
+				exitInterrupted("Run 'lightspeed build' to start the build again")
 			}
-			createdDockerfile = true
+			ui.PrintError("Failed to build image: %v", err)
+			os.Exit(exitBuildFailed)
 		}
 
-		ui.PrintInfo("Building Docker image...")
 		fmt.Println()
+		ui.PrintSuccess("Built image: %s", fullImageName)
+		fmt.Println()
+		ui.PrintInfo("Run with: docker run -p 8080:80 %s", fullImageName)
+		fmt.Println()
+		timer.Finish(dir, tag)
 
-		// Build the image for linux/amd64 platform
-		// Use --pull to always get the latest base image
-		dockerArgs := []string{
-			"build",
-			"--pull",
-			"--platform", "linux/amd64",
-			"-t", fullImageName,
-			".",
+		if jsonOutput() {
+			printJSON(buildOutput{Site: siteName, Tag: tag, Image: fullImageName})
 		}
+	},
+}
 
-		dockerCmd := exec.Command("docker", dockerArgs...)
-		dockerCmd.Dir = dir
-		dockerCmd.Stdout = os.Stdout
-		dockerCmd.Stderr = os.Stderr
+// buildOutput is the result of build with --output json
+type buildOutput struct {
+	Site  string `json:"site"`
+	Tag   string `json:"tag"`
+	Image string `json:"image"`
+}
 
-		buildErr := dockerCmd.Run()
+// buildDockerImage builds the project image for the build platforms (linux/amd64 by default)
+// with the given tags
+// If the project doesn't have a Dockerfile, a generated one is written to a temporary directory
+// so nothing is written to the project directory
+// Multi-platform images are built with buildx and pushed by the build (the caller logs in first)
+// Output is saved to ~/.lightspeed/logs and summarized if the build fails
+func buildDockerImage(ctx context.Context, dir, siteImage string, tags []string) error {
+	platforms, err := buildPlatforms()
+	if err != nil {
+		return err
+	}
 
-		// Clean up Dockerfile if we created it
-		if createdDockerfile {
-			os.Remove(dockerfilePath)
+	dockerArgs := []string{"build"}
+	if len(platforms) > 1 {
+		if err := ensureMultiPlatformBuilder(ctx); err != nil {
+			return fmt.Errorf("failed to create buildx builder: %w", err)
 		}
+		ui.PrintInfo("Building for %s...", strings.Join(platforms, ", "))
+		dockerArgs = []string{"buildx", "build", "--builder", multiPlatformBuilder, "--push", "--provenance=false"}
+	}
 
-		if buildErr != nil {
-			ui.PrintError("Failed to build image: %v", buildErr)
-			os.Exit(1)
+	// Use --pull to always get the latest base image
+	// Images carry BuildKit's inline cache, so later builds can reuse their layers with --cache-from
+	dockerArgs = append(dockerArgs,
+		"--pull",
+		"--platform", strings.Join(platforms, ","),
+		"--build-arg", "BUILDKIT_INLINE_CACHE=1",
+	)
+	for _, tag := range tags {
+		dockerArgs = append(dockerArgs, "-t", tag)
+	}
+	if cacheFrom := resolveCacheFrom(dir); cacheFrom != "" {
+		ui.PrintInfo("Reusing layers of %s...", cacheFrom)
+		dockerArgs = append(dockerArgs, "--cache-from", cacheFrom)
+	} else if buildCacheFrom == cachePublished {
+		ui.PrintInfo("Nothing published from this project yet, building without --cache-from")
+	}
+
+	generated, err := generatedFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to generate files: %w", err)
+	}
+	siteInfo, err := loadSiteInfo(dir)
+	if err != nil {
+		return err
+	}
+	if siteInfo != nil {
+		if err := validateSiteType(siteInfo.Type); err != nil {
+			return err
+		}
+	}
+	compress := siteInfo == nil || siteInfo.Compress
+	composer := usesComposer(dir, siteInfo)
+
+	var dockerfile string
+	_, statErr := os.Stat(filepath.Join(dir, "Dockerfile"))
+	generatedDockerfile := os.IsNotExist(statErr)
+	if generatedDockerfile {
+		ui.PrintInfo("Using generated Dockerfile...")
+		baseImage := getBaseImage(siteImage)
+		if isStaticSite(siteInfo) {
+			baseImage = staticBaseImage(siteImage)
+		}
+		// Private base images are pulled with a login from the config or the operator
+		if login := baseImageLogin(ctx, baseImage); login != nil {
+			if err := dockerLoginBase(ctx, login); err != nil {
+				return fmt.Errorf("failed to log in to %s: %w", login.Registry, err)
+			}
 		}
 
-		fmt.Println()
-		ui.PrintSuccess("Built image: %s", fullImageName)
-		fmt.Println()
-		ui.PrintInfo("Run with: docker run -p 8080:80 %s", fullImageName)
-		fmt.Println()
-	},
+		if isStaticSite(siteInfo) {
+			ui.PrintInfo("Building static site (nginx, no PHP)...")
+			dockerfile = generateStaticDockerfile(siteImage, generated, compress)
+		} else {
+			if composer {
+				ui.PrintInfo("Installing Composer dependencies (composer.json)...")
+			}
+			dockerfile = generateDockerfile(siteImage, generated, compress, composer)
+		}
+	} else if len(generated) > 0 {
+		names := make([]string, 0, len(generated))
+		for name := range generated {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		ui.PrintWarning("Not adding %s: generated files need the generated Dockerfile", strings.Join(names, ", "))
+	}
+
+	// The Dockerfile is written outside the project with the ignore patterns of the build
+	ignores, err := buildIgnores(dir, generatedDockerfile)
+	if err != nil {
+		return err
+	}
+	if ignores != "" {
+		if !generatedDockerfile {
+			ui.PrintInfo("Using %s...", lightspeedIgnoreFile)
+			data, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+			if err != nil {
+				return fmt.Errorf("failed to read Dockerfile: %w", err)
+			}
+			dockerfile = string(data)
+		}
+		path, cleanup, err := writeBuildDockerfile(dockerfile, ignores)
+		if err != nil {
+			return fmt.Errorf("failed to write Dockerfile: %w", err)
+		}
+		defer cleanup()
+		dockerArgs = append(dockerArgs, "-f", path)
+	}
+	dockerArgs = append(dockerArgs, ".")
+
+	// Capture output instead of streaming it; it's only shown (summarized) on failure
+	// On a terminal a spinner shows the current build step meanwhile
+	dockerCmd := dockerCommand(ctx, dockerArgs...)
+	dockerCmd.Dir = dir
+	dockerCmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1", "BUILDKIT_PROGRESS=plain")
+
+	var spinner *ui.Spinner
+	if ui.Stdout.Animated() {
+		spinner = ui.StartSpinner("Building")
+	}
+	progress := newBuildProgress(spinner)
+	dockerCmd.Stdout = progress
+	dockerCmd.Stderr = progress
+	err = dockerCmd.Run()
+	if spinner != nil {
+		spinner.Stop()
+	}
+
+	output := progress.output.Bytes()
+	logPath, _ := saveBuildLog(filepath.Base(dir), output)
+	if err != nil && ctx.Err() == nil {
+		printBuildFailure(output, logPath)
+	}
+
+	return err
+}
+
+// resolveSiteName returns the site name for a project directory
+// Priority: explicit name > site.properties name > directory name
+func resolveSiteName(dir, name string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+
+	siteInfo, err := loadSiteInfo(dir)
+	if err != nil {
+		return "", err
+	}
+	if siteInfo != nil && siteInfo.Name != "" {
+		return siteInfo.Name, nil
+	}
+
+	return sanitizeContainerName(filepath.Base(dir)), nil
 }
 
-func createDockerfile(path string, siteImage string) error {
+// generateDockerfile returns the Dockerfile used for projects without their own
+// Generated files (sitemap.xml, robots.txt) are written into the web root by the build,
+// base64 encoded so their content can't break the Dockerfile. With compress, static assets
+// get .gz and .br variants from the base image's precompress script (older images have none).
+// With composer, a build stage installs the production dependencies into vendor/.
+func generateDockerfile(siteImage string, generated map[string]string, compress, composer bool) string {
 	baseImage := getBaseImage(siteImage)
-	content := fmt.Sprintf(`FROM %s
 
-# Copy project files
-COPY . /var/www/html/
+	var stages, steps strings.Builder
+	if composer {
+		stages.WriteString(composerStage)
+		steps.WriteString(composerSteps)
+	}
+	if len(generated) > 0 {
+		names := make([]string, 0, len(generated))
+		for name := range generated {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		steps.WriteString("# Write generated files\n")
+		for _, name := range names {
+			encoded := base64.StdEncoding.EncodeToString([]byte(generated[name]))
+			fmt.Fprintf(&steps, "RUN echo %s | base64 -d > /var/www/html/%s\n", encoded, name)
+		}
+		steps.WriteString("\n")
+	}
+	if compress {
+		steps.WriteString("# Pre-compress static assets\n")
+		steps.WriteString("RUN if [ -x /usr/local/bin/precompress ]; then precompress /var/www/html; fi\n\n")
+	}
 
-# Set proper permissions
-RUN chown -R www-data:www-data /var/www/html
+	return fmt.Sprintf(`# syntax=docker/dockerfile:1
+%sFROM %s
 
-# Expose port 80
-EXPOSE 80
-`, baseImage)
+# Copy project files (owned by www-data without a separate chown layer)
+COPY --chown=www-data:www-data . /var/www/html/
 
-	return os.WriteFile(path, []byte(content), 0644)
+%s# Expose port 80
+EXPOSE 80
+`, stages.String(), baseImage, steps.String())
 }
 
 // SiteInfo holds information about a site from site.properties
 type SiteInfo struct {
-	Name    string
-	Domains []string
-	Image   string
+	Name        string
+	Domains     []string
+	Image       string
+	Type        string // Project type: php (default) or static
+	TagStrategy string
+	Compress    bool // Pre-compress static assets in the image (compress=false turns it off)
+	Composer    bool // Install Composer dependencies if there's a composer.json (composer=false turns it off)
 }
 
 // resolveImage normalizes an image specification
@@ -184,12 +357,12 @@ func resolveImage(image string) string {
 
 // loadSiteInfo loads site information from site.properties
 func loadSiteInfo(dir string) (*SiteInfo, error) {
-	propsPath := filepath.Join(dir, "site.properties")
+	propsPath := properties.ProjectFile(dir)
 	if !properties.FileExists(propsPath) {
 		return nil, nil
 	}
 
-	props, err := properties.ParseProperties(propsPath)
+	props, err := parseProjectFile(propsPath)
 	if err != nil {
 		return nil, err
 	}
@@ -213,6 +386,18 @@ func loadSiteInfo(dir string) (*SiteInfo, error) {
 	// Get base image
 	info.Image = props.Get("image")
 
+	// Get project type
+	info.Type = props.Get("type")
+
+	// Get tag strategy
+	info.TagStrategy = props.Get("tag.strategy")
+
+	// Pre-compression is on unless turned off
+	info.Compress = props.Get("compress") != "false"
+
+	// Composer dependencies are installed unless turned off
+	info.Composer = props.Get("composer") != "false"
+
 	return info, nil
 }
 
@@ -230,8 +415,13 @@ func printSiteInfo(siteName string, version string, domains []string) {
 }
 
 func init() {
-	buildCmd.Flags().StringVarP(&buildTag, "tag", "t", "", "Tag for the image (default: git version or 'latest')")
+	buildCmd.Flags().StringVarP(&buildTag, "tag", "t", "", "Tag for the image (default: from tag.strategy, git version or 'latest')")
 	buildCmd.Flags().StringVarP(&buildImage, "image", "i", "", "Base Docker image to use (default: lightspeed-server)")
+	buildCmd.Flags().BoolVar(&showTimings, "timings", false, "Show the build time compared with earlier builds")
+	buildCmd.Flags().StringVar(&buildCacheFrom, "cache-from", "", "Reuse the layers of an image (default with no value: the last published image)")
+	buildCmd.Flags().Lookup("cache-from").NoOptDefVal = cachePublished
+	buildCmd.Flags().StringVar(&buildPlatform, "platform", defaultBuildPlatform, "Platform to build for")
 
+	supportsJSON(buildCmd)
 	rootCmd.AddCommand(buildCmd)
 }
@@ -0,0 +1,21 @@
+package cmd
+
+// cachePublished is the --cache-from value that reuses the layers of the project's last published image
+const cachePublished = "published"
+
+// buildCacheFrom is an image whose layers builds reuse (--cache-from)
+var buildCacheFrom string
+
+// resolveCacheFrom returns the image a build of a project reuses layers from (empty for none)
+// Published images carry BuildKit's inline cache, so their layers can be reused without pulling them
+func resolveCacheFrom(dir string) string {
+	if buildCacheFrom != cachePublished {
+		return buildCacheFrom
+	}
+
+	state, err := loadProjectState(dir)
+	if err != nil || state.Published == nil || len(state.Published.Images) == 0 {
+		return ""
+	}
+	return state.Published.Images[0]
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+const logsBaseDir = ".lightspeed/logs"
+
+// buildDiagnosis describes a recognized build failure
+type buildDiagnosis struct {
+	pattern *regexp.Regexp
+	problem string
+	hint    string
+}
+
+// buildDiagnoses are checked in order; the first match wins
+var buildDiagnoses = []buildDiagnosis{
+	{
+		pattern: regexp.MustCompile(`(?i)cannot connect to the docker daemon|docker daemon is not running|error during connect`),
+		problem: "Docker is not running",
+		hint:    "Start Docker Desktop (or the docker service) and try again",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)pull access denied|manifest unknown|not found: manifest|failed to resolve source metadata`),
+		problem: "Base image could not be pulled",
+		hint:    "Check the 'image' property in site.properties and your network connection",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)PHP (Parse|Fatal) error|Parse error: syntax error`),
+		problem: "PHP error while building",
+		hint:    "Fix the PHP error shown below and build again",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)your requirements could not be resolved|composer\.lock.*not up to date|composer.*(could not|failed)`),
+		problem: "Composer dependencies could not be installed",
+		hint:    "Run 'composer update' locally and commit composer.lock",
+	},
+	{
+		pattern: regexp.MustCompile(`AH\d{5}:|Invalid command '.*', perhaps misspelled`),
+		problem: "Apache configuration error",
+		hint:    "Check your .htaccess and Apache configuration files",
+	},
+	{
+		pattern: regexp.MustCompile(`nginx: \[emerg\]`),
+		problem: "Nginx configuration error",
+		hint:    "Check your nginx configuration files",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)COPY failed|failed to compute cache key|not found: not found`),
+		problem: "A file referenced by the Dockerfile is missing",
+		hint:    "Check COPY/ADD paths in your Dockerfile and your .dockerignore",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)no space left on device`),
+		problem: "Docker ran out of disk space",
+		hint:    "Free space with 'docker system prune' and try again",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)exec format error`),
+		problem: "Image architecture doesn't match the build platform",
+		hint:    "Use a base image that supports linux/amd64",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)permission denied`),
+		problem: "Permission denied",
+		hint:    "Check file permissions in the project and that your user can run docker",
+	},
+}
+
+// diagnoseBuild finds the first recognized failure in build output
+// Returns the diagnosis (nil if unrecognized) and the index of the matching line
+func diagnoseBuild(lines []string) (*buildDiagnosis, int) {
+	for i := range buildDiagnoses {
+		for j, line := range lines {
+			if buildDiagnoses[i].pattern.MatchString(line) {
+				return &buildDiagnoses[i], j
+			}
+		}
+	}
+	return nil, -1
+}
+
+// buildExcerpt returns the lines around a match, or the tail of the log if there is no match
+func buildExcerpt(lines []string, match int) []string {
+	const contextLines = 5
+	const tailLines = 15
+
+	if match < 0 {
+		if len(lines) > tailLines {
+			return lines[len(lines)-tailLines:]
+		}
+		return lines
+	}
+
+	start := match - contextLines
+	if start < 0 {
+		start = 0
+	}
+	end := match + contextLines + 1
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return lines[start:end]
+}
+
+// saveBuildLog writes build output to ~/.lightspeed/logs and returns the file path
+func saveBuildLog(name string, output []byte) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	logsDir := filepath.Join(homeDir, logsBaseDir)
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return "", err
+	}
+
+	logPath := filepath.Join(logsDir, fmt.Sprintf("build-%s-%s.log", sanitizeContainerName(name), time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(logPath, output, 0644); err != nil {
+		return "", err
+	}
+
+	return logPath, nil
+}
+
+// printBuildFailure prints a concise diagnosis of a failed build with the relevant log excerpt
+func printBuildFailure(output []byte, logPath string) {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	diagnosis, match := diagnoseBuild(lines)
+
+	fmt.Println()
+	if diagnosis != nil {
+		ui.PrintError("%s", diagnosis.problem)
+		ui.PrintInfo("%s", diagnosis.hint)
+	} else {
+		ui.PrintError("Docker build failed")
+	}
+
+	excerpt := buildExcerpt(lines, match)
+	if len(excerpt) > 0 {
+		fmt.Println()
+		for _, line := range excerpt {
+			fmt.Printf("  %s\n", ui.Muted(line))
+		}
+	}
+
+	if logPath != "" {
+		fmt.Println()
+		ui.PrintKeyValue("Full log", logPath)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	cacheSiteName string
+	cacheShared   bool
+	cacheVersion  string
+	cacheSize     string
+	cacheReveal   bool
+	cacheForce    bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the Redis/Valkey cache of a site (sessions and caching)",
+}
+
+var cacheCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Provision a Redis/Valkey cache (redeploys the site)",
+	Long:  "Provision a managed Valkey cache for the site, or a user on the operator's shared instance with --shared, and set its connection details as site variables (passwords as secrets). Running it again for a site that has a cache sets the variables again.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := cacheSite()
+
+		if cacheShared && (cacheSize != "" || cacheVersion != "") {
+			ui.PrintError("--size and --version only apply to a dedicated cache, not --shared")
+			os.Exit(1)
+		}
+
+		if cacheShared {
+			ui.PrintInfo("Provisioning shared cache for '%s'...", siteName)
+		} else {
+			ui.PrintInfo("Provisioning cache for '%s'...", siteName)
+		}
+		cache, err := newBackend().CreateCache(cmd.Context(), siteName, api.CacheRequest{
+			Shared:  cacheShared,
+			Version: cacheVersion,
+			Size:    cacheSize,
+		})
+		if err != nil {
+			ui.PrintError("Failed to create cache: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Cache %s attached, the site is redeploying", cache.Name)
+		fmt.Println()
+		printCache(cache, false)
+		fmt.Println()
+		if cache.Status != "online" {
+			ui.PrintInfo("The cache takes a few minutes to come online; run 'lightspeed cache info' to check")
+		} else {
+			ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+		}
+		fmt.Println()
+	},
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the connection details of the site's cache",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := cacheSite()
+
+		cache, err := newBackend().GetCache(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get cache: %v", err)
+			os.Exit(1)
+		}
+
+		printCache(cache, cacheReveal)
+		fmt.Println()
+	},
+}
+
+var cacheDestroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete the site's cache and its data (redeploys the site)",
+	Long:  "Delete the site's cache with all its keys, or its user and keys on the shared instance, and remove its connection variables from the site. Asks for the cache name to confirm unless --force is given.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+		siteName := cacheSite()
+
+		cache, err := backend.GetCache(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to get cache: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintKeyValue("Site", siteName)
+		ui.PrintKeyValue("Cache", cacheKind(cache))
+		fmt.Println()
+
+		if !cacheForce && !confirmCacheDestroy(cache.Name) {
+			ui.PrintInfo("Aborted")
+			fmt.Println()
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Deleting cache %s...", cache.Name)
+		if err := backend.DeleteCache(ctx, siteName); err != nil {
+			ui.PrintError("Failed to delete cache: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Deleted cache %s, the site is redeploying", cache.Name)
+		fmt.Println()
+	},
+}
+
+// cacheSite resolves the site cache commands apply to
+func cacheSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, cacheSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+// cacheKind describes a cache as dedicated or shared
+func cacheKind(cache *api.Cache) string {
+	if cache.Shared {
+		return fmt.Sprintf("%s (shared %s)", cache.Name, cache.Engine)
+	}
+	return fmt.Sprintf("%s (%s)", cache.Name, cache.Engine)
+}
+
+// printCache prints a cache's details, hiding its password unless reveal is set
+func printCache(cache *api.Cache, reveal bool) {
+	ui.PrintKeyValue("Cache", cache.Name)
+	engine := cache.Engine
+	if cache.Version != "" {
+		engine += " " + cache.Version
+	}
+	if cache.Shared {
+		engine += " (shared instance)"
+	}
+	ui.PrintKeyValue("Engine", engine)
+	if cache.Size != "" {
+		ui.PrintKeyValue("Size", cache.Size)
+	}
+	if cache.Region != "" {
+		ui.PrintKeyValue("Region", cache.Region)
+	}
+	ui.PrintKeyValue("Status", cache.Status)
+	if cache.Host != "" {
+		ui.PrintKeyValue("Host", cache.Host+":"+strconv.Itoa(cache.Port))
+	}
+	if cache.User != "" {
+		ui.PrintKeyValue("User", cache.User)
+	}
+	if cache.Prefix != "" {
+		ui.PrintKeyValue("Key prefix", cache.Prefix)
+	}
+	if cache.Password != "" {
+		if reveal {
+			ui.PrintKeyValue("Password", cache.Password)
+		} else {
+			ui.PrintKeyValue("Password", ui.Muted("(hidden, use --reveal)"))
+		}
+	}
+	if cache.URI != "" && reveal {
+		ui.PrintKeyValue("URI", cache.URI)
+	}
+	if len(cache.Env) > 0 {
+		ui.PrintKeyValue("Variables", strings.Join(cache.Env, ", "))
+	}
+}
+
+// confirmCacheDestroy asks the user to type the cache name to confirm deleting it
+func confirmCacheDestroy(cacheName string) bool {
+	if ciRefusesPrompt() {
+		return false
+	}
+	ui.PrintWarning("This permanently deletes the cache and all its keys, including sessions")
+	fmt.Printf("Type '%s' to confirm: ", cacheName)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	return strings.TrimSpace(answer) == cacheName
+}
+
+func init() {
+	cacheCmd.PersistentFlags().StringVarP(&cacheSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	cacheCreateCmd.Flags().BoolVar(&cacheShared, "shared", false, "Use the operator's shared instance, with a user and key prefix of the site")
+	cacheCreateCmd.Flags().StringVar(&cacheVersion, "version", "", "Valkey version of a dedicated cache (default: the latest)")
+	cacheCreateCmd.Flags().StringVar(&cacheSize, "size", "", "Node size of a dedicated cache (default: db-s-1vcpu-1gb)")
+	cacheInfoCmd.Flags().BoolVar(&cacheReveal, "reveal", false, "Show the password and connection URI")
+	cacheDestroyCmd.Flags().BoolVarP(&cacheForce, "force", "f", false, "Delete without asking for confirmation")
+
+	cacheCmd.AddCommand(cacheCreateCmd)
+	cacheCmd.AddCommand(cacheInfoCmd)
+	cacheCmd.AddCommand(cacheDestroyCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
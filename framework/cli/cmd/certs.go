@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var certsCmd = &cobra.Command{
+	Use:   "certs [name]",
+	Short: "Show the TLS certificate status of a site's domains",
+	Long:  "Show the certificate of each of a site's domains as the platform provisions it: pending (waiting for the domain to resolve to the site), issued with its expiry, expiring soon (renewal is failing) or failed, with the reason the platform gives.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		list, err := newBackend().GetCertificates(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get certificates of '%s': %v", siteName, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if jsonOutput() {
+			printJSON(list)
+			return
+		}
+
+		if len(list.Certificates) == 0 {
+			ui.PrintInfo("'%s' has no domains", siteName)
+			fmt.Println()
+			return
+		}
+		ui.PrintInfo("Certificates of '%s'", siteName)
+		fmt.Println()
+
+		problems := 0
+		for _, cert := range list.Certificates {
+			printCertificate(cert, time.Now())
+			if cert.Status != api.CertificateIssued {
+				problems++
+			}
+		}
+		fmt.Println()
+		if problems > 0 {
+			fmt.Println(ui.Muted("  Certificates are issued and renewed while a domain resolves to the site; check with 'lightspeed dns check'"))
+			fmt.Println()
+		}
+	},
+}
+
+// printCertificate prints a domain's certificate on one line: domain, type, status and expiry or reason
+func printCertificate(cert api.SiteCertificate, now time.Time) {
+	detail := cert.Message
+	if expires, err := time.Parse(time.RFC3339, cert.ExpiresAt); err == nil {
+		days := int(expires.Sub(now).Hours() / 24)
+		detail = fmt.Sprintf("expires %s (%d days)", expires.Format("2006-01-02"), days)
+		if cert.Message != "" && cert.Status != api.CertificateIssued {
+			detail += ", " + cert.Message
+		}
+	}
+
+	line := fmt.Sprintf("  %-32s %-8s %-9s", cert.Domain, cert.Type, certificateStatus(cert.Status))
+	if cert.Status == api.CertificateIssued {
+		detail = ui.Muted(detail)
+	}
+	fmt.Println(line + " " + detail)
+}
+
+// certificateStatus returns a human-readable certificate status
+func certificateStatus(status string) string {
+	switch status {
+	case api.CertificatePending:
+		return "Pending"
+	case api.CertificateIssued:
+		return "Issued"
+	case api.CertificateExpiring:
+		return "Expiring"
+	case api.CertificateError:
+		return "Failed"
+	}
+	return status
+}
+
+func init() {
+	supportsJSON(certsCmd)
+	rootCmd.AddCommand(certsCmd)
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// checksFile defines a project's synthetic checks, uploaded by deploy and checks push
+const checksFile = "checks.yaml"
+
+var checksSiteName string
+
+// checksFileContent is the format of checks.yaml
+type checksFileContent struct {
+	Checks []struct {
+		Name  string `yaml:"name"`
+		Steps []struct {
+			Get      string            `yaml:"get"`
+			Post     string            `yaml:"post"`
+			Form     map[string]string `yaml:"form"`
+			Status   int               `yaml:"status"`
+			Contains string            `yaml:"contains"`
+		} `yaml:"steps"`
+	} `yaml:"checks"`
+}
+
+var checksCmd = &cobra.Command{
+	Use:   "checks",
+	Short: "Manage synthetic checks of a site",
+	Long:  "Synthetic checks are multi-step transactions (load a page, post a form, look for text) the operator runs against a site every minute. A failing check counts as downtime.",
+}
+
+var checksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the site's checks and their last results",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := checksSite()
+
+		checks, err := newBackend().GetChecks(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get checks: %v", err)
+			os.Exit(1)
+		}
+
+		if len(checks.Checks) == 0 {
+			ui.PrintInfo("No synthetic checks for '%s'", siteName)
+			fmt.Println()
+			return
+		}
+
+		results := make(map[string]api.SyntheticResult, len(checks.Results))
+		for _, result := range checks.Results {
+			results[result.Name] = result
+		}
+
+		ui.PrintInfo("Synthetic checks of '%s'", siteName)
+		fmt.Println()
+		for _, check := range checks.Checks {
+			result, ok := results[check.Name]
+			switch {
+			case !ok:
+				fmt.Printf("  %s %s\n", check.Name, ui.Muted("(not run yet)"))
+			case result.Passed:
+				fmt.Printf("  %s %s\n", check.Name, ui.Muted(fmt.Sprintf("passed in %dms at %s", result.DurationMs, result.CheckedAt)))
+			default:
+				fmt.Printf("  %s failed at step %d: %s\n", check.Name, result.Step, result.Error)
+			}
+			for i, step := range check.Steps {
+				fmt.Printf("    %d. %s %s\n", i+1, step.Method, step.Path)
+			}
+		}
+		fmt.Println()
+	},
+}
+
+var checksPushCmd = &cobra.Command{
+	Use:   "push [file]",
+	Short: "Upload the checks in checks.yaml, replacing the site's checks",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		dir, siteName := checksSite()
+
+		path := filepath.Join(dir, checksFile)
+		if len(args) == 1 {
+			path = args[0]
+		}
+		checks, err := loadChecks(path)
+		if err != nil {
+			ui.PrintError("%v", err)
+			os.Exit(1)
+		}
+
+		if _, err := newBackend().SetChecks(cmd.Context(), siteName, checks); err != nil {
+			ui.PrintError("Failed to upload checks: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Uploaded %d checks for '%s'", len(checks), siteName)
+		fmt.Println()
+	},
+}
+
+var checksRmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove all checks of the site",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := checksSite()
+
+		if _, err := newBackend().SetChecks(cmd.Context(), siteName, nil); err != nil {
+			ui.PrintError("Failed to remove checks: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Removed the checks of '%s'", siteName)
+		fmt.Println()
+	},
+}
+
+// loadChecks reads synthetic checks from a checks.yaml file
+// Form values can reference environment variables (${NAME}), so credentials stay out of the file
+func loadChecks(path string) ([]api.SyntheticCheck, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var content checksFileContent
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	checks := make([]api.SyntheticCheck, 0, len(content.Checks))
+	for _, c := range content.Checks {
+		check := api.SyntheticCheck{Name: c.Name}
+		for i, s := range c.Steps {
+			step := api.SyntheticStep{Status: s.Status, Contains: s.Contains}
+			switch {
+			case s.Get != "" && s.Post == "":
+				step.Method, step.Path = "GET", s.Get
+			case s.Post != "" && s.Get == "":
+				step.Method, step.Path = "POST", s.Post
+			default:
+				return nil, fmt.Errorf("%s: check '%s' step %d needs either get or post", path, c.Name, i+1)
+			}
+			if len(s.Form) > 0 {
+				step.Form = make(map[string]string, len(s.Form))
+				for key, value := range s.Form {
+					step.Form[key] = os.ExpandEnv(value)
+				}
+			}
+			check.Steps = append(check.Steps, step)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// syncChecks uploads the project's checks.yaml if it has one
+func syncChecks(ctx context.Context, out *ui.Output, backend Backend, dir, siteName string) {
+	path := filepath.Join(dir, checksFile)
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	checks, err := loadChecks(path)
+	if err == nil {
+		_, err = backend.SetChecks(ctx, siteName, checks)
+	}
+	if err != nil {
+		out.PrintWarning("Synthetic checks not updated: %v", err)
+		return
+	}
+	out.PrintInfo("Synthetic checks: %d from %s", len(checks), checksFile)
+}
+
+// checksSite resolves the project directory and site checks commands apply to
+func checksSite() (string, string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, checksSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return dir, siteName
+}
+
+func init() {
+	checksCmd.PersistentFlags().StringVarP(&checksSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	checksCmd.AddCommand(checksListCmd)
+	checksCmd.AddCommand(checksPushCmd)
+	checksCmd.AddCommand(checksRmCmd)
+	rootCmd.AddCommand(checksCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"lightspeed/core/lib/ui"
+)
+
+// ciMode is set by --ci, or when the environment of a CI system is detected
+// It turns off prompts, the browser and styled output, and prints deploy results on stdout
+var ciMode bool
+
+// ciEnvVars are set by CI systems; CI covers most of them (GitHub Actions, GitLab, CircleCI,
+// Travis, Buildkite), the others are for systems that don't set it
+var ciEnvVars = []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION"}
+
+// Exit codes of build, publish and deploy, so CI can tell failures apart
+// Interrupted commands exit with 130, like other programs stopped with Ctrl-C
+const (
+	exitFailure      = 1 // Any other failure, e.g. invalid flags or site.properties
+	exitBuildFailed  = 3 // The image didn't build
+	exitPushFailed   = 4 // The image wasn't pushed to the registry
+	exitDeployFailed = 5 // The site wasn't created or its deployment failed
+	exitVerifyFailed = 6 // The deployment is live, but the site didn't respond
+	exitPerfFailed   = 7 // The site missed its performance budget
+)
+
+// detectCI checks the environment for a CI system; CI=false (or 0) turns detection off
+func detectCI() bool {
+	if ci := os.Getenv("CI"); ci != "" {
+		return ci != "false" && ci != "0"
+	}
+	for _, name := range ciEnvVars {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setupCI turns CI mode on with --ci or in a detected CI system
+func setupCI() {
+	if !ciMode && !detectCI() {
+		return
+	}
+	ciMode = true
+	ui.SetPlain()
+	ui.PrintDebug("CI mode: prompts, the browser and styled output are off")
+}
+
+// stepExitCode returns the exit code of a failed deploy step
+func stepExitCode(step string) int {
+	switch step {
+	case "build":
+		return exitBuildFailed
+	case "push":
+		return exitPushFailed
+	case "ensure-site", "wait-deploy":
+		return exitDeployFailed
+	case "verify":
+		return exitVerifyFailed
+	case "perf":
+		return exitPerfFailed
+	}
+	return exitFailure
+}
+
+// ciRefusesPrompt checks if a confirmation can't be asked because of CI mode, saying how to skip it
+func ciRefusesPrompt() bool {
+	if !ciMode {
+		return false
+	}
+	ui.PrintError("Can't ask for confirmation in CI mode; pass --force")
+	return true
+}
+
+// printCIResult prints a deploy's URL and deployment ID on stdout as key=value lines,
+// e.g. for appending to $GITHUB_OUTPUT
+func printCIResult(url, deploymentID string) {
+	if url != "" {
+		fmt.Printf("url=%s\n", url)
+	}
+	if deploymentID != "" {
+		fmt.Printf("deployment_id=%s\n", deploymentID)
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"lightspeed/core/lib/ui"
+)
+
+// composerImage runs Composer for builds and development servers
+const composerImage = "composer:2"
+
+// composerStage is the build stage of the generated Dockerfile that installs Composer dependencies
+// Only composer.json and composer.lock are copied, so the layer is cached until they change,
+// and downloads are kept in a BuildKit cache mount for when they do.
+// The autoloader is generated in the site image, where the project's classes are.
+const composerStage = `FROM ` + composerImage + ` AS vendor
+WORKDIR /app
+COPY composer.json composer.lock* ./
+RUN --mount=type=cache,target=/tmp/cache composer install --no-dev --no-interaction --no-progress --prefer-dist --no-scripts --no-autoloader --ignore-platform-reqs && mkdir -p vendor
+
+`
+
+// composerSteps replace the project's local vendor directory (which may hold dev dependencies)
+// with the one installed by the vendor stage, and generate an optimized autoloader
+const composerSteps = `# Install Composer dependencies
+RUN rm -rf /var/www/html/vendor
+COPY --chown=www-data:www-data --from=vendor /app/vendor /var/www/html/vendor
+COPY --from=vendor /usr/bin/composer /usr/local/bin/composer
+RUN COMPOSER_ALLOW_SUPERUSER=1 composer dump-autoload --no-dev --optimize --no-scripts --no-interaction --working-dir=/var/www/html
+
+`
+
+// usesComposer checks if a project has Composer dependencies to install
+// composer=false in site.properties turns it off (e.g. when vendor/ is committed); static sites never run PHP
+func usesComposer(dir string, siteInfo *SiteInfo) bool {
+	if _, err := os.Stat(filepath.Join(dir, "composer.json")); err != nil || isStaticSite(siteInfo) {
+		return false
+	}
+	return siteInfo == nil || siteInfo.Composer
+}
+
+// installComposerVendor installs a project's Composer dependencies (with dev dependencies)
+// into its vendor directory for the development server, which mounts the project directory
+// Nothing is done if vendor/ already exists
+func installComposerVendor(ctx context.Context, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "vendor")); err == nil {
+		return nil
+	}
+
+	ui.PrintInfo("Installing Composer dependencies...")
+	dockerArgs := []string{"run", "--rm", "-v", dir + ":/app", "-w", "/app"}
+	// Files are created as the user, not root, where Docker shares the host's user IDs
+	if runtime.GOOS == "linux" {
+		dockerArgs = append(dockerArgs, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()), "-e", "COMPOSER_HOME=/tmp/composer")
+	}
+	dockerArgs = append(dockerArgs, composerImage, "install", "--no-interaction", "--no-progress", "--ignore-platform-reqs")
+
+	output, err := dockerCommand(ctx, dockerArgs...).CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if len(lines) > 5 {
+			lines = lines[len(lines)-5:]
+		}
+		return fmt.Errorf("composer install failed: %s", strings.Join(lines, "\n"))
+	}
+	return nil
+}
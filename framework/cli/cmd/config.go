@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+// configFile is the global CLI configuration, shared by all projects
+const configFile = ".lightspeed/config.yaml"
+
+// cliConfig is the global configuration loaded before every command
+var cliConfig globalConfig
+
+// globalConfig holds the settings in ~/.lightspeed/config.yaml
+type globalConfig struct {
+	API      string `yaml:"api,omitempty"`
+	Registry string `yaml:"registry,omitempty"`
+	Region   string `yaml:"region,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+	Timings  string `yaml:"timings,omitempty"`
+
+	// Registries are logins for private registries base images are pulled from, by host
+	Registries map[string]registryLogin `yaml:"registries,omitempty"`
+}
+
+// registryLogin is the login for a private registry
+// Password is a reference like the token setting: env:NAME, file:PATH or the password itself
+type registryLogin struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// configKey is a setting of the global configuration
+type configKey struct {
+	Name        string
+	Description string
+	Field       func(c *globalConfig) *string
+}
+
+// configKeys are the settings of the global configuration, in display order
+var configKeys = []configKey{
+	{"api", "Operator API host[:port]", func(c *globalConfig) *string { return &c.API }},
+	{"registry", "Registry host[:port] (default: the api host if set)", func(c *globalConfig) *string { return &c.Registry }},
+	{"region", "Region new sites are created in (e.g. nyc, ams, sfo)", func(c *globalConfig) *string { return &c.Region }},
+	{"token", "Access token reference: env:NAME, file:PATH or the token itself", func(c *globalConfig) *string { return &c.Token }},
+	{"timings", "Show a timing summary after build, publish and deploy (true or false)", func(c *globalConfig) *string { return &c.Timings }},
+}
+
+// appRegions are the App Platform regions sites can be created in
+var appRegions = []string{"ams", "blr", "fra", "lon", "nyc", "sfo", "sgp", "syd", "tor"}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show or change the global CLI configuration",
+	Long:  "Show or change the settings in ~/.lightspeed/config.yaml: api, registry, region, token, timings and the logins of private base image registries. The --api flag and LIGHTSPEED_API environment variable still override api and registry.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configListCmd.Run(cmd, args)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		config := loadConfigOrExit()
+		path, _ := configPath()
+		ui.PrintKeyValue("File", path)
+		fmt.Println()
+		for _, key := range configKeys {
+			value := *key.Field(config)
+			if value == "" {
+				value = "-"
+			} else if key.Name == "token" {
+				value = maskTokenReference(value)
+			}
+			ui.PrintKeyValue(key.Name, value)
+		}
+		if len(config.Registries) > 0 {
+			fmt.Println()
+			hosts := make([]string, 0, len(config.Registries))
+			for host := range config.Registries {
+				hosts = append(hosts, host)
+			}
+			sort.Strings(hosts)
+			for _, host := range hosts {
+				login := config.Registries[host]
+				ui.PrintKeyValue(host, login.Username+" / "+maskTokenReference(login.Password))
+			}
+		}
+		fmt.Println()
+		ui.PrintKeyValue("API host", apiHost)
+		ui.PrintKeyValue("Registry host", registryHost)
+		fmt.Println()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := findConfigKey(args[0])
+		config := loadConfigOrExit()
+		if value := *key.Field(config); value != "" {
+			fmt.Println(value)
+		}
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		key := findConfigKey(args[0])
+		value := strings.TrimSpace(args[1])
+		if err := validateConfigValue(key.Name, value); err != nil {
+			ui.PrintError("Invalid %s: %v", key.Name, err)
+			os.Exit(1)
+		}
+
+		config := loadConfigOrExit()
+		*key.Field(config) = value
+		if err := saveConfig(config); err != nil {
+			ui.PrintError("Failed to save config: %v", err)
+			os.Exit(1)
+		}
+
+		if key.Name == "token" {
+			value = maskTokenReference(value)
+		}
+		ui.PrintSuccess("Set %s to %s", key.Name, value)
+		fmt.Println()
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Reset a setting to its default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		key := findConfigKey(args[0])
+		config := loadConfigOrExit()
+		*key.Field(config) = ""
+		if err := saveConfig(config); err != nil {
+			ui.PrintError("Failed to save config: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Unset %s", key.Name)
+		fmt.Println()
+	},
+}
+
+var configRegistryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage the logins of private registries base images come from",
+	Long:  "Builds log in to the registry of a private base image with the login saved here; without one, they ask the operator for its pull secret.",
+}
+
+var configRegistrySetCmd = &cobra.Command{
+	Use:   "set <host> <username> <password>",
+	Short: "Save the login of a registry (password: env:NAME, file:PATH or the password itself)",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		host := registry.NormalizeHost(args[0])
+		if host == "" || strings.Contains(host, "://") || strings.Contains(host, "/") {
+			ui.PrintError("Invalid registry: %q must be a host[:port] without scheme or path", args[0])
+			os.Exit(1)
+		}
+		password := strings.TrimSpace(args[2])
+		if err := validateConfigValue("token", password); err != nil {
+			ui.PrintError("Invalid password: %v", err)
+			os.Exit(1)
+		}
+
+		config := loadConfigOrExit()
+		if config.Registries == nil {
+			config.Registries = map[string]registryLogin{}
+		}
+		config.Registries[host] = registryLogin{Username: strings.TrimSpace(args[1]), Password: password}
+		if err := saveConfig(config); err != nil {
+			ui.PrintError("Failed to save config: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Set the login of %s to %s / %s", host, args[1], maskTokenReference(password))
+		fmt.Println()
+	},
+}
+
+var configRegistryUnsetCmd = &cobra.Command{
+	Use:   "unset <host>",
+	Short: "Remove the login of a registry",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		host := registry.NormalizeHost(args[0])
+		config := loadConfigOrExit()
+		if _, ok := config.Registries[host]; !ok {
+			ui.PrintError("No login for %s", host)
+			os.Exit(1)
+		}
+		delete(config.Registries, host)
+		if err := saveConfig(config); err != nil {
+			ui.PrintError("Failed to save config: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Removed the login of %s", host)
+		fmt.Println()
+	},
+}
+
+// configPath returns the path of the global config file
+func configPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, configFile), nil
+}
+
+// loadConfig reads the global config file (empty if it doesn't exist)
+func loadConfig() (*globalConfig, error) {
+	config := &globalConfig{}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// saveConfig writes the global config file, readable only by the user (it may hold a token)
+func saveConfig(config *globalConfig) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// loadConfigOrExit reads the global config file, exiting if it's invalid
+func loadConfigOrExit() *globalConfig {
+	config, err := loadConfig()
+	if err != nil {
+		ui.PrintError("%v", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+// findConfigKey returns a setting by name, exiting if there's no such setting
+func findConfigKey(name string) configKey {
+	for _, key := range configKeys {
+		if key.Name == name {
+			return key
+		}
+	}
+
+	names := make([]string, 0, len(configKeys))
+	for _, key := range configKeys {
+		names = append(names, key.Name)
+	}
+	ui.PrintError("Unknown setting '%s' (settings: %s)", name, strings.Join(names, ", "))
+	os.Exit(1)
+	return configKey{}
+}
+
+// validateConfigValue checks a value before it's saved
+func validateConfigValue(key, value string) error {
+	if value == "" {
+		return fmt.Errorf("value is empty (use 'lightspeed config unset %s')", key)
+	}
+
+	switch key {
+	case "api", "registry":
+		if strings.Contains(value, "://") || strings.Contains(value, "/") {
+			return fmt.Errorf("%q must be a host[:port] without scheme or path", value)
+		}
+	case "region":
+		for _, region := range appRegions {
+			if value == region {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a region (regions: %s)", value, strings.Join(appRegions, ", "))
+	case "timings":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%q must be true or false", value)
+		}
+	case "token":
+		if name, ok := strings.CutPrefix(value, "env:"); ok && name == "" {
+			return fmt.Errorf("env: needs a variable name")
+		}
+		if path, ok := strings.CutPrefix(value, "file:"); ok && path == "" {
+			return fmt.Errorf("file: needs a path")
+		}
+	}
+	return nil
+}
+
+// resolveTokenReference returns the token a config token reference points to
+// env:NAME reads an environment variable, file:PATH reads a file, anything else is the token
+func resolveTokenReference(ref string) string {
+	if name, ok := strings.CutPrefix(ref, "env:"); ok {
+		return os.Getenv(name)
+	}
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		if strings.HasPrefix(path, "~/") {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(homeDir, path[2:])
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+	return ref
+}
+
+// maskTokenReference hides a token stored directly in the config, keeping references readable
+func maskTokenReference(ref string) string {
+	if strings.HasPrefix(ref, "env:") || strings.HasPrefix(ref, "file:") {
+		return ref
+	}
+	if len(ref) <= 8 {
+		return "****"
+	}
+	return ref[:6] + "****"
+}
+
+func init() {
+	settings := make([]string, 0, len(configKeys))
+	for _, key := range configKeys {
+		settings = append(settings, fmt.Sprintf("  %-9s %s", key.Name, key.Description))
+	}
+	configSetCmd.Long = "Change a setting in ~/.lightspeed/config.yaml.\n\nSettings:\n" + strings.Join(settings, "\n")
+
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configRegistryCmd.AddCommand(configRegistrySetCmd)
+	configRegistryCmd.AddCommand(configRegistryUnsetCmd)
+	configCmd.AddCommand(configRegistryCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	convertTo    string
+	convertKeep  bool
+	convertForce bool
+)
+
+// manifestListKeys are the site.properties keys holding comma-separated lists, written as
+// YAML lists in lightspeed.yaml
+var manifestListKeys = []string{"domains", "libraries", "services", "resolvers", "firewall.allow", "firewall.deny", "hooks.ping"}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert site.properties to lightspeed.yaml, or back",
+	Long:  "Convert the project's site.properties to a lightspeed.yaml manifest (--to yaml, the default), or its lightspeed.yaml to site.properties (--to properties). The converted file replaces the original, which is removed unless --keep is given. Comments aren't carried over.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		var from, to string
+		switch convertTo {
+		case "yaml":
+			from, to = properties.PropertiesFile, properties.ManifestFile
+		case "properties":
+			from, to = properties.ManifestFile, properties.PropertiesFile
+		default:
+			ui.PrintError("Invalid --to '%s': must be yaml or properties", convertTo)
+			os.Exit(1)
+		}
+		fromPath, toPath := filepath.Join(dir, from), filepath.Join(dir, to)
+
+		if !properties.FileExists(fromPath) {
+			ui.PrintError("No %s in %s", from, dir)
+			os.Exit(1)
+		}
+		if properties.FileExists(toPath) && !convertForce {
+			ui.PrintError("%s already exists", to)
+			ui.PrintInfo("Use --force to replace it")
+			os.Exit(1)
+		}
+
+		props, err := properties.ParseFile(fromPath)
+		if err != nil {
+			ui.PrintError("Failed to parse %s: %v", from, err)
+			os.Exit(1)
+		}
+
+		var content []byte
+		if to == properties.ManifestFile {
+			content, err = properties.FormatManifest(manifestProperties(props))
+		} else {
+			var text string
+			text, err = properties.FormatProperties(props)
+			content = []byte(text)
+		}
+		if err != nil {
+			ui.PrintError("Failed to convert %s: %v", from, err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(toPath, content, 0644); err != nil {
+			ui.PrintError("Failed to write %s: %v", to, err)
+			os.Exit(1)
+		}
+		if hasComments(fromPath) {
+			ui.PrintWarning("Comments in %s aren't carried over to %s", from, to)
+		}
+		if !convertKeep {
+			if err := os.Remove(fromPath); err != nil {
+				ui.PrintWarning("Failed to remove %s: %v", from, err)
+			}
+		} else if to == properties.ManifestFile {
+			ui.PrintInfo("%s is ignored while there is a %s", from, to)
+		}
+
+		ui.PrintSuccess("Converted %s to %s (%d settings)", from, to, len(props))
+		fmt.Println()
+	},
+}
+
+// manifestProperties returns properties with the comma-separated lists of manifestListKeys
+// split, so the manifest has them as lists
+func manifestProperties(props properties.Properties) properties.Properties {
+	converted := make(properties.Properties, len(props))
+	for key, value := range props {
+		converted[key] = value
+	}
+	for _, key := range manifestListKeys {
+		if _, ok := props[key]; !ok {
+			continue
+		}
+		items := []interface{}{}
+		for _, item := range props.GetList(key) {
+			items = append(items, item)
+		}
+		converted[key] = items
+	}
+	return converted
+}
+
+// hasComments checks if a site.properties or YAML file has comment lines
+func hasComments(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if strings.HasPrefix(strings.TrimSpace(string(line)), "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	convertCmd.Flags().StringVar(&convertTo, "to", "yaml", "Format to convert to: yaml (lightspeed.yaml) or properties (site.properties)")
+	convertCmd.Flags().BoolVar(&convertKeep, "keep", false, "Keep the original file")
+	convertCmd.Flags().BoolVarP(&convertForce, "force", "f", false, "Replace an existing converted file")
+	rootCmd.AddCommand(convertCmd)
+}
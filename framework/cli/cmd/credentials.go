@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// credentialsFile holds the access tokens from lightspeed login, per API host
+const credentialsFile = ".lightspeed/credentials"
+
+// tokenEnv overrides the saved access token (for CI)
+const tokenEnv = "LIGHTSPEED_TOKEN"
+
+// credentials are the access tokens saved by lightspeed login
+type credentials struct {
+	Hosts map[string]credential `json:"hosts"`
+}
+
+// credential is the access token for an API host
+type credential struct {
+	Token     string `json:"token"`
+	Name      string `json:"name,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// credentialsPath returns the path of the credentials file
+func credentialsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, credentialsFile), nil
+}
+
+// loadCredentials reads the credentials file (empty if it doesn't exist)
+func loadCredentials() (*credentials, error) {
+	creds := &credentials{Hosts: map[string]credential{}}
+
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	if creds.Hosts == nil {
+		creds.Hosts = map[string]credential{}
+	}
+	return creds, nil
+}
+
+// saveCredentials writes the credentials file, readable only by the user
+func saveCredentials(creds *credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// accessToken returns the access token for the API host ("" if not logged in)
+// Priority: LIGHTSPEED_TOKEN env var > config token reference > lightspeed login
+func accessToken() string {
+	if token := os.Getenv(tokenEnv); token != "" {
+		return token
+	}
+	if cliConfig.Token != "" {
+		if token := resolveTokenReference(cliConfig.Token); token != "" {
+			return token
+		}
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return ""
+	}
+	return creds.Hosts[apiHost].Token
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+var cronSiteName string
+
+var cronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Manage the cron jobs of a site (scheduled requests)",
+	Long:  "Cron jobs are paths of the site the operator requests on a schedule, signed so the site can check they come from the operator. Jobs are set with cron.<name> in site.properties and applied by deploy and cron push.",
+}
+
+var cronListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the site's cron jobs and their last runs",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := cronSite()
+
+		cron, err := newBackend().GetCron(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get cron jobs: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if len(cron.Jobs) == 0 {
+			ui.PrintInfo("No cron jobs for '%s'", siteName)
+			fmt.Println()
+			return
+		}
+
+		ui.PrintInfo("Cron jobs of '%s' (UTC)", siteName)
+		fmt.Println()
+		printCronJobs(cron)
+		fmt.Println()
+	},
+}
+
+var cronPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Apply the cron jobs in site.properties, replacing the site's jobs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		dir, siteName := cronSite()
+
+		propsPath := properties.ProjectFile(dir)
+		if !properties.FileExists(propsPath) {
+			ui.PrintError("No site.properties in %s", dir)
+			os.Exit(1)
+		}
+		props, err := properties.ParseFile(propsPath)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+		jobs, err := getSiteCron(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		if jobs == nil {
+			ui.PrintError("No cron.<name> jobs in site.properties (use 'lightspeed cron rm' to remove the site's jobs)")
+			os.Exit(1)
+		}
+
+		cron, err := newBackend().SetCron(cmd.Context(), siteName, jobs)
+		if err != nil {
+			ui.PrintError("Failed to update cron jobs: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Set %d cron jobs for '%s'", len(cron.Jobs), siteName)
+		fmt.Println()
+		printCronJobs(cron)
+		fmt.Println()
+	},
+}
+
+var cronRunCmd = &cobra.Command{
+	Use:   "run <job>",
+	Short: "Run a cron job now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := cronSite()
+
+		ui.PrintInfo("Running %s...", args[0])
+		result, err := newBackend().RunCronJob(cmd.Context(), siteName, args[0])
+		if err != nil {
+			ui.PrintError("Failed to run cron job: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if result.Error != "" {
+			ui.PrintError("%s failed after %dms: %s", result.Name, result.DurationMs, result.Error)
+			os.Exit(1)
+		}
+		ui.PrintSuccess("%s returned HTTP %d in %dms", result.Name, result.Status, result.DurationMs)
+		fmt.Println()
+	},
+}
+
+var cronRmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove all cron jobs of the site",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := cronSite()
+
+		if _, err := newBackend().SetCron(cmd.Context(), siteName, nil); err != nil {
+			ui.PrintError("Failed to remove cron jobs: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Removed the cron jobs of '%s'", siteName)
+		fmt.Println()
+	},
+}
+
+// getSiteCron returns the site's cron jobs from site.properties, one per name:
+// cron.cleanup=*/15 * * * * /cron/cleanup.php requests the path every 15 minutes, and a method
+// before the path (cron.digest=@daily POST /cron/digest) sends it with POST
+// Jobs are sorted by name; nil without cron properties, so jobs set elsewhere are left alone
+func getSiteCron(props properties.Properties) ([]api.CronJob, error) {
+	var jobs []api.CronJob
+	for key := range props {
+		name, ok := strings.CutPrefix(key, "cron.")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(props.Get(key))
+		if len(fields) < 2 || !strings.HasPrefix(fields[len(fields)-1], "/") {
+			return nil, fmt.Errorf("%s must be a schedule followed by a path (e.g. */15 * * * * /cron/cleanup.php)", key)
+		}
+
+		job := api.CronJob{Name: name, Path: fields[len(fields)-1]}
+		fields = fields[:len(fields)-1]
+		if method := strings.ToUpper(fields[len(fields)-1]); method == "GET" || method == "POST" {
+			job.Method = method
+			fields = fields[:len(fields)-1]
+		}
+		job.Schedule = strings.Join(fields, " ")
+		if job.Schedule == "" {
+			return nil, fmt.Errorf("%s has no schedule", key)
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+// syncCron applies the cron jobs from site.properties, if it has any
+// Like synthetic checks, a failure is a warning and doesn't fail the deploy
+func syncCron(ctx context.Context, out *ui.Output, backend Backend, siteName string, jobs []api.CronJob) {
+	if jobs == nil {
+		return
+	}
+
+	if _, err := backend.SetCron(ctx, siteName, jobs); err != nil {
+		out.PrintWarning("Cron jobs not updated: %v", err)
+		return
+	}
+	out.PrintInfo("Cron jobs: %d from site.properties", len(jobs))
+}
+
+// printCronJobs prints cron jobs with the results of their last runs
+func printCronJobs(cron *api.SiteCron) {
+	results := make(map[string]api.CronResult, len(cron.Results))
+	for _, result := range cron.Results {
+		results[result.Name] = result
+	}
+
+	for _, job := range cron.Jobs {
+		fmt.Printf("  %-16s %-16s %s %s\n", job.Name, job.Schedule, job.Method, job.Path)
+		result, ok := results[job.Name]
+		switch {
+		case !ok:
+			fmt.Printf("    %s\n", ui.Muted("not run yet"))
+		case result.Error != "":
+			fmt.Printf("    %s\n", ui.ErrorStyle.Render(fmt.Sprintf("failed at %s: %s", result.RanAt, result.Error)))
+		default:
+			fmt.Printf("    %s\n", ui.Muted(fmt.Sprintf("HTTP %d in %dms at %s", result.Status, result.DurationMs, result.RanAt)))
+		}
+	}
+}
+
+// cronSite resolves the project directory and site cron commands apply to
+func cronSite() (string, string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, cronSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return dir, siteName
+}
+
+func init() {
+	cronCmd.PersistentFlags().StringVarP(&cronSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	cronCmd.AddCommand(cronListCmd)
+	cronCmd.AddCommand(cronPushCmd)
+	cronCmd.AddCommand(cronRunCmd)
+	cronCmd.AddCommand(cronRmCmd)
+	rootCmd.AddCommand(cronCmd)
+}
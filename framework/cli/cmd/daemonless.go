@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+// noDocker builds and pushes images in-process instead of with Docker (--no-docker)
+var noDocker bool
+
+// webRoot is where project files are served from in site images
+const webRoot = "var/www/html"
+
+// useDaemonless checks if images are built without Docker: with --no-docker, or when docker isn't installed
+func useDaemonless() bool {
+	if noDocker {
+		return true
+	}
+	_, err := exec.LookPath("docker")
+	return err != nil
+}
+
+// layerOwner owns the files of a built layer
+type layerOwner struct {
+	uid, gid int
+	name     string
+}
+
+// builtImage is an image built without Docker: one layer of project files on the base image
+// of each build platform
+type builtImage struct {
+	source    *registry.Client
+	layer     *registry.Layer
+	platforms []platformImage
+}
+
+// platformImage is the image of one platform
+type platformImage struct {
+	platform string
+	base     *registry.Image
+	manifest *registry.Manifest
+	config   []byte
+}
+
+// Remove deletes the layer's temporary file
+func (b *builtImage) Remove() {
+	if b != nil && b.layer != nil {
+		b.layer.Remove()
+	}
+}
+
+// buildImageDaemonless builds the project image in-process: the project files (with generated
+// files and .gz variants of text assets) are packed into a layer on top of the base image of
+// each build platform, which is read from its registry without being pulled
+// This only covers the generated Dockerfile; Composer isn't run, so vendor/ must exist.
+func buildImageDaemonless(ctx context.Context, dir, siteImage string) (*builtImage, error) {
+	platforms, err := buildPlatforms()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Dockerfile")); err == nil {
+		return nil, fmt.Errorf("the project's Dockerfile needs Docker to build (builds without Docker only cover the generated Dockerfile)")
+	}
+
+	siteInfo, err := loadSiteInfo(dir)
+	if err != nil {
+		return nil, err
+	}
+	if siteInfo != nil {
+		if err := validateSiteType(siteInfo.Type); err != nil {
+			return nil, err
+		}
+	}
+	static := isStaticSite(siteInfo)
+	compress := siteInfo == nil || siteInfo.Compress
+
+	if usesComposer(dir, siteInfo) {
+		if _, err := os.Stat(filepath.Join(dir, "vendor")); err != nil {
+			return nil, fmt.Errorf("composer.json needs Docker to install dependencies; run 'composer install --no-dev' first to build without Docker")
+		}
+		ui.PrintWarning("Composer isn't run without Docker; using the project's vendor/ as is")
+	}
+
+	generated, err := generatedFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate files: %w", err)
+	}
+	ignores, err := buildIgnores(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := newIgnoreMatcher(ignores)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the base image's manifest and config (its layers are copied on push)
+	baseImage := getBaseImage(siteImage)
+	owner := layerOwner{uid: 33, gid: 33, name: "www-data"}
+	if static {
+		baseImage = staticBaseImage(siteImage)
+		owner = layerOwner{name: "root"}
+		ui.PrintInfo("Building static site (nginx, no PHP)...")
+	}
+	ref, err := registry.ParseReference(baseImage)
+	if err != nil {
+		return nil, err
+	}
+	ui.PrintInfo("Reading base image %s...", baseImage)
+	source := registry.NewClient(ref.Registry, "", "")
+	if login := baseImageLogin(ctx, baseImage); login != nil {
+		source = registry.NewClient(ref.Registry, login.Username, login.Password)
+	}
+	bases := make([]*registry.Image, len(platforms))
+	for i, platform := range platforms {
+		if bases[i], err = source.GetImage(ctx, ref, registryPlatform(platform)); err != nil {
+			return nil, fmt.Errorf("failed to read base image: %w", err)
+		}
+	}
+
+	w, err := registry.NewLayerWriter()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create layer: %w", err)
+	}
+	count, err := writeProjectLayer(w, dir, matcher, generated, owner, static, compress)
+	if err != nil {
+		w.Abort()
+		return nil, err
+	}
+	layer, err := w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write layer: %w", err)
+	}
+	ui.PrintInfo("Packed %d files (%s)", count, formatBytes(layer.Size))
+
+	// Project files don't depend on the platform, so every platform gets the same layer
+	built := &builtImage{source: source, layer: layer}
+	for i, base := range bases {
+		manifest, config, err := base.AppendLayer(layer, "lightspeed: COPY . /"+webRoot+"/")
+		if err != nil {
+			layer.Remove()
+			return nil, err
+		}
+		built.platforms = append(built.platforms, platformImage{platform: platforms[i], base: base, manifest: manifest, config: config})
+	}
+	return built, nil
+}
+
+// writeProjectLayer writes the project files, generated files and (with compress) .gz variants
+// of text assets under the web root, plus the nginx configuration and start script of static sites
+// Returns the number of files written.
+func writeProjectLayer(w *registry.LayerWriter, dir string, matcher *ignoreMatcher, generated map[string]string, owner layerOwner, static, compress bool) (int, error) {
+	if static {
+		conf := []byte(staticNginxConfig)
+		if err := writeLayerFile(w, "etc/nginx/conf.d/default.conf", conf, 0644, layerOwner{name: "root"}); err != nil {
+			return 0, err
+		}
+		if err := writeLayerFile(w, "docker-entrypoint.d/40-lightspeed.sh", []byte(staticMetadataScript), 0755, layerOwner{name: "root"}); err != nil {
+			return 0, err
+		}
+	}
+
+	root := &tar.Header{Typeflag: tar.TypeDir, Name: webRoot + "/", Mode: 0755}
+	setOwner(root, owner)
+	if err := w.WriteHeader(root); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if matcher.SkipDir(rel) {
+				return filepath.SkipDir
+			}
+		}
+		if matcher.Ignored(rel) || (static && (rel == properties.PropertiesFile || rel == properties.ManifestFile)) {
+			return nil
+		}
+		// Generated files replace project files of the same name
+		if _, ok := generated[rel]; ok {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name := path.Join(webRoot, rel)
+		switch {
+		case entry.IsDir():
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name + "/"
+			setOwner(header, owner)
+			return w.WriteHeader(header)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, filepath.ToSlash(target))
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			setOwner(header, owner)
+			return w.WriteHeader(header)
+		case info.Mode().IsRegular():
+			count++
+			if compress && isCompressible(rel) {
+				data, err := os.ReadFile(p)
+				if err != nil {
+					return err
+				}
+				if err := writeLayerFile(w, name, data, int64(info.Mode().Perm()), owner); err != nil {
+					return err
+				}
+				return writeCompressed(w, name, data, info.Mode().Perm(), owner)
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			setOwner(header, owner)
+			if err := w.WriteHeader(header); err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(w, f)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to pack project files: %w", err)
+	}
+
+	names := make([]string, 0, len(generated))
+	for name := range generated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := []byte(generated[name])
+		full := path.Join(webRoot, name)
+		if err := writeLayerFile(w, full, data, 0644, owner); err != nil {
+			return 0, err
+		}
+		if compress && isCompressible(name) {
+			if err := writeCompressed(w, full, data, 0644, owner); err != nil {
+				return 0, err
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// writeLayerFile writes a file to a layer
+func writeLayerFile(w *registry.LayerWriter, name string, data []byte, mode int64, owner layerOwner) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: int64(len(data))}
+	setOwner(header, owner)
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeCompressed writes the .gz variant of a file (like gzip -k -9)
+func writeCompressed(w *registry.LayerWriter, name string, data []byte, mode fs.FileMode, owner layerOwner) error {
+	var compressed bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	gz.Name = path.Base(name)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return writeLayerFile(w, name+".gz", compressed.Bytes(), int64(mode), owner)
+}
+
+// isCompressible checks if a file is a text asset that gets a .gz variant
+func isCompressible(name string) bool {
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	for _, compressible := range staticCompressed {
+		if strings.EqualFold(ext, compressible) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOwner sets the owner of a layer entry
+func setOwner(header *tar.Header, owner layerOwner) {
+	header.Uid, header.Gid = owner.uid, owner.gid
+	header.Uname, header.Gname = owner.name, owner.name
+}
+
+// pushImageDaemonless pushes an image built without Docker under each of its tags
+// Base layers the registry doesn't have yet are copied from the base image's registry.
+// Images of several platforms are pushed by digest and tagged through a manifest list.
+func pushImageDaemonless(ctx context.Context, image *builtImage, images []string) error {
+	target := registry.NewClient(registryHost, "lightspeed", registryPassword())
+	first, err := registry.ParseReference(images[0])
+	if err != nil {
+		return err
+	}
+	repository := first.Repository
+
+	copied := map[string]bool{}
+	for _, p := range image.platforms {
+		for _, layer := range p.base.Manifest.Layers {
+			if copied[layer.Digest] {
+				continue
+			}
+			copied[layer.Digest] = true
+
+			exists, err := target.BlobExists(ctx, repository, layer.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to check layer: %w", err)
+			}
+			if exists {
+				fmt.Printf("• Layer %s already exists\n", shortDigest(layer.Digest))
+				continue
+			}
+
+			fmt.Printf("• Copying base layer %s (%s)...\n", shortDigest(layer.Digest), formatBytes(layer.Size))
+			blob, err := image.source.GetBlob(ctx, p.base.Reference.Repository, layer.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to read base layer: %w", err)
+			}
+			err = target.UploadBlob(ctx, repository, layer.Digest, layer.Size, blob)
+			blob.Close()
+			if err != nil {
+				return fmt.Errorf("failed to push base layer: %w", err)
+			}
+		}
+	}
+
+	spinner := ui.StartSpinner("Pushing project layer %s (%s)", shortDigest(image.layer.Digest), formatBytes(image.layer.Size))
+	f, err := image.layer.Open()
+	if err != nil {
+		spinner.Stop()
+		return err
+	}
+	err = target.UploadBlob(ctx, repository, image.layer.Digest, image.layer.Size, f)
+	f.Close()
+	spinner.Stop()
+	if err != nil {
+		return fmt.Errorf("failed to push project layer: %w", err)
+	}
+
+	var descriptors []registry.Descriptor
+	var manifest []byte
+	mediaType := ""
+	for _, p := range image.platforms {
+		config := p.manifest.Config
+		if err := target.UploadBlob(ctx, repository, config.Digest, config.Size, bytes.NewReader(p.config)); err != nil {
+			return fmt.Errorf("failed to push image config: %w", err)
+		}
+
+		if manifest, err = json.Marshal(p.manifest); err != nil {
+			return err
+		}
+		mediaType = p.manifest.MediaType
+		if len(image.platforms) == 1 {
+			break
+		}
+
+		digest := registry.Digest(manifest)
+		platform := registryPlatform(p.platform)
+		fmt.Printf("• Pushing %s image...\n", p.platform)
+		if err := target.PutManifest(ctx, repository, digest, mediaType, manifest); err != nil {
+			return err
+		}
+		descriptors = append(descriptors, registry.Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(manifest)), Platform: &platform})
+	}
+	if len(descriptors) > 0 {
+		index := registry.NewIndex(descriptors)
+		if manifest, err = json.Marshal(index); err != nil {
+			return err
+		}
+		mediaType = index.MediaType
+	}
+
+	for _, name := range images {
+		ref, err := registry.ParseReference(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("• Pushing %s...\n", name)
+		if err := target.PutManifest(ctx, ref.Repository, ref.Tag, mediaType, manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	dbSiteName string
+	dbEngine   string
+	dbVersion  string
+	dbSize     string
+	dbNodes    int
+	dbReveal   bool
+	dbForce    bool
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the managed database of a site",
+}
+
+var dbCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Provision a managed database (redeploys the site)",
+	Long:  "Provision a DigitalOcean managed database for the site and set its connection details as site variables (passwords as secrets). Running it again for a site that has a database sets the variables again.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dbSite()
+
+		ui.PrintInfo("Provisioning %s database for '%s'...", dbEngine, siteName)
+		db, err := newBackend().CreateDatabase(cmd.Context(), siteName, api.DatabaseRequest{
+			Engine:  dbEngine,
+			Version: dbVersion,
+			Size:    dbSize,
+			Nodes:   dbNodes,
+		})
+		if err != nil {
+			ui.PrintError("Failed to create database: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Database %s attached, the site is redeploying", db.Name)
+		fmt.Println()
+		printDatabase(db, false)
+		fmt.Println()
+		if db.Status != "online" {
+			ui.PrintInfo("The database takes a few minutes to come online; run 'lightspeed db info' to check")
+		} else {
+			ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+		}
+		fmt.Println()
+	},
+}
+
+var dbInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the connection details of the site's database",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dbSite()
+
+		db, err := newBackend().GetDatabase(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get database: %v", err)
+			os.Exit(1)
+		}
+
+		printDatabase(db, dbReveal)
+		fmt.Println()
+	},
+}
+
+var dbDestroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete the site's database and its data (redeploys the site)",
+	Long:  "Delete the site's managed database with all its data and remove its connection variables from the site. Asks for the database name to confirm unless --force is given.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+		siteName := dbSite()
+
+		db, err := backend.GetDatabase(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to get database: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintKeyValue("Site", siteName)
+		ui.PrintKeyValue("Database", fmt.Sprintf("%s (%s)", db.Name, db.Engine))
+		fmt.Println()
+
+		if !dbForce && !confirmDatabaseDestroy(db.Name) {
+			ui.PrintInfo("Aborted")
+			fmt.Println()
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Deleting database %s...", db.Name)
+		if err := backend.DeleteDatabase(ctx, siteName); err != nil {
+			ui.PrintError("Failed to delete database: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Deleted database %s, the site is redeploying", db.Name)
+		fmt.Println()
+	},
+}
+
+// dbSite resolves the site db commands apply to
+func dbSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, dbSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+// printDatabase prints a database's details, hiding its password unless reveal is set
+func printDatabase(db *api.Database, reveal bool) {
+	ui.PrintKeyValue("Database", db.Name)
+	engine := db.Engine
+	if db.Version != "" {
+		engine += " " + db.Version
+	}
+	ui.PrintKeyValue("Engine", engine)
+	if db.Size != "" {
+		ui.PrintKeyValue("Size", fmt.Sprintf("%s (%d node(s))", db.Size, db.Nodes))
+	}
+	if db.Region != "" {
+		ui.PrintKeyValue("Region", db.Region)
+	}
+	ui.PrintKeyValue("Status", db.Status)
+	if db.Host != "" {
+		ui.PrintKeyValue("Host", db.Host+":"+strconv.Itoa(db.Port))
+	}
+	if db.Database != "" {
+		ui.PrintKeyValue("Name", db.Database)
+	}
+	if db.User != "" {
+		ui.PrintKeyValue("User", db.User)
+	}
+	if db.Password != "" {
+		if reveal {
+			ui.PrintKeyValue("Password", db.Password)
+		} else {
+			ui.PrintKeyValue("Password", ui.Muted("(hidden, use --reveal)"))
+		}
+	}
+	if db.URI != "" && reveal {
+		ui.PrintKeyValue("URI", db.URI)
+	}
+	if len(db.Env) > 0 {
+		ui.PrintKeyValue("Variables", strings.Join(db.Env, ", "))
+	}
+}
+
+// confirmDatabaseDestroy asks the user to type the database name to confirm deleting it
+func confirmDatabaseDestroy(dbName string) bool {
+	if ciRefusesPrompt() {
+		return false
+	}
+	ui.PrintWarning("This permanently deletes the database and all its data")
+	fmt.Printf("Type '%s' to confirm: ", dbName)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	return strings.TrimSpace(answer) == dbName
+}
+
+func init() {
+	dbCmd.PersistentFlags().StringVarP(&dbSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	dbCreateCmd.Flags().StringVar(&dbEngine, "engine", "pg", "Database engine: pg, mysql or valkey")
+	dbCreateCmd.Flags().StringVar(&dbVersion, "version", "", "Engine version (default: the latest)")
+	dbCreateCmd.Flags().StringVar(&dbSize, "size", "", "Node size (default: db-s-1vcpu-1gb)")
+	dbCreateCmd.Flags().IntVar(&dbNodes, "nodes", 0, "Number of nodes, 1-3 (default: 1)")
+	dbInfoCmd.Flags().BoolVar(&dbReveal, "reveal", false, "Show the password and connection URI")
+	dbDestroyCmd.Flags().BoolVarP(&dbForce, "force", "f", false, "Delete without asking for confirmation")
+
+	dbCmd.AddCommand(dbCreateCmd)
+	dbCmd.AddCommand(dbInfoCmd)
+	dbCmd.AddCommand(dbDestroyCmd)
+	rootCmd.AddCommand(dbCmd)
+}
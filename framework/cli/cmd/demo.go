@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	demoTTL  time.Duration
+	demoName string
+)
+
+var demoCmd = &cobra.Command{
+	Use:   "demo <template>",
+	Short: "Deploy a temporary demo site from a template",
+	Long:  "Deploy a temporary site from an operator template without a local project. The site is deleted automatically when its TTL expires.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+
+		template, err := backend.GetTemplate(ctx, args[0])
+		if err != nil {
+			ui.PrintError("Failed to get template '%s': %v", args[0], err)
+			os.Exit(1)
+		}
+
+		siteName := demoName
+		if siteName == "" {
+			siteName = fmt.Sprintf("demo-%s-%s", template.Name, randomSuffix())
+		}
+		siteName = sanitizeContainerName(siteName)
+
+		dockerRegistry := getDockerRegistryHost()
+		image := fmt.Sprintf("%s/%s:latest", dockerRegistry, siteName)
+
+		ui.PrintKeyValue("Site", siteName)
+		ui.PrintKeyValue("Template", template.Name)
+		ui.PrintKeyValue("Expires in", demoTTL.String())
+		fmt.Println()
+
+		// Build a starter project in a temporary directory
+		dir, err := os.MkdirTemp("", "lightspeed-demo-")
+		if err != nil {
+			ui.PrintError("Failed to create temporary directory: %v", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := writeDemoProject(dir, template); err != nil {
+			ui.PrintError("Failed to create demo project: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Building Docker image...")
+		if err := buildDockerImage(ctx, dir, template.Image, []string{image}); err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("Run 'lightspeed demo " + template.Name + "' to start again")
+			}
+			ui.PrintError("Failed to build image: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println()
+
+		ui.PrintInfo("Logging in to registry...")
+		if err := dockerLogin(ctx, dockerRegistry); err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("Run 'lightspeed demo " + template.Name + "' to start again")
+			}
+			ui.PrintError("Failed to login to registry: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		if err := waitForRegistryWrites(ctx, ui.Stdout, backend); err != nil {
+			exitInterrupted("Run 'lightspeed demo " + template.Name + "' to start again")
+		}
+		if err := pushImage(ctx, image); err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("Run 'lightspeed demo " + template.Name + "' to start again")
+			}
+			ui.PrintError("Failed to push image: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		fmt.Println()
+
+		site := api.Site{
+			Name:     siteName,
+			Image:    siteName,
+			Tag:      "latest",
+			Template: template.Name,
+			TTL:      demoTTL.String(),
+			Region:   cliConfig.Region,
+
+			RandomSuffix: true,
+		}
+		siteURL, err := releaseSite(ctx, ui.Stdout, backend, site, nil)
+		if err != nil {
+			if interrupted(ctx) {
+				fmt.Println()
+				ui.PrintInfo("The demo site is still being created and expires on its own")
+				exitInterrupted("Run 'lightspeed demo " + template.Name + "' to start another")
+			}
+			ui.PrintError("Demo failed: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if !ciMode {
+			fmt.Println()
+			ui.PrintInfo("Opening browser...")
+			openBrowser(siteURL)
+		}
+
+		fmt.Println()
+		ui.PrintSuccess("Demo site is live!")
+		fmt.Printf("  %s\n", siteURL)
+		fmt.Println()
+		ui.PrintKeyValue("Expires", time.Now().Add(demoTTL).Format("Jan 2 15:04 MST"))
+		fmt.Println()
+	},
+}
+
+// writeDemoProject writes a starter page for a demo site
+func writeDemoProject(dir string, template *api.Template) error {
+	description := template.Description
+	if description == "" {
+		description = "A temporary Lightspeed demo site"
+	}
+
+	index := fmt.Sprintf(`<?php
+/**
+ * Lightspeed demo site
+ */
+?>
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Lightspeed Demo - %[1]s</title>
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; padding: 2rem;">
+    <h1>Hello from %[1]s!</h1>
+    <p>%[2]s</p>
+    <p>PHP <?= PHP_VERSION ?></p>
+</body>
+</html>
+`, html.EscapeString(template.Name), html.EscapeString(description))
+
+	return os.WriteFile(filepath.Join(dir, "index.php"), []byte(index), 0644)
+}
+
+// randomSuffix returns a short random hex string for unique site names
+func randomSuffix() string {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%04x", time.Now().UnixNano()&0xffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+func init() {
+	demoCmd.Flags().DurationVar(&demoTTL, "ttl", 2*time.Hour, "How long the demo site lives (max 24h)")
+	demoCmd.Flags().StringVarP(&demoName, "name", "n", "", "Site name (default: demo-<template>-<random>)")
+
+	rootCmd.AddCommand(demoCmd)
+}
@@ -1,75 +1,108 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net"
-	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/dns"
 	"lightspeed/core/lib/properties"
 	"lightspeed/core/lib/ui"
-	"lightspeed/core/lib/version"
 )
 
-// SiteStatus represents the status response from the API
-type SiteStatus struct {
-	Name   string   `json:"name"`
-	Status string   `json:"status"`
-	URLs   []string `json:"urls"`
-}
-
 var (
 	deploySiteName string
+	deployAll      bool
+	deployParallel int
+
+	deployCancelOnInterrupt bool
+	deployRandomSuffix      bool
+
+	deploySkip      []string
+	deploySkipBuild bool
+	deployNoBuild   bool
+	deployFrom      string
 )
 
 var deployCmd = &cobra.Command{
 	Use:   "deploy",
 	Short: "Build and deploy to Lightspeed",
-	Long:  "Build, push to registry, and deploy via Lightspeed operator",
+	Long:  "Build, push to registry, and deploy via Lightspeed operator. Runs the steps build, push, ensure-site, wait-deploy, verify, perf, hooks and open in order; use --skip to leave steps out and --from to resume at a step.",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Note: buildCmd.Run prints the header
-
 		dir, err := os.Getwd()
 		if err != nil {
 			ui.PrintError("Failed to get current directory: %v", err)
 			os.Exit(1)
 		}
 
+		// --on-commit is a kind of watch mode
+		deployWatch = deployWatch || deployOnCommit
+		if deployWatch && (deployAll || deployNoBuild || deploySkipBuild) {
+			ui.PrintError("--watch rebuilds the site on every change, so it can't be used with --all, --no-build or --skip-build")
+			os.Exit(1)
+		}
+		if deployDryRun && (deployAll || deployWatch) {
+			ui.PrintError("--dry-run can't be used with --all or --watch")
+			os.Exit(1)
+		}
+		if jsonOutput() && (deployDryRun || deployWatch) {
+			ui.PrintError("--output json can't be used with --dry-run or --watch")
+			os.Exit(1)
+		}
+		if deployEnvironment != "" {
+			if deployAll {
+				ui.PrintError("--env can't be used with --all")
+				os.Exit(1)
+			}
+			if err := validateEnvironment(dir, deployEnvironment); err != nil {
+				ui.PrintError("Invalid --env: %v", err)
+				os.Exit(1)
+			}
+		}
+		if deployOnCommit {
+			if _, err := headCommit(cmd.Context(), dir); err != nil {
+				ui.PrintError("--on-commit needs a git repository with at least one commit")
+				os.Exit(1)
+			}
+		}
+
+		// Deploy every site in the workspace
+		if deployAll {
+			deployWorkspace(cmd.Context(), dir, deployParallel)
+			return
+		}
+
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		timer := newPhaseTimer("deploy")
+		checkSiteProperties(dir)
+
 		projectName := filepath.Base(dir)
 		imageName := sanitizeContainerName(projectName)
 
 		// Load site.properties if it exists
 		var props properties.Properties
-		propsPath := filepath.Join(dir, "site.properties")
+		propsPath := properties.ProjectFile(dir)
 		if properties.FileExists(propsPath) {
-			props, err = properties.ParseProperties(propsPath)
+			props, err = parseProjectFile(propsPath)
 			if err != nil {
 				ui.PrintError("Failed to parse site.properties: %v", err)
 				os.Exit(1)
 			}
 		}
-
-		// Determine version tag
-		tag := publishTag
-		if tag == "" {
-			if version.IsGitRepo(dir) {
-				v, err := version.GetFromGit(dir)
-				if err == nil {
-					tag = v.String()
-				}
-			}
-			if tag == "" {
-				tag = "latest"
-			}
+		siteInfo, err := loadSiteInfo(dir)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
 		}
 
 		// Get site name from --name flag, then site.properties, then fallback to project name
@@ -81,195 +114,438 @@ var deployCmd = &cobra.Command{
 			siteName = imageName
 		}
 
-		// Set the publish name flag so publish command uses it
-		publishName = siteName
-
-		// Step 1: Build and push the image (prints header and initial info including site and platform)
-		publishCmd.Run(cmd, args)
-
-		// Step 2: Check if site exists
-		apiURL := getAPIURL()
-		ui.PrintInfo("Checking site '%s'...", siteName)
-		exists, err := siteExists(apiURL, siteName)
-		if err != nil {
-			ui.PrintError("Failed to check site: %v", err)
-			os.Exit(1)
+		// Get domains from site.properties if available
+		var domains []string
+		if props != nil {
+			// Support both "domain" (single) and "domains" (list)
+			domain := props.Get("domain")
+			if domain != "" {
+				domains = append(domains, domain)
+			}
+			domainsList := props.GetList("domains")
+			domains = append(domains, domainsList...)
 		}
 
-		if !exists {
-			// Get domains from site.properties if available
-			var domains []string
-			if props != nil {
-				// Support both "domain" (single) and "domains" (list)
-				domain := props.Get("domain")
-				if domain != "" {
-					domains = append(domains, domain)
-				}
-				domainsList := props.GetList("domains")
-				domains = append(domains, domainsList...)
+		// Registry image names (use Docker-specific host for Docker operations)
+		dockerRegistry := getDockerRegistryHost()
+		registryBase := fmt.Sprintf("%s/%s", dockerRegistry, siteName)
+
+		// Deploy the tag last published from this project instead of building a new one
+		var tag, commit string
+		var images []string
+		if deployNoBuild {
+			published := publishedFor(dir, siteName)
+			tag = published.Tag
+			commit = published.Commit
+			images = published.Images
+		} else {
+			commit = buildCommit(dir)
+			if deployDryRun {
+				tag, err = planTag(ctx, dir, siteName)
+			} else {
+				tag, err = resolveTag(ctx, dir, siteName, publishTag)
 			}
-
-			// Create new site
-			ui.PrintInfo("Creating site '%s'...", siteName)
-			// Use siteName for image because that's what publish command uses
-			err = createSite(apiURL, siteName, siteName, tag, domains)
 			if err != nil {
-				ui.PrintError("Failed to create site: %v", err)
+				ui.PrintError("Failed to determine tag: %v", err)
 				os.Exit(1)
 			}
-			ui.PrintSuccess("Created site '%s'", siteName)
-
-			// Wait for deployment to complete (new sites need to wait)
-			fmt.Println()
-			_, err := waitForDeployment(apiURL, siteName)
-			if err != nil {
-				ui.PrintError("Deployment failed: %v", err)
-				os.Exit(1)
+			images = []string{fmt.Sprintf("%s:%s", registryBase, tag)}
+			if tag != "latest" {
+				images = append(images, fmt.Sprintf("%s:latest", registryBase))
 			}
+		}
 
-			// Use lightspeed.ee URL
-			siteURL := fmt.Sprintf("https://%s.lightspeed.ee", siteName)
+		if _, err := buildPlatforms(); err != nil {
+			ui.PrintError("Invalid --platform: %v", err)
+			os.Exit(1)
+		}
 
-			// Wait for site to respond
-			fmt.Println()
-			if err := waitForURLReady(siteURL); err != nil{
-				ui.PrintError("Site deployment completed but URL not responding: %v", err)
-				fmt.Println()
-				ui.PrintKeyValue("URL", siteURL)
-				os.Exit(1)
-			}
+		sloTarget, err := getSLOTarget(props)
+		if err != nil {
+			ui.PrintError("Invalid slo in site.properties: %v", err)
+			os.Exit(1)
+		}
+		perf, err := getPerfBudget(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		hooks, err := getDeployHooks(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		service, err := getSiteService(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		ingress, err := getSiteIngress(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		settings, err := getSiteRuntime(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		edge, err := getSiteEdge(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		firewall, err := getSiteFirewall(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		cron, err := getSiteCron(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
 
-			// Open browser
-			fmt.Println()
-			ui.PrintInfo("Opening browser...")
-			openBrowser(siteURL)
+		printSiteInfo(siteName, tag, domains)
+		if deployEnvironment != "" {
+			ui.PrintKeyValue("Environment", deployEnvironment)
+		}
+		ui.PrintKeyValue("Registry", dockerRegistry)
+		ui.PrintKeyValue("Platform", apiHost)
+		fmt.Println()
 
-			// Final success message
-			fmt.Println()
-			ui.PrintSuccess("Deployed successfully!")
-			fmt.Printf("  %s\n", siteURL)
-		} else {
-			// Existing site - deploy_on_push triggers deployment automatically
-			// Wait for deployment to complete
-			ui.PrintInfo("Deployment triggered by image push")
+		state := &deployState{
+			Dir: dir,
+			Site: api.Site{
+				Name:     siteName,
+				Image:    siteName,
+				Tag:      tag,
+				Commit:   commit,
+				Domains:  domains,
+				Template: props.Get("template"),
+				Region:   siteRegion(props),
+
+				RandomSuffix: deployRandomSuffix,
+				BaseDomain:   props.Get("base_domain"),
+				Service:      service,
+				Ingress:      ingress,
+			},
+			Images:    images,
+			Registry:  dockerRegistry,
+			Resolvers: getCheckResolvers(props),
+			SLOTarget: sloTarget,
+			Perf:      perf,
+			Hooks:     hooks,
+			Edge:      edge,
+			Firewall:  firewall,
+			Cron:      cron,
+			Backend:   newBackend(),
+
+			Daemonless:    useDaemonless(),
+			MultiPlatform: multiPlatform(),
+		}
+		settings.apply(&state.Site)
+		if siteInfo != nil {
+			state.SiteImage = siteInfo.Image
+		}
 
-			fmt.Println()
-			_, err := waitForRedeployment(apiURL, siteName)
-			if err != nil {
-				ui.PrintError("Deployment failed: %v", err)
+		// Select the steps to run
+		steps := newPipeline(deploySteps...)
+		skip := deploySkip
+		if deploySkipBuild || deployNoBuild {
+			skip = append(skip, "build")
+		}
+		if deployNoBuild {
+			skip = append(skip, "push")
+		}
+		// The performance probe only runs with a budget to check
+		if !perf.Enabled() {
+			skip = append(skip, "perf")
+		}
+		// Hooks purge caches and notify search engines, so only production deploys run them
+		if !hooks.Enabled() || siteEnvironment(props) != productionEnvironment {
+			skip = append(skip, "hooks")
+		}
+		// Nobody is there to look at the browser in CI
+		if ciMode {
+			skip = append(skip, "open")
+		}
+		if err := steps.Skip(skip...); err != nil {
+			ui.PrintError("Invalid --skip: %v", err)
+			os.Exit(1)
+		}
+		if err := steps.From(deployFrom); err != nil {
+			ui.PrintError("Invalid --from: %v", err)
+			os.Exit(1)
+		}
+
+		// Show what would happen without touching Docker or the site
+		if deployDryRun {
+			if err := printDeployPlan(ctx, state, steps); err != nil {
+				ui.PrintError("Failed to plan deploy: %v", err)
+				printErrorHint(err)
 				os.Exit(1)
 			}
+			return
+		}
 
-			// Use lightspeed.ee URL
-			siteURL := fmt.Sprintf("https://%s.lightspeed.ee", siteName)
-
-			// Wait for site to respond
-			fmt.Println()
-			if err := waitForURLReady(siteURL); err != nil {
-				ui.PrintError("Site deployment completed but URL not responding: %v", err)
+		if err := steps.Run(ctx, state); err != nil {
+			var stepErr *pipelineError
+			errors.As(err, &stepErr)
+			if interrupted(ctx) {
+				if stepErr.Step == "wait-deploy" || stepErr.Step == "verify" {
+					handleDeployInterrupt(siteName)
+				}
+				exitInterrupted(fmt.Sprintf("Run 'lightspeed deploy --from %s' to resume", stepErr.Step))
+			}
+			ui.PrintError("Deploy failed at %s: %v", stepErr.Step, stepErr.Err)
+			printErrorHint(stepErr.Err)
+			if state.URL != "" {
 				fmt.Println()
-				ui.PrintKeyValue("URL", siteURL)
-				os.Exit(1)
+				ui.PrintKeyValue("URL", state.URL)
 			}
-
-			// Open browser
 			fmt.Println()
-			ui.PrintInfo("Opening browser...")
-			openBrowser(siteURL)
+			ui.PrintInfo("Run 'lightspeed deploy --from %s' to retry from this step", stepErr.Step)
+			if jsonOutput() {
+				result := newDeployOutput(ctx, state, steps)
+				result.FailedStep, result.Error = stepErr.Step, stepErr.Err.Error()
+				printJSON(result)
+			}
+			os.Exit(stepExitCode(stepErr.Step))
+		}
 
-			fmt.Println()
-			ui.PrintSuccess("Deployed successfully!")
-			fmt.Printf("  %s\n", siteURL)
+		// Final success message
+		ui.PrintSuccess("Deployed successfully!")
+		if state.URL != "" {
+			fmt.Printf("  %s\n", state.URL)
 		}
 		fmt.Println()
+		for _, timing := range steps.timings {
+			if !timing.Skipped {
+				timer.Add(deployPhase(timing.Name), timing.Duration)
+			}
+		}
+		if !timingsEnabled() {
+			steps.PrintTimings()
+			fmt.Println()
+		}
+		timer.Finish(dir, tag)
+
+		switch {
+		case jsonOutput():
+			printJSON(newDeployOutput(ctx, state, steps))
+		case ciMode:
+			result := newDeployOutput(ctx, state, steps)
+			printCIResult(result.URL, result.DeploymentID)
+		}
+
+		// Keep redeploying changes; the browser is only opened by the first deploy
+		if deployWatch {
+			watchDeploys(ctx, state, append(skip, "open"))
+		}
 	},
 }
 
-// siteExists checks if a site exists via the operator API
-func siteExists(operatorURL, name string) (bool, error) {
-	url := fmt.Sprintf("%s/sites/%s", operatorURL, name)
-	resp, err := http.Get(url)
+// deployOutput is the result of deploy with --output json
+type deployOutput struct {
+	Site         string             `json:"site"`
+	Tag          string             `json:"tag"`
+	Images       []string           `json:"images"`
+	Created      bool               `json:"created"`
+	URL          string             `json:"url,omitempty"`
+	DeploymentID string             `json:"deployment_id,omitempty"`
+	Status       string             `json:"status,omitempty"`
+	Steps        []deployStepOutput `json:"steps"`
+	FailedStep   string             `json:"failed_step,omitempty"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// deployStepOutput is how long a deploy step took with --output json
+type deployStepOutput struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+	Skipped bool    `json:"skipped,omitempty"`
+}
+
+// newDeployOutput collects the result of a deploy, with the site's deployment as the operator reports it
+func newDeployOutput(ctx context.Context, d *deployState, steps *pipeline) deployOutput {
+	result := deployOutput{
+		Site:    d.Site.Name,
+		Tag:     d.Site.Tag,
+		Images:  d.Images,
+		Created: d.Created,
+		URL:     d.URL,
+		Steps:   []deployStepOutput{},
+	}
+	if status, err := d.Backend.GetSiteStatus(ctx, d.Site.Name); err == nil {
+		result.DeploymentID = status.DeploymentID
+		result.Status = status.Status
+	}
+	for _, timing := range steps.timings {
+		result.Steps = append(result.Steps, deployStepOutput{
+			Name:    timing.Name,
+			Seconds: timing.Duration.Round(100 * time.Millisecond).Seconds(),
+			Skipped: timing.Skipped,
+		})
+	}
+	return result
+}
+
+// publishedFor returns the image last published from a project for a site, exiting if there is none
+func publishedFor(dir, siteName string) *publishedImage {
+	state, err := loadProjectState(dir)
 	if err != nil {
-		return false, err
+		ui.PrintError("Failed to load project state: %v", err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK, nil
+	published := state.Published
+	if published == nil {
+		ui.PrintError("Nothing has been published from this project")
+		ui.PrintInfo("Run 'lightspeed publish' first, or deploy without --no-build")
+		os.Exit(1)
+	}
+	if published.Site != siteName {
+		ui.PrintError("The last publish was for site '%s', not '%s'", published.Site, siteName)
+		ui.PrintInfo("Run 'lightspeed publish -n %s' first", siteName)
+		os.Exit(1)
+	}
+	return published
 }
 
-// createSite creates a new site via the operator API
-func createSite(operatorURL, name, image, tag string, domains []string) error {
-	url := fmt.Sprintf("%s/sites", operatorURL)
-
-	payload := map[string]interface{}{
-		"name":  name,
-		"image": image,
-		"tag":   tag,
+// handleDeployInterrupt cancels the remote deployment if requested and exits with a resumable next step
+func handleDeployInterrupt(siteName string) {
+	if !deployCancelOnInterrupt {
+		fmt.Println()
+		ui.PrintInfo("The deployment continues in the background")
+		exitInterrupted("Run 'lightspeed deploy --from wait-deploy' to resume waiting for it")
 	}
-	if len(domains) > 0 {
-		payload["domains"] = domains
+
+	fmt.Println()
+	ui.PrintInfo("Canceling deployment of '%s'...", siteName)
+	if err := newBackend().CancelDeployment(context.Background(), siteName); err != nil {
+		ui.PrintWarning("Failed to cancel deployment: %v", err)
+	} else {
+		ui.PrintSuccess("Deployment canceled")
 	}
-	body, _ := json.Marshal(payload)
+	exitInterrupted("Run 'lightspeed deploy' to deploy again")
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+// releaseSite creates the site if needed (or waits for the push-triggered redeploy),
+// then waits for the site and its custom domains to respond
+// Returns the site URL, which is also set when the deployment succeeded but the URL isn't responding
+func releaseSite(ctx context.Context, out *ui.Output, backend Backend, site api.Site, resolvers []string) (string, error) {
+	created, domain, err := ensureSite(ctx, out, backend, site)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+	out.Blank()
+	if err := waitForRelease(ctx, out, backend, site.Name, created); err != nil {
+		return "", err
 	}
 
-	return nil
+	siteURL := siteURLFor(ctx, backend, site.Name, domain)
+	out.Blank()
+	return siteURL, verifySite(ctx, out, siteURL, site.Domains, resolvers)
 }
 
-// triggerDeploy triggers a deployment via the operator API
-func triggerDeploy(operatorURL, name string) error {
-	url := fmt.Sprintf("%s/sites/%s/deploy", operatorURL, name)
+// ensureSite creates the site if it doesn't exist
+// Returns whether it was created, and the domain the operator allocated for a new site
+func ensureSite(ctx context.Context, out *ui.Output, backend Backend, site api.Site) (bool, string, error) {
+	siteName := site.Name
 
-	resp, err := http.Post(url, "application/json", nil)
+	out.PrintInfo("Checking site '%s'...", siteName)
+	exists, err := backend.SiteExists(ctx, siteName)
 	if err != nil {
-		return err
+		return false, "", fmt.Errorf("failed to check site: %w", err)
+	}
+	if exists {
+		// Existing site - the operator deploys the pushed image and records its release
+		if status, err := backend.GetSiteStatus(ctx, siteName); err == nil && status.Tag != "" && site.Tag != "" && status.Tag != site.Tag {
+			out.PrintInfo("Switching site from %s to %s...", status.Tag, site.Tag)
+		} else {
+			out.PrintInfo("Deploying %s...", site.Tag)
+		}
+		if _, err := backend.TriggerDeploy(ctx, siteName, api.DeployRequest{Tag: site.Tag, Commit: site.Commit, Service: site.Service, Ingress: site.Ingress}); err != nil {
+			return false, "", fmt.Errorf("failed to deploy %s: %w", site.Tag, err)
+		}
+		return false, "", nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+	// Create new site
+	if site.Template != "" {
+		out.PrintInfo("Creating site '%s' from template '%s'...", siteName, site.Template)
+	} else {
+		out.PrintInfo("Creating site '%s'...", siteName)
+	}
+	created, err := backend.CreateSite(ctx, site)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to create site: %w", err)
+	}
+	out.PrintSuccess("Created site '%s'", siteName)
+	if created.Domain != "" && created.Domain != siteName+".lightspeed.ee" {
+		out.PrintKeyValue("Domain", created.Domain)
 	}
 
-	return nil
+	return true, created.Domain, nil
 }
 
-// getSiteStatus gets the current status of a site
-func getSiteStatus(operatorURL, name string) (*SiteStatus, error) {
-	url := fmt.Sprintf("%s/sites/%s", operatorURL, name)
+// warnErrorBudget warns before redeploying a site that has used most of this month's error budget
+// Sites that don't exist yet or have no uptime checks are skipped
+func warnErrorBudget(ctx context.Context, out *ui.Output, backend Backend, siteName string, target float64) {
+	slo, err := backend.GetSiteSLO(ctx, siteName, target)
+	if err != nil || slo.Checks == 0 {
+		return
+	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	switch slo.Status {
+	case "exhausted":
+		out.PrintWarning("Error budget of '%s' is exhausted: %.1f minutes down this month, %.1f allowed by the %v%% SLO", siteName, slo.DowntimeMinutes, slo.BudgetMinutes, slo.Target)
+	case "at_risk":
+		out.PrintWarning("Error budget of '%s' is nearly exhausted: %.1f%% left (%.1f of %.1f minutes used this month, %v%% SLO)", siteName, slo.BudgetRemaining, slo.DowntimeMinutes, slo.BudgetMinutes, slo.Target)
+	default:
+		return
 	}
+	out.PrintInfo("A failed deploy may break the SLO; consider deploying when the site is stable")
+}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, err
+// waitForRelease waits for the deployment of a new site, or the redeploy of an existing one
+func waitForRelease(ctx context.Context, out *ui.Output, backend Backend, siteName string, created bool) error {
+	wait := waitForRedeployment
+	if created {
+		// New sites need to wait for their first deployment
+		wait = waitForDeployment
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+	if _, err := wait(ctx, out, backend, siteName); err != nil {
+		return fmt.Errorf("deployment failed: %w", err)
 	}
+	return nil
+}
 
-	var status SiteStatus
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return nil, err
+// siteURLFor returns the URL of a site, using the domain the operator allocated
+func siteURLFor(ctx context.Context, backend Backend, siteName, domain string) string {
+	if domain == "" {
+		if status, err := backend.GetSiteStatus(ctx, siteName); err == nil {
+			domain = status.Domain
+		}
+	}
+	if domain == "" {
+		domain = siteName + ".lightspeed.ee"
+	}
+	return "https://" + domain
+}
+
+// verifySite waits for the site URL to respond, then checks its custom domains
+// Custom domains that aren't ready only produce warnings
+func verifySite(ctx context.Context, out *ui.Output, siteURL string, domains []string, resolvers []string) error {
+	if err := waitForURLReady(ctx, out, siteURL, resolvers); err != nil {
+		return fmt.Errorf("site deployment completed but URL not responding: %w", err)
 	}
 
-	return &status, nil
+	waitForDomainsReady(ctx, out, domains, resolvers)
+	return nil
 }
 
 // getDigitalOceanURL extracts the .ondigitalocean.app URL from a list of URLs
@@ -287,186 +563,418 @@ func getDigitalOceanURL(urls []string) string {
 }
 
 // waitForRedeployment waits for an existing app to redeploy (DEPLOYING → ACTIVE)
-func waitForRedeployment(operatorURL, name string) (string, error) {
-	ui.PrintInfo("Waiting for deployment...")
+func waitForRedeployment(ctx context.Context, out *ui.Output, backend Backend, name string) (string, error) {
+	phases := startDeployPhases(ctx, out, backend, name)
+	defer phases.stop()
 
-	lastStatus := ""
 	sawDeploying := false
 	firstActiveTime := time.Time{}
-	timeout := time.After(5 * time.Minute)
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			return "", fmt.Errorf("deployment timed out after 5 minutes")
-		case <-ticker.C:
-			status, err := getSiteStatus(operatorURL, name)
-			if err != nil {
-				continue
-			}
+	siteURL := ""
 
-			// Show status change
-			if status.Status != lastStatus {
-				statusDisplay := formatStatus(status.Status)
-				ui.PrintKeyValue("  Status", statusDisplay)
-				lastStatus = status.Status
-			}
+	err := pollSiteStatus(ctx, backend, name, 5*time.Minute, func(status *api.SiteResponse) (bool, error) {
+		phase := sitePhase(status)
+		phases.update(phase)
 
-			// Track if we've seen deploying state
-			// SUPERSEDED means old deployment was replaced by new one
-			if status.Status == "DEPLOYING" || status.Status == "PENDING_DEPLOY" || status.Status == "BUILDING" || status.Status == "PENDING_BUILD" || status.Status == "SUPERSEDED" {
-				sawDeploying = true
-				firstActiveTime = time.Time{} // Reset active timer
-			}
+		// Track if we've seen deploying state
+		// SUPERSEDED means old deployment was replaced by new one
+		if phase == "DEPLOYING" || phase == "PENDING_DEPLOY" || phase == "BUILDING" || phase == "PENDING_BUILD" || phase == "SUPERSEDED" {
+			sawDeploying = true
+			firstActiveTime = time.Time{} // Reset active timer
+		}
 
-			// If ACTIVE and we saw deploying, deployment is complete
-			if status.Status == "ACTIVE" && sawDeploying {
-				return getDigitalOceanURL(status.URLs), nil
-			}
+		// If ACTIVE and we saw deploying, deployment is complete
+		if phase == "ACTIVE" && sawDeploying {
+			siteURL = getDigitalOceanURL(status.URLs)
+			return true, nil
+		}
 
-			// If ACTIVE but no deploying state seen yet, track how long it's been ACTIVE
-			// After 30 seconds of ACTIVE without seeing deploying, assume no deployment needed
-			if status.Status == "ACTIVE" && !sawDeploying {
-				if firstActiveTime.IsZero() {
-					firstActiveTime = time.Now()
-				} else if time.Since(firstActiveTime) > 30*time.Second {
-					ui.PrintInfo("No new deployment detected (already up to date)")
-					return getDigitalOceanURL(status.URLs), nil
-				}
+		// If ACTIVE but no deploying state seen yet, track how long it's been ACTIVE
+		// After 30 seconds of ACTIVE without seeing deploying, assume no deployment needed
+		if phase == "ACTIVE" && !sawDeploying {
+			if firstActiveTime.IsZero() {
+				firstActiveTime = time.Now()
+			} else if time.Since(firstActiveTime) > 30*time.Second {
+				out.PrintInfo("No new deployment detected (already up to date)")
+				siteURL = getDigitalOceanURL(status.URLs)
+				return true, nil
 			}
+		}
 
-			// Handle failures
-			if status.Status == "ERROR" || status.Status == "FAILED" {
-				return "", fmt.Errorf("deployment failed with status: %s", status.Status)
-			}
+		// Handle failures
+		if phase == "ERROR" || phase == "FAILED" {
+			return false, fmt.Errorf("deployment failed with status: %s", phase)
 		}
+		return false, nil
+	})
+	if err != nil && !interrupted(ctx) {
+		phases.showFailure()
 	}
+
+	return siteURL, err
 }
 
 // waitForDeployment polls for deployment status and shows progress (new sites)
-func waitForDeployment(operatorURL, name string) (string, error) {
-	ui.PrintInfo("Waiting for deployment...")
-
-	lastStatus := ""
-	timeout := time.After(10 * time.Minute)
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			return "", fmt.Errorf("deployment timed out after 10 minutes")
-		case <-ticker.C:
-			status, err := getSiteStatus(operatorURL, name)
-			if err != nil {
-				// Might not be ready yet, continue polling
-				continue
-			}
+func waitForDeployment(ctx context.Context, out *ui.Output, backend Backend, name string) (string, error) {
+	phases := startDeployPhases(ctx, out, backend, name)
+	defer phases.stop()
+
+	siteURL := ""
+
+	err := pollSiteStatus(ctx, backend, name, 10*time.Minute, func(status *api.SiteResponse) (bool, error) {
+		phase := sitePhase(status)
+		phases.update(phase)
+
+		// Check for terminal states
+		switch phase {
+		case "ACTIVE":
+			siteURL = getDigitalOceanURL(status.URLs)
+			return true, nil
+		case "ERROR", "FAILED":
+			return false, fmt.Errorf("deployment failed with status: %s", phase)
+		case "CANCELED":
+			return false, fmt.Errorf("deployment was canceled")
+		}
+		return false, nil
+	})
+	if err != nil && !interrupted(ctx) {
+		phases.showFailure()
+	}
 
-			// Show status change
-			if status.Status != lastStatus {
-				statusDisplay := formatStatus(status.Status)
-				ui.PrintKeyValue("  Status", statusDisplay)
-				lastStatus = status.Status
-			}
+	return siteURL, err
+}
 
-			// Check for terminal states
-			switch status.Status {
-			case "ACTIVE":
-				return getDigitalOceanURL(status.URLs), nil
-			case "ERROR", "FAILED":
-				return "", fmt.Errorf("deployment failed with status: %s", status.Status)
-			case "CANCELED":
-				return "", fmt.Errorf("deployment was canceled")
-			}
-		}
+// deployPhases shows the phases of a deployment while waiting for it, with the time each took
+// A spinner shows the current phase and how long it has been running, and the build and deploy
+// logs are streamed under it
+type deployPhases struct {
+	out     *ui.Output
+	spinner *ui.Spinner
+	status  string
+	logs    *deployLogs
+}
+
+// startDeployPhases starts showing the phases of a site's deployment
+func startDeployPhases(ctx context.Context, out *ui.Output, backend Backend, name string) *deployPhases {
+	return &deployPhases{
+		out:     out,
+		spinner: out.StartSpinner("Waiting for deployment"),
+		logs:    startDeployLogs(ctx, out, backend, name),
 	}
 }
 
-// waitForURLReady does a quick check to see if the URL is responding
-func waitForURLReady(siteURL string) error {
-	ui.PrintInfo("Waiting for site to respond...")
-	maxAttempts := 60 // 60 attempts * 5 seconds = 5 minutes
-	retryDelay := 5 * time.Second
+// update prints a change of the deployment's status with the time the previous phase took
+func (p *deployPhases) update(status string) {
+	p.logs.update(status)
+	if status == p.status {
+		return
+	}
+	line := formatStatus(status)
+	if p.status != "" {
+		line += ui.Muted(fmt.Sprintf(" (%s took %s)", strings.ToLower(strings.TrimSuffix(formatStatus(p.status), "...")), ui.FormatElapsed(p.spinner.Elapsed())))
+	}
+	p.out.PrintKeyValue("  Status", line)
+	p.status = status
+	p.spinner.Restart("%s", strings.TrimSuffix(formatStatus(status), "..."))
+}
+
+// stop stops the spinner and the log streams
+func (p *deployPhases) stop() {
+	p.logs.stop()
+	p.spinner.Stop()
+}
+
+// showFailure shows the logs of the phase a deployment failed in, unless they were streamed
+func (p *deployPhases) showFailure() {
+	p.spinner.Stop()
+	p.logs.showFailure()
+}
 
-	// Parse hostname from URL
-	var hostname string
-	if strings.HasPrefix(siteURL, "https://") {
-		hostname = strings.TrimPrefix(siteURL, "https://")
-	} else if strings.HasPrefix(siteURL, "http://") {
-		hostname = strings.TrimPrefix(siteURL, "http://")
+// getCheckResolvers returns the nameservers used for readiness checks
+// Priority: LIGHTSPEED_RESOLVERS env var > site.properties resolvers > system resolver
+func getCheckResolvers(props properties.Properties) []string {
+	if env := os.Getenv("LIGHTSPEED_RESOLVERS"); env != "" {
+		return dns.ParseServers(env)
+	}
+	if props != nil {
+		return props.GetList("resolvers")
 	}
-	hostname = strings.Split(hostname, "/")[0]
+	return nil
+}
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		// Use Google's DNS (8.8.8.8) to resolve hostname and get IP
-		resolver := &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{Timeout: 10 * time.Second}
-				return d.DialContext(ctx, network, "8.8.8.8:53")
-			},
+// siteRegion returns the region new sites are created in
+// Priority: site.properties region > config region > operator default
+func siteRegion(props properties.Properties) string {
+	if region := props.Get("region"); region != "" {
+		return region
+	}
+	return cliConfig.Region
+}
+
+// getSiteService returns the service deployed alongside the site from site.properties
+// (service.image and optional service.name/tag/port/path/instances/size); nil without service.image
+// Defaults are left to the operator
+func getSiteService(props properties.Properties) (*api.SiteService, error) {
+	image := props.Get("service.image")
+	if image == "" {
+		return nil, nil
+	}
+
+	service := &api.SiteService{
+		Name:  props.Get("service.name"),
+		Image: image,
+		Tag:   props.Get("service.tag"),
+		Path:  props.Get("service.path"),
+		Size:  props.Get("service.size"),
+	}
+	for _, field := range []struct {
+		key    string
+		target *int
+	}{{"service.port", &service.Port}, {"service.instances", &service.Instances}} {
+		key, value := field.key, props.Get(field.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s %q must be a positive number", key, value)
 		}
+		*field.target = n
+	}
+	return service, nil
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		ips, err := resolver.LookupHost(ctx, hostname)
-		cancel()
+// siteRuntime holds the settings of the site's container from site.properties: port,
+// instances, size, env.KEY=value variables and label.KEY=value labels; they're applied when the
+// site is created
+// Unset settings are left to the operator (or the site's template)
+type siteRuntime struct {
+	Port      int
+	Instances int
+	Size      string
+	Env       map[string]string
+	Labels    map[string]string
+}
 
-		if err != nil || len(ips) == 0 {
-			// DNS not propagated yet
-			if attempt%6 == 0 {
-				ui.PrintInfo("DNS not yet propagated, retrying...")
+var (
+	// envNamePattern matches an environment variable name
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// labelKeyPattern and labelValuePattern match the site labels the operator accepts
+	labelKeyPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{0,63}$`)
+)
+
+// getSiteRuntime returns the runtime settings of the site's container from site.properties
+func getSiteRuntime(props properties.Properties) (siteRuntime, error) {
+	settings := siteRuntime{Size: props.Get("size")}
+	for _, field := range []struct {
+		key    string
+		target *int
+		max    int
+	}{{"port", &settings.Port, 65535}, {"instances", &settings.Instances, 10}} {
+		key, value := field.key, props.Get(field.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > field.max {
+			return siteRuntime{}, fmt.Errorf("%s %q must be a number between 1 and %d", key, value, field.max)
+		}
+		*field.target = n
+	}
+
+	for key := range props {
+		if name, ok := strings.CutPrefix(key, "env."); ok {
+			if !envNamePattern.MatchString(name) {
+				return siteRuntime{}, fmt.Errorf("%s: %q is not a valid environment variable name", key, name)
 			}
-			if attempt < maxAttempts {
-				time.Sleep(retryDelay)
+			if settings.Env == nil {
+				settings.Env = map[string]string{}
 			}
+			settings.Env[name] = props.Get(key)
+		}
+		if name, ok := strings.CutPrefix(key, "label."); ok {
+			if !labelKeyPattern.MatchString(name) {
+				return siteRuntime{}, fmt.Errorf("%s: %q is not a valid label (lowercase letters, digits, '.', '_' and '-')", key, name)
+			}
+			if value := props.Get(key); !labelValuePattern.MatchString(value) {
+				return siteRuntime{}, fmt.Errorf("%s: %q is not a valid label value (letters, digits, '.', '_' and '-', up to 63)", key, value)
+			}
+			if settings.Labels == nil {
+				settings.Labels = map[string]string{}
+			}
+			settings.Labels[name] = props.Get(key)
+		}
+	}
+	return settings, nil
+}
+
+// apply sets the runtime settings on the site to create
+func (r siteRuntime) apply(site *api.Site) {
+	site.Port = r.Port
+	site.Instances = r.Instances
+	site.Size = r.Size
+	site.Env = r.Env
+	site.Labels = r.Labels
+}
+
+// getSiteIngress returns the site's routing rules from site.properties, one per path:
+// ingress./blog=blog routes /blog to the blog component ("site" is the site, service names are
+// expanded), optionally followed by ", preserve" to keep the prefix or ", rewrite=/path" to replace it
+// Rules are sorted by path; nil without ingress properties
+func getSiteIngress(props properties.Properties) ([]api.IngressRule, error) {
+	var rules []api.IngressRule
+	for key := range props {
+		path, ok := strings.CutPrefix(key, "ingress.")
+		if !ok {
 			continue
 		}
+		options := strings.Split(props.Get(key), ",")
+		rule := api.IngressRule{Path: path, Component: strings.TrimSpace(options[0])}
+		if rule.Component == "" {
+			return nil, fmt.Errorf("%s must name a component (e.g. site)", key)
+		}
+		for _, option := range options[1:] {
+			option = strings.TrimSpace(option)
+			switch {
+			case option == "preserve":
+				rule.PreservePathPrefix = true
+			case strings.HasPrefix(option, "rewrite="):
+				rule.Rewrite = strings.TrimPrefix(option, "rewrite=")
+			default:
+				return nil, fmt.Errorf("%s has unknown option %q (use preserve or rewrite=/path)", key, option)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Path < rules[j].Path })
+	return rules, nil
+}
+
+// getSLOTarget returns the availability target from site.properties (0 if not set)
+func getSLOTarget(props properties.Properties) (float64, error) {
+	value := props.Get("slo")
+	if value == "" {
+		return 0, nil
+	}
 
-		// Got IP! Now check if site responds
-		ip := ips[0]
+	target, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || target <= 0 || target >= 100 {
+		return 0, fmt.Errorf("%q must be a percentage between 0 and 100", value)
+	}
+	return target, nil
+}
 
-		// Create HTTP request to IP with Host header set to hostname
-		req, _ := http.NewRequest("GET", "https://"+ip+"/", nil)
-		req.Host = hostname
+// waitForURLReady does a quick check to see if the URL is responding
+func waitForURLReady(ctx context.Context, out *ui.Output, siteURL string, resolvers []string) error {
+	spinner := out.StartSpinner("Waiting for site to respond")
+	defer spinner.Stop()
+	maxAttempts := 60 // 60 attempts * 5 seconds = 5 minutes
+	retryDelay := 5 * time.Second
 
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-					ServerName:         hostname, // For SNI
-				},
-			},
-		}
+	resolver := dns.NewResolver(resolvers)
 
-		resp, err := client.Do(req)
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		statusCode, err := resolver.CheckURL(ctx, siteURL)
+		lastErr = err
 		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			if statusCode >= 200 && statusCode < 400 {
 				return nil
 			}
 			// Show status code if not in success range
 			if attempt%6 == 0 { // Log every 30 seconds
-				ui.PrintInfo("Site returned status %d, still waiting...", resp.StatusCode)
+				out.PrintInfo("Site returned status %d, still waiting...", statusCode)
+			}
+		} else if errors.Is(err, dns.ErrNotResolved) {
+			// DNS not propagated yet
+			if attempt%6 == 0 {
+				out.PrintInfo("DNS not yet propagated (resolver: %s), retrying...", resolver.Describe())
 			}
 		} else {
 			// Log errors occasionally
 			if attempt%6 == 0 { // Log every 30 seconds
-				ui.PrintInfo("Connection error: %v, retrying...", err)
+				out.PrintInfo("Connection error: %v, retrying...", err)
 			}
 		}
 
 		if attempt < maxAttempts {
-			time.Sleep(retryDelay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDelay):
+			}
 		}
 	}
 
+	if lastErr != nil {
+		return fmt.Errorf("site did not respond with 200 after %d attempts (5 minutes): %w", maxAttempts, lastErr)
+	}
 	return fmt.Errorf("site did not respond with 200 after %d attempts (5 minutes)", maxAttempts)
 }
 
+// waitForDomainsReady waits for custom domains to resolve, serve a valid certificate and respond
+// Custom domains depend on the user's DNS, so failures are reported but do not fail the deploy
+func waitForDomainsReady(ctx context.Context, out *ui.Output, domains []string, resolvers []string) []*dns.DomainStatus {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	out.Blank()
+	out.PrintInfo("Checking custom domains...")
+
+	resolver := dns.NewResolver(resolvers)
+	maxAttempts := 24 // 24 attempts * 5 seconds = 2 minutes
+	retryDelay := 5 * time.Second
+
+	statuses := make([]*dns.DomainStatus, len(domains))
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		ready := true
+		for i, domain := range domains {
+			if statuses[i] != nil && statuses[i].Ready() {
+				continue
+			}
+			statuses[i] = resolver.CheckDomain(ctx, domain)
+			if !statuses[i].Ready() {
+				ready = false
+			}
+		}
+
+		if ready || ctx.Err() != nil {
+			break
+		}
+		if attempt < maxAttempts {
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+
+	for _, status := range statuses {
+		printDomainStatus(out, status)
+	}
+
+	return statuses
+}
+
+// printDomainStatus prints the readiness of a single domain
+func printDomainStatus(out *ui.Output, status *dns.DomainStatus) {
+	if status.Ready() {
+		out.PrintSuccess("%s is ready", status.Domain)
+		return
+	}
+
+	switch {
+	case !status.DNS:
+		out.PrintWarning("%s: DNS not resolving", status.Domain)
+	case !status.TLS:
+		out.PrintWarning("%s: DNS resolves, TLS certificate not ready", status.Domain)
+	default:
+		out.PrintWarning("%s: not responding (%v)", status.Domain, status.Err)
+	}
+}
+
 // formatStatus returns a human-readable status
 func formatStatus(status string) string {
 	switch status {
@@ -493,6 +1001,25 @@ func formatStatus(status string) string {
 
 func init() {
 	deployCmd.Flags().StringVarP(&deploySiteName, "name", "n", "", "Site name (default: project directory name)")
-
+	deployCmd.Flags().StringVar(&deployEnvironment, "env", "", "Deploy to an environment of the project (e.g. staging, as <name>-staging with its environments.staging settings)")
+	deployCmd.Flags().BoolVar(&deployAll, "all", false, "Deploy all sites in subdirectories of the current directory")
+	deployCmd.Flags().BoolVar(&deployCancelOnInterrupt, "cancel-on-interrupt", false, "Cancel the remote deployment when interrupted with Ctrl-C")
+	deployCmd.Flags().BoolVar(&deployRandomSuffix, "random-suffix", false, "Append a random suffix to the subdomain if [name].lightspeed.ee is taken")
+	deployCmd.Flags().StringSliceVar(&deploySkip, "skip", nil, "Comma-separated steps to skip (build, push, ensure-site, wait-deploy, verify, perf, hooks, open)")
+	deployCmd.Flags().BoolVar(&deploySkipBuild, "skip-build", false, "Skip building the image (push the last local build)")
+	deployCmd.Flags().BoolVar(&deployNoBuild, "no-build", false, "Deploy the tag last published from this project without building or pushing")
+	deployCmd.Flags().StringVar(&deployFrom, "from", "", "Resume from a step, skipping the steps before it")
+	deployCmd.Flags().BoolVar(&showTimings, "timings", false, "Show build, push, wait and DNS times compared with earlier deploys")
+	deployCmd.Flags().StringVar(&buildCacheFrom, "cache-from", "", "Reuse the layers of an image (default with no value: the last published image)")
+	deployCmd.Flags().Lookup("cache-from").NoOptDefVal = cachePublished
+	deployCmd.Flags().StringVar(&buildPlatform, "platform", defaultBuildPlatform, "Comma-separated platforms to build for (e.g. linux/amd64,linux/arm64)")
+	deployCmd.Flags().BoolVar(&noDocker, "no-docker", false, "Build and push the image without Docker (default when docker isn't installed)")
+	deployCmd.Flags().BoolVar(&deployWatch, "watch", false, "After deploying, redeploy with a new tag whenever project files change")
+	deployCmd.Flags().BoolVar(&deployOnCommit, "on-commit", false, "Like --watch, but redeploy only when a git commit is made")
+	deployCmd.Flags().BoolVar(&noDeployLogs, "no-logs", false, "Don't stream the build and deploy logs while waiting for the deployment (they're still shown if it fails)")
+	deployCmd.Flags().BoolVar(&deployDryRun, "dry-run", false, "Show the images, site changes and DNS records a deploy would make, without building or deploying")
+	deployCmd.Flags().IntVarP(&deployParallel, "parallel", "j", 4, "Maximum number of sites to push and deploy concurrently (with --all)")
+
+	supportsJSON(deployCmd)
 	rootCmd.AddCommand(deployCmd)
 }
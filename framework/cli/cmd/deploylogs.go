@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+// failureLogLines is how many of the last log lines a failed deployment shows when none were streamed
+const failureLogLines = 30
+
+// noDeployLogs turns off streaming the build and deploy logs while waiting for a deployment
+var noDeployLogs bool
+
+// deployLogs streams the build and deploy logs of a deployment while waiting for it
+// Each log type is followed from the phase that writes it (build logs from BUILDING, deploy logs
+// from DEPLOYING) until the stream ends or the wait is over. Prefixed output (deploy --all) and
+// quiet output only get the logs of a failed deployment.
+type deployLogs struct {
+	ctx     context.Context
+	out     *ui.Output
+	backend Backend
+	name    string
+	live    bool // Stream logs as they come (else only show them on failure)
+
+	mu      sync.Mutex
+	started map[string]bool // Log types being (or done) streamed
+	logType string          // Log type of the last phase that writes logs
+	printed map[string]int  // Lines streamed of each log type
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// startDeployLogs prepares streaming the logs of a site's deployment
+func startDeployLogs(ctx context.Context, out *ui.Output, backend Backend, name string) *deployLogs {
+	ctx, cancel := context.WithCancel(ctx)
+	return &deployLogs{
+		ctx:     ctx,
+		out:     out,
+		backend: backend,
+		name:    name,
+		live:    !noDeployLogs && !ui.Quiet() && out.Prefix() == "",
+		started: map[string]bool{},
+		printed: map[string]int{},
+		logType: "deploy",
+		cancel:  cancel,
+	}
+}
+
+// update follows the logs of a deployment status's phase, unless they're already streamed
+// Called on every status poll, so a stream that couldn't be opened yet is retried
+func (l *deployLogs) update(status string) {
+	logType := ""
+	switch status {
+	case "BUILDING":
+		logType = "build"
+	case "DEPLOYING":
+		logType = "deploy"
+	default:
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logType = logType
+	if !l.live || l.started[logType] {
+		return
+	}
+	l.started[logType] = true
+	l.wg.Add(1)
+	go l.follow(logType)
+}
+
+// follow prints the lines of one log type as they come
+func (l *deployLogs) follow(logType string) {
+	defer l.wg.Done()
+
+	stream, err := l.backend.StreamLogs(l.ctx, l.name, LogOptions{Type: logType, Follow: true})
+	if err != nil {
+		ui.PrintDebug("No %s logs yet: %v", logType, err)
+		l.mu.Lock()
+		delete(l.started, logType)
+		l.mu.Unlock()
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		l.out.Println(ui.Muted("  │ " + scanner.Text()))
+		l.mu.Lock()
+		l.printed[logType]++
+		l.mu.Unlock()
+	}
+}
+
+// stop ends the log streams
+func (l *deployLogs) stop() {
+	l.cancel()
+	l.wg.Wait()
+}
+
+// showFailure prints the last lines of the logs of the phase a deployment failed in, unless
+// they were streamed, so the error that failed it shows
+func (l *deployLogs) showFailure() {
+	l.stop()
+
+	l.mu.Lock()
+	logType, printed := l.logType, l.printed[l.logType]
+	l.mu.Unlock()
+	if printed > 0 {
+		return
+	}
+
+	// The wait may have ended with the deploy's context, so the logs get their own
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	stream, err := l.backend.StreamLogs(ctx, l.name, LogOptions{Type: logType, Tail: failureLogLines})
+	if err != nil {
+		ui.PrintDebug("Failed to get %s logs: %v", logType, err)
+		return
+	}
+	defer stream.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	l.out.Println("  Last " + logType + " log lines:")
+	for _, line := range lines {
+		l.out.Println(ui.Muted("  │ " + line))
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var deploymentsLimit int
+
+var deploymentsCmd = &cobra.Command{
+	Use:   "deployments [name]",
+	Short: "List a site's recent deployments",
+	Long:  "List the recent deployments of a site, newest first, with the tag and release each rolled out, its phase, how long it took and when it started. Any listed tag can be redeployed with 'lightspeed rollback --tag'.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		list, err := newBackend().ListDeployments(cmd.Context(), siteName, deploymentsLimit)
+		if err != nil {
+			ui.PrintError("Failed to list deployments of '%s': %v", siteName, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if jsonOutput() {
+			printJSON(list)
+			return
+		}
+
+		if len(list.Deployments) == 0 {
+			ui.PrintInfo("No deployments of '%s' yet", siteName)
+			fmt.Println()
+			return
+		}
+		ui.PrintInfo("%d deployment(s) of '%s'", len(list.Deployments), siteName)
+		fmt.Println()
+		for _, d := range list.Deployments {
+			printDeployment(d)
+		}
+		fmt.Println()
+		fmt.Println(ui.Muted("  Redeploy a tag with 'lightspeed rollback --tag <tag>'"))
+		fmt.Println()
+	},
+}
+
+// printDeployment prints a deployment on one line: ID, tag and release, phase, duration and start time
+func printDeployment(d api.SiteDeployment) {
+	tag := d.Tag
+	if tag == "" {
+		tag = "-"
+	}
+	if d.Release != "" {
+		tag += " (" + d.Release + ")"
+	}
+	duration := "-"
+	if d.DurationMs > 0 {
+		duration = formatMs(d.DurationMs)
+	}
+
+	line := fmt.Sprintf("  %-36s  %-32s %-12s %-8s %s", d.ID, tag, deploymentPhase(d.Phase), duration, ui.Muted(d.CreatedAt))
+	if d.Active {
+		line += " (current)"
+	}
+	fmt.Println(line)
+}
+
+// deploymentPhase returns a human-readable phase of a past or current deployment
+// Unlike formatStatus, a superseded deployment is one that was replaced, not a redeploy
+func deploymentPhase(phase string) string {
+	if phase == "SUPERSEDED" {
+		return "Superseded"
+	}
+	return formatStatus(phase)
+}
+
+func init() {
+	deploymentsCmd.Flags().IntVarP(&deploymentsLimit, "limit", "l", 0, "Number of deployments to show (default: 20, at most 100)")
+	supportsJSON(deploymentsCmd)
+	rootCmd.AddCommand(deploymentsCmd)
+}
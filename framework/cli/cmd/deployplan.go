@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var deployDryRun bool
+
+// planTag returns the tag a deploy would build without allocating one
+// Build numbers and dated tags are handed out by the operator, so for those only the strategy is shown
+func planTag(ctx context.Context, dir, siteName string) (string, error) {
+	if publishTag == "" {
+		if siteInfo, err := loadSiteInfo(dir); err == nil && siteInfo != nil {
+			switch siteInfo.TagStrategy {
+			case tagStrategyBuild:
+				return "<next build number>", nil
+			case tagStrategyDate:
+				return "<next dated tag>", nil
+			}
+		}
+	}
+	return resolveTag(ctx, dir, siteName, publishTag)
+}
+
+// printDeployPlan prints what a deploy would do: the images it would build and push, the changes
+// it would make to the site, and the steps it would run
+// Only reads from the operator, and never runs Docker
+func printDeployPlan(ctx context.Context, d *deployState, steps *pipeline) error {
+	backend := d.Backend
+	site := d.Site
+
+	ui.PrintInfo("Dry run: nothing is built, pushed or deployed")
+	fmt.Println()
+
+	// Image
+	fmt.Println("  Image")
+	switch {
+	case !steps.Runs("build") && !steps.Runs("push"):
+		fmt.Printf("    deploy %s %s\n", site.Tag, ui.Muted("(already in the registry)"))
+	case !steps.Runs("build"):
+		fmt.Printf("    push %s %s\n", strings.Join(d.Images, ", "), ui.Muted("(built earlier)"))
+	default:
+		mode := "with Docker"
+		if d.Daemonless {
+			mode = "without Docker"
+		} else if d.MultiPlatform {
+			platforms, _ := buildPlatforms()
+			mode = "with Docker for " + strings.Join(platforms, ", ")
+		}
+		fmt.Printf("    build %s %s\n", d.Images[0], ui.Muted("("+mode+")"))
+		for _, image := range d.Images[1:] {
+			fmt.Printf("    tag   %s\n", image)
+		}
+	}
+	fmt.Println()
+
+	exists, err := backend.SiteExists(ctx, site.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check site: %w", err)
+	}
+	if exists {
+		if err := planSiteUpdate(ctx, backend, site); err != nil {
+			return err
+		}
+	} else {
+		planSiteCreate(site)
+	}
+	if steps.Runs("ensure-site") {
+		if err := planOperatorRequest(ctx, backend, site, exists); err != nil {
+			return err
+		}
+	}
+
+	// Settings synced after the site is ensured
+	var syncs []string
+	if _, err := os.Stat(filepath.Join(d.Dir, checksFile)); err == nil {
+		if checks, err := loadChecks(filepath.Join(d.Dir, checksFile)); err == nil {
+			syncs = append(syncs, fmt.Sprintf("synthetic checks: %d from %s", len(checks), checksFile))
+		} else {
+			syncs = append(syncs, fmt.Sprintf("synthetic checks: %s is invalid (%v)", checksFile, err))
+		}
+	}
+	if d.Edge != nil {
+		if d.Edge.Enabled {
+			syncs = append(syncs, "edge mode: on"+planEdgeRules(d.Edge))
+		} else {
+			syncs = append(syncs, "edge mode: off")
+		}
+	}
+	if d.Firewall != nil {
+		syncs = append(syncs, fmt.Sprintf("IP rules: %d allowed, %d denied", len(d.Firewall.Allow), len(d.Firewall.Deny)))
+	}
+	if d.Cron != nil {
+		syncs = append(syncs, fmt.Sprintf("cron jobs: %d", len(d.Cron)))
+	}
+	if len(syncs) > 0 && steps.Runs("ensure-site") {
+		fmt.Println("  Settings")
+		for _, sync := range syncs {
+			fmt.Printf("    %s\n", sync)
+		}
+		fmt.Println()
+	}
+
+	// Steps
+	fmt.Println("  Steps")
+	for _, name := range steps.Names() {
+		if steps.Runs(name) {
+			fmt.Printf("    %s\n", name)
+		} else {
+			fmt.Println(ui.Muted(fmt.Sprintf("    %-12s skipped", name)))
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+// planSiteCreate prints the site a deploy would create and the DNS records the operator would add
+func planSiteCreate(site api.Site) {
+	fmt.Printf("  Site '%s' doesn't exist and would be created\n", site.Name)
+	if site.Template != "" {
+		fmt.Printf("    + template  %s\n", site.Template)
+	}
+	if site.Region != "" {
+		fmt.Printf("    + region    %s\n", site.Region)
+	}
+	fmt.Printf("    + image     %s:%s\n", site.Image, site.Tag)
+	if site.Service != nil {
+		fmt.Printf("    + service   %s\n", planService(site.Service))
+	}
+	if len(site.Ingress) > 0 {
+		fmt.Println("    + routing")
+		planIngressRules("      ", site.Ingress)
+	}
+	fmt.Println()
+
+	baseDomain := site.BaseDomain
+	if baseDomain == "" {
+		baseDomain = "lightspeed.ee"
+	}
+	domain := site.Name + "." + baseDomain
+	if site.RandomSuffix {
+		domain = site.Name + "-<random>." + baseDomain
+	}
+	fmt.Println("  DNS")
+	fmt.Printf("    + CNAME %s %s\n", domain, ui.Muted("-> site ingress"))
+	for _, custom := range site.Domains {
+		fmt.Printf("    + CNAME %s %s\n", custom, ui.Muted("-> site ingress (if the operator manages its zone)"))
+	}
+	fmt.Println()
+}
+
+// planSiteUpdate prints the changes a deploy would make to an existing site
+// Domains are only set when a site is created, so custom domains it lacks are only reported
+func planSiteUpdate(ctx context.Context, backend Backend, site api.Site) error {
+	status, err := backend.GetSiteStatus(ctx, site.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get site: %w", err)
+	}
+
+	fmt.Printf("  Site '%s' exists and would be redeployed\n", site.Name)
+	changes := 0
+	if status.Tag != site.Tag {
+		fmt.Printf("    ~ image     %s -> %s\n", planValue(status.Tag), site.Tag)
+		changes++
+	}
+	if site.Service != nil {
+		wanted := planService(site.Service)
+		switch {
+		case status.Service == nil:
+			fmt.Printf("    + service   %s\n", wanted)
+			changes++
+		case serviceChanged(status.Service, site.Service):
+			fmt.Printf("    ~ service   %s -> %s\n", planService(status.Service), wanted)
+			changes++
+		}
+	}
+	if len(site.Ingress) > 0 {
+		current, err := backend.GetIngress(ctx, site.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get routing rules: %w", err)
+		}
+		if removed, added := diffIngress(site.Name, current.Rules, site.Ingress); len(removed) > 0 || len(added) > 0 {
+			fmt.Println("    ~ routing")
+			for _, rule := range removed {
+				fmt.Printf("      - %-20s -> %s\n", rule.Path, rule.Component)
+			}
+			for _, rule := range added {
+				fmt.Printf("      + %-20s -> %s\n", rule.Path, rule.Component)
+			}
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Println(ui.Muted("    No spec changes (the same tag is redeployed)"))
+	}
+	fmt.Println()
+
+	domains, err := backend.ListDomains(ctx, site.Name)
+	if err != nil {
+		return fmt.Errorf("failed to list domains: %w", err)
+	}
+	attached := map[string]bool{}
+	for _, domain := range domains.Domains {
+		attached[strings.ToLower(domain.Domain)] = true
+	}
+	fmt.Println("  DNS")
+	fmt.Println(ui.Muted("    No changes"))
+	for _, domain := range site.Domains {
+		if !attached[strings.ToLower(domain)] {
+			fmt.Printf("    %s isn't attached to the site; deploys don't add domains (run 'lightspeed domains add %s')\n", domain, domain)
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+// planOperatorRequest prints the request a deploy would make to create or redeploy the site and,
+// from the operator's dry run of it, the changes to the site's app spec
+// Operators without dry runs are only listed the request
+func planOperatorRequest(ctx context.Context, backend Backend, site api.Site, exists bool) error {
+	var plan *api.DryRun
+	var err error
+	request := "POST /sites"
+	if exists {
+		request = "POST /sites/" + site.Name + "/deploy"
+		plan, err = backend.PlanDeploy(ctx, site.Name, api.DeployRequest{Tag: site.Tag, Commit: site.Commit, Service: site.Service, Ingress: site.Ingress})
+	} else {
+		plan, err = backend.PlanCreateSite(ctx, site)
+	}
+
+	fmt.Println("  Operator")
+	if errors.Is(err, errDryRunUnsupported) {
+		fmt.Printf("    %s %s\n", request, ui.Muted("(the operator has no dry runs, so its spec changes aren't known)"))
+		fmt.Println()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("the operator would reject the deploy: %w", err)
+	}
+
+	switch plan.Action {
+	case "create":
+		fmt.Printf("    %s %s\n", request, ui.Muted("(creates the app, which deploys it)"))
+	case "update":
+		fmt.Printf("    %s %s\n", request, ui.Muted("(updates the app spec, which deploys it)"))
+	default:
+		fmt.Printf("    %s %s\n", request, ui.Muted("(redeploys the app as it is)"))
+	}
+	if plan.Domain != "" {
+		fmt.Printf("    domain %s\n", plan.Domain)
+	}
+	printSpecChanges("      ", plan)
+	for _, warning := range plan.Warnings {
+		fmt.Println(ui.Muted("    note: " + warning))
+	}
+	fmt.Println()
+	return nil
+}
+
+// diffIngress compares a site's routing rules with the rules a deploy would apply
+// Rules from site.properties name components by alias ("site", service names), so they're
+// expanded to the component names the operator returns; / goes to the site unless a rule routes it
+func diffIngress(siteName string, current, wanted []api.IngressRule) (removed, added []api.IngressRule) {
+	expanded := make([]api.IngressRule, 0, len(wanted)+1)
+	routesRoot := false
+	for _, rule := range wanted {
+		switch {
+		case rule.Component == "site":
+			rule.Component = siteName
+		case rule.Component != siteName && !strings.HasPrefix(rule.Component, siteName+"-"):
+			rule.Component = siteName + "-" + rule.Component
+		}
+		routesRoot = routesRoot || rule.Path == "/"
+		expanded = append(expanded, rule)
+	}
+	if !routesRoot {
+		expanded = append(expanded, api.IngressRule{Path: "/", Component: siteName})
+	}
+
+	for _, rule := range current {
+		if !containsRule(expanded, rule) {
+			removed = append(removed, rule)
+		}
+	}
+	for _, rule := range expanded {
+		if !containsRule(current, rule) {
+			added = append(added, rule)
+		}
+	}
+	return removed, added
+}
+
+// containsRule checks if a list has a rule
+func containsRule(rules []api.IngressRule, rule api.IngressRule) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceChanged checks if deploying a service would change the one a site runs
+// Fields site.properties leaves to the operator's defaults aren't compared
+func serviceChanged(current, wanted *api.SiteService) bool {
+	return current.Image != wanted.Image ||
+		(wanted.Name != "" && current.Name != wanted.Name) ||
+		(wanted.Tag != "" && current.Tag != wanted.Tag) ||
+		(wanted.Port != 0 && current.Port != wanted.Port) ||
+		(wanted.Path != "" && current.Path != wanted.Path) ||
+		(wanted.Instances != 0 && current.Instances != wanted.Instances) ||
+		(wanted.Size != "" && current.Size != wanted.Size)
+}
+
+// planIngressRules prints routing rules with an indent
+func planIngressRules(indent string, rules []api.IngressRule) {
+	for _, rule := range rules {
+		fmt.Printf("%s%-20s -> %s\n", indent, rule.Path, rule.Component)
+	}
+}
+
+// planService describes a service as deployed, leaving operator defaults out
+func planService(service *api.SiteService) string {
+	description := service.Image
+	if service.Tag != "" {
+		description += ":" + service.Tag
+	}
+	if service.Name != "" {
+		description = service.Name + " (" + description + ")"
+	}
+	var options []string
+	if service.Path != "" {
+		options = append(options, "path "+service.Path)
+	}
+	if service.Port != 0 {
+		options = append(options, fmt.Sprintf("port %d", service.Port))
+	}
+	if service.Instances != 0 {
+		options = append(options, fmt.Sprintf("%d instance(s)", service.Instances))
+	}
+	if service.Size != "" {
+		options = append(options, "size "+service.Size)
+	}
+	if len(options) > 0 {
+		description += ", " + strings.Join(options, ", ")
+	}
+	return description
+}
+
+// planEdgeRules describes the access rules of edge mode settings
+func planEdgeRules(edge *api.SiteEdge) string {
+	var rules []string
+	if user, _, ok := strings.Cut(edge.Auth, ":"); ok {
+		rules = append(rules, "basic auth ("+user+")")
+	}
+	if edge.Maintenance {
+		rules = append(rules, "maintenance page")
+	}
+	if edge.Log {
+		rules = append(rules, "request logs")
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(rules, ", ") + ")"
+}
+
+// planValue shows an empty value as none
+func planValue(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	deployWatch    bool
+	deployOnCommit bool
+)
+
+// commitInterval is how often the git HEAD is checked for new commits with --on-commit
+const commitInterval = 2 * time.Second
+
+// watchDeploys redeploys the site whenever the project changes, or with --on-commit whenever
+// a commit is made, until interrupted. Every redeploy builds and pushes a new tag and waits
+// for the site; a failed redeploy is reported and the next change tries again.
+func watchDeploys(ctx context.Context, d *deployState, skip []string) {
+	redeploy := func(reason string) {
+		fmt.Printf("  %s %s\n", ui.Muted(time.Now().Format("15:04:05")), reason)
+		fmt.Println()
+
+		tag, err := watchTag(ctx, d.Dir, d.Site.Name)
+		if err != nil {
+			if interrupted(ctx) {
+				return
+			}
+			ui.PrintError("Failed to determine tag: %v", err)
+			printErrorHint(err)
+			fmt.Println()
+			return
+		}
+
+		// Steps set state for the steps after them, so every redeploy starts from a clean slate
+		registryBase := fmt.Sprintf("%s/%s", d.Registry, d.Site.Name)
+		d.Site.Tag = tag
+		d.Images = []string{registryBase + ":" + tag, registryBase + ":latest"}
+		d.Built, d.Pushed = nil, false
+		d.Created, d.Domain, d.URL = false, "", ""
+
+		steps := newPipeline(deploySteps...)
+		steps.Skip(skip...)
+		start := time.Now()
+		if err := steps.Run(ctx, d); err != nil {
+			var stepErr *pipelineError
+			errors.As(err, &stepErr)
+			if interrupted(ctx) {
+				if stepErr.Step == "wait-deploy" || stepErr.Step == "verify" {
+					handleDeployInterrupt(d.Site.Name)
+				}
+				return
+			}
+			ui.PrintError("Redeploy of %s failed at %s: %v", tag, stepErr.Step, stepErr.Err)
+			printErrorHint(stepErr.Err)
+			fmt.Println()
+			ui.PrintInfo("Waiting for the next change to try again...")
+			fmt.Println()
+			return
+		}
+
+		ui.PrintSuccess("Deployed %s in %v", tag, time.Since(start).Round(time.Second))
+		if d.URL != "" {
+			fmt.Printf("  %s\n", d.URL)
+		}
+		fmt.Println()
+	}
+
+	if deployOnCommit {
+		ui.PrintInfo("Watching for commits (Ctrl-C to stop watching)...")
+		fmt.Println()
+		watchCommits(ctx, d.Dir, func(commit string) {
+			redeploy("commit " + commit)
+		})
+	} else {
+		ui.PrintInfo("Watching for changes (Ctrl-C to stop watching)...")
+		fmt.Println()
+		watchProject(ctx, d.Dir, func(changed []string) {
+			redeploy(changeSummary(changed))
+		})
+	}
+
+	fmt.Println()
+	ui.PrintInfo("Stopped watching")
+	fmt.Println()
+}
+
+// watchTag allocates the tag of a watch mode redeploy from the operator: the site's next dated
+// tag with tag.strategy=date, otherwise its next build number
+// Git based tags would repeat for changes between commits, so they aren't used
+func watchTag(ctx context.Context, dir, siteName string) (string, error) {
+	strategy := tagStrategyBuild
+	if siteInfo, err := loadSiteInfo(dir); err == nil && siteInfo != nil && siteInfo.TagStrategy == tagStrategyDate {
+		strategy = tagStrategyDate
+	}
+	return allocateTag(ctx, siteName, strategy)
+}
+
+// watchCommits polls a git repository until ctx is done, calling onCommit with the short
+// SHA of every new HEAD commit
+func watchCommits(ctx context.Context, dir string, onCommit func(commit string)) {
+	previous, _ := headCommit(ctx, dir)
+	ticker := time.NewTicker(commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		commit, err := headCommit(ctx, dir)
+		if err != nil || commit == previous {
+			continue
+		}
+		previous = commit
+		onCommit(commit)
+	}
+}
+
+// headCommit returns the short SHA of a repository's HEAD commit
+func headCommit(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	destroyForce  bool
+	destroyImage  bool
+	destroyDNS    bool
+	destroyDryRun bool
+)
+
+var destroyCmd = &cobra.Command{
+	Use:   "destroy [name]",
+	Short: "Delete a deployed site",
+	Long:  "Delete a site's app, and optionally its registry repository and DNS records. Asks for the site name to confirm unless --force is given.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		if destroyDryRun {
+			plan, err := backend.PlanDeleteSite(ctx, siteName, DeleteOptions{Image: destroyImage, DNS: destroyDNS})
+			if err != nil {
+				ui.PrintError("Failed to plan deleting site '%s': %v", siteName, err)
+				printErrorHint(err)
+				os.Exit(1)
+			}
+			ui.PrintInfo("Dry run: nothing is deleted")
+			fmt.Println()
+			fmt.Printf("  DELETE /sites/%s would delete\n", siteName)
+			for _, deleted := range plan.Deletes {
+				fmt.Printf("    - %s\n", deleted)
+			}
+			fmt.Println()
+			return
+		}
+
+		status, err := backend.GetSiteStatus(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to get site '%s': %v", siteName, err)
+			os.Exit(1)
+		}
+
+		ui.PrintKeyValue("Site", siteName)
+		if status.Domain != "" {
+			ui.PrintKeyValue("Domain", status.Domain)
+		}
+		if destroyImage {
+			ui.PrintKeyValue("Image", "repository will be deleted")
+		}
+		if destroyDNS {
+			ui.PrintKeyValue("DNS", "records will be deleted")
+		}
+		fmt.Println()
+
+		if !destroyForce && !confirmDestroy(siteName) {
+			ui.PrintInfo("Aborted")
+			fmt.Println()
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Deleting site '%s'...", siteName)
+		err = backend.DeleteSite(ctx, siteName, DeleteOptions{
+			Image: destroyImage,
+			DNS:   destroyDNS,
+		})
+		if err != nil {
+			ui.PrintError("Failed to delete site: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Deleted site '%s'", siteName)
+		fmt.Println()
+	},
+}
+
+// confirmDestroy asks the user to type the site name to confirm deleting it
+func confirmDestroy(siteName string) bool {
+	if ciRefusesPrompt() {
+		return false
+	}
+	ui.PrintWarning("This permanently deletes the site")
+	fmt.Printf("Type '%s' to confirm: ", siteName)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	return strings.TrimSpace(answer) == siteName
+}
+
+func init() {
+	destroyCmd.Flags().BoolVarP(&destroyForce, "force", "f", false, "Delete without asking for confirmation")
+	destroyCmd.Flags().BoolVar(&destroyImage, "image", false, "Also delete the site's registry repository")
+	destroyCmd.Flags().BoolVar(&destroyDNS, "dns", false, "Also delete the DNS records on the site's domain")
+	destroyCmd.Flags().BoolVar(&destroyDryRun, "dry-run", false, "Show what would be deleted without deleting it")
+
+	rootCmd.AddCommand(destroyCmd)
+}
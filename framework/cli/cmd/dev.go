@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	devLogsTail   int
+	devLogsFollow bool
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Work with the local development container",
+}
+
+var devLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Stream logs of the development container",
+	Long:  "Stream the nginx and PHP logs of the project's development container (started with 'lightspeed start'), including PHP errors and access logs.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		containerName := devContainerName(dir)
+		if !containerExists(containerName) {
+			ui.PrintError("No development server found for this project")
+			ui.PrintInfo("Start one with: lightspeed start")
+			os.Exit(1)
+		}
+
+		dockerArgs := []string{"logs"}
+		if devLogsTail > 0 {
+			dockerArgs = append(dockerArgs, "--tail", strconv.Itoa(devLogsTail))
+		}
+		if devLogsFollow {
+			dockerArgs = append(dockerArgs, "-f")
+		}
+		dockerArgs = append(dockerArgs, containerName)
+
+		// Ctrl-C stops docker logs along with the CLI; that's the normal way to stop following
+		dockerCmd := dockerCommand(cmd.Context(), dockerArgs...)
+		dockerCmd.Stdout = os.Stdout
+		dockerCmd.Stderr = os.Stderr
+		if err := dockerCmd.Run(); err != nil && cmd.Context().Err() == nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			ui.PrintError("Failed to run docker logs: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	devLogsCmd.Flags().IntVar(&devLogsTail, "tail", 100, "Number of existing lines to show (0 for all)")
+	devLogsCmd.Flags().BoolVarP(&devLogsFollow, "follow", "f", true, "Keep streaming new log lines")
+
+	devCmd.AddCommand(devLogsCmd)
+	rootCmd.AddCommand(devCmd)
+}
@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// devServiceLabel marks the service containers of a development server with its container name
+const devServiceLabel = "lightspeed.dev"
+
+// devDatabaseLabel marks the database container started with --with-db
+const devDatabaseLabel = "lightspeed.with-db"
+
+// devServicePassword is the password of development databases (local only)
+const devServicePassword = "lightspeed"
+
+// devSeedDir holds the SQL files that seed a project's development database
+const devSeedDir = "db"
+
+// devService is an auxiliary container (database, cache) started next to the development server
+// The PHP container reaches it on the shared network by its name, e.g. mysql:3306
+type devService struct {
+	Name   string   // Network alias and container name suffix
+	Image  string   // Image with version
+	Env    []string // Environment of the service container
+	PHPEnv []string // Environment injected into the PHP container
+	Data   string   // Data directory kept in a named volume across restarts
+	Init   string   // Directory the image runs seed scripts from on first start
+	Seed   string   // Project directory mounted as Init (empty to not seed)
+	WithDB bool     // Started with --with-db rather than from site.properties
+}
+
+// devServiceType describes a known service
+type devServiceType struct {
+	image  string
+	env    []string
+	phpEnv []string
+	data   string
+	init   string
+}
+
+// devServiceTypes are the services that get credentials and connection variables
+// Other names run the image of that name and only get NAME_HOST
+var devServiceTypes = map[string]devServiceType{
+	"mysql": {
+		image:  "mysql",
+		env:    []string{"MYSQL_ROOT_PASSWORD=" + devServicePassword, "MYSQL_DATABASE=lightspeed", "MYSQL_USER=lightspeed", "MYSQL_PASSWORD=" + devServicePassword},
+		phpEnv: []string{"DB_CONNECTION=mysql", "DB_HOST=mysql", "DB_PORT=3306", "DB_DATABASE=lightspeed", "DB_USERNAME=lightspeed", "DB_PASSWORD=" + devServicePassword},
+		data:   "/var/lib/mysql",
+		init:   "/docker-entrypoint-initdb.d",
+	},
+	"mariadb": {
+		image:  "mariadb",
+		env:    []string{"MARIADB_ROOT_PASSWORD=" + devServicePassword, "MARIADB_DATABASE=lightspeed", "MARIADB_USER=lightspeed", "MARIADB_PASSWORD=" + devServicePassword},
+		phpEnv: []string{"DB_CONNECTION=mysql", "DB_HOST=mariadb", "DB_PORT=3306", "DB_DATABASE=lightspeed", "DB_USERNAME=lightspeed", "DB_PASSWORD=" + devServicePassword},
+		data:   "/var/lib/mysql",
+		init:   "/docker-entrypoint-initdb.d",
+	},
+	"postgres": {
+		image:  "postgres",
+		env:    []string{"POSTGRES_DB=lightspeed", "POSTGRES_USER=lightspeed", "POSTGRES_PASSWORD=" + devServicePassword},
+		phpEnv: []string{"DB_CONNECTION=pgsql", "DB_HOST=postgres", "DB_PORT=5432", "DB_DATABASE=lightspeed", "DB_USERNAME=lightspeed", "DB_PASSWORD=" + devServicePassword},
+		data:   "/var/lib/postgresql/data",
+		init:   "/docker-entrypoint-initdb.d",
+	},
+	"redis": {
+		image:  "redis",
+		phpEnv: []string{"REDIS_HOST=redis", "REDIS_PORT=6379"},
+		data:   "/data",
+	},
+	"memcached": {
+		image:  "memcached",
+		phpEnv: []string{"MEMCACHED_HOST=memcached", "MEMCACHED_PORT=11211"},
+	},
+}
+
+// getDevServices reads the services property of site.properties (e.g. services=mysql:8,redis:7)
+func getDevServices(props properties.Properties) ([]devService, error) {
+	var services []devService
+	seen := make(map[string]bool)
+	for _, entry := range props.GetList("services") {
+		service, err := parseDevService(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[service.Name] {
+			return nil, fmt.Errorf("service %s is listed twice", service.Name)
+		}
+		seen[service.Name] = true
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// parseDevService parses a service as name:version (latest if the version is left out)
+func parseDevService(entry string) (devService, error) {
+	name, version, _ := strings.Cut(strings.TrimSpace(entry), ":")
+	name = strings.ToLower(name)
+	if version == "" {
+		version = "latest"
+	}
+	if sanitizeContainerName(name) != name || name == "" {
+		return devService{}, fmt.Errorf("invalid service %q (use name:version, e.g. mysql:8)", entry)
+	}
+
+	service := devService{Name: name, Image: name + ":" + version}
+	if known, ok := devServiceTypes[name]; ok {
+		service.Image = known.image + ":" + version
+		service.Env = known.env
+		service.PHPEnv = known.phpEnv
+		service.Data = known.data
+		service.Init = known.init
+	} else {
+		envName := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+		service.PHPEnv = []string{envName + "_HOST=" + name}
+	}
+	return service, nil
+}
+
+// loadDevServices reads the services of a project's site.properties
+func loadDevServices(dir string) ([]devService, error) {
+	propsPath := properties.ProjectFile(dir)
+	if !properties.FileExists(propsPath) {
+		return nil, nil
+	}
+	props, err := properties.ParseFile(propsPath)
+	if err != nil {
+		return nil, err
+	}
+	return getDevServices(props)
+}
+
+// projectServices returns the services of a project's development server: those in
+// site.properties, plus the database of --with-db (e.g. mysql or mariadb:11) if given
+// Databases are seeded from the project's db directory if it exists
+func projectServices(dir, withDB string) ([]devService, error) {
+	services, err := loadDevServices(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid services in site.properties: %w", err)
+	}
+
+	if withDB != "" {
+		db, err := parseDevService(withDB)
+		if err != nil {
+			return nil, err
+		}
+		if db.Name != "mysql" && db.Name != "mariadb" {
+			return nil, fmt.Errorf("--with-db must be mysql or mariadb, not %s", db.Name)
+		}
+		db.WithDB = true
+
+		added := false
+		for i, service := range services {
+			switch {
+			case service.Name == db.Name:
+				services[i].WithDB = true
+				added = true
+			case devServiceEnv(service, "DB_HOST"):
+				return nil, fmt.Errorf("site.properties already starts a database (%s)", service.Name)
+			}
+		}
+		if !added {
+			services = append(services, db)
+		}
+	}
+
+	seed := filepath.Join(dir, devSeedDir)
+	if info, err := os.Stat(seed); err == nil && info.IsDir() {
+		for i := range services {
+			if services[i].Init != "" {
+				services[i].Seed = seed
+			}
+		}
+	}
+	return services, nil
+}
+
+// startProjectServices starts the services of a project's development server
+// Returns the network and environment of its PHP container (empty without services)
+func startProjectServices(dir, containerName, withDB string) (string, []string, error) {
+	services, err := projectServices(dir, withDB)
+	if err != nil {
+		return "", nil, err
+	}
+	return startDevServices(containerName, services)
+}
+
+// devServiceEnv checks if a service gives the PHP container a variable
+func devServiceEnv(service devService, key string) bool {
+	for _, value := range service.PHPEnv {
+		if strings.HasPrefix(value, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// devDatabase returns the database a development server was started with --with-db
+// as name:version (empty if it wasn't)
+func devDatabase(containerName string) string {
+	output, err := dockerCommand(context.Background(), "ps", "-a",
+		"--filter", "label="+devServiceLabel+"="+containerName,
+		"--filter", "label="+devDatabaseLabel,
+		"--format", "{{.Image}}").Output()
+	if err != nil {
+		return ""
+	}
+	image, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimSpace(image)
+}
+
+// devNetworkName returns the network a development server shares with its services
+func devNetworkName(containerName string) string {
+	return containerName + "-net"
+}
+
+// startDevServices starts the services of a development server on its network
+// Services that are already running are left as they are.
+// Returns the network to run the PHP container on and the environment to give it
+func startDevServices(containerName string, services []devService) (string, []string, error) {
+	if len(services) == 0 {
+		return "", nil, nil
+	}
+
+	network := devNetworkName(containerName)
+	if dockerCommand(context.Background(), "network", "inspect", network).Run() != nil {
+		if output, err := dockerCommand(context.Background(), "network", "create", "--label", devServiceLabel+"="+containerName, network).CombinedOutput(); err != nil {
+			return "", nil, fmt.Errorf("failed to create network %s: %s", network, strings.TrimSpace(string(output)))
+		}
+	}
+
+	var env []string
+	for _, service := range services {
+		env = append(env, service.PHPEnv...)
+
+		name := containerName + "-" + service.Name
+		if isContainerRunning(name) {
+			continue
+		}
+		stopContainer(name)
+
+		dockerArgs := []string{
+			"run",
+			"-d",
+			"--name", name,
+			"--network", network,
+			"--network-alias", service.Name,
+			"--label", devServiceLabel + "=" + containerName,
+		}
+		if service.WithDB {
+			dockerArgs = append(dockerArgs, "--label", devDatabaseLabel)
+		}
+		for _, value := range service.Env {
+			dockerArgs = append(dockerArgs, "-e", value)
+		}
+		if service.Data != "" {
+			dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s-data:%s", name, service.Data))
+		}
+		// Seed scripts only run while the data volume is empty
+		if service.Seed != "" {
+			dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s:%s:ro", service.Seed, service.Init))
+		}
+		dockerArgs = append(dockerArgs, service.Image)
+
+		if output, err := dockerCommand(context.Background(), dockerArgs...).CombinedOutput(); err != nil {
+			return "", nil, fmt.Errorf("failed to start %s: %s", service.Image, strings.TrimSpace(string(output)))
+		}
+	}
+	return network, env, nil
+}
+
+// stopDevServices removes the service containers and network of a development server
+// Data volumes are kept, so databases survive a restart
+func stopDevServices(containerName string) {
+	output, err := dockerCommand(context.Background(), "ps", "-aq", "--filter", "label="+devServiceLabel+"="+containerName).Output()
+	if err == nil {
+		if ids := strings.Fields(string(output)); len(ids) > 0 {
+			dockerCommand(context.Background(), append([]string{"rm", "-f"}, ids...)...).Run()
+		}
+	}
+	dockerCommand(context.Background(), "network", "rm", devNetworkName(containerName)).Run()
+}
+
+// devServiceNames lists services as name:version for output
+func devServiceNames(services []devService) string {
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		_, version, _ := strings.Cut(service.Image, ":")
+		names = append(names, service.Name+":"+version)
+	}
+	return strings.Join(names, ", ")
+}
+
+// printDevDatabase prints the connection details of the database started with --with-db
+func printDevDatabase(services []devService) {
+	for _, service := range services {
+		if !service.WithDB {
+			continue
+		}
+		ui.PrintKeyValue("  Database", fmt.Sprintf("%s:3306, user lightspeed, password %s, database lightspeed", service.Name, devServicePassword))
+		if service.Seed != "" {
+			ui.PrintKeyValue("  Seed", service.Seed+" (on first start)")
+		}
+	}
+}
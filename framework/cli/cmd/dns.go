@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	dnsSiteName      string
+	dnsPriority      int
+	emailProvider    string
+	emailDKIMSel     string
+	emailDKIMKey     string
+	emailDKIMTokens  []string
+	emailRegion      string
+	emailDMARCReport string
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Manage DNS records of a site's domain",
+}
+
+var dnsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List DNS records in the site's domain",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dnsSite()
+
+		list, err := newBackend().ListDNSRecords(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to list DNS records: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("DNS records for %s", list.Domain)
+		fmt.Println()
+		for _, record := range list.Records {
+			printDNSRecord(record)
+		}
+		fmt.Println()
+	},
+}
+
+var dnsAddCmd = &cobra.Command{
+	Use:   "add <type> <name> <content>",
+	Short: "Add a DNS record in the site's domain",
+	Long:  "Add an A, AAAA, CNAME, TXT or MX record. The name is relative to the site's domain (e.g. _verify for _verify.mysite.lightspeed.ee).",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dnsSite()
+
+		record, err := newBackend().AddDNSRecord(cmd.Context(), siteName, api.DNSRecord{
+			Type:     strings.ToUpper(args[0]),
+			Name:     args[1],
+			Content:  args[2],
+			Priority: dnsPriority,
+		})
+		if err != nil {
+			ui.PrintError("Failed to add DNS record: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Added DNS record")
+		printDNSRecord(*record)
+		fmt.Println()
+	},
+}
+
+var dnsRemoveCmd = &cobra.Command{
+	Use:     "rm <type> <name> [content]",
+	Aliases: []string{"remove"},
+	Short:   "Remove a DNS record from the site's domain",
+	Long:    "Remove a DNS record by type and name. If several records share the type and name, pass the content of the one to remove.",
+	Args:    cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dnsSite()
+
+		record := api.DNSRecord{Type: strings.ToUpper(args[0]), Name: args[1]}
+		if len(args) > 2 {
+			record.Content = args[2]
+		}
+
+		if err := newBackend().DeleteDNSRecord(cmd.Context(), siteName, record); err != nil {
+			ui.PrintError("Failed to remove DNS record: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Removed %s record %s", record.Type, record.Name)
+		fmt.Println()
+	},
+}
+
+var dnsProxyCmd = &cobra.Command{
+	Use:       "proxy <on|off>",
+	Short:     "Turn Cloudflare CDN proxying of the site's domain on or off",
+	Long:      "Serve the site's domain through Cloudflare's CDN (orange cloud). The operator pins the SSL mode for the domain to Full so requests to the app don't loop or fail certificate checks.",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"on", "off"},
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dnsSite()
+
+		var enabled bool
+		switch strings.ToLower(args[0]) {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			ui.PrintError("Invalid proxy mode '%s': use on or off", args[0])
+			os.Exit(1)
+		}
+
+		proxy, err := newBackend().SetProxy(cmd.Context(), siteName, enabled)
+		if err != nil {
+			ui.PrintError("Failed to set proxy mode: %v", err)
+			os.Exit(1)
+		}
+
+		if proxy.Enabled {
+			ui.PrintSuccess("%s is served through Cloudflare", proxy.Domain)
+			ui.PrintKeyValue("SSL mode", proxy.SSLMode)
+		} else {
+			ui.PrintSuccess("%s is served directly by the app", proxy.Domain)
+		}
+		fmt.Println()
+	},
+}
+
+// dnsSite resolves the site DNS commands apply to
+func dnsSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, dnsSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+// printDNSRecord prints a DNS record on one line
+func printDNSRecord(record api.DNSRecord) {
+	content := record.Content
+	if record.Type == "MX" {
+		content = fmt.Sprintf("%d %s", record.Priority, content)
+	}
+	if record.Proxied {
+		content += " (proxied)"
+	}
+	fmt.Printf("  %-5s %s -> %s\n", record.Type, record.Name, content)
+}
+
+var dnsEmailSetupCmd = &cobra.Command{
+	Use:   "email-setup",
+	Short: "Create SPF, DKIM and DMARC records for sending mail",
+	Long:  "Create the SPF, DKIM and DMARC records a mail provider (postmark or ses) needs to send mail from the site's domain without landing in spam",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := dnsSite()
+
+		ui.PrintInfo("Creating %s email records for '%s'...", emailProvider, siteName)
+		result, err := newBackend().SetupEmail(cmd.Context(), siteName, api.EmailSetup{
+			Provider:     strings.ToLower(emailProvider),
+			DKIMSelector: emailDKIMSel,
+			DKIMKey:      emailDKIMKey,
+			DKIMTokens:   emailDKIMTokens,
+			Region:       emailRegion,
+			DMARCEmail:   emailDMARCReport,
+		})
+		if err != nil {
+			ui.PrintError("Failed to set up email: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Email records created for %s", result.Domain)
+		fmt.Println()
+		for _, record := range result.Records {
+			printDNSRecord(record)
+		}
+		fmt.Println()
+
+		switch strings.ToLower(emailProvider) {
+		case "postmark":
+			ui.PrintInfo("In Postmark, set the sender signature's Return-Path to pm-bounces.%s and verify DKIM", result.Domain)
+		case "ses":
+			ui.PrintInfo("In SES, set the identity's custom MAIL FROM domain to mail.%s", result.Domain)
+		}
+		ui.PrintInfo("DMARC starts in monitoring mode (p=none); tighten it once mail is passing")
+		fmt.Println()
+	},
+}
+
+func init() {
+	dnsCmd.PersistentFlags().StringVarP(&dnsSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	dnsEmailSetupCmd.Flags().StringVarP(&emailProvider, "provider", "p", "", "Mail provider: postmark or ses")
+	dnsEmailSetupCmd.Flags().StringVar(&emailDKIMSel, "dkim-selector", "", "DKIM selector (postmark)")
+	dnsEmailSetupCmd.Flags().StringVar(&emailDKIMKey, "dkim-key", "", "DKIM public key record value (postmark)")
+	dnsEmailSetupCmd.Flags().StringSliceVar(&emailDKIMTokens, "dkim-tokens", nil, "Comma-separated Easy DKIM tokens (ses)")
+	dnsEmailSetupCmd.Flags().StringVar(&emailRegion, "region", "", "SES region (default: us-east-1)")
+	dnsEmailSetupCmd.Flags().StringVar(&emailDMARCReport, "dmarc-email", "", "Address DMARC aggregate reports are sent to")
+	dnsEmailSetupCmd.MarkFlagRequired("provider")
+
+	dnsAddCmd.Flags().IntVar(&dnsPriority, "priority", 10, "MX record priority")
+
+	dnsCmd.AddCommand(dnsListCmd)
+	dnsCmd.AddCommand(dnsAddCmd)
+	dnsCmd.AddCommand(dnsRemoveCmd)
+	dnsCmd.AddCommand(dnsProxyCmd)
+	dnsCmd.AddCommand(dnsEmailSetupCmd)
+	dnsCmd.AddCommand(dnsCheckCmd)
+	rootCmd.AddCommand(dnsCmd)
+}
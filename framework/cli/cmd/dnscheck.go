@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/dns"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// domainCheck is the outcome of one check of a domain
+type domainCheck struct {
+	Name    string // DNS, Cloudflare or TLS
+	Passed  bool
+	Skipped bool
+	Message string
+	Hint    string // What to do about a failed check
+}
+
+var dnsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the site's domains resolve to it and serve a valid certificate",
+	Long:  "Check every domain of the site (and those in site.properties): that it resolves to the site's ingress, by CNAME or a flattened record; that the operator's zone holds its record, for domains it manages; and that it serves a valid TLS certificate. Failed checks come with what to do about them, and make the command exit with a non-zero status.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		siteName := dnsSite()
+
+		list, err := newBackend().ListDomainRecords(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to list domains: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		dir, _ := os.Getwd()
+		var props properties.Properties
+		if propsPath := properties.ProjectFile(dir); properties.FileExists(propsPath) {
+			props, _ = parseProjectFile(propsPath)
+		}
+		resolver := dns.NewResolver(getCheckResolvers(props))
+
+		ui.PrintInfo("Checking the domains of '%s' (resolver: %s)...", siteName, resolver.Describe())
+		fmt.Println()
+
+		failed := 0
+		for _, domain := range list.Domains {
+			if printDomainChecks(domain.Domain, checkDomain(ctx, resolver, domain)) {
+				failed++
+			}
+		}
+		unattached := unattachedDomains(dir, siteName, list.Domains)
+		for _, domain := range unattached {
+			check := domainCheck{
+				Name:    "Site",
+				Message: "in site.properties, but not a domain of the site",
+				Hint:    fmt.Sprintf("Run 'lightspeed deploy', or attach it with 'lightspeed domains add %s'", domain),
+			}
+			printDomainChecks(domain, []domainCheck{check})
+			failed++
+		}
+		if interrupted(ctx) {
+			exitInterrupted("Run 'lightspeed dns check' to check again")
+		}
+
+		if failed > 0 {
+			ui.PrintError("%d of %d domain(s) failed", failed, len(list.Domains)+len(unattached))
+			fmt.Println()
+			os.Exit(1)
+		}
+		ui.PrintSuccess("All %d domain(s) are set up", len(list.Domains))
+		fmt.Println()
+	},
+}
+
+// checkDomain checks a domain's DNS, its record in the operator's zone and its certificate
+func checkDomain(ctx context.Context, resolver *dns.Resolver, domain api.SiteDomain) []domainCheck {
+	resolves := checkDomainDNS(ctx, resolver, domain)
+	checks := []domainCheck{resolves, checkDomainRecord(domain)}
+
+	tlsCheck := domainCheck{Name: "TLS"}
+	switch cert, err := resolver.CheckTLS(ctx, domain.Domain); {
+	case !resolves.Passed && err != nil:
+		tlsCheck.Skipped = true
+		tlsCheck.Message = "skipped until the domain resolves to the site"
+	case err != nil:
+		tlsCheck.Message = err.Error()
+		tlsCheck.Hint = "The certificate is issued once the domain resolves to the site, which can take up to an hour; check again later"
+		if domain.Proxied {
+			tlsCheck.Hint = "Cloudflare serves the certificate of proxied domains; check the domain's SSL/TLS settings in Cloudflare"
+		}
+	default:
+		tlsCheck.Passed = true
+		tlsCheck.Message = fmt.Sprintf("valid until %s", cert.NotAfter.Format("2006-01-02"))
+		if cert.Issuer.CommonName != "" {
+			tlsCheck.Message += ", issued by " + cert.Issuer.CommonName
+		}
+	}
+	return append(checks, tlsCheck)
+}
+
+// checkDomainDNS checks a domain resolves to its target: by a CNAME to it, by resolving to the
+// same addresses (a flattened CNAME or ALIAS record at the apex), or through the CDN when proxied
+func checkDomainDNS(ctx context.Context, resolver *dns.Resolver, domain api.SiteDomain) domainCheck {
+	check := domainCheck{Name: "DNS"}
+	hint := fmt.Sprintf("Create a CNAME record %s -> %s with your DNS provider (for an apex domain, use CNAME flattening or an ALIAS record)", domain.Domain, domain.Target)
+	if domain.Managed {
+		hint = "The operator manages this record; a new record can take a few minutes to propagate, so check again later"
+	}
+
+	addrs, err := resolver.LookupHost(ctx, domain.Domain)
+	if err != nil {
+		check.Message = "doesn't resolve"
+		check.Hint = hint
+		return check
+	}
+	if domain.Proxied {
+		check.Passed = true
+		check.Message = "served through Cloudflare (proxied)"
+		return check
+	}
+
+	cname, err := resolver.LookupCNAME(ctx, domain.Domain)
+	if err == nil && cname != domain.Domain {
+		if sameHost(cname, domain.Target) {
+			check.Passed = true
+			check.Message = "CNAME -> " + domain.Target
+			return check
+		}
+		if target, err := resolver.LookupCNAME(ctx, domain.Target); err == nil && sameHost(cname, target) {
+			check.Passed = true
+			check.Message = "CNAME -> " + domain.Target
+			return check
+		}
+	}
+
+	targetAddrs, err := resolver.LookupHost(ctx, domain.Target)
+	if err == nil && sharesAddress(addrs, targetAddrs) {
+		check.Passed = true
+		check.Message = "resolves to the addresses of " + domain.Target
+		return check
+	}
+
+	if cname != "" && cname != domain.Domain {
+		check.Message = fmt.Sprintf("CNAME -> %s, not %s", cname, domain.Target)
+	} else {
+		check.Message = fmt.Sprintf("resolves to %s, not %s", strings.Join(addrs, ", "), domain.Target)
+	}
+	check.Hint = hint
+	if !domain.Managed {
+		check.Hint = fmt.Sprintf("Point %s at %s with a CNAME record (for an apex domain, use CNAME flattening or an ALIAS record)", domain.Domain, domain.Target)
+	}
+	return check
+}
+
+// checkDomainRecord checks the operator's zone holds the record of a domain it manages
+func checkDomainRecord(domain api.SiteDomain) domainCheck {
+	check := domainCheck{Name: "Cloudflare"}
+	hint := "Detach and attach the domain again ('lightspeed domains remove' and 'lightspeed domains add') to recreate its record"
+	if domain.Type == "PRIMARY" {
+		hint = "The operator creates the record when it starts and for new sites; restart the operator to recreate it"
+	}
+
+	switch {
+	case !domain.Managed:
+		check.Skipped = true
+		check.Message = "not in a zone the operator manages"
+	case domain.Record == "":
+		check.Message = "no CNAME record in the zone"
+		check.Hint = hint
+	case !sameHost(domain.Record, domain.Target):
+		check.Message = fmt.Sprintf("CNAME -> %s, not %s", domain.Record, domain.Target)
+		check.Hint = hint
+	default:
+		check.Passed = true
+		check.Message = "CNAME -> " + domain.Record
+		if domain.Proxied {
+			check.Message += " (proxied)"
+		}
+	}
+	return check
+}
+
+// unattachedDomains returns the domains in site.properties that aren't domains of the site
+// Only checked for the project's own site, not one selected with --name
+func unattachedDomains(dir, siteName string, domains []api.SiteDomain) []string {
+	siteInfo, err := loadSiteInfo(dir)
+	if err != nil || siteInfo == nil {
+		return nil
+	}
+	if projectName, err := resolveSiteName(dir, ""); err != nil || projectName != siteName {
+		return nil
+	}
+
+	attached := map[string]bool{}
+	for _, domain := range domains {
+		attached[domain.Domain] = true
+	}
+	var missing []string
+	for _, domain := range siteInfo.Domains {
+		domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+		if !attached[domain] {
+			missing = append(missing, domain)
+		}
+	}
+	return missing
+}
+
+// printDomainChecks prints the checks of a domain, with the hints of failed ones
+// Returns true if a check failed
+func printDomainChecks(domain string, checks []domainCheck) bool {
+	fmt.Println(ui.Bold(domain))
+	failed := false
+	for _, check := range checks {
+		switch {
+		case check.Skipped:
+			fmt.Println(ui.Muted(fmt.Sprintf("  - %s: %s", check.Name, check.Message)))
+		case check.Passed:
+			ui.PrintSuccess("%s: %s", check.Name, check.Message)
+		default:
+			failed = true
+			ui.PrintError("%s: %s", check.Name, check.Message)
+			if check.Hint != "" {
+				fmt.Println(ui.Muted("  " + check.Hint))
+			}
+		}
+	}
+	fmt.Println()
+	return failed
+}
+
+// sameHost compares hostnames, ignoring case and a trailing dot
+func sameHost(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// sharesAddress checks if two address lists have an address in common
+func sharesAddress(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"lightspeed/core/lib/ui"
+)
+
+// dockerCommand creates a docker command, printing it with --verbose
+func dockerCommand(ctx context.Context, args ...string) *exec.Cmd {
+	ui.PrintDebug("docker %s", strings.Join(args, " "))
+	return exec.CommandContext(ctx, "docker", args...)
+}
+
+// pushLayerPattern matches a layer status line of docker push (e.g. "3f2a9c1b7d4e: Pushed")
+var pushLayerPattern = regexp.MustCompile(`^([0-9a-f]{12}): (.+)$`)
+
+// buildStepPattern matches the start of a build step in BuildKit's plain output
+// (e.g. "#8 [2/3] COPY . /var/www/html/", or "#5 [linux/arm64 1/3] FROM ..." with a stage or platform)
+var buildStepPattern = regexp.MustCompile(`^#\d+ \[(?:\S+ )?(\d+)/(\d+)\] (.+)$`)
+
+// lineWriter collects output and passes each complete line to a handler
+// Lines end with \n, or \r where output redraws a line in place
+type lineWriter struct {
+	output  bytes.Buffer
+	partial []byte
+	line    func(string)
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.output.Write(data)
+	w.partial = append(w.partial, data...)
+	for {
+		i := bytes.IndexAny(w.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.line(strings.TrimSpace(string(w.partial[:i])))
+		w.partial = w.partial[i+1:]
+	}
+	return len(data), nil
+}
+
+// pushProgress follows the layers of a docker push on a spinner
+// Without a terminal docker prints a line whenever a layer changes state, which is enough to
+// count the finished layers
+type pushProgress struct {
+	lineWriter
+	spinner  *ui.Spinner
+	layers   map[string]bool // Whether each layer is done, by ID
+	existing int             // Layers the registry already had
+}
+
+func newPushProgress(spinner *ui.Spinner) *pushProgress {
+	p := &pushProgress{spinner: spinner, layers: map[string]bool{}}
+	p.lineWriter.line = p.layer
+	return p
+}
+
+// layer records a layer status line
+func (p *pushProgress) layer(line string) {
+	match := pushLayerPattern.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	id, status := match[1], match[2]
+	if p.layers[id] {
+		return
+	}
+	switch {
+	case status == "Layer already exists", strings.HasPrefix(status, "Mounted from"):
+		p.existing++
+		p.layers[id] = true
+	case status == "Pushed":
+		p.layers[id] = true
+	default:
+		p.layers[id] = false
+	}
+
+	done := 0
+	for _, finished := range p.layers {
+		if finished {
+			done++
+		}
+	}
+	p.spinner.Progress(int64(done), int64(len(p.layers)))
+}
+
+// buildProgress shows the current step of a docker build on a spinner
+type buildProgress struct {
+	lineWriter
+	spinner *ui.Spinner
+}
+
+func newBuildProgress(spinner *ui.Spinner) *buildProgress {
+	p := &buildProgress{spinner: spinner}
+	p.lineWriter.line = p.step
+	return p
+}
+
+// step shows a build step line
+func (p *buildProgress) step(line string) {
+	match := buildStepPattern.FindStringSubmatch(line)
+	if match == nil || p.spinner == nil {
+		return
+	}
+	step, _ := strconv.ParseInt(match[1], 10, 64)
+	steps, _ := strconv.ParseInt(match[2], 10, 64)
+	description := []rune(match[3])
+	if len(description) > 48 {
+		description = append(description[:47], '…')
+	}
+	p.spinner.Update("Building: %s", string(description))
+	p.spinner.Progress(step, steps)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var domainsSiteName string
+
+var domainsCmd = &cobra.Command{
+	Use:   "domains",
+	Short: "Manage the custom domains of a site",
+	Long:  "Attach custom domains to a deployed site or detach them, without recreating the site",
+}
+
+var domainsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the domains of the site",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := domainsSite()
+
+		list, err := newBackend().ListDomains(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to list domains: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Domains of '%s'", siteName)
+		fmt.Println()
+		for _, domain := range list.Domains {
+			fmt.Printf("  %-7s %s\n", domain.Type, domain.Domain)
+		}
+		fmt.Println()
+	},
+}
+
+var domainsAddCmd = &cobra.Command{
+	Use:   "add <domain>",
+	Short: "Attach a custom domain to the site",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := domainsSite()
+
+		ui.PrintInfo("Adding %s to '%s'...", args[0], siteName)
+		domain, err := newBackend().AddDomain(cmd.Context(), siteName, args[0])
+		if err != nil {
+			ui.PrintError("Failed to add domain: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Added %s", domain.Domain)
+		fmt.Println()
+		printDomainSetup(domain)
+	},
+}
+
+var domainsRemoveCmd = &cobra.Command{
+	Use:     "remove <domain>",
+	Aliases: []string{"rm"},
+	Short:   "Detach a custom domain from the site",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := domainsSite()
+
+		if err := newBackend().RemoveDomain(cmd.Context(), siteName, args[0]); err != nil {
+			ui.PrintError("Failed to remove domain: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Removed %s from '%s'", args[0], siteName)
+		fmt.Println()
+	},
+}
+
+// printDomainSetup prints what's left to do for a new domain to reach the site
+func printDomainSetup(domain *api.SiteDomain) {
+	if domain.Managed {
+		ui.PrintInfo("DNS record created; the certificate is issued once the domain resolves")
+	} else {
+		ui.PrintInfo("Create this record with your DNS provider:")
+		fmt.Printf("  CNAME %s -> %s\n", domain.Domain, domain.Target)
+		fmt.Println()
+		ui.PrintInfo("For an apex domain, use your provider's CNAME flattening or ALIAS record")
+	}
+	ui.PrintInfo("Add the domain to site.properties so it's kept if the site is recreated")
+	fmt.Println()
+}
+
+// domainsSite resolves the site domains commands apply to
+func domainsSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, domainsSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+func init() {
+	domainsCmd.PersistentFlags().StringVarP(&domainsSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	domainsCmd.AddCommand(domainsListCmd)
+	domainsCmd.AddCommand(domainsAddCmd)
+	domainsCmd.AddCommand(domainsRemoveCmd)
+	rootCmd.AddCommand(domainsCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// getSiteEdge reads the edge mode settings from site.properties
+// Returns nil without an edge property, so settings made elsewhere are left alone
+func getSiteEdge(props properties.Properties) (*api.SiteEdge, error) {
+	if props.Get("edge") == "" {
+		return nil, nil
+	}
+
+	edge := &api.SiteEdge{
+		Enabled:     props.GetBool("edge"),
+		Auth:        os.ExpandEnv(props.Get("edge.auth")),
+		Maintenance: props.GetBool("edge.maintenance"),
+		Log:         props.GetBool("edge.log"),
+	}
+
+	if edge.Auth != "" {
+		if user, password, ok := strings.Cut(edge.Auth, ":"); !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("edge.auth must be user:password")
+		}
+	}
+	return edge, nil
+}
+
+// syncEdge applies the edge mode settings from site.properties, if it has any
+// Unlike checks, a failure fails the deploy: the site would otherwise be served without its access rules
+func syncEdge(ctx context.Context, out *ui.Output, backend Backend, siteName string, edge *api.SiteEdge) error {
+	if edge == nil {
+		return nil
+	}
+
+	result, err := backend.SetEdge(ctx, siteName, *edge)
+	if err != nil {
+		return fmt.Errorf("edge settings not applied: %w", err)
+	}
+	if !result.Enabled {
+		out.PrintInfo("Edge mode: off")
+		return nil
+	}
+
+	var rules []string
+	if result.AuthUser != "" {
+		rules = append(rules, "basic auth ("+result.AuthUser+")")
+	}
+	if result.Maintenance {
+		rules = append(rules, "maintenance page")
+	}
+	if len(result.Allow) > 0 || len(result.Deny) > 0 {
+		rules = append(rules, "IP rules")
+	}
+	if result.Log {
+		rules = append(rules, "request logs")
+	}
+	if len(rules) == 0 {
+		rules = append(rules, "no rules")
+	}
+	out.PrintInfo("Edge mode: %s", strings.Join(rules, ", "))
+	return nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var envSiteName string
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage environment variables of a site",
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the site's environment variables",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		list, err := newBackend().ListEnv(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to list environment variables: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if len(list.Vars) == 0 {
+			ui.PrintInfo("No environment variables set for '%s'", siteName)
+			fmt.Println()
+			return
+		}
+
+		ui.PrintInfo("Environment variables of '%s'", siteName)
+		fmt.Println()
+		for _, env := range list.Vars {
+			printEnvVar(env)
+		}
+		fmt.Println()
+	},
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <KEY=VALUE>...",
+	Short: "Set environment variables (redeploys the site)",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		var update api.EnvUpdate
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				ui.PrintError("Invalid '%s': use KEY=VALUE", arg)
+				os.Exit(1)
+			}
+			update.Set = append(update.Set, api.EnvVar{Key: key, Value: value, Type: "GENERAL"})
+		}
+
+		updateEnv(cmd, siteName, update)
+	},
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <KEY>...",
+	Short: "Remove environment variables (redeploys the site)",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		updateEnv(cmd, siteName, api.EnvUpdate{Unset: args})
+	},
+}
+
+// updateEnv applies an env update and prints the resulting variables
+func updateEnv(cmd *cobra.Command, siteName string, update api.EnvUpdate) {
+	ui.PrintInfo("Updating environment of '%s'...", siteName)
+	list, err := newBackend().UpdateEnv(cmd.Context(), siteName, update)
+	if err != nil {
+		ui.PrintError("Failed to update environment variables: %v", err)
+		printErrorHint(err)
+		os.Exit(1)
+	}
+
+	ui.PrintSuccess("Environment updated, the site is redeploying")
+	fmt.Println()
+	for _, env := range list.Vars {
+		printEnvVar(env)
+	}
+	fmt.Println()
+	ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+	fmt.Println()
+}
+
+// envSite resolves the site env commands apply to
+func envSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, envSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+// printEnvVar prints an environment variable, hiding secret values
+func printEnvVar(env api.EnvVar) {
+	if env.Type == "SECRET" {
+		fmt.Printf("  %s=%s\n", env.Key, ui.Muted("(secret)"))
+		return
+	}
+	fmt.Printf("  %s=%s\n", env.Key, env.Value)
+}
+
+func init() {
+	envCmd.PersistentFlags().StringVarP(&envSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+	rootCmd.AddCommand(envCmd)
+}
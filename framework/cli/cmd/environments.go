@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"lightspeed/core/lib/properties"
+)
+
+// environmentsPrefix starts the keys of environment sections, which override the project's
+// other keys when it's deployed to that environment: environments.staging.instances=1
+const environmentsPrefix = "environments."
+
+// environmentNamePattern matches an environment name, which becomes part of its site's name
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,18}[a-z0-9])?$`)
+
+// deployEnvironment is the environment selected with --env ("" for the project as it is)
+var deployEnvironment string
+
+// parseProjectFile parses a project file with the section of the selected environment applied
+func parseProjectFile(path string) (properties.Properties, error) {
+	props, err := properties.ParseFile(path)
+	if err != nil || deployEnvironment == "" {
+		return props, err
+	}
+	dirName := sanitizeContainerName(filepath.Base(filepath.Dir(path)))
+	return forEnvironment(props, deployEnvironment, dirName), nil
+}
+
+// forEnvironment returns a project's properties for an environment
+// Environments other than production are their own site, named <name>-<environment>, without the
+// project's custom domains (they belong to production) and built for that environment. Keys of
+// the environment's section then replace the project's, so a section can set its own name,
+// domains or env.* variables.
+func forEnvironment(props properties.Properties, env, dirName string) properties.Properties {
+	result := properties.Properties{}
+	for key, value := range props {
+		if strings.HasPrefix(key, environmentsPrefix) {
+			continue
+		}
+		if env != productionEnvironment && (key == "domain" || key == "domains") {
+			continue
+		}
+		result[key] = value
+	}
+	if env != productionEnvironment {
+		result["name"] = sanitizeContainerName(props.GetWithDefault("name", dirName)) + "-" + env
+		result["environment"] = env
+	}
+
+	section := environmentsPrefix + env + "."
+	for key, value := range props {
+		if name, ok := strings.CutPrefix(key, section); ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// projectEnvironments returns the environments a project has sections for, sorted
+func projectEnvironments(props properties.Properties) []string {
+	seen := map[string]bool{}
+	var envs []string
+	for key := range props {
+		rest, ok := strings.CutPrefix(key, environmentsPrefix)
+		if !ok {
+			continue
+		}
+		env, _, _ := strings.Cut(rest, ".")
+		if !seen[env] {
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// validateEnvironment checks the environment selected with --env: production, or one the
+// project has a section for
+func validateEnvironment(dir, env string) error {
+	if !environmentNamePattern.MatchString(env) {
+		return fmt.Errorf("environment '%s' must be 1-20 lowercase letters, digits and dashes", env)
+	}
+	if env == productionEnvironment {
+		return nil
+	}
+
+	propsPath := properties.ProjectFile(dir)
+	if !properties.FileExists(propsPath) {
+		return fmt.Errorf("no site.properties or lightspeed.yaml to find environment '%s' in", env)
+	}
+	props, err := properties.ParseFile(propsPath)
+	if err != nil {
+		return err
+	}
+	envs := projectEnvironments(props)
+	for _, known := range envs {
+		if known == env {
+			return nil
+		}
+	}
+	if len(envs) == 0 {
+		return fmt.Errorf("%s has no environments (add e.g. %s%s.instances=1)", filepath.Base(propsPath), environmentsPrefix, env)
+	}
+	return fmt.Errorf("%s has no environment '%s' (environments: %s)", filepath.Base(propsPath), env, strings.Join(envs, ", "))
+}
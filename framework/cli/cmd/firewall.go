@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+var firewallSiteName string
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "Manage the IP rules of a site in edge mode",
+	Long:  "Allow or deny IPs and CIDR ranges at the edge proxy. Denied IPs are rejected first; with an allow list, only IPs on it get through. Blocked requests are audit-logged by the operator.",
+}
+
+var firewallListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the IP rules of the site",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := firewallSite()
+
+		firewall, err := newBackend().GetFirewall(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get IP rules: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("IP rules of '%s'", siteName)
+		fmt.Println()
+		if len(firewall.Allow) == 0 && len(firewall.Deny) == 0 {
+			fmt.Println(ui.Muted("  No rules, every IP is allowed"))
+		}
+		for _, rule := range firewall.Deny {
+			fmt.Printf("  deny   %s\n", rule)
+		}
+		for _, rule := range firewall.Allow {
+			fmt.Printf("  allow  %s\n", rule)
+		}
+		if len(firewall.Allow) > 0 {
+			fmt.Println(ui.Muted("  Other IPs are blocked"))
+		}
+		fmt.Println()
+	},
+}
+
+var firewallAllowCmd = &cobra.Command{
+	Use:   "allow <ip|cidr>...",
+	Short: "Add IPs or CIDR ranges to the allow list (only they get through)",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateFirewall(cmd.Context(), func(firewall *api.SiteFirewall) {
+			firewall.Allow = appendRules(firewall.Allow, args)
+		})
+	},
+}
+
+var firewallDenyCmd = &cobra.Command{
+	Use:   "deny <ip|cidr>...",
+	Short: "Add IPs or CIDR ranges to the deny list",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateFirewall(cmd.Context(), func(firewall *api.SiteFirewall) {
+			firewall.Deny = appendRules(firewall.Deny, args)
+		})
+	},
+}
+
+var firewallRemoveCmd = &cobra.Command{
+	Use:     "remove <ip|cidr>...",
+	Aliases: []string{"rm"},
+	Short:   "Remove IPs or CIDR ranges from the allow and deny lists",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateFirewall(cmd.Context(), func(firewall *api.SiteFirewall) {
+			firewall.Allow = removeRules(firewall.Allow, args)
+			firewall.Deny = removeRules(firewall.Deny, args)
+		})
+	},
+}
+
+var firewallClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every IP rule of the site",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateFirewall(cmd.Context(), func(firewall *api.SiteFirewall) {
+			firewall.Allow, firewall.Deny = nil, nil
+		})
+	},
+}
+
+// updateFirewall changes the site's IP rules and prints the result
+func updateFirewall(ctx context.Context, change func(firewall *api.SiteFirewall)) {
+	ui.PrintHeader(Version)
+	siteName := firewallSite()
+	backend := newBackend()
+
+	firewall, err := backend.GetFirewall(ctx, siteName)
+	if err != nil {
+		ui.PrintError("Failed to get IP rules: %v", err)
+		printErrorHint(err)
+		os.Exit(1)
+	}
+	change(firewall)
+	for _, rule := range append(append([]string{}, firewall.Allow...), firewall.Deny...) {
+		if !validIPRule(rule) {
+			ui.PrintError("'%s' is not an IP or CIDR range", rule)
+			os.Exit(1)
+		}
+	}
+
+	updated, err := backend.SetFirewall(ctx, siteName, *firewall)
+	if err != nil {
+		ui.PrintError("Failed to update IP rules: %v", err)
+		printErrorHint(err)
+		os.Exit(1)
+	}
+
+	ui.PrintSuccess("Updated IP rules of '%s': %d allowed, %d denied", siteName, len(updated.Allow), len(updated.Deny))
+	ui.PrintInfo("Add them to firewall.allow/firewall.deny in site.properties so deploys keep them")
+	fmt.Println()
+}
+
+// getSiteFirewall reads the IP rules from site.properties
+// Returns nil without firewall properties, so rules set with 'lightspeed firewall' are left alone
+func getSiteFirewall(props properties.Properties) (*api.SiteFirewall, error) {
+	if props.Get("firewall.allow") == "" && props.Get("firewall.deny") == "" {
+		return nil, nil
+	}
+
+	firewall := &api.SiteFirewall{
+		Allow: props.GetList("firewall.allow"),
+		Deny:  props.GetList("firewall.deny"),
+	}
+	for _, rule := range append(append([]string{}, firewall.Allow...), firewall.Deny...) {
+		if !validIPRule(rule) {
+			return nil, fmt.Errorf("firewall rule %q must be an IP or CIDR range", rule)
+		}
+	}
+	return firewall, nil
+}
+
+// syncFirewall applies the IP rules from site.properties, if it has any
+// Like the edge settings, a failure fails the deploy
+func syncFirewall(ctx context.Context, out *ui.Output, backend Backend, siteName string, firewall *api.SiteFirewall) error {
+	if firewall == nil {
+		return nil
+	}
+
+	result, err := backend.SetFirewall(ctx, siteName, *firewall)
+	if err != nil {
+		return fmt.Errorf("IP rules not applied: %w", err)
+	}
+	out.PrintInfo("IP rules: %d allowed, %d denied", len(result.Allow), len(result.Deny))
+	return nil
+}
+
+// validIPRule checks a rule is an IP or CIDR range
+func validIPRule(rule string) bool {
+	rule = strings.TrimSpace(rule)
+	if net.ParseIP(rule) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(rule)
+	return err == nil
+}
+
+// appendRules adds rules to a list, skipping ones it has
+func appendRules(list, rules []string) []string {
+	for _, rule := range rules {
+		if !containsString(list, rule) {
+			list = append(list, rule)
+		}
+	}
+	return list
+}
+
+// removeRules drops rules from a list
+func removeRules(list, rules []string) []string {
+	kept := make([]string, 0, len(list))
+	for _, rule := range list {
+		if !containsString(rules, rule) {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
+
+// firewallSite resolves the site firewall commands apply to
+func firewallSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, firewallSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+func init() {
+	firewallCmd.PersistentFlags().StringVarP(&firewallSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	firewallCmd.AddCommand(firewallListCmd)
+	firewallCmd.AddCommand(firewallAllowCmd)
+	firewallCmd.AddCommand(firewallDenyCmd)
+	firewallCmd.AddCommand(firewallRemoveCmd)
+	firewallCmd.AddCommand(firewallClearCmd)
+	rootCmd.AddCommand(firewallCmd)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/dns"
+	"lightspeed/core/lib/ui"
+)
+
+// docsURL is where the hints' documentation links point
+const docsURL = "https://github.com/abrayall/lightspeed#"
+
+// errorHint describes a recognized failure and how to fix it
+// A hint matches an operator error code, an error in the chain, or the error message
+type errorHint struct {
+	code    string
+	target  error
+	pattern *regexp.Regexp
+	problem string
+	hint    string
+	docs    string
+}
+
+// errorHints are checked in order; the first match wins
+var errorHints = []errorHint{
+	{
+		pattern: regexp.MustCompile(`(?i)cannot connect to the docker daemon|docker daemon is not running|error during connect|"docker": executable file not found`),
+		problem: "Docker is not running",
+		hint:    "Start Docker Desktop (or the docker service) and try again",
+		docs:    "requirements",
+	},
+	{
+		code:    api.ErrorCodeRateLimited,
+		pattern: regexp.MustCompile(`(?i)429 Too Many Requests|too_many_requests`),
+		problem: "DigitalOcean's API rate limit was reached",
+		hint:    "Wait a minute and try again; deploying many sites at once uses up the limit faster",
+		docs:    "troubleshooting",
+	},
+	{
+		code:    api.ErrorCodeProviderAuth,
+		problem: "DigitalOcean rejected the operator's token",
+		hint:    "The operator's DigitalOcean token is invalid or lacks a scope; ask its administrator to replace it",
+		docs:    "troubleshooting",
+	},
+	{
+		code:    api.ErrorCodeUnauthorized,
+		pattern: regexp.MustCompile(`(?i)unauthorized|authentication required|denied: requested access`),
+		problem: "The registry or operator requires you to log in",
+		hint:    "Run 'lightspeed login' and try again",
+		docs:    "login",
+	},
+	{
+		code:    api.ErrorCodeTagNotFound,
+		pattern: regexp.MustCompile(`(?i)tag \S+ not found in registry`),
+		problem: "The image tag isn't in the registry",
+		hint:    "Publish it with 'lightspeed publish' (or deploy without --no-build); 'lightspeed inspect' shows the published tags",
+		docs:    "publish",
+	},
+	{
+		code:    api.ErrorCodePolicy,
+		problem: "The change breaks a policy of the operator",
+		hint:    "Change the settings the violations name (region, size, instances, label.* or env.* in site.properties), or ask the operator's administrator about the policy",
+		docs:    "site-policies",
+	},
+	{
+		code:    api.ErrorCodeSiteNotFound,
+		problem: "The site doesn't exist",
+		hint:    "Check the site name (-n or name in site.properties), or create the site with 'lightspeed deploy'",
+		docs:    "deploy",
+	},
+	{
+		target:  dns.ErrNotResolved,
+		problem: "The site's domain didn't resolve in time",
+		hint:    "DNS can take a few minutes to propagate; run 'lightspeed deploy --from verify' to check again, or set LIGHTSPEED_RESOLVERS to query other nameservers",
+		docs:    "troubleshooting",
+	},
+}
+
+// findHint finds the first hint matching an error (nil if the failure is unrecognized)
+func findHint(err error) *errorHint {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *operatorError
+	errors.As(err, &apiErr)
+	for i := range errorHints {
+		h := &errorHints[i]
+		switch {
+		case h.code != "" && apiErr != nil && apiErr.Code == h.code:
+			return h
+		case h.target != nil && errors.Is(err, h.target):
+			return h
+		case h.pattern != nil && h.pattern.MatchString(err.Error()):
+			return h
+		}
+	}
+	return nil
+}
+
+// printErrorHint prints the problem, remediation and documentation link of a recognized failure
+// Nothing is printed for unrecognized failures
+func printErrorHint(err error) {
+	h := findHint(err)
+	if h == nil {
+		return
+	}
+	ui.PrintWarning("%s", h.problem)
+	ui.PrintInfo("%s", h.hint)
+	if h.docs != "" {
+		fmt.Println(ui.Muted("  See " + docsURL + h.docs))
+	}
+}
+
+// dockerError adds the last line docker printed to the error of a docker command
+func dockerError(err error, output string) error {
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Errorf("%w: %s", err, last)
+	}
+	return err
+}
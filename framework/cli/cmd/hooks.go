@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// indexNowEndpoint shares submitted URLs with all search engines supporting IndexNow
+const indexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+// indexNowMaxURLs is the most URLs IndexNow accepts in one submission
+const indexNowMaxURLs = 10000
+
+// hookTimeout bounds each hook request, so a slow endpoint doesn't hold up the deploy
+const hookTimeout = 15 * time.Second
+
+// indexNowKeyPattern is the format IndexNow requires of keys
+var indexNowKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9-]{8,128}$`)
+
+// deployHooks are the post-deploy hooks from site.properties, run after production deploys
+type deployHooks struct {
+	Purge    bool     // hooks.purge, purge the CDN cache of the site's domain
+	IndexNow string   // hooks.indexnow, IndexNow key to submit the site's pages with
+	Ping     []string // hooks.ping, URLs to request ({url} and {sitemap} are replaced)
+}
+
+// Enabled checks if any hook is set
+func (h deployHooks) Enabled() bool {
+	return h.Purge || h.IndexNow != "" || len(h.Ping) > 0
+}
+
+// getDeployHooks reads the post-deploy hooks from site.properties
+func getDeployHooks(props properties.Properties) (deployHooks, error) {
+	hooks := deployHooks{
+		Purge:    props.GetBool("hooks.purge"),
+		IndexNow: props.Get("hooks.indexnow"),
+		Ping:     props.GetList("hooks.ping"),
+	}
+
+	if hooks.IndexNow != "" && !indexNowKeyPattern.MatchString(hooks.IndexNow) {
+		return hooks, fmt.Errorf("hooks.indexnow must be 8-128 letters, digits or dashes")
+	}
+	for _, ping := range hooks.Ping {
+		if u, err := url.Parse(ping); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return hooks, fmt.Errorf("hooks.ping %q must be an http(s) URL", ping)
+		}
+	}
+	return hooks, nil
+}
+
+// runDeployHooks purges the site's cache and notifies search engines of the deploy
+// Hooks run once the site is live, so failures are warnings rather than failing the deploy
+func runDeployHooks(ctx context.Context, out *ui.Output, backend Backend, hooks deployHooks, dir, siteName, siteURL string) {
+	if hooks.Purge {
+		purge, err := backend.PurgeCache(ctx, siteName)
+		if err != nil {
+			out.PrintWarning("Cache not purged: %v", err)
+		} else {
+			out.PrintSuccess("Purged cache of %s", purge.Domain)
+		}
+	}
+
+	if hooks.IndexNow != "" {
+		count, err := submitIndexNow(ctx, dir, siteURL, hooks.IndexNow)
+		if err != nil {
+			out.PrintWarning("IndexNow not notified: %v", err)
+		} else {
+			out.PrintSuccess("Submitted %d pages to IndexNow", count)
+		}
+	}
+
+	for _, ping := range hooks.Ping {
+		endpoint := strings.NewReplacer(
+			"{url}", url.QueryEscape(siteURL),
+			"{sitemap}", url.QueryEscape(siteURL+"/sitemap.xml"),
+		).Replace(ping)
+		if err := pingHook(ctx, endpoint); err != nil {
+			out.PrintWarning("Ping failed: %v", err)
+		} else {
+			out.PrintSuccess("Pinged %s", hostOf(endpoint))
+		}
+	}
+}
+
+// submitIndexNow submits the project's pages to IndexNow
+// The key is verified against the key file the build adds to the image (see generatedFiles)
+func submitIndexNow(ctx context.Context, dir, siteURL, key string) (int, error) {
+	pages, err := sitemapPages(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(pages) == 0 {
+		return 0, fmt.Errorf("no pages found")
+	}
+	if len(pages) > indexNowMaxURLs {
+		pages = pages[:indexNowMaxURLs]
+	}
+
+	urls := make([]string, len(pages))
+	for i, page := range pages {
+		urls[i] = siteURL + page.Path
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"host":        hostOf(siteURL),
+		"key":         key,
+		"keyLocation": siteURL + "/" + key + ".txt",
+		"urlList":     urls,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, indexNowEndpoint, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return 0, fmt.Errorf("IndexNow returned HTTP %d", resp.StatusCode)
+	}
+	return len(urls), nil
+}
+
+// pingHook requests a ping endpoint
+func pingHook(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Deploy/1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned HTTP %d", hostOf(endpoint), resp.StatusCode)
+	}
+	return nil
+}
+
+// hooksSiteURL returns the URL hooks announce: the site's first custom domain, or its own URL
+func hooksSiteURL(siteURL string, domains []string) string {
+	if len(domains) > 0 {
+		return "https://" + domains[0]
+	}
+	return strings.TrimSuffix(siteURL, "/")
+}
+
+// hostOf returns the host of a URL, or the URL itself if it can't be parsed
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+// indexNowKeyFile returns the key file IndexNow verifies a key against, if the project sets one
+func indexNowKeyFile(props properties.Properties) (string, string) {
+	key := props.Get("hooks.indexnow")
+	if key == "" || !indexNowKeyPattern.MatchString(key) {
+		return "", ""
+	}
+	return key + ".txt", key
+}
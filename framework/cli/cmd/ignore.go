@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lightspeedIgnoreFile lists files left out of builds, in .dockerignore syntax
+const lightspeedIgnoreFile = ".lightspeedignore"
+
+// defaultIgnores are left out of the build context of projects built with the generated Dockerfile,
+// and written to the .dockerignore of new projects
+var defaultIgnores = []string{
+	".git",
+	".svn",
+	".hg",
+	".idea",
+	".vscode",
+	"*.swp",
+	".DS_Store",
+	"Thumbs.db",
+	"node_modules",
+	"*.log",
+	".lightspeed",
+	".dockerignore",
+	lightspeedIgnoreFile,
+}
+
+// dockerignoreContent returns the .dockerignore created by init
+func dockerignoreContent() string {
+	return strings.Join(defaultIgnores, "\n") + "\n"
+}
+
+// buildIgnores returns the ignore patterns of a build, or nil if docker's own handling of
+// .dockerignore is enough (a project Dockerfile without .lightspeedignore).
+// Generated builds start from the default patterns; the project's .dockerignore and
+// .lightspeedignore follow, so their ! patterns can bring files back.
+func buildIgnores(dir string, generated bool) (string, error) {
+	lightspeedIgnore, err := readIgnoreFile(dir, lightspeedIgnoreFile)
+	if err != nil {
+		return "", err
+	}
+	if !generated && lightspeedIgnore == "" {
+		return "", nil
+	}
+
+	dockerIgnore, err := readIgnoreFile(dir, ".dockerignore")
+	if err != nil {
+		return "", err
+	}
+
+	var ignores strings.Builder
+	if generated {
+		ignores.WriteString(dockerignoreContent())
+	}
+	if dockerIgnore != "" {
+		fmt.Fprintf(&ignores, "# .dockerignore\n%s\n", dockerIgnore)
+	}
+	if lightspeedIgnore != "" {
+		fmt.Fprintf(&ignores, "# %s\n%s\n", lightspeedIgnoreFile, lightspeedIgnore)
+	}
+	return ignores.String(), nil
+}
+
+// readIgnoreFile reads an ignore file of a project (empty if it doesn't exist)
+func readIgnoreFile(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeBuildDockerfile writes a Dockerfile with its ignore patterns (Dockerfile.dockerignore,
+// which BuildKit reads instead of the context's .dockerignore) to a temporary directory,
+// so nothing is written to the project. Returns the Dockerfile path and a cleanup function.
+func writeBuildDockerfile(dockerfile, ignores string) (string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "lightspeed-build-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	path := filepath.Join(tmpDir, "Dockerfile")
+	if err := os.WriteFile(path, []byte(dockerfile), 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err := os.WriteFile(path+".dockerignore", []byte(ignores), 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// ignoreMatcher matches project paths against ignore patterns the way docker does:
+// patterns are relative to the project root, match directories with their contents,
+// and the last matching pattern wins (so ! patterns can bring files back)
+type ignoreMatcher struct {
+	patterns   []ignorePattern
+	exceptions bool
+}
+
+// ignorePattern is a compiled ignore pattern
+type ignorePattern struct {
+	re      *regexp.Regexp
+	exclude bool
+}
+
+// newIgnoreMatcher compiles ignore patterns in .dockerignore syntax
+func newIgnoreMatcher(ignores string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	for _, line := range strings.Split(ignores, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		exclude := strings.HasPrefix(line, "!")
+		if exclude {
+			line = strings.TrimSpace(line[1:])
+			m.exceptions = true
+		}
+		line = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(line)), "/")
+		if line == "" {
+			continue
+		}
+
+		re, err := regexp.Compile("^" + ignoreRegexp(line) + "(/.*)?$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern '%s': %w", line, err)
+		}
+		m.patterns = append(m.patterns, ignorePattern{re: re, exclude: exclude})
+	}
+	return m, nil
+}
+
+// ignoreRegexp translates an ignore pattern into a regular expression
+// ** matches any number of directories, * and ? match within a path segment
+func ignoreRegexp(pattern string) string {
+	var re strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := strings.Replace(pattern[i+1:i+end], "!", "^", 1)
+			re.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// Ignored checks if a path (relative to the project, with / separators) is ignored
+func (m *ignoreMatcher) Ignored(rel string) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.re.MatchString(rel) {
+			ignored = !p.exclude
+		}
+	}
+	return ignored
+}
+
+// SkipDir checks if an ignored directory can be skipped entirely
+// Without ! patterns nothing inside it can be brought back
+func (m *ignoreMatcher) SkipDir(rel string) bool {
+	return !m.exceptions && m.Ignored(rel)
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+	"lightspeed/core/lib/version"
+)
+
+var (
+	infoName  string
+	infoTag   string
+	infoImage string
+)
+
+// Sources of resolved settings, as info shows them
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceConfig  = "config"
+	sourceDefault = "default"
+)
+
+// infoValue is a resolved setting and where it came from
+type infoValue struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// infoLibrary is a library from site.properties and the path it resolves to
+type infoLibrary struct {
+	Spec      string `json:"spec"`
+	Path      string `json:"path"`
+	Installed bool   `json:"installed"` // False if the path doesn't exist (the bundled library isn't downloaded yet)
+}
+
+// infoOutput is the result of info with --output json
+type infoOutput struct {
+	Dir       string        `json:"dir"`
+	File      string        `json:"file,omitempty"`
+	Values    []infoValue   `json:"values"`
+	Libraries []infoLibrary `json:"libraries"`
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the resolved configuration of the project",
+	Long:  "Show the configuration build, publish and deploy would use for the project: the site name, the image and tag that would be built, the registry and API hosts, the libraries and the paths they resolve to, and where each value came from (flag, env, site.properties, config or default). Nothing is built, downloaded or allocated.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		info, err := resolveInfo(dir)
+		if err != nil {
+			ui.PrintError("Failed to resolve the configuration: %v", err)
+			os.Exit(1)
+		}
+
+		if jsonOutput() {
+			printJSON(info)
+			return
+		}
+
+		ui.PrintKeyValue("Project", info.Dir)
+		if info.File != "" {
+			ui.PrintKeyValue("File", info.File)
+		} else {
+			ui.PrintKeyValue("File", ui.Muted("none (run 'lightspeed init' to create site.properties)"))
+		}
+		fmt.Println()
+		for _, value := range info.Values {
+			ui.PrintKeyValue(value.Name, value.Value+" "+ui.Muted("("+value.Source+")"))
+		}
+		fmt.Println()
+
+		if len(info.Libraries) == 0 {
+			ui.PrintKeyValue("Libraries", ui.Muted("none"))
+		} else {
+			fmt.Println("  Libraries")
+			for _, lib := range info.Libraries {
+				note := ""
+				if !lib.Installed {
+					note = " " + ui.Muted("(not found)")
+				}
+				fmt.Printf("    %s -> %s%s\n", lib.Spec, lib.Path, note)
+			}
+		}
+		fmt.Println()
+	},
+}
+
+// resolveInfo resolves the settings of a project the way build, publish and deploy do
+// Tags that the operator hands out (build numbers and dated tags) are shown as placeholders,
+// and libraries aren't downloaded
+func resolveInfo(dir string) (*infoOutput, error) {
+	info := &infoOutput{Dir: dir, Values: []infoValue{}, Libraries: []infoLibrary{}}
+
+	props := properties.Properties{}
+	fileSource := properties.PropertiesFile
+	if path := properties.ProjectFile(dir); properties.FileExists(path) {
+		parsed, err := properties.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		props = parsed
+		info.File = path
+		fileSource = filepath.Base(path)
+	}
+	add := func(name, value, source string) {
+		info.Values = append(info.Values, infoValue{Name: name, Value: value, Source: source})
+	}
+
+	// Site name: --name > name > directory name
+	switch {
+	case infoName != "":
+		add("Site", infoName, sourceFlag+": --name")
+	case props.Get("name") != "":
+		add("Site", sanitizeContainerName(props.Get("name")), fileSource+": name")
+	default:
+		add("Site", sanitizeContainerName(filepath.Base(dir)), sourceDefault+": directory name")
+	}
+	siteName := info.Values[0].Value
+
+	// Tag: --tag > tag.strategy > git-describe
+	strategy, strategySource := props.Get("tag.strategy"), fileSource+": tag.strategy="
+	if strategy == "" {
+		strategy, strategySource = tagStrategyGitDescribe, sourceDefault+": "
+	}
+	if err := validateTagStrategy(strategy); err != nil {
+		return nil, err
+	}
+	tag, tagSource := infoTag, sourceFlag+": --tag"
+	if tag == "" {
+		tag, tagSource = strategyTag(dir, strategy), strategySource+strategy
+	}
+	add("Tag", tag, tagSource)
+	add("Image", registryHost+"/"+siteName+":"+tag, "registry/site:tag")
+
+	// Base image: --image > image > the CLI version's server image
+	switch {
+	case infoImage != "":
+		add("Base image", resolveImage(infoImage), sourceFlag+": --image")
+	case props.Get("image") != "":
+		add("Base image", resolveImage(props.Get("image")), fileSource+": image")
+	default:
+		add("Base image", resolveImage(""), sourceDefault+": CLI version")
+	}
+
+	projectType, typeSource := props.Get("type"), fileSource+": type"
+	if projectType == "" {
+		projectType, typeSource = "php", sourceDefault
+	}
+	add("Type", projectType, typeSource)
+
+	apiSource, registrySource := hostSources()
+	add("API", apiHost, apiSource)
+	add("API URL", getAPIURL(), "API host")
+	add("Registry", registryHost, registrySource)
+
+	switch region := siteRegion(props); {
+	case props.Get("region") != "":
+		add("Region", region, fileSource+": region")
+	case region != "":
+		add("Region", region, sourceConfig+": region")
+	default:
+		add("Region", "operator default", sourceDefault)
+	}
+
+	token, tokenSource := tokenStatus()
+	add("Token", token, tokenSource)
+
+	for _, spec := range props.GetList("libraries") {
+		info.Libraries = append(info.Libraries, infoLibraryPath(dir, spec))
+	}
+	return info, nil
+}
+
+// strategyTag returns the tag a tag strategy gives without allocating one
+func strategyTag(dir, strategy string) string {
+	switch strategy {
+	case tagStrategyBuild:
+		return "<next build number>"
+	case tagStrategyDate:
+		return "<next dated tag>"
+	case tagStrategyGitSHA:
+		if version.IsGitRepo(dir) {
+			if commit, err := version.GetCommit(dir); err == nil {
+				return commit
+			}
+		}
+	default:
+		if version.IsGitRepo(dir) {
+			if v, err := version.GetFromGit(dir); err == nil {
+				return v.String()
+			}
+		}
+	}
+	return "latest"
+}
+
+// hostSources returns where the API and registry hosts came from, in the order the root
+// command resolves them: --api > LIGHTSPEED_API > config > default
+func hostSources() (string, string) {
+	switch {
+	case apiHostOverride != "":
+		return sourceFlag + ": --api", sourceFlag + ": --api"
+	case os.Getenv("LIGHTSPEED_API") != "":
+		return sourceEnv + ": LIGHTSPEED_API", sourceEnv + ": LIGHTSPEED_API"
+	}
+
+	apiSource := sourceDefault
+	if cliConfig.API != "" {
+		apiSource = sourceConfig + ": api"
+	}
+	registrySource := apiSource
+	if cliConfig.Registry != "" {
+		registrySource = sourceConfig + ": registry"
+	}
+	return apiSource, registrySource
+}
+
+// tokenStatus returns whether there is an access token for the API host and where it comes from,
+// without the token itself
+func tokenStatus() (string, string) {
+	if os.Getenv(tokenEnv) != "" {
+		return "set", sourceEnv + ": " + tokenEnv
+	}
+	if cliConfig.Token != "" && resolveTokenReference(cliConfig.Token) != "" {
+		return "set", sourceConfig + ": token"
+	}
+	if creds, err := loadCredentials(); err == nil && creds.Hosts[apiHost].Token != "" {
+		return "set", "lightspeed login"
+	}
+	return "not set", sourceDefault
+}
+
+// infoLibraryPath resolves a library spec like resolveLibraryPath, without downloading it
+func infoLibraryPath(dir, spec string) infoLibrary {
+	lib := infoLibrary{Spec: spec, Path: spec}
+	switch {
+	case spec == "lightspeed":
+		lib.Path = getLibraryDir()
+	case strings.HasPrefix(spec, "lightspeed:"):
+		lib.Path = getLibraryDirForVersion(strings.TrimPrefix(strings.TrimPrefix(spec, "lightspeed:"), "v"))
+	}
+
+	check := lib.Path
+	if !filepath.IsAbs(check) {
+		check = filepath.Join(dir, check)
+	}
+	_, err := os.Stat(check)
+	lib.Installed = err == nil
+	return lib
+}
+
+func init() {
+	infoCmd.Flags().StringVarP(&infoName, "name", "n", "", "Site name (default: from site.properties or the project directory name)")
+	infoCmd.Flags().StringVarP(&infoTag, "tag", "t", "", "Version tag (default: from tag.strategy, git version or 'latest')")
+	infoCmd.Flags().StringVarP(&infoImage, "image", "i", "", "Base Docker image (default: from site.properties or lightspeed-server)")
+	rootCmd.AddCommand(infoCmd)
+	supportsJSON(infoCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+var ingressSiteName string
+
+var ingressCmd = &cobra.Command{
+	Use:   "ingress",
+	Short: "Manage path routing of a site",
+	Long:  "Routing rules send requests under a path prefix to a component of the site (the site, its service or another component of the app). Rules are set with ingress.<path> in site.properties and applied by deploy and ingress push.",
+}
+
+var ingressListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the site's routing rules",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		_, siteName := ingressSite()
+
+		rules, err := newBackend().GetIngress(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to get routing rules: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Routing rules of '%s'", siteName)
+		fmt.Println()
+		printIngressRules(rules.Rules)
+		fmt.Println()
+	},
+}
+
+var ingressPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Apply the routing rules in site.properties (redeploys the site)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		dir, siteName := ingressSite()
+
+		propsPath := properties.ProjectFile(dir)
+		if !properties.FileExists(propsPath) {
+			ui.PrintError("No site.properties in %s", dir)
+			os.Exit(1)
+		}
+		props, err := properties.ParseFile(propsPath)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+		rules, err := getSiteIngress(props)
+		if err != nil {
+			ui.PrintError("Invalid site.properties: %v", err)
+			os.Exit(1)
+		}
+		if len(rules) == 0 {
+			ui.PrintError("No ingress.<path> rules in site.properties")
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Updating routing of '%s'...", siteName)
+		updated, err := newBackend().SetIngress(cmd.Context(), siteName, rules)
+		if err != nil {
+			ui.PrintError("Failed to update routing rules: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Routing updated, the site is redeploying")
+		fmt.Println()
+		printIngressRules(updated.Rules)
+		fmt.Println()
+		ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+		fmt.Println()
+	},
+}
+
+// ingressSite resolves the project directory and site ingress commands apply to
+func ingressSite() (string, string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, ingressSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return dir, siteName
+}
+
+// printIngressRules prints routing rules as path -> component
+func printIngressRules(rules []api.IngressRule) {
+	for _, rule := range rules {
+		option := ""
+		switch {
+		case rule.PreservePathPrefix:
+			option = ui.Muted(" (prefix preserved)")
+		case rule.Rewrite != "":
+			option = ui.Muted(" (rewritten to " + rule.Rewrite + ")")
+		}
+		fmt.Printf("  %-20s -> %s%s\n", rule.Path, rule.Component, option)
+	}
+}
+
+func init() {
+	ingressCmd.PersistentFlags().StringVarP(&ingressSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	ingressCmd.AddCommand(ingressListCmd)
+	ingressCmd.AddCommand(ingressPushCmd)
+	rootCmd.AddCommand(ingressCmd)
+}
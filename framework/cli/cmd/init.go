@@ -1,24 +1,29 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
 	"lightspeed/core/lib/ui"
 )
 
 var (
-	initName    string
-	initDomains []string
+	initName     string
+	initDomains  []string
+	initTemplate string
+	initImage    string
 )
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a new Lightspeed project",
-	Long:  "Create a new PHP project with basic directory structure and index.php",
+	Long:  "Create a new PHP project with basic directory structure and index.php. Run without flags in a terminal to be asked for the site name, domains, template and PHP version.",
 	Run: func(cmd *cobra.Command, args []string) {
 		ui.PrintHeader(Version)
 
@@ -28,6 +33,18 @@ var initCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		// Ask for the settings when run without flags in a terminal (scripts keep the defaults)
+		propsPath := properties.ProjectFile(dir)
+		if cmd.Flags().NFlag() == 0 && isInteractive() && !properties.FileExists(propsPath) {
+			if err := initWizard(cmd.Context(), dir); err != nil {
+				if interrupted(cmd.Context()) {
+					exitInterrupted("")
+				}
+				ui.PrintError("Failed to read answer: %v", err)
+				os.Exit(1)
+			}
+		}
+
 		// Determine site name
 		siteName := initName
 		if siteName == "" {
@@ -41,6 +58,16 @@ var initCmd = &cobra.Command{
 			domains = []string{siteName + ".com"}
 		}
 
+		// Look up the template (its image becomes the site's base image)
+		var template *api.Template
+		if initTemplate != "" {
+			template, err = newBackend().GetTemplate(cmd.Context(), initTemplate)
+			if err != nil {
+				ui.PrintError("Failed to get template '%s': %v", initTemplate, err)
+				os.Exit(1)
+			}
+		}
+
 		// Track what we create
 		var created []string
 
@@ -114,7 +141,6 @@ h1 {
 		}
 
 		// Create site.properties if it doesn't exist
-		propsPath := filepath.Join(dir, "site.properties")
 		if _, err := os.Stat(propsPath); os.IsNotExist(err) {
 			var propsContent string
 			propsContent = fmt.Sprintf("name=%s\n", siteName)
@@ -123,12 +149,24 @@ h1 {
 			} else {
 				propsContent += fmt.Sprintf("domains=%s\n", strings.Join(domains, ","))
 			}
+			image := initImage
+			if template != nil {
+				propsContent += fmt.Sprintf("template=%s\n", template.Name)
+				if image == "" {
+					image = template.Image
+				}
+			}
+			if image != "" {
+				propsContent += fmt.Sprintf("image=%s\n", image)
+			}
 			propsContent += "libraries=lightspeed\n"
 			if err := os.WriteFile(propsPath, []byte(propsContent), 0644); err != nil {
 				ui.PrintWarning("Failed to create site.properties: %v", err)
 			} else {
 				created = append(created, "site.properties")
 			}
+		} else if template != nil {
+			ui.PrintWarning("%s already exists; add 'template=%s' to it to use the template", filepath.Base(propsPath), template.Name)
 		}
 
 		// Create .idea directory for PhpStorm
@@ -164,12 +202,25 @@ lightspeed
 			}
 		}
 
+		// Create .dockerignore if it doesn't exist, so VCS and IDE files stay out of images
+		dockerignorePath := filepath.Join(dir, ".dockerignore")
+		if _, err := os.Stat(dockerignorePath); os.IsNotExist(err) {
+			if err := os.WriteFile(dockerignorePath, []byte(dockerignoreContent()), 0644); err != nil {
+				ui.PrintWarning("Failed to create .dockerignore: %v", err)
+			} else {
+				created = append(created, ".dockerignore")
+			}
+		}
+
 		// Print success
 		if len(created) > 0 {
 			ui.PrintSuccess("Initialized Lightspeed project")
 			fmt.Println()
 			ui.PrintKeyValue("Name", siteName)
 			ui.PrintKeyValue("Domain", strings.Join(domains, ", "))
+			if template != nil {
+				ui.PrintKeyValue("Template", template.Name)
+			}
 			fmt.Println()
 			ui.PrintInfo("Files created:")
 			for _, f := range created {
@@ -187,9 +238,80 @@ lightspeed
 	},
 }
 
+// initWizard asks for the site name, domains, template and PHP version, setting the init flags
+func initWizard(ctx context.Context, dir string) error {
+	p := newPrompter(ctx)
+	ui.PrintInfo("Set up a new site (press Enter to accept the default)")
+	fmt.Println()
+
+	name, err := p.Ask("Site name", sanitizeContainerName(filepath.Base(dir)))
+	if err != nil {
+		return err
+	}
+	initName = sanitizeContainerName(name)
+
+	domains, err := p.Ask("Domains (comma-separated)", initName+".com")
+	if err != nil {
+		return err
+	}
+	initDomains = nil
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			initDomains = append(initDomains, domain)
+		}
+	}
+
+	// Templates come from the operator; skip the question if it can't be reached
+	var template *api.Template
+	if list, err := newBackend().ListTemplates(ctx); err == nil && len(list.Templates) > 0 {
+		fmt.Println()
+		options := []string{"none"}
+		for _, t := range list.Templates {
+			option := t.Name
+			if t.Description != "" {
+				option += " - " + t.Description
+			}
+			options = append(options, option)
+		}
+		choice, err := p.Choose("Template:", options, 0)
+		if err != nil {
+			return err
+		}
+		if choice > 0 {
+			template = &list.Templates[choice-1]
+			initTemplate = template.Name
+		}
+	}
+
+	// The PHP version comes with the base image; templates choose their own
+	if template == nil || template.Image == "" {
+		fmt.Println()
+		options := []string{
+			fmt.Sprintf("%s - PHP 8.2 with nginx (%s)", "lightspeed", resolveImage("")),
+			"custom - Another version of the lightspeed image or your own PHP image",
+		}
+		choice, err := p.Choose("PHP version (base image):", options, 0)
+		if err != nil {
+			return err
+		}
+		if choice == 1 {
+			image, err := p.Ask("Image (version such as 0.5.4, or image reference)", "")
+			if err != nil {
+				return err
+			}
+			initImage = image
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
+
 func init() {
 	initCmd.Flags().StringVarP(&initName, "name", "n", "", "Site name (default: directory name)")
 	initCmd.Flags().StringSliceVarP(&initDomains, "domain", "d", nil, "Domain(s) for the site (default: name.com)")
+	initCmd.Flags().StringVarP(&initTemplate, "template", "t", "", "Site template from the operator catalog")
+	initCmd.Flags().StringVarP(&initImage, "image", "i", "", "Base image: version of the lightspeed image or image reference (default: CLI version)")
 
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var inspectSiteName string
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [tag]",
+	Short: "Show details of a pushed image",
+	Long:  "Show the manifest details (digest, platforms, layers, size, labels) of a pushed image tag",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		siteName, err := resolveSiteName(dir, inspectSiteName)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		tag := "latest"
+		if len(args) > 0 {
+			tag = args[0]
+		}
+
+		image, err := newBackend().InspectImage(cmd.Context(), siteName, tag)
+		if err != nil {
+			ui.PrintError("Failed to inspect %s:%s: %v", siteName, tag, err)
+			os.Exit(1)
+		}
+
+		platforms := make([]string, 0, len(image.Platforms))
+		for _, p := range image.Platforms {
+			platform := p.OS + "/" + p.Architecture
+			if p.Variant != "" {
+				platform += "/" + p.Variant
+			}
+			platforms = append(platforms, platform)
+		}
+
+		ui.PrintKeyValue("Image", fmt.Sprintf("%s:%s", image.Repository, image.Tag))
+		ui.PrintKeyValue("Digest", image.Digest)
+		ui.PrintKeyValue("Platforms", strings.Join(platforms, ", "))
+		ui.PrintKeyValue("Size", formatBytes(image.Size))
+		if image.Created != "" {
+			ui.PrintKeyValue("Created", image.Created)
+		}
+
+		fmt.Println()
+		ui.PrintInfo("Layers (%d):", len(image.Layers))
+		for _, layer := range image.Layers {
+			fmt.Printf("  %s  %s\n", ui.Muted(shortDigest(layer.Digest)), formatBytes(layer.Size))
+		}
+
+		if len(image.Labels) > 0 {
+			keys := make([]string, 0, len(image.Labels))
+			for key := range image.Labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			fmt.Println()
+			ui.PrintInfo("Labels:")
+			for _, key := range keys {
+				ui.PrintKeyValue("  "+key, image.Labels[key])
+			}
+		}
+		fmt.Println()
+	},
+}
+
+// shortDigest shortens a sha256 digest for display
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+// formatBytes formats a byte count for display
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	inspectCmd.Flags().StringVarP(&inspectSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+	rootCmd.AddCommand(inspectCmd)
+}
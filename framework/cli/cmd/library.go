@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"lightspeed/core/lib/properties"
@@ -219,12 +220,12 @@ func resolveLibraryPath(spec string) (string, error) {
 
 // loadLibraries loads and resolves library paths from site.properties
 func loadLibraries(dir string) ([]string, error) {
-	propsPath := filepath.Join(dir, "site.properties")
+	propsPath := properties.ProjectFile(dir)
 	if _, err := os.Stat(propsPath); os.IsNotExist(err) {
 		return nil, nil
 	}
 
-	props, err := properties.ParseProperties(propsPath)
+	props, err := parseProjectFile(propsPath)
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +257,7 @@ func loadLibraries(dir string) ([]string, error) {
 // updateIdeaConfig updates .idea/php.xml and run configurations with resolved library paths
 func updateIdeaConfig(dir string) error {
 	ideaDir := filepath.Join(dir, ".idea")
-	propsPath := filepath.Join(dir, "site.properties")
+	propsPath := properties.ProjectFile(dir)
 
 	// Only proceed if both .idea and site.properties exist
 	if _, err := os.Stat(ideaDir); os.IsNotExist(err) {
@@ -277,9 +278,10 @@ func updateIdeaConfig(dir string) error {
 	}
 
 	// Generate php.xml content
+	// PhpStorm stores paths with forward slashes on every OS
 	var paths string
 	for _, lib := range libraries {
-		paths += fmt.Sprintf("      <path value=\"%s\" />\n", lib)
+		paths += fmt.Sprintf("      <path value=\"%s\" />\n", filepath.ToSlash(lib))
 	}
 
 	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
@@ -320,8 +322,8 @@ func updateRunConfig(dir string, libraries []string) error {
 
 	// Get site name from site.properties
 	siteName := filepath.Base(dir) // default to directory name
-	propsPath := filepath.Join(dir, "site.properties")
-	if props, err := properties.ParseProperties(propsPath); err == nil {
+	propsPath := properties.ProjectFile(dir)
+	if props, err := properties.ParseFile(propsPath); err == nil {
 		if name := props.Get("name"); name != "" {
 			siteName = name
 		}
@@ -329,14 +331,15 @@ func updateRunConfig(dir string, libraries []string) error {
 	siteName = sanitizeContainerName(siteName)
 
 	// Build include_path from libraries using $USER_HOME$ variable
+	// PHP separates include_path entries with ; on Windows and : elsewhere
 	includePath := "."
 	homeDir, _ := os.UserHomeDir()
 	for _, lib := range libraries {
 		// Convert absolute home path to $USER_HOME$ variable
-		if strings.HasPrefix(lib, homeDir) {
-			lib = "$USER_HOME$" + strings.TrimPrefix(lib, homeDir)
+		if homeDir != "" && hasPathPrefix(lib, homeDir) {
+			lib = "$USER_HOME$" + lib[len(homeDir):]
 		}
-		includePath += ":" + lib
+		includePath += string(os.PathListSeparator) + filepath.ToSlash(lib)
 	}
 
 	content := fmt.Sprintf(`<component name="ProjectRunConfigurationManager">
@@ -357,3 +360,15 @@ func updateRunConfig(dir string, libraries []string) error {
 
 	return os.WriteFile(runConfigPath, []byte(content), 0644)
 }
+
+// hasPathPrefix checks if a path starts with a prefix, ignoring case on Windows
+// (where C:\Users and c:\users are the same directory)
+func hasPathPrefix(path, prefix string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(path[:len(prefix)], prefix)
+	}
+	return path[:len(prefix)] == prefix
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	loadtestRPS         int
+	loadtestDuration    time.Duration
+	loadtestPaths       []string
+	loadtestConcurrency int
+	loadtestTimeout     time.Duration
+	loadtestForce       bool
+)
+
+// loadtestResult is the outcome of a single request
+type loadtestResult struct {
+	Path    string
+	Status  int // 0 when the request failed
+	Latency time.Duration
+}
+
+// loadtestReport summarizes a load test
+type loadtestReport struct {
+	Results  []loadtestResult
+	Skipped  int // Requests not sent because the concurrency limit was reached
+	Elapsed  time.Duration
+	Statuses map[int]int
+	Failed   int // Network errors and timeouts
+	Errors   int // Failed requests and HTTP 5xx responses
+}
+
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest [name|url]",
+	Short: "Generate load against a preview or staging site",
+	Long:  "Send requests at a fixed rate to a site and report latency percentiles and error rates, to right-size instance counts before promoting. Targets the project's site by default; production sites are refused without --force.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+
+		if loadtestRPS <= 0 {
+			ui.PrintError("--rps must be at least 1")
+			os.Exit(1)
+		}
+		if loadtestDuration <= 0 {
+			ui.PrintError("--duration must be positive")
+			os.Exit(1)
+		}
+		if loadtestConcurrency <= 0 {
+			ui.PrintError("--concurrency must be at least 1")
+			os.Exit(1)
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		var props properties.Properties
+		if propsPath := properties.ProjectFile(dir); properties.FileExists(propsPath) {
+			if props, err = properties.ParseFile(propsPath); err != nil {
+				ui.PrintError("Failed to load site.properties: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		target := ""
+		if len(args) > 0 {
+			target = args[0]
+		}
+
+		var site *api.SiteResponse
+		baseURL, environment := "", ""
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			if err := loadtestTarget(target); err != nil {
+				ui.PrintError("%v", err)
+				os.Exit(1)
+			}
+			baseURL = strings.TrimRight(target, "/")
+			// Only the project's own site has a known environment
+			environment = siteEnvironment(nil)
+		} else {
+			siteName, err := resolveSiteName(dir, target)
+			if err != nil {
+				ui.PrintError("Failed to load site.properties: %v", err)
+				os.Exit(1)
+			}
+			projectName, _ := resolveSiteName(dir, "")
+			if target == "" || target == projectName {
+				environment = siteEnvironment(props)
+			} else {
+				environment = siteEnvironment(nil)
+			}
+
+			site, err = newBackend().GetSiteStatus(ctx, siteName)
+			if err != nil {
+				ui.PrintError("Failed to get status of '%s': %v", siteName, err)
+				os.Exit(1)
+			}
+			domain := site.Domain
+			if domain == "" {
+				domain = siteName + ".lightspeed.ee"
+			}
+			baseURL = "https://" + domain
+		}
+
+		// The project's custom domains serve production, whatever the environment says
+		host := hostOf(baseURL)
+		for _, domain := range append([]string{props.Get("domain")}, props.GetList("domains")...) {
+			if domain != "" && strings.EqualFold(host, domain) {
+				environment = productionEnvironment
+			}
+		}
+
+		if environment == productionEnvironment && !loadtestForce {
+			ui.PrintError("%s is a production site", baseURL)
+			ui.PrintInfo("Load test a preview or staging site (environment in site.properties or %s), or use --force", environmentEnv)
+			os.Exit(1)
+		}
+
+		paths := loadtestPaths
+		if len(paths) == 0 {
+			paths = []string{"/"}
+		}
+		for i, path := range paths {
+			if !strings.HasPrefix(path, "/") {
+				paths[i] = "/" + path
+			}
+		}
+
+		ui.PrintKeyValue("Target", baseURL)
+		ui.PrintKeyValue("Environment", environment)
+		ui.PrintKeyValue("Paths", strings.Join(paths, ", "))
+		ui.PrintKeyValue("Rate", fmt.Sprintf("%d requests/s for %v (up to %d in flight)", loadtestRPS, loadtestDuration, loadtestConcurrency))
+		if site != nil && site.Instances > 0 {
+			ui.PrintKeyValue("Instances", fmt.Sprintf("%d x %s", site.Instances, site.Size))
+		}
+		fmt.Println()
+		ui.PrintInfo("Running load test (Ctrl-C to stop early)...")
+
+		report := runLoadTest(ctx, baseURL, paths, loadtestRPS, loadtestDuration, loadtestConcurrency, loadtestTimeout)
+		fmt.Println()
+		if interrupted(ctx) {
+			ui.PrintWarning("Stopped early; results cover %v", report.Elapsed.Round(time.Second))
+			fmt.Println()
+		}
+		printLoadTestReport(report)
+
+		// More than 1% errors or skipped requests mean the site is saturated
+		if (len(report.Results) > 0 && report.Errors*100 > len(report.Results)) || report.Skipped > 0 {
+			fmt.Println()
+			ui.PrintWarning("The site didn't keep up with %d requests/s", loadtestRPS)
+			if site != nil {
+				ui.PrintInfo("Add instances or use a larger size with: lightspeed scale %s", site.Name)
+			}
+		}
+	},
+}
+
+// runLoadTest sends requests to the paths in turn at a fixed rate until the duration is up
+// Requests are sent on schedule whether or not earlier ones returned (open loop), so a slow
+// site shows up as latency and skipped requests rather than a lower request rate.
+func runLoadTest(ctx context.Context, baseURL string, paths []string, rps int, duration time.Duration, concurrency int, timeout time.Duration) *loadtestReport {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: concurrency,
+			Proxy:               http.ProxyFromEnvironment,
+		},
+	}
+
+	report := &loadtestReport{Statuses: make(map[int]int)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, concurrency)
+
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+
+	start := time.Now()
+	for sent := 0; ; sent++ {
+		select {
+		case <-ctx.Done():
+		case <-deadline.C:
+		case <-ticker.C:
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				report.Skipped++
+				continue
+			}
+
+			path := paths[sent%len(paths)]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+
+				result := loadtestRequest(ctx, client, baseURL, path)
+				if result.Status == 0 && ctx.Err() != nil {
+					// Cut off by Ctrl-C, not a failure of the site
+					return
+				}
+				mu.Lock()
+				report.Results = append(report.Results, result)
+				mu.Unlock()
+			}()
+			continue
+		}
+		break
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+
+	for _, result := range report.Results {
+		switch {
+		case result.Status == 0:
+			report.Failed++
+			report.Errors++
+		case result.Status >= http.StatusInternalServerError:
+			report.Errors++
+		}
+		if result.Status != 0 {
+			report.Statuses[result.Status]++
+		}
+	}
+	return report
+}
+
+// loadtestRequest sends a single request, reading the whole response like a browser would
+func loadtestRequest(ctx context.Context, client *http.Client, baseURL, path string) loadtestResult {
+	result := loadtestResult{Path: path}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return result
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Loadtest/1.0")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	result.Latency = time.Since(start)
+	return result
+}
+
+// printLoadTestReport prints request counts, error rates and latency percentiles overall and per path
+func printLoadTestReport(report *loadtestReport) {
+	total := len(report.Results)
+	ui.PrintKeyValue("Requests", fmt.Sprintf("%d in %v (%.1f/s)", total, report.Elapsed.Round(100*time.Millisecond), float64(total)/report.Elapsed.Seconds()))
+	if total == 0 {
+		return
+	}
+
+	ui.PrintKeyValue("Errors", fmt.Sprintf("%d (%.1f%%; %d failed, %d HTTP 5xx)", report.Errors, percent(report.Errors, total), report.Failed, report.Errors-report.Failed))
+	if report.Skipped > 0 {
+		ui.PrintKeyValue("Skipped", fmt.Sprintf("%d (concurrency limit of %d reached)", report.Skipped, loadtestConcurrency))
+	}
+
+	codes := make([]int, 0, len(report.Statuses))
+	for code := range report.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var statuses []string
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d x%d", code, report.Statuses[code]))
+	}
+	if len(statuses) > 0 {
+		ui.PrintKeyValue("Statuses", strings.Join(statuses, ", "))
+	}
+
+	latencies := loadtestLatencies(report.Results, "")
+	if len(latencies) == 0 {
+		return
+	}
+	fmt.Println()
+	ui.PrintKeyValue("Latency", formatLatencies(latencies))
+
+	paths := make(map[string]bool)
+	for _, result := range report.Results {
+		paths[result.Path] = true
+	}
+	if len(paths) < 2 {
+		return
+	}
+	names := make([]string, 0, len(paths))
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+	for _, path := range names {
+		if latencies := loadtestLatencies(report.Results, path); len(latencies) > 0 {
+			ui.PrintKeyValue("  "+path, formatLatencies(latencies))
+		}
+	}
+}
+
+// loadtestLatencies returns the sorted latencies of the requests that got a response,
+// for one path or all of them
+func loadtestLatencies(results []loadtestResult, path string) []time.Duration {
+	var latencies []time.Duration
+	for _, result := range results {
+		if result.Status != 0 && (path == "" || result.Path == path) {
+			latencies = append(latencies, result.Latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+// formatLatencies formats the percentiles of sorted latencies
+func formatLatencies(latencies []time.Duration) string {
+	format := func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("p50 %s, p90 %s, p95 %s, p99 %s, max %s",
+		format(percentile(latencies, 50)),
+		format(percentile(latencies, 90)),
+		format(percentile(latencies, 95)),
+		format(percentile(latencies, 99)),
+		format(latencies[len(latencies)-1]))
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func percent(n, total int) float64 {
+	return float64(n) / float64(total) * 100
+}
+
+// loadtestTarget validates a target URL
+func loadtestTarget(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", raw)
+	}
+	return nil
+}
+
+func init() {
+	loadtestCmd.Flags().IntVar(&loadtestRPS, "rps", 10, "Requests per second")
+	loadtestCmd.Flags().DurationVarP(&loadtestDuration, "duration", "d", 30*time.Second, "How long to send requests")
+	loadtestCmd.Flags().StringSliceVar(&loadtestPaths, "path", nil, "Path to request, repeatable; requests cycle through the paths (default: /)")
+	loadtestCmd.Flags().IntVar(&loadtestConcurrency, "concurrency", 50, "Maximum requests in flight; requests over it are skipped")
+	loadtestCmd.Flags().DurationVar(&loadtestTimeout, "timeout", 10*time.Second, "Timeout of each request")
+	loadtestCmd.Flags().BoolVar(&loadtestForce, "force", false, "Allow load testing a production site")
+
+	rootCmd.AddCommand(loadtestCmd)
+}
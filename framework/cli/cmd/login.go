@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	loginToken     string
+	loginWithToken bool
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log the CLI in to the Lightspeed operator",
+	Long:  "Log in with a code approved in the browser, or with an access token issued by the operator admin (--token, or --with-token to read it from stdin). The token is saved in ~/.lightspeed/credentials and sent with every site and registry request.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+
+		token := loginToken
+		if loginWithToken {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				ui.PrintError("No token given on stdin")
+				os.Exit(1)
+			}
+			token = strings.TrimSpace(line)
+		}
+
+		var err error
+		if token == "" {
+			token, err = deviceLogin(ctx)
+			if err != nil {
+				if interrupted(ctx) {
+					exitInterrupted("")
+				}
+				ui.PrintError("Login failed: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		// Check the token before saving it
+		identity, err := newOperatorBackend(getAPIURL(), token).WhoAmI(ctx)
+		if err != nil {
+			ui.PrintError("Token rejected by %s: %v", apiHost, err)
+			os.Exit(1)
+		}
+
+		if err := saveLogin(token, identity); err != nil {
+			ui.PrintError("Failed to save credentials: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Logged in to %s as %s", apiHost, identity.Name)
+		fmt.Println()
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log the CLI out and revoke its access token",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		creds, err := loadCredentials()
+		if err != nil {
+			ui.PrintError("Failed to read credentials: %v", err)
+			os.Exit(1)
+		}
+		saved, ok := creds.Hosts[apiHost]
+		if !ok {
+			ui.PrintInfo("Not logged in to %s", apiHost)
+			fmt.Println()
+			return
+		}
+
+		if err := newOperatorBackend(getAPIURL(), saved.Token).Logout(cmd.Context()); err != nil {
+			ui.PrintWarning("Failed to revoke the access token: %v", err)
+		}
+
+		delete(creds.Hosts, apiHost)
+		if err := saveCredentials(creds); err != nil {
+			ui.PrintError("Failed to save credentials: %v", err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Logged out of %s", apiHost)
+		fmt.Println()
+	},
+}
+
+// deviceLogin runs the device code flow: the code is approved in the browser while
+// the operator is polled for the token
+func deviceLogin(ctx context.Context) (string, error) {
+	backend := newOperatorBackend(getAPIURL(), "")
+	code, err := backend.StartLogin(ctx, loginName())
+	if err != nil {
+		return "", err
+	}
+
+	ui.PrintInfo("Approve this login in your browser:")
+	fmt.Println()
+	ui.PrintKeyValue("  URL", code.VerificationURL)
+	ui.PrintKeyValue("  Code", code.UserCode)
+	fmt.Println()
+	openBrowser(code.VerificationURLComplete)
+	ui.PrintInfo("Waiting for approval...")
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+
+		token, err := backend.PollLogin(ctx, code.DeviceCode)
+		if errors.Is(err, errLoginPending) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return token.Token, nil
+	}
+}
+
+// saveLogin saves the access token for the API host
+func saveLogin(token string, identity *api.AccessToken) error {
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	creds.Hosts[apiHost] = credential{
+		Token:     token,
+		Name:      identity.Name,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	return saveCredentials(creds)
+}
+
+// loginName names the token after the user and machine, so the admin can tell tokens apart
+func loginName() string {
+	name := "cli"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		name += "@" + hostname
+	}
+	return name
+}
+
+func init() {
+	loginCmd.Flags().StringVar(&loginToken, "token", "", "Access token issued by the operator admin")
+	loginCmd.Flags().BoolVar(&loginWithToken, "with-token", false, "Read the access token from stdin")
+
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	logsSiteName string
+	logsType     string
+	logsTail     int
+	logsFollow   bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show logs of a deployed site",
+	Long:  "Show the runtime, build or deploy logs of a deployed site, streamed through the operator",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+
+		if logsType != "run" && logsType != "build" && logsType != "deploy" {
+			ui.PrintError("Invalid log type '%s': use run, build or deploy", logsType)
+			os.Exit(1)
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		siteName, err := resolveSiteName(dir, logsSiteName)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		stream, err := newBackend().StreamLogs(ctx, siteName, LogOptions{
+			Type:   logsType,
+			Tail:   logsTail,
+			Follow: logsFollow,
+		})
+		if err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("")
+			}
+			ui.PrintError("Failed to get logs of '%s': %v", siteName, err)
+			os.Exit(1)
+		}
+		defer stream.Close()
+
+		ui.PrintInfo("%s logs of '%s'", logsType, siteName)
+		fmt.Println()
+
+		if _, err := io.Copy(os.Stdout, stream); err != nil && !interrupted(ctx) {
+			ui.PrintError("Log stream ended: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	logsCmd.Flags().StringVarP(&logsSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+	logsCmd.Flags().StringVarP(&logsType, "type", "t", "run", "Logs to show: run, build or deploy")
+	logsCmd.Flags().IntVar(&logsTail, "tail", 100, "Number of existing lines to show (0 for all)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep streaming new log lines")
+
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats of --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// jsonOutputAnnotation marks the commands that support --output json
+const jsonOutputAnnotation = "lightspeed.json-output"
+
+var outputFormat string
+
+// jsonStdout is the real stdout in JSON mode, where os.Stdout is pointed at stderr
+var jsonStdout *os.File
+
+// setupOutput validates --output for a command
+// In JSON mode the styled progress output moves to stderr, so stdout only carries the result
+func setupOutput(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputText:
+		return nil
+	case outputJSON:
+	default:
+		return fmt.Errorf("unknown output format '%s' (use %s or %s)", outputFormat, outputText, outputJSON)
+	}
+	if cmd.Annotations[jsonOutputAnnotation] == "" {
+		return fmt.Errorf("'lightspeed %s' doesn't support --output json", cmd.Name())
+	}
+
+	jsonStdout = os.Stdout
+	os.Stdout = os.Stderr
+	return nil
+}
+
+// jsonOutput checks if the command's result is printed as JSON
+func jsonOutput() bool {
+	return jsonStdout != nil
+}
+
+// printJSON prints a command's result as JSON to stdout
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(jsonStdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// supportsJSON marks commands whose results can be printed with --output json
+func supportsJSON(commands ...*cobra.Command) {
+	for _, cmd := range commands {
+		if cmd.Annotations == nil {
+			cmd.Annotations = map[string]string{}
+		}
+		cmd.Annotations[jsonOutputAnnotation] = "true"
+	}
+}
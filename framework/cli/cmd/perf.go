@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// perfHistory is how many performance measurements are kept per project
+const perfHistory = 20
+
+// Limits of the performance probe, so a page with many assets doesn't stall a deploy
+const (
+	perfMaxResources = 100
+	perfConcurrency  = 6
+	perfTimeout      = 30 * time.Second
+)
+
+var (
+	// perfSrcPattern matches the resources a page loads through src attributes
+	perfSrcPattern = regexp.MustCompile(`(?is)<(?:script|img|iframe|source|video|audio)\b[^>]*?\ssrc\s*=\s*["']([^"']+)["']`)
+	// perfLinkPattern matches link tags, whose rel decides if the href is loaded
+	perfLinkPattern = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	perfRelPattern  = regexp.MustCompile(`(?is)\srel\s*=\s*["']([^"']+)["']`)
+	perfHrefPattern = regexp.MustCompile(`(?is)\shref\s*=\s*["']([^"']+)["']`)
+)
+
+// perfLoadedRels are the link relations browsers load while rendering a page
+var perfLoadedRels = []string{"stylesheet", "icon", "preload", "modulepreload", "manifest"}
+
+// perfBudget is the performance budget from site.properties (zero values are unchecked)
+type perfBudget struct {
+	TTFB     time.Duration // perf.ttfb
+	Weight   int64         // perf.weight, total bytes of the page and its resources
+	Requests int           // perf.requests, the page and its resources
+	Fail     bool          // perf.fail, fail the deploy instead of warning
+}
+
+// perfMeasurement is the result of a performance probe, kept in the project state for the trend
+type perfMeasurement struct {
+	URL        string `json:"url"`
+	Tag        string `json:"tag,omitempty"`
+	TTFBMs     int64  `json:"ttfb_ms"`
+	Weight     int64  `json:"weight"`
+	Requests   int    `json:"requests"`
+	MeasuredAt string `json:"measured_at"`
+}
+
+// Enabled checks if any budget is set
+func (b perfBudget) Enabled() bool {
+	return b.TTFB > 0 || b.Weight > 0 || b.Requests > 0
+}
+
+// Exceeded lists the budgets a measurement is over
+func (b perfBudget) Exceeded(m *perfMeasurement) []string {
+	var exceeded []string
+	if ttfb := time.Duration(m.TTFBMs) * time.Millisecond; b.TTFB > 0 && ttfb > b.TTFB {
+		exceeded = append(exceeded, fmt.Sprintf("TTFB %v over %v", ttfb, b.TTFB))
+	}
+	if b.Weight > 0 && m.Weight > b.Weight {
+		exceeded = append(exceeded, fmt.Sprintf("page weight %s over %s", formatBytes(m.Weight), formatBytes(b.Weight)))
+	}
+	if b.Requests > 0 && m.Requests > b.Requests {
+		exceeded = append(exceeded, fmt.Sprintf("%d requests over %d", m.Requests, b.Requests))
+	}
+	return exceeded
+}
+
+// getPerfBudget reads the performance budget from site.properties
+func getPerfBudget(props properties.Properties) (perfBudget, error) {
+	var budget perfBudget
+
+	if value := props.Get("perf.ttfb"); value != "" {
+		ttfb, err := time.ParseDuration(value)
+		if err != nil || ttfb <= 0 {
+			return budget, fmt.Errorf("perf.ttfb %q must be a duration such as 800ms", value)
+		}
+		budget.TTFB = ttfb
+	}
+	if value := props.Get("perf.weight"); value != "" {
+		weight, err := parseSize(value)
+		if err != nil {
+			return budget, fmt.Errorf("perf.weight %q must be a size such as 500KB or 2MB", value)
+		}
+		budget.Weight = weight
+	}
+	if value := props.Get("perf.requests"); value != "" {
+		requests, err := strconv.Atoi(value)
+		if err != nil || requests <= 0 {
+			return budget, fmt.Errorf("perf.requests %q must be a positive number", value)
+		}
+		budget.Requests = requests
+	}
+	budget.Fail = props.GetBool("perf.fail")
+
+	return budget, nil
+}
+
+// parseSize parses a byte size such as 512, 500KB or 1.5MB (binary units)
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// measurePerf loads a page and the resources it references, measuring time to first byte
+// of the page, the total bytes transferred and the number of requests
+func measurePerf(ctx context.Context, pageURL string) (*perfMeasurement, error) {
+	ctx, cancel := context.WithTimeout(ctx, perfTimeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: perfTimeout}
+
+	var start, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Perf/1.0")
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s returned HTTP %d", pageURL, resp.StatusCode)
+	}
+
+	m := &perfMeasurement{
+		URL:        pageURL,
+		TTFBMs:     firstByte.Sub(start).Milliseconds(),
+		Weight:     int64(len(page)),
+		Requests:   1,
+		MeasuredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, perfConcurrency)
+	for _, resource := range pageResources(resp.Request.URL, string(page)) {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(resource string) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			size, err := fetchSize(ctx, client, resource)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			m.Weight += size
+			m.Requests++
+			mu.Unlock()
+		}(resource)
+	}
+	wg.Wait()
+
+	return m, nil
+}
+
+// pageResources returns the absolute URLs of the scripts, stylesheets, images and other
+// resources a page references
+func pageResources(base *url.URL, html string) []string {
+	var refs []string
+	for _, match := range perfSrcPattern.FindAllStringSubmatch(html, -1) {
+		refs = append(refs, match[1])
+	}
+	for _, tag := range perfLinkPattern.FindAllString(html, -1) {
+		rel := perfRelPattern.FindStringSubmatch(tag)
+		href := perfHrefPattern.FindStringSubmatch(tag)
+		if rel == nil || href == nil {
+			continue
+		}
+		for _, loaded := range perfLoadedRels {
+			if strings.Contains(strings.ToLower(rel[1]), loaded) {
+				refs = append(refs, href[1])
+				break
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(refs))
+	resources := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		if seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		resources = append(resources, u.String())
+		if len(resources) == perfMaxResources {
+			break
+		}
+	}
+	return resources
+}
+
+// fetchSize downloads a resource and returns its size
+func fetchSize(ctx context.Context, client *http.Client, resource string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resource, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Perf/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(io.Discard, resp.Body)
+}
+
+// recordPerf adds a measurement to the project's performance trend and returns the previous one
+func recordPerf(dir string, m *perfMeasurement) (*perfMeasurement, error) {
+	state, err := loadProjectState(dir)
+	if err != nil {
+		state = &projectState{}
+	}
+
+	var previous *perfMeasurement
+	if n := len(state.Perf); n > 0 {
+		p := state.Perf[n-1]
+		previous = &p
+	}
+
+	state.Perf = append(state.Perf, *m)
+	if len(state.Perf) > perfHistory {
+		state.Perf = state.Perf[len(state.Perf)-perfHistory:]
+	}
+	return previous, saveProjectState(dir, state)
+}
+
+// printPerf prints a measurement with the change since the previous one
+func printPerf(out *ui.Output, m, previous *perfMeasurement) {
+	ttfb := fmt.Sprintf("%dms", m.TTFBMs)
+	weight := formatBytes(m.Weight)
+	requests := strconv.Itoa(m.Requests)
+	if previous != nil {
+		ttfb += ui.Muted(fmt.Sprintf(" (%+dms)", m.TTFBMs-previous.TTFBMs))
+		weight += ui.Muted(fmt.Sprintf(" (%s%s)", sign(m.Weight-previous.Weight), formatBytes(abs64(m.Weight-previous.Weight))))
+		requests += ui.Muted(fmt.Sprintf(" (%+d)", m.Requests-previous.Requests))
+	}
+	out.PrintKeyValue("  TTFB", ttfb)
+	out.PrintKeyValue("  Weight", weight)
+	out.PrintKeyValue("  Requests", requests)
+}
+
+func sign(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// deployStep is a named stage of the deploy pipeline
+// Steps share state through deployState, so the pipeline can skip steps or resume
+// from any step as long as later steps don't rely on state only an earlier step sets
+type deployStep struct {
+	Name string
+	Run  func(ctx context.Context, d *deployState) error
+}
+
+// pipelineError is returned when a pipeline step fails
+type pipelineError struct {
+	Step string
+	Err  error
+}
+
+func (e *pipelineError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+func (e *pipelineError) Unwrap() error {
+	return e.Err
+}
+
+// pipeline runs deploy steps in order, timing each one
+type pipeline struct {
+	steps   []deployStep
+	skip    map[string]bool
+	from    string
+	timings []stepTiming
+}
+
+// stepTiming records how long a step took (zero if skipped)
+type stepTiming struct {
+	Name     string
+	Duration time.Duration
+	Skipped  bool
+}
+
+// newPipeline creates a pipeline of steps
+func newPipeline(steps ...deployStep) *pipeline {
+	return &pipeline{steps: steps, skip: map[string]bool{}}
+}
+
+// Names returns the step names in order
+func (p *pipeline) Names() []string {
+	names := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+// Skip skips the named steps
+func (p *pipeline) Skip(names ...string) error {
+	for _, name := range names {
+		if !p.has(name) {
+			return fmt.Errorf("unknown step '%s' (steps: %s)", name, strings.Join(p.Names(), ", "))
+		}
+		p.skip[name] = true
+	}
+	return nil
+}
+
+// From resumes the pipeline at the named step, skipping the steps before it
+func (p *pipeline) From(name string) error {
+	if name != "" && !p.has(name) {
+		return fmt.Errorf("unknown step '%s' (steps: %s)", name, strings.Join(p.Names(), ", "))
+	}
+	p.from = name
+	return nil
+}
+
+// Runs checks if the named step would run, given the skipped steps and the step to resume at
+func (p *pipeline) Runs(name string) bool {
+	if p.skip[name] {
+		return false
+	}
+	started := p.from == ""
+	for _, step := range p.steps {
+		if step.Name == p.from {
+			started = true
+		}
+		if step.Name == name {
+			return started
+		}
+	}
+	return false
+}
+
+// Run runs the steps in order, stopping at the first failure
+// Failures are returned as a *pipelineError naming the step
+func (p *pipeline) Run(ctx context.Context, state *deployState) error {
+	started := p.from == ""
+	for _, step := range p.steps {
+		if step.Name == p.from {
+			started = true
+		}
+		if !started || p.skip[step.Name] {
+			p.timings = append(p.timings, stepTiming{Name: step.Name, Skipped: true})
+			continue
+		}
+
+		start := time.Now()
+		err := step.Run(ctx, state)
+		p.timings = append(p.timings, stepTiming{Name: step.Name, Duration: time.Since(start)})
+		if err != nil {
+			return &pipelineError{Step: step.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// PrintTimings prints how long each step took
+func (p *pipeline) PrintTimings() {
+	var total time.Duration
+	for _, timing := range p.timings {
+		if timing.Skipped {
+			fmt.Println(ui.Muted(fmt.Sprintf("  %-12s skipped", timing.Name)))
+			continue
+		}
+		total += timing.Duration
+		fmt.Printf("  %-12s %v\n", timing.Name, timing.Duration.Round(100*time.Millisecond))
+	}
+	fmt.Printf("  %-12s %v\n", "total", total.Round(100*time.Millisecond))
+}
+
+func (p *pipeline) has(name string) bool {
+	for _, step := range p.steps {
+		if step.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deployState is the state shared by the deploy pipeline steps
+type deployState struct {
+	Dir       string
+	Site      api.Site
+	SiteImage string   // Base image for the Dockerfile
+	Images    []string // Tags built and pushed
+	Registry  string
+	Resolvers []string
+	SLOTarget float64 // Availability target from site.properties (0 for the operator default)
+	Perf      perfBudget
+	Hooks     deployHooks
+	Edge      *api.SiteEdge     // Edge mode settings from site.properties (nil to leave them alone)
+	Firewall  *api.SiteFirewall // IP rules from site.properties (nil to leave them alone)
+	Cron      []api.CronJob     // Cron jobs from site.properties (nil to leave them alone)
+	Backend   Backend
+
+	Daemonless    bool        // Build and push without Docker
+	MultiPlatform bool        // Docker builds for several platforms, which push the images
+	Built         *builtImage // Image built without Docker, pushed by the push step
+	Pushed        bool        // Images were pushed by the build step
+
+	Created bool   // Site was created by this deploy
+	Domain  string // Domain allocated when the site was created
+	URL     string
+}
+
+// deploySteps are the steps of lightspeed deploy
+var deploySteps = []deployStep{
+	{Name: "build", Run: deployBuild},
+	{Name: "push", Run: deployPush},
+	{Name: "ensure-site", Run: deployEnsureSite},
+	{Name: "wait-deploy", Run: deployWait},
+	{Name: "verify", Run: deployVerify},
+	{Name: "perf", Run: deployPerf},
+	{Name: "hooks", Run: deployRunHooks},
+	{Name: "open", Run: deployOpen},
+}
+
+// deployBuild builds the site image with all of its tags
+// Multi-platform Docker builds log in first and push the images
+func deployBuild(ctx context.Context, d *deployState) error {
+	switch {
+	case d.Daemonless:
+		ui.PrintInfo("Building image without Docker...")
+		built, err := buildImageDaemonless(ctx, d.Dir, d.SiteImage)
+		if err != nil {
+			return err
+		}
+		d.Built = built
+	case d.MultiPlatform:
+		if err := deployLogin(ctx, d); err != nil {
+			return err
+		}
+		ui.PrintInfo("Building and pushing Docker images...")
+		if err := buildDockerImage(ctx, d.Dir, d.SiteImage, d.Images); err != nil {
+			return err
+		}
+		d.Pushed = true
+	default:
+		ui.PrintInfo("Building Docker image...")
+		if err := buildDockerImage(ctx, d.Dir, d.SiteImage, d.Images); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	ui.PrintSuccess("Built image: %s", d.Images[0])
+	fmt.Println()
+	return nil
+}
+
+// deployLogin logs docker in to the registry and waits while registry writes are blocked
+// by garbage collection
+func deployLogin(ctx context.Context, d *deployState) error {
+	ui.PrintInfo("Logging in to registry...")
+	if err := dockerLogin(ctx, d.Registry); err != nil {
+		return fmt.Errorf("failed to login to registry: %w", err)
+	}
+	return waitForRegistryWrites(ctx, ui.Stdout, d.Backend)
+}
+
+// deployPush logs in to the registry and pushes the built tags
+// Images built without Docker only exist in memory, and multi-platform Docker images are pushed
+// by the build, so for those the push step only works in the same run as the build step
+func deployPush(ctx context.Context, d *deployState) error {
+	switch {
+	case d.Daemonless:
+		if d.Built == nil {
+			return fmt.Errorf("nothing to push: images built without Docker are pushed in the same run as the build step")
+		}
+		defer d.Built.Remove()
+
+		// Registry writes are blocked during garbage collection
+		if err := waitForRegistryWrites(ctx, ui.Stdout, d.Backend); err != nil {
+			return err
+		}
+		ui.PrintInfo("Pushing image...")
+		if err := pushImageDaemonless(ctx, d.Built, d.Images); err != nil {
+			return err
+		}
+	case d.MultiPlatform:
+		if !d.Pushed {
+			return fmt.Errorf("nothing to push: multi-platform images are pushed by the build step")
+		}
+	default:
+		if err := deployLogin(ctx, d); err != nil {
+			return err
+		}
+		ui.PrintInfo("Pushing images...")
+		for _, image := range d.Images {
+			if err := pushImage(ctx, image); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := recordPublished(d.Dir, d.Site.Name, d.Registry, d.Site.Tag, d.Site.Commit, d.Images); err != nil {
+		ui.PrintWarning("Failed to save project state: %v", err)
+	}
+
+	fmt.Println()
+	ui.PrintSuccess("Pushed %s", d.Images[0])
+	fmt.Println()
+	return nil
+}
+
+// deployEnsureSite creates the site if it doesn't exist yet
+func deployEnsureSite(ctx context.Context, d *deployState) error {
+	warnErrorBudget(ctx, ui.Stdout, d.Backend, d.Site.Name, d.SLOTarget)
+
+	created, domain, err := ensureSite(ctx, ui.Stdout, d.Backend, d.Site)
+	if err != nil {
+		return err
+	}
+	d.Created = created
+	d.Domain = domain
+	syncChecks(ctx, ui.Stdout, d.Backend, d.Dir, d.Site.Name)
+	if err := syncEdge(ctx, ui.Stdout, d.Backend, d.Site.Name, d.Edge); err != nil {
+		return err
+	}
+	if err := syncFirewall(ctx, ui.Stdout, d.Backend, d.Site.Name, d.Firewall); err != nil {
+		return err
+	}
+	syncCron(ctx, ui.Stdout, d.Backend, d.Site.Name, d.Cron)
+	fmt.Println()
+	return nil
+}
+
+// deployWait waits for the site's deployment to complete
+// When resumed at this step the site already exists, so it waits for a redeploy
+func deployWait(ctx context.Context, d *deployState) error {
+	if err := waitForRelease(ctx, ui.Stdout, d.Backend, d.Site.Name, d.Created); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
+// deployVerify waits for the site and its custom domains to respond
+func deployVerify(ctx context.Context, d *deployState) error {
+	d.URL = siteURLFor(ctx, d.Backend, d.Site.Name, d.Domain)
+	if err := verifySite(ctx, ui.Stdout, d.URL, d.Site.Domains, d.Resolvers); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
+// deployPerf measures the site's performance against the budget in site.properties
+// Exceeded budgets fail the deploy with perf.fail, otherwise they're a warning
+func deployPerf(ctx context.Context, d *deployState) error {
+	if d.URL == "" {
+		d.URL = siteURLFor(ctx, d.Backend, d.Site.Name, d.Domain)
+	}
+
+	ui.PrintInfo("Measuring performance...")
+	m, err := measurePerf(ctx, d.URL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		ui.PrintWarning("Performance not measured: %v", err)
+		fmt.Println()
+		return nil
+	}
+	m.Tag = d.Site.Tag
+
+	previous, err := recordPerf(d.Dir, m)
+	if err != nil {
+		ui.PrintWarning("Failed to save performance trend: %v", err)
+	}
+	printPerf(ui.Stdout, m, previous)
+
+	exceeded := d.Perf.Exceeded(m)
+	if len(exceeded) == 0 {
+		ui.PrintSuccess("Within performance budget")
+		fmt.Println()
+		return nil
+	}
+	if d.Perf.Fail {
+		return fmt.Errorf("performance budget exceeded: %s", strings.Join(exceeded, ", "))
+	}
+	ui.PrintWarning("Performance budget exceeded: %s", strings.Join(exceeded, ", "))
+	fmt.Println()
+	return nil
+}
+
+// deployRunHooks runs the post-deploy hooks in site.properties
+func deployRunHooks(ctx context.Context, d *deployState) error {
+	if d.URL == "" {
+		d.URL = siteURLFor(ctx, d.Backend, d.Site.Name, d.Domain)
+	}
+
+	ui.PrintInfo("Running deploy hooks...")
+	runDeployHooks(ctx, ui.Stdout, d.Backend, d.Hooks, d.Dir, d.Site.Name, hooksSiteURL(d.URL, d.Site.Domains))
+	fmt.Println()
+	return ctx.Err()
+}
+
+// deployOpen opens the site in the browser
+func deployOpen(ctx context.Context, d *deployState) error {
+	if d.URL == "" {
+		d.URL = siteURLFor(ctx, d.Backend, d.Site.Name, d.Domain)
+	}
+	ui.PrintInfo("Opening browser...")
+	openBrowser(d.URL)
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+// defaultBuildPlatform is the platform App Platform runs, which every image must include
+const defaultBuildPlatform = "linux/amd64"
+
+// multiPlatformBuilder is the buildx builder of multi-platform builds
+// Docker's default builder can't put several platforms in one image, so a docker-container
+// builder is created the first time one is needed
+const multiPlatformBuilder = "lightspeed-multiarch"
+
+// buildPlatform is the comma-separated platform list of build, publish and deploy (--platform)
+var buildPlatform string
+
+// buildPlatforms returns the platforms images are built for (linux/amd64 by default)
+func buildPlatforms() ([]string, error) {
+	if strings.TrimSpace(buildPlatform) == "" {
+		return []string{defaultBuildPlatform}, nil
+	}
+
+	var platforms []string
+	seen := map[string]bool{}
+	for _, platform := range strings.Split(buildPlatform, ",") {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if platform == "" || seen[platform] {
+			continue
+		}
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] != "linux" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform '%s' (expected linux/<arch>, e.g. linux/arm64)", platform)
+		}
+		seen[platform] = true
+		platforms = append(platforms, platform)
+	}
+	if !seen[defaultBuildPlatform] {
+		return nil, fmt.Errorf("platforms must include %s, which App Platform runs", defaultBuildPlatform)
+	}
+	return platforms, nil
+}
+
+// multiPlatform checks if images are built for more than one platform
+// Multi-platform images can't be loaded into Docker's local image store, so Docker builds push them
+func multiPlatform() bool {
+	platforms, err := buildPlatforms()
+	return err == nil && len(platforms) > 1
+}
+
+// registryPlatform converts an os/arch[/variant] platform for the registry client
+func registryPlatform(platform string) registry.Platform {
+	parts := strings.SplitN(platform, "/", 3)
+	p := registry.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p
+}
+
+// ensureMultiPlatformBuilder creates the buildx builder of multi-platform builds if it doesn't exist
+func ensureMultiPlatformBuilder(ctx context.Context) error {
+	if dockerCommand(ctx, "buildx", "inspect", multiPlatformBuilder).Run() == nil {
+		return nil
+	}
+
+	ui.PrintInfo("Creating buildx builder %s for multi-platform builds...", multiPlatformBuilder)
+	output, err := dockerCommand(ctx, "buildx", "create", "--name", multiPlatformBuilder, "--driver", "docker-container").CombinedOutput()
+	return dockerError(err, string(output))
+}
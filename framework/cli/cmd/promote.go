@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+var promoteTag string
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote <from> [to]",
+	Short: "Deploy the exact image one site runs to another",
+	Long:  "Copy the image a site runs (e.g. staging) by digest to another site's repository (default: the site from site.properties) and deploy it there, without rebuilding, so both sites run binary-identical images. The image keeps its tag unless --tag is given.",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		from := args[0]
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		to, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+		if from == to {
+			ui.PrintError("Can't promote '%s' to itself", from)
+			os.Exit(1)
+		}
+
+		source, err := backend.GetSiteStatus(ctx, from)
+		if err != nil {
+			ui.PrintError("Failed to get status of '%s': %v", from, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		if source.Tag == "" {
+			ui.PrintError("Site '%s' doesn't run an image", from)
+			os.Exit(1)
+		}
+		sourceTags, err := backend.ListTags(ctx, from)
+		if err != nil {
+			ui.PrintError("Failed to get image of '%s': %v", from, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		targetTags, err := backend.ListTags(ctx, to)
+		if err != nil {
+			ui.PrintError("Failed to get image of '%s': %v", to, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		tag := promoteTag
+		if tag == "" {
+			tag = source.Tag
+		}
+
+		ui.PrintKeyValue("From", fmt.Sprintf("%s (%s:%s)", from, sourceTags.Repository, source.Tag))
+		ui.PrintKeyValue("To", fmt.Sprintf("%s (%s:%s)", to, targetTags.Repository, tag))
+		if source.InProgress != nil {
+			ui.PrintWarning("A deployment of '%s' is in progress; promoting the image it runs now", from)
+		}
+		fmt.Println()
+
+		digest, err := promoteImage(ctx, sourceTags.Repository, source.Tag, source.Release, targetTags.Repository, tag)
+		if err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("Run 'lightspeed promote " + from + " " + to + "' to resume (layers already copied are skipped)")
+			}
+			ui.PrintError("Failed to copy image: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		ui.PrintKeyValue("Digest", digest)
+		fmt.Println()
+
+		ui.PrintInfo("Deploying %s to '%s'...", tag, to)
+		deployment, err := backend.TriggerDeploy(ctx, to, api.DeployRequest{Tag: tag, Commit: source.Commit})
+		if err != nil {
+			ui.PrintError("Failed to deploy %s: %v", tag, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		if deployment.DeploymentID != "" {
+			ui.PrintKeyValue("Deployment", deployment.DeploymentID)
+		}
+		fmt.Println()
+
+		if _, err := waitForRedeployment(ctx, ui.Stdout, backend, to); err != nil {
+			if interrupted(ctx) {
+				fmt.Println()
+				ui.PrintInfo("The deployment continues in the background")
+				exitInterrupted("Run 'lightspeed status --watch' to follow it")
+			}
+			ui.PrintError("Promotion failed: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		// Both sites record the release (digest) they run, so the promotion can be checked end to end
+		fmt.Println()
+		if target, err := backend.GetSiteStatus(ctx, to); err == nil && source.Release != "" && target.Release != source.Release {
+			ui.PrintWarning("'%s' runs release %s, not %s like '%s'", to, target.Release, source.Release, from)
+		} else {
+			ui.PrintSuccess("Promoted %s from '%s' to '%s'", tag, from, to)
+		}
+		fmt.Println()
+	},
+}
+
+// promoteImage copies the manifest of repository:tag to another repository by its bytes, so the copy
+// has the same digest, along with the blobs and platform manifests it references
+// The release a site runs is the start of the digest it was deployed with, so a tag re-pushed since is
+// refused rather than promoting an image the site doesn't run. A target tag that already holds the
+// image is left as it is; one holding another image is refused.
+// Returns the digest of the promoted image.
+func promoteImage(ctx context.Context, sourceRepo, sourceTag, release, targetRepo, tag string) (string, error) {
+	client := registry.NewClient(registryHost, "lightspeed", registryPassword())
+	target := registry.NewClient(registryHost, "lightspeed", registryPassword())
+
+	manifest, mediaType, err := client.ReadManifest(ctx, sourceRepo, sourceTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s:%s: %w", sourceRepo, sourceTag, err)
+	}
+	digest := registry.Digest(manifest)
+	if release != "" && !strings.HasPrefix(strings.TrimPrefix(digest, "sha256:"), release) {
+		return "", fmt.Errorf("%s:%s was pushed again after it was deployed (it runs release %s, the tag is now %s); redeploy it first",
+			sourceRepo, sourceTag, release, shortDigest(digest))
+	}
+
+	existing, _, err := target.ReadManifest(ctx, targetRepo, tag)
+	var registryErr *registry.Error
+	switch {
+	case err == nil && registry.Digest(existing) == digest:
+		ui.PrintInfo("%s:%s already holds the image", targetRepo, tag)
+		return digest, nil
+	case err == nil:
+		return "", fmt.Errorf("%s:%s already holds another image; promote under another tag with --tag", targetRepo, tag)
+	case !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusNotFound:
+		return "", fmt.Errorf("failed to check %s:%s: %w", targetRepo, tag, err)
+	}
+
+	ui.PrintInfo("Copying %s:%s to %s:%s...", sourceRepo, sourceTag, targetRepo, tag)
+	if err := copyManifest(ctx, client, target, sourceRepo, targetRepo, tag, mediaType, manifest); err != nil {
+		return "", err
+	}
+	ui.PrintSuccess("Copied %s", shortDigest(digest))
+	return digest, nil
+}
+
+// copyManifest copies a manifest's blobs (and, for an index, its platform manifests) and then the
+// manifest itself under reference
+func copyManifest(ctx context.Context, source, target *registry.Client, sourceRepo, targetRepo, reference, mediaType string, manifest []byte) error {
+	var parsed registry.Manifest
+	if err := json.Unmarshal(manifest, &parsed); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	if mediaType == "" {
+		mediaType = parsed.MediaType
+	}
+
+	for _, child := range parsed.Manifests {
+		platform, childType, err := source.ReadManifest(ctx, sourceRepo, child.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to read manifest %s: %w", shortDigest(child.Digest), err)
+		}
+		if err := copyManifest(ctx, source, target, sourceRepo, targetRepo, child.Digest, childType, platform); err != nil {
+			return err
+		}
+	}
+
+	blobs := parsed.Layers
+	if parsed.Config != nil {
+		blobs = append([]registry.Descriptor{*parsed.Config}, blobs...)
+	}
+	for _, blob := range blobs {
+		exists, err := target.BlobExists(ctx, targetRepo, blob.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to check layer: %w", err)
+		}
+		if exists {
+			continue
+		}
+
+		fmt.Printf("• Copying layer %s (%s)...\n", shortDigest(blob.Digest), formatBytes(blob.Size))
+		content, err := source.GetBlob(ctx, sourceRepo, blob.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+		err = target.UploadBlob(ctx, targetRepo, blob.Digest, blob.Size, content)
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("failed to push layer: %w", err)
+		}
+	}
+
+	if err := target.PutManifest(ctx, targetRepo, reference, mediaType, manifest); err != nil {
+		return fmt.Errorf("failed to push manifest: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	promoteCmd.Flags().StringVarP(&promoteTag, "tag", "t", "", "Tag of the image in the target site's repository (default: the tag the source site runs)")
+
+	rootCmd.AddCommand(promoteCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"lightspeed/core/lib/ui"
+)
+
+// isInteractive checks if the CLI is attached to a terminal, so it can prompt (never in CI mode)
+func isInteractive() bool {
+	if ciMode {
+		return false
+	}
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// prompter asks questions on the terminal
+// Answers are read in the background so Ctrl-C (canceling ctx) interrupts a question
+type prompter struct {
+	ctx   context.Context
+	lines chan string
+}
+
+// newPrompter creates a prompter reading answers from stdin
+func newPrompter(ctx context.Context) *prompter {
+	p := &prompter{ctx: ctx, lines: make(chan string)}
+	go p.read()
+	return p
+}
+
+func (p *prompter) read() {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadString('\n')
+		if line != "" {
+			p.lines <- line
+		}
+		if err != nil {
+			close(p.lines)
+			return
+		}
+	}
+}
+
+// Ask prints a question and returns the answer, or the default if the answer is empty
+func (p *prompter) Ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s %s: ", question, ui.Muted("["+def+"]"))
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	var answer string
+	select {
+	case <-p.ctx.Done():
+		fmt.Println()
+		return "", p.ctx.Err()
+	case line, ok := <-p.lines:
+		if !ok {
+			fmt.Println()
+			return "", io.EOF
+		}
+		answer = line
+	}
+
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// Choose prints numbered options and returns the index of the chosen one
+// The default is chosen on an empty answer; options can be picked by number or name
+func (p *prompter) Choose(question string, options []string, def int) (int, error) {
+	fmt.Println(question)
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+
+	for {
+		answer, err := p.Ask("Choose", strconv.Itoa(def+1))
+		if err != nil {
+			return 0, err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		for i, option := range options {
+			if strings.EqualFold(strings.Fields(option)[0], answer) {
+				return i, nil
+			}
+		}
+		ui.PrintWarning("Enter a number from 1 to %d", len(options))
+	}
+}
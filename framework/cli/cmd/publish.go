@@ -1,34 +1,40 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
 	"lightspeed/core/lib/ui"
-	"lightspeed/core/lib/version"
 )
 
 var (
-	publishTag  string
-	publishName string
+	publishTag    string
+	publishName   string
+	publishDeploy bool
 )
 
 var publishCmd = &cobra.Command{
 	Use:   "publish",
 	Short: "Build and push Docker image to registry",
-	Long:  "Build the Docker image and push to the Lightspeed registry",
+	Long:  "Build the Docker image and push to the Lightspeed registry. Sites are only touched with --deploy; otherwise run 'lightspeed deploy --no-build' to deploy the published tag.",
 	Run: func(cmd *cobra.Command, args []string) {
 		ui.PrintHeader(Version)
+		timer := newPhaseTimer("publish")
 
 		dir, err := os.Getwd()
 		if err != nil {
 			ui.PrintError("Failed to get current directory: %v", err)
 			os.Exit(1)
 		}
+		checkSiteProperties(dir)
 
 		projectName := filepath.Base(dir)
 		imageName := sanitizeContainerName(projectName)
@@ -54,18 +60,12 @@ var publishCmd = &cobra.Command{
 		}
 
 		// Determine version tag
-		tag := publishTag
-		if tag == "" {
-			if version.IsGitRepo(dir) {
-				v, err := version.GetFromGit(dir)
-				if err == nil {
-					tag = v.String()
-				}
-			}
-			if tag == "" {
-				tag = "latest"
-			}
+		tag, err := resolveTag(cmd.Context(), dir, siteName, publishTag)
+		if err != nil {
+			ui.PrintError("Failed to determine tag: %v", err)
+			os.Exit(1)
 		}
+		commit := buildCommit(dir)
 
 		// Registry image names (use Docker-specific host for Docker operations)
 		// Use siteName for the image name (respects --name flag)
@@ -77,6 +77,9 @@ var publishCmd = &cobra.Command{
 		printSiteInfo(siteName, tag, domains)
 		ui.PrintKeyValue("Registry", dockerRegistry)
 		ui.PrintKeyValue("Platform", apiHost)
+		if multiPlatform() {
+			ui.PrintKeyValue("Architectures", buildPlatform)
+		}
 		fmt.Println()
 
 		// Get site image for Dockerfile
@@ -85,104 +88,271 @@ var publishCmd = &cobra.Command{
 			siteImage = siteInfo.Image
 		}
 
-		// Check if Dockerfile exists, create if not
-		dockerfilePath := filepath.Join(dir, "Dockerfile")
-		createdDockerfile := false
-		if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
-			ui.PrintInfo("Creating Dockerfile...")
-			if err := createDockerfile(dockerfilePath, siteImage); err != nil {
-				ui.PrintError("Failed to create Dockerfile: %v", err)
-				os.Exit(1)
-			}
-			createdDockerfile = true
+		published := []string{versionImage}
+		if tag != "latest" {
+			published = append(published, latestImage)
 		}
 
-		// Build the image
-		ui.PrintInfo("Building Docker image...")
-		fmt.Println()
-
-		// Use --pull to always get the latest base image
-		buildArgs := []string{
-			"build",
-			"--pull",
-			"--platform", "linux/amd64",
-			"-t", versionImage,
-			"-t", latestImage,
-			".",
+		platforms, err := buildPlatforms()
+		if err != nil {
+			ui.PrintError("Invalid --platform: %v", err)
+			os.Exit(1)
 		}
+		daemonless := useDaemonless()
+		// Multi-platform Docker builds push the images themselves, so they log in first
+		pushedByBuild := !daemonless && len(platforms) > 1
 
-		dockerBuildCmd := exec.Command("docker", buildArgs...)
-		dockerBuildCmd.Dir = dir
-		dockerBuildCmd.Stdout = os.Stdout
-		dockerBuildCmd.Stderr = os.Stderr
-
-		buildErr := dockerBuildCmd.Run()
-
-		// Clean up Dockerfile if we created it
-		if createdDockerfile {
-			os.Remove(dockerfilePath)
+		// login logs docker in to the registry and waits while registry writes are blocked
+		// by garbage collection
+		login := func() {
+			ui.PrintInfo("Logging in to registry...")
+			if err := dockerLogin(cmd.Context(), dockerRegistry); err != nil {
+				if interrupted(cmd.Context()) {
+					exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to start again")
+				}
+				ui.PrintError("Failed to login to registry: %v", err)
+				printErrorHint(err)
+				os.Exit(exitPushFailed)
+			}
+			if err := waitForRegistryWrites(cmd.Context(), ui.Stdout, newBackend()); err != nil {
+				exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to start again")
+			}
 		}
 
-		if buildErr != nil {
-			ui.PrintError("Failed to build image: %v", buildErr)
-			os.Exit(1)
+		// Build the image
+		var built *builtImage
+		timer.Start("build")
+		switch {
+		case daemonless:
+			ui.PrintInfo("Building image without Docker...")
+			built, err = buildImageDaemonless(cmd.Context(), dir, siteImage)
+		case pushedByBuild:
+			login()
+			ui.PrintInfo("Building and pushing Docker images...")
+			err = buildDockerImage(cmd.Context(), dir, siteImage, published)
+		default:
+			ui.PrintInfo("Building Docker image...")
+			err = buildDockerImage(cmd.Context(), dir, siteImage, []string{versionImage, latestImage})
+		}
+		if err != nil {
+			if interrupted(cmd.Context()) {
+				exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to start again")
+			}
+			ui.PrintError("Failed to build image: %v", err)
+			printErrorHint(err)
+			os.Exit(exitBuildFailed)
 		}
 
 		fmt.Println()
 		ui.PrintSuccess("Built image: %s", versionImage)
 		fmt.Println()
 
-		// Auto-login to registry
-		ui.PrintInfo("Logging in to registry...")
-		if err := dockerLogin(dockerRegistry); err != nil {
-			ui.PrintError("Failed to login to registry: %v", err)
-			os.Exit(1)
-		}
+		timer.Start("push")
+		switch {
+		case daemonless:
+			// Registry writes are blocked during garbage collection
+			if err := waitForRegistryWrites(cmd.Context(), ui.Stdout, newBackend()); err != nil {
+				exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to start again")
+			}
 
-		// Push specific tags we just built
-		ui.PrintInfo("Pushing images...")
-		if err := pushImage(versionImage); err != nil {
-			ui.PrintError("Failed to push image: %v", err)
-			os.Exit(1)
-		}
-		if tag != "latest" {
-			if err := pushImage(latestImage); err != nil {
+			ui.PrintInfo("Pushing image...")
+			err := pushImageDaemonless(cmd.Context(), built, published)
+			built.Remove()
+			if err != nil {
+				if interrupted(cmd.Context()) {
+					exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to resume (layers already pushed are skipped)")
+				}
 				ui.PrintError("Failed to push image: %v", err)
-				os.Exit(1)
+				printErrorHint(err)
+				os.Exit(exitPushFailed)
 			}
+		case !pushedByBuild:
+			login()
+
+			// Push specific tags we just built
+			ui.PrintInfo("Pushing images...")
+			for _, image := range published {
+				if err := pushImage(cmd.Context(), image); err != nil {
+					if interrupted(cmd.Context()) {
+						exitInterrupted("Run 'lightspeed " + cmd.Name() + "' to resume (layers already pushed are skipped)")
+					}
+					ui.PrintError("Failed to push image: %v", err)
+					printErrorHint(err)
+					os.Exit(exitPushFailed)
+				}
+			}
+		}
+
+		if err := recordPublished(dir, siteName, dockerRegistry, tag, commit, published); err != nil {
+			ui.PrintWarning("Failed to save project state: %v", err)
 		}
 
 		fmt.Println()
 		ui.PrintSuccess("Published successfully!")
 		fmt.Println()
 		ui.PrintInfo("Published tags:")
-		fmt.Printf("  • %s\n", versionImage)
-		if tag != "latest" {
-			fmt.Printf("  • %s\n", latestImage)
+		for _, image := range published {
+			fmt.Printf("  • %s\n", image)
 		}
 		fmt.Println()
+
+		result := publishOutput{Site: siteName, Tag: tag, Images: published, Platforms: platforms}
+		if publishDeploy {
+			timer.Start("wait")
+			backend := newBackend()
+			deployPublished(cmd.Context(), backend, siteName, tag, commit)
+			switch {
+			case jsonOutput():
+				result.Deployment, _ = backend.GetSiteStatus(cmd.Context(), siteName)
+			case ciMode:
+				status, _ := backend.GetSiteStatus(cmd.Context(), siteName)
+				deploymentID, domain := "", ""
+				if status != nil {
+					deploymentID, domain = status.DeploymentID, status.Domain
+				}
+				printCIResult(siteURLFor(cmd.Context(), backend, siteName, domain), deploymentID)
+			}
+		}
+		timer.Finish(dir, tag)
+
+		if jsonOutput() {
+			printJSON(result)
+		}
 	},
 }
 
-func dockerLogin(registry string) error {
-	cmd := exec.Command("docker", "login", registry, "-u", "lightspeed", "--password-stdin")
-	cmd.Stdin = strings.NewReader("lightspeed")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// publishOutput is the result of publish with --output json
+type publishOutput struct {
+	Site       string            `json:"site"`
+	Tag        string            `json:"tag"`
+	Images     []string          `json:"images"`
+	Platforms  []string          `json:"platforms"`
+	Deployment *api.SiteResponse `json:"deployment,omitempty"` // Site status after --deploy
+}
+
+// deployPublished deploys a published tag to an existing site and waits for it
+// Creating sites is left to deploy, which knows the site's template and domains
+func deployPublished(ctx context.Context, backend Backend, siteName, tag, commit string) {
+	exists, err := backend.SiteExists(ctx, siteName)
+	if err != nil {
+		ui.PrintError("Failed to check site: %v", err)
+		printErrorHint(err)
+		os.Exit(exitDeployFailed)
+	}
+	if !exists {
+		ui.PrintError("Site '%s' doesn't exist", siteName)
+		ui.PrintInfo("Run 'lightspeed deploy --no-build' to create it from the published tag")
+		os.Exit(exitDeployFailed)
+	}
+
+	ui.PrintInfo("Deploying %s to '%s'...", tag, siteName)
+	if _, err := backend.TriggerDeploy(ctx, siteName, api.DeployRequest{Tag: tag, Commit: commit}); err != nil {
+		ui.PrintError("Failed to deploy: %v", err)
+		printErrorHint(err)
+		os.Exit(exitDeployFailed)
+	}
+	if _, err := waitForRedeployment(ctx, ui.Stdout, backend, siteName); err != nil {
+		if interrupted(ctx) {
+			exitInterrupted("Run 'lightspeed status --watch' to follow the deployment")
+		}
+		ui.PrintError("Deploy failed: %v", err)
+		printErrorHint(err)
+		os.Exit(exitDeployFailed)
+	}
+
+	fmt.Println()
+	ui.PrintSuccess("Deployed %s to '%s'", tag, siteName)
+	fmt.Println()
 }
 
-func pushImage(image string) error {
-	fmt.Printf("• Pushing %s...\n", image)
-	cmd := exec.Command("docker", "push", image)
+// dockerLogin logs docker in to the registry with the access token from lightspeed login
+// Without one, the anonymous login is used (accepted unless the operator requires auth)
+func dockerLogin(ctx context.Context, registry string) error {
+	password := registryPassword()
+
+	var stderr bytes.Buffer
+	cmd := dockerCommand(ctx, "login", registry, "-u", "lightspeed", "--password-stdin")
+	cmd.Stdin = strings.NewReader(password)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	return dockerError(cmd.Run(), stderr.String())
+}
+
+// registryPassword returns the registry password: the access token from lightspeed login,
+// or the anonymous login's password without one
+func registryPassword() string {
+	if token := accessToken(); token != "" {
+		return token
+	}
+	return "lightspeed"
+}
+
+// pushImage pushes an image with docker
+// On a terminal a progress bar counts the pushed layers instead of docker's output
+func pushImage(ctx context.Context, image string) error {
+	spinner := ui.StartSpinner("Pushing %s", image)
+	var stderr bytes.Buffer
+	cmd := dockerCommand(ctx, "push", image)
+	if !spinner.Animated() {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		return dockerError(cmd.Run(), stderr.String())
+	}
+
+	progress := newPushProgress(spinner)
+	cmd.Stdout = progress
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	elapsed := spinner.Elapsed()
+	spinner.Stop()
+	if err != nil {
+		return dockerError(err, stderr.String())
+	}
+	ui.Stdout.Println(ui.Muted(fmt.Sprintf("  Pushed %s in %s (%d layer(s), %d already in the registry)",
+		image, ui.FormatElapsed(elapsed), len(progress.layers), progress.existing)))
+	return nil
+}
+
+// waitForRegistryWrites waits while registry garbage collection is running
+// Pushes made during collection would be held by the operator anyway; waiting here shows why
+// Returns an error only if ctx is canceled; health check failures are ignored
+func waitForRegistryWrites(ctx context.Context, out *ui.Output, backend Backend) error {
+	health, err := backend.Health(ctx)
+	if err != nil || health.GarbageCollection == nil || !health.GarbageCollection.Active {
+		return nil
+	}
+
+	out.PrintWarning("Registry garbage collection in progress (%s), waiting for it to finish...", health.GarbageCollection.Status)
+	start := time.Now()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		health, err := backend.Health(ctx)
+		if err != nil || health.GarbageCollection == nil || !health.GarbageCollection.Active {
+			out.PrintSuccess("Garbage collection finished after %v", time.Since(start).Round(time.Second))
+			return nil
+		}
+		out.Println(ui.Muted(fmt.Sprintf("  Still collecting (%s, %v elapsed)", health.GarbageCollection.Status, time.Since(start).Round(time.Second))))
+	}
 }
 
 func init() {
-	publishCmd.Flags().StringVarP(&publishTag, "tag", "t", "", "Version tag (default: git version or 'latest')")
+	publishCmd.Flags().StringVarP(&publishTag, "tag", "t", "", "Version tag (default: from tag.strategy, git version or 'latest')")
 	publishCmd.Flags().StringVarP(&publishName, "name", "n", "", "Site name (default: project directory name)")
+	publishCmd.Flags().BoolVar(&publishDeploy, "deploy", false, "Also deploy the published tag to the existing site")
+	publishCmd.Flags().BoolVar(&showTimings, "timings", false, "Show build, push and wait times compared with earlier publishes")
+	publishCmd.Flags().StringVar(&buildCacheFrom, "cache-from", "", "Reuse the layers of an image (default with no value: the last published image)")
+	publishCmd.Flags().Lookup("cache-from").NoOptDefVal = cachePublished
+	publishCmd.Flags().StringVar(&buildPlatform, "platform", defaultBuildPlatform, "Comma-separated platforms to build for (e.g. linux/amd64,linux/arm64)")
+	publishCmd.Flags().BoolVar(&noDocker, "no-docker", false, "Build and push the image without Docker (default when docker isn't installed)")
 
+	supportsJSON(publishCmd)
 	rootCmd.AddCommand(publishCmd)
 }
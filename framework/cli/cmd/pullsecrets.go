@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+// pullSecretTimeout bounds asking the operator for a pull secret, so builds don't hang offline
+const pullSecretTimeout = 5 * time.Second
+
+// baseImageLogin returns the login for the registry of a private base image: the one saved
+// with 'lightspeed config registry set', or else the operator's pull secret for the image
+// Lightspeed's server images and Docker Hub's official images are public, so they're never
+// looked up. Returns nil without a login; the build then pulls anonymously.
+func baseImageLogin(ctx context.Context, image string) *api.PullSecret {
+	ref, err := registry.ParseReference(image)
+	if err != nil || strings.HasPrefix(image, defaultServerImage+":") {
+		return nil
+	}
+	host := registry.NormalizeHost(ref.Registry)
+	if host == "docker.io" && strings.HasPrefix(ref.Repository, "library/") {
+		return nil
+	}
+
+	if login, ok := cliConfig.Registries[host]; ok {
+		password := resolveTokenReference(login.Password)
+		if password == "" {
+			ui.PrintWarning("The password of the %s login in the config is empty, pulling %s anonymously", host, image)
+			return nil
+		}
+		ui.PrintInfo("Using the %s login of %s from the config", host, login.Username)
+		return &api.PullSecret{Registry: host, Username: login.Username, Password: password}
+	}
+
+	// Builds without a saved login (e.g. in CI) get the operator's, which only hands it out
+	// to the access tokens it's registered for
+	if accessToken() == "" {
+		return nil
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, pullSecretTimeout)
+	defer cancel()
+	secret, err := newBackend().GetPullSecret(lookupCtx, image)
+	var apiErr *operatorError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		ui.PrintWarning("The operator's pull secret for %s isn't registered for this access token, pulling %s anonymously", host, image)
+		return nil
+	}
+	if err != nil {
+		ui.PrintDebug("No pull secret for %s: %v", image, err)
+		return nil
+	}
+	if secret == nil {
+		return nil
+	}
+	secret.Registry = host
+	ui.PrintInfo("Using the operator's pull secret for %s", host)
+	return secret
+}
+
+// dockerLoginBase logs docker in to the registry of a private base image before a build
+func dockerLoginBase(ctx context.Context, login *api.PullSecret) error {
+	var stderr bytes.Buffer
+	cmd := dockerCommand(ctx, "login", login.Registry, "-u", login.Username, "--password-stdin")
+	cmd.Stdin = strings.NewReader(login.Password)
+	cmd.Stderr = &stderr
+	return dockerError(cmd.Run(), stderr.String())
+}
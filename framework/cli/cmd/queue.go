@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	queueSiteName        string
+	queueDelay           int
+	queueForce           bool
+	queueWorkerScript    string
+	queueWorkerInstances int
+	queueWorkerSize      string
+)
+
+// queueWorkerTemplate is the worker script 'lightspeed queue init' creates
+const queueWorkerTemplate = `<?php
+/**
+ * Queue worker
+ *
+ * Runs in the background once enabled with 'lightspeed queue worker enable',
+ * with the site's image and variables. Push jobs from your pages with:
+ *
+ *   lightspeed_queue_push('default', ['to' => $email]);
+ */
+
+require_once('lightspeed/queue.php');
+
+lightspeed_queue_work('default', function ($payload, array $job) {
+    // Throwing leaves the job to be retried, up to 5 attempts
+    error_log('Processing job ' . $job['id'] . ': ' . json_encode($payload));
+});
+`
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage the background job queues of a site",
+	Long:  "Manage the job queues the operator hosts for a site, and the worker component that runs their jobs. Sites push and pop jobs with the lightspeed/queue.php library.",
+}
+
+var queueListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the site's queues and their job counts",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+		siteName := queueSite()
+
+		list, err := backend.ListQueues(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to list queues: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintInfo("Queues of '%s'", siteName)
+		fmt.Println()
+		if len(list.Queues) == 0 {
+			fmt.Printf("  %s\n", ui.Muted("(no jobs)"))
+		}
+		for _, queue := range list.Queues {
+			fmt.Printf("  %-20s %d ready, %d delayed, %d running, %d failed\n", queue.Name, queue.Ready, queue.Delayed, queue.Reserved, queue.Failed)
+		}
+		fmt.Println()
+
+		if worker, err := backend.GetWorker(ctx, siteName); err == nil {
+			ui.PrintKeyValue("Worker", fmt.Sprintf("%s (%d × %s)", worker.Script, worker.Instances, worker.Size))
+		} else {
+			ui.PrintKeyValue("Worker", ui.Muted("none (run 'lightspeed queue worker enable')"))
+		}
+		fmt.Println()
+	},
+}
+
+var queuePushCmd = &cobra.Command{
+	Use:   "push <queue> [payload]",
+	Short: "Push a job with a JSON payload to a queue",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		push := api.QueuePush{Delay: queueDelay}
+		if len(args) > 1 {
+			if !json.Valid([]byte(args[1])) {
+				ui.PrintError("Payload is not valid JSON: %s", args[1])
+				os.Exit(1)
+			}
+			push.Payload = json.RawMessage(args[1])
+		}
+
+		job, err := newBackend().PushJob(cmd.Context(), siteName, args[0], push)
+		if err != nil {
+			ui.PrintError("Failed to push job: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if job.AvailableAt != "" {
+			ui.PrintSuccess("Queued job %s on %s, to run after %s", job.ID, job.Queue, job.AvailableAt)
+		} else {
+			ui.PrintSuccess("Queued job %s on %s", job.ID, job.Queue)
+		}
+		fmt.Println()
+	},
+}
+
+var queueFailedCmd = &cobra.Command{
+	Use:   "failed <queue>",
+	Short: "List the failed jobs of a queue",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		jobs, err := newBackend().ListFailedJobs(cmd.Context(), siteName, args[0])
+		if err != nil {
+			ui.PrintError("Failed to list failed jobs: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		if len(jobs) == 0 {
+			ui.PrintSuccess("No failed jobs in %s", args[0])
+			fmt.Println()
+			return
+		}
+
+		ui.PrintInfo("Failed jobs of %s", args[0])
+		fmt.Println()
+		for _, job := range jobs {
+			fmt.Printf("  %s %s\n", job.ID, ui.Muted(fmt.Sprintf("queued %s, %s", job.CreatedAt, job.Error)))
+			fmt.Printf("    %s\n", string(job.Payload))
+		}
+		fmt.Println()
+		ui.PrintInfo("Run 'lightspeed queue retry %s' to run them again", args[0])
+		fmt.Println()
+	},
+}
+
+var queueRetryCmd = &cobra.Command{
+	Use:   "retry <queue>",
+	Short: "Run the failed jobs of a queue again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		count, err := newBackend().RetryJobs(cmd.Context(), siteName, args[0])
+		if err != nil {
+			ui.PrintError("Failed to retry jobs: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Retrying %d failed jobs of %s", count, args[0])
+		fmt.Println()
+	},
+}
+
+var queuePurgeCmd = &cobra.Command{
+	Use:   "purge <queue>",
+	Short: "Delete every job of a queue",
+	Long:  "Delete every job of a queue, including running and failed ones. Asks for the queue name to confirm unless --force is given.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		if !queueForce && !confirmQueuePurge(args[0]) {
+			ui.PrintInfo("Aborted")
+			fmt.Println()
+			os.Exit(1)
+		}
+
+		if err := newBackend().PurgeQueue(cmd.Context(), siteName, args[0]); err != nil {
+			ui.PrintError("Failed to purge queue: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Purged %s", args[0])
+		fmt.Println()
+	},
+}
+
+var queueInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a worker script in the project",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(dir, queueWorkerScript)
+		if _, err := os.Stat(path); err == nil {
+			ui.PrintError("%s already exists", queueWorkerScript)
+			os.Exit(1)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			ui.PrintError("Failed to create %s: %v", filepath.Dir(queueWorkerScript), err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(path, []byte(queueWorkerTemplate), 0644); err != nil {
+			ui.PrintError("Failed to create %s: %v", queueWorkerScript, err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Created %s", queueWorkerScript)
+		fmt.Println()
+		ui.PrintInfo("Deploy the site, then run 'lightspeed queue worker enable' to start it")
+		fmt.Println()
+	},
+}
+
+var queueWorkerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Manage the component that runs the site's jobs",
+}
+
+var queueWorkerEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Run a worker script of the site in the background (redeploys the site)",
+	Long:  "Add a worker component to the site that runs a PHP script of the project with the site's image, tag and variables. Running it again updates the script, instance count or size.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		ui.PrintInfo("Enabling worker for '%s'...", siteName)
+		worker, err := newBackend().SetWorker(cmd.Context(), siteName, api.SiteWorker{
+			Script:    queueWorkerScript,
+			Instances: queueWorkerInstances,
+			Size:      queueWorkerSize,
+		})
+		if err != nil {
+			ui.PrintError("Failed to enable worker: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Worker enabled, the site is redeploying")
+		fmt.Println()
+		ui.PrintKeyValue("Script", worker.Script)
+		ui.PrintKeyValue("Instances", fmt.Sprintf("%d", worker.Instances))
+		ui.PrintKeyValue("Size", worker.Size)
+		ui.PrintKeyValue("Tag", worker.Tag)
+		fmt.Println()
+		ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+		fmt.Println()
+	},
+}
+
+var queueWorkerDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Remove the site's worker component (redeploys the site)",
+	Long:  "Remove the site's worker component. Queued jobs are kept until the worker is enabled again or the queue is purged.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := queueSite()
+
+		if err := newBackend().DeleteWorker(cmd.Context(), siteName); err != nil {
+			ui.PrintError("Failed to disable worker: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Worker disabled, the site is redeploying")
+		fmt.Println()
+	},
+}
+
+// queueSite resolves the site queue commands apply to
+func queueSite() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		ui.PrintError("Failed to get current directory: %v", err)
+		os.Exit(1)
+	}
+
+	siteName, err := resolveSiteName(dir, queueSiteName)
+	if err != nil {
+		ui.PrintError("Failed to load site.properties: %v", err)
+		os.Exit(1)
+	}
+	return siteName
+}
+
+// confirmQueuePurge asks the user to type the queue name to confirm purging it
+func confirmQueuePurge(queue string) bool {
+	if ciRefusesPrompt() {
+		return false
+	}
+	ui.PrintWarning("This deletes every job of the queue, including running and failed ones")
+	fmt.Printf("Type '%s' to confirm: ", queue)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	return strings.TrimSpace(answer) == queue
+}
+
+func init() {
+	queueCmd.PersistentFlags().StringVarP(&queueSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	queuePushCmd.Flags().IntVar(&queueDelay, "delay", 0, "Seconds before the job can run")
+	queuePurgeCmd.Flags().BoolVarP(&queueForce, "force", "f", false, "Purge without asking for confirmation")
+	queueInitCmd.Flags().StringVar(&queueWorkerScript, "script", "worker.php", "Path of the worker script in the project")
+	queueWorkerEnableCmd.Flags().StringVar(&queueWorkerScript, "script", "worker.php", "Path of the worker script in the project")
+	queueWorkerEnableCmd.Flags().IntVar(&queueWorkerInstances, "instances", 0, "Number of worker instances (default: 1, or the current count)")
+	queueWorkerEnableCmd.Flags().StringVar(&queueWorkerSize, "size", "", "Instance size of the worker (default: the site's size)")
+
+	queueWorkerCmd.AddCommand(queueWorkerEnableCmd)
+	queueWorkerCmd.AddCommand(queueWorkerDisableCmd)
+
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queuePushCmd)
+	queueCmd.AddCommand(queueFailedCmd)
+	queueCmd.AddCommand(queueRetryCmd)
+	queueCmd.AddCommand(queuePurgeCmd)
+	queueCmd.AddCommand(queueInitCmd)
+	queueCmd.AddCommand(queueWorkerCmd)
+	rootCmd.AddCommand(queueCmd)
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+// Operator API exchanges are recorded to the fixture file in LIGHTSPEED_RECORD, or answered
+// from the one in LIGHTSPEED_REPLAY without contacting the operator, so deploy, status and
+// rollback flows can be exercised (e.g. in tests) without a running operator
+const (
+	recordEnv = "LIGHTSPEED_RECORD"
+	replayEnv = "LIGHTSPEED_REPLAY"
+)
+
+// replayPollInterval replaces the status polling interval while replaying, as recorded
+// responses don't change by waiting
+const replayPollInterval = 10 * time.Millisecond
+
+// exchange is a recorded operator request and its response
+// The host and Authorization header aren't recorded, so fixtures replay against any API URL
+// and hold no access token
+type exchange struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"` // Path and query
+	Body        string `json:"body,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response"`
+}
+
+// fixture is a file of recorded exchanges, in the order their responses were read
+type fixture struct {
+	Exchanges []exchange `json:"exchanges"`
+}
+
+// Recorders and replayers are shared by the backends of a process, per fixture file, so the
+// exchanges of a command that creates several backends end up in (or come from) one file
+var (
+	vcrMu        sync.Mutex
+	vcrRecorders = map[string]*recorder{}
+	vcrReplayers = map[string]*replayer{}
+)
+
+// replaying checks if operator requests are answered from a fixture
+func replaying() bool {
+	return os.Getenv(replayEnv) != ""
+}
+
+// operatorTransport wraps the transport of operator requests with the recorder or replayer
+// when LIGHTSPEED_RECORD or LIGHTSPEED_REPLAY is set; replaying takes precedence
+func operatorTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	vcrMu.Lock()
+	defer vcrMu.Unlock()
+
+	if file := os.Getenv(replayEnv); file != "" {
+		r, ok := vcrReplayers[file]
+		if !ok {
+			var err error
+			if r, err = loadReplayer(file); err != nil {
+				return failingTransport{err: err}
+			}
+			vcrReplayers[file] = r
+			ui.PrintDebug("Replaying operator requests from %s (%d exchanges)", file, len(r.exchanges))
+		}
+		return r
+	}
+
+	if file := os.Getenv(recordEnv); file != "" {
+		r, ok := vcrRecorders[file]
+		if !ok {
+			r = &recorder{file: file}
+			vcrRecorders[file] = r
+			ui.PrintDebug("Recording operator requests to %s", file)
+		}
+		return &recordingTransport{base: base, recorder: r}
+	}
+	return base
+}
+
+// recorder saves exchanges to a fixture file
+// The file is rewritten after every exchange, since commands end with os.Exit
+type recorder struct {
+	file string
+	mu   sync.Mutex
+	data fixture
+}
+
+// add records an exchange and saves the fixture (0600, as responses can hold credentials)
+func (r *recorder) add(e exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data.Exchanges = append(r.data.Exchanges, e)
+	data, err := json.MarshalIndent(r.data, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.file, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		ui.PrintWarning("Failed to save recorded requests to %s: %v", r.file, err)
+	}
+}
+
+// recordingTransport makes requests with its base transport and records them
+type recordingTransport struct {
+	base     http.RoundTripper
+	recorder *recorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := exchange{Method: req.Method, Path: req.URL.RequestURI()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		e.Body = string(body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	e.Status = resp.StatusCode
+	e.ContentType = resp.Header.Get("Content-Type")
+
+	// The response is recorded once it's closed, so streamed logs are captured as they're read
+	resp.Body = &recordingBody{body: resp.Body, done: func(data []byte) {
+		e.Response = string(data)
+		t.recorder.add(e)
+	}}
+	return resp, nil
+}
+
+// recordingBody keeps what is read from a response body and hands it over when closed
+type recordingBody struct {
+	body io.ReadCloser
+	data bytes.Buffer
+	done func([]byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.data.Write(p[:n])
+	return n, err
+}
+
+// Close reads what's left of the body (a decoder may stop before the end) before recording it
+func (b *recordingBody) Close() error {
+	io.Copy(&b.data, b.body)
+	err := b.body.Close()
+	b.once.Do(func() { b.done(b.data.Bytes()) })
+	return err
+}
+
+// replayer answers requests with recorded responses
+// Exchanges are matched by method, path and query, in the order they were recorded; once the
+// exchanges of a request are used up the last one is repeated, so polling ends on the final state
+type replayer struct {
+	mu        sync.Mutex
+	exchanges []exchange
+	queues    map[string][]exchange
+	last      map[string]exchange
+}
+
+// loadReplayer loads a fixture file for replaying
+func loadReplayer(file string) (*replayer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", replayEnv, err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	r := &replayer{exchanges: f.Exchanges, queues: map[string][]exchange{}, last: map[string]exchange{}}
+	for _, e := range f.Exchanges {
+		key := e.Method + " " + e.Path
+		r.queues[key] = append(r.queues[key], e)
+	}
+	return r, nil
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := req.Method + " " + req.URL.RequestURI()
+
+	r.mu.Lock()
+	e, ok := r.last[key]
+	if queue := r.queues[key]; len(queue) > 0 {
+		e, ok = queue[0], true
+		r.queues[key] = queue[1:]
+		r.last[key] = e
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s in %s", key, os.Getenv(replayEnv))
+	}
+
+	header := http.Header{}
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(e.Response))),
+		ContentLength: int64(len(e.Response)),
+		Request:       req,
+	}, nil
+}
+
+// failingTransport fails every request, e.g. when the replay fixture can't be loaded
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
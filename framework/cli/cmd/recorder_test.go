@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// The fixtures in testdata were recorded with LIGHTSPEED_RECORD from an operator running
+// against the fake DigitalOcean and Cloudflare APIs (platform/operator/internal/testsupport)
+
+// replayBackend returns a backend answering from a fixture in testdata
+// The fixture is loaded afresh, so each test replays it from the start
+func replayBackend(t *testing.T, name string) Backend {
+	t.Helper()
+
+	file := filepath.Join("testdata", name)
+	t.Setenv(replayEnv, file)
+	forgetFixture := func() {
+		vcrMu.Lock()
+		delete(vcrReplayers, file)
+		vcrMu.Unlock()
+	}
+	forgetFixture()
+	t.Cleanup(forgetFixture)
+	return newOperatorBackend("https://operator.test", "")
+}
+
+// testOutput is a prefixed output, so waits don't stream deployment logs
+var testOutput = ui.NewOutput("test")
+
+func TestReplayDeploy(t *testing.T) {
+	ctx := context.Background()
+	backend := replayBackend(t, "deploy.json")
+
+	created, err := backend.CreateSite(ctx, api.Site{Name: "shop", Tag: "1.0.0"})
+	if err != nil {
+		t.Fatalf("CreateSite: %v", err)
+	}
+	if created.Domain != "shop.lightspeed.ee" {
+		t.Errorf("domain = %q, want shop.lightspeed.ee", created.Domain)
+	}
+	siteURL, err := waitForDeployment(ctx, testOutput, backend, "shop")
+	if err != nil {
+		t.Fatalf("waitForDeployment: %v", err)
+	}
+	if want := "https://shop-app-00000001.ondigitalocean.app"; siteURL != want {
+		t.Errorf("URL = %q, want %q", siteURL, want)
+	}
+
+	deployment, err := backend.TriggerDeploy(ctx, "shop", api.DeployRequest{Tag: "1.1.0"})
+	if err != nil {
+		t.Fatalf("TriggerDeploy: %v", err)
+	}
+	if deployment.Tag != "1.1.0" || deployment.DeploymentID == "" {
+		t.Errorf("deployment = %+v, want one of 1.1.0", deployment)
+	}
+	if err := waitForRelease(ctx, testOutput, backend, "shop", false); err != nil {
+		t.Fatalf("waitForRelease: %v", err)
+	}
+
+	// Polling ends on the last recorded state
+	status, err := backend.GetSiteStatus(ctx, "shop")
+	if err != nil {
+		t.Fatalf("GetSiteStatus: %v", err)
+	}
+	if status.Status != "ACTIVE" || status.Tag != "1.1.0" || status.DeploymentID != deployment.DeploymentID {
+		t.Errorf("status = %s %s %s, want ACTIVE 1.1.0 %s", status.Status, status.Tag, status.DeploymentID, deployment.DeploymentID)
+	}
+}
+
+func TestReplayDeployFailed(t *testing.T) {
+	ctx := context.Background()
+	backend := replayBackend(t, "deploy-failed.json")
+
+	if _, err := backend.CreateSite(ctx, api.Site{Name: "broken", Tag: "1.0.0"}); err != nil {
+		t.Fatalf("CreateSite: %v", err)
+	}
+	err := waitForRelease(ctx, testOutput, backend, "broken", true)
+	if err == nil || !strings.Contains(err.Error(), "ERROR") {
+		t.Errorf("waitForRelease = %v, want a failed deployment", err)
+	}
+}
+
+func TestReplayStatus(t *testing.T) {
+	ctx := context.Background()
+	backend := replayBackend(t, "status.json")
+
+	status, err := backend.GetSiteStatus(ctx, "shop")
+	if err != nil {
+		t.Fatalf("GetSiteStatus: %v", err)
+	}
+	if status.InProgress == nil || siteStatusSettled(status) {
+		t.Fatalf("status = %+v, want a deployment in progress", status)
+	}
+	if got := sitePhase(status); got != status.InProgress.Status {
+		t.Errorf("phase = %s, want the in-progress deployment's %s", got, status.InProgress.Status)
+	}
+	inProgress := status.InProgress.DeploymentID
+
+	polls := 0
+	err = pollSiteStatus(ctx, backend, "shop", 0, func(latest *api.SiteResponse) (bool, error) {
+		polls++
+		status = latest
+		return siteStatusSettled(latest), nil
+	})
+	if err != nil {
+		t.Fatalf("pollSiteStatus: %v", err)
+	}
+	if polls != 2 {
+		t.Errorf("settled after %d polls, want 2", polls)
+	}
+	if status.Status != "ACTIVE" || status.DeploymentID != inProgress {
+		t.Errorf("status = %s %s, want ACTIVE %s", status.Status, status.DeploymentID, inProgress)
+	}
+}
+
+func TestReplayRollback(t *testing.T) {
+	ctx := context.Background()
+	backend := replayBackend(t, "rollback.json")
+
+	list, err := backend.ListTags(ctx, "shop")
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if list.Current != "1.2.0" || len(list.Tags) != 3 {
+		t.Fatalf("tags = %+v, want 3 with 1.2.0 current", list)
+	}
+	previous := list.Tags[1].Tag
+
+	deployment, err := backend.TriggerDeploy(ctx, "shop", api.DeployRequest{Tag: previous})
+	if err != nil {
+		t.Fatalf("TriggerDeploy: %v", err)
+	}
+	if deployment.Tag != "1.1.0" {
+		t.Errorf("deployed %s, want 1.1.0", deployment.Tag)
+	}
+	if _, err := waitForRedeployment(ctx, testOutput, backend, "shop"); err != nil {
+		t.Fatalf("waitForRedeployment: %v", err)
+	}
+	status, err := backend.GetSiteStatus(ctx, "shop")
+	if err != nil {
+		t.Fatalf("GetSiteStatus: %v", err)
+	}
+	if status.Tag != "1.1.0" || status.DeploymentID != deployment.DeploymentID {
+		t.Errorf("status = %s %s, want 1.1.0 %s", status.Tag, status.DeploymentID, deployment.DeploymentID)
+	}
+}
+
+func TestReplayUnrecorded(t *testing.T) {
+	backend := replayBackend(t, "status.json")
+
+	_, err := backend.GetSiteStatus(context.Background(), "blog")
+	if err == nil || !strings.Contains(err.Error(), "no recorded response for GET /sites/blog") {
+		t.Errorf("GetSiteStatus = %v, want no recorded response", err)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	operator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.SiteResponse{Name: "shop", Status: "ACTIVE", Tag: "1.0.0"})
+	}))
+	defer operator.Close()
+
+	file := filepath.Join(t.TempDir(), "fixture.json")
+	t.Setenv(recordEnv, file)
+	t.Cleanup(func() {
+		vcrMu.Lock()
+		delete(vcrRecorders, file)
+		vcrMu.Unlock()
+	})
+	if _, err := newOperatorBackend(operator.URL, "secret-token").GetSiteStatus(context.Background(), "shop"); err != nil {
+		t.Fatalf("GetSiteStatus: %v", err)
+	}
+
+	// Fixtures hold neither the operator's address nor the access token
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if strings.Contains(string(data), "secret-token") || strings.Contains(string(data), operator.Listener.Addr().String()) {
+		t.Errorf("fixture holds the token or host: %s", data)
+	}
+
+	t.Setenv(replayEnv, file)
+	t.Cleanup(func() {
+		vcrMu.Lock()
+		delete(vcrReplayers, file)
+		vcrMu.Unlock()
+	})
+	operator.Close()
+	status, err := newOperatorBackend("https://elsewhere.test", "").GetSiteStatus(context.Background(), "shop")
+	if err != nil {
+		t.Fatalf("replayed GetSiteStatus: %v", err)
+	}
+	if status.Name != "shop" || status.Tag != "1.0.0" {
+		t.Errorf("replayed status = %+v, want shop 1.0.0", status)
+	}
+}
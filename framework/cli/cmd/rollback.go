@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// rollbackListLimit is how many recent tags rollback offers to pick from
+const rollbackListLimit = 10
+
+var rollbackTag string
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [name]",
+	Short: "Redeploy a previous image tag",
+	Long:  "List recent image tags of a site and redeploy the one picked (or the one given with --tag). The site stays pinned to that tag until the next deploy.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		tag := rollbackTag
+		if tag == "" {
+			list, err := backend.ListTags(ctx, siteName)
+			if err != nil {
+				ui.PrintError("Failed to list tags of '%s': %v", siteName, err)
+				os.Exit(1)
+			}
+			tag = pickRollbackTag(list)
+		}
+
+		ui.PrintInfo("Rolling back '%s' to %s...", siteName, tag)
+		deployment, err := backend.TriggerDeploy(ctx, siteName, api.DeployRequest{Tag: tag})
+		if err != nil {
+			ui.PrintError("Failed to deploy %s: %v", tag, err)
+			os.Exit(1)
+		}
+		if deployment.DeploymentID != "" {
+			ui.PrintKeyValue("Deployment", deployment.DeploymentID)
+		}
+		fmt.Println()
+
+		if _, err := waitForRedeployment(ctx, ui.Stdout, backend, siteName); err != nil {
+			if interrupted(ctx) {
+				fmt.Println()
+				ui.PrintInfo("The deployment continues in the background")
+				exitInterrupted("Run 'lightspeed status --watch' to follow it")
+			}
+			ui.PrintError("Rollback failed: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		fmt.Println()
+		ui.PrintSuccess("Rolled back '%s' to %s", siteName, tag)
+		fmt.Println()
+	},
+}
+
+// pickRollbackTag lists recent tags and asks the user to pick one
+func pickRollbackTag(list *api.TagList) string {
+	tags := list.Tags
+	if len(tags) > rollbackListLimit {
+		tags = tags[:rollbackListLimit]
+	}
+	if len(tags) == 0 {
+		ui.PrintError("No tags found in repository '%s'", list.Repository)
+		os.Exit(1)
+	}
+
+	ui.PrintInfo("Recent tags of %s:", list.Repository)
+	for i, tag := range tags {
+		line := fmt.Sprintf("  %2d) %-24s %s", i+1, tag.Tag, ui.Muted(tag.UpdatedAt))
+		if tag.Tag == list.Current {
+			line += " (current)"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+
+	if ciMode {
+		ui.PrintError("Can't ask for a tag in CI mode; pass one with --tag")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Select a tag [1-%d]: ", len(tags))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		ui.PrintInfo("Aborted")
+		os.Exit(1)
+	}
+
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(tags) {
+		ui.PrintError("Invalid choice '%s'", strings.TrimSpace(answer))
+		os.Exit(1)
+	}
+	fmt.Println()
+	return tags[choice-1].Tag
+}
+
+func init() {
+	rollbackCmd.Flags().StringVarP(&rollbackTag, "tag", "t", "", "Tag to deploy (default: pick from recent tags)")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -21,8 +21,10 @@ const (
 // Shared hosts for deploy/publish commands
 var (
 	apiHostOverride string // Set by --api flag
-	registryHost    string // Computed: override or default
-	apiHost         string // Computed: override or default
+	quiet           bool   // Set by --quiet flag
+	verbose         bool   // Set by --verbose flag
+	registryHost    string // Computed: override, config or default
+	apiHost         string // Computed: override, config or default
 )
 
 var rootCmd = &cobra.Command{
@@ -37,7 +39,11 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Commands receive a context that is canceled on Ctrl-C so they can clean up
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -48,29 +54,71 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 
 	// Hidden --api flag for testing (shared by deploy/publish)
-	// When set, overrides both registry and API hosts
+	// When set, overrides both registry and API hosts from the config file
 	rootCmd.PersistentFlags().StringVar(&apiHostOverride, "api", "", "Override API and registry host:port")
 	rootCmd.PersistentFlags().MarkHidden("api")
 
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print results, warnings and errors")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also print the docker commands run and the API calls made")
+	rootCmd.PersistentFlags().BoolVar(&ciMode, "ci", false, "CI mode: no prompts or browser, plain output, deploy URL and ID on stdout (default: on when a CI system is detected)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text or json (build, publish, deploy, info, sites, deployments and status)")
+
 	// Set up pre-run to compute hosts after flags are parsed
 	originalPreRun := rootCmd.PersistentPreRun
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		switch {
+		case quiet && verbose:
+			ui.PrintError("--quiet and --verbose can't be used together")
+			os.Exit(1)
+		case quiet:
+			ui.SetLevel(ui.LevelQuiet)
+		case verbose:
+			ui.SetLevel(ui.LevelVerbose)
+		}
+		setupCI()
+
+		if err := setupOutput(cmd); err != nil {
+			ui.PrintError("Invalid --output: %v", err)
+			os.Exit(1)
+		}
+
+		// Load ~/.lightspeed/config.yaml (an invalid file is ignored so commands keep working)
+		if config, err := loadConfig(); err != nil {
+			ui.PrintWarning("Ignoring config: %v", err)
+		} else {
+			cliConfig = *config
+		}
+
 		// Check env var first, then flag
 		override := os.Getenv("LIGHTSPEED_API")
 		if apiHostOverride != "" {
 			override = apiHostOverride
 		}
 
-		if override != "" {
+		switch {
+		case override != "":
 			// Use override for both
 			registryHost = override
 			apiHost = override
-		} else {
+		case cliConfig.API != "":
+			// The operator serves the registry too, unless the config names another one
+			apiHost = cliConfig.API
+			registryHost = cliConfig.API
+			if cliConfig.Registry != "" {
+				registryHost = cliConfig.Registry
+			}
+		default:
 			// Use separate defaults
 			registryHost = defaultRegistryHost
 			apiHost = defaultAPIHost
+			if cliConfig.Registry != "" {
+				registryHost = cliConfig.Registry
+			}
 		}
 
+		// Look for a newer release while the command runs
+		startUpdateCheck()
+
 		// Ensure PHP library is installed
 		ensureLibrary()
 
@@ -83,6 +131,11 @@ func init() {
 			originalPreRun(cmd, args)
 		}
 	}
+
+	// Commands that fail exit before this, so the update hint only follows successful output
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		printUpdateHint()
+	}
 }
 
 var versionCmd = &cobra.Command{
@@ -93,24 +146,23 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// loopbackHosts are the host names of the local machine translated for Docker
+var loopbackHosts = []string{"localhost", "127.0.0.1", "[::1]"}
+
 // getDockerRegistryHost returns the registry host for Docker operations
-// On macOS, localhost must be translated to host.docker.internal for Docker to reach the host
+// On macOS and Windows, localhost must be translated to host.docker.internal for Docker to reach the host
 func getDockerRegistryHost() string {
 	host := registryHost
 
 	// Docker Desktop runs in a VM, so localhost doesn't work
-	// Translate localhost to host.docker.internal
-	if strings.HasPrefix(host, "localhost:") {
-		return "host.docker.internal" + strings.TrimPrefix(host, "localhost")
-	}
-	if host == "localhost" {
-		return "host.docker.internal"
-	}
-	if strings.HasPrefix(host, "127.0.0.1:") {
-		return "host.docker.internal" + strings.TrimPrefix(host, "127.0.0.1")
-	}
-	if host == "127.0.0.1" {
-		return "host.docker.internal"
+	// Translate localhost to host.docker.internal (host names are case-insensitive)
+	for _, loopback := range loopbackHosts {
+		if len(host) < len(loopback) || !strings.EqualFold(host[:len(loopback)], loopback) {
+			continue
+		}
+		if rest := host[len(loopback):]; rest == "" || strings.HasPrefix(rest, ":") {
+			return "host.docker.internal" + rest
+		}
 	}
 
 	return host
@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,8 +20,10 @@ import (
 )
 
 var (
-	runPort  int
-	runImage string
+	runPort   int
+	runImage  string
+	runWatch  bool
+	runWithDB string
 )
 
 // Default server image from GitHub Container Registry
@@ -32,14 +38,27 @@ func getServerImage(siteImage string) string {
 	return resolveImage(siteImage)
 }
 
+// getDevServer returns the image and command (nil for the image's own) of the development container
+// Static sites are served by nginx without PHP, with the configuration of their built image
+func getDevServer(dir string) (string, []string) {
+	siteImage := getSiteImage(dir)
+	if getSiteType(dir) != siteTypeStatic {
+		return getServerImage(siteImage), nil
+	}
+	if runImage == "" && siteImage == "" {
+		return staticServerImage, staticServerCommand()
+	}
+	return getServerImage(siteImage), staticServerCommand()
+}
+
 // getSiteImage loads the image property from site.properties if it exists
 func getSiteImage(dir string) string {
-	propsPath := filepath.Join(dir, "site.properties")
+	propsPath := properties.ProjectFile(dir)
 	if !properties.FileExists(propsPath) {
 		return ""
 	}
 
-	props, err := properties.ParseProperties(propsPath)
+	props, err := properties.ParseFile(propsPath)
 	if err != nil {
 		return ""
 	}
@@ -47,10 +66,57 @@ func getSiteImage(dir string) string {
 	return props.Get("image")
 }
 
+// devPropertiesMount is where the site.properties converted from a lightspeed.yaml is mounted
+const devPropertiesMount = "/var/www/html/site.properties"
+
+// withDevProperties returns the mounts of a development container with, for projects with a
+// lightspeed.yaml, its conversion to site.properties (written to ~/.lightspeed/dev) mounted
+// over the project's for the PHP library. The file is written again on every (re)start.
+func withDevProperties(dir, containerName string, binds []string) []string {
+	kept := make([]string, 0, len(binds)+1)
+	for _, bind := range binds {
+		if !strings.Contains(bind, ":"+devPropertiesMount) {
+			kept = append(kept, bind)
+		}
+	}
+
+	propsPath := properties.ProjectFile(dir)
+	if !properties.IsManifest(propsPath) {
+		return kept
+	}
+	props, err := properties.ParseFile(propsPath)
+	if err != nil {
+		ui.PrintWarning("Failed to load %s: %v", properties.ManifestFile, err)
+		return kept
+	}
+	content, err := properties.FormatProperties(props)
+	if err != nil {
+		ui.PrintWarning("Failed to convert %s: %v", properties.ManifestFile, err)
+		return kept
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		ui.PrintWarning("Failed to get home directory: %v", err)
+		return kept
+	}
+	devDir := filepath.Join(homeDir, ".lightspeed", "dev")
+	path := filepath.Join(devDir, containerName+".properties")
+	if err := os.MkdirAll(devDir, 0755); err != nil {
+		ui.PrintWarning("Failed to create %s: %v", devDir, err)
+		return kept
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		ui.PrintWarning("Failed to write %s: %v", path, err)
+		return kept
+	}
+	return append(kept, fmt.Sprintf("%s:%s:ro", path, devPropertiesMount))
+}
+
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start a PHP development server",
-	Long:  "Start a PHP container with the current directory mounted as a volume",
+	Long:  "Start a PHP container with the current directory mounted as a volume. With --watch, the CLI stays in the foreground and reloads open pages when site files change.",
 	Run: func(cmd *cobra.Command, args []string) {
 		ui.PrintHeader(Version)
 
@@ -60,13 +126,7 @@ var startCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Get project name from site.properties, fallback to directory name
-		projectName := filepath.Base(dir)
-		siteInfo, _ := loadSiteInfo(dir)
-		if siteInfo != nil && siteInfo.Name != "" {
-			projectName = siteInfo.Name
-		}
-		containerName := fmt.Sprintf("lightspeed-%s", sanitizeContainerName(projectName))
+		containerName := devContainerName(dir)
 
 		// Check if container is already running
 		if isContainerRunning(containerName) {
@@ -88,44 +148,94 @@ var startCmd = &cobra.Command{
 			}
 		}
 
-		ui.PrintInfo("Starting development server...")
-		fmt.Println()
+		// In watch mode the CLI serves the port, proxying the container on another port
+		containerPort := port
+		var listener net.Listener
+		if runWatch {
+			listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				ui.PrintError("Failed to listen on port %d: %v", port, err)
+				os.Exit(1)
+			}
+			defer listener.Close()
 
-		// Get site image from site.properties
-		siteImage := getSiteImage(dir)
+			containerPort = findAvailablePort()
+			if containerPort == 0 {
+				ui.PrintError("No available ports found in range 9000-9099")
+				os.Exit(1)
+			}
+		}
 
-		// Run PHP container with nginx
-		serverImage := getServerImage(siteImage)
-		dockerArgs := []string{
-			"run",
-			"-d",
-			"--name", containerName,
-			"-p", fmt.Sprintf("%d:80", port),
-			"-v", fmt.Sprintf("%s:/var/www/html", dir),
-			serverImage,
+		services, err := projectServices(dir, runWithDB)
+		if err != nil {
+			ui.PrintError("%v", err)
+			os.Exit(1)
 		}
 
-		dockerCmd := exec.Command("docker", dockerArgs...)
-		output, err := dockerCmd.CombinedOutput()
+		// Composer projects need their vendor directory, which is mounted with the project
+		siteInfo, _ := loadSiteInfo(dir)
+		if usesComposer(dir, siteInfo) {
+			if err := installComposerVendor(cmd.Context(), dir); err != nil {
+				ui.PrintWarning("%v", err)
+				ui.PrintInfo("Run 'composer install' in the project to install them yourself")
+			}
+		}
+
+		ui.PrintInfo("Starting development server...")
+		fmt.Println()
+
+		// Start the auxiliary services first, so the PHP container can join their network
+		network, env, err := startDevServices(containerName, services)
 		if err != nil {
+			ui.PrintError("Failed to start services: %v", err)
+			stopDevServices(containerName)
+			os.Exit(1)
+		}
+
+		// Run PHP container with nginx (nginx alone for static sites), using the site image from site.properties
+		serverImage, command := getDevServer(dir)
+		binds := withDevProperties(dir, containerName, []string{fmt.Sprintf("%s:/var/www/html", dir)})
+		if output, err := runDevContainer(containerName, containerPort, binds, serverImage, command, network, env); err != nil {
 			ui.PrintError("Failed to start container: %v", err)
 			ui.PrintError("%s", string(output))
+			stopDevServices(containerName)
 			os.Exit(1)
 		}
 
 		url := fmt.Sprintf("http://localhost:%d", port)
 
+		var reload *liveReload
+		if runWatch {
+			reload = newLiveReload(containerPort)
+			go http.Serve(listener, reload)
+		}
+
 		ui.PrintSuccess("Development server started")
 		fmt.Println()
 		ui.PrintKeyValue("  URL", url)
 		ui.PrintKeyValue("  Container", containerName)
+		if len(services) > 0 {
+			ui.PrintKeyValue("  Services", devServiceNames(services))
+		}
+		printDevDatabase(services)
 		fmt.Println()
 
 		// Wait for server to be ready and open browser
-		if waitForServer(url, 30) {
+		if waitForServer(cmd.Context(), url, 30) {
 			openBrowser(url)
 		}
 
+		if !runWatch {
+			ui.PrintInfo("Run 'lightspeed stop' to stop the server")
+			fmt.Println()
+			return
+		}
+
+		serveWatch(cmd.Context(), reload, dir, containerName, containerPort)
+
+		// The container keeps running without the watcher, on its own port
+		fmt.Println()
+		ui.PrintInfo("Stopped watching; the server is still running at http://localhost:%d", containerPort)
 		ui.PrintInfo("Run 'lightspeed stop' to stop the server")
 		fmt.Println()
 	},
@@ -144,22 +254,20 @@ var stopCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Get project name from site.properties, fallback to directory name
-		projectName := filepath.Base(dir)
-		siteInfo, _ := loadSiteInfo(dir)
-		if siteInfo != nil && siteInfo.Name != "" {
-			projectName = siteInfo.Name
-		}
-		containerName := fmt.Sprintf("lightspeed-%s", sanitizeContainerName(projectName))
+		containerName := devContainerName(dir)
 
 		if !isContainerRunning(containerName) {
+			// Services may be left from a server that exited on its own
+			stopDevServices(containerName)
 			ui.PrintWarning("No running container found for this project")
 			os.Exit(0)
 		}
 
 		ui.PrintInfo("Stopping development server...")
 
-		if stopContainer(containerName) {
+		stopped := stopContainer(containerName)
+		stopDevServices(containerName)
+		if stopped {
 			ui.PrintSuccess("Development server stopped")
 		} else {
 			ui.PrintError("Failed to stop container")
@@ -168,8 +276,181 @@ var stopCmd = &cobra.Command{
 	},
 }
 
+var restartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart the PHP development server",
+	Long:  "Stop and re-create the development container with the same port and mounts, picking up changes to site.properties and the base image",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		containerName := devContainerName(dir)
+		if !containerExists(containerName) {
+			ui.PrintWarning("No development server found for this project")
+			ui.PrintInfo("Start one with: lightspeed start")
+			os.Exit(1)
+		}
+
+		// Keep the port and mounts of the current container unless --port is given
+		port, binds, err := inspectDevContainer(containerName)
+		if err != nil {
+			ui.PrintError("Failed to inspect container %s: %v", containerName, err)
+			os.Exit(1)
+		}
+		if runPort != 0 {
+			port = runPort
+		}
+		if len(binds) == 0 {
+			binds = []string{fmt.Sprintf("%s:/var/www/html", dir)}
+		}
+		binds = withDevProperties(dir, containerName, binds)
+
+		ui.PrintInfo("Restarting development server...")
+		fmt.Println()
+
+		if !stopContainer(containerName) {
+			ui.PrintError("Failed to stop container")
+			os.Exit(1)
+		}
+
+		// Resolve the image and services again, so changes to site.properties or --image are picked up
+		// The database of --with-db is kept unless another one is given
+		withDB := runWithDB
+		if withDB == "" {
+			withDB = devDatabase(containerName)
+		}
+		stopDevServices(containerName)
+		network, env, err := startProjectServices(dir, containerName, withDB)
+		if err != nil {
+			ui.PrintError("Failed to start services: %v", err)
+			os.Exit(1)
+		}
+		serverImage, command := getDevServer(dir)
+		if output, err := runDevContainer(containerName, port, binds, serverImage, command, network, env); err != nil {
+			ui.PrintError("Failed to start container: %v", err)
+			ui.PrintError("%s", string(output))
+			os.Exit(1)
+		}
+
+		url := fmt.Sprintf("http://localhost:%d", port)
+
+		ui.PrintSuccess("Development server restarted")
+		fmt.Println()
+		ui.PrintKeyValue("  URL", url)
+		ui.PrintKeyValue("  Image", serverImage)
+		ui.PrintKeyValue("  Container", containerName)
+		fmt.Println()
+
+		if !waitForServer(cmd.Context(), url, 30) {
+			ui.PrintWarning("Server didn't respond within 30 seconds; check 'docker logs %s'", containerName)
+			fmt.Println()
+		}
+	},
+}
+
+// devContainerName returns the development container name of a project
+// The project name comes from site.properties, falling back to the directory name
+func devContainerName(dir string) string {
+	projectName := filepath.Base(dir)
+	siteInfo, _ := loadSiteInfo(dir)
+	if siteInfo != nil && siteInfo.Name != "" {
+		projectName = siteInfo.Name
+	}
+	return fmt.Sprintf("lightspeed-%s", sanitizeContainerName(projectName))
+}
+
+// devPlatform returns the platform development containers run on: the host's architecture,
+// so Docker Desktop on Apple Silicon doesn't emulate amd64 images (empty for other architectures)
+func devPlatform() string {
+	switch runtime.GOARCH {
+	case "amd64", "arm64":
+		return "linux/" + runtime.GOARCH
+	}
+	return ""
+}
+
+// ensureNativeImage pulls the host's variant of a multi-arch image if the local copy is another one
+// Builds pull linux/amd64 base images for App Platform, which replaces the local copy of the tag.
+// Images without a native variant (or that can't be pulled) are run as they are.
+func ensureNativeImage(image string) {
+	platform := devPlatform()
+	if platform == "" {
+		return
+	}
+	output, err := dockerCommand(context.Background(), "image", "inspect", "--format", "{{.Os}}/{{.Architecture}}", image).Output()
+	if err != nil || strings.TrimSpace(string(output)) == platform {
+		// Missing images are pulled by docker run, which picks the native variant
+		return
+	}
+
+	ui.PrintInfo("Pulling %s for %s...", image, platform)
+	if err := dockerCommand(context.Background(), "pull", "--quiet", "--platform", platform, image).Run(); err != nil {
+		ui.PrintWarning("%s has no %s variant; it runs emulated (%s)", image, platform, strings.TrimSpace(string(output)))
+	}
+}
+
+// runDevContainer runs a development container serving port 80 on a host port
+// With services, it joins their network and gets their connection variables in env
+// A non-nil command replaces the image's command
+func runDevContainer(name string, port int, binds []string, image string, command []string, network string, env []string) ([]byte, error) {
+	ensureNativeImage(image)
+
+	dockerArgs := []string{
+		"run",
+		"-d",
+		"--name", name,
+		"-p", fmt.Sprintf("%d:80", port),
+	}
+	for _, bind := range binds {
+		dockerArgs = append(dockerArgs, "-v", bind)
+	}
+	if network != "" {
+		dockerArgs = append(dockerArgs, "--network", network)
+	}
+	for _, value := range env {
+		dockerArgs = append(dockerArgs, "-e", value)
+	}
+	dockerArgs = append(dockerArgs, image)
+	dockerArgs = append(dockerArgs, command...)
+
+	return dockerCommand(context.Background(), dockerArgs...).CombinedOutput()
+}
+
+// inspectDevContainer returns the host port and volume mounts of a development container
+func inspectDevContainer(name string) (int, []string, error) {
+	output, err := dockerCommand(context.Background(), "inspect", "--format", "{{json .HostConfig}}", name).Output()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var hostConfig struct {
+		Binds        []string
+		PortBindings map[string][]struct {
+			HostPort string
+		}
+	}
+	if err := json.Unmarshal(output, &hostConfig); err != nil {
+		return 0, nil, err
+	}
+
+	for _, binding := range hostConfig.PortBindings["80/tcp"] {
+		if port, err := strconv.Atoi(binding.HostPort); err == nil && port > 0 {
+			return port, hostConfig.Binds, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("container doesn't publish port 80")
+}
+
+// isContainerRunning checks if a container with exactly this name is running
+// The name filter matches substrings, so it's anchored to leave out the server's service containers
 func isContainerRunning(name string) bool {
-	cmd := exec.Command("docker", "ps", "-q", "-f", fmt.Sprintf("name=%s", name))
+	cmd := dockerCommand(context.Background(), "ps", "-q", "-f", fmt.Sprintf("name=^%s$", name))
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -179,14 +460,14 @@ func isContainerRunning(name string) bool {
 
 func stopContainer(name string) bool {
 	// Stop container if running
-	exec.Command("docker", "stop", name).Run()
+	dockerCommand(context.Background(), "stop", name).Run()
 	// Remove container
-	err := exec.Command("docker", "rm", name).Run()
+	err := dockerCommand(context.Background(), "rm", name).Run()
 	return err == nil || !containerExists(name)
 }
 
 func containerExists(name string) bool {
-	cmd := exec.Command("docker", "ps", "-aq", "-f", fmt.Sprintf("name=%s", name))
+	cmd := dockerCommand(context.Background(), "ps", "-aq", "-f", fmt.Sprintf("name=^%s$", name))
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -219,7 +500,7 @@ func findAvailablePort() int {
 	return 0
 }
 
-func waitForServer(url string, timeoutSeconds int) bool {
+func waitForServer(ctx context.Context, url string, timeoutSeconds int) bool {
 	client := &http.Client{Timeout: 2 * time.Second}
 	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
 
@@ -229,20 +510,32 @@ func waitForServer(url string, timeoutSeconds int) bool {
 			resp.Body.Close()
 			return true
 		}
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
 	return false
 }
 
+// openBrowser opens a URL in the default browser
+// On Windows, start is a cmd builtin (not on the PATH) that splits URLs at &, so the URL
+// protocol handler is used instead
+// CI machines have no one to look at the page, so nothing is opened in CI mode
 func openBrowser(url string) {
+	if ciMode {
+		return
+	}
+
 	var cmd *exec.Cmd
 	switch {
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
 	case isCommandAvailable("open"):
 		cmd = exec.Command("open", url)
 	case isCommandAvailable("xdg-open"):
 		cmd = exec.Command("xdg-open", url)
-	case isCommandAvailable("start"):
-		cmd = exec.Command("cmd", "/c", "start", url)
 	default:
 		return
 	}
@@ -257,7 +550,15 @@ func isCommandAvailable(name string) bool {
 func init() {
 	startCmd.Flags().IntVarP(&runPort, "port", "p", 0, "Port to expose (default: auto-detect in 9000 range)")
 	startCmd.Flags().StringVarP(&runImage, "image", "i", "", "Docker image to use (default: lightspeed-server)")
+	startCmd.Flags().BoolVarP(&runWatch, "watch", "w", false, "Reload the browser when files change (runs until Ctrl-C)")
+	startCmd.Flags().StringVar(&runWithDB, "with-db", "", "Start a MySQL or MariaDB container seeded from ./db (mysql, mariadb or name:version)")
+	startCmd.Flags().Lookup("with-db").NoOptDefVal = "mysql"
+	restartCmd.Flags().IntVarP(&runPort, "port", "p", 0, "Port to expose (default: the current port)")
+	restartCmd.Flags().StringVarP(&runImage, "image", "i", "", "Docker image to use (default: from site.properties)")
+	restartCmd.Flags().StringVar(&runWithDB, "with-db", "", "Database container to start (default: the current one)")
+	restartCmd.Flags().Lookup("with-db").NoOptDefVal = "mysql"
 
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(restartCmd)
 }
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	scaleInstances int
+	scaleSize      string
+	scaleDryRun    bool
+)
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale [name]",
+	Short: "Change a site's instance count or size",
+	Long:  "Change how many instances a site runs and their size (an App Platform slug such as apps-s-1vcpu-1gb). The site redeploys with the new settings.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		if !cmd.Flags().Changed("instances") && scaleSize == "" {
+			ui.PrintError("Nothing to change: use --instances and/or --size")
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("instances") && scaleInstances < 1 {
+			ui.PrintError("--instances must be at least 1")
+			os.Exit(1)
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		update := api.SiteUpdate{
+			Instances: scaleInstances,
+			Size:      scaleSize,
+		}
+		if scaleDryRun {
+			plan, err := newBackend().PlanUpdateSite(cmd.Context(), siteName, update)
+			if err != nil {
+				ui.PrintError("Failed to plan scaling: %v", err)
+				printErrorHint(err)
+				os.Exit(1)
+			}
+			ui.PrintInfo("Dry run: the site isn't changed")
+			fmt.Println()
+			fmt.Printf("  PATCH /sites/%s would change the site's spec, which redeploys it\n", siteName)
+			printSpecChanges("    ", plan)
+			fmt.Println()
+			return
+		}
+
+		// The session warning is only for scaling out from a single instance
+		previous := 0
+		if scaleInstances > 1 {
+			if current, err := newBackend().GetSiteStatus(cmd.Context(), siteName); err == nil {
+				previous = max(current.Instances, 1)
+			}
+		}
+
+		ui.PrintInfo("Scaling '%s'...", siteName)
+		site, err := newBackend().UpdateSite(cmd.Context(), siteName, update)
+		if err != nil {
+			ui.PrintError("Failed to scale site: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		ui.PrintSuccess("Site scaled, the site is redeploying")
+		fmt.Println()
+		ui.PrintKeyValue("Instances", fmt.Sprintf("%d", site.Instances))
+		if site.Size != "" {
+			ui.PrintKeyValue("Size", site.Size)
+		}
+		fmt.Println()
+		// App Platform balances requests across instances and only exposes one ingress, so
+		// neither it nor the edge proxy can pin a visitor to the instance holding their session
+		if previous == 1 && site.Instances > 1 {
+			ui.PrintWarning("PHP sessions are stored per instance, and requests aren't routed to the same instance")
+			ui.PrintInfo("Keep sessions in shared storage (e.g. the 'lightspeed db' database) if the site uses them")
+		}
+		ui.PrintInfo("Run 'lightspeed status --watch' to follow the deployment")
+		fmt.Println()
+	},
+}
+
+func init() {
+	scaleCmd.Flags().IntVarP(&scaleInstances, "instances", "i", 0, "Number of instances to run")
+	scaleCmd.Flags().StringVarP(&scaleSize, "size", "s", "", "Instance size slug (e.g. apps-s-1vcpu-1gb)")
+	scaleCmd.Flags().BoolVar(&scaleDryRun, "dry-run", false, "Show the spec changes without scaling the site")
+
+	rootCmd.AddCommand(scaleCmd)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secret environment variables of a site",
+	Long:  "Manage environment variables stored encrypted in the site's app spec. Secret values are never shown, and they're kept when the spec is updated by deploys, rollbacks and env changes.",
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the site's secrets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		list, err := newBackend().ListEnv(cmd.Context(), siteName)
+		if err != nil {
+			ui.PrintError("Failed to list secrets: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		var names []string
+		for _, env := range list.Vars {
+			if env.Type == "SECRET" {
+				names = append(names, env.Key)
+			}
+		}
+		if len(names) == 0 {
+			ui.PrintInfo("No secrets set for '%s'", siteName)
+			fmt.Println()
+			return
+		}
+
+		ui.PrintInfo("Secrets of '%s'", siteName)
+		fmt.Println()
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Println()
+	},
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <KEY[=VALUE]>...",
+	Short: "Set secrets (redeploys the site)",
+	Long:  "Set secrets from KEY=VALUE arguments. For a KEY without a value, the value is read from stdin so it doesn't end up in shell history (e.g. lightspeed secrets set API_KEY < key.txt).",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		var update api.EnvUpdate
+		stdin := bufio.NewReader(os.Stdin)
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if key == "" {
+				ui.PrintError("Invalid '%s': use KEY=VALUE or KEY", arg)
+				os.Exit(1)
+			}
+			if !ok {
+				value = readSecret(stdin, key)
+			}
+			update.Set = append(update.Set, api.EnvVar{Key: key, Value: value, Type: "SECRET"})
+		}
+
+		updateSecrets(cmd, siteName, update)
+	},
+}
+
+var secretsRemoveCmd = &cobra.Command{
+	Use:     "remove <KEY>...",
+	Aliases: []string{"rm"},
+	Short:   "Remove secrets (redeploys the site)",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		siteName := envSite()
+
+		updateSecrets(cmd, siteName, api.EnvUpdate{Unset: args})
+	},
+}
+
+// updateSecrets applies a secrets update, printing only the keys
+func updateSecrets(cmd *cobra.Command, siteName string, update api.EnvUpdate) {
+	ui.PrintInfo("Updating secrets of '%s'...", siteName)
+	if _, err := newBackend().UpdateEnv(cmd.Context(), siteName, update); err != nil {
+		ui.PrintError("Failed to update secrets: %v", err)
+		printErrorHint(err)
+		os.Exit(1)
+	}
+
+	for _, env := range update.Set {
+		ui.PrintSuccess("Set %s", env.Key)
+	}
+	for _, key := range update.Unset {
+		ui.PrintSuccess("Removed %s", key)
+	}
+	fmt.Println()
+	ui.PrintInfo("The site is redeploying; run 'lightspeed status --watch' to follow it")
+	fmt.Println()
+}
+
+// readSecret reads a secret value for key from one line of stdin
+func readSecret(stdin *bufio.Reader, key string) string {
+	fmt.Printf("Value for %s: ", key)
+	value, err := stdin.ReadString('\n')
+	if err != nil && value == "" {
+		fmt.Println()
+		ui.PrintError("No value given for %s", key)
+		os.Exit(1)
+	}
+	return strings.TrimRight(value, "\r\n")
+}
+
+func init() {
+	secretsCmd.PersistentFlags().StringVarP(&envSiteName, "name", "n", "", "Site name (default: from site.properties or directory name)")
+
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsRemoveCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+// shellCommand starts bash in the container, or sh in images without bash
+const shellCommand = "if command -v bash > /dev/null; then exec bash; else exec sh; fi"
+
+var shellCmd = &cobra.Command{
+	Use:     "shell [-- command...]",
+	Aliases: []string{"exec"},
+	Short:   "Open a shell in the development container",
+	Long:    "Open a shell (bash, or sh if the image has no bash) in the project's development container, or run a command in it, e.g. 'lightspeed shell -- php -i'.",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		containerName := devContainerName(dir)
+		if !isContainerRunning(containerName) {
+			ui.PrintError("No running development server found for this project")
+			ui.PrintInfo("Start one with: lightspeed start")
+			os.Exit(1)
+		}
+
+		// Allocate a terminal only when there is one, so commands can be piped
+		dockerArgs := []string{"exec", "-i"}
+		if isInteractive() {
+			dockerArgs = append(dockerArgs, "-t")
+		}
+		dockerArgs = append(dockerArgs, "-w", "/var/www/html", containerName)
+		if len(args) > 0 {
+			dockerArgs = append(dockerArgs, args...)
+		} else {
+			dockerArgs = append(dockerArgs, "sh", "-c", shellCommand)
+		}
+
+		dockerCmd := dockerCommand(context.Background(), dockerArgs...)
+		dockerCmd.Stdin = os.Stdin
+		dockerCmd.Stdout = os.Stdout
+		dockerCmd.Stderr = os.Stderr
+		if err := dockerCmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			ui.PrintError("Failed to run docker exec: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
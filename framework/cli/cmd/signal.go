@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"lightspeed/core/lib/ui"
+)
+
+// interruptContext returns a context that is canceled on the first Ctrl-C (or SIGTERM)
+// After the first signal the default handling is restored, so a second Ctrl-C exits immediately
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// interrupted reports whether the command was interrupted by Ctrl-C
+func interrupted(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+// exitInterrupted prints a resumable next step and exits with the conventional SIGINT status
+func exitInterrupted(next string) {
+	fmt.Println()
+	ui.PrintWarning("Interrupted")
+	if next != "" {
+		ui.PrintInfo("%s", next)
+	}
+	fmt.Println()
+	os.Exit(130)
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/properties"
+)
+
+// environmentEnv overrides the environment from site.properties, e.g. for staging builds in CI
+const environmentEnv = "LIGHTSPEED_ENVIRONMENT"
+
+// productionEnvironment is the only environment search engines may index
+const productionEnvironment = "production"
+
+// sitemapSkipDirs are project directories that don't hold pages
+var sitemapSkipDirs = map[string]bool{
+	"assets":       true,
+	"includes":     true,
+	"vendor":       true,
+	"node_modules": true,
+}
+
+// sitemapPage is a page listed in the sitemap
+type sitemapPage struct {
+	Path     string
+	Modified time.Time
+}
+
+// sitemapURLSet is the sitemap.xml document
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// siteEnvironment returns the environment a build is for
+// Priority: LIGHTSPEED_ENVIRONMENT env var > site.properties environment > production
+func siteEnvironment(props properties.Properties) string {
+	if env := os.Getenv(environmentEnv); env != "" {
+		return strings.ToLower(env)
+	}
+	if env := props.Get("environment"); env != "" {
+		return strings.ToLower(env)
+	}
+	return productionEnvironment
+}
+
+// generatedFiles returns the files generated into a project's image, keyed by path in the
+// web root: with sitemap=true, a sitemap.xml of the project's pages and a robots.txt that
+// allows indexing only in production. Files the project has itself are kept in production.
+// Production images also get the IndexNow key file of the hooks.indexnow deploy hook, and
+// PHP projects with a lightspeed.yaml (or deployed to an environment) a site.properties converted from it.
+func generatedFiles(dir string) (map[string]string, error) {
+	propsPath := properties.ProjectFile(dir)
+	if !properties.FileExists(propsPath) {
+		return nil, nil
+	}
+	props, err := parseProjectFile(propsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	// The PHP library reads site.properties, so a manifest is written into the image as one,
+	// as is the project with its environment's section applied
+	if (properties.IsManifest(propsPath) || deployEnvironment != "") && props.Get("type") != siteTypeStatic {
+		content, err := properties.FormatProperties(props)
+		if err != nil {
+			return nil, err
+		}
+		files[properties.PropertiesFile] = content
+	}
+	production := siteEnvironment(props) == productionEnvironment
+	if name, key := indexNowKeyFile(props); name != "" && production {
+		files[name] = key
+	}
+	if !props.GetBool("sitemap") {
+		return files, nil
+	}
+
+	if !production {
+		// Previews and staging must never be indexed, whatever robots.txt the project has
+		files["robots.txt"] = "User-agent: *\nDisallow: /\n"
+		return files, nil
+	}
+
+	domain, err := productionDomain(dir, props)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := "https://" + domain
+	if !projectHas(dir, "sitemap.xml") {
+		pages, err := sitemapPages(dir)
+		if err != nil {
+			return nil, err
+		}
+		sitemap, err := buildSitemap(baseURL, pages)
+		if err != nil {
+			return nil, err
+		}
+		files["sitemap.xml"] = sitemap
+	}
+	if !projectHas(dir, "robots.txt") {
+		files["robots.txt"] = fmt.Sprintf("User-agent: *\nAllow: /\n\nSitemap: %s/sitemap.xml\n", baseURL)
+	}
+	return files, nil
+}
+
+// productionDomain returns the domain the sitemap lists pages under
+// Priority: first custom domain > site subdomain under the base domain
+func productionDomain(dir string, props properties.Properties) (string, error) {
+	if domain := props.Get("domain"); domain != "" {
+		return domain, nil
+	}
+	if domains := props.GetList("domains"); len(domains) > 0 {
+		return domains[0], nil
+	}
+
+	siteName, err := resolveSiteName(dir, "")
+	if err != nil {
+		return "", err
+	}
+	return siteName + "." + props.GetWithDefault("base_domain", "lightspeed.ee"), nil
+}
+
+// sitemapPages finds the PHP and HTML pages of a project
+// PHP pages are listed without extension (the server resolves /about to about.php) and
+// index pages as their directory. Hidden files, files starting with _ and error pages are left out.
+func sitemapPages(dir string) ([]sitemapPage, error) {
+	var pages []sitemapPage
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if rel, _ := filepath.Rel(dir, path); sitemapSkipDirs[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(name)
+		if ext != ".php" && ext != ".html" {
+			return nil
+		}
+		base := strings.TrimSuffix(name, ext)
+		if base == "error" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		urlPath := "/" + filepath.ToSlash(rel)
+		switch {
+		case base == "index":
+			urlPath = strings.TrimSuffix(urlPath, name)
+		case ext == ".php":
+			urlPath = strings.TrimSuffix(urlPath, ext)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		pages = append(pages, sitemapPage{Path: urlPath, Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
+	return pages, nil
+}
+
+// buildSitemap renders a sitemap.xml listing pages under a base URL
+func buildSitemap(baseURL string, pages []sitemapPage) (string, error) {
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, page := range pages {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     baseURL + page.Path,
+			LastMod: page.Modified.UTC().Format("2006-01-02"),
+		})
+	}
+
+	data, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+// projectHas checks if a project has a file of its own in its root
+func projectHas(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
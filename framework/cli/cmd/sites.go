@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var sitesCmd = &cobra.Command{
+	Use:   "sites",
+	Short: "List the deployed sites",
+	Long:  "List every site on the platform with its status, the tag it runs and its domain",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		list, err := newBackend().ListSites(cmd.Context())
+		if err != nil {
+			ui.PrintError("Failed to list sites: %v", err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		sort.Slice(list.Sites, func(i, j int) bool { return list.Sites[i].Name < list.Sites[j].Name })
+
+		if jsonOutput() {
+			printJSON(list)
+			return
+		}
+
+		if len(list.Sites) == 0 {
+			ui.PrintInfo("No sites yet")
+			fmt.Println()
+			return
+		}
+		ui.PrintInfo("%d site(s)", len(list.Sites))
+		fmt.Println()
+		for _, site := range list.Sites {
+			tag := site.Tag
+			if tag == "" {
+				tag = "-"
+			}
+			fmt.Printf("  %-24s %-18s %-20s %s\n", site.Name, formatStatus(site.Status), tag, site.Domain)
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	supportsJSON(sitesCmd)
+	rootCmd.AddCommand(sitesCmd)
+}
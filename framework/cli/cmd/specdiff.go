@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// specChange is a difference between two app specs at a path such as
+// services[mysite].envs[DEBUG].value
+type specChange struct {
+	Path   string
+	Before interface{} // nil if the value is added
+	After  interface{} // nil if the value is removed
+}
+
+// diffSpecs compares two app specs (plain JSON values, as dry runs return them)
+// Lists whose items have a name (components, domains are keyed by domain, envs by key) are
+// compared item by item, so a change shows as one path instead of the whole list
+func diffSpecs(before, after interface{}) []specChange {
+	var changes []specChange
+	diffSpecValue("", before, after, &changes)
+	return changes
+}
+
+// diffSpecValue adds the changes between two values at a path
+// A section that's added or removed is compared with an empty one, so each of its values shows
+func diffSpecValue(path string, before, after interface{}, changes *[]specChange) {
+	change := specChange{Path: path, Before: before, After: after}
+	switch {
+	case before == nil && after != nil:
+		before = emptySpecValue(after)
+	case after == nil && before != nil:
+		after = emptySpecValue(before)
+	}
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			keys := map[string]bool{}
+			for key := range b {
+				keys[key] = true
+			}
+			for key := range a {
+				keys[key] = true
+			}
+			for _, key := range sortedSpecKeys(keys) {
+				diffSpecValue(joinSpecPath(path, key), b[key], a[key], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok {
+			if bItems, aItems, keyed := keySpecItems(b, a); keyed {
+				keys := map[string]bool{}
+				for key := range bItems {
+					keys[key] = true
+				}
+				for key := range aItems {
+					keys[key] = true
+				}
+				for _, key := range sortedSpecKeys(keys) {
+					diffSpecValue(path+"["+key+"]", bItems[key], aItems[key], changes)
+				}
+				return
+			}
+			if len(a) == len(b) {
+				for i := range b {
+					diffSpecValue(fmt.Sprintf("%s[%d]", path, i), b[i], a[i], changes)
+				}
+				return
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, change)
+	}
+}
+
+// emptySpecValue returns an empty section or list of a value's kind, or nil for other values
+func emptySpecValue(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case []interface{}:
+		return []interface{}{}
+	}
+	return nil
+}
+
+// keySpecItems keys the items of two lists by their name, domain or key field
+// Returns false if an item has none of them, or two items share one
+func keySpecItems(before, after []interface{}) (map[string]interface{}, map[string]interface{}, bool) {
+	key := func(items []interface{}) (map[string]interface{}, bool) {
+		keyed := map[string]interface{}{}
+		for _, item := range items {
+			fields, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			name := ""
+			for _, field := range []string{"name", "domain", "key"} {
+				if value, ok := fields[field].(string); ok && value != "" {
+					name = value
+					break
+				}
+			}
+			if _, taken := keyed[name]; name == "" || taken {
+				return nil, false
+			}
+			keyed[name] = item
+		}
+		return keyed, true
+	}
+
+	bItems, ok := key(before)
+	if !ok {
+		return nil, nil, false
+	}
+	aItems, ok := key(after)
+	if !ok {
+		return nil, nil, false
+	}
+	return bItems, aItems, true
+}
+
+// joinSpecPath appends a field to a spec path
+func joinSpecPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedSpecKeys returns the keys of a set in order
+func sortedSpecKeys(keys map[string]bool) []string {
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// printSpecChanges prints the changes between a dry run's current and planned spec
+func printSpecChanges(indent string, plan *api.DryRun) {
+	changes := diffSpecs(plan.Current, plan.Spec)
+	if len(changes) == 0 {
+		fmt.Println(ui.Muted(indent + "No spec changes"))
+		return
+	}
+	for _, change := range changes {
+		switch {
+		case change.Before == nil:
+			fmt.Printf("%s+ %s: %s\n", indent, change.Path, specValue(change.After))
+		case change.After == nil:
+			fmt.Printf("%s- %s: %s\n", indent, change.Path, specValue(change.Before))
+		default:
+			fmt.Printf("%s~ %s: %s -> %s\n", indent, change.Path, specValue(change.Before), specValue(change.After))
+		}
+	}
+}
+
+// specValue formats a spec value on one line
+func specValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimSpace(string(data))
+}
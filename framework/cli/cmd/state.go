@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateBaseDir holds project state files
+// State lives outside the project so it never ends up in the image built from the project directory
+const stateBaseDir = ".lightspeed/state"
+
+// projectState is what the CLI remembers about a project between commands
+type projectState struct {
+	Published *publishedImage   `json:"published,omitempty"`
+	Perf      []perfMeasurement `json:"perf,omitempty"`    // Performance after recent deploys, oldest first
+	Timings   []commandTiming   `json:"timings,omitempty"` // Durations of recent builds, publishes and deploys, oldest first
+}
+
+// publishedImage is the image last pushed from a project
+type publishedImage struct {
+	Site        string   `json:"site"`
+	Registry    string   `json:"registry"`
+	Tag         string   `json:"tag"`
+	Commit      string   `json:"commit,omitempty"`
+	Images      []string `json:"images"`
+	PublishedAt string   `json:"published_at"`
+}
+
+// projectStatePath returns the state file path of a project directory
+// The path hash keeps projects with the same directory name apart
+func projectStatePath(dir string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := fmt.Sprintf("%s-%s.json", sanitizeContainerName(filepath.Base(abs)), hex.EncodeToString(sum[:])[:8])
+	return filepath.Join(homeDir, stateBaseDir, name), nil
+}
+
+// loadProjectState reads a project's state (empty if nothing was saved yet)
+func loadProjectState(dir string) (*projectState, error) {
+	path, err := projectStatePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &projectState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state projectState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// saveProjectState writes a project's state
+func saveProjectState(dir string, state *projectState) error {
+	path, err := projectStatePath(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordPublished saves the image just pushed from a project, so deploy --no-build can use it
+func recordPublished(dir, site, registry, tag, commit string, images []string) error {
+	state, err := loadProjectState(dir)
+	if err != nil {
+		state = &projectState{}
+	}
+
+	state.Published = &publishedImage{
+		Site:        site,
+		Registry:    registry,
+		Tag:         tag,
+		Commit:      commit,
+		Images:      images,
+		PublishedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	return saveProjectState(dir, state)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"lightspeed/core/lib/properties"
+)
+
+// Project types (type in site.properties)
+const (
+	siteTypePHP    = "php"
+	siteTypeStatic = "static"
+)
+
+// staticServerImage serves static sites, in development and in their built image
+const staticServerImage = "nginx:alpine"
+
+// staticNginxConfig serves the web root without PHP
+// Pages resolve with or without .html, pre-compressed .gz files are served when present,
+// and dotfiles (except .well-known) and site.properties are hidden. /__lightspeed serves the
+// deploy metadata written by staticMetadataScript, which also writes the edge origin lock (the
+// include matches nothing without it, e.g. in development).
+const staticNginxConfig = `server {
+    listen 80;
+    root /var/www/html;
+    index index.html index.htm;
+    gzip_static on;
+
+    include /etc/nginx/lightspeed-edge*.conf;
+
+    location = /__lightspeed {
+        default_type application/json;
+        add_header Cache-Control no-store;
+        alias /etc/nginx/lightspeed.json;
+    }
+
+    location / {
+        try_files $uri $uri/ $uri.html =404;
+    }
+
+    location ~ /\.(?!well-known/) {
+        return 404;
+    }
+
+    location = /site.properties {
+        return 404;
+    }
+
+    error_page 404 /404.html;
+}
+`
+
+// staticMetadataScript writes the deploy metadata the operator sets in the environment for
+// /__lightspeed, the static counterpart of the PHP library's deploy.php, and for sites in edge
+// mode the check that rejects requests without the edge key, like the PHP server's start script
+// The nginx image runs the scripts in /docker-entrypoint.d when the container starts.
+const staticMetadataScript = `#!/bin/sh
+printf '{"site":"%s","version":"%s","commit":"%s","deployed_at":"%s","release":"%s"}\n' \
+    "$LIGHTSPEED_SITE" "$LIGHTSPEED_VERSION" "$LIGHTSPEED_COMMIT" "$LIGHTSPEED_DEPLOYED_AT" "$LIGHTSPEED_RELEASE" \
+    > /etc/nginx/lightspeed.json
+rm -f /etc/nginx/lightspeed-edge.conf
+if [ -n "$LIGHTSPEED_EDGE_KEY" ]; then
+    printf 'if ($http_x_lightspeed_edge_key != "%s") {\n    return 403;\n}\n' "$LIGHTSPEED_EDGE_KEY" > /etc/nginx/lightspeed-edge.conf
+fi
+`
+
+// staticCompressed lists the file types pre-compressed in static site images
+var staticCompressed = []string{"html", "htm", "css", "js", "mjs", "json", "xml", "svg", "txt", "map"}
+
+// validateSiteType checks the type in site.properties (empty is php)
+func validateSiteType(siteType string) error {
+	switch siteType {
+	case "", siteTypePHP, siteTypeStatic:
+		return nil
+	}
+	return fmt.Errorf("unknown type '%s' (types: %s, %s)", siteType, siteTypePHP, siteTypeStatic)
+}
+
+// isStaticSite checks if a project is a static site
+func isStaticSite(siteInfo *SiteInfo) bool {
+	return siteInfo != nil && siteInfo.Type == siteTypeStatic
+}
+
+// getSiteType loads the type property from site.properties if it exists
+func getSiteType(dir string) string {
+	propsPath := properties.ProjectFile(dir)
+	if !properties.FileExists(propsPath) {
+		return ""
+	}
+
+	props, err := parseProjectFile(propsPath)
+	if err != nil {
+		return ""
+	}
+
+	return props.Get("type")
+}
+
+// staticServerCommand runs nginx with the static site configuration
+// The configuration is written by the command, so nothing is added to the project directory
+func staticServerCommand() []string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(staticNginxConfig))
+	return []string{"sh", "-c", fmt.Sprintf("echo %s | base64 -d > /etc/nginx/conf.d/default.conf && exec nginx -g 'daemon off;'", encoded)}
+}
+
+// staticBaseImage returns the base image of static sites: nginx unless an image is set
+func staticBaseImage(siteImage string) string {
+	if buildImage != "" || siteImage != "" {
+		return getBaseImage(siteImage)
+	}
+	return staticServerImage
+}
+
+// generateStaticDockerfile returns the generated Dockerfile of static sites: nginx serving
+// the project files, with generated files and (with compress) .gz variants of text assets
+func generateStaticDockerfile(siteImage string, generated map[string]string, compress bool) string {
+	baseImage := staticBaseImage(siteImage)
+
+	var steps strings.Builder
+	if len(generated) > 0 {
+		names := make([]string, 0, len(generated))
+		for name := range generated {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		steps.WriteString("# Write generated files\n")
+		for _, name := range names {
+			encoded := base64.StdEncoding.EncodeToString([]byte(generated[name]))
+			fmt.Fprintf(&steps, "RUN echo %s | base64 -d > /var/www/html/%s\n", encoded, name)
+		}
+		steps.WriteString("\n")
+	}
+	if compress {
+		names := make([]string, 0, len(staticCompressed))
+		for _, ext := range staticCompressed {
+			names = append(names, fmt.Sprintf("-name '*.%s'", ext))
+		}
+		steps.WriteString("# Pre-compress static assets\n")
+		fmt.Fprintf(&steps, "RUN find /var/www/html -type f \\( %s \\) -exec gzip -k -f -9 {} +\n\n", strings.Join(names, " -o "))
+	}
+
+	return fmt.Sprintf(`FROM %s
+
+# Configure nginx
+RUN echo %s | base64 -d > /etc/nginx/conf.d/default.conf
+
+# Write the deploy metadata served at /__lightspeed on start
+RUN mkdir -p /docker-entrypoint.d && \
+    echo %s | base64 -d > /docker-entrypoint.d/40-lightspeed.sh && \
+    chmod 755 /docker-entrypoint.d/40-lightspeed.sh
+
+# Copy project files
+COPY . /var/www/html/
+RUN rm -f /var/www/html/site.properties /var/www/html/lightspeed.yaml
+
+%s# Expose port 80
+EXPOSE 80
+`, baseImage, base64.StdEncoding.EncodeToString([]byte(staticNginxConfig)),
+		base64.StdEncoding.EncodeToString([]byte(staticMetadataScript)), steps.String())
+}
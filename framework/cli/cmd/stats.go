@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/ui"
+)
+
+var (
+	statsCommand string
+	statsLimit   int
+)
+
+// timedCommands are the commands whose timings are recorded, in display order
+var timedCommands = []string{"build", "publish", "deploy"}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show build, publish and deploy times of the project",
+	Long:  "Show how long recent builds, publishes and deploys of the project took, phase by phase, with averages and the trend between older and newer runs. Useful for judging the impact of caching.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		commands := timedCommands
+		if statsCommand != "" {
+			if !containsString(timedCommands, statsCommand) {
+				ui.PrintError("Invalid --command '%s' (commands: %s)", statsCommand, strings.Join(timedCommands, ", "))
+				os.Exit(1)
+			}
+			commands = []string{statsCommand}
+		}
+
+		state, err := loadProjectState(dir)
+		if err != nil {
+			ui.PrintError("Failed to load project state: %v", err)
+			os.Exit(1)
+		}
+
+		shown := false
+		for _, command := range commands {
+			timings := timingsOf(state.Timings, command)
+			if len(timings) == 0 {
+				continue
+			}
+			if statsLimit > 0 && len(timings) > statsLimit {
+				timings = timings[len(timings)-statsLimit:]
+			}
+			printStats(command, timings)
+			shown = true
+		}
+
+		if !shown {
+			ui.PrintInfo("No timings recorded for this project yet")
+			ui.PrintInfo("Timings are saved by every build, publish and deploy")
+		}
+		fmt.Println()
+	},
+}
+
+// printStats prints the timed runs of a command as a table, oldest first, with averages and the trend
+func printStats(command string, timings []commandTiming) {
+	// Columns are the phases in the order they first appear
+	var phases []string
+	for _, timing := range timings {
+		for _, phase := range timing.Phases {
+			if !containsString(phases, phase.Name) {
+				phases = append(phases, phase.Name)
+			}
+		}
+	}
+
+	ui.PrintInfo("%s (%d runs)", command, len(timings))
+	header := fmt.Sprintf("  %-12s %-16s", "when", "tag")
+	for _, phase := range phases {
+		header += fmt.Sprintf(" %8s", phase)
+	}
+	fmt.Println(ui.Muted(header + fmt.Sprintf(" %8s", "total")))
+
+	for _, timing := range timings {
+		when := timing.At
+		if at, err := time.Parse(time.RFC3339, timing.At); err == nil {
+			when = at.Local().Format("Jan 02 15:04")
+		}
+		row := fmt.Sprintf("  %-12s %-16s", when, truncateString(timing.Tag, 16))
+		for _, phase := range phases {
+			value := "-"
+			if ms, ok := timing.Phase(phase); ok {
+				value = formatMs(ms)
+			}
+			row += fmt.Sprintf(" %8s", value)
+		}
+		fmt.Println(row + fmt.Sprintf(" %8s", formatMs(timing.TotalMs)))
+	}
+
+	row := fmt.Sprintf("  %-12s %-16s", "average", "")
+	for _, phase := range phases {
+		row += fmt.Sprintf(" %8s", formatMs(averagePhase(timings, phase)))
+	}
+	fmt.Println(row + fmt.Sprintf(" %8s", formatMs(averagePhase(timings, ""))))
+
+	// The trend compares the newer half of the runs with the older half
+	if len(timings) >= 4 {
+		half := len(timings) / 2
+		older, newer := averagePhase(timings[:half], ""), averagePhase(timings[len(timings)-half:], "")
+		fmt.Printf("  %-12s %s%s\n", "recent avg", formatMs(newer), ui.Muted(fmt.Sprintf(" (%s%s vs the older %d runs)", sign(newer-older), formatMs(abs64(newer-older)), half)))
+	}
+	fmt.Println()
+}
+
+// containsString checks if a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateString shortens a string to n characters, marking the cut with ~
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "~"
+}
+
+func init() {
+	statsCmd.Flags().StringVar(&statsCommand, "command", "", "Only show one command: build, publish or deploy")
+	statsCmd.Flags().IntVar(&statsLimit, "limit", 10, "Number of recent runs to show per command (0 for all)")
+
+	rootCmd.AddCommand(statsCmd)
+}
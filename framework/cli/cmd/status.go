@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/ui"
+)
+
+// siteStatusInterval is how often site status is polled while waiting or watching
+const siteStatusInterval = 3 * time.Second
+
+var statusWatch bool
+
+var statusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Short: "Show the deployment status of a site",
+	Long:  "Show the deployment phase, active deployment, instance count and URLs of a site. With --watch, keep refreshing until the deployment reaches a terminal state.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+		ctx := cmd.Context()
+		backend := newBackend()
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		siteName, err := resolveSiteName(dir, name)
+		if err != nil {
+			ui.PrintError("Failed to load site.properties: %v", err)
+			os.Exit(1)
+		}
+
+		status, err := backend.GetSiteStatus(ctx, siteName)
+		if err != nil {
+			ui.PrintError("Failed to get status of '%s': %v", siteName, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+		printSiteStatus(status)
+
+		if !statusWatch || siteStatusSettled(status) {
+			if jsonOutput() {
+				printJSON(status)
+			}
+			return
+		}
+
+		fmt.Println()
+		spinner := ui.StartSpinner("Watching for changes")
+		last := siteStatusSummary(status)
+		err = pollSiteStatus(ctx, backend, siteName, 0, func(latest *api.SiteResponse) (bool, error) {
+			if summary := siteStatusSummary(latest); summary != last {
+				ui.Stdout.Println("")
+				printSiteStatus(latest)
+				last = summary
+			}
+			status = latest
+			return siteStatusSettled(latest), nil
+		})
+		spinner.Stop()
+		if err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("")
+			}
+			ui.PrintError("Failed to watch '%s': %v", siteName, err)
+			printErrorHint(err)
+			os.Exit(1)
+		}
+
+		fmt.Println()
+		if jsonOutput() {
+			printJSON(status)
+		}
+		if status.Status == "ERROR" || status.Status == "FAILED" {
+			ui.PrintError("Deployment failed")
+			ui.PrintInfo("Run 'lightspeed logs -t build' to see why")
+			os.Exit(1)
+		}
+		ui.PrintSuccess("Deployment settled")
+		fmt.Println()
+	},
+}
+
+// pollSiteStatus gets a site's status every siteStatusInterval until check reports done or fails
+// Errors getting the status are retried, since a new site might not be ready yet.
+// A zero timeout polls until done or the context is canceled.
+func pollSiteStatus(ctx context.Context, backend Backend, name string, timeout time.Duration, check func(*api.SiteResponse) (bool, error)) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	interval := siteStatusInterval
+	if replaying() {
+		interval = replayPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("deployment timed out after %d minutes", int(timeout.Minutes()))
+		case <-ticker.C:
+			status, err := backend.GetSiteStatus(ctx, name)
+			if err != nil {
+				continue
+			}
+
+			done, err := check(status)
+			if err != nil || done {
+				return err
+			}
+		}
+	}
+}
+
+// siteStatusSettled checks if a site has no deployment in progress and is in a terminal phase
+func siteStatusSettled(status *api.SiteResponse) bool {
+	if status.InProgress != nil {
+		return false
+	}
+	switch status.Status {
+	case "ACTIVE", "ERROR", "FAILED", "CANCELED":
+		return true
+	}
+	return false
+}
+
+// sitePhase returns the phase of a site's deployment in progress, or else of its active one
+// App Platform keeps the last deployment active while a new one builds and deploys
+func sitePhase(status *api.SiteResponse) string {
+	if status.InProgress != nil && status.InProgress.Status != "" {
+		return status.InProgress.Status
+	}
+	return status.Status
+}
+
+// siteStatusSummary returns the status fields a watch reprints on change
+func siteStatusSummary(status *api.SiteResponse) string {
+	summary := fmt.Sprintf("%s|%s|%d", status.Status, status.DeploymentID, status.Instances)
+	if status.InProgress != nil {
+		summary += "|" + status.InProgress.DeploymentID + "|" + status.InProgress.Status
+	}
+	return summary
+}
+
+// printSiteStatus prints a site's deployment status
+func printSiteStatus(status *api.SiteResponse) {
+	ui.PrintKeyValue("Site", status.Name)
+	ui.PrintKeyValue("Status", formatStatus(status.Status))
+	if status.DeploymentID != "" {
+		ui.PrintKeyValue("Deployment", status.DeploymentID)
+	}
+	if status.Release != "" {
+		ui.PrintKeyValue("Release", fmt.Sprintf("%s (%s)", status.Release, status.Tag))
+	}
+	if status.Commit != "" {
+		ui.PrintKeyValue("Commit", status.Commit)
+	}
+	if status.DeployedAt != "" {
+		ui.PrintKeyValue("Deployed", status.DeployedAt)
+	}
+	if status.Serving != "" && status.Serving != status.Tag {
+		ui.PrintKeyValue("Serving", status.Serving+" (last uptime check)")
+	}
+	if status.InProgress != nil {
+		ui.PrintKeyValue("In progress", fmt.Sprintf("%s (%s)", status.InProgress.DeploymentID, formatStatus(status.InProgress.Status)))
+	}
+	if status.Instances > 0 {
+		instances := fmt.Sprintf("%d", status.Instances)
+		if status.Size != "" {
+			instances += " x " + status.Size
+		}
+		ui.PrintKeyValue("Instances", instances)
+	}
+	if service := status.Service; service != nil {
+		summary := fmt.Sprintf("%s at %s (%s:%s, port %d", service.Name, service.Path, service.Image, service.Tag, service.Port)
+		if service.Instances > 0 {
+			summary += fmt.Sprintf(", %d x %s", service.Instances, service.Size)
+		}
+		ui.PrintKeyValue("Service", summary+")")
+	}
+	if status.Domain != "" {
+		ui.PrintKeyValue("URL", "https://"+status.Domain)
+	}
+	if len(status.URLs) > 0 {
+		ui.PrintKeyValue("App URLs", strings.Join(status.URLs, ", "))
+	}
+	if status.UpdatedAt != "" {
+		ui.PrintKeyValue("Updated", status.UpdatedAt)
+	}
+	if status.ExpiresAt != "" {
+		ui.PrintKeyValue("Expires", status.ExpiresAt)
+	}
+}
+
+func init() {
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Keep refreshing until the deployment reaches a terminal state (printed once settled with --output json)")
+
+	supportsJSON(statusCmd)
+
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"lightspeed/core/lib/version"
+)
+
+// Tag strategies, selected with tag.strategy in site.properties
+const (
+	tagStrategyGitDescribe = "git-describe" // 1.2.3 or 1.2.3-5 from git describe (default)
+	tagStrategyGitSHA      = "git-sha"      // Short commit SHA
+	tagStrategyDate        = "date"         // 2024.06.18-1, numbered within the day
+	tagStrategyBuild       = "build"        // Monotonic build number: 1, 2, 3...
+)
+
+// resolveTag returns the image tag to use
+// Priority: explicit tag > the site's tag strategy; strategies based on git fall back to
+// "latest" outside a git repository
+func resolveTag(ctx context.Context, dir, siteName, tag string) (string, error) {
+	if tag != "" {
+		return tag, nil
+	}
+
+	strategy := tagStrategyGitDescribe
+	if siteInfo, err := loadSiteInfo(dir); err == nil && siteInfo != nil && siteInfo.TagStrategy != "" {
+		strategy = siteInfo.TagStrategy
+	}
+
+	switch strategy {
+	case tagStrategyGitDescribe:
+		if version.IsGitRepo(dir) {
+			if v, err := version.GetFromGit(dir); err == nil {
+				return v.String(), nil
+			}
+		}
+		return "latest", nil
+	case tagStrategyGitSHA:
+		if version.IsGitRepo(dir) {
+			return version.GetCommit(dir)
+		}
+		return "latest", nil
+	case tagStrategyDate, tagStrategyBuild:
+		return allocateTag(ctx, siteName, strategy)
+	}
+
+	return "", validateTagStrategy(strategy)
+}
+
+// buildCommit returns the git commit a project is built from, recorded with its deploys
+// Returns "" outside a git repository
+func buildCommit(dir string) string {
+	if !version.IsGitRepo(dir) {
+		return ""
+	}
+	commit, err := version.GetCommit(dir)
+	if err != nil {
+		return ""
+	}
+	return commit
+}
+
+// validateTagStrategy checks a tag.strategy is one of the known strategies
+func validateTagStrategy(strategy string) error {
+	switch strategy {
+	case "", tagStrategyGitDescribe, tagStrategyGitSHA, tagStrategyDate, tagStrategyBuild:
+		return nil
+	}
+	return fmt.Errorf("unknown tag.strategy '%s' (use %s, %s, %s or %s)",
+		strategy, tagStrategyGitDescribe, tagStrategyGitSHA, tagStrategyDate, tagStrategyBuild)
+}
+
+// allocateTag gets the site's next build number or dated tag from the operator
+// The operator hands out each number once, so builds running in parallel never share a tag
+func allocateTag(ctx context.Context, siteName, strategy string) (string, error) {
+	allocation, err := newBackend().AllocateTag(ctx, siteName, strategy)
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate tag: %w", err)
+	}
+	return allocation.Tag, nil
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+// timingHistory is how many timed runs are kept per project
+const timingHistory = 50
+
+// showTimings prints the timing summary after build, publish and deploy (also the timings setting)
+var showTimings bool
+
+// commandTiming is how long a build, publish or deploy took, phase by phase
+type commandTiming struct {
+	Command string        `json:"command"`
+	Tag     string        `json:"tag,omitempty"`
+	Phases  []phaseTiming `json:"phases"`
+	TotalMs int64         `json:"total_ms"`
+	At      string        `json:"at"`
+}
+
+// phaseTiming is how long a phase of a command took
+type phaseTiming struct {
+	Name string `json:"name"`
+	Ms   int64  `json:"ms"`
+}
+
+// Phase returns the duration of a phase in milliseconds (false if the run didn't have it)
+func (t commandTiming) Phase(name string) (int64, bool) {
+	for _, phase := range t.Phases {
+		if phase.Name == name {
+			return phase.Ms, true
+		}
+	}
+	return 0, false
+}
+
+// phaseTimer times the phases of a command
+// Phases with the same name add up, so a phase can be timed in several parts
+type phaseTimer struct {
+	timing  commandTiming
+	started time.Time
+	phase   string
+	since   time.Time
+}
+
+// newPhaseTimer starts timing a command
+func newPhaseTimer(command string) *phaseTimer {
+	now := time.Now()
+	return &phaseTimer{timing: commandTiming{Command: command}, started: now, since: now}
+}
+
+// Start ends the current phase and starts the named one
+func (t *phaseTimer) Start(phase string) {
+	t.Stop()
+	t.phase = phase
+	t.since = time.Now()
+}
+
+// Stop ends the current phase
+func (t *phaseTimer) Stop() {
+	if t.phase != "" {
+		t.Add(t.phase, time.Since(t.since))
+		t.phase = ""
+	}
+}
+
+// Add adds time to a phase
+func (t *phaseTimer) Add(phase string, d time.Duration) {
+	for i := range t.timing.Phases {
+		if t.timing.Phases[i].Name == phase {
+			t.timing.Phases[i].Ms += d.Milliseconds()
+			return
+		}
+	}
+	t.timing.Phases = append(t.timing.Phases, phaseTiming{Name: phase, Ms: d.Milliseconds()})
+}
+
+// Finish ends the command and saves its timing to the project state
+// The summary is printed if timings are turned on
+func (t *phaseTimer) Finish(dir, tag string) {
+	t.Stop()
+	t.timing.Tag = tag
+	t.timing.TotalMs = time.Since(t.started).Milliseconds()
+	t.timing.At = time.Now().UTC().Format(time.RFC3339)
+
+	previous, err := recordTiming(dir, t.timing)
+	if err != nil {
+		ui.PrintWarning("Failed to save timings: %v", err)
+	}
+	if timingsEnabled() {
+		printTimingSummary(t.timing, previous)
+		fmt.Println()
+	}
+}
+
+// deployPhase returns the phase a deploy step is counted in
+// Creating or deploying the site counts as waiting, and verify waits for DNS and the site to respond
+func deployPhase(step string) string {
+	switch step {
+	case "ensure-site", "wait-deploy":
+		return "wait"
+	case "verify":
+		return "dns"
+	}
+	return step
+}
+
+// timingsEnabled checks if the timing summary is turned on by --timings or the timings setting
+func timingsEnabled() bool {
+	return showTimings || cliConfig.Timings == "true"
+}
+
+// recordTiming adds a command's timing to the project state
+// Returns the earlier timings of the same command, oldest first
+func recordTiming(dir string, timing commandTiming) ([]commandTiming, error) {
+	state, err := loadProjectState(dir)
+	if err != nil {
+		state = &projectState{}
+	}
+
+	previous := timingsOf(state.Timings, timing.Command)
+	state.Timings = append(state.Timings, timing)
+	if len(state.Timings) > timingHistory {
+		state.Timings = state.Timings[len(state.Timings)-timingHistory:]
+	}
+	return previous, saveProjectState(dir, state)
+}
+
+// timingsOf returns the timings of a command
+func timingsOf(timings []commandTiming, command string) []commandTiming {
+	var matching []commandTiming
+	for _, timing := range timings {
+		if timing.Command == command {
+			matching = append(matching, timing)
+		}
+	}
+	return matching
+}
+
+// printTimingSummary prints a command's phases and total, compared with the average of its earlier runs
+func printTimingSummary(timing commandTiming, previous []commandTiming) {
+	ui.PrintInfo("Timings")
+	for _, phase := range timing.Phases {
+		fmt.Printf("  %-8s %8s%s\n", phase.Name, formatMs(phase.Ms), timingChange(phase.Ms, averagePhase(previous, phase.Name)))
+	}
+	fmt.Printf("  %-8s %8s%s\n", "total", formatMs(timing.TotalMs), timingChange(timing.TotalMs, averagePhase(previous, "")))
+}
+
+// averagePhase returns the average duration of a phase over timed runs (the total if phase is empty)
+// Returns -1 if no run had the phase
+func averagePhase(timings []commandTiming, phase string) int64 {
+	var sum, count int64
+	for _, timing := range timings {
+		ms, ok := timing.TotalMs, true
+		if phase != "" {
+			ms, ok = timing.Phase(phase)
+		}
+		if ok {
+			sum += ms
+			count++
+		}
+	}
+	if count == 0 {
+		return -1
+	}
+	return sum / count
+}
+
+// timingChange formats the change of a duration from its average (empty without an average)
+func timingChange(ms, average int64) string {
+	if average < 0 {
+		return ""
+	}
+	diff := ms - average
+	return ui.Muted(fmt.Sprintf(" (%s%s vs avg)", sign(diff), formatMs(abs64(diff))))
+}
+
+// formatMs formats milliseconds as a rounded duration
+func formatMs(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"lightspeed/core/lib/ui"
+)
+
+const (
+	// updateCheckFile caches the latest release, so GitHub is asked at most once a day
+	updateCheckFile = ".lightspeed/update-check.json"
+	// updateCheckEnv turns off the update check when set (e.g. LIGHTSPEED_NO_UPDATE_CHECK=1)
+	updateCheckEnv      = "LIGHTSPEED_NO_UPDATE_CHECK"
+	updateCheckInterval = 24 * time.Hour
+	latestReleaseURL    = "https://api.github.com/repos/abrayall/lightspeed/releases/latest"
+)
+
+// updateCheck is the cached result of the last update check
+type updateCheck struct {
+	Latest    string    `json:"latest"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// latestVersion receives the latest release found by the update check ("" if unknown)
+var latestVersion chan string
+
+var versionRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// startUpdateCheck looks up the latest release in the background while the command runs
+// The cached release is used for a day; dev builds, CI mode, JSON and quiet output skip the check
+func startUpdateCheck() {
+	if os.Getenv(updateCheckEnv) != "" || ciMode || jsonOutput() || ui.Quiet() || parseVersion(Version) == nil {
+		return
+	}
+
+	latestVersion = make(chan string, 1)
+	go func() {
+		path, err := updateCheckPath()
+		if err != nil {
+			latestVersion <- ""
+			return
+		}
+
+		var check updateCheck
+		if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &check) == nil &&
+			time.Since(check.CheckedAt) < updateCheckInterval {
+			latestVersion <- check.Latest
+			return
+		}
+
+		latest, err := fetchLatestRelease()
+		if err != nil {
+			ui.PrintDebug("Update check failed: %v", err)
+			latestVersion <- ""
+			return
+		}
+		saveUpdateCheck(path, updateCheck{Latest: latest, CheckedAt: time.Now()})
+		latestVersion <- latest
+	}()
+}
+
+// printUpdateHint prints a line at the end of the command's output when a newer release is out
+// It doesn't hold up the command: a check still running a second after the command finished is dropped
+func printUpdateHint() {
+	if latestVersion == nil {
+		return
+	}
+
+	var latest string
+	select {
+	case latest = <-latestVersion:
+	case <-time.After(time.Second):
+		return
+	}
+
+	if newerVersion(latest, Version) {
+		fmt.Fprintln(os.Stderr, ui.Muted(fmt.Sprintf("A new version of lightspeed is available: %s (you have %s), set %s=1 to stop checking",
+			latest, getBaseVersion(), updateCheckEnv)))
+	}
+}
+
+// fetchLatestRelease gets the version of the latest release from GitHub
+func fetchLatestRelease() (string, error) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(latestReleaseURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	if parseVersion(release.TagName) == nil {
+		return "", fmt.Errorf("unexpected release tag '%s'", release.TagName)
+	}
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
+
+// saveUpdateCheck caches the result of an update check (errors are ignored, the check runs again next time)
+func saveUpdateCheck(path string, check updateCheck) {
+	data, err := json.Marshal(check)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// updateCheckPath returns the path of the update check cache
+func updateCheckPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, updateCheckFile), nil
+}
+
+// newerVersion checks if version latest is newer than current (false if either isn't a version)
+func newerVersion(latest, current string) bool {
+	l, c := parseVersion(latest), parseVersion(current)
+	if l == nil || c == nil {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses the major, minor and patch numbers of a version (e.g. "v0.5.3-12031417"), nil if it isn't one
+func parseVersion(version string) []int {
+	matches := versionRegex.FindStringSubmatch(version)
+	if matches == nil {
+		return nil
+	}
+	numbers := make([]int, 3)
+	for i := range numbers {
+		numbers[i], _ = strconv.Atoi(matches[i+1])
+	}
+	return numbers
+}
@@ -0,0 +1,432 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/registry"
+	"lightspeed/core/lib/ui"
+)
+
+// siteKeys are the site.properties keys read by the CLI and the PHP library
+var siteKeys = []string{
+	"name", "domain", "domains", "base_domain", "template", "region", "port", "instances", "size", "environment", "email",
+	"image", "type", "libraries", "tag.strategy", "compress", "composer", "sitemap", "services",
+	"resolvers", "slo", "edge", "edge.auth", "edge.maintenance", "edge.log",
+	"firewall.allow", "firewall.deny", "hooks.purge", "hooks.indexnow", "hooks.ping",
+	"perf.ttfb", "perf.weight", "perf.requests", "perf.fail",
+	"service.image", "service.name", "service.tag", "service.port", "service.path", "service.instances", "service.size",
+}
+
+// siteKeyPrefixes start keys named by the site: cron jobs, environment variables, ingress paths,
+// labels and library settings
+var siteKeyPrefixes = []string{"cron.", "env.", "ingress.", "label.", "smtp.", "velocity."}
+
+// siteNamespaces are owned by lightspeed, so an unknown key in them is a mistake rather than a
+// setting of the site's own
+var siteNamespaces = []string{"edge.", "environments.", "firewall.", "hooks.", "perf.", "service.", "tag."}
+
+// siteBoolKeys only take true or false
+var siteBoolKeys = []string{"compress", "composer", "sitemap", "edge", "edge.maintenance", "edge.log", "hooks.purge", "perf.fail"}
+
+var (
+	siteNamePattern  = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	domainPattern    = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	imageTagPattern  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	imageRepoPattern = regexp.MustCompile(`^[a-z0-9]+([._/-][a-z0-9]+)*$`)
+)
+
+// siteProblem is something wrong in site.properties
+type siteProblem struct {
+	Message string
+	Warning bool // Doesn't stop build, publish or deploy
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check site.properties for mistakes",
+	Long:  "Check site.properties for unknown keys, malformed domains, bad image references, invalid library specs and invalid settings. build, publish and deploy run the same checks before they start.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+		propsPath := properties.ProjectFile(dir)
+		if !properties.FileExists(propsPath) {
+			ui.PrintError("No site.properties or lightspeed.yaml in %s", dir)
+			ui.PrintInfo("Run 'lightspeed init' to create a site project")
+			os.Exit(1)
+		}
+		file := filepath.Base(propsPath)
+
+		problems, err := validateSite(dir)
+		if err != nil {
+			ui.PrintError("Failed to parse %s: %v", file, err)
+			os.Exit(1)
+		}
+
+		errors, warnings := printSiteProblems("", file, problems)
+		if errors > 0 {
+			fmt.Println()
+			ui.PrintError("%s has %d errors and %d warnings", file, errors, warnings)
+			fmt.Println()
+			os.Exit(1)
+		}
+		if warnings > 0 {
+			fmt.Println()
+			ui.PrintSuccess("%s is valid, with %d warnings", file, warnings)
+		} else {
+			ui.PrintSuccess("%s is valid", file)
+		}
+		fmt.Println()
+	},
+}
+
+// checkSiteProperties validates site.properties (or lightspeed.yaml) before build, publish and deploy
+// Warnings are printed and the command goes on; errors exit before anything is built
+func checkSiteProperties(dir string) {
+	file := filepath.Base(properties.ProjectFile(dir))
+	problems, err := validateSite(dir)
+	if err != nil {
+		ui.PrintError("Failed to parse %s: %v", file, err)
+		os.Exit(1)
+	}
+	if errors, warnings := printSiteProblems("", file, problems); errors > 0 {
+		ui.PrintInfo("Fix %s, then run 'lightspeed validate' to check it", file)
+		os.Exit(1)
+	} else if warnings > 0 {
+		fmt.Println()
+	}
+}
+
+// printSiteProblems prints the problems found in a site's project file, prefixed with
+// the site for workspaces, and returns the number of errors and warnings
+func printSiteProblems(site, file string, problems []siteProblem) (int, int) {
+	prefix := file + ": "
+	if site != "" {
+		prefix = site + "/" + file + ": "
+	}
+
+	errors, warnings := 0, 0
+	for _, problem := range problems {
+		if problem.Warning {
+			ui.PrintWarning("%s%s", prefix, problem.Message)
+			warnings++
+		} else {
+			ui.PrintError("%s%s", prefix, problem.Message)
+			errors++
+		}
+	}
+	return errors, warnings
+}
+
+// validateSite checks a project's site.properties or lightspeed.yaml (no problems without one)
+// Only a file that can't be parsed is an error; everything else is a problem
+func validateSite(dir string) ([]siteProblem, error) {
+	propsPath := properties.ProjectFile(dir)
+	if !properties.FileExists(propsPath) {
+		return nil, nil
+	}
+	props, err := properties.ParseFile(propsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []siteProblem
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, siteProblem{Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(format string, args ...interface{}) {
+		problems = append(problems, siteProblem{Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	// The manifest replaces site.properties, in the image too
+	if properties.IsManifest(propsPath) && properties.PropertiesFileExists(dir, properties.PropertiesFile) {
+		warn("site.properties is ignored, as the project has a lightspeed.yaml (remove one of them)")
+	}
+
+	// Unknown keys
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if knownSiteKey(key) {
+			continue
+		}
+		suggestion := suggestSiteKey(key)
+		switch {
+		case ownedSiteKey(key) && suggestion != "":
+			fail("unknown key %s (did you mean %s?)", key, suggestion)
+		case ownedSiteKey(key):
+			fail("unknown key %s", key)
+		case suggestion != "":
+			// Other keys can be the site's own settings, read with site()->get()
+			warn("unknown key %s (did you mean %s?)", key, suggestion)
+		}
+	}
+
+	if name := props.Get("name"); name != "" && !siteNamePattern.MatchString(name) {
+		fail("name '%s' must be 1-63 lowercase letters, digits and dashes, e.g. %s", name, suggestSiteName(name))
+	}
+
+	// Domains
+	var domains []string
+	if domain := props.Get("domain"); domain != "" {
+		domains = append(domains, domain)
+	}
+	domains = append(domains, props.GetList("domains")...)
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		if err := validateDomainName(domain); err != nil {
+			fail("%v", err)
+			continue
+		}
+		if seen[strings.ToLower(domain)] {
+			warn("domain %s is listed twice", domain)
+		}
+		seen[strings.ToLower(domain)] = true
+	}
+	if baseDomain := props.Get("base_domain"); baseDomain != "" {
+		if err := validateDomainName(baseDomain); err != nil {
+			fail("base_domain: %v", err)
+		}
+	}
+
+	// Images
+	if image := props.Get("image"); image != "" {
+		if err := validateSiteImage(image); err != nil {
+			fail("image: %v", err)
+		}
+	}
+	if image := props.Get("service.image"); image != "" {
+		if err := validateImageReference(image); err != nil {
+			fail("service.image: %v", err)
+		}
+	}
+
+	// Libraries
+	for _, spec := range strings.Split(props.Get("libraries"), ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			if err := validateLibrarySpec(dir, spec); err != nil {
+				fail("libraries: %v", err)
+			}
+		}
+	}
+
+	for _, key := range siteBoolKeys {
+		if value, ok := props[key]; ok {
+			if _, isBool := value.(bool); !isBool && value != "true" && value != "false" {
+				fail("%s '%v' must be true or false", key, value)
+			}
+		}
+	}
+
+	if err := validateSiteType(props.Get("type")); err != nil {
+		fail("%v", err)
+	}
+	if err := validateTagStrategy(props.Get("tag.strategy")); err != nil {
+		fail("%v", err)
+	}
+	if _, err := getSLOTarget(props); err != nil {
+		fail("slo %v", err)
+	}
+
+	// Environment sections, checked with the settings deploy reads as each environment
+	envs := projectEnvironments(props)
+	for _, env := range envs {
+		if !environmentNamePattern.MatchString(env) {
+			fail("environment '%s' must be 1-20 lowercase letters, digits and dashes", env)
+		}
+	}
+
+	// The settings deploy reads report their own mistakes
+	for _, check := range []func(properties.Properties) error{
+		func(p properties.Properties) error { _, err := getPerfBudget(p); return err },
+		func(p properties.Properties) error { _, err := getDeployHooks(p); return err },
+		func(p properties.Properties) error { _, err := getSiteService(p); return err },
+		func(p properties.Properties) error { _, err := getSiteIngress(p); return err },
+		func(p properties.Properties) error { _, err := getSiteRuntime(p); return err },
+		func(p properties.Properties) error { _, err := getSiteEdge(p); return err },
+		func(p properties.Properties) error { _, err := getSiteFirewall(p); return err },
+		func(p properties.Properties) error { _, err := getSiteCron(p); return err },
+		func(p properties.Properties) error { _, err := getDevServices(p); return err },
+	} {
+		err := check(props)
+		if err != nil {
+			fail("%v", err)
+		}
+		// A mistake an environment inherits is only reported once
+		for _, env := range envs {
+			if envErr := check(forEnvironment(props, env, filepath.Base(dir))); envErr != nil && (err == nil || envErr.Error() != err.Error()) {
+				fail("environments.%s: %v", env, envErr)
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// knownSiteKey checks if a key is read by the CLI or the PHP library
+// A key of an environment section is known if the key it overrides is
+func knownSiteKey(key string) bool {
+	if rest, ok := strings.CutPrefix(key, environmentsPrefix); ok {
+		_, inner, found := strings.Cut(rest, ".")
+		return found && !strings.HasPrefix(inner, environmentsPrefix) && knownSiteKey(inner)
+	}
+	for _, known := range siteKeys {
+		if key == known {
+			return true
+		}
+	}
+	for _, prefix := range siteKeyPrefixes {
+		if strings.HasPrefix(key, prefix) || key == strings.TrimSuffix(prefix, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedSiteKey checks if a key is in one of lightspeed's namespaces
+func ownedSiteKey(key string) bool {
+	for _, namespace := range siteNamespaces {
+		if strings.HasPrefix(key, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestSiteKey returns the known key closest to a misspelled one ("" if none is close)
+func suggestSiteKey(key string) string {
+	best, bestDistance := "", 3
+	for _, known := range siteKeys {
+		if d := editDistance(strings.ToLower(key), known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// suggestSiteName turns a name into a valid site name (e.g. "My Site" -> "my-site")
+func suggestSiteName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else if !strings.HasSuffix(b.String(), "-") {
+			b.WriteRune('-')
+		}
+	}
+	suggestion := strings.Trim(b.String(), "-")
+	if len(suggestion) > 63 {
+		suggestion = strings.TrimRight(suggestion[:63], "-")
+	}
+	if suggestion == "" {
+		return "my-site"
+	}
+	return suggestion
+}
+
+// validateDomainName checks a domain is a bare host name, saying what to drop if it isn't
+func validateDomainName(domain string) error {
+	switch {
+	case strings.Contains(domain, "://"):
+		return fmt.Errorf("domain '%s' must be a host name without http:// or https:// (e.g. example.com)", domain)
+	case strings.Contains(domain, "/"):
+		return fmt.Errorf("domain '%s' must be a host name without a path (e.g. example.com)", domain)
+	case strings.Contains(domain, ":"):
+		return fmt.Errorf("domain '%s' must be a host name without a port (e.g. example.com)", domain)
+	case !strings.Contains(domain, "."):
+		return fmt.Errorf("domain '%s' must be a full domain name (e.g. example.com)", domain)
+	case len(domain) > 253 || !domainPattern.MatchString(strings.ToLower(domain)):
+		return fmt.Errorf("domain '%s' isn't a valid domain name: use labels of letters, digits and dashes separated by dots", domain)
+	}
+	return nil
+}
+
+// validateSiteImage checks the image property: a server version or a full image reference,
+// as resolveImage reads it
+func validateSiteImage(image string) error {
+	if strings.Contains(image, "/") || strings.Contains(image, ":") {
+		return validateImageReference(image)
+	}
+	if image != "latest" && parseVersion(image) == nil {
+		return fmt.Errorf("'%s' must be a server version (e.g. 0.5.4) or a full image reference (e.g. ghcr.io/acme/server:1.0)", image)
+	}
+	return nil
+}
+
+// validateImageReference checks an image reference is one Docker accepts
+func validateImageReference(image string) error {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return err
+	}
+	if !imageRepoPattern.MatchString(ref.Repository) {
+		return fmt.Errorf("'%s' isn't a valid image reference: the repository must be lowercase letters, digits and separators", image)
+	}
+	if ref.Digest == "" && !imageTagPattern.MatchString(ref.Tag) {
+		return fmt.Errorf("'%s' isn't a valid image reference: the tag must be letters, digits, dots, dashes and underscores", image)
+	}
+	return nil
+}
+
+// validateLibrarySpec checks a libraries entry: lightspeed, lightspeed:version or a path
+// Relative paths are relative to the project
+func validateLibrarySpec(dir, spec string) error {
+	if spec == "lightspeed" {
+		return nil
+	}
+	if version, ok := strings.CutPrefix(spec, "lightspeed:"); ok {
+		if parseVersion(version) == nil {
+			return fmt.Errorf("'%s' must name a library version (e.g. lightspeed:0.5.4)", spec)
+		}
+		return nil
+	}
+
+	path := spec
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return fmt.Errorf("'%s' isn't lightspeed, lightspeed:version or a library directory", spec)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
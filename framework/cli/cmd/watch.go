@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// liveReloadPath is the event stream browsers listen on for reloads
+const liveReloadPath = "/__lightspeed/livereload"
+
+// liveReloadScript is injected into HTML pages served in watch mode
+const liveReloadScript = `<script>(function(){var s=new EventSource("` + liveReloadPath + `");s.onmessage=function(){s.close();location.reload()};})();</script>`
+
+// Polling intervals of the watcher; changes are applied once files stop changing
+const (
+	watchInterval = 500 * time.Millisecond
+	watchSettle   = 300 * time.Millisecond
+)
+
+// watchExtensions are the file types that trigger a reload
+var watchExtensions = map[string]bool{
+	".php": true, ".html": true, ".htm": true, ".css": true, ".js": true, ".mjs": true,
+	".json": true, ".svg": true, ".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+}
+
+// watchSkipDirs are directories whose changes don't affect the site
+var watchSkipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// liveReload proxies the dev container, injecting the reload script into HTML pages,
+// and serves the event stream that tells browsers to reload
+type liveReload struct {
+	proxy *httputil.ReverseProxy
+
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// newLiveReload creates a live reload proxy for the container published on port
+func newLiveReload(port int) *liveReload {
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)}
+	lr := &liveReload{clients: make(map[chan struct{}]bool)}
+
+	lr.proxy = httputil.NewSingleHostReverseProxy(target)
+	director := lr.proxy.Director
+	lr.proxy.Director = func(r *http.Request) {
+		director(r)
+		// Pages must come back uncompressed to inject the script
+		r.Header.Del("Accept-Encoding")
+	}
+	lr.proxy.ModifyResponse = injectLiveReload
+	return lr
+}
+
+func (lr *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == liveReloadPath {
+		lr.serveEvents(w, r)
+		return
+	}
+	lr.proxy.ServeHTTP(w, r)
+}
+
+// serveEvents streams a reload event to a browser when the project changes
+func (lr *liveReload) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	reload := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[reload] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, reload)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-reload:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// Reload tells every connected browser to reload
+func (lr *liveReload) Reload() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// injectLiveReload adds the reload script to HTML responses
+func injectLiveReload(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i], append([]byte(liveReloadScript), body[i:]...)...)
+	} else {
+		body = append(body, liveReloadScript...)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// watchProject polls a project for changes until ctx is done, calling onChange with the
+// changed files once they stop changing
+func watchProject(ctx context.Context, dir string, onChange func(changed []string)) {
+	previous := scanProject(dir)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := scanProject(dir)
+		changed := diffScans(previous, current)
+		if len(changed) == 0 {
+			continue
+		}
+
+		// Wait for editors and tools that write in several steps to finish
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchSettle):
+			}
+			settled := scanProject(dir)
+			more := diffScans(current, settled)
+			current = settled
+			if len(more) == 0 {
+				break
+			}
+			changed = append(changed, more...)
+		}
+
+		previous = current
+		onChange(changed)
+	}
+}
+
+// scanProject returns the modification time and size of every watched file
+func scanProject(dir string) map[string]string {
+	files := make(map[string]string)
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || watchSkipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name != properties.PropertiesFile && name != properties.ManifestFile && !watchExtensions[strings.ToLower(filepath.Ext(name))] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		// Paths use / on every OS
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+		return nil
+	})
+	return files
+}
+
+// diffScans lists the files added, changed or removed between two scans
+func diffScans(before, after map[string]string) []string {
+	var changed []string
+	for path, stamp := range after {
+		if before[path] != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// serveWatch reloads browsers when project files change, re-creating the dev container
+// first when site.properties or lightspeed.yaml changes (e.g. a new base image). Runs until ctx is done.
+func serveWatch(ctx context.Context, lr *liveReload, dir, containerName string, containerPort int) {
+	ui.PrintInfo("Watching for changes (Ctrl-C to stop watching)...")
+	fmt.Println()
+
+	watchProject(ctx, dir, func(changed []string) {
+		restart := false
+		for _, path := range changed {
+			if path == properties.PropertiesFile || path == properties.ManifestFile {
+				restart = true
+			}
+		}
+
+		if restart {
+			ui.PrintInfo("Project settings changed, restarting container...")
+			serverImage, command := getDevServer(dir)
+			binds := withDevProperties(dir, containerName, []string{fmt.Sprintf("%s:/var/www/html", dir)})
+			stopContainer(containerName)
+			stopDevServices(containerName)
+			network, env, err := startProjectServices(dir, containerName, runWithDB)
+			if err != nil {
+				ui.PrintError("Failed to start services: %v", err)
+				return
+			}
+			if output, err := runDevContainer(containerName, containerPort, binds, serverImage, command, network, env); err != nil {
+				ui.PrintError("Failed to restart container: %v", err)
+				ui.PrintError("%s", string(output))
+				return
+			}
+			waitForServer(ctx, fmt.Sprintf("http://localhost:%d", containerPort), 30)
+		}
+
+		fmt.Printf("  %s %s\n", ui.Muted(time.Now().Format("15:04:05")), changeSummary(changed))
+		lr.Reload()
+	})
+}
+
+// changeSummary describes changed files by the first one and how many more changed
+func changeSummary(changed []string) string {
+	if len(changed) > 1 {
+		return fmt.Sprintf("%s and %d more", changed[0], len(changed)-1)
+	}
+	return changed[0]
+}
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+)
+
+// workspaceSite is a site project found in a workspace directory
+type workspaceSite struct {
+	Dir        string
+	Name       string
+	Tag        string
+	Image      string
+	Domains    []string
+	Resolvers  []string
+	Template   string
+	BaseDomain string
+	Region     string
+	Runtime    siteRuntime
+	Edge       *api.SiteEdge
+	Firewall   *api.SiteFirewall
+	Cron       []api.CronJob
+}
+
+// deployResult holds the outcome of deploying a single workspace site
+type deployResult struct {
+	Site     *workspaceSite
+	URL      string
+	Err      error
+	Step     string // Step that failed: build, push or ensure-site
+	Duration time.Duration
+}
+
+// findWorkspaceSites returns the site projects in the immediate subdirectories of dir
+// A subdirectory is a site project if it contains a site.properties file
+func findWorkspaceSites(ctx context.Context, dir string) ([]*workspaceSite, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sites []*workspaceSite
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		siteDir := filepath.Join(dir, entry.Name())
+		propsPath := properties.ProjectFile(siteDir)
+		if !properties.FileExists(propsPath) {
+			continue
+		}
+
+		props, err := properties.ParseFile(propsPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		name := sanitizeContainerName(props.GetWithDefault("name", entry.Name()))
+		tag, err := resolveTag(ctx, siteDir, name, publishTag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		edge, err := getSiteEdge(props)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		firewall, err := getSiteFirewall(props)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		cron, err := getSiteCron(props)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		settings, err := getSiteRuntime(props)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		site := &workspaceSite{
+			Dir:        siteDir,
+			Name:       name,
+			Tag:        tag,
+			Image:      props.Get("image"),
+			Resolvers:  getCheckResolvers(props),
+			Template:   props.Get("template"),
+			BaseDomain: props.Get("base_domain"),
+			Region:     siteRegion(props),
+			Runtime:    settings,
+			Edge:       edge,
+			Firewall:   firewall,
+			Cron:       cron,
+		}
+		if domain := props.Get("domain"); domain != "" {
+			site.Domains = append(site.Domains, domain)
+		}
+		site.Domains = append(site.Domains, props.GetList("domains")...)
+
+		sites = append(sites, site)
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].Name < sites[j].Name
+	})
+
+	return sites, nil
+}
+
+// deployWorkspace deploys every site in the workspace
+// Builds run sequentially (docker builds compete for CPU and output), while pushes and
+// deployment waits run in parallel with at most `parallel` sites in flight
+func deployWorkspace(ctx context.Context, dir string, parallel int) {
+	ui.PrintHeader(Version)
+
+	sites, err := findWorkspaceSites(ctx, dir)
+	if err != nil {
+		ui.PrintError("Failed to load workspace: %v", err)
+		os.Exit(1)
+	}
+	if len(sites) == 0 {
+		ui.PrintError("No sites found (expected subdirectories containing site.properties)")
+		os.Exit(1)
+	}
+	invalid := false
+	for _, site := range sites {
+		problems, _ := validateSite(site.Dir)
+		file := filepath.Base(properties.ProjectFile(site.Dir))
+		if failed, _ := printSiteProblems(filepath.Base(site.Dir), file, problems); failed > 0 {
+			invalid = true
+		}
+	}
+	if invalid {
+		ui.PrintInfo("Fix the sites' site.properties, then run 'lightspeed validate' in them to check")
+		os.Exit(1)
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	if _, err := buildPlatforms(); err != nil {
+		ui.PrintError("Invalid --platform: %v", err)
+		os.Exit(1)
+	}
+	// Multi-platform builds push the images, so they log in first and nothing is pushed after them
+	pushedByBuild := multiPlatform()
+
+	dockerRegistry := getDockerRegistryHost()
+	backend := newBackend()
+
+	ui.PrintKeyValue("Sites", fmt.Sprintf("%d", len(sites)))
+	ui.PrintKeyValue("Registry", dockerRegistry)
+	ui.PrintKeyValue("Platform", apiHost)
+	ui.PrintKeyValue("Parallel", fmt.Sprintf("%d", parallel))
+	fmt.Println()
+
+	if pushedByBuild {
+		workspaceLogin(ctx, dockerRegistry, backend)
+	}
+
+	// Step 1: Build all images sequentially
+	var built []*workspaceSite
+	var results []*deployResult
+	for _, site := range sites {
+		ui.PrintInfo("Building %s:%s...", site.Name, site.Tag)
+
+		start := time.Now()
+		registryBase := fmt.Sprintf("%s/%s", dockerRegistry, site.Name)
+		images := []string{registryBase + ":" + site.Tag, registryBase + ":latest"}
+		if err := buildDockerImage(ctx, site.Dir, site.Image, images); err != nil {
+			if interrupted(ctx) {
+				exitInterrupted("Run 'lightspeed deploy --all' to start again")
+			}
+			ui.PrintError("Failed to build %s: %v", site.Name, err)
+			results = append(results, &deployResult{Site: site, Err: fmt.Errorf("build failed: %w", err), Step: "build", Duration: time.Since(start)})
+			fmt.Println()
+			continue
+		}
+
+		ui.PrintSuccess("Built %s", images[0])
+		fmt.Println()
+		built = append(built, site)
+	}
+
+	// Step 2: Push and deploy in parallel
+	if len(built) > 0 {
+		if !pushedByBuild {
+			workspaceLogin(ctx, dockerRegistry, backend)
+		}
+
+		ui.PrintInfo("Deploying %d sites...", len(built))
+		fmt.Println()
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, parallel)
+
+		for _, site := range built {
+			wg.Add(1)
+			go func(site *workspaceSite) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				result := deployWorkspaceSite(ctx, site, dockerRegistry, backend, pushedByBuild)
+
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}(site)
+		}
+		wg.Wait()
+	}
+
+	// Step 3: Summary
+	printDeploySummary(results)
+	if jsonOutput() {
+		printJSON(workspaceDeployOutput(results))
+	}
+
+	if interrupted(ctx) {
+		exitInterrupted("Deployments already started continue in the background; run 'lightspeed deploy --all' to resume")
+	}
+
+	// The first failed site decides the exit code
+	for _, result := range results {
+		if result.Err != nil {
+			os.Exit(stepExitCode(result.Step))
+		}
+	}
+}
+
+// workspaceLogin logs docker in to the registry and waits while registry writes are blocked
+// by garbage collection, exiting on failure
+func workspaceLogin(ctx context.Context, dockerRegistry string, backend Backend) {
+	ui.PrintInfo("Logging in to registry...")
+	if err := dockerLogin(ctx, dockerRegistry); err != nil {
+		if interrupted(ctx) {
+			exitInterrupted("Run 'lightspeed deploy --all' to start again")
+		}
+		ui.PrintError("Failed to login to registry: %v", err)
+		printErrorHint(err)
+		os.Exit(1)
+	}
+	fmt.Println()
+
+	// Registry writes are blocked during garbage collection
+	if err := waitForRegistryWrites(ctx, ui.Stdout, backend); err != nil {
+		exitInterrupted("Run 'lightspeed deploy --all' to start again")
+	}
+}
+
+// deployWorkspaceSite pushes a built site image (unless the build pushed it) and waits for the deployment
+func deployWorkspaceSite(ctx context.Context, site *workspaceSite, dockerRegistry string, backend Backend, pushed bool) *deployResult {
+	out := ui.NewOutput(site.Name)
+	start := time.Now()
+	result := &deployResult{Site: site}
+
+	registryBase := fmt.Sprintf("%s/%s", dockerRegistry, site.Name)
+	images := []string{registryBase + ":" + site.Tag}
+	if site.Tag != "latest" {
+		images = append(images, registryBase+":latest")
+	}
+
+	if !pushed {
+		for _, image := range images {
+			out.PrintInfo("Pushing %s...", image)
+			if err := pushImageQuiet(ctx, image); err != nil {
+				out.PrintError("Failed to push image: %v", err)
+				result.Err = fmt.Errorf("push failed: %w", err)
+				result.Step = "push"
+				result.Duration = time.Since(start)
+				return result
+			}
+		}
+		out.PrintSuccess("Pushed %s", images[0])
+	}
+
+	release := api.Site{
+		Name:       site.Name,
+		Image:      site.Name,
+		Tag:        site.Tag,
+		Commit:     buildCommit(site.Dir),
+		Domains:    site.Domains,
+		Template:   site.Template,
+		BaseDomain: site.BaseDomain,
+		Region:     site.Region,
+	}
+	site.Runtime.apply(&release)
+	siteURL, err := releaseSite(ctx, out, backend, release, site.Resolvers)
+	result.URL = siteURL
+	result.Duration = time.Since(start)
+	if err != nil {
+		out.PrintError("Deploy failed: %v", err)
+		result.Err, result.Step = err, "ensure-site"
+		return result
+	}
+
+	syncChecks(ctx, out, backend, site.Dir, site.Name)
+	err = syncEdge(ctx, out, backend, site.Name, site.Edge)
+	if err == nil {
+		err = syncFirewall(ctx, out, backend, site.Name, site.Firewall)
+	}
+	if err != nil {
+		out.PrintError("Deploy failed: %v", err)
+		result.Err, result.Step = err, "ensure-site"
+		return result
+	}
+	syncCron(ctx, out, backend, site.Name, site.Cron)
+	out.PrintSuccess("Deployed %s", siteURL)
+	return result
+}
+
+// pushImageQuiet pushes an image without streaming progress, returning docker's output on failure
+func pushImageQuiet(ctx context.Context, image string) error {
+	output, err := dockerCommand(ctx, "push", "--quiet", image).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// workspaceOutput is the result of deploy --all with --output json
+type workspaceOutput struct {
+	Sites []workspaceSiteOutput `json:"sites"`
+}
+
+// workspaceSiteOutput is the result of deploying one site of a workspace
+type workspaceSiteOutput struct {
+	Site    string  `json:"site"`
+	Tag     string  `json:"tag"`
+	URL     string  `json:"url,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// workspaceDeployOutput collects the results of a workspace deploy
+func workspaceDeployOutput(results []*deployResult) workspaceOutput {
+	output := workspaceOutput{Sites: []workspaceSiteOutput{}}
+	for _, result := range results {
+		site := workspaceSiteOutput{
+			Site:    result.Site.Name,
+			Tag:     result.Site.Tag,
+			URL:     result.URL,
+			Seconds: result.Duration.Round(time.Second).Seconds(),
+		}
+		if result.Err != nil {
+			site.Error = result.Err.Error()
+		}
+		output.Sites = append(output.Sites, site)
+	}
+	return output
+}
+
+// printDeploySummary prints the aggregate result of a workspace deploy
+func printDeploySummary(results []*deployResult) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Site.Name < results[j].Site.Name
+	})
+
+	succeeded := 0
+	fmt.Println()
+	ui.PrintInfo("Summary:")
+	for _, result := range results {
+		duration := result.Duration.Round(time.Second)
+		if result.Err != nil {
+			ui.PrintError("%s (%v): %v", result.Site.Name, duration, result.Err)
+			continue
+		}
+		succeeded++
+		ui.PrintSuccess("%s (%v): %s", result.Site.Name, duration, result.URL)
+	}
+	fmt.Println()
+
+	if succeeded == len(results) {
+		ui.PrintSuccess("Deployed %d sites successfully!", succeeded)
+	} else {
+		ui.PrintError("%d of %d sites failed to deploy", len(results)-succeeded, len(results))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"lightspeed/core/lib/api"
+	"lightspeed/core/lib/properties"
+	"lightspeed/core/lib/ui"
+	"lightspeed/core/lib/version"
+)
+
+// Drift between a workspace site's local version and the version production runs
+const (
+	driftInSync      = "in sync"       // Production runs the local version
+	driftLocal       = "local changes" // The local version isn't published yet
+	driftPublished   = "not deployed"  // The last published version isn't deployed
+	driftNoSite      = "no site"       // The site doesn't exist on the platform
+	driftUnavailable = "unknown"       // The site's status couldn't be fetched
+)
+
+// workspaceStatusParallel is how many sites' statuses are fetched at once
+const workspaceStatusParallel = 8
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Work with the sites of a workspace",
+	Long:  "Commands for a workspace: a directory whose subdirectories are site projects (each with its own site.properties), deployed together with 'lightspeed deploy --all'",
+}
+
+var workspaceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show where every site of the workspace is",
+	Long:  "Show each site in the subdirectories of the current directory in one table: its local dev container, the local version, the tag last published from it, the tag production runs and whether production is behind the local version. Nothing is built or allocated, so sites with the date or build tag strategy compare commits instead of tags.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.PrintHeader(Version)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			ui.PrintError("Failed to get current directory: %v", err)
+			os.Exit(1)
+		}
+
+		sites, err := workspaceStatus(cmd.Context(), dir, newBackend())
+		if err != nil {
+			ui.PrintError("Failed to load workspace: %v", err)
+			os.Exit(1)
+		}
+
+		if jsonOutput() {
+			printJSON(workspaceStatusOutput{Sites: sites})
+			return
+		}
+
+		if len(sites) == 0 {
+			ui.PrintError("No sites found (expected subdirectories containing site.properties)")
+			os.Exit(1)
+		}
+		printWorkspaceStatus(sites)
+	},
+}
+
+// workspaceStatusOutput is the result of workspace status with --output json
+type workspaceStatusOutput struct {
+	Sites []*workspaceSiteStatus `json:"sites"`
+}
+
+// workspaceSiteStatus is where one site of a workspace is, locally and in production
+type workspaceSiteStatus struct {
+	Site      string `json:"site"`
+	Dir       string `json:"dir"`
+	Container string `json:"container"`           // Local dev container: running, stopped or none
+	Local     string `json:"local,omitempty"`     // Tag the site's tag strategy gives the working copy
+	Commit    string `json:"commit,omitempty"`    // Commit of the working copy
+	Published string `json:"published,omitempty"` // Tag last published from this machine
+	Deployed  string `json:"deployed,omitempty"`  // Tag production runs
+	Status    string `json:"status,omitempty"`    // Production's deployment phase
+	Drift     string `json:"drift"`
+	Error     string `json:"error,omitempty"`
+	commit    string // Commit the published image was built from
+	deployed  *api.SiteResponse
+}
+
+// workspaceStatus collects the status of every site in the workspace, sorted by name
+// The platform is asked about several sites at once, so a large workspace doesn't take a round
+// trip per site
+func workspaceStatus(ctx context.Context, dir string, backend Backend) ([]*workspaceSiteStatus, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := devContainerStates(ctx)
+	var sites []*workspaceSiteStatus
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		siteDir := filepath.Join(dir, entry.Name())
+		propsPath := properties.ProjectFile(siteDir)
+		if !properties.FileExists(propsPath) {
+			continue
+		}
+		props, err := properties.ParseFile(propsPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		name := sanitizeContainerName(props.GetWithDefault("name", entry.Name()))
+		site := &workspaceSiteStatus{
+			Site:      name,
+			Dir:       entry.Name(),
+			Container: "none",
+			Local:     localTag(siteDir, props.Get("tag.strategy")),
+			Commit:    buildCommit(siteDir),
+		}
+		if state, ok := containers["lightspeed-"+name]; ok {
+			site.Container = state
+		}
+		if state, err := loadProjectState(siteDir); err == nil && state.Published != nil && state.Published.Site == name {
+			site.Published = state.Published.Tag
+			site.commit = state.Published.Commit
+		}
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].Site < sites[j].Site
+	})
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workspaceStatusParallel)
+	for _, site := range sites {
+		wg.Add(1)
+		go func(site *workspaceSiteStatus) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			status, err := backend.GetSiteStatus(ctx, site.Site)
+			var apiErr *operatorError
+			switch {
+			case err == nil:
+				site.deployed = status
+				site.Deployed = status.Tag
+				site.Status = status.Status
+			case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			default:
+				site.Error = err.Error()
+			}
+		}(site)
+	}
+	wg.Wait()
+
+	for _, site := range sites {
+		site.Drift = site.drift()
+	}
+	return sites, nil
+}
+
+// drift compares the local version with the one production runs
+// Commits are compared when both are known, as tags of the date and build strategies aren't
+// known before publishing; otherwise the local and published tags are compared with the deployed one
+func (s *workspaceSiteStatus) drift() string {
+	switch {
+	case s.Error != "":
+		return driftUnavailable
+	case s.deployed == nil:
+		return driftNoSite
+	case s.Commit != "" && s.deployed.Commit != "":
+		if s.Commit == s.deployed.Commit {
+			return driftInSync
+		}
+		if s.Commit == s.commit && s.Published != s.Deployed {
+			return driftPublished
+		}
+		return driftLocal
+	case s.Local != "" && s.Local == s.Deployed:
+		return driftInSync
+	case s.Local == "" && s.Published == s.Deployed:
+		return driftInSync
+	case s.Published != "" && s.Published != s.Deployed && (s.Local == "" || s.Local == s.Published):
+		return driftPublished
+	}
+	return driftLocal
+}
+
+// localTag returns the tag the site's tag strategy gives its working copy, without building
+// Strategies the operator allocates tags for have no local tag ("")
+func localTag(dir, strategy string) string {
+	if !version.IsGitRepo(dir) {
+		return ""
+	}
+	switch strategy {
+	case "", tagStrategyGitDescribe:
+		if v, err := version.GetFromGit(dir); err == nil {
+			return v.String()
+		}
+	case tagStrategyGitSHA:
+		if commit, err := version.GetCommit(dir); err == nil {
+			return commit
+		}
+	}
+	return ""
+}
+
+// devContainerStates returns the state (running, stopped) of every lightspeed dev container
+// Empty when docker isn't available, so every site shows no container
+func devContainerStates(ctx context.Context) map[string]string {
+	states := map[string]string{}
+	output, err := dockerCommand(ctx, "ps", "-a", "--filter", "name=^lightspeed-", "--format", "{{.Names}}\t{{.State}}").Output()
+	if err != nil {
+		ui.PrintDebug("Failed to list containers: %v", err)
+		return states
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		name, state, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if state == "running" {
+			states[name] = "running"
+		} else {
+			states[name] = "stopped"
+		}
+	}
+	return states
+}
+
+// printWorkspaceStatus prints the workspace's sites as a table
+func printWorkspaceStatus(sites []*workspaceSiteStatus) {
+	ui.PrintInfo("%d site(s)", len(sites))
+	fmt.Println()
+
+	row := "  %-24s %-10s %-20s %-20s %-20s %s"
+	fmt.Println(ui.Muted(fmt.Sprintf(row, "SITE", "DEV", "LOCAL", "PUBLISHED", "DEPLOYED", "DRIFT")))
+	behind := 0
+	for _, site := range sites {
+		deployed := orDash(site.Deployed)
+		if site.deployed != nil && site.Status != "ACTIVE" {
+			deployed += " (" + strings.ToLower(strings.TrimSuffix(formatStatus(site.Status), "...")) + ")"
+		}
+
+		drift := site.Drift
+		switch site.Drift {
+		case driftInSync:
+			drift = ui.Highlight(drift)
+		case driftLocal, driftPublished:
+			behind++
+		}
+		fmt.Printf(row+"\n", site.Site, site.Container, orDash(site.Local), orDash(site.Published), deployed, drift)
+	}
+	fmt.Println()
+
+	for _, site := range sites {
+		if site.Error != "" {
+			ui.PrintWarning("%s: %s", site.Site, site.Error)
+		}
+	}
+	if behind > 0 {
+		fmt.Println(ui.Muted(fmt.Sprintf("  %d site(s) behind their local version; deploy them with 'lightspeed deploy --all'", behind)))
+		fmt.Println()
+	}
+}
+
+// orDash returns s, or "-" when it's empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	supportsJSON(workspaceStatusCmd)
+	workspaceCmd.AddCommand(workspaceStatusCmd)
+	rootCmd.AddCommand(workspaceCmd)
+}
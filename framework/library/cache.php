@@ -0,0 +1,101 @@
+<?php
+/**
+ * Lightspeed cache utilities
+ *
+ * 'lightspeed cache create' sets REDIS_URL (and REDIS_PREFIX on the shared
+ * instance, where a site may only use keys starting with its prefix) on the
+ * site, so sessions and cached data survive redeploys and are shared by
+ * every instance.
+ */
+
+/**
+ * Get the cache URL of the site ('' when it has no cache)
+ */
+function lightspeed_cache_url(): string {
+    $url = getenv('REDIS_URL');
+    return $url === false ? '' : $url;
+}
+
+/**
+ * Get the key prefix of the site ('' on a dedicated cache)
+ */
+function lightspeed_cache_prefix(): string {
+    $prefix = getenv('REDIS_PREFIX');
+    return $prefix === false ? '' : $prefix;
+}
+
+/**
+ * Connect to the site's cache with the phpredis extension (null when the site has no cache)
+ * Keys are prefixed, so they stay within what the site's user may access
+ */
+function lightspeed_cache(): ?Redis {
+    static $redis = null;
+    if ($redis !== null) {
+        return $redis;
+    }
+
+    $url = lightspeed_cache_url();
+    if ($url === '' || !class_exists('Redis')) {
+        return null;
+    }
+
+    $parts = parse_url($url);
+    $tls = ($parts['scheme'] ?? '') === 'rediss';
+    $client = new Redis();
+    $client->connect(($tls ? 'tls://' : '') . $parts['host'], $parts['port'] ?? 6379, 2.0);
+    if (isset($parts['pass'])) {
+        $auth = isset($parts['user']) ? [rawurldecode($parts['user']), rawurldecode($parts['pass'])] : rawurldecode($parts['pass']);
+        $client->auth($auth);
+    }
+    $prefix = lightspeed_cache_prefix();
+    if ($prefix !== '') {
+        $client->setOption(Redis::OPT_PREFIX, $prefix);
+    }
+
+    $redis = $client;
+    return $redis;
+}
+
+/**
+ * Build the session.save_path of the phpredis session handler for a cache URL
+ * Sessions are stored under the site's prefix, followed by 'session:'
+ */
+function lightspeed_cache_session_path(string $url, string $prefix = ''): string {
+    $parts = parse_url($url);
+    if ($parts === false || !isset($parts['host'])) {
+        return '';
+    }
+
+    $scheme = ($parts['scheme'] ?? '') === 'rediss' ? 'tls' : 'tcp';
+    $query = [];
+    if (isset($parts['pass'])) {
+        if (isset($parts['user'])) {
+            $query[] = 'auth[]=' . rawurlencode(rawurldecode($parts['user']));
+        }
+        $query[] = 'auth[]=' . rawurlencode(rawurldecode($parts['pass']));
+    }
+    $query[] = 'prefix=' . rawurlencode($prefix . 'session:');
+
+    return $scheme . '://' . $parts['host'] . ':' . ($parts['port'] ?? 6379) . '?' . implode('&', $query);
+}
+
+/**
+ * Store PHP sessions in the site's cache
+ * Call before session_start(); returns false (keeping file sessions) when the site has
+ * no cache or the redis extension isn't loaded
+ */
+function lightspeed_cache_sessions(): bool {
+    $url = lightspeed_cache_url();
+    if ($url === '' || !extension_loaded('redis')) {
+        return false;
+    }
+
+    $path = lightspeed_cache_session_path($url, lightspeed_cache_prefix());
+    if ($path === '') {
+        return false;
+    }
+
+    ini_set('session.save_handler', 'redis');
+    ini_set('session.save_path', $path);
+    return true;
+}
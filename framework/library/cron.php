@@ -0,0 +1,58 @@
+<?php
+/**
+ * Lightspeed cron utilities
+ *
+ * The operator requests the paths of a site's cron jobs ('lightspeed cron')
+ * on their schedules. Each request is signed with the site's own cron key
+ * (LIGHTSPEED_CRON_KEY), so a cron page can refuse requests that don't
+ * come from the operator.
+ */
+
+/**
+ * Compute the signature of a cron request: the hex HMAC-SHA256 of
+ * "{timestamp}\n{method}\n{path}" keyed with the site's cron key
+ */
+function lightspeed_cron_signature(string $key, string $timestamp, string $method, string $path): string {
+    return hash_hmac('sha256', $timestamp . "\n" . $method . "\n" . $path, $key);
+}
+
+/**
+ * Get the name of the cron job of the current request ('' when it isn't a cron request)
+ */
+function lightspeed_cron_job(): string {
+    return $_SERVER['HTTP_X_LIGHTSPEED_CRON'] ?? '';
+}
+
+/**
+ * Check that the current request was sent by the operator's cron runner
+ * Requests signed more than $maxAge seconds ago are refused, so they can't be replayed later
+ */
+function lightspeed_cron_verify(int $maxAge = 300): bool {
+    $key = getenv('LIGHTSPEED_CRON_KEY');
+    $timestamp = $_SERVER['HTTP_X_LIGHTSPEED_TIMESTAMP'] ?? '';
+    $signature = $_SERVER['HTTP_X_LIGHTSPEED_SIGNATURE'] ?? '';
+    if ($key === false || $key === '' || !ctype_digit($timestamp) || $signature === '') {
+        return false;
+    }
+    if (abs(time() - (int) $timestamp) > $maxAge) {
+        return false;
+    }
+
+    $method = $_SERVER['REQUEST_METHOD'] ?? 'GET';
+    $path = $_SERVER['REQUEST_URI'] ?? '/';
+    return hash_equals(lightspeed_cron_signature($key, $timestamp, $method, $path), $signature);
+}
+
+/**
+ * End the request with 403 unless it was sent by the operator's cron runner
+ */
+function lightspeed_cron_require(int $maxAge = 300): void {
+    if (lightspeed_cron_verify($maxAge)) {
+        return;
+    }
+
+    http_response_code(403);
+    header('Content-Type: text/plain');
+    echo "Forbidden\n";
+    exit;
+}
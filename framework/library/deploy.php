@@ -0,0 +1,46 @@
+<?php
+/**
+ * Lightspeed deployment metadata
+ *
+ * The operator records what it deployed in the site's environment on every
+ * deploy (LIGHTSPEED_SITE, LIGHTSPEED_VERSION, LIGHTSPEED_COMMIT,
+ * LIGHTSPEED_DEPLOYED_AT and LIGHTSPEED_RELEASE). The server serves them as
+ * JSON at /__lightspeed, so the running version can be checked from a browser
+ * and by the operator's uptime monitor.
+ */
+
+require_once __DIR__ . '/release.php';
+require_once __DIR__ . '/version.php';
+
+/**
+ * Get what the running site was deployed with ('' for values the operator didn't set)
+ */
+function lightspeed_deployment(): array {
+    $env = function (string $name): string {
+        $value = getenv($name);
+        return $value === false ? '' : $value;
+    };
+
+    return [
+        'site' => $env('LIGHTSPEED_SITE'),
+        'version' => $env('LIGHTSPEED_VERSION'),
+        'commit' => $env('LIGHTSPEED_COMMIT'),
+        'deployed_at' => $env('LIGHTSPEED_DEPLOYED_AT'),
+        'release' => lightspeed_release(),
+        'framework' => lightspeed_version(),
+    ];
+}
+
+/**
+ * Serve the deployment metadata as JSON, never cached
+ */
+function lightspeed_deployment_serve(): void {
+    header('Content-Type: application/json');
+    header('Cache-Control: no-store');
+    echo json_encode(lightspeed_deployment(), JSON_PRETTY_PRINT | JSON_UNESCAPED_SLASHES), "\n";
+}
+
+// Requested directly (nginx routes /__lightspeed here)
+if (PHP_SAPI !== 'cli' && realpath($_SERVER['SCRIPT_FILENAME'] ?? '') === __FILE__) {
+    lightspeed_deployment_serve();
+}
@@ -0,0 +1,125 @@
+<?php
+/**
+ * Lightspeed job queue utilities
+ *
+ * The operator hosts a small job queue for every site, reached with the
+ * OPERATOR_URL and LIGHTSPEED_SITE_TOKEN it deploys sites with (the token
+ * only reaches the site's own queues). Pages push jobs, and the queue worker
+ * ('lightspeed queue worker enable') pops and runs them in the background.
+ * A job that isn't acknowledged within its timeout is popped again, and
+ * fails after 5 attempts.
+ */
+
+/**
+ * Get the name of the site the queues belong to
+ */
+function lightspeed_queue_site(): string {
+    $site = getenv('LIGHTSPEED_SITE');
+    if ($site !== false && $site !== '') {
+        return $site;
+    }
+    return function_exists('site') ? site()->name() : basename(getcwd());
+}
+
+/**
+ * Build the operator URL of a site's queue endpoint
+ */
+function lightspeed_queue_url(string $operator, string $site, string $queue, string $path = ''): string {
+    $url = rtrim($operator, '/') . '/sites/' . rawurlencode($site) . '/queues/' . rawurlencode($queue);
+    return $path === '' ? $url : $url . '/' . ltrim($path, '/');
+}
+
+/**
+ * Send a request to a queue endpoint of the operator
+ * Returns the status code and decoded body (status 0 when the operator can't be reached)
+ */
+function lightspeed_queue_request(string $method, string $queue, string $path, ?array $body = null, int $timeout = 10): array {
+    $operator = getenv('OPERATOR_URL');
+    if ($operator === false || $operator === '') {
+        return [0, null];
+    }
+
+    $headers = "Content-Type: application/json\r\n";
+    $token = getenv('LIGHTSPEED_SITE_TOKEN');
+    if ($token !== false && $token !== '') {
+        $headers .= "Authorization: Bearer " . $token . "\r\n";
+    }
+
+    $context = stream_context_create([
+        'http' => [
+            'method' => $method,
+            'header' => $headers,
+            'content' => $body === null ? '' : json_encode($body),
+            'timeout' => $timeout,
+            'ignore_errors' => true,
+        ],
+    ]);
+
+    $response = @file_get_contents(lightspeed_queue_url($operator, lightspeed_queue_site(), $queue, $path), false, $context);
+    if ($response === false || !isset($http_response_header[0])) {
+        return [0, null];
+    }
+
+    preg_match('/^HTTP\/\S+\s+(\d+)/', $http_response_header[0], $matches);
+    return [(int) ($matches[1] ?? 0), json_decode($response, true)];
+}
+
+/**
+ * Push a job to a queue, to be run after $delay seconds
+ * Returns the job ID, or null if the job couldn't be queued
+ */
+function lightspeed_queue_push(string $queue, mixed $payload = null, int $delay = 0): ?string {
+    [$status, $job] = lightspeed_queue_request('POST', $queue, 'jobs', ['payload' => $payload, 'delay' => $delay]);
+    if ($status !== 201 || !is_array($job)) {
+        return null;
+    }
+    return $job['id'] ?? null;
+}
+
+/**
+ * Pop the next job of a queue, reserving it for $timeout seconds
+ * Returns the job (id, payload, attempts), or null when the queue is empty
+ */
+function lightspeed_queue_pop(string $queue, int $timeout = 60): ?array {
+    [$status, $job] = lightspeed_queue_request('POST', $queue, 'pop', ['timeout' => $timeout]);
+    if ($status !== 200 || !is_array($job)) {
+        return null;
+    }
+    return $job;
+}
+
+/**
+ * Acknowledge a popped job once it's done, so it isn't run again
+ */
+function lightspeed_queue_ack(string $queue, string $id): bool {
+    [$status] = lightspeed_queue_request('DELETE', $queue, 'jobs/' . rawurlencode($id));
+    return $status === 204;
+}
+
+/**
+ * Run the jobs of a queue forever, calling $handler with each job's payload and the job
+ * Jobs are acknowledged when the handler returns; a handler that throws leaves its job to be
+ * popped again after $timeout seconds. The queue is polled every $sleep seconds when empty.
+ * Only runs from the command line, since the worker script is also reachable over HTTP.
+ */
+function lightspeed_queue_work(string $queue, callable $handler, int $sleep = 5, int $timeout = 60): void {
+    if (PHP_SAPI !== 'cli') {
+        http_response_code(404);
+        return;
+    }
+
+    while (true) {
+        $job = lightspeed_queue_pop($queue, $timeout);
+        if ($job === null) {
+            sleep($sleep);
+            continue;
+        }
+
+        try {
+            $handler($job['payload'] ?? null, $job);
+            lightspeed_queue_ack($queue, $job['id']);
+        } catch (Throwable $e) {
+            error_log('[queue] Job ' . $job['id'] . ' of ' . $queue . ' failed (attempt ' . ($job['attempts'] ?? 1) . '): ' . $e->getMessage());
+        }
+    }
+}
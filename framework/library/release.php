@@ -0,0 +1,28 @@
+<?php
+/**
+ * Lightspeed release utilities
+ *
+ * The operator sets LIGHTSPEED_RELEASE to the image digest on every deploy,
+ * so asset URLs change with each release and caches never serve stale files.
+ */
+
+/**
+ * Get the release ID of the running site ('' when not deployed by the operator)
+ */
+function lightspeed_release(): string {
+    $release = getenv('LIGHTSPEED_RELEASE');
+    return $release === false ? '' : $release;
+}
+
+/**
+ * Append the release ID to an asset URL for cache busting
+ */
+function lightspeed_asset(string $path): string {
+    $release = lightspeed_release();
+    if ($release === '') {
+        return $path;
+    }
+
+    $separator = str_contains($path, '?') ? '&' : '?';
+    return $path . $separator . 'v=' . rawurlencode($release);
+}
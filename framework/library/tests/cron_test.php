@@ -0,0 +1,59 @@
+<?php
+
+require_once __DIR__ . '/../test.php';
+require_once __DIR__ . '/../cron.php';
+
+function cron_request(string $key, int $timestamp, string $path, ?string $signature = null): void {
+    $_SERVER['REQUEST_METHOD'] = 'GET';
+    $_SERVER['REQUEST_URI'] = $path;
+    $_SERVER['HTTP_X_LIGHTSPEED_CRON'] = 'cleanup';
+    $_SERVER['HTTP_X_LIGHTSPEED_TIMESTAMP'] = (string) $timestamp;
+    $_SERVER['HTTP_X_LIGHTSPEED_SIGNATURE'] = $signature ?? lightspeed_cron_signature($key, (string) $timestamp, 'GET', $path);
+}
+
+test('signature matches the operator', function() {
+    assert_equals(
+        '2bac67e0c5bde2c203e62a2ad8a5c438083ae8ddfc765827f23f7fee2b599a1a',
+        lightspeed_cron_signature('secret', '1700000000', 'GET', '/cron/cleanup')
+    );
+});
+
+test('job is empty without the cron header', function() {
+    unset($_SERVER['HTTP_X_LIGHTSPEED_CRON']);
+    assert_equals('', lightspeed_cron_job());
+});
+
+test('signed request is verified', function() {
+    putenv('LIGHTSPEED_CRON_KEY=secret');
+    cron_request('secret', time(), '/cron/cleanup');
+    assert_equals(true, lightspeed_cron_verify());
+    assert_equals('cleanup', lightspeed_cron_job());
+});
+
+test('request signed with another key is refused', function() {
+    putenv('LIGHTSPEED_CRON_KEY=secret');
+    cron_request('other', time(), '/cron/cleanup');
+    assert_equals(false, lightspeed_cron_verify());
+});
+
+test('request for another path is refused', function() {
+    putenv('LIGHTSPEED_CRON_KEY=secret');
+    cron_request('secret', time(), '/cron/cleanup');
+    $_SERVER['REQUEST_URI'] = '/cron/import';
+    assert_equals(false, lightspeed_cron_verify());
+});
+
+test('old request is refused', function() {
+    putenv('LIGHTSPEED_CRON_KEY=secret');
+    cron_request('secret', time() - 600, '/cron/cleanup');
+    assert_equals(false, lightspeed_cron_verify());
+});
+
+test('request is refused without a cron key', function() {
+    putenv('LIGHTSPEED_CRON_KEY');
+    cron_request('', time(), '/cron/cleanup');
+    assert_equals(false, lightspeed_cron_verify());
+});
+
+putenv('LIGHTSPEED_CRON_KEY');
+run_tests();
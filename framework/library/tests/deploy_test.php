@@ -0,0 +1,42 @@
+<?php
+
+require_once __DIR__ . '/../test.php';
+require_once __DIR__ . '/../deploy.php';
+
+function clear_deployment_env(): void {
+    foreach (['LIGHTSPEED_SITE', 'LIGHTSPEED_VERSION', 'LIGHTSPEED_COMMIT', 'LIGHTSPEED_DEPLOYED_AT', 'LIGHTSPEED_RELEASE'] as $name) {
+        putenv($name);
+    }
+}
+
+test('deployment is empty when not deployed', function() {
+    clear_deployment_env();
+    $deployment = lightspeed_deployment();
+    assert_equals('', $deployment['site']);
+    assert_equals('', $deployment['version']);
+    assert_equals('', $deployment['commit']);
+    assert_equals('', $deployment['deployed_at']);
+    assert_equals('', $deployment['release']);
+});
+
+test('deployment reads the operator env', function() {
+    clear_deployment_env();
+    putenv('LIGHTSPEED_SITE=blog');
+    putenv('LIGHTSPEED_VERSION=1.4.2');
+    putenv('LIGHTSPEED_COMMIT=a1b2c3d');
+    putenv('LIGHTSPEED_DEPLOYED_AT=2026-10-16T09:30:00Z');
+    putenv('LIGHTSPEED_RELEASE=3f2a9c1b7d4e');
+    $deployment = lightspeed_deployment();
+    assert_equals('blog', $deployment['site']);
+    assert_equals('1.4.2', $deployment['version']);
+    assert_equals('a1b2c3d', $deployment['commit']);
+    assert_equals('2026-10-16T09:30:00Z', $deployment['deployed_at']);
+    assert_equals('3f2a9c1b7d4e', $deployment['release']);
+});
+
+test('deployment includes the framework version', function() {
+    assert_equals(lightspeed_version(), lightspeed_deployment()['framework']);
+});
+
+clear_deployment_env();
+run_tests();
@@ -0,0 +1,36 @@
+<?php
+
+require_once __DIR__ . '/../test.php';
+require_once __DIR__ . '/../queue.php';
+
+test('queue site reads LIGHTSPEED_SITE', function() {
+    putenv('LIGHTSPEED_SITE=mysite');
+    assert_equals('mysite', lightspeed_queue_site());
+});
+
+test('queue url addresses the site queue', function() {
+    assert_equals(
+        'https://operator.example.com/sites/mysite/queues/emails',
+        lightspeed_queue_url('https://operator.example.com/', 'mysite', 'emails')
+    );
+});
+
+test('queue url appends the path', function() {
+    assert_equals(
+        'https://operator.example.com/sites/mysite/queues/emails/jobs/3f2a9c1b',
+        lightspeed_queue_url('https://operator.example.com', 'mysite', 'emails', '/jobs/3f2a9c1b')
+    );
+});
+
+test('push fails without an operator', function() {
+    putenv('OPERATOR_URL');
+    assert_equals(null, lightspeed_queue_push('emails', ['to' => 'someone@example.com']));
+});
+
+test('pop is empty without an operator', function() {
+    putenv('OPERATOR_URL');
+    assert_equals(null, lightspeed_queue_pop('emails'));
+});
+
+putenv('LIGHTSPEED_SITE');
+run_tests();
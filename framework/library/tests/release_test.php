@@ -0,0 +1,32 @@
+<?php
+
+require_once __DIR__ . '/../test.php';
+require_once __DIR__ . '/../release.php';
+
+test('release is empty when not set', function() {
+    putenv('LIGHTSPEED_RELEASE');
+    assert_equals('', lightspeed_release());
+});
+
+test('release reads LIGHTSPEED_RELEASE', function() {
+    putenv('LIGHTSPEED_RELEASE=3f2a9c1b7d4e');
+    assert_equals('3f2a9c1b7d4e', lightspeed_release());
+});
+
+test('asset is unchanged without a release', function() {
+    putenv('LIGHTSPEED_RELEASE');
+    assert_equals('/css/site.css', lightspeed_asset('/css/site.css'));
+});
+
+test('asset appends release as query', function() {
+    putenv('LIGHTSPEED_RELEASE=3f2a9c1b7d4e');
+    assert_equals('/css/site.css?v=3f2a9c1b7d4e', lightspeed_asset('/css/site.css'));
+});
+
+test('asset appends release to existing query', function() {
+    putenv('LIGHTSPEED_RELEASE=3f2a9c1b7d4e');
+    assert_equals('/img/logo.png?w=200&v=3f2a9c1b7d4e', lightspeed_asset('/img/logo.png?w=200'));
+});
+
+putenv('LIGHTSPEED_RELEASE');
+run_tests();
@@ -43,9 +43,13 @@ echo ""
 cat > "$BUILD_DIR/Dockerfile" << EOF
 FROM php:8.2-fpm
 
-# Install nginx and APCu
-RUN apt-get update && apt-get install -y nginx && rm -rf /var/lib/apt/lists/*
+# Install nginx, brotli, APCu and phpredis (the nginx brotli module is optional, gzip_static is built in)
+# phpredis provides the Redis client and session handler used with 'lightspeed cache'
+RUN apt-get update && apt-get install -y nginx brotli && \
+    (apt-get install -y libnginx-mod-http-brotli-static || true) && \
+    rm -rf /var/lib/apt/lists/*
 RUN pecl install apcu && docker-php-ext-enable apcu
+RUN pecl install redis && docker-php-ext-enable redis
 RUN echo 'apc.enable_cli=1' >> /usr/local/etc/php/conf.d/docker-php-ext-apcu.ini
 
 # Configure nginx
@@ -56,6 +60,14 @@ RUN echo 'server {\n\
     index index.php index.html;\n\
 \n\
     error_page 400 401 403 404 405 500 502 503 504 /error.php;\n\
+\n\
+    include /etc/nginx/lightspeed-edge.conf;\n\
+\n\
+    location = /__lightspeed {\n\
+        fastcgi_pass 127.0.0.1:9000;\n\
+        fastcgi_param SCRIPT_FILENAME /opt/lightspeed/deploy.php;\n\
+        include fastcgi_params;\n\
+    }\n\
 \n\
     location ~ ^/_/(.+?)(?:\.php)?\$ {\n\
         fastcgi_intercept_errors on;\n\
@@ -76,6 +88,19 @@ RUN echo 'server {\n\
     }\n\
 }' > /etc/nginx/sites-available/default
 
+# Send nginx logs to the container output (docker logs, lightspeed dev logs)
+RUN ln -sf /dev/stdout /var/log/nginx/access.log && ln -sf /dev/stderr /var/log/nginx/error.log
+
+# Serve pre-compressed .gz and .br variants of static assets to clients that accept them
+RUN printf 'gzip_static on;\\ngzip_vary on;\\n' > /etc/nginx/conf.d/precompressed.conf && \
+    if ls /etc/nginx/modules-enabled | grep -q brotli; then \
+        echo 'brotli_static on;' >> /etc/nginx/conf.d/precompressed.conf; \
+    fi
+
+# Pre-compression script, run by site builds (see the Dockerfile the CLI generates)
+COPY precompress.sh /usr/local/bin/precompress
+RUN chmod 755 /usr/local/bin/precompress
+
 # Create lightspeed directory in tmp for generated handlers
 RUN mkdir -p /tmp/lightspeed && chmod 777 /tmp/lightspeed
 
@@ -88,10 +113,10 @@ RUN echo 'version=${VERSION}' > /opt/lightspeed/version.properties && \
 # Add /opt to PHP include path
 RUN echo 'include_path = ".:/opt"' > /usr/local/etc/php/conf.d/lightspeed.ini
 
-# Start script to run both nginx and php-fpm
-RUN echo '#!/bin/bash\n\
-php-fpm -D\n\
-nginx -g "daemon off;"' > /start.sh && chmod +x /start.sh
+# Start script to run both nginx and php-fpm (see start.sh), and the origin lock it writes
+RUN touch /etc/nginx/lightspeed-edge.conf
+COPY start.sh /start.sh
+RUN chmod +x /start.sh
 
 EXPOSE 80
 
@@ -100,6 +125,57 @@ EOF
 
 echo -e "${GREEN}✓ Created: build/Dockerfile${NC}"
 
+# Generate pre-compression script
+cat > "$BUILD_DIR/precompress.sh" << 'EOF'
+#!/bin/sh
+# Pre-compresses the static assets under a directory (default: /var/www/html), so nginx
+# serves their .gz and .br variants instead of compressing on every request.
+# Files under 1KB are skipped, and variants that aren't smaller than the original are removed.
+set -e
+
+dir="${1:-/var/www/html}"
+
+for ext in css js mjs json map svg xml txt html htm wasm ico ttf otf eot; do
+    find "$dir" -type f -name "*.$ext" -size +1k
+done | while IFS= read -r file; do
+    size=$(wc -c < "$file")
+
+    gzip -k -9 -f "$file"
+    if [ "$(wc -c < "$file.gz")" -ge "$size" ]; then
+        rm -f "$file.gz"
+    fi
+
+    if command -v brotli > /dev/null; then
+        brotli -k -f -q 11 "$file"
+        if [ "$(wc -c < "$file.br")" -ge "$size" ]; then
+            rm -f "$file.br"
+        fi
+    fi
+done
+EOF
+chmod +x "$BUILD_DIR/precompress.sh"
+
+echo -e "${GREEN}✓ Created: build/precompress.sh${NC}"
+
+# Generate start script
+cat > "$BUILD_DIR/start.sh" << 'EOF'
+#!/bin/bash
+# Starts php-fpm and nginx. Sites in edge mode are deployed with LIGHTSPEED_EDGE_KEY, which
+# the operator's edge proxy sends with every request; requests without it are rejected, so
+# the site's App Platform ingress can't be used to get around the edge's rules.
+if [ -n "$LIGHTSPEED_EDGE_KEY" ]; then
+    printf 'if ($http_x_lightspeed_edge_key != "%s") {\n    return 403;\n}\n' "$LIGHTSPEED_EDGE_KEY" > /etc/nginx/lightspeed-edge.conf
+else
+    : > /etc/nginx/lightspeed-edge.conf
+fi
+
+php-fpm -D
+nginx -g "daemon off;"
+EOF
+chmod +x "$BUILD_DIR/start.sh"
+
+echo -e "${GREEN}✓ Created: build/start.sh${NC}"
+
 # Copy library files
 echo -e "${BLUE}Copying library files...${NC}"
 cp -r "$SCRIPT_DIR/../library" "$BUILD_DIR/library"
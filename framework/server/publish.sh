@@ -38,16 +38,21 @@ fi
 
 FULL_IMAGE_NAME="${REGISTRY}/${GITHUB_ORG}/${IMAGE_NAME}"
 
+# Platforms of the published image: amd64 for App Platform builds, arm64 so `lightspeed start`
+# runs natively on Apple Silicon and ARM Linux
+PLATFORMS="${PLATFORMS:-linux/amd64,linux/arm64}"
+
 # Get version using vermouth
 echo -e "${BLUE}Reading version from git tags...${NC}"
 VERSION=$(vermouth 2>/dev/null || curl -sfL https://raw.githubusercontent.com/abrayall/vermouth/refs/heads/main/vermouth.sh | sh -)
 
 echo -e "${GREEN}Publishing version: ${VERSION}${NC}"
 echo -e "${GRAY}Registry: ${FULL_IMAGE_NAME}${NC}"
+echo -e "${GRAY}Platforms: ${PLATFORMS}${NC}"
 echo ""
 
-# Check if local image exists
-if ! docker image inspect "lightspeed-server:${VERSION}" &>/dev/null; then
+# Check if the local image and its build directory exist
+if ! docker image inspect "lightspeed-server:${VERSION}" &>/dev/null || [ ! -f "$SCRIPT_DIR/build/Dockerfile" ]; then
     echo -e "${YELLOW}Local image not found, running build first...${NC}"
     echo ""
     "$SCRIPT_DIR/build.sh"
@@ -87,21 +92,18 @@ fi
 
 echo ""
 
-# Tag and push images
+# Build and push the multi-arch image from the generated build directory
+# (a multi-arch image can't be loaded locally, so buildx pushes it as it builds)
 echo -e "${YELLOW}=== Pushing to GitHub Container Registry ===${NC}"
 echo ""
 
-echo -e "${BLUE}Tagging ${FULL_IMAGE_NAME}:${VERSION}...${NC}"
-docker tag lightspeed-server:${VERSION} ${FULL_IMAGE_NAME}:${VERSION}
-
-echo -e "${BLUE}Tagging ${FULL_IMAGE_NAME}:latest...${NC}"
-docker tag lightspeed-server:latest ${FULL_IMAGE_NAME}:latest
-
-echo -e "${BLUE}Pushing ${FULL_IMAGE_NAME}:${VERSION}...${NC}"
-docker push ${FULL_IMAGE_NAME}:${VERSION}
-
-echo -e "${BLUE}Pushing ${FULL_IMAGE_NAME}:latest...${NC}"
-docker push ${FULL_IMAGE_NAME}:latest
+echo -e "${BLUE}Building and pushing ${FULL_IMAGE_NAME}:${VERSION} (${PLATFORMS})...${NC}"
+docker buildx build \
+    --platform "${PLATFORMS}" \
+    -t ${FULL_IMAGE_NAME}:${VERSION} \
+    -t ${FULL_IMAGE_NAME}:latest \
+    --push \
+    "$SCRIPT_DIR/build"
 
 echo ""
 echo "=============================================="
@@ -0,0 +1,632 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+)
+
+// accessTokenPrefix starts every access token the operator issues (and the admin and site
+// tokens), so they can be told apart from DigitalOcean tokens sent to /sites
+const accessTokenPrefix = "ls_"
+
+// siteTokenPrefix starts the token each site is deployed with, followed by the site's name
+const siteTokenPrefix = accessTokenPrefix + "site_"
+
+// Device codes are approved in a browser by someone holding the admin token
+const (
+	deviceCodeExpiry   = 10 * time.Minute
+	deviceCodeInterval = 5 // Seconds between token polls
+)
+
+// userCodeAlphabet leaves out letters and digits that are easy to confuse
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ23456789"
+
+// AuthHandler issues and checks access tokens for the CLI at /auth
+// Tokens are issued by the admin (for pasting or CI) or through a device code flow:
+// the CLI requests a code, someone with the admin token approves it in a browser,
+// and the CLI's poll returns the token. Only token hashes are stored.
+type AuthHandler struct {
+	path       string // JSON file tokens are persisted to (empty for in-memory only)
+	adminToken string
+	required   bool // Reject /sites and registry requests without a valid token
+
+	mu      sync.RWMutex
+	tokens  map[string]models.AccessToken // By token hash
+	devices map[string]*deviceAuth        // By device code
+}
+
+// deviceAuth is a pending device code
+type deviceAuth struct {
+	userCode  string
+	name      string
+	expiresAt time.Time
+	token     string // Set once approved
+}
+
+// NewAuthHandler creates an auth handler, loading issued tokens from path if set
+func NewAuthHandler(path, adminToken string, required bool) (*AuthHandler, error) {
+	h := &AuthHandler{
+		path:       path,
+		adminToken: adminToken,
+		required:   required,
+		tokens:     make(map[string]models.AccessToken),
+		devices:    make(map[string]*deviceAuth),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.AccessTokenList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, t := range list.Tokens {
+		h.tokens[t.Hash] = t
+	}
+	log.Printf("[AUTH] Loaded %d access tokens from %s", len(h.tokens), path)
+
+	return h, nil
+}
+
+// ServeHTTP routes /auth requests
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/auth"), "/")
+
+	log.Printf("[API] %s /auth/%s", r.Method, path)
+
+	switch {
+	case path == "device" && r.Method == http.MethodPost:
+		h.startDevice(w, r)
+	case path == "activate" && r.Method == http.MethodGet:
+		h.renderActivate(w, r.URL.Query().Get("code"), "")
+	case path == "activate" && r.Method == http.MethodPost:
+		h.activate(w, r)
+	case path == "token" && r.Method == http.MethodPost:
+		h.pollDevice(w, r)
+	case path == "token" && r.Method == http.MethodDelete:
+		h.revoke(w, r)
+	case path == "tokens" && r.Method == http.MethodPost:
+		h.issueToken(w, r)
+	case path == "whoami" && r.Method == http.MethodGet:
+		h.whoami(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Require wraps a handler so it rejects requests without a valid access token when
+// auth is required. The admin token is always accepted, and a site token only for its
+// own site's queues. Registry clients are challenged for Basic auth, which docker login
+// answers with the token as password.
+func (h *AuthHandler) Require(next http.Handler, registry bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if !h.required || h.valid(token) || h.siteRoute(token, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if registry {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Lightspeed"`)
+			writeRegistryUnauthorized(w)
+			return
+		}
+		h.writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Error: "Authentication required: run 'lightspeed login'", Code: models.ErrorCodeUnauthorized})
+	})
+}
+
+// valid checks if a token is the admin token or an issued access token
+// Issued tokens record when they were last used (at most once a minute, in memory)
+func (h *AuthHandler) valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	if h.isAdmin(token) {
+		return true
+	}
+
+	hash := hashToken(token)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.tokens[hash]
+	if !ok {
+		return false
+	}
+	if now := time.Now().UTC(); t.LastUsedAt == "" || now.Sub(parseTime(t.LastUsedAt)) > time.Minute {
+		t.LastUsedAt = now.Format(time.RFC3339)
+		h.tokens[hash] = t
+	}
+	return true
+}
+
+// Admin checks if a request carries the admin token
+// The one check behind every admin-only endpoint, so they all compare in constant time
+func (h *AuthHandler) Admin(r *http.Request) bool {
+	return h.isAdmin(requestToken(r))
+}
+
+// isAdmin checks if a token is the admin token (never when no admin token is set)
+func (h *AuthHandler) isAdmin(token string) bool {
+	return h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
+
+// SiteToken returns the token a site is deployed with: the site's name and an HMAC of it keyed
+// with the admin token, so it's checked without being stored. It only reaches the site's own
+// queues, never admin endpoints or other sites. Empty without an admin token (or auth handler).
+func (h *AuthHandler) SiteToken(site string) string {
+	if h == nil || h.adminToken == "" {
+		return ""
+	}
+	return siteTokenPrefix + site + "." + h.siteKey("site", site)
+}
+
+// CronKey returns the key the operator signs a site's cron requests with, derived from the
+// admin token and the site's name, so each site can only check its own requests
+// Empty without an admin token (or auth handler)
+func (h *AuthHandler) CronKey(site string) string {
+	if h == nil || h.adminToken == "" {
+		return ""
+	}
+	return h.siteKey("cron", site)
+}
+
+// EdgeKey returns the key the edge proxy sends to a site in edge mode, derived from the
+// admin token and the site's name; the site's server rejects requests without it, so its
+// ingress can't be used to get around the edge's rules
+// Empty without an admin token (or auth handler)
+func (h *AuthHandler) EdgeKey(site string) string {
+	if h == nil || h.adminToken == "" {
+		return ""
+	}
+	return h.siteKey("edge", site)
+}
+
+// siteKey derives a site's key for a purpose from the admin token (hex HMAC-SHA256)
+func (h *AuthHandler) siteKey(purpose, site string) string {
+	mac := hmac.New(sha256.New, []byte(h.adminToken))
+	mac.Write([]byte(purpose + "\n" + site))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenSite returns the site a site token belongs to, if it is a valid one
+func (h *AuthHandler) tokenSite(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, siteTokenPrefix)
+	if !ok || h.adminToken == "" {
+		return "", false
+	}
+	site, key, ok := strings.Cut(rest, ".")
+	if !ok || site == "" || subtle.ConstantTimeCompare([]byte(key), []byte(h.siteKey("site", site))) != 1 {
+		return "", false
+	}
+	return site, true
+}
+
+// SiteAccess checks if a request may use a site's queues: with the site's own token, or the
+// admin token or an access token. Checked even when auth isn't required, so a site's token
+// (or a request without one) never reaches another site's jobs.
+func (h *AuthHandler) SiteAccess(r *http.Request, site string) bool {
+	if h == nil {
+		return false
+	}
+	token := requestToken(r)
+	if owner, ok := h.tokenSite(token); ok {
+		return owner == site
+	}
+	return h.valid(token)
+}
+
+// siteRoute checks if a token is a site token and the path is one of its site's queue endpoints
+func (h *AuthHandler) siteRoute(token, path string) bool {
+	site, ok := h.tokenSite(token)
+	if !ok {
+		return false
+	}
+	rest, ok := strings.CutPrefix(path, "/sites/"+site+"/queues")
+	return ok && (rest == "" || strings.HasPrefix(rest, "/"))
+}
+
+// startDevice starts a device code flow
+func (h *AuthHandler) startDevice(w http.ResponseWriter, r *http.Request) {
+	var request models.DeviceCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	if request.Name == "" {
+		request.Name = "cli"
+	}
+	device := &deviceAuth{
+		userCode:  userCode(),
+		name:      request.Name,
+		expiresAt: time.Now().Add(deviceCodeExpiry),
+	}
+	deviceCode := randomHex(32)
+
+	h.mu.Lock()
+	h.pruneDevices()
+	h.devices[deviceCode] = device
+	h.mu.Unlock()
+
+	verificationURL := publicURL(r) + "/auth/activate"
+	h.writeJSON(w, http.StatusOK, models.DeviceCode{
+		DeviceCode:              deviceCode,
+		UserCode:                device.userCode,
+		VerificationURL:         verificationURL,
+		VerificationURLComplete: verificationURL + "?code=" + device.userCode,
+		ExpiresIn:               int(deviceCodeExpiry.Seconds()),
+		Interval:                deviceCodeInterval,
+	})
+}
+
+// activate approves a device code with the admin token
+func (h *AuthHandler) activate(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(strings.TrimSpace(r.FormValue("code")))
+	if !h.isAdmin(r.FormValue("admin_token")) {
+		h.renderActivate(w, code, "The admin token is not valid")
+		return
+	}
+
+	h.mu.Lock()
+	var device *deviceAuth
+	for _, d := range h.devices {
+		if d.userCode == code && d.token == "" && time.Now().Before(d.expiresAt) {
+			device = d
+		}
+	}
+	if device == nil {
+		h.mu.Unlock()
+		h.renderActivate(w, code, "This code is not valid or has expired")
+		return
+	}
+	// The name is what pull secrets are registered for, so it's approved along with the code
+	if r.FormValue("name") != device.name {
+		h.mu.Unlock()
+		h.renderActivate(w, code, "Check the name of the access token and approve again")
+		return
+	}
+	token, err := h.issue(device.name)
+	if err == nil {
+		device.token = token
+	}
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[AUTH] Error: Failed to save access tokens: %v", err)
+		h.renderActivate(w, code, "Failed to save the access token")
+		return
+	}
+
+	log.Printf("[AUDIT] Device %s approved for %s (from %s)", code, device.name, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	activateTemplate.Execute(w, activatePage{Done: true})
+}
+
+// pollDevice returns the access token of an approved device code
+// Errors follow the device authorization grant (RFC 8628): authorization_pending until
+// approved, expired_token once the code expires
+func (h *AuthHandler) pollDevice(w http.ResponseWriter, r *http.Request) {
+	var request models.DeviceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	device, ok := h.devices[request.DeviceCode]
+	if ok && device.token != "" {
+		delete(h.devices, request.DeviceCode)
+	}
+	h.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(device.expiresAt) && device.token == "":
+		h.writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "expired_token"})
+	case device.token == "":
+		h.writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "authorization_pending"})
+	default:
+		h.writeJSON(w, http.StatusOK, h.describe(device.token))
+	}
+}
+
+// issueToken issues an access token directly; requires the admin token
+func (h *AuthHandler) issueToken(w http.ResponseWriter, r *http.Request) {
+	if !h.Admin(r) {
+		h.writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Error: "Admin token required", Code: models.ErrorCodeUnauthorized})
+		return
+	}
+
+	var request models.DeviceCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	token, err := h.issue(request.Name)
+	h.mu.Unlock()
+	if err != nil {
+		log.Printf("[AUTH] Error: Failed to save access tokens: %v", err)
+		h.writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to save access token"})
+		return
+	}
+
+	log.Printf("[AUDIT] Access token issued for %s (from %s)", request.Name, r.RemoteAddr)
+	h.writeJSON(w, http.StatusCreated, h.describe(token))
+}
+
+// whoami describes the access token of the request
+func (h *AuthHandler) whoami(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if !h.valid(token) {
+		h.writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Error: "Not logged in", Code: models.ErrorCodeUnauthorized})
+		return
+	}
+
+	described := h.describe(token)
+	described.Token = ""
+	h.writeJSON(w, http.StatusOK, described)
+}
+
+// revoke deletes the access token of the request
+func (h *AuthHandler) revoke(w http.ResponseWriter, r *http.Request) {
+	hash := hashToken(requestToken(r))
+
+	h.mu.Lock()
+	t, ok := h.tokens[hash]
+	if ok {
+		delete(h.tokens, hash)
+	}
+	err := h.save()
+	h.mu.Unlock()
+
+	if !ok {
+		h.writeJSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Access token not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("[AUTH] Error: Failed to save access tokens: %v", err)
+	}
+
+	log.Printf("[AUDIT] Access token of %s revoked (from %s)", t.Name, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issue creates and saves an access token (caller must hold the lock)
+func (h *AuthHandler) issue(name string) (string, error) {
+	if name == "" {
+		name = "cli"
+	}
+	token := accessTokenPrefix + randomHex(32)
+	hash := hashToken(token)
+	h.tokens[hash] = models.AccessToken{
+		Name:      name,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := h.save(); err != nil {
+		delete(h.tokens, hash)
+		return "", err
+	}
+	return token, nil
+}
+
+// tokenHashes returns the hashes of the issued access tokens with a name
+func (h *AuthHandler) tokenHashes(name string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var hashes []string
+	for hash, t := range h.tokens {
+		if t.Name == name {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// describe returns the details of a token, including the token itself
+func (h *AuthHandler) describe(token string) models.AccessToken {
+	if h.isAdmin(token) {
+		return models.AccessToken{Token: token, Name: "operator", Admin: true}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	t := h.tokens[hashToken(token)]
+	t.Token = token
+	t.Hash = ""
+	return t
+}
+
+// pruneDevices drops expired device codes (caller must hold the lock)
+func (h *AuthHandler) pruneDevices() {
+	for code, d := range h.devices {
+		if time.Now().After(d.expiresAt) {
+			delete(h.devices, code)
+		}
+	}
+}
+
+// save writes all token hashes to the tokens file (caller must hold the lock)
+func (h *AuthHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	list := models.AccessTokenList{Tokens: make([]models.AccessToken, 0, len(h.tokens))}
+	for _, t := range h.tokens {
+		list.Tokens = append(list.Tokens, t)
+	}
+	sort.Slice(list.Tokens, func(i, j int) bool { return list.Tokens[i].CreatedAt < list.Tokens[j].CreatedAt })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *AuthHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// renderActivate renders the device approval form, with the name of the access token a
+// pending code asks for
+func (h *AuthHandler) renderActivate(w http.ResponseWriter, code, problem string) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	page := activatePage{Code: code, Error: problem}
+	h.mu.RLock()
+	for _, d := range h.devices {
+		if code != "" && d.userCode == code && d.token == "" && time.Now().Before(d.expiresAt) {
+			page.Name = d.name
+		}
+	}
+	h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if problem != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	activateTemplate.Execute(w, page)
+}
+
+// requestToken returns the token of a request, from a bearer token or a Basic auth password
+func requestToken(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// isAccessToken checks if a token was issued by the operator
+func isAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// hashToken returns the hex SHA-256 of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// userCode returns a code to type in the browser, like WDJB-MJHT
+func userCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	code := make([]byte, 0, 9)
+	for i, c := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, userCodeAlphabet[int(c)%len(userCodeAlphabet)])
+	}
+	return string(code)
+}
+
+// publicURL returns the scheme and host the request was made to
+func publicURL(r *http.Request) string {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host
+}
+
+// parseTime parses an RFC 3339 time (zero if invalid)
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
+
+// writeRegistryUnauthorized writes a registry API error for a missing login
+func writeRegistryUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required: run 'lightspeed login'"}]}`))
+}
+
+// activatePage is the data of the device approval form
+type activatePage struct {
+	Code  string
+	Name  string // Name of the access token the code asks for (if it's pending)
+	Error string
+	Done  bool
+}
+
+var activateTemplate = template.Must(template.New("activate").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Lightspeed login</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 420px; margin: 80px auto; padding: 0 16px; color: #1f2933; }
+label { display: block; margin: 16px 0 4px; }
+input { width: 100%; padding: 8px; box-sizing: border-box; font-size: 1em; }
+button { margin-top: 24px; padding: 8px 16px; font-size: 1em; }
+.error { color: #c92a2a; }
+</style>
+</head>
+<body>
+<h1>Lightspeed login</h1>
+{{if .Done}}
+<p>The CLI is logged in. You can close this window.</p>
+{{else}}
+<p>Confirm the code shown by <code>lightspeed login</code>.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/auth/activate">
+<label for="code">Code</label>
+<input id="code" name="code" value="{{.Code}}" autocomplete="off" required>
+{{if .Name}}<p>It asks for an access token named <strong>{{.Name}}</strong>; pull secrets registered for that name are handed to it.</p>
+<input type="hidden" name="name" value="{{.Name}}">{{end}}
+<label for="admin_token">Admin token</label>
+<input id="admin_token" name="admin_token" type="password" required>
+<button type="submit">Approve</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	models "lightspeed/core/lib/api"
+)
+
+// BaseDomainsHandler handles /base-domains endpoints
+// Tenants register their own domain with a DNS provider token scoped to it; holding a
+// working token for the zone is the proof of ownership. Site subdomains are then
+// provisioned under the tenant's zone instead of lightspeed.ee.
+type BaseDomainsHandler struct {
+	path       string // JSON file base domains are persisted to (empty for in-memory only)
+	auth       *AuthHandler
+	statusHost string // Host status domains are pointed at (the operator's public host)
+
+	mu        sync.RWMutex
+	domains   map[string]models.BaseDomain
+	providers map[string]DNSProvider
+}
+
+// NewBaseDomainsHandler creates a base domains handler, loading saved domains from path if set
+func NewBaseDomainsHandler(path string, auth *AuthHandler) (*BaseDomainsHandler, error) {
+	h := &BaseDomainsHandler{
+		path:      path,
+		auth:      auth,
+		domains:   make(map[string]models.BaseDomain),
+		providers: make(map[string]DNSProvider),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.BaseDomainList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, d := range list.BaseDomains {
+		provider := newDNSProvider(d.Provider, d.Token, d.Domain)
+		if provider == nil {
+			log.Printf("[API] Skipping base domain %s: unsupported provider %q", d.Domain, d.Provider)
+			continue
+		}
+		h.domains[d.Domain] = d
+		h.providers[d.Domain] = provider
+	}
+	log.Printf("[API] Loaded %d base domains from %s", len(h.domains), path)
+
+	return h, nil
+}
+
+// SetStatusHost sets the host tenants' status page domains are CNAMEd to
+func (h *BaseDomainsHandler) SetStatusHost(host string) {
+	h.statusHost = host
+}
+
+// TenantForStatusDomain returns the base domain whose status page is served on a domain
+// (empty if none is)
+func (h *BaseDomainsHandler) TenantForStatusDomain(domain string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for base, d := range h.domains {
+		if d.StatusDomain != "" && d.StatusDomain == domain {
+			return base
+		}
+	}
+	return ""
+}
+
+// Has checks if a base domain is registered
+func (h *BaseDomainsHandler) Has(domain string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, ok := h.domains[domain]
+	return ok
+}
+
+// ProviderFor returns the DNS provider of the registered base domain a domain is under
+// (nil if it isn't under one)
+func (h *BaseDomainsHandler) ProviderFor(domain string) DNSProvider {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var best string
+	for base := range h.domains {
+		if strings.HasSuffix(domain, "."+base) && len(base) > len(best) {
+			best = base
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return h.providers[best]
+}
+
+// ServeHTTP routes requests to appropriate handlers
+func (h *BaseDomainsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/base-domains"), "/")
+
+	log.Printf("[API] %s /base-domains/%s", r.Method, path)
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, models.BaseDomainList{BaseDomains: h.list()})
+	case path == "" && r.Method == http.MethodPost:
+		h.registerDomain(w, r)
+	case strings.HasSuffix(path, "/setup") && r.Method == http.MethodGet:
+		domain := strings.TrimSuffix(path, "/setup")
+		h.writeJSON(w, http.StatusOK, baseDomainSetup(domain, ""))
+	case r.Method == http.MethodDelete:
+		h.deleteDomain(w, r, path)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns all base domains (without tokens) sorted by domain
+func (h *BaseDomainsHandler) list() []models.BaseDomain {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := make([]models.BaseDomain, 0, len(h.domains))
+	for _, d := range h.domains {
+		d.Token = ""
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list
+}
+
+// registerDomain verifies the provider token can manage the zone and registers it
+func (h *BaseDomainsHandler) registerDomain(w http.ResponseWriter, r *http.Request) {
+	var d models.BaseDomain
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.Domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d.Domain), "."))
+	if !validBaseDomain(d.Domain) {
+		h.writeError(w, fmt.Sprintf("'%s' is not a valid domain", d.Domain), http.StatusBadRequest)
+		return
+	}
+	if d.Domain == baseDomain || strings.HasSuffix(d.Domain, "."+baseDomain) {
+		h.writeError(w, baseDomain+" domains can't be registered", http.StatusBadRequest)
+		return
+	}
+
+	provider := newDNSProvider(d.Provider, d.Token, d.Domain)
+	if provider == nil {
+		h.writeError(w, fmt.Sprintf("Unsupported DNS provider '%s'", d.Provider), http.StatusBadRequest)
+		return
+	}
+	if d.Token == "" {
+		h.writeJSON(w, http.StatusUnprocessableEntity, baseDomainSetup(d.Domain, "A DNS provider API token is required"))
+		return
+	}
+	if err := provider.Verify(); err != nil {
+		log.Printf("[API] Base domain %s failed verification: %v", d.Domain, err)
+		h.writeJSON(w, http.StatusUnprocessableEntity, baseDomainSetup(d.Domain, "Could not access the zone with this token: "+err.Error()))
+		return
+	}
+
+	if d.Provider == "" {
+		d.Provider = "cloudflare"
+	}
+	if cf, ok := provider.(*CloudflareClient); ok {
+		d.NameServers = cf.NameServers()
+	}
+
+	if d.StatusDomain != "" {
+		d.StatusDomain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d.StatusDomain), "."))
+		if !strings.HasSuffix(d.StatusDomain, "."+d.Domain) || !validBaseDomain(d.StatusDomain) {
+			h.writeError(w, fmt.Sprintf("The status page domain must be a subdomain of %s", d.Domain), http.StatusBadRequest)
+			return
+		}
+		if err := h.pointStatusDomain(provider, d.StatusDomain); err != nil {
+			h.writeError(w, "Failed to point the status page domain at the operator: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	h.mu.Lock()
+	h.domains[d.Domain] = d
+	h.providers[d.Domain] = provider
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save base domains: %v", err)
+		h.writeError(w, "Failed to save base domain", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Registered base domain %s (%s)", d.Domain, d.Provider)
+	d.Token = ""
+	h.writeJSON(w, http.StatusCreated, d)
+}
+
+// deleteDomain removes a base domain; requires the domain's provider token or the admin token
+func (h *BaseDomainsHandler) deleteDomain(w http.ResponseWriter, r *http.Request, domain string) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	admin := h.auth.Admin(r)
+
+	h.mu.Lock()
+	d, ok := h.domains[domain]
+	if !ok {
+		h.mu.Unlock()
+		h.writeError(w, "Base domain not found", http.StatusNotFound)
+		return
+	}
+	if !admin && (token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.Token)) != 1) {
+		h.mu.Unlock()
+		h.writeError(w, "The domain's provider token or the admin token is required", http.StatusUnauthorized)
+		return
+	}
+	delete(h.domains, domain)
+	delete(h.providers, domain)
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save base domains: %v", err)
+		h.writeError(w, "Failed to save base domains", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Deleted base domain %s", domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pointStatusDomain CNAMEs a status page domain to the operator, serving it through the
+// provider's CDN where supported so it gets a certificate
+func (h *BaseDomainsHandler) pointStatusDomain(provider DNSProvider, domain string) error {
+	if h.statusHost == "" {
+		return nil
+	}
+	if err := provider.EnsureCNAME(domain, h.statusHost); err != nil {
+		return err
+	}
+	if proxy, ok := provider.(ProxyProvider); ok {
+		if err := proxy.SetProxied(domain, true); err != nil {
+			return err
+		}
+	}
+	log.Printf("[API] Status page domain %s points at %s", domain, h.statusHost)
+	return nil
+}
+
+// save writes all base domains (with tokens) to the base domains file (caller must hold the lock)
+func (h *BaseDomainsHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	list := models.BaseDomainList{BaseDomains: make([]models.BaseDomain, 0, len(h.domains))}
+	for _, d := range h.domains {
+		list.BaseDomains = append(list.BaseDomains, d)
+	}
+	sort.Slice(list.BaseDomains, func(i, j int) bool { return list.BaseDomains[i].Domain < list.BaseDomains[j].Domain })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The file holds provider tokens, so keep it private
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *BaseDomainsHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError writes a JSON error response
+func (h *BaseDomainsHandler) writeError(w http.ResponseWriter, message string, status int) {
+	h.writeJSON(w, status, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
+
+// validBaseDomain checks a domain has at least two valid DNS labels
+func validBaseDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if !subdomainPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// baseDomainSetup returns the steps to delegate a domain to a supported provider
+func baseDomainSetup(domain, problem string) models.BaseDomainSetup {
+	return models.BaseDomainSetup{
+		Domain: domain,
+		Error:  problem,
+		Steps: []string{
+			fmt.Sprintf("Add %s to Cloudflare (the free plan is enough) at https://dash.cloudflare.com", domain),
+			"At your registrar, replace the domain's nameservers with the two Cloudflare nameservers shown for the zone",
+			fmt.Sprintf("Create a Cloudflare API token with the 'Zone > DNS > Edit' permission limited to the %s zone", domain),
+			fmt.Sprintf("Register the domain: lightspeed base-domain %s --token <token>", domain),
+			fmt.Sprintf("Set base_domain=%s in site.properties; new sites are created as <name>.%s", domain, domain),
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// BuildNumbers allocates build numbers per site, so concurrent builds never get the same tag
+// Counters are persisted to a JSON file before a number is handed out
+type BuildNumbers struct {
+	path string // JSON file counters are persisted to (empty for in-memory only)
+
+	mu       sync.Mutex
+	counters map[string]int
+}
+
+// NewBuildNumbers creates a build number allocator, loading saved counters from path if set
+func NewBuildNumbers(path string) (*BuildNumbers, error) {
+	b := &BuildNumbers{
+		path:     path,
+		counters: make(map[string]int),
+	}
+
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &b.counters); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	log.Printf("[API] Loaded %d build counters from %s", len(b.counters), path)
+
+	return b, nil
+}
+
+// Next allocates the number after both the counter for key and floor
+// The counter is saved before the number is returned; if saving fails, nothing is allocated
+func (b *BuildNumbers) Next(key string, floor int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous, ok := b.counters[key]
+	next := previous + 1
+	if floor >= next {
+		next = floor + 1
+	}
+
+	b.counters[key] = next
+	if err := b.save(); err != nil {
+		if ok {
+			b.counters[key] = previous
+		} else {
+			delete(b.counters, key)
+		}
+		return 0, err
+	}
+
+	return next, nil
+}
+
+// save writes the counters to the JSON file (no-op if in-memory)
+func (b *BuildNumbers) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.counters, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write doesn't lose the counters
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// allocateTag allocates the next build number or dated tag of a site
+// The site doesn't need to exist yet, so CI can allocate a tag before the first deploy.
+// Counters start after the highest matching tag already in the registry.
+func (h *SitesHandler) allocateTag(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if !subdomainPattern.MatchString(name) {
+		h.writeError(w, fmt.Sprintf("'%s' is not a valid site name", name), nil, http.StatusBadRequest)
+		return
+	}
+	if h.buildNumbers == nil {
+		h.writeError(w, "Tag allocation is not available", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = "build"
+	}
+	prefix := ""
+	switch strategy {
+	case "build":
+	case "date":
+		prefix = time.Now().UTC().Format("2006.01.02") + "-"
+	default:
+		h.writeError(w, "strategy must be build or date", nil, http.StatusBadRequest)
+		return
+	}
+
+	// Tags pushed before the counter existed (or by the CLI's own numbering) are skipped over
+	floor := 0
+	if tags, err := do.ListTags(r.Context(), h.defaultRegistry, name); err == nil {
+		for _, tag := range tags {
+			suffix, ok := strings.CutPrefix(tag.Tag, prefix)
+			if !ok {
+				continue
+			}
+			if n, err := strconv.Atoi(suffix); err == nil && n > floor {
+				floor = n
+			}
+		}
+	}
+
+	number, err := h.buildNumbers.Next(name+"@"+prefix, floor)
+	if err != nil {
+		h.writeError(w, "Failed to save build number", err, http.StatusInternalServerError)
+		return
+	}
+
+	tag := prefix + strconv.Itoa(number)
+	log.Printf("[API] Allocated tag %s:%s", name, tag)
+	h.writeJSONStatus(w, http.StatusCreated, models.TagAllocation{Tag: tag, Number: number})
+}
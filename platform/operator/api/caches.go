@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Defaults of a site's dedicated cache
+const (
+	cacheEngine      = "valkey"
+	defaultCacheSize = "db-s-1vcpu-1gb"
+)
+
+// cacheTagPrefix tags a cache cluster with the site it belongs to
+// Caches have their own tag, so they're never mistaken for the site's database
+const cacheTagPrefix = "lightspeed-cache:"
+
+// cacheEnvKeys are the site variables a cache sets, removed when it's destroyed
+var cacheEnvKeys = []string{"REDIS_URL", "REDIS_HOST", "REDIS_PORT", "REDIS_USERNAME", "REDIS_PASSWORD", "REDIS_PREFIX"}
+
+// serveSiteCache routes /sites/{name}/cache requests
+func (h *SitesHandler) serveSiteCache(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getSiteCache(w, r, do, name)
+	case http.MethodPost:
+		h.createSiteCache(w, r, do, name)
+	case http.MethodDelete:
+		h.deleteSiteCache(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSiteCache returns a site's cache with its connection details
+func (h *SitesHandler) getSiteCache(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if _, ok := h.findApp(w, r, do, name); !ok {
+		return
+	}
+
+	if user, ok := h.cache.Get(name); ok {
+		h.writeJSON(w, h.sharedCacheResponse(user))
+		return
+	}
+
+	db, err := findSiteCache(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list caches", err)
+		return
+	}
+	if db == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no cache", name), nil, http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, cacheResponse(db))
+}
+
+// createSiteCache gives a site a cache, either a dedicated managed Valkey cluster or a user on
+// the shared instance, and sets its connection details as site variables, which redeploys the site
+// A site that already has a cache gets its variables set again, so a failed update can be retried
+func (h *SitesHandler) createSiteCache(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	// The body is optional
+	var req models.CacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if req.Size == "" {
+		req.Size = defaultCacheSize
+	}
+	if !databaseSizePattern.MatchString(req.Size) {
+		h.writeError(w, fmt.Sprintf("'%s' is not a database size (e.g. db-s-1vcpu-1gb)", req.Size), nil, http.StatusBadRequest)
+		return
+	}
+	if req.Shared && !h.cache.Enabled() {
+		h.writeError(w, "The operator has no shared cache instance (start it with --cache-url)", nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	// A valkey database already sets the REDIS_* variables
+	database, err := findSiteDatabase(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list databases", err)
+		return
+	}
+	if database != nil && (database.Engine == "valkey" || database.Engine == "redis") {
+		h.writeError(w, fmt.Sprintf("Site '%s' already has a %s database, which sets the same variables", name, database.Engine), nil, http.StatusConflict)
+		return
+	}
+
+	db, err := findSiteCache(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list caches", err)
+		return
+	}
+	_, hasShared := h.cache.Get(name)
+	switch {
+	case req.Shared && db != nil:
+		h.writeError(w, fmt.Sprintf("Site '%s' already has a dedicated cache", name), nil, http.StatusConflict)
+		return
+	case !req.Shared && hasShared:
+		h.writeError(w, fmt.Sprintf("Site '%s' already has a cache on the shared instance", name), nil, http.StatusConflict)
+		return
+	}
+
+	var response models.Cache
+	var env []models.EnvVar
+	created := false
+	if req.Shared {
+		user, isNew, err := h.cache.Create(r.Context(), name)
+		if err != nil {
+			h.writeError(w, "Failed to create cache user", err, http.StatusBadGateway)
+			return
+		}
+		created = isNew
+		if created {
+			log.Printf("[AUDIT] Created shared cache user %s for %s (from %s)", user.User, name, r.RemoteAddr)
+		}
+		response = h.sharedCacheResponse(user)
+		env = h.sharedCacheEnv(user)
+	} else {
+		created = db == nil
+		if created {
+			region := databaseRegions[app.Spec.Region]
+			if region == "" {
+				region = databaseRegions[defaultRegion]
+			}
+			db, err = do.CreateDatabase(r.Context(), digitalocean.DatabaseCreate{
+				Name:     name + "-cache",
+				Engine:   cacheEngine,
+				Version:  req.Version,
+				Size:     req.Size,
+				Region:   region,
+				NumNodes: 1,
+				Tags:     []string{cacheTagPrefix + name},
+			})
+			if err != nil {
+				h.writeAPIError(w, "Failed to create cache", err)
+				return
+			}
+			log.Printf("[AUDIT] Created cache %s for %s (from %s)", db.Name, name, r.RemoteAddr)
+
+			// Only the site's app may connect; the cache stays reachable if this fails
+			rules := []digitalocean.DatabaseFirewallRule{{Type: "app", Value: app.ID}}
+			if err := do.SetDatabaseFirewall(r.Context(), db.ID, rules); err != nil {
+				log.Printf("[API] Failed to restrict cache %s to %s: %v", db.Name, name, err)
+			}
+		}
+		response = cacheResponse(db)
+		env = cacheEnv(db)
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Cache created, but failed to get site spec", err)
+		return
+	}
+	updateSpecEnvs(spec, models.EnvUpdate{Set: env})
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Cache created, but failed to set the site's variables (retry to set them)", err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	h.writeJSONStatus(w, status, response)
+}
+
+// deleteSiteCache deletes a site's cache and its data, and removes its site variables
+func (h *SitesHandler) deleteSiteCache(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	if user, ok := h.cache.Get(name); ok {
+		if err := h.cache.Delete(r.Context(), name); err != nil {
+			h.writeError(w, "Failed to delete cache user", err, http.StatusBadGateway)
+			return
+		}
+		log.Printf("[AUDIT] Deleted shared cache user %s of %s (from %s)", user.User, name, r.RemoteAddr)
+	} else {
+		db, err := findSiteCache(r.Context(), do, name)
+		if err != nil {
+			h.writeAPIError(w, "Failed to list caches", err)
+			return
+		}
+		if db == nil {
+			h.writeError(w, fmt.Sprintf("Site '%s' has no cache", name), nil, http.StatusNotFound)
+			return
+		}
+		if err := do.DeleteDatabase(r.Context(), db.ID); err != nil {
+			h.writeAPIError(w, "Failed to delete cache", err)
+			return
+		}
+		log.Printf("[AUDIT] Deleted cache %s of %s (from %s)", db.Name, name, r.RemoteAddr)
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Cache deleted, but failed to get site spec", err)
+		return
+	}
+	updateSpecEnvs(spec, models.EnvUpdate{Unset: cacheEnvKeys})
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Cache deleted, but failed to remove the site's variables", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findSiteCache finds the dedicated cache of a site by its tag (nil if it has none)
+func findSiteCache(ctx context.Context, do *digitalocean.Client, name string) (*digitalocean.Database, error) {
+	databases, err := do.ListDatabases(ctx, cacheTagPrefix+name)
+	if err != nil || len(databases) == 0 {
+		return nil, err
+	}
+	return &databases[0], nil
+}
+
+// siteHasCache checks if a site has a cache of either kind
+func (h *SitesHandler) siteHasCache(ctx context.Context, do *digitalocean.Client, name string) (bool, error) {
+	if _, ok := h.cache.Get(name); ok {
+		return true, nil
+	}
+	db, err := findSiteCache(ctx, do, name)
+	return db != nil, err
+}
+
+// cacheEnv returns the site variables for a dedicated cache's connection details
+func cacheEnv(db *digitalocean.Database) []models.EnvVar {
+	conn := db.Connection
+	return []models.EnvVar{
+		{Key: "REDIS_URL", Value: conn.URI, Type: "SECRET"},
+		{Key: "REDIS_HOST", Value: conn.Host, Type: "GENERAL"},
+		{Key: "REDIS_PORT", Value: strconv.Itoa(conn.Port), Type: "GENERAL"},
+		{Key: "REDIS_USERNAME", Value: conn.User, Type: "GENERAL"},
+		{Key: "REDIS_PASSWORD", Value: conn.Password, Type: "SECRET"},
+	}
+}
+
+// sharedCacheEnv returns the site variables for a site's user on the shared instance
+func (h *SitesHandler) sharedCacheEnv(user sharedCacheUser) []models.EnvVar {
+	uri, host, port := h.cache.Connection(user)
+	return []models.EnvVar{
+		{Key: "REDIS_URL", Value: uri, Type: "SECRET"},
+		{Key: "REDIS_HOST", Value: host, Type: "GENERAL"},
+		{Key: "REDIS_PORT", Value: strconv.Itoa(port), Type: "GENERAL"},
+		{Key: "REDIS_USERNAME", Value: user.User, Type: "GENERAL"},
+		{Key: "REDIS_PASSWORD", Value: user.Password, Type: "SECRET"},
+		{Key: "REDIS_PREFIX", Value: sharedCachePrefix(user.Site), Type: "GENERAL"},
+	}
+}
+
+// cacheResponse converts a dedicated cache cluster to its API form
+func cacheResponse(db *digitalocean.Database) models.Cache {
+	response := models.Cache{
+		Name:     db.Name,
+		Engine:   db.Engine,
+		Version:  db.Version,
+		Size:     db.Size,
+		Region:   db.Region,
+		Status:   db.Status,
+		Host:     db.Connection.Host,
+		Port:     db.Connection.Port,
+		User:     db.Connection.User,
+		Password: db.Connection.Password,
+		URI:      db.Connection.URI,
+	}
+	for _, env := range cacheEnv(db) {
+		response.Env = append(response.Env, env.Key)
+	}
+	if !db.CreatedAt.IsZero() {
+		response.CreatedAt = db.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+// sharedCacheResponse converts a site's user on the shared instance to its API form
+func (h *SitesHandler) sharedCacheResponse(user sharedCacheUser) models.Cache {
+	uri, host, port := h.cache.Connection(user)
+	response := models.Cache{
+		Name:      user.User,
+		Shared:    true,
+		Engine:    cacheEngine,
+		Status:    "online",
+		Host:      host,
+		Port:      port,
+		User:      user.User,
+		Password:  user.Password,
+		URI:       uri,
+		Prefix:    sharedCachePrefix(user.Site),
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for _, env := range h.sharedCacheEnv(user) {
+		response.Env = append(response.Env, env.Key)
+	}
+	return response
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// certExpiringWithin is how close to expiring an issued certificate is reported as expiring
+// DigitalOcean renews certificates well before this, so one this close means renewal is failing
+// (usually because the domain no longer resolves to the app)
+const certExpiringWithin = 14 * 24 * time.Hour
+
+// siteCertificates returns the TLS certificate state of each of a site's domains
+func (h *SitesHandler) siteCertificates(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	h.writeJSON(w, siteCertificates(app, time.Now()))
+}
+
+// siteCertificates describes the certificates of an app's domains from their provisioning state
+// Domains the spec has but DigitalOcean doesn't report yet are pending their deployment
+func siteCertificates(app *digitalocean.App, now time.Time) models.SiteCertificateList {
+	provisioned := make(map[string]digitalocean.AppDomain, len(app.Domains))
+	for _, d := range app.Domains {
+		provisioned[d.Spec.Domain] = d
+	}
+
+	list := models.SiteCertificateList{Certificates: []models.SiteCertificate{}}
+	for _, spec := range app.Spec.Domains {
+		cert := models.SiteCertificate{Domain: spec.Domain, Type: spec.Type}
+		d, ok := provisioned[spec.Domain]
+		if !ok {
+			cert.Status = models.CertificatePending
+			cert.Message = "waiting for the deployment that adds the domain"
+			list.Certificates = append(list.Certificates, cert)
+			continue
+		}
+
+		cert.Phase = d.Phase
+		if d.CertificateExpiresAt != nil && !d.CertificateExpiresAt.IsZero() {
+			cert.ExpiresAt = d.CertificateExpiresAt.UTC().Format(time.RFC3339)
+		}
+		switch {
+		case d.Phase == "ERROR":
+			cert.Status = models.CertificateError
+		case d.Phase != "ACTIVE":
+			cert.Status = models.CertificatePending
+		case d.CertificateExpiresAt != nil && d.CertificateExpiresAt.Sub(now) < certExpiringWithin:
+			cert.Status = models.CertificateExpiring
+		default:
+			cert.Status = models.CertificateIssued
+		}
+		if cert.Status != models.CertificateIssued {
+			cert.Message = domainStepReason(d)
+		}
+		list.Certificates = append(list.Certificates, cert)
+	}
+	return list
+}
+
+// domainStepReason returns the reason of the first provisioning step of a domain that isn't done
+func domainStepReason(d digitalocean.AppDomain) string {
+	if d.Progress == nil {
+		return ""
+	}
+	for _, step := range d.Progress.Steps {
+		if step.Status == "SUCCESS" {
+			continue
+		}
+		if step.Reason != nil && step.Reason.Message != "" {
+			return step.Reason.Message
+		}
+		return step.Name + ": " + step.Status
+	}
+	return ""
+}
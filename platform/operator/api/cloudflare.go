@@ -3,28 +3,72 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+
+	models "lightspeed/core/lib/api"
 )
 
-const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+// defaultCloudflareAPI is the Cloudflare API base URL
+const defaultCloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// cloudflareAPI is the base URL the Cloudflare clients use
+var cloudflareAPI = defaultCloudflareAPI
+
+// SetCloudflareAPI overrides the Cloudflare API base URL, e.g. to point at a fake API (empty restores the default)
+func SetCloudflareAPI(baseURL string) {
+	if baseURL == "" {
+		baseURL = defaultCloudflareAPI
+	}
+	cloudflareAPI = strings.TrimSuffix(baseURL, "/")
+}
 
-// CloudflareClient handles Cloudflare API interactions
+// errCloudflareNotFound is returned when a Cloudflare resource doesn't exist
+var errCloudflareNotFound = errors.New("cloudflare resource not found")
+
+// CloudflareClient handles Cloudflare API interactions for a single zone
 type CloudflareClient struct {
-	token  string
-	zoneID string
+	token       string
+	zone        string
+	zoneID      string
+	nameServers []string
 }
 
-// NewCloudflareClient creates a new Cloudflare client
+// NewCloudflareClient creates a new Cloudflare client for the lightspeed.ee zone
 func NewCloudflareClient(token string) *CloudflareClient {
+	return NewCloudflareZoneClient(token, baseDomain)
+}
+
+// NewCloudflareZoneClient creates a new Cloudflare client for a zone
+func NewCloudflareZoneClient(token, zone string) *CloudflareClient {
 	return &CloudflareClient{
 		token: token,
+		zone:  zone,
 	}
 }
 
+// Zone returns the zone the client manages
+func (c *CloudflareClient) Zone() string {
+	return c.zone
+}
+
+// Verify checks the token can access the zone
+func (c *CloudflareClient) Verify() error {
+	_, err := c.getZoneID()
+	return err
+}
+
+// NameServers returns the Cloudflare nameservers assigned to the zone (after Verify)
+func (c *CloudflareClient) NameServers() []string {
+	return c.nameServers
+}
+
 // CloudflareResponse is the standard CF API response
 type CloudflareResponse struct {
 	Success bool              `json:"success"`
@@ -38,26 +82,28 @@ type CloudflareError struct {
 }
 
 type CloudflareZone struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	NameServers []string `json:"name_servers"`
 }
 
 type CloudflareDNSRecord struct {
-	ID      string `json:"id,omitempty"`
-	Type    string `json:"type"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	TTL     int    `json:"ttl"`
-	Proxied bool   `json:"proxied"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	TTL      int    `json:"ttl"`
+	Priority *int   `json:"priority,omitempty"`
+	Proxied  bool   `json:"proxied"`
 }
 
-// getZoneID finds the zone ID for lightspeed.ee
+// getZoneID finds the zone ID for the client's zone
 func (c *CloudflareClient) getZoneID() (string, error) {
 	if c.zoneID != "" {
 		return c.zoneID, nil
 	}
 
-	req, err := http.NewRequest("GET", cloudflareAPI+"/zones?name=lightspeed.ee", nil)
+	req, err := http.NewRequest("GET", cloudflareAPI+"/zones?name="+c.zone, nil)
 	if err != nil {
 		return "", err
 	}
@@ -91,21 +137,22 @@ func (c *CloudflareClient) getZoneID() (string, error) {
 	}
 
 	if len(zones) == 0 {
-		return "", fmt.Errorf("zone lightspeed.ee not found")
+		return "", fmt.Errorf("zone %s not found", c.zone)
 	}
 
 	c.zoneID = zones[0].ID
+	c.nameServers = zones[0].NameServers
 	return c.zoneID, nil
 }
 
-// findDNSRecord finds a DNS record by name
-func (c *CloudflareClient) findDNSRecord(name string) (*CloudflareDNSRecord, error) {
+// findDNSRecord finds a DNS record by type and name
+func (c *CloudflareClient) findDNSRecord(recordType, name string) (*CloudflareDNSRecord, error) {
 	zoneID, err := c.getZoneID()
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/zones/%s/dns_records?type=CNAME&name=%s", cloudflareAPI, zoneID, name)
+	url := fmt.Sprintf("%s/zones/%s/dns_records?type=%s&name=%s", cloudflareAPI, zoneID, recordType, name)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -148,29 +195,38 @@ func (c *CloudflareClient) findDNSRecord(name string) (*CloudflareDNSRecord, err
 
 // EnsureCNAME creates or updates a CNAME record
 func (c *CloudflareClient) EnsureCNAME(subdomain, target string) error {
-	// Ensure full domain name
-	fullName := subdomain
-	if !strings.HasSuffix(subdomain, ".lightspeed.ee") {
-		fullName = subdomain + ".lightspeed.ee"
-	}
-
 	// Remove https:// prefix if present
 	target = strings.TrimPrefix(target, "https://")
 	target = strings.TrimPrefix(target, "http://")
 
+	return c.EnsureRecord(models.DNSRecord{Type: "CNAME", Name: subdomain, Content: target})
+}
+
+// EnsureRecord creates or updates the record of a type and name
+func (c *CloudflareClient) EnsureRecord(r models.DNSRecord) error {
+	// Ensure full domain name
+	fullName := r.Name
+	if fullName != c.zone && !strings.HasSuffix(fullName, "."+c.zone) {
+		fullName = r.Name + "." + c.zone
+	}
+
 	// Check if record exists
-	existing, err := c.findDNSRecord(fullName)
+	existing, err := c.findDNSRecord(r.Type, fullName)
 	if err != nil {
 		return err
 	}
 
 	record := CloudflareDNSRecord{
-		Type:    "CNAME",
+		Type:    r.Type,
 		Name:    fullName,
-		Content: target,
+		Content: r.Content,
 		TTL:     1, // Auto
 		Proxied: false,
 	}
+	if r.Type == "MX" {
+		priority := r.Priority
+		record.Priority = &priority
+	}
 
 	zoneID, err := c.getZoneID()
 	if err != nil {
@@ -180,19 +236,21 @@ func (c *CloudflareClient) EnsureCNAME(subdomain, target string) error {
 	var req *http.Request
 	if existing != nil {
 		// Update existing record
-		if existing.Content == target {
-			log.Printf("DNS record %s already points to %s", fullName, target)
+		if existing.Content == r.Content {
+			log.Printf("DNS record %s %s already points to %s", r.Type, fullName, r.Content)
 			return nil
 		}
 
+		// Keep proxied mode when the target changes
 		record.ID = existing.ID
+		record.Proxied = existing.Proxied
 		body, _ := json.Marshal(record)
 		url := fmt.Sprintf("%s/zones/%s/dns_records/%s", cloudflareAPI, zoneID, existing.ID)
 		req, err = http.NewRequest("PUT", url, bytes.NewBuffer(body))
 		if err != nil {
 			return err
 		}
-		log.Printf("Updating DNS record %s -> %s", fullName, target)
+		log.Printf("Updating DNS record %s %s -> %s", r.Type, fullName, r.Content)
 	} else {
 		// Create new record
 		body, _ := json.Marshal(record)
@@ -201,7 +259,7 @@ func (c *CloudflareClient) EnsureCNAME(subdomain, target string) error {
 		if err != nil {
 			return err
 		}
-		log.Printf("Creating DNS record %s -> %s", fullName, target)
+		log.Printf("Creating DNS record %s %s -> %s", r.Type, fullName, r.Content)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.token)
@@ -227,6 +285,245 @@ func (c *CloudflareClient) EnsureCNAME(subdomain, target string) error {
 		return fmt.Errorf("cloudflare API failed")
 	}
 
-	log.Printf("DNS record %s successfully configured", fullName)
+	log.Printf("DNS record %s %s successfully configured", r.Type, fullName)
+	return nil
+}
+
+// call makes a Cloudflare API request for the client's zone and returns the result
+func (c *CloudflareClient) call(method, path string, payload interface{}) (json.RawMessage, error) {
+	zoneID, err := c.getZoneID()
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/zones/%s%s", cloudflareAPI, zoneID, path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errCloudflareNotFound
+	}
+
+	var cfResp CloudflareResponse
+	if err := json.Unmarshal(data, &cfResp); err != nil {
+		return nil, err
+	}
+
+	if !cfResp.Success {
+		if len(cfResp.Errors) > 0 {
+			return nil, fmt.Errorf("cloudflare error: %s", cfResp.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("cloudflare API failed")
+	}
+
+	return cfResp.Result, nil
+}
+
+// ListRecords lists the records on domain and its subdomains
+func (c *CloudflareClient) ListRecords(domain string) ([]models.DNSRecord, error) {
+	var records []models.DNSRecord
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("name.endswith", domain)
+		query.Set("per_page", "100")
+		query.Set("page", strconv.Itoa(page))
+
+		result, err := c.call("GET", "/dns_records?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var cfRecords []CloudflareDNSRecord
+		if err := json.Unmarshal(result, &cfRecords); err != nil {
+			return nil, err
+		}
+
+		for _, r := range cfRecords {
+			// endswith also matches other-domain.tld for domain.tld
+			if r.Name == domain || strings.HasSuffix(r.Name, "."+domain) {
+				records = append(records, dnsRecord(r))
+			}
+		}
+		if len(cfRecords) < 100 {
+			return records, nil
+		}
+	}
+}
+
+// CreateRecord creates a record, alongside any existing records of the same type and name
+func (c *CloudflareClient) CreateRecord(r models.DNSRecord) (*models.DNSRecord, error) {
+	cfRecord := CloudflareDNSRecord{
+		Type:    r.Type,
+		Name:    r.Name,
+		Content: r.Content,
+		TTL:     1, // Auto
+	}
+	if r.Type == "MX" {
+		priority := r.Priority
+		cfRecord.Priority = &priority
+	}
+
+	result, err := c.call("POST", "/dns_records", cfRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	var created CloudflareDNSRecord
+	if err := json.Unmarshal(result, &created); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created DNS record %s %s -> %s", created.Type, created.Name, created.Content)
+	record := dnsRecord(created)
+	return &record, nil
+}
+
+// DeleteRecord deletes a record by ID
+func (c *CloudflareClient) DeleteRecord(id string) error {
+	if _, err := c.call("DELETE", "/dns_records/"+id, nil); err != nil {
+		return err
+	}
+
+	log.Printf("Deleted DNS record %s", id)
+	return nil
+}
+
+// dnsRecord converts a Cloudflare record to a DNS record
+func dnsRecord(r CloudflareDNSRecord) models.DNSRecord {
+	record := models.DNSRecord{
+		ID:      r.ID,
+		Type:    r.Type,
+		Name:    r.Name,
+		Content: r.Content,
+		Proxied: r.Proxied,
+	}
+	if r.Priority != nil {
+		record.Priority = *r.Priority
+	}
+	return record
+}
+
+// sslConfigPhase is the ruleset phase Cloudflare configuration rules (per-host settings) live in
+const sslConfigPhase = "http_config_settings"
+
+// CloudflareRule is a rule in a zone ruleset
+type CloudflareRule struct {
+	ID               string          `json:"id,omitempty"`
+	Expression       string          `json:"expression"`
+	Action           string          `json:"action"`
+	ActionParameters json.RawMessage `json:"action_parameters,omitempty"`
+	Description      string          `json:"description,omitempty"`
+	Enabled          *bool           `json:"enabled,omitempty"`
+}
+
+// SetProxied turns Cloudflare proxying of a hostname on or off
+// Proxied hostnames get a configuration rule pinning SSL mode to Full: Flexible makes
+// HTTP requests to the origin, which App Platform redirects to HTTPS in a loop, and
+// Full (strict) fails once the origin's certificate can't renew behind the proxy
+func (c *CloudflareClient) SetProxied(hostname string, proxied bool) error {
+	record, err := c.findDNSRecord("CNAME", hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("no CNAME record for %s", hostname)
+	}
+
+	// Set SSL mode before proxying so no request goes through with the zone default
+	if proxied {
+		if err := c.setSSLRule(hostname, true); err != nil {
+			return fmt.Errorf("failed to set SSL mode: %w", err)
+		}
+	}
+
+	if record.Proxied != proxied {
+		if _, err := c.call("PATCH", "/dns_records/"+record.ID, map[string]bool{"proxied": proxied}); err != nil {
+			return err
+		}
+		log.Printf("Set DNS record %s proxied=%t", hostname, proxied)
+	}
+
+	if !proxied {
+		if err := c.setSSLRule(hostname, false); err != nil {
+			return fmt.Errorf("failed to remove SSL mode rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// PurgeCache purges the cached content of a hostname
+func (c *CloudflareClient) PurgeCache(hostname string) error {
+	if _, err := c.call("POST", "/purge_cache", map[string][]string{"hosts": {hostname}}); err != nil {
+		return err
+	}
+	log.Printf("Purged cache of %s", hostname)
+	return nil
+}
+
+// setSSLRule adds or removes the Full SSL mode configuration rule of a hostname
+func (c *CloudflareClient) setSSLRule(hostname string, present bool) error {
+	description := "lightspeed: SSL Full for " + hostname
+
+	var rules []CloudflareRule
+	result, err := c.call("GET", "/rulesets/phases/"+sslConfigPhase+"/entrypoint", nil)
+	if err != nil && !errors.Is(err, errCloudflareNotFound) {
+		return err
+	}
+	if err == nil {
+		var ruleset struct {
+			Rules []CloudflareRule `json:"rules"`
+		}
+		if err := json.Unmarshal(result, &ruleset); err != nil {
+			return err
+		}
+		rules = ruleset.Rules
+	}
+
+	kept := make([]CloudflareRule, 0, len(rules)+1)
+	found := false
+	for _, rule := range rules {
+		if rule.Description == description {
+			found = true
+			if !present {
+				continue
+			}
+		}
+		kept = append(kept, rule)
+	}
+	if found == present {
+		return nil
+	}
+	if present {
+		kept = append(kept, CloudflareRule{
+			Expression:       fmt.Sprintf("(http.host eq %q)", hostname),
+			Action:           "set_config",
+			ActionParameters: json.RawMessage(`{"ssl":"full"}`),
+			Description:      description,
+		})
+	}
+
+	if _, err := c.call("PUT", "/rulesets/phases/"+sslConfigPhase+"/entrypoint", map[string]interface{}{"rules": kept}); err != nil {
+		return err
+	}
+	log.Printf("Updated SSL mode rule for %s (present=%t)", hostname, present)
 	return nil
 }
@@ -0,0 +1,467 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/worker"
+)
+
+// Limits of the cron jobs of a site
+const (
+	maxCronJobs     = 10
+	cronTimeout     = 60 * time.Second // How long a site has to answer a cron request
+	cronConcurrency = 8                // Cron requests sent at once
+)
+
+// cronNamePattern matches a cron job name
+var cronNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Headers of a cron request
+// The signature is the hex HMAC-SHA256 of "{timestamp}\n{method}\n{path}" keyed with the site's
+// LIGHTSPEED_CRON_KEY, so only the operator can send requests the site accepts (see lightspeed/cron.php)
+const (
+	cronJobHeader       = "X-Lightspeed-Cron"
+	cronTimestampHeader = "X-Lightspeed-Timestamp"
+	cronSignatureHeader = "X-Lightspeed-Signature"
+)
+
+// WebCron requests site URLs on schedules, a lighter alternative to a worker component for
+// periodic tasks such as cleanups, digests and feed imports. Jobs of deleted sites are pruned by the DNS sync.
+type WebCron struct {
+	handler *SitesHandler
+	path    string // JSON file jobs are persisted to (empty for in-memory only)
+	client  *http.Client
+
+	mu        sync.RWMutex
+	jobs      map[string][]models.CronJob    // By site
+	schedules map[string][]*cronSchedule     // Parsed schedules of the jobs, by site
+	results   map[string][]models.CronResult // Last run of each job, by site
+}
+
+// NewWebCron creates the cron runner, loading saved jobs from path if set
+func NewWebCron(handler *SitesHandler, path string) (*WebCron, error) {
+	c := &WebCron{
+		handler: handler,
+		path:    path,
+		client: &http.Client{
+			Timeout: cronTimeout,
+			// A redirect would be followed without the signature, so it's reported instead
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		jobs:      make(map[string][]models.CronJob),
+		schedules: make(map[string][]*cronSchedule),
+		results:   make(map[string][]models.CronResult),
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.SiteCronList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, site := range list.Sites {
+		schedules, err := validateCronJobs(site.Jobs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron jobs of %s in %s: %w", site.Site, path, err)
+		}
+		c.jobs[site.Site] = site.Jobs
+		c.schedules[site.Site] = schedules
+	}
+	log.Printf("[CRON] Loaded cron jobs of %d sites from %s", len(c.jobs), path)
+
+	return c, nil
+}
+
+// Run runs the jobs that are due at the start of every minute (blocks; run it under the worker supervisor)
+func (c *WebCron) Run() {
+	log.Printf("[CRON] Started")
+
+	for {
+		minute := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(minute))
+
+		// Jobs may take longer than a minute, which mustn't delay the next minute's jobs
+		go func() {
+			defer worker.Recover("cron run")
+			c.runDue(minute)
+		}()
+	}
+}
+
+// Get returns a site's cron jobs and the results of their last runs
+func (c *WebCron) Get(site string) models.SiteCron {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return models.SiteCron{
+		Site:    site,
+		Jobs:    append([]models.CronJob{}, c.jobs[site]...),
+		Results: append([]models.CronResult(nil), c.results[site]...),
+	}
+}
+
+// Set replaces a site's cron jobs (removing them if empty)
+// Results of jobs that are kept are kept too
+func (c *WebCron) Set(site string, jobs []models.CronJob) error {
+	schedules, err := validateCronJobs(jobs)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(jobs) == 0 {
+		delete(c.jobs, site)
+		delete(c.schedules, site)
+		delete(c.results, site)
+		return c.save()
+	}
+
+	names := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		names[job.Name] = true
+	}
+	var results []models.CronResult
+	for _, result := range c.results[site] {
+		if names[result.Name] {
+			results = append(results, result)
+		}
+	}
+	c.jobs[site] = jobs
+	c.schedules[site] = schedules
+	c.results[site] = results
+	return c.save()
+}
+
+// Prune drops the cron jobs of sites that no longer exist
+func (c *WebCron) Prune(existing map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pruned := false
+	for site := range c.jobs {
+		if !existing[site] {
+			delete(c.jobs, site)
+			delete(c.schedules, site)
+			delete(c.results, site)
+			pruned = true
+			log.Printf("[CRON] Removed the cron jobs of deleted site %s", site)
+		}
+	}
+	if !pruned {
+		return
+	}
+	if err := c.save(); err != nil {
+		log.Printf("[CRON] Failed to save cron jobs: %v", err)
+	}
+}
+
+// runDue runs the jobs whose schedule matches a minute
+func (c *WebCron) runDue(minute time.Time) {
+	due := map[string][]models.CronJob{}
+	c.mu.RLock()
+	for site, jobs := range c.jobs {
+		for i, job := range jobs {
+			if c.schedules[site][i].Matches(minute) {
+				due[site] = append(due[site], job)
+			}
+		}
+	}
+	c.mu.RUnlock()
+	if len(due) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cronTimeout+30*time.Second)
+	defer cancel()
+
+	apps, err := c.handler.doClient.ListApps(ctx)
+	if err != nil {
+		log.Printf("[CRON] Failed to list apps, skipping the jobs of %s: %v", minute.UTC().Format("15:04"), err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, cronConcurrency)
+	for i := range apps {
+		app := &apps[i]
+		// Sites still on their first deployment aren't serving yet
+		if len(due[app.Spec.Name]) == 0 || app.ActiveDeployment == nil || app.ActiveDeployment.ID == "" {
+			continue
+		}
+		for _, job := range due[app.Spec.Name] {
+			job := job
+			wg.Add(1)
+			limit <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-limit }()
+				defer worker.Recover("cron job " + job.Name + " of " + app.Spec.Name)
+				c.record(app.Spec.Name, c.runJob(ctx, app, job))
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// runJob sends a job's signed request to the site
+func (c *WebCron) runJob(ctx context.Context, app *digitalocean.App, job models.CronJob) models.CronResult {
+	start := time.Now()
+	result := models.CronResult{Name: job.Name, RanAt: start.UTC().Format(time.RFC3339)}
+
+	status, err := c.request(ctx, app.Spec.Name, "https://"+domainOf(app), job)
+	result.Status = status
+	result.DurationMs = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case status >= http.StatusBadRequest:
+		result.Error = fmt.Sprintf("%s %s returned HTTP %d", job.Method, job.Path, status)
+	case status >= http.StatusMultipleChoices:
+		result.Error = fmt.Sprintf("%s %s redirected (HTTP %d); cron requests aren't signed after a redirect", job.Method, job.Path, status)
+	}
+	if result.Error != "" {
+		log.Printf("[CRON] Job %s of %s failed: %s", job.Name, app.Spec.Name, result.Error)
+	}
+	return result
+}
+
+// request sends a job's signed request to a site and returns the response status
+func (c *WebCron) request(ctx context.Context, site, baseURL string, job models.CronJob) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, job.Method, baseURL+job.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("User-Agent", "Lightspeed-Cron/1.0")
+	req.Header.Set(cronJobHeader, job.Name)
+	req.Header.Set(cronTimestampHeader, timestamp)
+	req.Header.Set(cronSignatureHeader, cronSignature(c.handler.auth.CronKey(site), timestamp, job.Method, job.Path))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	return resp.StatusCode, nil
+}
+
+// record saves the result of a job's run, unless the job was removed while running
+func (c *WebCron) record(site string, result models.CronResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exists := false
+	for _, job := range c.jobs[site] {
+		exists = exists || job.Name == result.Name
+	}
+	if !exists {
+		return
+	}
+
+	results := c.results[site]
+	for i := range results {
+		if results[i].Name == result.Name {
+			results[i] = result
+			return
+		}
+	}
+	c.results[site] = append(results, result)
+}
+
+// cronSignature signs a cron request with a site's key
+func cronSignature(token, timestamp, method, path string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + path))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateCronJobs checks the cron jobs of a site before they're saved, filling in defaults
+// Returns the parsed schedules of the jobs
+func validateCronJobs(jobs []models.CronJob) ([]*cronSchedule, error) {
+	if len(jobs) > maxCronJobs {
+		return nil, fmt.Errorf("at most %d cron jobs per site", maxCronJobs)
+	}
+
+	names := make(map[string]bool, len(jobs))
+	schedules := make([]*cronSchedule, 0, len(jobs))
+	for i := range jobs {
+		job := &jobs[i]
+		if !cronNamePattern.MatchString(job.Name) {
+			return nil, fmt.Errorf("cron job name '%s' must be lowercase letters, digits, - and _", job.Name)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate cron job '%s'", job.Name)
+		}
+		names[job.Name] = true
+
+		job.Method = strings.ToUpper(job.Method)
+		if job.Method == "" {
+			job.Method = http.MethodGet
+		}
+		if job.Method != http.MethodGet && job.Method != http.MethodPost {
+			return nil, fmt.Errorf("cron job '%s': method must be GET or POST", job.Name)
+		}
+		if !strings.HasPrefix(job.Path, "/") {
+			return nil, fmt.Errorf("cron job '%s': path must start with /", job.Name)
+		}
+
+		schedule, err := parseCronSchedule(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("cron job '%s': %w", job.Name, err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("cron job '%s': schedule '%s' never runs", job.Name, job.Schedule)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// save writes all cron jobs to the cron file (caller must hold the lock)
+func (c *WebCron) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	list := models.SiteCronList{Sites: make([]models.SiteCron, 0, len(c.jobs))}
+	for site, jobs := range c.jobs {
+		list.Sites = append(list.Sites, models.SiteCron{Site: site, Jobs: jobs})
+	}
+	sort.Slice(list.Sites, func(i, j int) bool { return list.Sites[i].Site < list.Sites[j].Site })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// serveSiteCron routes /sites/{name}/cron requests
+func (h *SitesHandler) serveSiteCron(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if h.cron == nil {
+		h.writeError(w, "Cron jobs are not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		h.writeJSON(w, h.cron.Get(app.Spec.Name))
+	case http.MethodPut:
+		h.setSiteCron(w, r, do, name)
+	case http.MethodDelete:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		if err := h.cron.Set(app.Spec.Name, nil); err != nil {
+			h.writeError(w, "Failed to save cron jobs", err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[CRON] Removed cron jobs of %s", app.Spec.Name)
+		h.writeJSON(w, h.cron.Get(app.Spec.Name))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setSiteCron replaces a site's cron jobs
+func (h *SitesHandler) setSiteCron(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.SiteCron
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if _, err := validateCronJobs(update.Jobs); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	if err := h.cron.Set(app.Spec.Name, update.Jobs); err != nil {
+		h.writeError(w, "Failed to save cron jobs", err, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[CRON] Set %d cron jobs of %s", len(update.Jobs), app.Spec.Name)
+
+	h.writeJSON(w, h.cron.Get(app.Spec.Name))
+}
+
+// runSiteCronJob runs one of a site's cron jobs now, at POST /sites/{name}/cron/{job}
+func (h *SitesHandler) runSiteCronJob(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name, jobName string) {
+	if h.cron == nil {
+		h.writeError(w, "Cron jobs are not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	var job *models.CronJob
+	for _, j := range h.cron.Get(app.Spec.Name).Jobs {
+		if j.Name == jobName {
+			job = &j
+			break
+		}
+	}
+	if job == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no cron job '%s'", name, jobName), nil, http.StatusNotFound)
+		return
+	}
+
+	result := h.cron.runJob(r.Context(), app, *job)
+	h.cron.record(app.Spec.Name, result)
+	log.Printf("[CRON] Ran %s of %s on request (HTTP %d)", job.Name, app.Spec.Name, result.Status)
+
+	h.writeJSON(w, result)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthand schedules cron expressions may use
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a parsed cron expression: minute, hour, day of month, month and day of week
+// Each field is a bitset of the values it matches
+type cronSchedule struct {
+	minute, hour, day, month, weekday uint64
+
+	// A day of month and day of week that are both restricted match either, like cron
+	anyDay, anyWeekday bool
+}
+
+// parseCronSchedule parses a five-field cron expression (e.g. "*/15 * * * *") or a macro (e.g. "@daily")
+// Fields are *, values, ranges (1-5) and steps (*/10, 0-30/5), separated by commas; schedules are in UTC
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule '%s' must have 5 fields (minute hour day month weekday) or be a macro like @daily", expr)
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.day, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if s.weekday, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("weekday: %w", err)
+	}
+	// 7 is Sunday too
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+
+	return &s, nil
+}
+
+// parseCronField parses a field of a cron expression into a bitset of the values between min and max it matches
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+			step = n
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			low, high, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(low); err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", low)
+			}
+			if end, err = strconv.Atoi(high); err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", high)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", rangePart)
+			}
+			start = n
+			// A single value with a step runs from the value to the end (e.g. 5/15)
+			if !hasStep {
+				end = n
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("'%s' is outside %d-%d", part, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches checks if the schedule runs in the minute of t (in UTC)
+func (s *cronSchedule) Matches(t time.Time) bool {
+	t = t.UTC()
+	return s.minute&(1<<uint(t.Minute())) != 0 &&
+		s.hour&(1<<uint(t.Hour())) != 0 &&
+		s.month&(1<<uint(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+// Next returns the first minute after t the schedule runs in (zero if none within 5 years, e.g. "0 0 30 2 *")
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay checks if the schedule runs on the day of t
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	day := s.day&(1<<uint(t.Day())) != 0
+	weekday := s.weekday&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Defaults of a site's managed database
+const (
+	defaultDatabaseEngine = "pg"
+	defaultDatabaseSize   = "db-s-1vcpu-1gb"
+	maxDatabaseNodes      = 3
+)
+
+// databaseTagPrefix tags a database cluster with the site it belongs to
+const databaseTagPrefix = "lightspeed-site:"
+
+// databaseEngines are the engines a site's database can run
+var databaseEngines = map[string]bool{"pg": true, "mysql": true, "valkey": true}
+
+// databaseSizePattern matches a database node size slug (e.g. db-s-1vcpu-1gb)
+var databaseSizePattern = regexp.MustCompile(`^db-[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// databaseRegions maps App Platform regions to the datacenter databases are created in
+var databaseRegions = map[string]string{
+	"ams": "ams3", "blr": "blr1", "fra": "fra1", "lon": "lon1", "nyc": "nyc3",
+	"sfo": "sfo3", "sgp": "sgp1", "syd": "syd1", "tor": "tor1",
+}
+
+// serveSiteDatabase routes /sites/{name}/db requests
+func (h *SitesHandler) serveSiteDatabase(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getSiteDatabase(w, r, do, name)
+	case http.MethodPost:
+		h.createSiteDatabase(w, r, do, name)
+	case http.MethodDelete:
+		h.deleteSiteDatabase(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSiteDatabase returns a site's database with its connection details
+func (h *SitesHandler) getSiteDatabase(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if _, ok := h.findApp(w, r, do, name); !ok {
+		return
+	}
+
+	db, err := findSiteDatabase(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list databases", err)
+		return
+	}
+	if db == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no database", name), nil, http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, databaseResponse(db))
+}
+
+// createSiteDatabase provisions a managed database for a site and sets its connection
+// details as site variables (passwords as secrets), which redeploys the site
+// A site that already has a database gets its variables set again, so a failed update can be retried
+func (h *SitesHandler) createSiteDatabase(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	// The body is optional
+	var req models.DatabaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if err := normalizeDatabaseRequest(&req); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	db, err := findSiteDatabase(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list databases", err)
+		return
+	}
+	created := db == nil
+	if created && req.Engine == "valkey" {
+		// A cache already sets the REDIS_* variables
+		hasCache, err := h.siteHasCache(r.Context(), do, name)
+		if err != nil {
+			h.writeAPIError(w, "Failed to list caches", err)
+			return
+		}
+		if hasCache {
+			h.writeError(w, fmt.Sprintf("Site '%s' already has a cache, which sets the same variables", name), nil, http.StatusConflict)
+			return
+		}
+	}
+	if created {
+		region := databaseRegions[app.Spec.Region]
+		if region == "" {
+			region = databaseRegions[defaultRegion]
+		}
+		db, err = do.CreateDatabase(r.Context(), digitalocean.DatabaseCreate{
+			Name:     name + "-db",
+			Engine:   req.Engine,
+			Version:  req.Version,
+			Size:     req.Size,
+			Region:   region,
+			NumNodes: req.Nodes,
+			Tags:     []string{databaseTagPrefix + name},
+		})
+		if err != nil {
+			h.writeAPIError(w, "Failed to create database", err)
+			return
+		}
+		log.Printf("[AUDIT] Created %s database %s for %s (from %s)", db.Engine, db.Name, name, r.RemoteAddr)
+
+		// Only the site's app may connect; the database stays reachable if this fails
+		rules := []digitalocean.DatabaseFirewallRule{{Type: "app", Value: app.ID}}
+		if err := do.SetDatabaseFirewall(r.Context(), db.ID, rules); err != nil {
+			log.Printf("[API] Failed to restrict database %s to %s: %v", db.Name, name, err)
+		}
+	} else if db.Engine != req.Engine {
+		h.writeError(w, fmt.Sprintf("Site '%s' already has a %s database", name, db.Engine), nil, http.StatusConflict)
+		return
+	}
+
+	env := databaseEnv(db)
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Database created, but failed to get site spec", err)
+		return
+	}
+	updateSpecEnvs(spec, models.EnvUpdate{Set: env})
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Database created, but failed to set the site's variables (retry to set them)", err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	h.writeJSONStatus(w, status, databaseResponse(db))
+}
+
+// deleteSiteDatabase deletes a site's database and its data, and removes its site variables
+func (h *SitesHandler) deleteSiteDatabase(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	db, err := findSiteDatabase(r.Context(), do, name)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list databases", err)
+		return
+	}
+	if db == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no database", name), nil, http.StatusNotFound)
+		return
+	}
+
+	if err := do.DeleteDatabase(r.Context(), db.ID); err != nil {
+		h.writeAPIError(w, "Failed to delete database", err)
+		return
+	}
+	log.Printf("[AUDIT] Deleted database %s of %s (from %s)", db.Name, name, r.RemoteAddr)
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Database deleted, but failed to get site spec", err)
+		return
+	}
+	// Only the database's own variables are removed, so a cache's REDIS_* variables are kept
+	var keys []string
+	for _, env := range databaseEnv(db) {
+		keys = append(keys, env.Key)
+	}
+	updateSpecEnvs(spec, models.EnvUpdate{Unset: keys})
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Database deleted, but failed to remove the site's variables", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findSiteDatabase finds the database of a site by its tag (nil if it has none)
+func findSiteDatabase(ctx context.Context, do *digitalocean.Client, name string) (*digitalocean.Database, error) {
+	databases, err := do.ListDatabases(ctx, databaseTagPrefix+name)
+	if err != nil || len(databases) == 0 {
+		return nil, err
+	}
+	return &databases[0], nil
+}
+
+// normalizeDatabaseRequest fills in the defaults of a database request and validates it
+func normalizeDatabaseRequest(req *models.DatabaseRequest) error {
+	if req.Engine == "" {
+		req.Engine = defaultDatabaseEngine
+	}
+	if req.Size == "" {
+		req.Size = defaultDatabaseSize
+	}
+	if req.Nodes == 0 {
+		req.Nodes = 1
+	}
+
+	if !databaseEngines[req.Engine] {
+		return fmt.Errorf("engine must be pg, mysql or valkey")
+	}
+	if !databaseSizePattern.MatchString(req.Size) {
+		return fmt.Errorf("'%s' is not a database size (e.g. db-s-1vcpu-1gb)", req.Size)
+	}
+	if req.Nodes < 1 || req.Nodes > maxDatabaseNodes {
+		return fmt.Errorf("nodes must be between 1 and %d", maxDatabaseNodes)
+	}
+	return nil
+}
+
+// databaseEnv returns the site variables for a database's connection details
+// SQL databases use the same names as the pg/mysql services of lightspeed start
+func databaseEnv(db *digitalocean.Database) []models.EnvVar {
+	conn := db.Connection
+	port := strconv.Itoa(conn.Port)
+
+	if db.Engine == "valkey" || db.Engine == "redis" {
+		return []models.EnvVar{
+			{Key: "REDIS_URL", Value: conn.URI, Type: "SECRET"},
+			{Key: "REDIS_HOST", Value: conn.Host, Type: "GENERAL"},
+			{Key: "REDIS_PORT", Value: port, Type: "GENERAL"},
+			{Key: "REDIS_PASSWORD", Value: conn.Password, Type: "SECRET"},
+		}
+	}
+
+	connection := "pgsql"
+	if db.Engine == "mysql" {
+		connection = "mysql"
+	}
+	return []models.EnvVar{
+		{Key: "DATABASE_URL", Value: conn.URI, Type: "SECRET"},
+		{Key: "DB_CONNECTION", Value: connection, Type: "GENERAL"},
+		{Key: "DB_HOST", Value: conn.Host, Type: "GENERAL"},
+		{Key: "DB_PORT", Value: port, Type: "GENERAL"},
+		{Key: "DB_DATABASE", Value: conn.Database, Type: "GENERAL"},
+		{Key: "DB_USERNAME", Value: conn.User, Type: "GENERAL"},
+		{Key: "DB_PASSWORD", Value: conn.Password, Type: "SECRET"},
+	}
+}
+
+// databaseResponse converts a database cluster to its API form
+func databaseResponse(db *digitalocean.Database) models.Database {
+	response := models.Database{
+		ID:       db.ID,
+		Name:     db.Name,
+		Engine:   db.Engine,
+		Version:  db.Version,
+		Size:     db.Size,
+		Nodes:    db.NumNodes,
+		Region:   db.Region,
+		Status:   db.Status,
+		Host:     db.Connection.Host,
+		Port:     db.Connection.Port,
+		Database: db.Connection.Database,
+		User:     db.Connection.User,
+		Password: db.Connection.Password,
+		URI:      db.Connection.URI,
+	}
+	for _, env := range databaseEnv(db) {
+		response.Env = append(response.Env, env.Key)
+	}
+	if !db.CreatedAt.IsZero() {
+		response.CreatedAt = db.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
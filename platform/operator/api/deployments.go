@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+const (
+	// defaultDeploymentsLimit is how many deployments the deployments list returns by default
+	defaultDeploymentsLimit = 20
+
+	// maxDeploymentsLimit caps ?limit= of the deployments list
+	maxDeploymentsLimit = 100
+)
+
+// siteDeployments lists a site's recent deployments, newest first (?limit=N, default 20)
+// The tag and release of each come from the spec it rolled out, so they can be rolled back to
+func (h *SitesHandler) siteDeployments(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	limit := defaultDeploymentsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxDeploymentsLimit {
+			h.writeError(w, "limit must be a number from 1 to "+strconv.Itoa(maxDeploymentsLimit), nil, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	// List responses don't always include deployments, so get the full app
+	app, err := do.GetApp(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site", err)
+		return
+	}
+
+	deployments, err := do.ListDeployments(r.Context(), app.ID, limit)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list deployments", err)
+		return
+	}
+
+	list := models.SiteDeploymentList{Deployments: make([]models.SiteDeployment, 0, len(deployments))}
+	for _, d := range deployments {
+		list.Deployments = append(list.Deployments, siteDeployment(d, app.ActiveDeployment))
+	}
+	h.writeJSON(w, list)
+}
+
+// siteDeployment converts a DigitalOcean deployment for the deployments list
+func siteDeployment(d digitalocean.Deployment, active *digitalocean.Deployment) models.SiteDeployment {
+	deployment := models.SiteDeployment{
+		ID:        d.ID,
+		Phase:     d.Phase,
+		Cause:     d.Cause,
+		Active:    active != nil && active.ID == d.ID,
+		CreatedAt: d.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if d.Spec != nil {
+		deployed := digitalocean.App{Spec: *d.Spec}
+		if image := deployed.Image(); image != nil {
+			deployment.Tag = image.Tag
+		}
+		deployment.Release = deployed.Env(releaseEnv)
+	}
+
+	switch d.Phase {
+	case "ACTIVE", "ERROR", "CANCELED", "SUPERSEDED":
+		if d.UpdatedAt.After(d.CreatedAt) {
+			deployment.DurationMs = d.UpdatedAt.Sub(d.CreatedAt).Milliseconds()
+		}
+	}
+	return deployment
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+)
+
+// Disk status levels
+const (
+	diskOK       = "ok"
+	diskLow      = "low"
+	diskCritical = "critical"
+)
+
+// diskTempAge is how old a .tmp file must be before it's treated as left over by an
+// interrupted save (saves write the .tmp file and rename it right away)
+const diskTempAge = 10 * time.Minute
+
+// diskRetryAfter is the Retry-After sent with requests rejected for lack of space
+const diskRetryAfter = 60 * time.Second
+
+// DiskGuard watches the free space of the directories the operator saves its state files
+// to. Leftover temp files of interrupted saves are removed, and while space is critically
+// low, requests that change state are rejected with 503 and Retry-After instead of
+// failing halfway through a save. Registry pushes stream to the upstream registry and
+// don't use local disk, so they're never held back.
+type DiskGuard struct {
+	files    []string // State files, whose .tmp files are left over by interrupted saves
+	dirs     []string
+	low      float64 // Free percent below which space is low
+	critical float64 // Free percent below which state changes are rejected
+	interval time.Duration
+
+	mu       sync.RWMutex
+	status   models.DiskStatus
+	rejected int64
+	removed  int64
+}
+
+// NewDiskGuard creates a guard for the directories of the given state files (empty paths are in-memory)
+func NewDiskGuard(files []string, low, critical float64, interval time.Duration) *DiskGuard {
+	seen := make(map[string]bool)
+	var paths, dirs []string
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		paths = append(paths, file)
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return &DiskGuard{
+		files:    paths,
+		dirs:     dirs,
+		low:      low,
+		critical: critical,
+		interval: interval,
+		status:   models.DiskStatus{Status: diskOK, Volumes: []models.DiskVolume{}},
+	}
+}
+
+// Enabled checks if there are state file directories to watch
+func (g *DiskGuard) Enabled() bool {
+	return len(g.dirs) > 0
+}
+
+// Run checks free space periodically (blocks; run it under the worker supervisor)
+func (g *DiskGuard) Run() {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	log.Printf("[DISK] Watching %s every %v", strings.Join(g.dirs, ", "), g.interval)
+
+	g.check()
+	for range ticker.C {
+		g.check()
+	}
+}
+
+// check measures free space and removes leftover temp files
+func (g *DiskGuard) check() {
+	removed := 0
+	for _, file := range g.files {
+		if removeStaleTempFile(file+".tmp", diskTempAge) {
+			removed++
+		}
+	}
+
+	status := models.DiskStatus{
+		Status:    diskOK,
+		Volumes:   make([]models.DiskVolume, 0, len(g.dirs)),
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, dir := range g.dirs {
+		volume := models.DiskVolume{Path: dir}
+		free, total, err := diskSpace(dir)
+		if err != nil {
+			volume.Error = err.Error()
+			status.Volumes = append(status.Volumes, volume)
+			continue
+		}
+		volume.FreeBytes = free
+		volume.TotalBytes = total
+		if total > 0 {
+			volume.FreePercent = round(float64(free)/float64(total)*100, 2)
+		}
+		status.Volumes = append(status.Volumes, volume)
+
+		switch {
+		case volume.FreePercent < g.critical:
+			status.Status = diskCritical
+		case volume.FreePercent < g.low && status.Status == diskOK:
+			status.Status = diskLow
+		}
+	}
+
+	g.mu.Lock()
+	previous := g.status.Status
+	g.status = status
+	g.removed += int64(removed)
+	g.mu.Unlock()
+
+	if removed > 0 {
+		log.Printf("[DISK] Removed %d temp files left by interrupted saves", removed)
+	}
+	if status.Status != previous {
+		log.Printf("[DISK] Free space is %s (was %s)", status.Status, previous)
+	}
+}
+
+// Status returns the last measurement with the guard's counters
+func (g *DiskGuard) Status() models.DiskStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	status := g.status
+	status.Volumes = append([]models.DiskVolume{}, g.status.Volumes...)
+	status.RejectedRequests = g.rejected
+	status.TempFilesRemoved = g.removed
+	return status
+}
+
+// Guard wraps a handler, rejecting requests that change state while space is critically low
+func (g *DiskGuard) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.blocks(r) {
+			g.mu.Lock()
+			g.rejected++
+			g.mu.Unlock()
+
+			log.Printf("[DISK] Rejected %s %s: disk space critically low", r.Method, r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(diskRetryAfter.Seconds())))
+			http.Error(w, "Operator disk space is critically low; try again later", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// blocks checks if a request must be rejected for lack of space
+func (g *DiskGuard) blocks(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/v2/") || isDryRun(r) {
+		return false
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.status.Status == diskCritical
+}
+
+// removeStaleTempFile removes a temp file if it's older than age
+func removeStaleTempFile(path string, age time.Duration) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || time.Since(info.ModTime()) < age {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("[DISK] Failed to remove %s: %v", path, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package api
+
+import "errors"
+
+// diskSpace isn't supported on this platform; volumes report an error and are never low
+func diskSpace(dir string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk space not supported on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package api
+
+import "syscall"
+
+// diskSpace returns the bytes available to the operator and the size of a directory's filesystem
+func diskSpace(dir string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
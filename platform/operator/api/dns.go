@@ -1,7 +1,7 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"log"
 	"time"
 )
@@ -20,17 +20,13 @@ func NewDNSSyncWorker(handler *SitesHandler, interval time.Duration) *DNSSyncWor
 	}
 }
 
-// Start begins the DNS sync worker
-func (w *DNSSyncWorker) Start() {
+// Run syncs DNS of all sites, then of new sites periodically (blocks; run it under the worker supervisor)
+func (w *DNSSyncWorker) Run() {
 	// Sync all sites on startup
 	log.Printf("[DNS Sync] Initial sync of all sites")
 	w.syncAllDNS()
 
-	// Then start periodic sync for new sites only
-	go w.run()
-}
-
-func (w *DNSSyncWorker) run() {
+	// Then sync new sites only
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
 
@@ -43,34 +39,20 @@ func (w *DNSSyncWorker) run() {
 
 // syncAllDNS syncs DNS for all apps (used on startup)
 func (w *DNSSyncWorker) syncAllDNS() {
-	// Get all apps
-	resp, err := w.handler.doRequest("GET", "/apps", "Bearer "+w.handler.defaultToken, nil)
+	apps, err := w.handler.doClient.ListApps(context.Background())
 	if err != nil {
 		log.Printf("[DNS Sync] Failed to list apps: %v", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Apps []struct {
-			Spec struct {
-				Name string `json:"name"`
-			} `json:"spec"`
-			DefaultIngress string `json:"default_ingress"`
-		} `json:"apps"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("[DNS Sync] Failed to parse apps: %v", err)
-		return
-	}
 
 	// For each app with a default_ingress, ensure DNS exists
 	count := 0
-	for _, app := range result.Apps {
+	for i := range apps {
+		app := &apps[i]
 		if app.DefaultIngress != "" {
 			appName := app.Spec.Name
-			if err := w.handler.cfClient.EnsureCNAME(appName, app.DefaultIngress); err != nil {
+			domain := domainOf(app)
+			if err := w.handler.dnsProviderFor(domain).EnsureCNAME(domain, w.handler.cnameTarget(app)); err != nil {
 				log.Printf("[DNS Sync] Failed to sync DNS for %s: %v", appName, err)
 			} else {
 				count++
@@ -80,39 +62,41 @@ func (w *DNSSyncWorker) syncAllDNS() {
 	log.Printf("[DNS Sync] Initial sync complete (%d apps checked)", count)
 }
 
-// syncNewSitesDNS only syncs DNS for recently created apps (last 10 minutes)
+// syncNewSitesDNS only syncs DNS for recently created apps (last 10 minutes), and refreshes edge routes
 func (w *DNSSyncWorker) syncNewSitesDNS() {
-	// Get all apps
-	resp, err := w.handler.doRequest("GET", "/apps", "Bearer "+w.handler.defaultToken, nil)
+	apps, err := w.handler.doClient.ListApps(context.Background())
 	if err != nil {
 		log.Printf("[DNS Sync] Failed to list apps: %v", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Apps []struct {
-			Spec struct {
-				Name string `json:"name"`
-			} `json:"spec"`
-			DefaultIngress string    `json:"default_ingress"`
-			CreatedAt      time.Time `json:"created_at"`
-		} `json:"apps"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("[DNS Sync] Failed to parse apps: %v", err)
-		return
-	}
 
 	// Only check apps created in the last 10 minutes
 	cutoff := time.Now().Add(-10 * time.Minute)
-	for _, app := range result.Apps {
+	existing := make(map[string]bool, len(apps))
+	for i := range apps {
+		app := &apps[i]
+		existing[app.Spec.Name] = true
+		// Edge routes of every site follow changes of its ingress
+		if w.handler.edge != nil && app.DefaultIngress != "" {
+			w.handler.edge.Route(app)
+		}
 		if app.CreatedAt.After(cutoff) && app.DefaultIngress != "" {
 			appName := app.Spec.Name
-			if err := w.handler.cfClient.EnsureCNAME(appName, app.DefaultIngress); err != nil {
+			domain := domainOf(app)
+			if err := w.handler.dnsProviderFor(domain).EnsureCNAME(domain, w.handler.cnameTarget(app)); err != nil {
 				log.Printf("[DNS Sync] Failed to sync DNS for %s: %v", appName, err)
 			}
 		}
 	}
+
+	if w.handler.edge != nil {
+		w.handler.edge.Prune(existing)
+	}
+	w.handler.cache.Prune(context.Background(), existing)
+	if w.handler.queues != nil {
+		w.handler.queues.Prune(existing)
+	}
+	if w.handler.cron != nil {
+		w.handler.cron.Prune(existing)
+	}
 }
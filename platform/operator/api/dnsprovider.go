@@ -0,0 +1,45 @@
+package api
+
+import models "lightspeed/core/lib/api"
+
+// DNSProvider manages DNS records in a zone
+// Site subdomains are provisioned through a provider, either the platform's
+// lightspeed.ee zone or a tenant's own zone
+type DNSProvider interface {
+	// Zone returns the domain the provider manages
+	Zone() string
+	// Verify checks the provider can manage the zone
+	Verify() error
+	// EnsureCNAME creates or updates a CNAME record (name may be relative to the zone)
+	EnsureCNAME(name, target string) error
+	// EnsureRecord creates or updates the record of a type and name (name may be relative to the zone)
+	EnsureRecord(record models.DNSRecord) error
+	// ListRecords lists the records on domain and its subdomains
+	ListRecords(domain string) ([]models.DNSRecord, error)
+	// CreateRecord creates a record, alongside any existing records of the same type and name
+	CreateRecord(record models.DNSRecord) (*models.DNSRecord, error)
+	// DeleteRecord deletes a record by ID
+	DeleteRecord(id string) error
+}
+
+// ProxyProvider is a DNS provider that can serve hostnames through its CDN
+type ProxyProvider interface {
+	// SetProxied turns proxying of a hostname on or off, configuring TLS to the origin
+	SetProxied(hostname string, proxied bool) error
+}
+
+// CachePurger is a DNS provider whose CDN caches proxied hostnames
+type CachePurger interface {
+	// PurgeCache drops everything the CDN cached for a hostname
+	PurgeCache(hostname string) error
+}
+
+// newDNSProvider creates a provider for a zone by provider name
+// Returns nil for unsupported providers
+func newDNSProvider(provider, token, zone string) DNSProvider {
+	switch provider {
+	case "", "cloudflare":
+		return NewCloudflareZoneClient(token, zone)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	models "lightspeed/core/lib/api"
+)
+
+// secretPlaceholder replaces the values of secret environment variables in dry run specs
+const secretPlaceholder = "<secret>"
+
+// isDryRun checks if a request only asks what it would do (?dry_run=true)
+// Dry runs are validated like the request, but nothing is created, updated or deleted
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return dryRun
+}
+
+// writeDryRun answers a dry run with what the request would have done
+func (h *SitesHandler) writeDryRun(w http.ResponseWriter, r *http.Request, plan models.DryRun) {
+	plan.Current = redactSpec(plan.Current)
+	plan.Spec = redactSpec(plan.Spec)
+	log.Printf("[API] Dry run: %s %s (from %s)", plan.Action, plan.Site, r.RemoteAddr)
+	h.writeJSON(w, plan)
+}
+
+// redactSpec returns an app spec as plain JSON values, with the values of SECRET environment
+// variables replaced (new sites get their site token as one)
+// Specs are copied, so the result can be kept while the original is changed
+func redactSpec(spec interface{}) interface{} {
+	if spec == nil {
+		return nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil
+	}
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil
+	}
+	redactSecrets(plain)
+	return plain
+}
+
+// redactSecrets replaces the values of SECRET environment variables anywhere in a spec
+func redactSecrets(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v["type"] == "SECRET" {
+			if _, ok := v["value"]; ok {
+				v["value"] = secretPlaceholder
+			}
+		}
+		for _, child := range v {
+			redactSecrets(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactSecrets(child)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/internal/testsupport"
+)
+
+// fakeCloud runs the fake DigitalOcean and Cloudflare APIs and points the clients at them
+func fakeCloud(t *testing.T) (*testsupport.FakeDigitalOcean, *testsupport.FakeCloudflare) {
+	t.Helper()
+
+	do := testsupport.NewFakeDigitalOcean()
+	cf := testsupport.NewFakeCloudflare(baseDomain)
+	digitalocean.SetDefaultBaseURL(do.BaseURL())
+	SetCloudflareAPI(cf.BaseURL())
+	t.Cleanup(func() {
+		digitalocean.SetDefaultBaseURL("")
+		SetCloudflareAPI("")
+		do.Close()
+		cf.Close()
+	})
+	return do, cf
+}
+
+// newTestSites creates a sites handler against the fakes, with site tokens from an admin token
+func newTestSites(t *testing.T) *SitesHandler {
+	t.Helper()
+
+	auth, err := NewAuthHandler("", "admin-token", true)
+	if err != nil {
+		t.Fatalf("NewAuthHandler: %v", err)
+	}
+	h := NewSitesHandler("fake-token", "reg", "fake-token", "https://operator.test")
+	h.SetAuth(auth)
+	return h
+}
+
+// serve sends a request to the sites handler and decodes a JSON response into out (if not nil)
+func serve(t *testing.T, h http.Handler, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	r := httptest.NewRequest(method, path, payload)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// waitForActive polls a site like the CLI does until it has no deployment in progress
+// Returns the site and the number of polls it took
+func waitForActive(t *testing.T, h http.Handler, name string) (models.SiteResponse, int) {
+	t.Helper()
+
+	for polls := 1; polls <= 10; polls++ {
+		var site models.SiteResponse
+		if status := serve(t, h, http.MethodGet, "/sites/"+name, nil, &site); status != http.StatusOK {
+			t.Fatalf("GET /sites/%s: status %d", name, status)
+		}
+		if site.InProgress == nil {
+			return site, polls
+		}
+	}
+	t.Fatalf("deployment of %s didn't finish after 10 polls", name)
+	return models.SiteResponse{}, 0
+}
+
+func TestCreateSite(t *testing.T) {
+	do, _ := fakeCloud(t)
+	h := newTestSites(t)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+
+	site := models.Site{Name: "shop", Tag: "1.0.0", Port: 8080, Env: map[string]string{"GREETING": "hello"}}
+	var created models.SiteResponse
+	if status := serve(t, h, http.MethodPost, "/sites", site, &created); status != http.StatusCreated {
+		t.Fatalf("POST /sites: status %d, want %d", status, http.StatusCreated)
+	}
+	if created.Domain != "shop."+baseDomain {
+		t.Errorf("domain = %q, want %q", created.Domain, "shop."+baseDomain)
+	}
+
+	app := do.App("shop")
+	if app == nil {
+		t.Fatal("app shop wasn't created")
+	}
+	image := app.Image()
+	if image == nil || image.Repository != "shop" || image.Tag != "1.0.0" {
+		t.Errorf("image = %+v, want shop:1.0.0", image)
+	}
+	if image != nil && image.DeployOnPush != nil && image.DeployOnPush.Enabled {
+		t.Error("site deploys on push, want deploys through the operator")
+	}
+	if got := app.Env("GREETING"); got != "hello" {
+		t.Errorf("GREETING = %q, want hello", got)
+	}
+	if got, want := app.Env(siteTokenEnv), h.auth.SiteToken("shop"); got != want {
+		t.Errorf("%s = %q, want the site's token", siteTokenEnv, got)
+	}
+	if got := app.Env(sharedTokenEnv); got != "" {
+		t.Errorf("%s is set, want the admin token kept on the operator", sharedTokenEnv)
+	}
+
+	if status := serve(t, h, http.MethodPost, "/sites", site, nil); status != http.StatusConflict {
+		t.Errorf("creating shop again: status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestDeployPolling(t *testing.T) {
+	do, _ := fakeCloud(t)
+	do.DeployPolls = 4
+	h := newTestSites(t)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+
+	if status := serve(t, h, http.MethodPost, "/sites", models.Site{Name: "shop", Tag: "1.0.0"}, nil); status != http.StatusCreated {
+		t.Fatalf("POST /sites: status %d", status)
+	}
+	site, polls := waitForActive(t, h, "shop")
+	if polls != do.DeployPolls {
+		t.Errorf("creation took %d polls, want %d", polls, do.DeployPolls)
+	}
+	if site.Status != "ACTIVE" || site.Tag != "1.0.0" {
+		t.Errorf("site = %s %s, want ACTIVE 1.0.0", site.Status, site.Tag)
+	}
+
+	do.PushImage("reg/shop", "1.1.0", "linux/amd64")
+	if status := serve(t, h, http.MethodPost, "/sites/shop/deploy", models.DeployRequest{Tag: "1.1.0"}, nil); status >= 300 {
+		t.Fatalf("POST /sites/shop/deploy: status %d", status)
+	}
+	site, _ = waitForActive(t, h, "shop")
+	if site.Status != "ACTIVE" || site.Tag != "1.1.0" {
+		t.Errorf("site = %s %s, want ACTIVE 1.1.0", site.Status, site.Tag)
+	}
+	if site.DeploymentID == "" {
+		t.Error("site has no active deployment")
+	}
+}
+
+func TestDeployPollingFailure(t *testing.T) {
+	do, _ := fakeCloud(t)
+	h := newTestSites(t)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+	if status := serve(t, h, http.MethodPost, "/sites", models.Site{Name: "shop", Tag: "1.0.0"}, nil); status != http.StatusCreated {
+		t.Fatalf("POST /sites: status %d", status)
+	}
+	waitForActive(t, h, "shop")
+
+	// A failed redeploy leaves the site running its last deployment
+	do.FailDeployments = true
+	do.PushImage("reg/shop", "1.1.0", "linux/amd64")
+	if status := serve(t, h, http.MethodPost, "/sites/shop/deploy", models.DeployRequest{Tag: "1.1.0"}, nil); status >= 300 {
+		t.Fatalf("POST /sites/shop/deploy: status %d", status)
+	}
+	site, _ := waitForActive(t, h, "shop")
+	if site.Status != "ACTIVE" {
+		t.Errorf("status = %s, want the last deployment ACTIVE", site.Status)
+	}
+}
+
+func TestDNSSync(t *testing.T) {
+	do, cf := fakeCloud(t)
+	h := newTestSites(t)
+	app := do.AddApp(map[string]interface{}{
+		"name":    "shop",
+		"domains": []map[string]string{{"domain": "shop." + baseDomain, "type": "PRIMARY"}},
+	})
+
+	worker := NewDNSSyncWorker(h, time.Minute)
+	worker.syncAllDNS()
+
+	record := cf.Record("CNAME", "shop."+baseDomain)
+	if record == nil {
+		t.Fatalf("no CNAME record for shop.%s", baseDomain)
+	}
+	target := strings.TrimPrefix(app.DefaultIngress, "https://")
+	if record.Content != target {
+		t.Errorf("CNAME -> %s, want %s", record.Content, target)
+	}
+
+	// Syncing again leaves the record as it is
+	worker.syncAllDNS()
+	if got := len(cf.Records(baseDomain)); got != 1 {
+		t.Errorf("%d records after a second sync, want 1", got)
+	}
+	if got := cf.Count(http.MethodPost, "/client/v4/zones/"); got != 1 {
+		t.Errorf("%d records created, want 1", got)
+	}
+}
+
+func TestDNSSyncPrunesDeletedSites(t *testing.T) {
+	do, _ := fakeCloud(t)
+	h := newTestSites(t)
+	queues, err := NewJobQueues("")
+	if err != nil {
+		t.Fatalf("NewJobQueues: %v", err)
+	}
+	h.SetJobQueues(queues)
+	do.AddApp(map[string]interface{}{"name": "shop"})
+
+	for _, site := range []string{"shop", "gone"} {
+		if _, err := queues.Push(site, "emails", json.RawMessage(`{}`), 0); err != nil {
+			t.Fatalf("Push(%s): %v", site, err)
+		}
+	}
+
+	NewDNSSyncWorker(h, time.Minute).syncNewSitesDNS()
+
+	if stats := queues.Stats("shop"); len(stats) != 1 {
+		t.Errorf("shop has %d queues, want its jobs kept", len(stats))
+	}
+	if stats := queues.Stats("gone"); len(stats) != 0 {
+		t.Errorf("deleted site has %d queues, want its jobs pruned", len(stats))
+	}
+}
+
+func TestEdgeOriginLock(t *testing.T) {
+	do, cf := fakeCloud(t)
+	h := newTestSites(t)
+	edge, err := NewEdgeProxy("edge.test", "", h.auth)
+	if err != nil {
+		t.Fatalf("NewEdgeProxy: %v", err)
+	}
+	h.SetEdge(edge)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+	if status := serve(t, h, http.MethodPost, "/sites", models.Site{Name: "shop", Tag: "1.0.0"}, nil); status != http.StatusCreated {
+		t.Fatalf("POST /sites: status %d", status)
+	}
+	waitForActive(t, h, "shop")
+
+	key := h.auth.EdgeKey("shop")
+	if status := serve(t, h, http.MethodPut, "/sites/shop/edge", models.SiteEdge{Enabled: true}, nil); status != http.StatusOK {
+		t.Fatalf("PUT /sites/shop/edge: status %d", status)
+	}
+	if got := do.App("shop").Env(edgeKeyEnv); got != key {
+		t.Errorf("%s = %q, want the site's edge key", edgeKeyEnv, got)
+	}
+	if record := cf.Record("CNAME", "shop."+baseDomain); record == nil || record.Content != "edge.test" {
+		t.Errorf("CNAME = %+v, want edge.test", record)
+	}
+
+	// The edge sends the key to the ingress, whatever the client sent
+	r := httptest.NewRequest(http.MethodGet, "https://shop."+baseDomain+"/", nil)
+	r.Header.Set(edgeKeyHeader, "guess")
+	edge.routes["shop."+baseDomain].proxy.Director(r)
+	if got := r.Header.Get(edgeKeyHeader); got != key {
+		t.Errorf("%s sent to the ingress = %q, want the site's edge key", edgeKeyHeader, got)
+	}
+
+	// Deploys keep the key while the site is in edge mode
+	waitForActive(t, h, "shop")
+	do.PushImage("reg/shop", "1.1.0", "linux/amd64")
+	if status := serve(t, h, http.MethodPost, "/sites/shop/deploy", models.DeployRequest{Tag: "1.1.0"}, nil); status >= 300 {
+		t.Fatalf("POST /sites/shop/deploy: status %d", status)
+	}
+	if got := do.App("shop").Env(edgeKeyEnv); got != key {
+		t.Errorf("%s = %q after a deploy, want the site's edge key", edgeKeyEnv, got)
+	}
+
+	waitForActive(t, h, "shop")
+	if status := serve(t, h, http.MethodDelete, "/sites/shop/edge", nil, nil); status != http.StatusOK {
+		t.Fatalf("DELETE /sites/shop/edge: status %d", status)
+	}
+	if got := do.App("shop").Env(edgeKeyEnv); got != "" {
+		t.Errorf("%s is still set after leaving edge mode", edgeKeyEnv)
+	}
+}
+
+func TestEdgeAuth(t *testing.T) {
+	path := t.TempDir() + "/edge.json"
+	legacy := models.SiteEdgeList{Sites: []models.SiteEdge{{
+		Site: "shop", Enabled: true, Domain: "shop.test", Ingress: "shop.invalid",
+		AuthUser: "preview", AuthHash: hashToken("preview:secret"),
+	}}}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	edge, err := NewEdgeProxy("edge.test", path, nil)
+	if err != nil {
+		t.Fatalf("NewEdgeProxy: %v", err)
+	}
+
+	// Bad gateway means the request got past the edge's rules to the (unreachable) ingress
+	status := func(user, password string) int {
+		r := httptest.NewRequest(http.MethodGet, "https://shop.test/", nil)
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		edge.Serve(http.NotFoundHandler()).ServeHTTP(w, r)
+		return w.Code
+	}
+	if got := status("", ""); got != http.StatusUnauthorized {
+		t.Errorf("without credentials: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := status("preview", "wrong"); got != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := status("preview", "secret"); got != http.StatusBadGateway {
+		t.Errorf("legacy credentials: status %d, want %d", got, http.StatusBadGateway)
+	}
+
+	// The legacy SHA-256 is replaced with a bcrypt hash, which still matches
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list models.SiteEdgeList
+	json.Unmarshal(saved, &list)
+	if len(list.Sites) != 1 || !strings.HasPrefix(list.Sites[0].AuthHash, "$2") {
+		t.Fatalf("saved settings = %s, want a bcrypt hash", saved)
+	}
+	if got := status("preview", "secret"); got != http.StatusBadGateway {
+		t.Errorf("rehashed credentials: status %d, want %d", got, http.StatusBadGateway)
+	}
+	if got := status("preview", "wrong"); got != http.StatusUnauthorized {
+		t.Errorf("wrong password after rehash: status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestPullSecretBoundToToken(t *testing.T) {
+	auth, err := NewAuthHandler("", "admin-token", true)
+	if err != nil {
+		t.Fatalf("NewAuthHandler: %v", err)
+	}
+	secrets, err := NewPullSecretsHandler("", auth)
+	if err != nil {
+		t.Fatalf("NewPullSecretsHandler: %v", err)
+	}
+	send := func(method, path, token string, body interface{}) int {
+		data, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, path, bytes.NewReader(data))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		secrets.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	auth.mu.Lock()
+	ci, _ := auth.issue("ci")
+	auth.mu.Unlock()
+	secret := models.PullSecret{Registry: "ghcr.io", Username: "bot", Password: "read-only", Tokens: []string{"ci"}}
+	if status := send(http.MethodPost, "/pull-secrets", "admin-token", secret); status != http.StatusCreated {
+		t.Fatalf("registering the secret: status %d", status)
+	}
+	resolve := models.PullSecretRequest{Image: "ghcr.io/acme/base:1"}
+	if status := send(http.MethodPost, "/pull-secrets/resolve", ci, resolve); status != http.StatusOK {
+		t.Errorf("resolving with the registered token: status %d, want %d", status, http.StatusOK)
+	}
+
+	// A later login with the same name doesn't get the secret, and the name is now ambiguous
+	auth.mu.Lock()
+	impostor, _ := auth.issue("ci")
+	auth.mu.Unlock()
+	if status := send(http.MethodPost, "/pull-secrets/resolve", impostor, resolve); status != http.StatusForbidden {
+		t.Errorf("resolving with another token named ci: status %d, want %d", status, http.StatusForbidden)
+	}
+	if status := send(http.MethodPost, "/pull-secrets", "admin-token", secret); status != http.StatusBadRequest {
+		t.Errorf("registering for an ambiguous name: status %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestActivateShowsTokenName(t *testing.T) {
+	auth, err := NewAuthHandler("", "admin-token", true)
+	if err != nil {
+		t.Fatalf("NewAuthHandler: %v", err)
+	}
+	var device models.DeviceCode
+	if status := serve(t, auth, http.MethodPost, "/auth/device", models.DeviceCodeRequest{Name: "ci@runner"}, &device); status != http.StatusOK {
+		t.Fatalf("POST /auth/device: status %d", status)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/auth/activate?code="+device.UserCode, nil)
+	w := httptest.NewRecorder()
+	auth.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "ci@runner") {
+		t.Errorf("approval page doesn't show the token's name:\n%s", w.Body.String())
+	}
+
+	approve := func(name string) int {
+		form := "code=" + device.UserCode + "&admin_token=admin-token&name=" + name
+		r := httptest.NewRequest(http.MethodPost, "/auth/activate", strings.NewReader(form))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		auth.ServeHTTP(w, r)
+		return w.Code
+	}
+	// A code typed in without seeing the name is shown with it before it can be approved
+	if status := approve(""); status != http.StatusUnauthorized {
+		t.Errorf("approving without the name: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := approve("ci@runner"); status != http.StatusOK {
+		t.Errorf("approving with the name: status %d, want %d", status, http.StatusOK)
+	}
+	if hashes := auth.tokenHashes("ci@runner"); len(hashes) != 1 {
+		t.Errorf("%d tokens named ci@runner, want 1", len(hashes))
+	}
+}
+
+func TestCreatedResponsesAreJSON(t *testing.T) {
+	do, _ := fakeCloud(t)
+	h := newTestSites(t)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+
+	data, _ := json.Marshal(models.Site{Name: "shop", Tag: "1.0.0"})
+	r := httptest.NewRequest(http.MethodPost, "/sites", bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /sites: status %d, want %d", w.Code, http.StatusCreated)
+	}
+	// The recorder snapshots the headers when the status is written, like a real response
+	if got := w.Result().Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
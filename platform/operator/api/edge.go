@@ -0,0 +1,565 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// EdgeProxy is the optional front door of sites in edge mode. Their domains are CNAMEd to
+// the edge host instead of their App Platform ingress, and requests are proxied to the
+// ingress once the site's rules pass: IP allow and deny lists, a maintenance page and
+// basic auth, none of which App Platform can apply per site.
+//
+// The rules only hold because sites in edge mode are deployed with their edge key (see
+// AuthHandler.EdgeKey), and their server rejects requests that don't carry it, so the
+// ingress can't be used to go around the edge.
+type EdgeProxy struct {
+	host string       // Hostname edge sites' domains are CNAMEd to (edge mode is off if empty)
+	path string       // JSON file settings are persisted to (empty for in-memory only)
+	auth *AuthHandler // Derives the edge key sent to sites' ingress
+
+	mu     sync.RWMutex
+	sites  map[string]*edgeSite // By site
+	routes map[string]*edgeSite // By domain
+}
+
+// edgeKeyHeader carries the edge key (see AuthHandler.EdgeKey) to a site's ingress
+const edgeKeyHeader = "X-Lightspeed-Edge-Key"
+
+// edgeSite is a site's edge settings with its parsed IP rules
+type edgeSite struct {
+	config models.SiteEdge
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+	proxy  *httputil.ReverseProxy
+
+	// SHA-256 of the last basic auth credentials that matched, so bcrypt doesn't run on
+	// every request (in memory only)
+	verified atomic.Pointer[string]
+}
+
+// NewEdgeProxy creates an edge proxy serving sites CNAMEd to host, loading saved settings from path if set
+func NewEdgeProxy(host, path string, auth *AuthHandler) (*EdgeProxy, error) {
+	e := &EdgeProxy{
+		host:   host,
+		path:   path,
+		auth:   auth,
+		sites:  make(map[string]*edgeSite),
+		routes: make(map[string]*edgeSite),
+	}
+
+	if path == "" {
+		return e, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.SiteEdgeList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, config := range list.Sites {
+		site, err := e.newEdgeSite(config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid edge settings of %s: %w", config.Site, err)
+		}
+		e.add(site)
+	}
+	log.Printf("[EDGE] Loaded edge settings of %d sites from %s", len(e.sites), path)
+
+	return e, nil
+}
+
+// Enabled checks if edge mode is available (an edge host is configured)
+func (e *EdgeProxy) Enabled() bool {
+	return e.host != ""
+}
+
+// Host returns the hostname edge sites' domains are CNAMEd to
+func (e *EdgeProxy) Host() string {
+	return e.host
+}
+
+// Get returns a site's edge settings
+func (e *EdgeProxy) Get(site string) models.SiteEdge {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	s, ok := e.sites[site]
+	if !ok {
+		return models.SiteEdge{Site: site}
+	}
+	config := s.config
+	config.AuthHash = ""
+	return config
+}
+
+// Set replaces a site's edge settings (removing them if not enabled)
+// The site's IP rules are kept, they're changed with SetFirewall
+func (e *EdgeProxy) Set(config models.SiteEdge) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	config.Allow, config.Deny = nil, nil
+	if existing, ok := e.sites[config.Site]; ok {
+		config.Allow, config.Deny = existing.config.Allow, existing.config.Deny
+	}
+	e.remove(config.Site)
+	if config.Enabled {
+		site, err := e.newEdgeSite(config)
+		if err != nil {
+			return err
+		}
+		e.add(site)
+	}
+	return e.save()
+}
+
+// Route updates the domain and ingress of a site in edge mode, returning the target its
+// domain's CNAME should point to: the edge host, or the app's ingress if the site isn't in edge mode
+func (e *EdgeProxy) Route(app *digitalocean.App) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.sites[app.Spec.Name]
+	if !ok || e.host == "" {
+		return app.DefaultIngress
+	}
+
+	domain, ingress := domainOf(app), strings.TrimPrefix(app.DefaultIngress, "https://")
+	if s.config.Domain != domain || s.config.Ingress != ingress {
+		config := s.config
+		config.Domain, config.Ingress = domain, ingress
+		if updated, err := e.newEdgeSite(config); err == nil {
+			e.remove(app.Spec.Name)
+			e.add(updated)
+			if err := e.save(); err != nil {
+				log.Printf("[EDGE] Failed to save edge settings: %v", err)
+			}
+			log.Printf("[EDGE] %s routes to %s", domain, ingress)
+		}
+	}
+	return e.host
+}
+
+// Prune drops the edge settings of sites that no longer exist
+func (e *EdgeProxy) Prune(existing map[string]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pruned := false
+	for site := range e.sites {
+		if !existing[site] {
+			e.remove(site)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return
+	}
+	if err := e.save(); err != nil {
+		log.Printf("[EDGE] Failed to save edge settings: %v", err)
+	}
+}
+
+// Serve serves the domains of sites in edge mode, passing other requests to next
+func (e *EdgeProxy) Serve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		e.mu.RLock()
+		site := e.routes[strings.ToLower(host)]
+		e.mu.RUnlock()
+		if site == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		e.serveSite(w, r, site)
+	})
+}
+
+// serveSite applies a site's rules to a request and proxies it to the site's ingress
+func (e *EdgeProxy) serveSite(w http.ResponseWriter, r *http.Request, site *edgeSite) {
+	start := time.Now()
+	rec := &edgeResponse{ResponseWriter: w, status: http.StatusOK}
+	ip := clientIP(r)
+	rule := site.blockedBy(ip)
+
+	switch {
+	case rule != "":
+		log.Printf("[AUDIT] Edge blocked %s %s %s%s (site %s, %s)", ip, r.Method, site.config.Domain, r.URL.Path, site.config.Site, rule)
+		http.Error(rec, "Forbidden", http.StatusForbidden)
+	case site.config.Maintenance:
+		rec.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rec.Header().Set("Retry-After", "300")
+		rec.Header().Set("Cache-Control", "no-store")
+		rec.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(rec, edgeMaintenancePage)
+	case !e.authorized(site, r):
+		rec.Header().Set("WWW-Authenticate", `Basic realm="`+site.config.Domain+`", charset="UTF-8"`)
+		http.Error(rec, "Unauthorized", http.StatusUnauthorized)
+	default:
+		site.proxy.ServeHTTP(rec, r)
+	}
+
+	if site.config.Log {
+		log.Printf("[EDGE] %s %s %s %s %d %v", site.config.Site, ip, r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// newEdgeSite parses a site's edge settings, creating the proxy to its ingress
+func (e *EdgeProxy) newEdgeSite(config models.SiteEdge) (*edgeSite, error) {
+	site := &edgeSite{config: config}
+
+	var err error
+	if site.allow, err = parseIPRules(config.Allow); err != nil {
+		return nil, err
+	}
+	if site.deny, err = parseIPRules(config.Deny); err != nil {
+		return nil, err
+	}
+
+	ingress, key := config.Ingress, e.auth.EdgeKey(config.Site)
+	site.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			// App Platform routes by host, so the request is sent to the ingress's hostname
+			req.Header.Set("X-Forwarded-Host", req.Host)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.URL.Scheme = "https"
+			req.URL.Host = ingress
+			req.Host = ingress
+			// The edge key gets the request past the site's origin lock; one sent by the
+			// client is never passed on
+			if key != "" {
+				req.Header.Set(edgeKeyHeader, key)
+			} else {
+				req.Header.Del(edgeKeyHeader)
+			}
+			// Basic auth credentials are for the edge, not the site
+			if config.AuthHash != "" {
+				req.Header.Del("Authorization")
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("[EDGE] Failed to proxy %s%s to %s: %v", config.Domain, r.URL.Path, ingress, err)
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+		},
+	}
+	return site, nil
+}
+
+// authorized checks a request's basic auth credentials, if the site requires them
+func (e *EdgeProxy) authorized(site *edgeSite, r *http.Request) bool {
+	if site.config.AuthHash == "" {
+		return true
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	credentials := user + ":" + password
+	digest := hashToken(credentials)
+	if last := site.verified.Load(); last != nil && subtle.ConstantTimeCompare([]byte(*last), []byte(digest)) == 1 {
+		return true
+	}
+	if strings.HasPrefix(site.config.AuthHash, "$2") {
+		if bcrypt.CompareHashAndPassword([]byte(site.config.AuthHash), []byte(credentials)) != nil {
+			return false
+		}
+	} else {
+		// Saved as an unsalted SHA-256 before credentials were hashed with bcrypt: it's
+		// replaced with a bcrypt hash the first time they match
+		if subtle.ConstantTimeCompare([]byte(digest), []byte(site.config.AuthHash)) != 1 {
+			return false
+		}
+		e.rehashAuth(site.config.Site, site.config.AuthHash, credentials)
+	}
+	site.verified.Store(&digest)
+	return true
+}
+
+// rehashAuth replaces a site's legacy SHA-256 credentials hash with a bcrypt hash of the
+// credentials that matched it, unless its settings changed since
+func (e *EdgeProxy) rehashAuth(name, legacy, credentials string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("[EDGE] Failed to hash the basic auth credentials of %s: %v", name, err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	existing, ok := e.sites[name]
+	if !ok || existing.config.AuthHash != legacy {
+		return
+	}
+	config := existing.config
+	config.AuthHash = string(hash)
+	updated, err := e.newEdgeSite(config)
+	if err != nil {
+		return
+	}
+	e.remove(name)
+	e.add(updated)
+	if err := e.save(); err != nil {
+		log.Printf("[EDGE] Failed to save edge settings: %v", err)
+		return
+	}
+	log.Printf("[EDGE] Rehashed the basic auth credentials of %s with bcrypt", name)
+}
+
+// add indexes a site by name and domain (caller must hold the lock)
+func (e *EdgeProxy) add(site *edgeSite) {
+	e.sites[site.config.Site] = site
+	if site.config.Domain != "" && site.config.Ingress != "" {
+		e.routes[site.config.Domain] = site
+	}
+}
+
+// remove drops a site from both indexes (caller must hold the lock)
+func (e *EdgeProxy) remove(name string) {
+	site, ok := e.sites[name]
+	if !ok {
+		return
+	}
+	delete(e.sites, name)
+	if e.routes[site.config.Domain] == site {
+		delete(e.routes, site.config.Domain)
+	}
+}
+
+// save writes all edge settings to the edge file (caller must hold the lock)
+func (e *EdgeProxy) save() error {
+	if e.path == "" {
+		return nil
+	}
+
+	list := models.SiteEdgeList{Sites: make([]models.SiteEdge, 0, len(e.sites))}
+	for _, site := range e.sites {
+		list.Sites = append(list.Sites, site.config)
+	}
+	sort.Slice(list.Sites, func(i, j int) bool { return list.Sites[i].Site < list.Sites[j].Site })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.path)
+}
+
+// edgeResponse records the status of a proxied response for request logging
+type edgeResponse struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *edgeResponse) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through, so streamed responses aren't held back
+func (r *edgeResponse) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer, so protocol upgrades can hijack the connection
+func (r *edgeResponse) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// serveSiteEdge routes /sites/{name}/edge requests
+func (h *SitesHandler) serveSiteEdge(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if h.edge == nil || !h.edge.Enabled() {
+		h.writeError(w, "Edge mode is not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		h.writeJSON(w, h.edge.Get(app.Spec.Name))
+	case http.MethodPut:
+		h.setSiteEdge(w, r, do, name)
+	case http.MethodDelete:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		if err := h.edge.Set(models.SiteEdge{Site: app.Spec.Name}); err != nil {
+			h.writeError(w, "Failed to save edge settings", err, http.StatusInternalServerError)
+			return
+		}
+		if !h.pointSiteDomain(w, app) || !h.lockSiteOrigin(w, r, do, app) {
+			return
+		}
+		log.Printf("[EDGE] Turned off edge mode of %s", app.Spec.Name)
+		h.writeJSON(w, h.edge.Get(app.Spec.Name))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setSiteEdge replaces a site's edge settings and points its domain at the edge or its ingress
+func (h *SitesHandler) setSiteEdge(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.SiteEdge
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	update.AuthUser, update.AuthHash = "", ""
+	if update.Auth != "" {
+		user, password, ok := strings.Cut(update.Auth, ":")
+		if !ok || user == "" || password == "" {
+			h.writeError(w, "Auth must be user:password", nil, http.StatusBadRequest)
+			return
+		}
+		// bcrypt only uses the first 72 bytes, so longer credentials would match on a prefix
+		hash, err := bcrypt.GenerateFromPassword([]byte(update.Auth), bcrypt.DefaultCost)
+		if err != nil {
+			h.writeError(w, "Auth must be at most 72 bytes", err, http.StatusBadRequest)
+			return
+		}
+		update.AuthUser, update.AuthHash = user, string(hash)
+		update.Auth = ""
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	domain := domainOf(app)
+	if update.Enabled && !strings.HasSuffix(domain, "."+baseDomain) {
+		h.writeError(w, fmt.Sprintf("Edge mode is only available for %s subdomains (the site's domain is %s)", baseDomain, domain), nil, http.StatusBadRequest)
+		return
+	}
+
+	update.Site = app.Spec.Name
+	update.Domain = domain
+	update.Ingress = strings.TrimPrefix(app.DefaultIngress, "https://")
+	if err := h.edge.Set(update); err != nil {
+		h.writeError(w, "Failed to save edge settings", err, http.StatusInternalServerError)
+		return
+	}
+	if !h.pointSiteDomain(w, app) || !h.lockSiteOrigin(w, r, do, app) {
+		return
+	}
+	log.Printf("[EDGE] Set edge settings of %s (enabled: %v)", app.Spec.Name, update.Enabled)
+
+	h.writeJSON(w, h.edge.Get(app.Spec.Name))
+}
+
+// pointSiteDomain CNAMEs a site's domain to the edge or its ingress, after its edge mode changed
+// Sites without an ingress yet are pointed by the DNS sync once they have one
+func (h *SitesHandler) pointSiteDomain(w http.ResponseWriter, app *digitalocean.App) bool {
+	if app.DefaultIngress == "" {
+		return true
+	}
+	domain := domainOf(app)
+	if err := h.dnsProviderFor(domain).EnsureCNAME(domain, h.cnameTarget(app)); err != nil {
+		h.writeError(w, "Failed to update DNS", err, http.StatusBadGateway)
+		return false
+	}
+	return true
+}
+
+// lockSiteOrigin deploys a site with its edge key when it went into edge mode, and without it
+// when it left, which redeploys the site (it's left alone if its edge key is already right)
+func (h *SitesHandler) lockSiteOrigin(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, app *digitalocean.App) bool {
+	update := h.edgeKeyUpdate(app.Spec.Name)
+	locked := false
+	if site := app.Site(); site != nil {
+		for _, env := range site.Envs {
+			locked = locked || env.Key == edgeKeyEnv
+		}
+	}
+	if locked == (len(update.Set) > 0) {
+		return true
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Edge settings saved, but failed to get site spec (retry to update the site)", err)
+		return false
+	}
+	updateSpecEnvs(spec, update)
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Edge settings saved, but failed to update the site's edge key (retry to update it)", err)
+		return false
+	}
+	if locked {
+		log.Printf("[EDGE] Redeploying %s without its edge key", app.Spec.Name)
+	} else {
+		log.Printf("[EDGE] Redeploying %s with its edge key", app.Spec.Name)
+	}
+	return true
+}
+
+// cnameTarget returns the target of a site domain's CNAME: the edge host for sites in edge
+// mode, otherwise the app's ingress
+func (h *SitesHandler) cnameTarget(app *digitalocean.App) string {
+	if h.edge == nil {
+		return app.DefaultIngress
+	}
+	return h.edge.Route(app)
+}
+
+// edgeMaintenancePage is served to visitors of sites in maintenance mode
+const edgeMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Down for maintenance</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; color: #333; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { text-align: center; padding: 2rem; }
+h1 { font-size: 1.5rem; }
+p { color: #666; }
+</style>
+</head>
+<body>
+<main>
+<h1>Down for maintenance</h1>
+<p>This site is being updated and will be back shortly.</p>
+</main>
+</body>
+</html>
+`
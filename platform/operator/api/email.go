@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Site domains are CNAMEs to App Platform, so no other records can exist on the
+// same name. SPF is published on the provider's bounce (Return-Path / MAIL FROM)
+// subdomain instead, which is the domain SPF is checked and aligned against.
+const (
+	postmarkBounceSubdomain = "pm-bounces"
+	postmarkBounceTarget    = "pm.mtasv.net"
+	sesMailFromSubdomain    = "mail"
+	sesDefaultRegion        = "us-east-1"
+)
+
+// setupEmail provisions the SPF, DKIM and DMARC records for sending mail from a site's domain
+func (h *SitesHandler) setupEmail(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var setup models.EmailSetup
+	if err := json.NewDecoder(r.Body).Decode(&setup); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	records, err := emailRecords(domain, setup)
+	if err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	provider := h.dnsProviderFor(domain)
+	for _, record := range records {
+		if err := provider.EnsureRecord(record); err != nil {
+			h.writeError(w, fmt.Sprintf("Failed to create %s record %s", record.Type, record.Name), err, http.StatusBadGateway)
+			return
+		}
+	}
+
+	log.Printf("[API] Provisioned %s email records for %s (%d records)", setup.Provider, domain, len(records))
+	h.writeJSON(w, models.EmailSetupResponse{
+		Domain:  domain,
+		Records: records,
+	})
+}
+
+// emailRecords returns the DNS records a mail provider needs to send from domain
+func emailRecords(domain string, setup models.EmailSetup) ([]models.DNSRecord, error) {
+	var records []models.DNSRecord
+
+	switch strings.ToLower(setup.Provider) {
+	case "postmark":
+		if setup.DKIMSelector == "" || setup.DKIMKey == "" {
+			return nil, fmt.Errorf("postmark requires dkim_selector and dkim_key (from the sender signature's DNS settings)")
+		}
+		records = append(records,
+			models.DNSRecord{Type: "TXT", Name: setup.DKIMSelector + "._domainkey." + domain, Content: setup.DKIMKey},
+			models.DNSRecord{Type: "CNAME", Name: postmarkBounceSubdomain + "." + domain, Content: postmarkBounceTarget},
+		)
+	case "ses":
+		if len(setup.DKIMTokens) == 0 {
+			return nil, fmt.Errorf("ses requires dkim_tokens (from the verified identity's Easy DKIM settings)")
+		}
+		region := setup.Region
+		if region == "" {
+			region = sesDefaultRegion
+		}
+		for _, token := range setup.DKIMTokens {
+			records = append(records, models.DNSRecord{Type: "CNAME", Name: token + "._domainkey." + domain, Content: token + ".dkim.amazonses.com"})
+		}
+		mailFrom := sesMailFromSubdomain + "." + domain
+		records = append(records,
+			models.DNSRecord{Type: "MX", Name: mailFrom, Content: "feedback-smtp." + region + ".amazonses.com", Priority: 10},
+			models.DNSRecord{Type: "TXT", Name: mailFrom, Content: "v=spf1 include:amazonses.com ~all"},
+		)
+	default:
+		return nil, fmt.Errorf("unsupported email provider '%s': use postmark or ses", setup.Provider)
+	}
+
+	// Start DMARC in monitoring mode so a misconfigured sender isn't rejected outright
+	dmarc := "v=DMARC1; p=none"
+	if setup.DMARCEmail != "" {
+		if _, err := mail.ParseAddress(setup.DMARCEmail); err != nil {
+			return nil, fmt.Errorf("invalid dmarc_email: %w", err)
+		}
+		dmarc += "; rua=mailto:" + setup.DMARCEmail
+	}
+	records = append(records, models.DNSRecord{Type: "TXT", Name: "_dmarc." + domain, Content: dmarc})
+
+	return records, nil
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// envKeyPattern matches a valid environment variable name
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const (
+	// siteTokenEnv holds the site's own token for the operator (see AuthHandler.SiteToken)
+	siteTokenEnv = "LIGHTSPEED_SITE_TOKEN"
+
+	// cronKeyEnv holds the key the site's cron requests are signed with (see AuthHandler.CronKey)
+	cronKeyEnv = "LIGHTSPEED_CRON_KEY"
+
+	// edgeKeyEnv holds the key the site's server requires on every request while the site is
+	// in edge mode (see AuthHandler.EdgeKey)
+	edgeKeyEnv = "LIGHTSPEED_EDGE_KEY"
+
+	// sharedTokenEnv held the admin token in sites created before sites had their own token;
+	// it's removed on their next deploy and stays reserved
+	sharedTokenEnv = "OPERATOR_TOKEN"
+)
+
+// operatorEnv checks if an environment variable is set by the operator and can't be changed by sites
+func operatorEnv(key string) bool {
+	return key == "OPERATOR_URL" || key == sharedTokenEnv || key == siteTokenEnv || key == cronKeyEnv || key == edgeKeyEnv || key == expiresAtEnv || key == releaseEnv || key == siteNameEnv || key == labelsEnv ||
+		key == versionEnv || key == commitEnv || key == deployedAtEnv
+}
+
+// siteCredentials returns the secret variables a site is deployed with to reach the operator
+func (h *SitesHandler) siteCredentials(name string) []models.EnvVar {
+	token := h.auth.SiteToken(name)
+	if token == "" {
+		return nil
+	}
+	return []models.EnvVar{
+		{Key: siteTokenEnv, Value: token, Type: "SECRET"},
+		{Key: cronKeyEnv, Value: h.auth.CronKey(name), Type: "SECRET"},
+	}
+}
+
+// credentialsUpdate returns the env update a deploy refreshes a site's credentials with, so
+// they follow a rotated admin token, and sites created before they had their own credentials
+// drop the shared token
+func (h *SitesHandler) credentialsUpdate(name string) models.EnvUpdate {
+	update := models.EnvUpdate{Set: h.siteCredentials(name), Unset: []string{sharedTokenEnv}}
+	edge := h.edgeKeyUpdate(name)
+	update.Set = append(update.Set, edge.Set...)
+	update.Unset = append(update.Unset, edge.Unset...)
+	return update
+}
+
+// edgeKeyUpdate returns the env update that locks a site's origin to the edge proxy while
+// it's in edge mode, and unlocks it otherwise
+func (h *SitesHandler) edgeKeyUpdate(name string) models.EnvUpdate {
+	key := h.auth.EdgeKey(name)
+	if key == "" || h.edge == nil || !h.edge.Get(name).Enabled {
+		return models.EnvUpdate{Unset: []string{edgeKeyEnv}}
+	}
+	return models.EnvUpdate{Set: []models.EnvVar{{Key: edgeKeyEnv, Value: key, Type: "SECRET"}}}
+}
+
+// serveSiteEnv routes /sites/{name}/env requests
+func (h *SitesHandler) serveSiteEnv(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSiteEnv(w, r, do, name)
+	case http.MethodPost:
+		h.updateSiteEnv(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listSiteEnv lists a site's environment variables, leaving out the operator's own
+func (h *SitesHandler) listSiteEnv(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, siteEnv(app))
+}
+
+// updateSiteEnv sets and unsets environment variables in a site's app spec, which redeploys it
+func (h *SitesHandler) updateSiteEnv(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.EnvUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if err := validateEnvUpdate(&update); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	// A secret can only be replaced by another secret, so its value is never turned into plain text
+	current := map[string]string{}
+	for _, env := range siteEnv(app).Vars {
+		current[env.Key] = env.Type
+	}
+	for _, env := range update.Set {
+		if current[env.Key] == "SECRET" && env.Type != "SECRET" {
+			h.writeError(w, fmt.Sprintf("%s is a secret; change it with lightspeed secrets", env.Key), nil, http.StatusConflict)
+			return
+		}
+	}
+
+	// Secret values come back encrypted and are sent back as-is, so they survive the update
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	updateSpecEnvs(spec, update)
+	if !h.checkPolicies(w, r, name, app, spec) {
+		return
+	}
+
+	updated, err := do.UpdateApp(r.Context(), app.ID, spec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to update site", err)
+		return
+	}
+
+	keys := make([]string, 0, len(update.Set))
+	for _, env := range update.Set {
+		keys = append(keys, env.Key)
+	}
+	// Only keys are logged, since values may be secrets
+	log.Printf("[AUDIT] Env of %s: set [%s] unset [%s] (from %s)", name, strings.Join(keys, ", "), strings.Join(update.Unset, ", "), r.RemoteAddr)
+	h.writeJSON(w, siteEnv(updated))
+}
+
+// validateEnvUpdate checks keys and types, defaulting the type to GENERAL
+func validateEnvUpdate(update *models.EnvUpdate) error {
+	if len(update.Set) == 0 && len(update.Unset) == 0 {
+		return fmt.Errorf("nothing to set or unset")
+	}
+
+	for i := range update.Set {
+		env := &update.Set[i]
+		if err := validateEnvKey(env.Key); err != nil {
+			return err
+		}
+		env.Type = strings.ToUpper(env.Type)
+		if env.Type == "" {
+			env.Type = "GENERAL"
+		}
+		if env.Type != "GENERAL" && env.Type != "SECRET" {
+			return fmt.Errorf("type of %s must be GENERAL or SECRET", env.Key)
+		}
+	}
+	for _, key := range update.Unset {
+		if err := validateEnvKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateEnvKey checks that a key is a valid name not reserved by the operator
+func validateEnvKey(key string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("'%s' is not a valid environment variable name", key)
+	}
+	if operatorEnv(key) {
+		return fmt.Errorf("%s is managed by the operator", key)
+	}
+	return nil
+}
+
+// setEnvValue sets a GENERAL variable in a list of spec envs, replacing one with the same key
+func setEnvValue(envs []map[string]interface{}, key, value string) []map[string]interface{} {
+	for _, env := range envs {
+		if env["key"] == key {
+			env["value"] = value
+			env["type"] = "GENERAL"
+			return envs
+		}
+	}
+	return append(envs, map[string]interface{}{
+		"key":   key,
+		"value": value,
+		"type":  "GENERAL",
+	})
+}
+
+// siteEnv returns the environment variables of an app's site component, leaving out the operator's own
+// Secret values are left out too, even though they're encrypted
+func siteEnv(app *digitalocean.App) models.EnvList {
+	list := models.EnvList{Vars: []models.EnvVar{}}
+	site := app.Site()
+	if site == nil {
+		return list
+	}
+
+	for _, env := range site.Envs {
+		if operatorEnv(env.Key) {
+			continue
+		}
+		value := env.Value
+		if env.Type == "SECRET" {
+			value = ""
+		}
+		list.Vars = append(list.Vars, models.EnvVar{Key: env.Key, Value: value, Type: env.Type})
+	}
+	return list
+}
+
+// updateSpecEnvs applies an env update to every service and worker of a raw app spec
+// Variables that are set keep their position; new ones are appended
+func updateSpecEnvs(spec map[string]interface{}, update models.EnvUpdate) {
+	unset := map[string]bool{}
+	for _, key := range update.Unset {
+		unset[key] = true
+	}
+
+	services, _ := spec["services"].([]interface{})
+	workers, _ := spec["workers"].([]interface{})
+	for _, component := range append(services[:len(services):len(services)], workers...) {
+		fields, ok := component.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		existing, _ := fields["envs"].([]interface{})
+		envs := make([]interface{}, 0, len(existing)+len(update.Set))
+		set := map[string]bool{}
+		for _, item := range existing {
+			env, _ := item.(map[string]interface{})
+			key, _ := env["key"].(string)
+			if unset[key] {
+				continue
+			}
+			for _, change := range update.Set {
+				if change.Key == key {
+					env = envSpec(change)
+					set[key] = true
+				}
+			}
+			envs = append(envs, env)
+		}
+		for _, change := range update.Set {
+			if !set[change.Key] {
+				envs = append(envs, envSpec(change))
+			}
+		}
+		fields["envs"] = envs
+	}
+}
+
+// envSpec converts an env var to its app spec form
+func envSpec(env models.EnvVar) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   env.Key,
+		"value": env.Value,
+		"type":  env.Type,
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// maxFirewallRules limits the allow and deny rules of a site, each
+const maxFirewallRules = 100
+
+// errNotEdgeSite is returned for IP rules of a site that isn't in edge mode
+var errNotEdgeSite = errors.New("site isn't in edge mode")
+
+// Firewall returns the IP rules of a site in edge mode
+func (e *EdgeProxy) Firewall(site string) (models.SiteFirewall, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	s, ok := e.sites[site]
+	if !ok {
+		return models.SiteFirewall{}, errNotEdgeSite
+	}
+	return models.SiteFirewall{
+		Site:  site,
+		Allow: append([]string{}, s.config.Allow...),
+		Deny:  append([]string{}, s.config.Deny...),
+	}, nil
+}
+
+// SetFirewall replaces the IP rules of a site in edge mode
+func (e *EdgeProxy) SetFirewall(site string, allow, deny []string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	existing, ok := e.sites[site]
+	if !ok {
+		return errNotEdgeSite
+	}
+	config := existing.config
+	config.Allow, config.Deny = allow, deny
+	updated, err := e.newEdgeSite(config)
+	if err != nil {
+		return err
+	}
+	e.remove(site)
+	e.add(updated)
+	return e.save()
+}
+
+// blockedBy checks a client IP against the site's deny and allow lists, returning why it's
+// blocked (empty if it isn't)
+func (s *edgeSite) blockedBy(ip net.IP) string {
+	if ip == nil {
+		if len(s.allow) == 0 {
+			return ""
+		}
+		return "unknown client IP"
+	}
+	for _, n := range s.deny {
+		if n.Contains(ip) {
+			return "denied by " + n.String()
+		}
+	}
+	if len(s.allow) == 0 {
+		return ""
+	}
+	for _, n := range s.allow {
+		if n.Contains(ip) {
+			return ""
+		}
+	}
+	return "not in allow list"
+}
+
+// clientIP returns the IP a request came from
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// parseIPRules parses IPs and CIDR ranges; a single IP matches only itself
+func parseIPRules(rules []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(rules))
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if !strings.Contains(rule, "/") {
+			ip := net.ParseIP(rule)
+			if ip == nil {
+				return nil, fmt.Errorf("'%s' is not an IP or CIDR range", rule)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			rule = fmt.Sprintf("%s/%d", rule, bits)
+		}
+		_, n, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not an IP or CIDR range", rule)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// normalizeIPRules validates rules, dropping blanks and duplicates
+func normalizeIPRules(rules []string) ([]string, error) {
+	if len(rules) > maxFirewallRules {
+		return nil, fmt.Errorf("at most %d rules", maxFirewallRules)
+	}
+	normalized := make([]string, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || seen[rule] {
+			continue
+		}
+		if _, err := parseIPRules([]string{rule}); err != nil {
+			return nil, err
+		}
+		seen[rule] = true
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// serveSiteFirewall routes /sites/{name}/firewall requests
+func (h *SitesHandler) serveSiteFirewall(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if h.edge == nil || !h.edge.Enabled() {
+		h.writeError(w, "Edge mode is not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		firewall, err := h.edge.Firewall(app.Spec.Name)
+		if err != nil {
+			h.writeFirewallError(w, app.Spec.Name, err)
+			return
+		}
+		h.writeJSON(w, firewall)
+	case http.MethodPut:
+		h.setSiteFirewall(w, r, do, name)
+	case http.MethodDelete:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		if err := h.edge.SetFirewall(app.Spec.Name, nil, nil); err != nil {
+			h.writeFirewallError(w, app.Spec.Name, err)
+			return
+		}
+		log.Printf("[AUDIT] Firewall of %s: removed all rules (from %s)", app.Spec.Name, r.RemoteAddr)
+		h.writeJSON(w, models.SiteFirewall{Site: app.Spec.Name, Allow: []string{}, Deny: []string{}})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setSiteFirewall replaces the IP rules of a site in edge mode
+func (h *SitesHandler) setSiteFirewall(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.SiteFirewall
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	allow, err := normalizeIPRules(update.Allow)
+	if err != nil {
+		h.writeError(w, "Invalid allow rule", err, http.StatusBadRequest)
+		return
+	}
+	deny, err := normalizeIPRules(update.Deny)
+	if err != nil {
+		h.writeError(w, "Invalid deny rule", err, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	if err := h.edge.SetFirewall(app.Spec.Name, allow, deny); err != nil {
+		h.writeFirewallError(w, app.Spec.Name, err)
+		return
+	}
+	log.Printf("[AUDIT] Firewall of %s: allow [%s] deny [%s] (from %s)", app.Spec.Name, strings.Join(allow, ", "), strings.Join(deny, ", "), r.RemoteAddr)
+
+	firewall, _ := h.edge.Firewall(app.Spec.Name)
+	h.writeJSON(w, firewall)
+}
+
+// writeFirewallError writes the error of a firewall request
+func (h *SitesHandler) writeFirewallError(w http.ResponseWriter, site string, err error) {
+	if errors.Is(err, errNotEdgeSite) {
+		h.writeError(w, fmt.Sprintf("Site '%s' isn't in edge mode, which IP rules need (set edge=true in site.properties and deploy)", site), nil, http.StatusConflict)
+		return
+	}
+	h.writeError(w, "Failed to save IP rules", err, http.StatusInternalServerError)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/platform/operator/proxy"
+)
+
+// ImagesHandler handles /images endpoints
+type ImagesHandler struct {
+	images *proxy.RegistryProxy
+}
+
+// NewImagesHandler creates a new images handler
+func NewImagesHandler(images *proxy.RegistryProxy) *ImagesHandler {
+	return &ImagesHandler{images: images}
+}
+
+// ServeHTTP handles GET /images/{repo}/{tag} (tag defaults to latest)
+func (h *ImagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/images"), "/")
+	log.Printf("[API] %s /images/%s", r.Method, path)
+
+	repository, tag := path, "latest"
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		repository, tag = path[:i], path[i+1:]
+	}
+	if repository == "" || tag == "" {
+		h.writeError(w, "Image must be /images/{repo}/{tag}", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := h.images.InspectImage(r.Context(), repository, tag)
+	if errors.Is(err, proxy.ErrImageNotFound) {
+		h.writeError(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] Error: Failed to inspect %s:%s: %v", repository, tag, err)
+		h.writeError(w, "Failed to inspect image: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imageResponse(repository, tag, manifest))
+}
+
+// imageResponse converts a parsed manifest to an image response
+func imageResponse(repository, tag string, manifest *proxy.ImageManifest) models.Image {
+	image := models.Image{
+		Repository: repository,
+		Tag:        tag,
+		Digest:     manifest.Digest,
+		MediaType:  manifest.MediaType,
+		Platforms:  make([]models.Platform, 0, len(manifest.Platforms)),
+		Layers:     make([]models.Layer, 0, len(manifest.Layers)),
+		Size:       manifest.Size,
+		Labels:     manifest.Labels,
+	}
+	for _, p := range manifest.Platforms {
+		image.Platforms = append(image.Platforms, models.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant})
+	}
+	for _, l := range manifest.Layers {
+		image.Layers = append(image.Layers, models.Layer{Digest: l.Digest, Size: l.Size})
+	}
+	if !manifest.Created.IsZero() {
+		image.Created = manifest.Created.UTC().Format(time.RFC3339)
+	}
+	return image
+}
+
+// writeError writes a JSON error response
+func (h *ImagesHandler) writeError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/worker"
+)
+
+// incidentThreshold is how many failed checks in a row open an incident
+const incidentThreshold = 3
+
+// incidentRetention is how long resolved incidents are kept
+const incidentRetention = 90 * 24 * time.Hour
+
+// deployWindow is how long after a deployment downtime is blamed on it
+const deployWindow = 30 * time.Minute
+
+// IncidentLog records sustained downtime of sites, opened and resolved by the uptime
+// monitor, and notifies a webhook of each change
+type IncidentLog struct {
+	path    string // JSON file incidents are persisted to (empty for in-memory only)
+	webhook string // URL incident events are posted to (empty for none)
+	client  *http.Client
+
+	notifications *NotificationsHandler // Notification destinations incidents are also posted to
+
+	mu        sync.RWMutex
+	incidents []models.Incident // Oldest first
+	nextID    int
+}
+
+// NewIncidentLog creates an incident log, loading saved incidents from path if set
+func NewIncidentLog(path, webhook string) (*IncidentLog, error) {
+	l := &IncidentLog{
+		path:    path,
+		webhook: webhook,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		nextID:  1,
+	}
+
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.IncidentList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	l.incidents = list.Incidents
+	for _, incident := range l.incidents {
+		if incident.ID >= l.nextID {
+			l.nextID = incident.ID + 1
+		}
+	}
+	log.Printf("[INCIDENT] Loaded %d incidents from %s", len(l.incidents), path)
+
+	return l, nil
+}
+
+// SetNotifications sets the notification destinations incidents are posted to, besides the webhook
+func (l *IncidentLog) SetNotifications(notifications *NotificationsHandler) {
+	l.notifications = notifications
+}
+
+// ServeHTTP routes /incidents requests
+func (l *IncidentLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/incidents"), "/")
+	if path == "" {
+		query := r.URL.Query()
+		l.writeJSON(w, http.StatusOK, models.IncidentList{Incidents: l.List(query.Get("site"), query.Get("status"))})
+		return
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil {
+		l.writeJSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Incident not found"})
+		return
+	}
+	incident, ok := l.Get(id)
+	if !ok {
+		l.writeJSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Incident not found"})
+		return
+	}
+	l.writeJSON(w, http.StatusOK, incident)
+}
+
+// List returns incidents newest first, optionally only those of a site or with a status
+func (l *IncidentLog) List(site, status string) []models.Incident {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := []models.Incident{}
+	for i := len(l.incidents) - 1; i >= 0; i-- {
+		incident := l.incidents[i]
+		if (site == "" || incident.Site == site) && (status == "" || incident.Status == status) {
+			list = append(list, incident)
+		}
+	}
+	return list
+}
+
+// Get returns an incident by ID
+func (l *IncidentLog) Get(id int) (models.Incident, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, incident := range l.incidents {
+		if incident.ID == id {
+			return incident, true
+		}
+	}
+	return models.Incident{}, false
+}
+
+// Open opens an incident for a site unless one is already open
+func (l *IncidentLog) Open(incident models.Incident) {
+	l.mu.Lock()
+	for _, existing := range l.incidents {
+		if existing.Site == incident.Site && existing.Status == "open" {
+			l.mu.Unlock()
+			return
+		}
+	}
+
+	incident.ID = l.nextID
+	incident.Status = "open"
+	l.nextID++
+	l.incidents = append(l.incidents, incident)
+	l.prune()
+	err := l.save()
+	l.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[INCIDENT] Failed to save incidents: %v", err)
+	}
+	log.Printf("[INCIDENT] Opened #%d for %s: %s", incident.ID, incident.Site, incident.Cause)
+	l.notify("opened", incident)
+}
+
+// Resolve resolves the open incident of a site, if it has one
+func (l *IncidentLog) Resolve(site string, at time.Time) {
+	l.mu.Lock()
+	var resolved *models.Incident
+	for i := range l.incidents {
+		if l.incidents[i].Site == site && l.incidents[i].Status == "open" {
+			resolved = &l.incidents[i]
+			break
+		}
+	}
+	if resolved == nil {
+		l.mu.Unlock()
+		return
+	}
+
+	resolved.Status = "resolved"
+	resolved.EndedAt = at.UTC().Format(time.RFC3339)
+	incident := *resolved
+	err := l.save()
+	l.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[INCIDENT] Failed to save incidents: %v", err)
+	}
+	log.Printf("[INCIDENT] Resolved #%d for %s", incident.ID, incident.Site)
+	l.notify("resolved", incident)
+}
+
+// notify posts an incident event to the webhook in the background, and to the notification destinations
+// The payload carries a text summary, so Slack-compatible webhooks can take it as is
+func (l *IncidentLog) notify(event string, incident models.Incident) {
+	text := fmt.Sprintf("Incident #%d: %s is down (%s)", incident.ID, incident.Domain, incident.Cause)
+	if event == "resolved" {
+		text = fmt.Sprintf("Incident #%d resolved: %s is back up", incident.ID, incident.Domain)
+	}
+	if l.notifications != nil {
+		l.notifications.Notify(models.NotificationIncident, incident.Site, text)
+	}
+	if l.webhook == "" {
+		return
+	}
+
+	data, err := json.Marshal(models.IncidentEvent{Event: event, Text: text, Incident: incident})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		defer worker.Recover("incident webhook")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.webhook, bytes.NewReader(data))
+		if err != nil {
+			log.Printf("[INCIDENT] Invalid webhook: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := l.client.Do(req)
+		if err != nil {
+			log.Printf("[INCIDENT] Failed to notify webhook: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("[INCIDENT] Webhook returned %s", resp.Status)
+		}
+	}()
+}
+
+// prune drops resolved incidents past the retention (caller must hold the lock)
+func (l *IncidentLog) prune() {
+	cutoff := time.Now().Add(-incidentRetention).UTC().Format(time.RFC3339)
+	kept := l.incidents[:0]
+	for _, incident := range l.incidents {
+		if incident.Status == "resolved" && incident.EndedAt < cutoff {
+			continue
+		}
+		kept = append(kept, incident)
+	}
+	l.incidents = kept
+}
+
+// save writes all incidents to the incidents file (caller must hold the lock)
+func (l *IncidentLog) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(models.IncidentList{Incidents: l.incidents}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (l *IncidentLog) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// probableCause guesses why a site went down from its deployments
+// Downtime starting during or shortly after a deployment is blamed on it
+func probableCause(app *digitalocean.App, downSince time.Time, status int, err error) (string, string) {
+	if d := app.InProgressDeployment; d != nil && d.ID != "" {
+		return fmt.Sprintf("Deployment %s in progress", d.ID), d.ID
+	}
+	if d := app.ActiveDeployment; d != nil && d.ID != "" && !d.CreatedAt.IsZero() {
+		if downSince.After(d.CreatedAt) && downSince.Sub(d.CreatedAt) < deployWindow {
+			tag := ""
+			if image := app.Image(); image != nil {
+				tag = " of " + image.Tag
+			}
+			return fmt.Sprintf("Deployment %s%s at %s", d.ID, tag, d.CreatedAt.UTC().Format(time.RFC3339)), d.ID
+		}
+	}
+	var synthetic *syntheticError
+	if errors.As(err, &synthetic) {
+		return fmt.Sprintf("Synthetic %v", err), ""
+	}
+	if err != nil {
+		return fmt.Sprintf("Site not reachable: %v", err), ""
+	}
+	return fmt.Sprintf("Site returned HTTP %d", status), ""
+}
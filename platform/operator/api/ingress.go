@@ -0,0 +1,281 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// maxIngressRules limits the routing rules of a site
+const maxIngressRules = 25
+
+// siteComponentAlias names the site component in routing rules
+const siteComponentAlias = "site"
+
+// serveSiteIngress routes /sites/{name}/ingress requests
+func (h *SitesHandler) serveSiteIngress(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getSiteIngress(w, r, do, name)
+	case http.MethodPut:
+		h.setSiteIngress(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSiteIngress returns the routing rules of a site
+func (h *SitesHandler) getSiteIngress(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	h.writeJSON(w, models.IngressRules{Rules: appIngressRules(app)})
+}
+
+// setSiteIngress replaces the routing rules of a site, which redeploys it
+func (h *SitesHandler) setSiteIngress(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var req models.IngressRules
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if err := normalizeIngress(req.Rules); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	if err := setSpecIngress(spec, req.Rules); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	updated, err := do.UpdateApp(r.Context(), app.ID, spec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to update site", err)
+		return
+	}
+
+	log.Printf("[API] Updated routing of %s (%d rule(s))", name, len(req.Rules))
+	h.writeJSON(w, models.IngressRules{Rules: appIngressRules(updated)})
+}
+
+// ingressChanged checks if applying routing rules would change a site's spec
+func (h *SitesHandler) ingressChanged(r *http.Request, do *digitalocean.Client, app *digitalocean.App, rules []models.IngressRule) (bool, error) {
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		return false, err
+	}
+	current := specIngressRules(spec)
+	if err := setSpecIngress(spec, rules); err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(current, specIngressRules(spec)), nil
+}
+
+// normalizeIngress cleans up the paths of routing rules and validates them
+func normalizeIngress(rules []models.IngressRule) error {
+	if len(rules) > maxIngressRules {
+		return fmt.Errorf("a site can have at most %d routing rules", maxIngressRules)
+	}
+
+	paths := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if !strings.HasPrefix(rule.Path, "/") || strings.ContainsAny(rule.Path, " ?#*") {
+			return fmt.Errorf("path '%s' must be a path prefix starting with /", rule.Path)
+		}
+		if rule.Path != "/" {
+			rule.Path = strings.TrimRight(rule.Path, "/")
+		}
+		if paths[rule.Path] {
+			return fmt.Errorf("path %s is routed twice", rule.Path)
+		}
+		paths[rule.Path] = true
+
+		if rule.Component == "" {
+			return fmt.Errorf("path %s has no component", rule.Path)
+		}
+		if rule.Rewrite != "" && !strings.HasPrefix(rule.Rewrite, "/") {
+			return fmt.Errorf("rewrite of %s must start with /", rule.Path)
+		}
+		if rule.Rewrite != "" && rule.PreservePathPrefix {
+			return fmt.Errorf("path %s can't both preserve its prefix and rewrite it", rule.Path)
+		}
+	}
+	return nil
+}
+
+// setSpecIngress replaces the routing rules of a raw app spec
+// Components the rules don't route keep their rules (so a site's service stays reachable),
+// and / is routed to the site component unless a rule routes it.
+// Rules are ordered most specific path first.
+func setSpecIngress(spec map[string]interface{}, rules []models.IngressRule) error {
+	site := siteSpecService(spec)
+	if site == nil {
+		return fmt.Errorf("site spec has no site component")
+	}
+	siteComponent, _ := site["name"].(string)
+	siteName, _ := spec["name"].(string)
+	components := specComponents(spec)
+
+	var result []interface{}
+	routed := map[string]bool{}
+	paths := map[string]bool{}
+	for _, rule := range rules {
+		component := rule.Component
+		switch {
+		case components[component]:
+		case component == siteComponentAlias:
+			component = siteComponent
+		case components[serviceComponent(siteName, component)]:
+			component = serviceComponent(siteName, component)
+		default:
+			return fmt.Errorf("site '%s' has no component '%s'", siteName, rule.Component)
+		}
+		rule.Component = component
+		result = append(result, ingressRuleSpec(rule))
+		routed[component] = true
+		paths[rule.Path] = true
+	}
+
+	ingress, _ := spec["ingress"].(map[string]interface{})
+	if ingress == nil {
+		ingress = map[string]interface{}{}
+		spec["ingress"] = ingress
+	}
+	existing, _ := ingress["rules"].([]interface{})
+	for _, item := range existing {
+		component, prefix := ingressRuleTarget(item)
+		if components[component] && !routed[component] && !paths[prefix] {
+			result = append(result, item)
+			paths[prefix] = true
+		}
+	}
+	if !paths["/"] {
+		result = append(result, ingressRuleSpec(models.IngressRule{Path: "/", Component: siteComponent}))
+	}
+
+	sortIngressRules(result)
+	ingress["rules"] = result
+	return nil
+}
+
+// ingressRuleSpec returns the raw app spec form of a routing rule
+func ingressRuleSpec(rule models.IngressRule) map[string]interface{} {
+	component := map[string]interface{}{"name": rule.Component}
+	if rule.PreservePathPrefix {
+		component["preserve_path_prefix"] = true
+	}
+	if rule.Rewrite != "" {
+		component["rewrite"] = rule.Rewrite
+	}
+	return map[string]interface{}{
+		"component": component,
+		"match": map[string]interface{}{
+			"path": map[string]interface{}{"prefix": rule.Path},
+		},
+	}
+}
+
+// ingressRuleTarget returns the component and path prefix of a raw ingress rule
+func ingressRuleTarget(item interface{}) (string, string) {
+	rule, _ := item.(map[string]interface{})
+	component, _ := rule["component"].(map[string]interface{})
+	match, _ := rule["match"].(map[string]interface{})
+	path, _ := match["path"].(map[string]interface{})
+	name, _ := component["name"].(string)
+	prefix, _ := path["prefix"].(string)
+	return name, prefix
+}
+
+// sortIngressRules orders raw ingress rules most specific path first, so / goes last
+func sortIngressRules(rules []interface{}) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		_, a := ingressRuleTarget(rules[i])
+		_, b := ingressRuleTarget(rules[j])
+		return len(a) > len(b)
+	})
+}
+
+// rawSpec converts a spec built by the operator to the raw form read back from DigitalOcean
+func rawSpec(spec interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	err = json.Unmarshal(data, &raw)
+	return raw, err
+}
+
+// specComponents returns the names of the routable components of a raw app spec
+func specComponents(spec map[string]interface{}) map[string]bool {
+	components := map[string]bool{}
+	for _, kind := range []string{"services", "static_sites", "functions"} {
+		items, _ := spec[kind].([]interface{})
+		for _, item := range items {
+			fields, _ := item.(map[string]interface{})
+			if name, _ := fields["name"].(string); name != "" {
+				components[name] = true
+			}
+		}
+	}
+	return components
+}
+
+// specIngressRules returns the routing rules of a raw app spec
+func specIngressRules(spec map[string]interface{}) []models.IngressRule {
+	ingress, _ := spec["ingress"].(map[string]interface{})
+	items, _ := ingress["rules"].([]interface{})
+	var rules []models.IngressRule
+	for _, item := range items {
+		name, prefix := ingressRuleTarget(item)
+		rule := models.IngressRule{Path: prefix, Component: name}
+		fields, _ := item.(map[string]interface{})
+		component, _ := fields["component"].(map[string]interface{})
+		rule.PreservePathPrefix, _ = component["preserve_path_prefix"].(bool)
+		rule.Rewrite, _ = component["rewrite"].(string)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// appIngressRules returns the routing rules of an app
+// An app without rules routes everything to its site component
+func appIngressRules(app *digitalocean.App) []models.IngressRule {
+	rules := []models.IngressRule{}
+	if app.Spec.Ingress != nil {
+		for _, rule := range app.Spec.Ingress.Rules {
+			rules = append(rules, models.IngressRule{
+				Path:               rule.Match.Path.Prefix,
+				Component:          rule.Component.Name,
+				PreservePathPrefix: rule.Component.PreservePathPrefix,
+				Rewrite:            rule.Component.Rewrite,
+			})
+		}
+	}
+	if len(rules) == 0 {
+		if site := app.Site(); site != nil {
+			rules = append(rules, models.IngressRule{Path: "/", Component: site.Name})
+		}
+	}
+	return rules
+}
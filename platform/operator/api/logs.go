@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lightspeed/core/lib/digitalocean"
+)
+
+// logTypes maps the log types accepted by /sites/{name}/logs to DigitalOcean log types
+var logTypes = map[string]string{
+	"build":  digitalocean.LogTypeBuild,
+	"deploy": digitalocean.LogTypeDeploy,
+	"run":    digitalocean.LogTypeRun,
+}
+
+// siteLogs streams a site's logs as plain text
+// Query parameters: type (build, deploy or run; default run), tail (last N lines of
+// the existing logs; default all) and follow (keep streaming new lines)
+func (h *SitesHandler) siteLogs(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	query := r.URL.Query()
+
+	logType, ok := logTypes[strings.ToLower(query.Get("type"))]
+	if query.Get("type") == "" {
+		logType, ok = digitalocean.LogTypeRun, true
+	}
+	if !ok {
+		h.writeError(w, "type must be build, deploy or run", nil, http.StatusBadRequest)
+		return
+	}
+
+	tail := 0
+	if value := query.Get("tail"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			h.writeError(w, "tail must be a positive number", nil, http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+	follow, _ := strconv.ParseBool(query.Get("follow"))
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	// List responses don't always include deployments, so get the full app
+	app, err := do.GetApp(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site", err)
+		return
+	}
+
+	deployment := logDeployment(app, logType)
+	// A failed deployment is neither in progress nor active, so build and deploy logs come from
+	// the newest deployment once none is in progress
+	if logType != digitalocean.LogTypeRun && app.InProgressDeployment == nil && app.PendingDeployment == nil {
+		if recent, err := do.ListDeployments(r.Context(), app.ID, 1); err == nil && len(recent) > 0 {
+			deployment = &recent[0]
+		}
+	}
+	if deployment == nil {
+		h.writeError(w, "Site has no deployments", nil, http.StatusNotFound)
+		return
+	}
+
+	logs, err := do.GetLogs(r.Context(), app.ID, deployment.ID, logType, false)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get logs", err)
+		return
+	}
+
+	log.Printf("[API] Streaming %s logs of %s (deployment %s)", strings.ToLower(logType), name, deployment.ID)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	out := newFlushWriter(w)
+	if err := writeHistoricLogs(r.Context(), out, logs.HistoricURLs, tail); err != nil {
+		log.Printf("[API] Error: Failed to read logs of %s: %v", name, err)
+		return
+	}
+
+	if !follow {
+		return
+	}
+
+	live, err := do.GetLogs(r.Context(), app.ID, deployment.ID, logType, true)
+	if err != nil || live.LiveURL == "" {
+		log.Printf("[API] Error: Failed to follow logs of %s: %v", name, err)
+		return
+	}
+
+	body, err := digitalocean.OpenLogs(r.Context(), live.LiveURL)
+	if err != nil {
+		log.Printf("[API] Error: Failed to follow logs of %s: %v", name, err)
+		return
+	}
+	defer body.Close()
+
+	// Runs until the log stream ends or the client disconnects
+	io.Copy(out, body)
+}
+
+// logDeployment picks the deployment to read logs from
+// Build and deploy logs come from the deployment in progress (or the active one), run logs from
+// the one serving traffic
+func logDeployment(app *digitalocean.App, logType string) *digitalocean.Deployment {
+	candidates := []*digitalocean.Deployment{app.InProgressDeployment, app.PendingDeployment, app.ActiveDeployment}
+	if logType == digitalocean.LogTypeRun {
+		candidates = []*digitalocean.Deployment{app.ActiveDeployment, app.InProgressDeployment}
+	}
+
+	for _, deployment := range candidates {
+		if deployment != nil && deployment.ID != "" {
+			return deployment
+		}
+	}
+	return nil
+}
+
+// writeHistoricLogs writes the existing logs, keeping only the last tail lines if tail is set
+func writeHistoricLogs(ctx context.Context, out io.Writer, urls []string, tail int) error {
+	var lines []string
+	for _, url := range urls {
+		body, err := digitalocean.OpenLogs(ctx, url)
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if tail == 0 {
+				io.WriteString(out, scanner.Text()+"\n")
+				continue
+			}
+			lines = append(lines, scanner.Text())
+			if len(lines) > tail {
+				lines = lines[1:]
+			}
+		}
+		body.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lines {
+		io.WriteString(out, line+"\n")
+	}
+	return nil
+}
+
+// flushWriter flushes every write so streamed logs reach the client immediately
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// newFlushWriter wraps a response writer, flushing after each write if it supports it
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	flusher, _ := w.(http.Flusher)
+	return &flushWriter{w: w, flusher: flusher}
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	return n, err
+}
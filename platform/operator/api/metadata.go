@@ -0,0 +1,44 @@
+package api
+
+import (
+	"time"
+
+	models "lightspeed/core/lib/api"
+)
+
+// Deploy metadata the operator records in a site's environment on every spec update, so the
+// running site can tell what it runs (the PHP library serves them at /__lightspeed)
+const (
+	// versionEnv holds the image tag the site was deployed with
+	versionEnv = "LIGHTSPEED_VERSION"
+
+	// commitEnv holds the git commit the image was built from, when the deployer sent one
+	commitEnv = "LIGHTSPEED_COMMIT"
+
+	// deployedAtEnv holds when the site was deployed (RFC 3339, UTC)
+	deployedAtEnv = "LIGHTSPEED_DEPLOYED_AT"
+)
+
+// deployMetadata returns the env update recording a deploy of a tag
+// Without a commit the previous one is unset, as it no longer describes the image
+func deployMetadata(tag, commit string, now time.Time) models.EnvUpdate {
+	update := models.EnvUpdate{Set: []models.EnvVar{
+		{Key: versionEnv, Value: tag, Type: "GENERAL"},
+		{Key: deployedAtEnv, Value: now.UTC().Format(time.RFC3339), Type: "GENERAL"},
+	}}
+	if commit != "" {
+		update.Set = append(update.Set, models.EnvVar{Key: commitEnv, Value: commit, Type: "GENERAL"})
+	} else {
+		update.Unset = []string{commitEnv}
+	}
+	return update
+}
+
+// metadataEnvs returns the deploy metadata of a new site in its app spec form
+func metadataEnvs(tag, commit string, now time.Time) []map[string]interface{} {
+	var envs []map[string]interface{}
+	for _, env := range deployMetadata(tag, commit, now).Set {
+		envs = append(envs, envSpec(env))
+	}
+	return envs
+}
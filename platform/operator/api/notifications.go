@@ -0,0 +1,551 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Time zones of quiet hours, on hosts without zoneinfo
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/platform/operator/worker"
+)
+
+const (
+	// defaultDigestAt is when daily digests are sent unless a destination sets a time
+	defaultDigestAt = "09:00"
+
+	// maxHeldNotifications caps the events held for a destination; the oldest are dropped
+	maxHeldNotifications = 500
+
+	// summaryLines is how many events a summary lists in its text (all are in its events)
+	summaryLines = 20
+)
+
+var (
+	// destinationNamePattern matches a notification destination name
+	destinationNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+	// clockPattern matches a time of day (HH:MM, 24-hour)
+	clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):([0-5][0-9])$`)
+
+	// notificationKinds are the events destinations can get, in the order summaries count them
+	notificationKinds = []string{models.NotificationDeploy, models.NotificationPrune, models.NotificationIncident}
+)
+
+// NotificationsHandler handles /admin/notifications endpoints and posts operator events
+// (deploys, image prunes, incidents) to the destinations the admin registers
+// Events are posted as they happen, except during a destination's quiet hours or in digest
+// mode, when they're held and summed up in one message. Run sends the summaries when due.
+type NotificationsHandler struct {
+	path   string // JSON file destinations and held events are persisted to (empty for in-memory only)
+	auth   *AuthHandler
+	client *http.Client
+
+	mu           sync.Mutex
+	destinations map[string]*notificationDestination
+}
+
+// notificationDestination is a destination with the events held for its next summary
+type notificationDestination struct {
+	models.NotificationDestination
+	location *time.Location
+	held     []models.Notification
+	digested string // Local date of the last digest (YYYY-MM-DD)
+}
+
+// notificationsState is the saved form of the destinations
+type notificationsState struct {
+	Destinations []models.NotificationDestination `json:"destinations"`
+	Held         map[string][]models.Notification `json:"held,omitempty"`
+	Digested     map[string]string                `json:"digested,omitempty"`
+}
+
+// NewNotificationsHandler creates a notifications handler, loading saved destinations from path if set
+func NewNotificationsHandler(path string, auth *AuthHandler) (*NotificationsHandler, error) {
+	h := &NotificationsHandler{
+		path:         path,
+		auth:         auth,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		destinations: make(map[string]*notificationDestination),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state notificationsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, d := range state.Destinations {
+		if err := validateDestination(&d); err != nil {
+			return nil, fmt.Errorf("%s: destination %s: %w", path, d.Name, err)
+		}
+		location, _ := time.LoadLocation(d.Timezone)
+		h.destinations[d.Name] = &notificationDestination{
+			NotificationDestination: d,
+			location:                location,
+			held:                    state.Held[d.Name],
+			digested:                state.Digested[d.Name],
+		}
+	}
+	log.Printf("[API] Loaded %d notification destinations from %s", len(h.destinations), path)
+
+	return h, nil
+}
+
+// ServeHTTP routes requests to appropriate handlers
+func (h *NotificationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/notifications"), "/")
+
+	log.Printf("[API] %s /admin/notifications/%s", r.Method, name)
+
+	switch {
+	case !h.auth.Admin(r):
+		h.writeError(w, "Admin token required", http.StatusUnauthorized)
+	case name == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, models.NotificationDestinationList{Destinations: h.list()})
+	case name == "" && r.Method == http.MethodPost:
+		h.saveDestination(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		h.getDestination(w, name)
+	case name != "" && r.Method == http.MethodDelete:
+		h.deleteDestination(w, r, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns all destinations sorted by name
+func (h *NotificationsHandler) list() []models.NotificationDestination {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	list := make([]models.NotificationDestination, 0, len(h.destinations))
+	for _, d := range h.destinations {
+		list = append(list, d.response())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// getDestination returns a destination by name
+func (h *NotificationsHandler) getDestination(w http.ResponseWriter, name string) {
+	h.mu.Lock()
+	d, ok := h.destinations[name]
+	var response models.NotificationDestination
+	if ok {
+		response = d.response()
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.writeError(w, "Notification destination not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// saveDestination creates or replaces a destination, keeping the events it holds
+func (h *NotificationsHandler) saveDestination(w http.ResponseWriter, r *http.Request) {
+	var d models.NotificationDestination
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateDestination(&d); err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	location, _ := time.LoadLocation(d.Timezone)
+
+	h.mu.Lock()
+	existing, existed := h.destinations[d.Name]
+	saved := &notificationDestination{NotificationDestination: d, location: location}
+	if existed {
+		saved.held, saved.digested = existing.held, existing.digested
+	}
+	h.destinations[d.Name] = saved
+	response := saved.response()
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save notification destinations: %v", err)
+		h.writeError(w, "Failed to save notification destination", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[AUDIT] Notification destination %s saved (from %s)", d.Name, r.RemoteAddr)
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	h.writeJSON(w, status, response)
+}
+
+// deleteDestination removes a destination and drops the events it holds
+func (h *NotificationsHandler) deleteDestination(w http.ResponseWriter, r *http.Request, name string) {
+	h.mu.Lock()
+	if _, ok := h.destinations[name]; !ok {
+		h.mu.Unlock()
+		h.writeError(w, "Notification destination not found", http.StatusNotFound)
+		return
+	}
+	delete(h.destinations, name)
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save notification destinations: %v", err)
+		h.writeError(w, "Failed to save notification destinations", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[AUDIT] Notification destination %s deleted (from %s)", name, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateDestination checks a destination's settings and normalizes them
+func validateDestination(d *models.NotificationDestination) error {
+	if !destinationNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits and hyphens")
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+
+	events := []string{}
+	for _, event := range d.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !slices.Contains(notificationKinds, event) {
+			return fmt.Errorf("events must be %s", strings.Join(notificationKinds, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	d.Events = events
+
+	if d.Timezone == "" {
+		d.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(d.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", d.Timezone)
+	}
+
+	var start, end int
+	if d.QuietHours != "" {
+		from, to, found := strings.Cut(d.QuietHours, "-")
+		start, err = parseClock(strings.TrimSpace(from))
+		if err == nil && found {
+			end, err = parseClock(strings.TrimSpace(to))
+		}
+		if err != nil || !found || start == end {
+			return fmt.Errorf("quiet_hours must be HH:MM-HH:MM, e.g. 22:00-07:00")
+		}
+		d.QuietHours = fmt.Sprintf("%s-%s", formatClock(start), formatClock(end))
+	}
+
+	switch {
+	case d.Digest && d.DigestAt == "":
+		d.DigestAt = defaultDigestAt
+	case !d.Digest && d.DigestAt != "":
+		return fmt.Errorf("digest_at needs digest")
+	}
+	if d.Digest {
+		at, err := parseClock(d.DigestAt)
+		if err != nil {
+			return fmt.Errorf("digest_at must be HH:MM")
+		}
+		// A digest due in quiet hours would wait for them to end, and then be too early for the day
+		if d.QuietHours != "" && inClockRange(at, start, end) {
+			return fmt.Errorf("digest_at can't fall in the quiet hours")
+		}
+	}
+
+	d.Held = 0
+	return nil
+}
+
+// Notify posts an event to the destinations that take it, or holds it for their next summary
+func (h *NotificationsHandler) Notify(event, site, text string) {
+	now := time.Now()
+	notification := models.Notification{Event: event, Site: site, Text: text, Time: now.UTC().Format(time.RFC3339)}
+
+	h.mu.Lock()
+	var urls []string
+	held := false
+	for _, d := range h.destinations {
+		if len(d.Events) > 0 && !slices.Contains(d.Events, event) {
+			continue
+		}
+		if !d.Digest && !d.quiet(now) {
+			urls = append(urls, d.URL)
+			continue
+		}
+		d.held = append(d.held, notification)
+		if len(d.held) > maxHeldNotifications {
+			d.held = d.held[len(d.held)-maxHeldNotifications:]
+		}
+		held = true
+	}
+	var err error
+	if held {
+		err = h.save()
+	}
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[NOTIFY] Failed to save held notifications: %v", err)
+	}
+	for _, u := range urls {
+		h.post(u, notification)
+	}
+}
+
+// notifyDeploy tells the notification destinations about a deployment the operator started
+func (h *SitesHandler) notifyDeploy(site, tag, deploymentID string) {
+	if h.notifications == nil {
+		return
+	}
+	text := fmt.Sprintf("Deploying %s at %s", site, tag)
+	if deploymentID != "" {
+		text += " (deployment " + deploymentID + ")"
+	}
+	h.notifications.Notify(models.NotificationDeploy, site, text)
+}
+
+// Run sends the summaries of held events each minute (blocks; run it under the worker supervisor)
+func (h *NotificationsHandler) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		h.flush(now)
+	}
+}
+
+// flush sends the summaries that are due: the daily digest once its time has come, and the
+// events held during quiet hours once they end
+func (h *NotificationsHandler) flush(now time.Time) {
+	type summary struct {
+		url          string
+		notification models.Notification
+	}
+
+	h.mu.Lock()
+	var summaries []summary
+	changed := false
+	for _, d := range h.destinations {
+		if d.quiet(now) {
+			continue
+		}
+		title := "During quiet hours"
+		if d.Digest {
+			local := now.In(d.location)
+			today := local.Format("2006-01-02")
+			at, _ := parseClock(d.DigestAt)
+			if d.digested == today || local.Hour()*60+local.Minute() < at {
+				continue
+			}
+			d.digested = today
+			changed = true
+			title = "Daily digest"
+		}
+		if len(d.held) == 0 {
+			continue
+		}
+		summaries = append(summaries, summary{url: d.URL, notification: summarize(title, d.held, now)})
+		d.held = nil
+		changed = true
+	}
+	var err error
+	if changed {
+		err = h.save()
+	}
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[NOTIFY] Failed to save notification destinations: %v", err)
+	}
+	for _, s := range summaries {
+		h.post(s.url, s.notification)
+	}
+}
+
+// summarize sums up held events in one notification: a count of each kind, then the events
+func summarize(title string, events []models.Notification, now time.Time) models.Notification {
+	counts := make([]string, 0, len(notificationKinds))
+	for _, kind := range notificationKinds {
+		n := 0
+		for _, event := range events {
+			if event.Event == kind {
+				n++
+			}
+		}
+		if n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s(s)", n, kind))
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s: %s", title, strings.Join(counts, ", "))
+	for i, event := range events {
+		if i == summaryLines {
+			fmt.Fprintf(&text, "\n… and %d more", len(events)-summaryLines)
+			break
+		}
+		fmt.Fprintf(&text, "\n• %s", event.Text)
+	}
+
+	return models.Notification{
+		Event:  models.NotificationDigest,
+		Text:   text.String(),
+		Time:   now.UTC().Format(time.RFC3339),
+		Events: events,
+	}
+}
+
+// post posts a notification to a destination URL in the background
+func (h *NotificationsHandler) post(destination string, notification models.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		defer worker.Recover("notification")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, destination, bytes.NewReader(data))
+		if err != nil {
+			log.Printf("[NOTIFY] Invalid destination: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := h.client.Do(req)
+		if err != nil {
+			log.Printf("[NOTIFY] Failed to post %s notification: %v", notification.Event, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("[NOTIFY] Destination returned %s", resp.Status)
+		}
+	}()
+}
+
+// quiet checks if a destination is in its quiet hours
+func (d *notificationDestination) quiet(now time.Time) bool {
+	if d.QuietHours == "" {
+		return false
+	}
+	from, to, _ := strings.Cut(d.QuietHours, "-")
+	start, _ := parseClock(from)
+	end, _ := parseClock(to)
+	local := now.In(d.location)
+	return inClockRange(local.Hour()*60+local.Minute(), start, end)
+}
+
+// response returns the destination as the API shows it
+func (d *notificationDestination) response() models.NotificationDestination {
+	response := d.NotificationDestination
+	response.Held = len(d.held)
+	return response
+}
+
+// parseClock parses a time of day (HH:MM) into minutes after midnight
+func parseClock(value string) (int, error) {
+	match := clockPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	return hours*60 + minutes, nil
+}
+
+// formatClock formats minutes after midnight as HH:MM
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// inClockRange checks if a time of day (minutes after midnight) falls in [start, end),
+// which wraps around midnight when end is before start
+func inClockRange(minute, start, end int) bool {
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// save writes the destinations and their held events to the state file (caller must hold the lock)
+func (h *NotificationsHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	state := notificationsState{
+		Destinations: make([]models.NotificationDestination, 0, len(h.destinations)),
+		Held:         map[string][]models.Notification{},
+		Digested:     map[string]string{},
+	}
+	for name, d := range h.destinations {
+		state.Destinations = append(state.Destinations, d.NotificationDestination)
+		if len(d.held) > 0 {
+			state.Held[name] = d.held
+		}
+		if d.digested != "" {
+			state.Digested[name] = d.digested
+		}
+	}
+	sort.Slice(state.Destinations, func(i, j int) bool { return state.Destinations[i].Name < state.Destinations[j].Name })
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write doesn't lose the destinations
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *NotificationsHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError writes a JSON error response
+func (h *NotificationsHandler) writeError(w http.ResponseWriter, message string, status int) {
+	h.writeJSON(w, status, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
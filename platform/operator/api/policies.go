@@ -0,0 +1,482 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Sites keep their labels in an app environment variable (key=value pairs, comma-separated),
+// so policies can check them without separate storage
+const labelsEnv = "LIGHTSPEED_LABELS"
+
+var (
+	// policyNamePattern matches a policy name
+	policyNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+	// labelKeyPattern matches a label key (e.g. team or cost-center)
+	labelKeyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+	// labelValuePattern matches a label value
+	labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{0,63}$`)
+
+	// sizeResourcesPattern reads the vCPUs and memory of an instance size slug
+	// (apps-s-1vcpu-0.5gb, apps-d-2vcpu-8gb, apps-s-1vcpu-1gb-fixed)
+	sizeResourcesPattern = regexp.MustCompile(`^apps-[a-z]+-([0-9]+)vcpu-([0-9]+(?:\.[0-9]+)?)gb(-[a-z]+)?$`)
+)
+
+// PoliciesHandler handles /admin/policies endpoints
+// The admin defines organization rules (allowed regions, largest instance size, required labels,
+// banned variables) that every site spec is checked against before a site is created or changed.
+// Violations a site already has don't block other changes, so a new policy doesn't lock existing
+// sites; only changes that add a violation are rejected.
+type PoliciesHandler struct {
+	path string // JSON file policies are persisted to (empty for in-memory only)
+	auth *AuthHandler
+
+	mu       sync.RWMutex
+	policies map[string]models.Policy
+}
+
+// NewPoliciesHandler creates a policies handler, loading saved policies from path if set
+func NewPoliciesHandler(path string, auth *AuthHandler) (*PoliciesHandler, error) {
+	h := &PoliciesHandler{
+		path:     path,
+		auth:     auth,
+		policies: make(map[string]models.Policy),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.PolicyList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, p := range list.Policies {
+		h.policies[p.Name] = p
+	}
+	log.Printf("[API] Loaded %d policies from %s", len(h.policies), path)
+
+	return h, nil
+}
+
+// ServeHTTP routes requests to appropriate handlers
+func (h *PoliciesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/policies"), "/")
+
+	log.Printf("[API] %s /admin/policies/%s", r.Method, name)
+
+	switch {
+	case !h.auth.Admin(r):
+		h.writeError(w, "Admin token required", http.StatusUnauthorized)
+	case name == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, models.PolicyList{Policies: h.list()})
+	case name == "" && r.Method == http.MethodPost:
+		h.savePolicy(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		h.getPolicy(w, name)
+	case name != "" && r.Method == http.MethodDelete:
+		h.deletePolicy(w, r, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns all policies sorted by name
+func (h *PoliciesHandler) list() []models.Policy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := make([]models.Policy, 0, len(h.policies))
+	for _, p := range h.policies {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// getPolicy returns a policy by name
+func (h *PoliciesHandler) getPolicy(w http.ResponseWriter, name string) {
+	h.mu.RLock()
+	p, ok := h.policies[name]
+	h.mu.RUnlock()
+
+	if !ok {
+		h.writeError(w, "Policy not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, p)
+}
+
+// savePolicy creates or replaces a policy
+func (h *PoliciesHandler) savePolicy(w http.ResponseWriter, r *http.Request) {
+	var p models.Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePolicy(&p); err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	_, existed := h.policies[p.Name]
+	h.policies[p.Name] = p
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save policies: %v", err)
+		h.writeError(w, "Failed to save policy", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[AUDIT] Policy %s saved (from %s)", p.Name, r.RemoteAddr)
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	h.writeJSON(w, status, p)
+}
+
+// deletePolicy removes a policy
+func (h *PoliciesHandler) deletePolicy(w http.ResponseWriter, r *http.Request, name string) {
+	h.mu.Lock()
+	if _, ok := h.policies[name]; !ok {
+		h.mu.Unlock()
+		h.writeError(w, "Policy not found", http.StatusNotFound)
+		return
+	}
+	delete(h.policies, name)
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save policies: %v", err)
+		h.writeError(w, "Failed to save policies", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[AUDIT] Policy %s deleted (from %s)", name, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validatePolicy checks a policy's rules and normalizes its lists
+func validatePolicy(p *models.Policy) error {
+	if !policyNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits and hyphens")
+	}
+	for _, list := range []*[]string{&p.Sites, &p.Regions, &p.RequiredLabels, &p.BannedEnv} {
+		for i, item := range *list {
+			(*list)[i] = strings.TrimSpace(item)
+			if (*list)[i] == "" {
+				return fmt.Errorf("lists can't have empty items")
+			}
+		}
+	}
+	for _, pattern := range append(append([]string{}, p.Sites...), p.BannedEnv...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s'", pattern)
+		}
+	}
+	for _, region := range p.Regions {
+		if !appRegions[region] {
+			return fmt.Errorf("unknown region '%s'", region)
+		}
+	}
+	if p.MaxSize != "" {
+		if _, _, ok := sizeResources(p.MaxSize); !ok {
+			return fmt.Errorf("max_size '%s' is not an instance size (e.g. apps-s-1vcpu-1gb)", p.MaxSize)
+		}
+	}
+	if p.MaxInstances < 0 || p.MaxInstances > maxInstances {
+		return fmt.Errorf("max_instances must be between 1 and %d", maxInstances)
+	}
+	for _, key := range p.RequiredLabels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("'%s' is not a label key", key)
+		}
+	}
+	if len(p.Regions) == 0 && p.MaxSize == "" && p.MaxInstances == 0 && len(p.RequiredLabels) == 0 && len(p.BannedEnv) == 0 {
+		return fmt.Errorf("a policy needs at least one of regions, max_size, max_instances, required_labels or banned_env")
+	}
+	return nil
+}
+
+// Violations checks a site's app spec against every policy that applies to the site
+// The spec can be typed or raw; messages are sorted and name the policy they break
+func (h *PoliciesHandler) Violations(site string, spec interface{}) []string {
+	raw, err := rawSpec(spec)
+	if err != nil {
+		return []string{fmt.Sprintf("spec can't be checked: %v", err)}
+	}
+
+	found := map[string]bool{}
+	for _, p := range h.list() {
+		if !matchesAny(p.Sites, site) {
+			continue
+		}
+		for _, violation := range policyViolations(p, raw) {
+			found[fmt.Sprintf("policy '%s': %s", p.Name, violation)] = true
+		}
+	}
+
+	violations := make([]string, 0, len(found))
+	for violation := range found {
+		violations = append(violations, violation)
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+// policyViolations returns how a raw app spec breaks a policy's rules
+func policyViolations(p models.Policy, spec map[string]interface{}) []string {
+	var violations []string
+
+	if region, _ := spec["region"].(string); len(p.Regions) > 0 && region != "" && !slices.Contains(p.Regions, region) {
+		violations = append(violations, fmt.Sprintf("region '%s' isn't allowed (allowed: %s)", region, strings.Join(p.Regions, ", ")))
+	}
+
+	envs := specEnvs(spec["envs"])
+	for _, component := range specComponentMaps(spec) {
+		name, _ := component["name"].(string)
+		if size, _ := component["instance_size_slug"].(string); p.MaxSize != "" && size != "" && !sizeWithin(size, p.MaxSize) {
+			violations = append(violations, fmt.Sprintf("%s's size %s is larger than %s", name, size, p.MaxSize))
+		}
+		if count, _ := component["instance_count"].(float64); p.MaxInstances > 0 && int(count) > p.MaxInstances {
+			violations = append(violations, fmt.Sprintf("%s runs %d instances, more than %d", name, int(count), p.MaxInstances))
+		}
+		envs = append(envs, specEnvs(component["envs"])...)
+	}
+
+	for _, env := range envs {
+		key, _ := env["key"].(string)
+		if operatorEnv(key) {
+			continue
+		}
+		for _, pattern := range p.BannedEnv {
+			if ok, _ := path.Match(pattern, key); ok {
+				violations = append(violations, fmt.Sprintf("variable %s isn't allowed (%s)", key, pattern))
+				break
+			}
+		}
+	}
+
+	labels := parseLabels(specSiteEnv(spec, labelsEnv))
+	for _, key := range p.RequiredLabels {
+		if labels[key] == "" {
+			violations = append(violations, fmt.Sprintf("label '%s' is required", key))
+		}
+	}
+	return violations
+}
+
+// specComponentMaps returns the services and workers of a raw app spec
+func specComponentMaps(spec map[string]interface{}) []map[string]interface{} {
+	var components []map[string]interface{}
+	for _, kind := range []string{"services", "workers"} {
+		items, _ := spec[kind].([]interface{})
+		for _, item := range items {
+			if component, ok := item.(map[string]interface{}); ok {
+				components = append(components, component)
+			}
+		}
+	}
+	return components
+}
+
+// specEnvs returns the environment variables of a raw envs list
+func specEnvs(value interface{}) []map[string]interface{} {
+	items, _ := value.([]interface{})
+	envs := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if env, ok := item.(map[string]interface{}); ok {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// specSiteEnv returns the value of a variable of the site component of a raw app spec
+func specSiteEnv(spec map[string]interface{}, key string) string {
+	site := siteSpecService(spec)
+	if site == nil {
+		return ""
+	}
+	for _, env := range specEnvs(site["envs"]) {
+		if env["key"] == key {
+			value, _ := env["value"].(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// sizeResources returns the vCPUs and memory (GB) of an instance size slug
+func sizeResources(size string) (int, float64, bool) {
+	match := sizeResourcesPattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, 0, false
+	}
+	cpus, _ := strconv.Atoi(match[1])
+	memory, _ := strconv.ParseFloat(match[2], 64)
+	return cpus, memory, true
+}
+
+// sizeWithin checks an instance size has no more vCPUs or memory than the largest allowed
+// Sizes that can't be read are never within it
+func sizeWithin(size, largest string) bool {
+	cpus, memory, ok := sizeResources(size)
+	maxCPUs, maxMemory, maxOK := sizeResources(largest)
+	return ok && maxOK && cpus <= maxCPUs && memory <= maxMemory
+}
+
+// matchesAny checks a name matches one of a list of patterns (an empty list matches everything)
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLabels checks the labels a site is created with
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("'%s' is not a label key (lowercase letters, digits, '.', '_' and '-')", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("label %s: '%s' is not a label value (letters, digits, '.', '_' and '-', up to 63)", key, value)
+		}
+	}
+	return nil
+}
+
+// formatLabels returns labels as the value of the labels variable, sorted by key
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseLabels reads the value of the labels variable (nil if empty)
+func parseLabels(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// checkPolicies rejects a site spec with 403 if it violates policies the site (nil for a new site)
+// doesn't already violate
+// Dry runs are checked too, so a plan shows the violations a change would be rejected for
+func (h *SitesHandler) checkPolicies(w http.ResponseWriter, r *http.Request, site string, app *digitalocean.App, spec interface{}) bool {
+	if h.policies == nil {
+		return true
+	}
+
+	existing := map[string]bool{}
+	if app != nil {
+		for _, violation := range h.policies.Violations(site, app.Spec) {
+			existing[violation] = true
+		}
+	}
+	var violations []string
+	for _, violation := range h.policies.Violations(site, spec) {
+		if !existing[violation] {
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	log.Printf("[API] Rejected a change to %s: %s (from %s)", site, strings.Join(violations, "; "), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:      "Site spec violates policies: " + strings.Join(violations, "; "),
+		Code:       models.ErrorCodePolicy,
+		Violations: violations,
+	})
+	return false
+}
+
+// save writes all policies to the policies file (caller must hold the lock)
+func (h *PoliciesHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	list := models.PolicyList{Policies: make([]models.Policy, 0, len(h.policies))}
+	for _, p := range h.policies {
+		list.Policies = append(list.Policies, p)
+	}
+	sort.Slice(list.Policies, func(i, j int) bool { return list.Policies[i].Name < list.Policies[j].Name })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write doesn't lose the policies
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *PoliciesHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError writes a JSON error response
+func (h *PoliciesHandler) writeError(w http.ResponseWriter, message string, status int) {
+	h.writeJSON(w, status, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/registry"
+)
+
+// PullSecretsHandler handles /pull-secrets endpoints
+// The admin registers logins for private registries that site base images come from; builds
+// ask for the login of their base image and only get it if the image's repository is one the
+// login is registered for. Registering read-only tokens keeps a leaked login from pushing.
+// Secrets are only handed to the access tokens they're registered for and the admin token,
+// even when auth isn't required, so any logged-in user can't read every password. Tokens are
+// registered by name, but the secret is bound to the token that had the name then, so a later
+// login with the same name doesn't get it.
+type PullSecretsHandler struct {
+	path string // JSON file pull secrets are persisted to (empty for in-memory only)
+	auth *AuthHandler
+
+	mu      sync.RWMutex
+	secrets map[string]models.PullSecret
+}
+
+// NewPullSecretsHandler creates a pull secrets handler, loading saved secrets from path if set
+func NewPullSecretsHandler(path string, auth *AuthHandler) (*PullSecretsHandler, error) {
+	h := &PullSecretsHandler{
+		path:    path,
+		auth:    auth,
+		secrets: make(map[string]models.PullSecret),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.PullSecretList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, s := range list.PullSecrets {
+		if len(s.Tokens) > 0 && len(s.TokenHashes) == 0 {
+			// Saved before secrets were bound to tokens: bound to the tokens named now, if unambiguous
+			if hashes, err := h.bindTokens(s.Tokens); err == nil {
+				s.TokenHashes = hashes
+			} else {
+				log.Printf("[API] Warning: pull secret for %s is only handed to the admin token until it's registered again: %v", s.Registry, err)
+			}
+		}
+		h.secrets[s.Registry] = s
+	}
+	log.Printf("[API] Loaded %d pull secrets from %s", len(h.secrets), path)
+
+	return h, nil
+}
+
+// ServeHTTP routes requests to appropriate handlers
+func (h *PullSecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/pull-secrets"), "/")
+
+	log.Printf("[API] %s /pull-secrets/%s", r.Method, path)
+
+	switch {
+	case path == "resolve" && r.Method == http.MethodPost:
+		h.resolveSecret(w, r)
+	case !h.auth.Admin(r):
+		h.writeError(w, "Admin token required", http.StatusUnauthorized)
+	case path == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, models.PullSecretList{PullSecrets: h.list()})
+	case path == "" && r.Method == http.MethodPost:
+		h.registerSecret(w, r)
+	case path != "" && r.Method == http.MethodDelete:
+		h.deleteSecret(w, path)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns all pull secrets (without passwords) sorted by registry
+func (h *PullSecretsHandler) list() []models.PullSecret {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := make([]models.PullSecret, 0, len(h.secrets))
+	for _, s := range h.secrets {
+		s.Password, s.TokenHashes = "", nil
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Registry < list[j].Registry })
+	return list
+}
+
+// For returns the pull secret for an image, if one is registered for its registry and repository
+func (h *PullSecretsHandler) For(image string) (models.PullSecret, bool) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return models.PullSecret{}, false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.secrets[registry.NormalizeHost(ref.Registry)]
+	if !ok {
+		return models.PullSecret{}, false
+	}
+	if len(s.Repositories) == 0 {
+		return s, true
+	}
+	for _, prefix := range s.Repositories {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if ref.Repository == prefix || strings.HasPrefix(ref.Repository, prefix+"/") {
+			return s, true
+		}
+	}
+	return models.PullSecret{}, false
+}
+
+// resolveSecret hands a build the pull secret of its base image, if the secret is registered
+// for the build's access token
+func (h *PullSecretsHandler) resolveSecret(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if !h.auth.valid(token) {
+		h.writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Error: "Authentication required: run 'lightspeed login'", Code: models.ErrorCodeUnauthorized})
+		return
+	}
+	caller := h.auth.describe(token)
+
+	var request models.PullSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := registry.ParseReference(request.Image); err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s, ok := h.For(request.Image)
+	if !ok {
+		h.writeError(w, "No pull secret for "+request.Image, http.StatusNotFound)
+		return
+	}
+	if !caller.Admin && !handedTo(s, hashToken(token)) {
+		log.Printf("[AUDIT] Pull secret for %s refused to %s for %s (from %s)", s.Registry, caller.Name, request.Image, r.RemoteAddr)
+		h.writeError(w, fmt.Sprintf("The pull secret for %s isn't registered for access token '%s'", s.Registry, caller.Name), http.StatusForbidden)
+		return
+	}
+
+	log.Printf("[AUDIT] Pull secret for %s handed out to %s for %s (from %s)", s.Registry, caller.Name, request.Image, r.RemoteAddr)
+	s.TokenHashes = nil
+	h.writeJSON(w, http.StatusOK, s)
+}
+
+// handedTo checks if a pull secret is bound to the access token with a hash
+func handedTo(s models.PullSecret, hash string) bool {
+	for _, bound := range s.TokenHashes {
+		if subtle.ConstantTimeCompare([]byte(bound), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// bindTokens returns the hashes of the access tokens with the names a secret is registered
+// for, failing if a name isn't the name of exactly one token
+func (h *PullSecretsHandler) bindTokens(names []string) ([]string, error) {
+	hashes := make([]string, 0, len(names))
+	for _, name := range names {
+		named := h.auth.tokenHashes(name)
+		switch len(named) {
+		case 0:
+			return nil, fmt.Errorf("no access token is named '%s'", name)
+		case 1:
+			hashes = append(hashes, named[0])
+		default:
+			return nil, fmt.Errorf("%d access tokens are named '%s'; log the others out first", len(named), name)
+		}
+	}
+	return hashes, nil
+}
+
+// registerSecret creates or replaces the pull secret of a registry
+func (h *PullSecretsHandler) registerSecret(w http.ResponseWriter, r *http.Request) {
+	var s models.PullSecret
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Registry = registry.NormalizeHost(s.Registry)
+	if s.Registry == "" || strings.ContainsAny(s.Registry, "/ ") {
+		h.writeError(w, "registry must be a registry host (e.g. ghcr.io)", http.StatusBadRequest)
+		return
+	}
+	if s.Username == "" || s.Password == "" {
+		h.writeError(w, "username and password are required", http.StatusBadRequest)
+		return
+	}
+	for i, prefix := range s.Repositories {
+		s.Repositories[i] = strings.Trim(strings.TrimSpace(prefix), "/")
+		if s.Repositories[i] == "" {
+			h.writeError(w, "repositories can't be empty", http.StatusBadRequest)
+			return
+		}
+	}
+	for i, name := range s.Tokens {
+		s.Tokens[i] = strings.TrimSpace(name)
+		if s.Tokens[i] == "" {
+			h.writeError(w, "tokens can't be empty", http.StatusBadRequest)
+			return
+		}
+	}
+	hashes, err := h.bindTokens(s.Tokens)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.TokenHashes = hashes
+
+	h.mu.Lock()
+	_, existed := h.secrets[s.Registry]
+	h.secrets[s.Registry] = s
+	err = h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save pull secrets: %v", err)
+		h.writeError(w, "Failed to save pull secret", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[AUDIT] Pull secret for %s registered (from %s)", s.Registry, r.RemoteAddr)
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	s.Password, s.TokenHashes = "", nil
+	h.writeJSON(w, status, s)
+}
+
+// deleteSecret removes the pull secret of a registry
+func (h *PullSecretsHandler) deleteSecret(w http.ResponseWriter, host string) {
+	host = registry.NormalizeHost(host)
+
+	h.mu.Lock()
+	if _, ok := h.secrets[host]; !ok {
+		h.mu.Unlock()
+		h.writeError(w, "Pull secret not found", http.StatusNotFound)
+		return
+	}
+	delete(h.secrets, host)
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save pull secrets: %v", err)
+		h.writeError(w, "Failed to save pull secrets", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Deleted pull secret for %s", host)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// save writes all pull secrets to the pull secrets file (caller must hold the lock)
+// The file holds passwords, so only the operator's user can read it
+func (h *PullSecretsHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	list := models.PullSecretList{PullSecrets: make([]models.PullSecret, 0, len(h.secrets))}
+	for _, s := range h.secrets {
+		list.PullSecrets = append(list.PullSecrets, s)
+	}
+	sort.Slice(list.PullSecrets, func(i, j int) bool { return list.PullSecrets[i].Registry < list.PullSecrets[j].Registry })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write doesn't lose the secrets
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *PullSecretsHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError writes a JSON error response
+func (h *PullSecretsHandler) writeError(w http.ResponseWriter, message string, status int) {
+	h.writeJSON(w, status, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
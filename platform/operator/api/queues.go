@@ -0,0 +1,485 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Limits of the job queues, which are meant for the background jobs of small sites
+const (
+	maxQueueJobs        = 10000     // Jobs of a site across its queues
+	maxQueuePayload     = 64 << 10  // Bytes of a job's payload
+	maxQueueDelay       = 7 * 86400 // Seconds a job can be delayed
+	defaultQueueTimeout = 60        // Seconds a popped job is reserved
+	maxQueueTimeout     = 12 * 3600
+	maxQueueAttempts    = 5 // Pops before a job that's never acknowledged fails
+)
+
+// queueNamePattern matches a queue name
+var queueNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// JobQueues is a minimal job queue for sites, so small PHP sites get background jobs without
+// running a broker. Every site has its own namespace of queues. Jobs are pushed, popped by a
+// worker (reserving them for a timeout) and acknowledged when done; a job that isn't
+// acknowledged in time is popped again, and fails after maxQueueAttempts pops.
+type JobQueues struct {
+	path string // JSON file jobs are persisted to (empty for in-memory only)
+
+	mu   sync.Mutex
+	jobs map[string][]*queuedJob // By site, oldest first
+}
+
+// queuedJob is a job with its state
+type queuedJob struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	AvailableAt time.Time       `json:"available_at"`       // Delayed or reserved until
+	Reserved    bool            `json:"reserved,omitempty"` // Popped and not acknowledged yet
+	Failed      bool            `json:"failed,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// jobQueuesFile is the persisted form of the job queues
+type jobQueuesFile struct {
+	Sites []jobQueuesSite `json:"sites"`
+}
+
+// jobQueuesSite is the persisted jobs of a site
+type jobQueuesSite struct {
+	Site string       `json:"site"`
+	Jobs []*queuedJob `json:"jobs"`
+}
+
+// NewJobQueues creates the job queues, loading saved jobs from path if set
+func NewJobQueues(path string) (*JobQueues, error) {
+	q := &JobQueues{path: path, jobs: make(map[string][]*queuedJob)}
+
+	if path == "" {
+		return q, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file jobQueuesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	count := 0
+	for _, site := range file.Sites {
+		q.jobs[site.Site] = site.Jobs
+		count += len(site.Jobs)
+	}
+	log.Printf("[QUEUE] Loaded %d jobs of %d sites from %s", count, len(q.jobs), path)
+
+	return q, nil
+}
+
+// Push adds a job to a site's queue, to be popped after delay
+func (q *JobQueues) Push(site, queue string, payload json.RawMessage, delay time.Duration) (models.QueueJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.jobs[site]) >= maxQueueJobs {
+		return models.QueueJob{}, fmt.Errorf("site '%s' has %d jobs queued, the most it can have", site, maxQueueJobs)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return models.QueueJob{}, err
+	}
+
+	now := time.Now().UTC()
+	job := &queuedJob{
+		ID:          hex.EncodeToString(id),
+		Queue:       queue,
+		Payload:     payload,
+		CreatedAt:   now,
+		AvailableAt: now.Add(delay),
+	}
+	q.jobs[site] = append(q.jobs[site], job)
+	if err := q.save(); err != nil {
+		return models.QueueJob{}, err
+	}
+	return job.model(), nil
+}
+
+// Pop reserves the oldest job of a site's queue that can be popped for timeout
+// Reserved jobs whose timeout passed are popped again, or fail after maxQueueAttempts
+// Returns false if the queue has no job ready
+func (q *JobQueues) Pop(site, queue string, timeout time.Duration) (models.QueueJob, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now().UTC()
+	changed := false
+	for _, job := range q.jobs[site] {
+		if job.Queue != queue || job.Failed || job.AvailableAt.After(now) {
+			continue
+		}
+		if job.Reserved && job.Attempts >= maxQueueAttempts {
+			job.Failed = true
+			job.Reserved = false
+			job.Error = fmt.Sprintf("not acknowledged after %d attempts", job.Attempts)
+			changed = true
+			log.Printf("[QUEUE] Job %s of %s/%s failed: %s", job.ID, site, queue, job.Error)
+			continue
+		}
+
+		job.Attempts++
+		job.Reserved = true
+		job.AvailableAt = now.Add(timeout)
+		if err := q.save(); err != nil {
+			return models.QueueJob{}, false, err
+		}
+		return job.model(), true, nil
+	}
+
+	if changed {
+		if err := q.save(); err != nil {
+			return models.QueueJob{}, false, err
+		}
+	}
+	return models.QueueJob{}, false, nil
+}
+
+// Ack removes a job once it's done
+// Returns false if the site's queue has no such job
+func (q *JobQueues) Ack(site, queue, id string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.jobs[site]
+	for i, job := range jobs {
+		if job.Queue != queue || job.ID != id {
+			continue
+		}
+		q.setJobs(site, append(jobs[:i:i], jobs[i+1:]...))
+		return true, q.save()
+	}
+	return false, nil
+}
+
+// Stats returns the job counts of a site's queues, sorted by name
+func (q *JobQueues) Stats(site string) []models.QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	byName := map[string]*models.QueueStats{}
+	for _, job := range q.jobs[site] {
+		stats := byName[job.Queue]
+		if stats == nil {
+			stats = &models.QueueStats{Name: job.Queue}
+			byName[job.Queue] = stats
+		}
+		switch {
+		case job.Failed:
+			stats.Failed++
+		case job.AvailableAt.After(now) && job.Reserved:
+			stats.Reserved++
+		case job.AvailableAt.After(now):
+			stats.Delayed++
+		default:
+			stats.Ready++
+		}
+	}
+
+	list := make([]models.QueueStats, 0, len(byName))
+	for _, stats := range byName {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Failed returns the failed jobs of a site's queue, oldest first
+func (q *JobQueues) Failed(site, queue string) []models.QueueJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := []models.QueueJob{}
+	for _, job := range q.jobs[site] {
+		if job.Queue == queue && job.Failed {
+			jobs = append(jobs, job.model())
+		}
+	}
+	return jobs
+}
+
+// Retry makes the failed jobs of a site's queue ready again, with their attempts reset
+// Returns the number of jobs retried
+func (q *JobQueues) Retry(site, queue string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	now := time.Now().UTC()
+	for _, job := range q.jobs[site] {
+		if job.Queue != queue || !job.Failed {
+			continue
+		}
+		job.Failed = false
+		job.Error = ""
+		job.Attempts = 0
+		job.AvailableAt = now
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, q.save()
+}
+
+// Purge deletes every job of a site's queue
+// Returns the number of jobs deleted
+func (q *JobQueues) Purge(site, queue string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var kept []*queuedJob
+	for _, job := range q.jobs[site] {
+		if job.Queue != queue {
+			kept = append(kept, job)
+		}
+	}
+	count := len(q.jobs[site]) - len(kept)
+	if count == 0 {
+		return 0, nil
+	}
+	q.setJobs(site, kept)
+	return count, q.save()
+}
+
+// Prune removes the jobs of sites that no longer exist
+func (q *JobQueues) Prune(existing map[string]bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pruned := false
+	for site := range q.jobs {
+		if !existing[site] {
+			delete(q.jobs, site)
+			pruned = true
+			log.Printf("[QUEUE] Removed the jobs of deleted site %s", site)
+		}
+	}
+	if !pruned {
+		return
+	}
+	if err := q.save(); err != nil {
+		log.Printf("[QUEUE] Failed to save jobs: %v", err)
+	}
+}
+
+// setJobs replaces a site's jobs, dropping the site when it has none (caller must hold the lock)
+func (q *JobQueues) setJobs(site string, jobs []*queuedJob) {
+	if len(jobs) == 0 {
+		delete(q.jobs, site)
+		return
+	}
+	q.jobs[site] = jobs
+}
+
+// save writes the jobs to the queues file (caller must hold the lock)
+func (q *JobQueues) save() error {
+	if q.path == "" {
+		return nil
+	}
+
+	file := jobQueuesFile{Sites: make([]jobQueuesSite, 0, len(q.jobs))}
+	for site, jobs := range q.jobs {
+		file.Sites = append(file.Sites, jobQueuesSite{Site: site, Jobs: jobs})
+	}
+	sort.Slice(file.Sites, func(i, j int) bool { return file.Sites[i].Site < file.Sites[j].Site })
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// model converts a job to its API form
+func (j *queuedJob) model() models.QueueJob {
+	job := models.QueueJob{
+		ID:        j.ID,
+		Queue:     j.Queue,
+		Payload:   j.Payload,
+		Attempts:  j.Attempts,
+		CreatedAt: j.CreatedAt.Format(time.RFC3339),
+		Error:     j.Error,
+	}
+	if !j.Failed && j.AvailableAt.After(time.Now()) {
+		job.AvailableAt = j.AvailableAt.Format(time.RFC3339)
+	}
+	return job
+}
+
+// serveSiteQueues routes /sites/{name}/queues requests:
+//
+//	GET    /sites/{name}/queues                  - Job counts of the site's queues
+//	POST   /sites/{name}/queues/{queue}/jobs     - Push a job
+//	POST   /sites/{name}/queues/{queue}/pop      - Pop a job (204 if there is none)
+//	DELETE /sites/{name}/queues/{queue}/jobs/{id} - Acknowledge a job
+//	GET    /sites/{name}/queues/{queue}/failed   - List failed jobs
+//	POST   /sites/{name}/queues/{queue}/retry    - Retry failed jobs
+//	DELETE /sites/{name}/queues/{queue}          - Delete every job of a queue
+//
+// Sites call these with the OPERATOR_URL and LIGHTSPEED_SITE_TOKEN they're deployed with, which
+// only reaches the site's own queues. Only listing queues looks the site up; jobs of deleted
+// sites are pruned by the DNS sync
+func (h *SitesHandler) serveSiteQueues(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name, path string) {
+	if h.queues == nil {
+		h.writeError(w, "Job queues are not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+	if name == "" || strings.Contains(name, "/") {
+		h.writeError(w, "Site name is required", nil, http.StatusBadRequest)
+		return
+	}
+	if !h.auth.SiteAccess(r, name) {
+		log.Printf("[AUDIT] Refused access to the queues of %s (from %s)", name, r.RemoteAddr)
+		h.writeError(w, fmt.Sprintf("The queues of '%s' need its site token or an access token: run 'lightspeed login'", name), nil, http.StatusUnauthorized)
+		return
+	}
+
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		h.writeJSON(w, models.QueueList{Site: app.Spec.Name, Queues: h.queues.Stats(app.Spec.Name)})
+		return
+	}
+
+	queue, action, _ := strings.Cut(path, "/")
+	if !queueNamePattern.MatchString(queue) {
+		h.writeError(w, fmt.Sprintf("'%s' is not a queue name (lowercase letters, digits, - and _)", queue), nil, http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		count, err := h.queues.Purge(name, queue)
+		if err != nil {
+			h.writeError(w, "Failed to save jobs", err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[AUDIT] Purged %d jobs of queue %s of %s (from %s)", count, queue, name, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	case action == "jobs" && r.Method == http.MethodPost:
+		h.pushJob(w, r, name, queue)
+	case action == "pop" && r.Method == http.MethodPost:
+		h.popJob(w, r, name, queue)
+	case strings.HasPrefix(action, "jobs/") && r.Method == http.MethodDelete:
+		found, err := h.queues.Ack(name, queue, strings.TrimPrefix(action, "jobs/"))
+		if err != nil {
+			h.writeError(w, "Failed to save jobs", err, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			h.writeError(w, fmt.Sprintf("Queue '%s' has no job '%s'", queue, strings.TrimPrefix(action, "jobs/")), nil, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "failed" && r.Method == http.MethodGet:
+		h.writeJSON(w, models.QueueJobList{Jobs: h.queues.Failed(name, queue)})
+	case action == "retry" && r.Method == http.MethodPost:
+		count, err := h.queues.Retry(name, queue)
+		if err != nil {
+			h.writeError(w, "Failed to save jobs", err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[QUEUE] Retrying %d failed jobs of %s/%s", count, name, queue)
+		h.writeJSON(w, map[string]int{"retried": count})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pushJob pushes a job to a site's queue
+func (h *SitesHandler) pushJob(w http.ResponseWriter, r *http.Request, name, queue string) {
+	var push models.QueuePush
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxQueuePayload+1024)).Decode(&push); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if len(push.Payload) == 0 {
+		push.Payload = json.RawMessage("null")
+	}
+	if len(push.Payload) > maxQueuePayload {
+		h.writeError(w, fmt.Sprintf("Payload is larger than %d KB", maxQueuePayload>>10), nil, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if push.Delay < 0 || push.Delay > maxQueueDelay {
+		h.writeError(w, fmt.Sprintf("delay must be between 0 and %d seconds", maxQueueDelay), nil, http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.queues.Push(name, queue, push.Payload, time.Duration(push.Delay)*time.Second)
+	if err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusTooManyRequests)
+		return
+	}
+
+	h.writeJSONStatus(w, http.StatusCreated, job)
+}
+
+// popJob pops the next job of a site's queue
+func (h *SitesHandler) popJob(w http.ResponseWriter, r *http.Request, name, queue string) {
+	// The body is optional
+	var pop models.QueuePop
+	if err := json.NewDecoder(r.Body).Decode(&pop); err != nil && err != io.EOF {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if pop.Timeout == 0 {
+		pop.Timeout = defaultQueueTimeout
+	}
+	if pop.Timeout < 1 || pop.Timeout > maxQueueTimeout {
+		h.writeError(w, fmt.Sprintf("timeout must be between 1 and %d seconds", maxQueueTimeout), nil, http.StatusBadRequest)
+		return
+	}
+
+	job, ok, err := h.queues.Pop(name, queue, time.Duration(pop.Timeout)*time.Second)
+	if err != nil {
+		h.writeError(w, "Failed to save jobs", err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.writeJSON(w, job)
+}
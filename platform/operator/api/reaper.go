@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SiteReaper periodically deletes temporary sites whose TTL has expired
+type SiteReaper struct {
+	handler  *SitesHandler
+	interval time.Duration
+}
+
+// NewSiteReaper creates a new site reaper
+func NewSiteReaper(handler *SitesHandler, interval time.Duration) *SiteReaper {
+	return &SiteReaper{
+		handler:  handler,
+		interval: interval,
+	}
+}
+
+// Run deletes expired sites periodically (blocks; run it under the worker supervisor)
+func (r *SiteReaper) Run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	log.Printf("[REAPER] Started, checking for expired sites every %v", r.interval)
+
+	for range ticker.C {
+		r.reap()
+	}
+}
+
+// reap deletes every site past its expiry
+func (r *SiteReaper) reap() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	apps, err := r.handler.doClient.ListApps(ctx)
+	if err != nil {
+		log.Printf("[REAPER] Failed to list apps: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, app := range apps {
+		value := app.Env(expiresAtEnv)
+		if value == "" {
+			continue
+		}
+
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			log.Printf("[REAPER] Ignoring %s: invalid expiry %q", app.Spec.Name, value)
+			continue
+		}
+		if now.Before(expiresAt) {
+			continue
+		}
+
+		if err := r.handler.doClient.DeleteApp(ctx, app.ID); err != nil {
+			log.Printf("[REAPER] Failed to delete %s: %v", app.Spec.Name, err)
+			continue
+		}
+		log.Printf("[REAPER] Deleted %s (expired %s)", app.Spec.Name, expiresAt.Format(time.RFC3339))
+	}
+}
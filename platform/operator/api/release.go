@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// releaseEnv is the app environment variable holding the release ID of the running image
+// The PHP library appends it to asset URLs, so every release busts reverse proxy caches
+const releaseEnv = "LIGHTSPEED_RELEASE"
+
+// releaseIDLength is how many hex digits of the image digest the release ID keeps
+const releaseIDLength = 12
+
+// releaseID returns the release ID of an image tag, taken from its manifest digest
+// Returns "" if the digest can't be read; the release is then left as it was
+func (h *SitesHandler) releaseID(ctx context.Context, repository, tag string) string {
+	if h.images == nil {
+		return ""
+	}
+
+	digest, err := h.images.InspectDigest(ctx, repository, tag)
+	if err != nil || digest == "" {
+		log.Printf("[API] Could not read digest of %s:%s, release not recorded: %v", repository, tag, err)
+		return ""
+	}
+
+	id := strings.TrimPrefix(digest, "sha256:")
+	if len(id) > releaseIDLength {
+		id = id[:releaseIDLength]
+	}
+	return id
+}
+
+// siteRelease returns the release a site runs
+func (h *SitesHandler) siteRelease(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	release := models.Release{ID: app.Env(releaseEnv)}
+	if image := app.Image(); image != nil {
+		release.Tag = image.Tag
+	}
+	if app.ActiveDeployment != nil {
+		release.DeploymentID = app.ActiveDeployment.ID
+	}
+	h.writeJSON(w, release)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// maxInstances limits how many instances a site can scale to
+const maxInstances = 10
+
+// sizeSlugPattern matches an App Platform instance size slug (e.g. apps-s-1vcpu-1gb)
+var sizeSlugPattern = regexp.MustCompile(`^apps-[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// updateSite changes the instance count and size of a site's service, which redeploys it
+// App Platform rejects sizes that don't exist, so only the slug's form is checked here
+func (h *SitesHandler) updateSite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.SiteUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if err := validateSiteUpdate(update); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	// Only the site is scaled; a service deployed alongside it keeps its own settings
+	site := siteSpecService(spec)
+	if site == nil {
+		h.writeError(w, "Site spec has no service to scale", nil, http.StatusInternalServerError)
+		return
+	}
+	var current interface{}
+	if isDryRun(r) {
+		current = redactSpec(spec)
+	}
+	if update.Instances > 0 {
+		site["instance_count"] = update.Instances
+	}
+	if update.Size != "" {
+		site["instance_size_slug"] = update.Size
+	}
+	if !h.checkPolicies(w, r, name, app, spec) {
+		return
+	}
+
+	if isDryRun(r) {
+		h.writeDryRun(w, r, models.DryRun{Action: "update", Site: name, Current: current, Spec: spec})
+		return
+	}
+
+	updated, err := do.UpdateApp(r.Context(), app.ID, spec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to scale site", err)
+		return
+	}
+
+	log.Printf("[AUDIT] Scaled %s to %d x %s (from %s)", name, updated.Instances(), updated.Size(), r.RemoteAddr)
+	h.writeJSON(w, siteResponse(updated))
+}
+
+// validateSiteUpdate checks that an update changes something and is in range
+func validateSiteUpdate(update models.SiteUpdate) error {
+	if update.Instances == 0 && update.Size == "" {
+		return fmt.Errorf("instances or size is required")
+	}
+	if update.Instances < 0 || update.Instances > maxInstances {
+		return fmt.Errorf("instances must be between 1 and %d", maxInstances)
+	}
+	if update.Size != "" && !sizeSlugPattern.MatchString(update.Size) {
+		return fmt.Errorf("'%s' is not an instance size (e.g. apps-s-1vcpu-1gb)", update.Size)
+	}
+	return nil
+}
+
+// validateSiteRuntime checks the port, instance count, size, environment and labels a site is created with
+func validateSiteRuntime(site models.Site) error {
+	if site.Port < 0 || site.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if site.Instances < 0 || site.Instances > maxInstances {
+		return fmt.Errorf("instances must be between 1 and %d", maxInstances)
+	}
+	if site.Size != "" && !sizeSlugPattern.MatchString(site.Size) {
+		return fmt.Errorf("'%s' is not an instance size (e.g. apps-s-1vcpu-1gb)", site.Size)
+	}
+	for key := range site.Env {
+		if err := validateEnvKey(key); err != nil {
+			return err
+		}
+	}
+	return validateLabels(site.Labels)
+}
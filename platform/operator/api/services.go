@@ -0,0 +1,256 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Defaults of a service deployed alongside a site
+const (
+	defaultServiceName = "api"
+	defaultServicePort = 8080
+	defaultServicePath = "/api"
+)
+
+// serviceNamePattern matches a service name, which is appended to the site name to name its component
+var serviceNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,15}$`)
+
+// normalizeService fills in the defaults of a site's service and validates it
+func normalizeService(service *models.SiteService) error {
+	if service.Image == "" {
+		return fmt.Errorf("service image is required")
+	}
+	if service.Name == "" {
+		service.Name = defaultServiceName
+	}
+	if service.Tag == "" {
+		service.Tag = "latest"
+	}
+	if service.Port == 0 {
+		service.Port = defaultServicePort
+	}
+	if service.Path == "" {
+		service.Path = defaultServicePath
+	}
+	service.Path = "/" + strings.Trim(service.Path, "/")
+
+	if !serviceNamePattern.MatchString(service.Name) {
+		return fmt.Errorf("service name '%s' must be lowercase letters, digits and dashes (up to 16)", service.Name)
+	}
+	if service.Port < 1 || service.Port > 65535 {
+		return fmt.Errorf("service port must be between 1 and 65535")
+	}
+	if service.Path == "/" {
+		return fmt.Errorf("service path can't be /, which is routed to the site")
+	}
+	if service.Instances < 0 || service.Instances > maxInstances {
+		return fmt.Errorf("service instances must be between 1 and %d", maxInstances)
+	}
+	if service.Size != "" && !sizeSlugPattern.MatchString(service.Size) {
+		return fmt.Errorf("'%s' is not an instance size (e.g. apps-s-1vcpu-1gb)", service.Size)
+	}
+	return nil
+}
+
+// verifyServiceImage checks that a service's tag is in the registry and runs on App Platform
+// Writes the error response and returns false if not
+func (h *SitesHandler) verifyServiceImage(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, service models.SiteService) bool {
+	exists, err := h.tagExists(r.Context(), do, service.Image, service.Tag)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list tags", err)
+		return false
+	}
+	if !exists {
+		h.writeErrorCode(w, models.ErrorCodeTagNotFound, fmt.Sprintf("Service tag %s:%s not found in registry", service.Image, service.Tag), nil, http.StatusNotFound)
+		return false
+	}
+	if err := h.validatePlatform(r.Context(), service.Image, service.Tag); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// serviceComponent returns the app component name of a site's service
+func serviceComponent(siteName, service string) string {
+	return siteName + "-" + service
+}
+
+// serviceSpec returns the app spec component of a site's service
+// The service shares the site's environment variables and, unless it sets its own, its size
+func (h *SitesHandler) serviceSpec(siteName string, service models.SiteService, size string, envs interface{}) map[string]interface{} {
+	instances := service.Instances
+	if instances == 0 {
+		instances = defaultInstances
+	}
+	if service.Size != "" {
+		size = service.Size
+	}
+
+	return map[string]interface{}{
+		"name":      serviceComponent(siteName, service.Name),
+		"http_port": service.Port,
+		"image": map[string]interface{}{
+			"registry_type": "DOCR",
+			"registry":      h.defaultRegistry,
+			"repository":    service.Image,
+			"tag":           service.Tag,
+			"deploy_on_push": map[string]bool{
+				"enabled": false,
+			},
+		},
+		"instance_count":     instances,
+		"instance_size_slug": size,
+		"envs":               envs,
+	}
+}
+
+// serviceRule returns the ingress rule routing a service's path to it
+// The prefix is stripped, so /api/users reaches the service as /users
+func serviceRule(siteName string, service models.SiteService) map[string]interface{} {
+	return ingressRuleSpec(models.IngressRule{Path: service.Path, Component: serviceComponent(siteName, service.Name)})
+}
+
+// setSpecService adds a site's service to a raw app spec, or updates the spec's service
+// Only one service runs next to the site, so a service with another name replaces it.
+// Its ingress rule goes ahead of the site's catch-all rule.
+func (h *SitesHandler) setSpecService(spec map[string]interface{}, service models.SiteService) error {
+	siteName, _ := spec["name"].(string)
+	siteIndex := siteSpecIndex(spec)
+	if siteIndex < 0 {
+		return fmt.Errorf("site spec has no site component")
+	}
+	existing, _ := spec["services"].([]interface{})
+	site := existing[siteIndex].(map[string]interface{})
+	size, _ := site["instance_size_slug"].(string)
+	component := serviceComponent(siteName, service.Name)
+
+	services := []interface{}{}
+	for i, item := range existing {
+		if i != siteIndex {
+			fields, _ := item.(map[string]interface{})
+			if name, _ := fields["name"].(string); name != component {
+				continue
+			}
+			// Keep the instance count and size the service was scaled to unless they're set
+			if service.Instances == 0 {
+				if count, ok := fields["instance_count"].(float64); ok && count > 0 {
+					service.Instances = int(count)
+				}
+			}
+			if service.Size == "" {
+				if slug, _ := fields["instance_size_slug"].(string); slug != "" {
+					service.Size = slug
+				}
+			}
+			item = h.serviceSpec(siteName, service, size, fields["envs"])
+			component = ""
+		}
+		services = append(services, item)
+	}
+	if component != "" {
+		services = append(services, h.serviceSpec(siteName, service, size, site["envs"]))
+	}
+	spec["services"] = services
+
+	ingress, _ := spec["ingress"].(map[string]interface{})
+	if ingress == nil {
+		ingress = map[string]interface{}{}
+		spec["ingress"] = ingress
+	}
+	// Rules of the components that remain are kept; the service's rule replaces its old one
+	siteComponent, _ := site["name"].(string)
+	component = serviceComponent(siteName, service.Name)
+	components := specComponents(spec)
+	rules := []interface{}{serviceRule(siteName, service)}
+	routed := false
+	existingRules, _ := ingress["rules"].([]interface{})
+	for _, item := range existingRules {
+		target, prefix := ingressRuleTarget(item)
+		if !components[target] || target == component || prefix == service.Path {
+			continue
+		}
+		rules = append(rules, item)
+		routed = routed || prefix == "/"
+	}
+	// Specs without rules route everything to their only component, which now needs a rule
+	if !routed {
+		rules = append(rules, ingressRuleSpec(models.IngressRule{Path: "/", Component: siteComponent}))
+	}
+	sortIngressRules(rules)
+	ingress["rules"] = rules
+	return nil
+}
+
+// siteSpecService returns the site component of a raw app spec (nil if it has none)
+func siteSpecService(spec map[string]interface{}) map[string]interface{} {
+	index := siteSpecIndex(spec)
+	if index < 0 {
+		return nil
+	}
+	services, _ := spec["services"].([]interface{})
+	return services[index].(map[string]interface{})
+}
+
+// siteSpecIndex returns the index of the site component in the services of a raw app spec:
+// the service named after the app, or else its first service (-1 if it has none)
+func siteSpecIndex(spec map[string]interface{}) int {
+	siteName, _ := spec["name"].(string)
+	services, _ := spec["services"].([]interface{})
+	first := -1
+	for i, item := range services {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := fields["name"].(string); name == siteName {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first
+}
+
+// siteService returns the service deployed alongside a site (nil if it has none)
+func siteService(app *digitalocean.App) *models.SiteService {
+	prefix := app.Spec.Name + "-"
+	site := app.Site()
+	for i := range app.Spec.Services {
+		component := &app.Spec.Services[i]
+		if component == site || component.Image == nil || !strings.HasPrefix(component.Name, prefix) {
+			continue
+		}
+		return &models.SiteService{
+			Name:      strings.TrimPrefix(component.Name, prefix),
+			Image:     component.Image.Repository,
+			Tag:       component.Image.Tag,
+			Port:      component.HTTPPort,
+			Path:      app.RoutePrefix(component.Name),
+			Instances: component.InstanceCount,
+			Size:      component.InstanceSize,
+		}
+	}
+	return nil
+}
+
+// sameService checks if a requested service matches the one a site runs
+// Instance count and size are only compared when the request sets them
+func sameService(current *models.SiteService, requested models.SiteService) bool {
+	if current == nil {
+		return false
+	}
+	return current.Name == requested.Name &&
+		current.Image == requested.Image &&
+		current.Tag == requested.Tag &&
+		current.Port == requested.Port &&
+		current.Path == requested.Path &&
+		(requested.Instances == 0 || current.Instances == requested.Instances) &&
+		(requested.Size == "" || current.Size == requested.Size)
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SharedCache gives sites a cache on one shared Redis-compatible instance. Every site gets
+// its own ACL user that can only use keys and channels starting with the site's prefix, so
+// sites can't read or flush each other's sessions.
+type SharedCache struct {
+	url  *url.URL // Admin connection URL of the instance (nil if there is none)
+	path string   // JSON file users are persisted to (empty for in-memory only)
+
+	mu    sync.Mutex
+	users map[string]sharedCacheUser // By site
+}
+
+// sharedCacheUser is the ACL user of a site on the shared instance
+type sharedCacheUser struct {
+	Site      string    `json:"site"`
+	User      string    `json:"user"`
+	Password  string    `json:"password"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// sharedCacheFile is the persisted form of the shared cache users
+type sharedCacheFile struct {
+	Users []sharedCacheUser `json:"users"`
+}
+
+// NewSharedCache creates the shared cache of the instance at rawURL (redis:// or rediss://,
+// with admin credentials), loading saved users from path if set
+// An empty rawURL disables the shared cache
+func NewSharedCache(rawURL, path string) (*SharedCache, error) {
+	c := &SharedCache{path: path, users: make(map[string]sharedCacheUser)}
+
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+			return nil, fmt.Errorf("cache URL must be redis://[user:password@]host[:port] or rediss://...")
+		}
+		c.url = u
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file sharedCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, user := range file.Users {
+		c.users[user.Site] = user
+	}
+	log.Printf("[CACHE] Loaded shared cache users of %d sites from %s", len(c.users), path)
+
+	return c, nil
+}
+
+// Enabled checks if a shared instance is configured
+func (c *SharedCache) Enabled() bool {
+	return c != nil && c.url != nil
+}
+
+// Get returns a site's user on the shared instance
+func (c *SharedCache) Get(site string) (sharedCacheUser, bool) {
+	if c == nil {
+		return sharedCacheUser{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.users[site]
+	return user, ok
+}
+
+// Create creates a site's ACL user, or resets the rules of the user it has
+// Returns whether the user is new
+func (c *SharedCache) Create(ctx context.Context, site string) (sharedCacheUser, bool, error) {
+	if !c.Enabled() {
+		return sharedCacheUser{}, false, fmt.Errorf("no shared cache instance is configured")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, exists := c.users[site]
+	if !exists {
+		password := make([]byte, 24)
+		if _, err := rand.Read(password); err != nil {
+			return sharedCacheUser{}, false, err
+		}
+		user = sharedCacheUser{
+			Site:      site,
+			User:      "lightspeed-" + site,
+			Password:  hex.EncodeToString(password),
+			CreatedAt: time.Now().UTC(),
+		}
+	}
+
+	conn, err := dialRedis(ctx, c.url)
+	if err != nil {
+		return sharedCacheUser{}, false, err
+	}
+	defer conn.Close()
+
+	prefix := sharedCachePrefix(site)
+	if _, err := conn.do("ACL", "SETUSER", user.User, "reset", "on", ">"+user.Password,
+		"~"+prefix+"*", "&"+prefix+"*", "+@all", "-@admin", "-@dangerous"); err != nil {
+		return sharedCacheUser{}, false, fmt.Errorf("failed to create cache user: %w", err)
+	}
+
+	c.users[site] = user
+	if err := c.save(); err != nil {
+		return sharedCacheUser{}, false, err
+	}
+	return user, !exists, nil
+}
+
+// Delete removes a site's ACL user and deletes the keys with its prefix
+func (c *SharedCache) Delete(ctx context.Context, site string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.users[site]
+	if !ok {
+		return nil
+	}
+	if c.Enabled() {
+		if err := c.deleteUser(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	delete(c.users, site)
+	return c.save()
+}
+
+// Prune deletes the users and keys of sites that no longer exist
+func (c *SharedCache) Prune(ctx context.Context, existing map[string]bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pruned := false
+	for site, user := range c.users {
+		if existing[site] {
+			continue
+		}
+		if c.Enabled() {
+			if err := c.deleteUser(ctx, user); err != nil {
+				log.Printf("[CACHE] Failed to delete cache user of %s: %v", site, err)
+				continue
+			}
+		}
+		delete(c.users, site)
+		pruned = true
+		log.Printf("[CACHE] Deleted cache user of removed site %s", site)
+	}
+	if !pruned {
+		return
+	}
+	if err := c.save(); err != nil {
+		log.Printf("[CACHE] Failed to save shared cache users: %v", err)
+	}
+}
+
+// Connection returns the URL, host and port sites connect to with a user's credentials
+// Users saved while an instance was configured have no connection without one
+func (c *SharedCache) Connection(user sharedCacheUser) (string, string, int) {
+	if c.url == nil {
+		return "", "", 0
+	}
+	host := c.url.Hostname()
+	port := 6379
+	if p, err := strconv.Atoi(c.url.Port()); err == nil {
+		port = p
+	}
+	u := url.URL{
+		Scheme: c.url.Scheme,
+		User:   url.UserPassword(user.User, user.Password),
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	return u.String(), host, port
+}
+
+// deleteUser drops a user and unlinks the keys with its site's prefix (caller must hold the lock)
+func (c *SharedCache) deleteUser(ctx context.Context, user sharedCacheUser) error {
+	conn, err := dialRedis(ctx, c.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.do("ACL", "DELUSER", user.User); err != nil {
+		return fmt.Errorf("failed to delete cache user: %w", err)
+	}
+
+	cursor := "0"
+	for {
+		reply, err := conn.do("SCAN", cursor, "MATCH", sharedCachePrefix(user.Site)+"*", "COUNT", "500")
+		if err != nil {
+			return fmt.Errorf("failed to list cache keys: %w", err)
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return fmt.Errorf("unexpected SCAN reply")
+		}
+		cursor, _ = items[0].(string)
+		keys, _ := items[1].([]interface{})
+		if len(keys) > 0 {
+			args := []string{"UNLINK"}
+			for _, key := range keys {
+				if k, ok := key.(string); ok {
+					args = append(args, k)
+				}
+			}
+			if _, err := conn.do(args...); err != nil {
+				return fmt.Errorf("failed to delete cache keys: %w", err)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// save writes the users to the cache file (caller must hold the lock)
+func (c *SharedCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	file := sharedCacheFile{Users: make([]sharedCacheUser, 0, len(c.users))}
+	for _, user := range c.users {
+		file.Users = append(file.Users, user)
+	}
+	sort.Slice(file.Users, func(i, j int) bool { return file.Users[i].Site < file.Users[j].Site })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// sharedCachePrefix is the prefix of a site's keys on the shared instance
+func sharedCachePrefix(site string) string {
+	return site + ":"
+}
+
+// redisConn is a minimal Redis protocol client, enough to manage users and keys
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// dialRedis connects to a redis:// or rediss:// URL and authenticates with its credentials
+func dialRedis(ctx context.Context, u *url.URL) (*redisConn, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "rediss" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cache: %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if user := u.User.Username(); user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate to cache: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and reads its reply: a string, an int64, nil or a []interface{} of replies
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read reads a reply
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
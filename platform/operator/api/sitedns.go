@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// siteRecordTypes are the record types sites can manage within their domain
+var siteRecordTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true, "TXT": true, "MX": true}
+
+// recordLabelPattern matches a DNS label, allowing underscores for service labels (_dmarc, _acme-challenge)
+var recordLabelPattern = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+
+// maxTXTLength limits TXT record content (Cloudflare's limit)
+const maxTXTLength = 2048
+
+// serveSiteDNS routes /sites/{name}/dns requests
+func (h *SitesHandler) serveSiteDNS(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSiteRecords(w, r, do, name)
+	case http.MethodPost:
+		h.addSiteRecord(w, r, do, name)
+	case http.MethodDelete:
+		h.deleteSiteRecords(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listSiteRecords lists the DNS records on a site's domain and its subdomains
+func (h *SitesHandler) listSiteRecords(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	records, err := h.dnsProviderFor(domain).ListRecords(domain)
+	if err != nil {
+		h.writeError(w, "Failed to list DNS records", err, http.StatusBadGateway)
+		return
+	}
+	if records == nil {
+		records = []models.DNSRecord{}
+	}
+
+	h.writeJSON(w, models.DNSRecordList{Domain: domain, Records: records})
+}
+
+// addSiteRecord adds a DNS record within a site's domain
+func (h *SitesHandler) addSiteRecord(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var record models.DNSRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	if err := normalizeSiteRecord(&record, domain); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.dnsProviderFor(domain).CreateRecord(record)
+	if err != nil {
+		h.writeError(w, "Failed to create DNS record", err, http.StatusBadGateway)
+		return
+	}
+
+	auditDNS(r, "created", name, *created)
+	h.writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// deleteSiteRecords deletes the DNS records matching type, name and (optionally) content
+// More than one match without content is a conflict, so a delete never removes more than intended
+func (h *SitesHandler) deleteSiteRecords(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	query := r.URL.Query()
+	match := models.DNSRecord{
+		Type:    query.Get("type"),
+		Name:    query.Get("name"),
+		Content: query.Get("content"),
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	recordName, err := siteRecordName(match.Name, domain)
+	if err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	recordType := strings.ToUpper(match.Type)
+	if recordName == domain {
+		h.writeError(w, "The site's own record is managed by the platform", nil, http.StatusBadRequest)
+		return
+	}
+
+	provider := h.dnsProviderFor(domain)
+	records, err := provider.ListRecords(domain)
+	if err != nil {
+		h.writeError(w, "Failed to list DNS records", err, http.StatusBadGateway)
+		return
+	}
+
+	var matches []models.DNSRecord
+	for _, record := range records {
+		if record.Type == recordType && record.Name == recordName && (match.Content == "" || record.Content == match.Content) {
+			matches = append(matches, record)
+		}
+	}
+	if len(matches) == 0 {
+		h.writeError(w, fmt.Sprintf("No %s record found for %s", recordType, recordName), nil, http.StatusNotFound)
+		return
+	}
+	if len(matches) > 1 {
+		h.writeError(w, fmt.Sprintf("%d %s records match %s; specify the content to delete one", len(matches), recordType, recordName), nil, http.StatusConflict)
+		return
+	}
+
+	if err := provider.DeleteRecord(matches[0].ID); err != nil {
+		h.writeError(w, "Failed to delete DNS record", err, http.StatusBadGateway)
+		return
+	}
+
+	auditDNS(r, "deleted", name, matches[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setSiteProxy turns CDN proxying of a site's domain on or off
+func (h *SitesHandler) setSiteProxy(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var proxy models.Proxy
+	if err := json.NewDecoder(r.Body).Decode(&proxy); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	provider, ok := h.dnsProviderFor(domain).(ProxyProvider)
+	if !ok {
+		h.writeError(w, fmt.Sprintf("The DNS provider of %s doesn't support proxying", domain), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := provider.SetProxied(domain, proxy.Enabled); err != nil {
+		h.writeError(w, "Failed to set proxy mode", err, http.StatusBadGateway)
+		return
+	}
+
+	proxy.Domain = domain
+	if proxy.Enabled {
+		proxy.SSLMode = "full"
+	}
+	log.Printf("[AUDIT] Proxy enabled=%t for %s (site %s, from %s)", proxy.Enabled, domain, name, r.RemoteAddr)
+	h.writeJSON(w, proxy)
+}
+
+// purgeSiteCache purges the CDN cache of a site's domain, so a deploy is served right away
+func (h *SitesHandler) purgeSiteCache(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	domain := domainOf(app)
+	purger, ok := h.dnsProviderFor(domain).(CachePurger)
+	if !ok {
+		h.writeError(w, fmt.Sprintf("The DNS provider of %s doesn't support cache purging", domain), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := purger.PurgeCache(domain); err != nil {
+		h.writeError(w, "Failed to purge cache", err, http.StatusBadGateway)
+		return
+	}
+
+	log.Printf("[AUDIT] Cache purged for %s (site %s, from %s)", domain, name, r.RemoteAddr)
+	h.writeJSON(w, models.CachePurge{Domain: domain})
+}
+
+// normalizeSiteRecord validates a record and scopes its name to the site's domain
+func normalizeSiteRecord(record *models.DNSRecord, domain string) error {
+	record.Type = strings.ToUpper(record.Type)
+	if !siteRecordTypes[record.Type] {
+		return fmt.Errorf("record type must be A, AAAA, CNAME, TXT or MX")
+	}
+
+	name, err := siteRecordName(record.Name, domain)
+	if err != nil {
+		return err
+	}
+	// The site's own name is a CNAME to the app, which can't share its name with other records
+	if name == domain {
+		return fmt.Errorf("records can't be added on %s itself; use a subdomain such as _verify.%s", domain, domain)
+	}
+	record.Name = name
+	record.ID = ""
+
+	content := strings.TrimSpace(record.Content)
+	switch record.Type {
+	case "A":
+		if ip := net.ParseIP(content); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("'%s' is not an IPv4 address", content)
+		}
+	case "AAAA":
+		if ip := net.ParseIP(content); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("'%s' is not an IPv6 address", content)
+		}
+	case "CNAME", "MX":
+		content = strings.TrimSuffix(strings.ToLower(content), ".")
+		if !validBaseDomain(content) {
+			return fmt.Errorf("'%s' is not a valid hostname", content)
+		}
+		if record.Type == "MX" && (record.Priority < 0 || record.Priority > 65535) {
+			return fmt.Errorf("priority must be between 0 and 65535")
+		}
+	case "TXT":
+		if content == "" || len(content) > maxTXTLength {
+			return fmt.Errorf("TXT content must be 1-%d characters", maxTXTLength)
+		}
+	}
+	record.Content = content
+	if record.Type != "MX" {
+		record.Priority = 0
+	}
+
+	return nil
+}
+
+// siteRecordName returns the full record name for a name within a site's domain
+// Names not ending in the domain are relative to it, so records can never escape
+// the site's scope; "" and "@" are the domain itself
+func siteRecordName(name, domain string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" || name == "@" || name == domain {
+		return domain, nil
+	}
+
+	relative := strings.TrimSuffix(name, "."+domain)
+	for _, label := range strings.Split(relative, ".") {
+		if !recordLabelPattern.MatchString(label) {
+			return "", fmt.Errorf("'%s' is not a valid record name", name)
+		}
+	}
+
+	return relative + "." + domain, nil
+}
+
+// auditDNS logs a DNS change with the caller's address
+func auditDNS(r *http.Request, action, site string, record models.DNSRecord) {
+	log.Printf("[AUDIT] DNS %s %s %s -> %s (site %s, from %s)", action, record.Type, record.Name, record.Content, site, r.RemoteAddr)
+}
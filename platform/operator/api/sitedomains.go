@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// serveSiteDomains routes /sites/{name}/domains requests
+func (h *SitesHandler) serveSiteDomains(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSiteDomains(w, r, do, name)
+	case http.MethodPost:
+		h.addSiteDomain(w, r, do, name)
+	case http.MethodDelete:
+		h.removeSiteDomain(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listSiteDomains lists the domains routed to a site
+// With ?records=true, managed domains come with the CNAME their zone holds, so clients can
+// check it (dns check) without the provider's credentials
+func (h *SitesHandler) listSiteDomains(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	list := models.SiteDomainList{Domains: []models.SiteDomain{}}
+	for _, d := range app.Spec.Domains {
+		domain := h.siteDomain(app, d.Domain, d.Type)
+		if r.URL.Query().Get("records") == "true" && domain.Managed {
+			if err := h.findDomainRecord(&domain); err != nil {
+				h.writeError(w, "Failed to list DNS records", err, http.StatusBadGateway)
+				return
+			}
+		}
+		list.Domains = append(list.Domains, domain)
+	}
+	h.writeJSON(w, list)
+}
+
+// findDomainRecord fills in the CNAME record a managed domain's zone holds for it
+func (h *SitesHandler) findDomainRecord(domain *models.SiteDomain) error {
+	records, err := h.zoneProviderFor(domain.Domain).ListRecords(domain.Domain)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Type == "CNAME" && record.Name == domain.Domain {
+			domain.Record = strings.TrimSuffix(record.Content, ".")
+			domain.Proxied = record.Proxied
+		}
+	}
+	return nil
+}
+
+// addSiteDomain adds a custom domain to a site's app spec, and points it at the app
+// if its zone is managed by the operator
+func (h *SitesHandler) addSiteDomain(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var request models.SiteDomain
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(request.Domain)), ".")
+	if !validBaseDomain(domain) {
+		h.writeError(w, fmt.Sprintf("'%s' is not a valid domain", request.Domain), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	for _, d := range app.Spec.Domains {
+		if d.Domain == domain {
+			h.writeError(w, fmt.Sprintf("%s is already a domain of '%s'", domain, name), nil, http.StatusConflict)
+			return
+		}
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	domains, _ := spec["domains"].([]interface{})
+	spec["domains"] = append(domains, map[string]interface{}{"domain": domain, "type": "ALIAS"})
+
+	updated, err := do.UpdateApp(r.Context(), app.ID, spec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to add domain", err)
+		return
+	}
+
+	result := h.siteDomain(updated, domain, "ALIAS")
+	if provider := h.zoneProviderFor(domain); provider != nil && updated.DefaultIngress != "" {
+		if err := provider.EnsureCNAME(domain, updated.DefaultIngress); err != nil {
+			// The domain is attached either way; report the record as one to create by hand
+			log.Printf("[API] Failed to create CNAME for %s: %v", domain, err)
+			result.Managed = false
+		}
+	}
+
+	log.Printf("[AUDIT] Domain %s added to %s (from %s)", domain, name, r.RemoteAddr)
+	h.writeJSONStatus(w, http.StatusCreated, result)
+}
+
+// removeSiteDomain removes a custom domain from a site's app spec, and its CNAME if the
+// operator manages the domain's zone
+func (h *SitesHandler) removeSiteDomain(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain"))), ".")
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	found := false
+	for _, d := range app.Spec.Domains {
+		if d.Domain != domain {
+			continue
+		}
+		if d.Type == "PRIMARY" {
+			h.writeError(w, fmt.Sprintf("%s is the site's primary domain and can't be removed", domain), nil, http.StatusBadRequest)
+			return
+		}
+		found = true
+	}
+	if !found {
+		h.writeError(w, fmt.Sprintf("%s is not a domain of '%s'", domain, name), nil, http.StatusNotFound)
+		return
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	existing, _ := spec["domains"].([]interface{})
+	domains := make([]interface{}, 0, len(existing))
+	for _, item := range existing {
+		if fields, ok := item.(map[string]interface{}); ok && fields["domain"] == domain {
+			continue
+		}
+		domains = append(domains, item)
+	}
+	spec["domains"] = domains
+
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Failed to remove domain", err)
+		return
+	}
+
+	if provider := h.zoneProviderFor(domain); provider != nil {
+		if err := deleteCNAME(provider, domain); err != nil {
+			log.Printf("[API] Failed to delete CNAME for %s: %v", domain, err)
+		}
+	}
+
+	log.Printf("[AUDIT] Domain %s removed from %s (from %s)", domain, name, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// siteDomain describes a domain of an app and how its DNS is set up
+// The primary domain points at the edge proxy for sites served through it, like the DNS sync does
+func (h *SitesHandler) siteDomain(app *digitalocean.App, domain, domainType string) models.SiteDomain {
+	target := app.DefaultIngress
+	if domainType == "PRIMARY" {
+		target = h.cnameTarget(app)
+	}
+	return models.SiteDomain{
+		Domain:  domain,
+		Type:    domainType,
+		Target:  strings.TrimPrefix(target, "https://"),
+		Managed: h.zoneProviderFor(domain) != nil,
+	}
+}
+
+// zoneProviderFor returns the DNS provider whose zone contains domain (nil if the operator
+// doesn't manage it, as for most custom domains)
+func (h *SitesHandler) zoneProviderFor(domain string) DNSProvider {
+	provider := h.dnsProviderFor(domain)
+	zone := provider.Zone()
+	if domain == zone || strings.HasSuffix(domain, "."+zone) {
+		return provider
+	}
+	return nil
+}
+
+// deleteCNAME deletes the CNAME record of a hostname, if there is one
+func deleteCNAME(provider DNSProvider, hostname string) error {
+	records, err := provider.ListRecords(hostname)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Type == "CNAME" && record.Name == hostname {
+			if err := provider.DeleteRecord(record.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,44 +1,133 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-)
 
-const digitalOceanAPI = "https://api.digitalocean.com/v2"
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/proxy"
+)
 
 // SitesHandler handles /sites endpoints
 type SitesHandler struct {
 	defaultToken    string
 	defaultRegistry string
+	doClient        *digitalocean.Client
 	cfClient        *CloudflareClient
 	operatorURL     string
-	operatorToken   string
+	auth            *AuthHandler
+	images          *proxy.RegistryProxy
+	templates       *TemplatesHandler
+	baseDomains     *BaseDomainsHandler
+	buildNumbers    *BuildNumbers
+	uptime          *UptimeMonitor
+	sloTarget       float64
+	synthetic       *SyntheticChecks
+	edge            *EdgeProxy
+	cache           *SharedCache
+	queues          *JobQueues
+	cron            *WebCron
+	policies        *PoliciesHandler
+	notifications   *NotificationsHandler
 }
 
 // NewSitesHandler creates a new sites handler
-func NewSitesHandler(defaultToken, defaultRegistry, cfToken, operatorURL, operatorToken string) *SitesHandler {
+func NewSitesHandler(defaultToken, defaultRegistry, cfToken, operatorURL string) *SitesHandler {
 	return &SitesHandler{
 		defaultToken:    defaultToken,
 		defaultRegistry: defaultRegistry,
+		doClient:        digitalocean.NewClient(defaultToken),
 		cfClient:        NewCloudflareClient(cfToken),
 		operatorURL:     operatorURL,
-		operatorToken:   operatorToken,
+		sloTarget:       DefaultSLOTarget,
 	}
 }
 
-// Site represents a site/app configuration (public API)
-type Site struct {
-	Name    string   `json:"name"`
-	Image   string   `json:"image,omitempty"`
-	Tag     string   `json:"tag,omitempty"`
-	Domains []string `json:"domains,omitempty"`
+// SetImageInspector sets the registry proxy used to inspect images before deploying
+func (h *SitesHandler) SetImageInspector(images *proxy.RegistryProxy) {
+	h.images = images
+}
+
+// SetTemplates sets the template catalog sites can be created from
+func (h *SitesHandler) SetTemplates(templates *TemplatesHandler) {
+	h.templates = templates
+}
+
+// SetBaseDomains sets the tenant base domains sites can be created under
+func (h *SitesHandler) SetBaseDomains(baseDomains *BaseDomainsHandler) {
+	h.baseDomains = baseDomains
+}
+
+// SetBuildNumbers sets the allocator for build number and dated tags
+func (h *SitesHandler) SetBuildNumbers(buildNumbers *BuildNumbers) {
+	h.buildNumbers = buildNumbers
+}
+
+// SetUptime sets the uptime monitor SLOs are computed from and the default SLO target
+func (h *SitesHandler) SetUptime(uptime *UptimeMonitor, sloTarget float64) {
+	h.uptime = uptime
+	h.sloTarget = sloTarget
+}
+
+// SetSyntheticChecks sets the store synthetic checks of sites are managed in
+func (h *SitesHandler) SetSyntheticChecks(synthetic *SyntheticChecks) {
+	h.synthetic = synthetic
+}
+
+// SetEdge sets the edge proxy sites in edge mode are served through
+func (h *SitesHandler) SetEdge(edge *EdgeProxy) {
+	h.edge = edge
+}
+
+// SetSharedCache sets the shared instance sites can get a cache on
+func (h *SitesHandler) SetSharedCache(cache *SharedCache) {
+	h.cache = cache
+}
+
+// SetJobQueues sets the job queues sites push background jobs to
+func (h *SitesHandler) SetJobQueues(queues *JobQueues) {
+	h.queues = queues
+}
+
+// SetCron sets the runner that requests site URLs on schedules
+func (h *SitesHandler) SetCron(cron *WebCron) {
+	h.cron = cron
+}
+
+// SetPolicies sets the policies site specs are checked against before sites are created or changed
+func (h *SitesHandler) SetPolicies(policies *PoliciesHandler) {
+	h.policies = policies
+}
+
+// SetNotifications sets the notification destinations deploys are posted to
+func (h *SitesHandler) SetNotifications(notifications *NotificationsHandler) {
+	h.notifications = notifications
+}
+
+// SetAuth sets the auth handler that gives sites their tokens
+func (h *SitesHandler) SetAuth(auth *AuthHandler) {
+	h.auth = auth
+}
+
+// dnsProviderFor returns the DNS provider that manages a site domain
+// Domains under a registered tenant base domain use the tenant's provider
+func (h *SitesHandler) dnsProviderFor(domain string) DNSProvider {
+	if h.baseDomains != nil {
+		if provider := h.baseDomains.ProviderFor(domain); provider != nil {
+			return provider
+		}
+	}
+	return h.cfClient
 }
 
 // Internal defaults (not exposed via API)
@@ -49,26 +138,32 @@ const (
 	defaultSize      = "apps-s-1vcpu-0.5gb"
 )
 
-// SiteResponse represents a site in responses
-type SiteResponse struct {
-	ID        string   `json:"id"`
-	Name      string   `json:"name"`
-	Region    string   `json:"region,omitempty"`
-	URLs      []string `json:"urls,omitempty"`
-	Status    string   `json:"status,omitempty"`
-	UpdatedAt string   `json:"updated_at,omitempty"`
+// appRegions are the App Platform regions sites can be created in
+var appRegions = map[string]bool{
+	"ams": true, "blr": true, "fra": true, "lon": true, "nyc": true,
+	"sfo": true, "sgp": true, "syd": true, "tor": true,
 }
 
+// Temporary sites store their expiry in an app environment variable so the reaper
+// can find them without separate storage
+const (
+	expiresAtEnv = "LIGHTSPEED_EXPIRES_AT"
+	maxSiteTTL   = 24 * time.Hour
+)
+
+// targetPlatform is the platform App Platform runs images on
+var targetPlatform = proxy.Platform{OS: "linux", Architecture: "amd64"}
+
 // ServeHTTP routes requests to appropriate handlers
 func (h *SitesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Get token from header or use default
+	// Lightspeed tokens (access, admin and site tokens) authenticate the caller,
+	// they're not DigitalOcean tokens
 	token := r.Header.Get("Authorization")
-	if token == "" && h.defaultToken != "" {
+	if (token == "" || isAccessToken(strings.TrimPrefix(token, "Bearer "))) && h.defaultToken != "" {
 		token = "Bearer " + h.defaultToken
 	}
-	if token != "" && !strings.HasPrefix(token, "Bearer ") {
-		token = "Bearer " + token
-	}
+	do := h.doClient.WithToken(token)
 
 	path := strings.TrimPrefix(r.URL.Path, "/sites")
 	path = strings.TrimPrefix(path, "/")
@@ -76,85 +171,106 @@ func (h *SitesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[API] %s /sites/%s", r.Method, path)
 
 	switch {
+	case strings.HasSuffix(path, "/queues") || strings.Contains(path, "/queues/"):
+		// Checked first, as queue and cron job names can end like other routes (e.g. /queues/dns)
+		name, rest, _ := strings.Cut(path, "/queues")
+		h.serveSiteQueues(w, r, do, name, strings.TrimPrefix(rest, "/"))
+	case strings.Contains(path, "/cron/"):
+		name, job, _ := strings.Cut(path, "/cron/")
+		h.runSiteCronJob(w, r, do, name, job)
+	case strings.HasSuffix(path, "/cron"):
+		h.serveSiteCron(w, r, do, strings.TrimSuffix(path, "/cron"))
+	case strings.HasSuffix(path, "/dns"):
+		h.serveSiteDNS(w, r, do, strings.TrimSuffix(path, "/dns"))
 	case path == "" && r.Method == http.MethodGet:
-		h.listSites(w, r, token)
+		h.listSites(w, r, do)
 	case path == "" && r.Method == http.MethodPost:
-		h.createSite(w, r, token)
+		h.createSite(w, r, do)
+	case strings.HasSuffix(path, "/logs") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/logs")
+		h.siteLogs(w, r, do, name)
+	case strings.HasSuffix(path, "/domains"):
+		h.serveSiteDomains(w, r, do, strings.TrimSuffix(path, "/domains"))
+	case strings.HasSuffix(path, "/checks"):
+		h.serveSiteChecks(w, r, do, strings.TrimSuffix(path, "/checks"))
+	case strings.HasSuffix(path, "/firewall"):
+		h.serveSiteFirewall(w, r, do, strings.TrimSuffix(path, "/firewall"))
+	case strings.HasSuffix(path, "/edge"):
+		h.serveSiteEdge(w, r, do, strings.TrimSuffix(path, "/edge"))
+	case strings.HasSuffix(path, "/env"):
+		h.serveSiteEnv(w, r, do, strings.TrimSuffix(path, "/env"))
+	case strings.HasSuffix(path, "/ingress"):
+		h.serveSiteIngress(w, r, do, strings.TrimSuffix(path, "/ingress"))
+	case strings.HasSuffix(path, "/db"):
+		h.serveSiteDatabase(w, r, do, strings.TrimSuffix(path, "/db"))
+	case strings.HasSuffix(path, "/cache"):
+		h.serveSiteCache(w, r, do, strings.TrimSuffix(path, "/cache"))
+	case strings.HasSuffix(path, "/worker"):
+		h.serveSiteWorker(w, r, do, strings.TrimSuffix(path, "/worker"))
+	case strings.HasSuffix(path, "/tags/next") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/tags/next")
+		h.allocateTag(w, r, do, name)
+	case strings.HasSuffix(path, "/release") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/release")
+		h.siteRelease(w, r, do, name)
+	case strings.HasSuffix(path, "/certs") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/certs")
+		h.siteCertificates(w, r, do, name)
+	case strings.HasSuffix(path, "/slo") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/slo")
+		h.siteSLO(w, r, do, name)
+	case strings.HasSuffix(path, "/deployments") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/deployments")
+		h.siteDeployments(w, r, do, name)
+	case strings.HasSuffix(path, "/tags") && r.Method == http.MethodGet:
+		name := strings.TrimSuffix(path, "/tags")
+		h.siteTags(w, r, do, name)
 	case r.Method == http.MethodGet:
-		h.getSite(w, r, token, path)
+		h.getSite(w, r, do, path)
 	case r.Method == http.MethodDelete:
-		h.deleteSite(w, r, token, path)
+		h.deleteSite(w, r, do, path)
+	case r.Method == http.MethodPatch && !strings.Contains(path, "/"):
+		h.updateSite(w, r, do, path)
 	case strings.HasSuffix(path, "/deploy") && r.Method == http.MethodPost:
 		name := strings.TrimSuffix(path, "/deploy")
-		h.deploySite(w, r, token, name)
+		h.deploySite(w, r, do, name)
+	case strings.HasSuffix(path, "/cancel") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/cancel")
+		h.cancelDeployment(w, r, do, name)
+	case strings.HasSuffix(path, "/proxy") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/proxy")
+		h.setSiteProxy(w, r, do, name)
+	case strings.HasSuffix(path, "/purge") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/purge")
+		h.purgeSiteCache(w, r, do, name)
+	case strings.HasSuffix(path, "/email") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/email")
+		h.setupEmail(w, r, do, name)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // listSites returns all apps from DigitalOcean
-func (h *SitesHandler) listSites(w http.ResponseWriter, r *http.Request, token string) {
-	resp, err := h.doRequest("GET", "/apps", token, nil)
+func (h *SitesHandler) listSites(w http.ResponseWriter, r *http.Request, do *digitalocean.Client) {
+	apps, err := do.ListApps(r.Context())
 	if err != nil {
-		h.writeError(w, "Failed to list sites", err, http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		h.forwardError(w, resp)
-		return
-	}
-
-	var result struct {
-		Apps []struct {
-			ID              string `json:"id"`
-			OwnerUUID       string `json:"owner_uuid"`
-			Spec            struct {
-				Name   string `json:"name"`
-				Region string `json:"region"`
-			} `json:"spec"`
-			DefaultIngress  string `json:"default_ingress"`
-			LiveURL         string `json:"live_url"`
-			ActiveDeployment struct {
-				Phase string `json:"phase"`
-			} `json:"active_deployment"`
-			UpdatedAt string `json:"updated_at"`
-		} `json:"apps"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		h.writeError(w, "Failed to parse response", err, http.StatusInternalServerError)
+		h.writeAPIError(w, "Failed to list sites", err)
 		return
 	}
 
 	// Transform to our format
-	sites := make([]SiteResponse, 0, len(result.Apps))
-	for _, app := range result.Apps {
-		urls := []string{}
-		if app.LiveURL != "" {
-			urls = append(urls, app.LiveURL)
-		}
-		if app.DefaultIngress != "" {
-			urls = append(urls, app.DefaultIngress)
-		}
-
-		sites = append(sites, SiteResponse{
-			ID:        app.ID,
-			Name:      app.Spec.Name,
-			Region:    app.Spec.Region,
-			URLs:      urls,
-			Status:    app.ActiveDeployment.Phase,
-			UpdatedAt: app.UpdatedAt,
-		})
+	sites := make([]models.SiteResponse, 0, len(apps))
+	for i := range apps {
+		sites = append(sites, siteResponse(&apps[i]))
 	}
 
-	h.writeJSON(w, map[string]interface{}{"sites": sites})
+	h.writeJSON(w, models.SiteList{Sites: sites})
 }
 
 // createSite creates a new app on DigitalOcean
-func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, token string) {
-	var site Site
+func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client) {
+	var site models.Site
 	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
 		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
 		return
@@ -166,6 +282,154 @@ func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, token
 		return
 	}
 
+	region := defaultRegion
+	if site.Region != "" {
+		if !appRegions[site.Region] {
+			h.writeError(w, fmt.Sprintf("Unknown region '%s'", site.Region), nil, http.StatusBadRequest)
+			return
+		}
+		region = site.Region
+	}
+
+	if site.Service != nil {
+		if err := normalizeService(site.Service); err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := normalizeIngress(site.Ingress); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	if err := validateSiteRuntime(site); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	// Allocate the site's subdomain, checking collisions against every existing site
+	apps, err := do.ListApps(r.Context())
+	if err != nil {
+		h.writeAPIError(w, "Failed to list sites", err)
+		return
+	}
+	for i := range apps {
+		if apps[i].Spec.Name == site.Name {
+			h.writeError(w, fmt.Sprintf("Site '%s' already exists", site.Name), nil, http.StatusConflict)
+			return
+		}
+	}
+	base := baseDomain
+	if site.BaseDomain != "" && site.BaseDomain != baseDomain {
+		if h.baseDomains == nil || !h.baseDomains.Has(site.BaseDomain) {
+			h.writeError(w, fmt.Sprintf("Base domain '%s' is not registered (see GET /base-domains/%s/setup)", site.BaseDomain, site.BaseDomain), nil, http.StatusBadRequest)
+			return
+		}
+		base = site.BaseDomain
+	}
+	domain, err := allocateDomain(apps, site.Name, base, site.RandomSuffix)
+	if errors.Is(err, ErrSubdomainTaken) {
+		h.writeError(w, err.Error()+" (request a random suffix to get a unique subdomain)", nil, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	log.Printf("[API] Allocated %s for site %s", domain, site.Name)
+
+	envs := []map[string]interface{}{
+		{
+			"key":   "OPERATOR_URL",
+			"value": h.operatorURL,
+			"type":  "GENERAL",
+		},
+		{
+			"key":   siteNameEnv,
+			"value": site.Name,
+			"type":  "GENERAL",
+		},
+	}
+	for _, env := range h.siteCredentials(site.Name) {
+		envs = append(envs, envSpec(env))
+	}
+
+	// Apply the template's size, instance count and environment
+	size := defaultSize
+	instances := defaultInstances
+	if site.Template != "" {
+		if h.templates == nil {
+			h.writeError(w, "Templates are not available", nil, http.StatusBadRequest)
+			return
+		}
+		template, ok := h.templates.Get(site.Template)
+		if !ok {
+			h.writeError(w, fmt.Sprintf("Unknown template '%s'", site.Template), nil, http.StatusBadRequest)
+			return
+		}
+		if template.Size != "" {
+			size = template.Size
+		}
+		if template.Instances > 0 {
+			instances = template.Instances
+		}
+		for key, value := range template.Env {
+			// Operator variables can't be overridden
+			if operatorEnv(key) {
+				continue
+			}
+			envs = append(envs, map[string]interface{}{
+				"key":   key,
+				"value": value,
+				"type":  "GENERAL",
+			})
+		}
+		log.Printf("[API] Creating site %s from template %s", site.Name, site.Template)
+	}
+
+	// The site's own settings (from site.properties) override the template's
+	port := defaultPort
+	if site.Port > 0 {
+		port = site.Port
+	}
+	if site.Instances > 0 {
+		instances = site.Instances
+	}
+	if site.Size != "" {
+		size = site.Size
+	}
+	keys := make([]string, 0, len(site.Env))
+	for key := range site.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		envs = setEnvValue(envs, key, site.Env[key])
+	}
+
+	if len(site.Labels) > 0 {
+		envs = append(envs, map[string]interface{}{
+			"key":   labelsEnv,
+			"value": formatLabels(site.Labels),
+			"type":  "GENERAL",
+		})
+	}
+
+	// Temporary sites are deleted by the reaper once they expire
+	if site.TTL != "" {
+		ttl, err := time.ParseDuration(site.TTL)
+		if err != nil || ttl <= 0 || ttl > maxSiteTTL {
+			h.writeError(w, fmt.Sprintf("ttl must be a duration between 1m and %v", maxSiteTTL), nil, http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().Add(ttl).UTC().Format(time.RFC3339)
+		envs = append(envs, map[string]interface{}{
+			"key":   expiresAtEnv,
+			"value": expiresAt,
+			"type":  "GENERAL",
+		})
+		log.Printf("[API] Site %s expires at %s", site.Name, expiresAt)
+	}
+
 	// Set defaults for optional fields
 	image := site.Image
 	if image == "" {
@@ -177,16 +441,50 @@ func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, token
 	}
 
 	// Wait for the tag to be available in the registry
-	log.Printf("[API] Verifying tag %s:%s exists in registry...", image, tag)
-	if err := h.waitForTag(image, tag, token); err != nil {
-		h.writeError(w, "Image tag not available", err, http.StatusNotFound)
+	// A dry run may come before the image is pushed, so it only reports a missing tag
+	var warnings []string
+	if isDryRun(r) {
+		exists, err := h.tagExists(r.Context(), do, image, tag)
+		if err != nil {
+			h.writeAPIError(w, "Failed to list tags", err)
+			return
+		}
+		if !exists {
+			warnings = append(warnings, fmt.Sprintf("image %s:%s isn't in the registry yet", image, tag))
+		}
+	} else {
+		log.Printf("[API] Verifying tag %s:%s exists in registry...", image, tag)
+		if err := h.waitForTag(r.Context(), do, image, tag); err != nil {
+			h.writeErrorCode(w, models.ErrorCodeTagNotFound, "Image tag not available", err, http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := h.validatePlatform(r.Context(), image, tag); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if site.Service != nil && !h.verifyServiceImage(w, r, do, *site.Service) {
 		return
 	}
 
-	// Build domains list - start with default lightspeed.ee domain as PRIMARY
+	// Record the release for cache busting
+	if release := h.releaseID(r.Context(), image, tag); release != "" {
+		envs = append(envs, map[string]interface{}{
+			"key":   releaseEnv,
+			"value": release,
+			"type":  "GENERAL",
+		})
+	}
+
+	// Record what is deployed, for /__lightspeed
+	envs = append(envs, metadataEnvs(tag, site.Commit, time.Now())...)
+
+	// Build domains list - start with the allocated lightspeed.ee domain as PRIMARY
 	domains := []map[string]string{
 		{
-			"domain": site.Name + ".lightspeed.ee",
+			"domain": domain,
 			"type":   "PRIMARY",
 		},
 	}
@@ -198,10 +496,27 @@ func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, token
 		})
 	}
 
+	// The site takes every path its service doesn't, whose rule goes first
+	rules := []map[string]interface{}{
+		{
+			"component": map[string]string{
+				"name": site.Name,
+			},
+			"match": map[string]interface{}{
+				"path": map[string]string{
+					"prefix": "/",
+				},
+			},
+		},
+	}
+	if site.Service != nil {
+		rules = append([]map[string]interface{}{serviceRule(site.Name, *site.Service)}, rules...)
+	}
+
 	// Build app spec using internal defaults
 	spec := map[string]interface{}{
 		"name":   site.Name,
-		"region": defaultRegion,
+		"region": region,
 		"features": []string{
 			"buildpack-stack=ubuntu-22",
 		},
@@ -211,294 +526,399 @@ func (h *SitesHandler) createSite(w http.ResponseWriter, r *http.Request, token
 		},
 		"domains": domains,
 		"ingress": map[string]interface{}{
-			"rules": []map[string]interface{}{
-				{
-					"component": map[string]string{
-						"name": site.Name,
-					},
-					"match": map[string]interface{}{
-						"path": map[string]string{
-							"prefix": "/",
-						},
-					},
-				},
-			},
+			"rules": rules,
 		},
 		"services": []map[string]interface{}{
 			{
 				"name":      site.Name,
-				"http_port": defaultPort,
+				"http_port": port,
 				"image": map[string]interface{}{
 					"registry_type": "DOCR",
 					"registry":      h.defaultRegistry,
 					"repository":    image,
 					"tag":           tag,
+					// Deploys go through the operator so the release ID is recorded
 					"deploy_on_push": map[string]bool{
-						"enabled": true,
-					},
-				},
-				"instance_count":     defaultInstances,
-				"instance_size_slug": defaultSize,
-				"envs": []map[string]interface{}{
-					{
-						"key":   "OPERATOR_URL",
-						"value": h.operatorURL,
-						"type":  "GENERAL",
-					},
-					{
-						"key":   "OPERATOR_TOKEN",
-						"value": h.operatorToken,
-						"type":  "SECRET",
+						"enabled": false,
 					},
 				},
+				"instance_count":     instances,
+				"instance_size_slug": size,
+				"envs":               envs,
 			},
 		},
 	}
-
-	payload := map[string]interface{}{
-		"spec": spec,
+	if site.Service != nil {
+		spec["services"] = append(spec["services"].([]map[string]interface{}), h.serviceSpec(site.Name, *site.Service, size, envs))
+		log.Printf("[API] Site %s runs service %s (%s:%s) at %s", site.Name, site.Service.Name, site.Service.Image, site.Service.Tag, site.Service.Path)
 	}
 
-	body, _ := json.Marshal(payload)
-
-	resp, err := h.doRequest("POST", "/apps", token, body)
-	if err != nil {
-		h.writeError(w, "Failed to create site", err, http.StatusBadGateway)
-		return
+	// Routing rules are applied to the raw form of the spec, as on deploys
+	var createSpec interface{} = spec
+	if len(site.Ingress) > 0 {
+		raw, err := rawSpec(spec)
+		if err == nil {
+			err = setSpecIngress(raw, site.Ingress)
+		}
+		if err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+		createSpec = raw
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		h.forwardError(w, resp)
+	if !h.checkPolicies(w, r, site.Name, nil, createSpec) {
 		return
 	}
 
-	var result struct {
-		App struct {
-			ID             string `json:"id"`
-			DefaultIngress string `json:"default_ingress"`
-			Spec           struct {
-				Name   string `json:"name"`
-				Region string `json:"region"`
-			} `json:"spec"`
-		} `json:"app"`
+	if isDryRun(r) {
+		h.writeDryRun(w, r, models.DryRun{Action: "create", Site: site.Name, Domain: domain, Spec: createSpec, Warnings: warnings})
+		return
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		h.writeError(w, "Failed to parse response", err, http.StatusInternalServerError)
+	app, err := do.CreateApp(r.Context(), createSpec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to create site", err)
 		return
 	}
+	h.notifyDeploy(site.Name, tag, "")
 
-	w.WriteHeader(http.StatusCreated)
-	h.writeJSON(w, SiteResponse{
-		ID:     result.App.ID,
-		Name:   result.App.Spec.Name,
-		Region: result.App.Spec.Region,
+	h.writeJSONStatus(w, http.StatusCreated, models.SiteResponse{
+		ID:        app.ID,
+		Name:      app.Spec.Name,
+		Region:    app.Spec.Region,
+		Domain:    domain,
+		ExpiresAt: app.Env(expiresAtEnv),
 	})
 }
 
 // getSite gets a specific app by name
-func (h *SitesHandler) getSite(w http.ResponseWriter, r *http.Request, token string, name string) {
-	// First, find the app ID by name
-	appID, err := h.findAppByName(token, name)
-	if err != nil {
-		h.writeError(w, "Failed to find site", err, http.StatusBadGateway)
+func (h *SitesHandler) getSite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
 		return
 	}
-	if appID == "" {
-		http.Error(w, `{"error":"Site not found"}`, http.StatusNotFound)
-		return
+
+	response := siteResponse(app)
+	if h.uptime != nil {
+		if uptime, ok := h.uptime.Site(name); ok {
+			response.Serving = uptime.Version
+		}
 	}
+	h.writeJSON(w, response)
+}
 
-	// Get the app details
-	resp, err := h.doRequest("GET", "/apps/"+appID, token, nil)
-	if err != nil {
-		h.writeError(w, "Failed to get site", err, http.StatusBadGateway)
+// deleteSite deletes an app
+// With image=true the site's registry repository is deleted too, and with dns=true
+// the DNS records on the site's domain (so a later site with the name starts clean)
+func (h *SitesHandler) deleteSite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		h.forwardError(w, resp)
+	if isDryRun(r) {
+		h.planDeleteSite(w, r, do, app)
 		return
 	}
 
-	var result struct {
-		App struct {
-			ID              string `json:"id"`
-			Spec            struct {
-				Name   string `json:"name"`
-				Region string `json:"region"`
-			} `json:"spec"`
-			LiveURL         string `json:"live_url"`
-			DefaultIngress  string `json:"default_ingress"`
-			ActiveDeployment struct {
-				Phase string `json:"phase"`
-			} `json:"active_deployment"`
-			UpdatedAt string `json:"updated_at"`
-		} `json:"app"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		h.writeError(w, "Failed to parse response", err, http.StatusInternalServerError)
+	if err := do.DeleteApp(r.Context(), app.ID); err != nil {
+		h.writeAPIError(w, "Failed to delete site", err)
 		return
 	}
+	log.Printf("[API] Deleted site %s", name)
 
-	urls := []string{}
-	if result.App.LiveURL != "" {
-		urls = append(urls, result.App.LiveURL)
+	query := r.URL.Query()
+	if deleteDNS, _ := strconv.ParseBool(query.Get("dns")); deleteDNS {
+		domain := domainOf(app)
+		if err := h.deleteSiteDNS(domain); err != nil {
+			h.writeError(w, fmt.Sprintf("Site deleted, but failed to delete DNS records of %s", domain), err, http.StatusBadGateway)
+			return
+		}
 	}
-	if result.App.DefaultIngress != "" {
-		urls = append(urls, result.App.DefaultIngress)
+
+	if deleteImage, _ := strconv.ParseBool(query.Get("image")); deleteImage {
+		if image := app.Image(); image != nil {
+			registry := image.Registry
+			if registry == "" {
+				registry = h.defaultRegistry
+			}
+			if err := do.DeleteRepository(r.Context(), registry, image.Repository); err != nil && !isNotFound(err) {
+				h.writeError(w, fmt.Sprintf("Site deleted, but failed to delete repository %s", image.Repository), err, http.StatusBadGateway)
+				return
+			}
+			log.Printf("[API] Deleted repository %s of site %s", image.Repository, name)
+		}
 	}
 
-	h.writeJSON(w, SiteResponse{
-		ID:        result.App.ID,
-		Name:      result.App.Spec.Name,
-		Region:    result.App.Spec.Region,
-		URLs:      urls,
-		Status:    result.App.ActiveDeployment.Phase,
-		UpdatedAt: result.App.UpdatedAt,
-	})
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// deleteSite deletes an app
-func (h *SitesHandler) deleteSite(w http.ResponseWriter, r *http.Request, token string, name string) {
-	appID, err := h.findAppByName(token, name)
+// planDeleteSite answers a dry run of deleteSite with what it would delete
+func (h *SitesHandler) planDeleteSite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, app *digitalocean.App) {
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
 	if err != nil {
-		h.writeError(w, "Failed to find site", err, http.StatusBadGateway)
+		h.writeAPIError(w, "Failed to get site spec", err)
 		return
 	}
-	if appID == "" {
-		http.Error(w, `{"error":"Site not found"}`, http.StatusNotFound)
-		return
+	plan := models.DryRun{
+		Action:  "delete",
+		Site:    app.Spec.Name,
+		Current: spec,
+		Deletes: []string{fmt.Sprintf("app %s (%s)", app.Spec.Name, app.ID)},
+	}
+
+	query := r.URL.Query()
+	if deleteDNS, _ := strconv.ParseBool(query.Get("dns")); deleteDNS {
+		domain := domainOf(app)
+		records, err := h.dnsProviderFor(domain).ListRecords(domain)
+		if err != nil {
+			h.writeError(w, fmt.Sprintf("Failed to list DNS records of %s", domain), err, http.StatusBadGateway)
+			return
+		}
+		plan.Deletes = append(plan.Deletes, fmt.Sprintf("%d DNS records of %s", len(records), domain))
+	}
+	if deleteImage, _ := strconv.ParseBool(query.Get("image")); deleteImage {
+		if image := app.Image(); image != nil {
+			plan.Deletes = append(plan.Deletes, fmt.Sprintf("repository %s and its tags", image.Repository))
+		}
 	}
+	h.writeDryRun(w, r, plan)
+}
 
-	resp, err := h.doRequest("DELETE", "/apps/"+appID, token, nil)
+// deleteSiteDNS deletes all DNS records on a site's domain and its subdomains
+func (h *SitesHandler) deleteSiteDNS(domain string) error {
+	provider := h.dnsProviderFor(domain)
+	records, err := provider.ListRecords(domain)
 	if err != nil {
-		h.writeError(w, "Failed to delete site", err, http.StatusBadGateway)
-		return
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		h.forwardError(w, resp)
-		return
+	for _, record := range records {
+		if err := provider.DeleteRecord(record.ID); err != nil {
+			return err
+		}
 	}
+	log.Printf("[API] Deleted %d DNS records of %s", len(records), domain)
+	return nil
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// isNotFound checks if a DigitalOcean API error is a 404
+func isNotFound(err error) bool {
+	var apiErr *digitalocean.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
 }
 
 // deploySite triggers a deployment
-func (h *SitesHandler) deploySite(w http.ResponseWriter, r *http.Request, token string, name string) {
-	appID, err := h.findAppByName(token, name)
-	if err != nil {
-		h.writeError(w, "Failed to find site", err, http.StatusBadGateway)
+// A tag in the body other than the one the site runs pins the site to that tag (e.g. a rollback)
+func (h *SitesHandler) deploySite(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	// The body is optional
+	var deploy models.DeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&deploy); err != nil && err != io.EOF {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
 		return
 	}
-	if appID == "" {
-		http.Error(w, `{"error":"Site not found"}`, http.StatusNotFound)
+
+	if deploy.Service != nil {
+		if err := normalizeService(deploy.Service); err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := normalizeIngress(deploy.Ingress); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
 		return
 	}
 
-	payload := map[string]interface{}{
-		"force_build": true,
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
 	}
-	body, _ := json.Marshal(payload)
 
-	resp, err := h.doRequest("POST", "/apps/"+appID+"/deployments", token, body)
-	if err != nil {
-		h.writeError(w, "Failed to create deployment", err, http.StatusBadGateway)
+	// A new or changed service is checked like the site's image, then applied with the spec update
+	service := deploy.Service
+	if service != nil && sameService(siteService(app), *service) {
+		service = nil
+	}
+	if service != nil && !h.verifyServiceImage(w, r, do, *service) {
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		h.forwardError(w, resp)
-		return
+	// Routing rules are applied with the spec update if they change it (or come with a new service,
+	// whose component they may route)
+	ingress := deploy.Ingress
+	if len(ingress) > 0 && service == nil {
+		changed, err := h.ingressChanged(r, do, app, ingress)
+		if err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+		if !changed {
+			ingress = nil
+		}
+	}
+
+	image := app.Image()
+	tag := deploy.Tag
+	if image != nil {
+		if tag == "" {
+			tag = image.Tag
+		}
+		if tag == "" {
+			tag = "latest"
+		}
+		if err := h.validatePlatform(r.Context(), image.Repository, tag); err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusUnprocessableEntity)
+			return
+		}
 	}
 
-	var result struct {
-		Deployment struct {
-			ID    string `json:"id"`
-			Phase string `json:"phase"`
-		} `json:"deployment"`
+	// A new tag, or new content pushed under the same tag, is deployed by updating the spec
+	// so the release ID changes with it. So are sites created when pushing deployed them, as
+	// each push would deploy them twice until the spec update turns it off.
+	if image != nil {
+		release := h.releaseID(r.Context(), image.Repository, tag)
+		commit := deploy.Commit != "" && deploy.Commit != app.Env(commitEnv)
+		if tag != image.Tag || (release != "" && release != app.Env(releaseEnv)) || commit || service != nil || len(ingress) > 0 || app.DeploysOnPush() {
+			h.pinImageTag(w, r, do, app, tag, release, deploy.Commit, service, ingress)
+			return
+		}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		h.writeError(w, "Failed to parse response", err, http.StatusInternalServerError)
+	// Without a spec change the site is redeployed as it is
+	if isDryRun(r) {
+		spec, err := do.GetAppSpec(r.Context(), app.ID)
+		if err != nil {
+			h.writeAPIError(w, "Failed to get site spec", err)
+			return
+		}
+		h.writeDryRun(w, r, models.DryRun{Action: "deploy", Site: name, Current: spec, Spec: spec})
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	h.writeJSON(w, map[string]interface{}{
-		"deployment_id": result.Deployment.ID,
-		"status":        result.Deployment.Phase,
+	deployment, err := do.CreateDeployment(r.Context(), app.ID, true)
+	if err != nil {
+		h.writeAPIError(w, "Failed to create deployment", err)
+		return
+	}
+	h.notifyDeploy(name, tag, deployment.ID)
+
+	h.writeJSONStatus(w, http.StatusCreated, models.Deployment{
+		DeploymentID: deployment.ID,
+		Status:       deployment.Phase,
+		Tag:          tag,
 	})
 }
 
-// findAppByName finds an app ID by name
-func (h *SitesHandler) findAppByName(token, name string) (string, error) {
-	resp, err := h.doRequest("GET", "/apps", token, nil)
-	if err != nil {
-		return "", err
+// cancelDeployment cancels the in-progress deployment of a site
+func (h *SitesHandler) cancelDeployment(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+	// Find the in-progress (or pending) deployment
+	app, err := do.GetApp(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site", err)
+		return
 	}
 
-	var result struct {
-		Apps []struct {
-			ID   string `json:"id"`
-			Spec struct {
-				Name string `json:"name"`
-			} `json:"spec"`
-		} `json:"apps"`
+	deployment := app.InProgressDeployment
+	if deployment == nil {
+		deployment = app.PendingDeployment
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	if deployment == nil || deployment.ID == "" {
+		h.writeError(w, "No deployment in progress", nil, http.StatusConflict)
+		return
 	}
 
-	for _, app := range result.Apps {
-		if app.Spec.Name == name {
-			return app.ID, nil
-		}
+	if err := do.CancelDeployment(r.Context(), app.ID, deployment.ID); err != nil {
+		h.writeAPIError(w, "Failed to cancel deployment", err)
+		return
 	}
 
-	return "", nil
+	log.Printf("[API] Canceled deployment %s for %s", deployment.ID, name)
+	h.writeJSON(w, models.Deployment{
+		DeploymentID: deployment.ID,
+		Status:       "CANCELED",
+	})
 }
 
-// doRequest makes a request to DigitalOcean API
-func (h *SitesHandler) doRequest(method, path, token string, body []byte) (*http.Response, error) {
-	var bodyReader io.Reader
-	if body != nil {
-		bodyReader = bytes.NewBuffer(body)
-	}
-
-	req, err := http.NewRequest(method, digitalOceanAPI+path, bodyReader)
+// findApp finds an app by name, writing an error response if it can't be found
+func (h *SitesHandler) findApp(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) (*digitalocean.App, bool) {
+	app, err := do.FindAppByName(r.Context(), name)
 	if err != nil {
-		return nil, err
+		h.writeError(w, "Failed to find site", err, http.StatusBadGateway)
+		return nil, false
+	}
+	if app == nil {
+		h.writeErrorCode(w, models.ErrorCodeSiteNotFound, "Site not found", nil, http.StatusNotFound)
+		return nil, false
 	}
+	return app, true
+}
 
-	req.Header.Set("Authorization", token)
-	req.Header.Set("Content-Type", "application/json")
+// siteResponse converts a DigitalOcean app to a site response
+func siteResponse(app *digitalocean.App) models.SiteResponse {
+	updatedAt := ""
+	if !app.UpdatedAt.IsZero() {
+		updatedAt = app.UpdatedAt.Format(time.RFC3339)
+	}
+
+	response := models.SiteResponse{
+		ID:        app.ID,
+		Name:      app.Spec.Name,
+		Region:    app.Spec.Region,
+		URLs:      app.URLs(),
+		Status:    app.ActivePhase(),
+		UpdatedAt: updatedAt,
+		Domain:    domainOf(app),
+		ExpiresAt: app.Env(expiresAtEnv),
+		Release:   app.Env(releaseEnv),
+		Instances: app.Instances(),
+		Size:      app.Size(),
+		Labels:    parseLabels(app.Env(labelsEnv)),
+
+		Commit:     app.Env(commitEnv),
+		DeployedAt: app.Env(deployedAtEnv),
+	}
+	if app.ActiveDeployment != nil {
+		response.DeploymentID = app.ActiveDeployment.ID
+	}
+	if image := app.Image(); image != nil {
+		response.Tag = image.Tag
+	}
+	response.Service = siteService(app)
+	if deployment := app.InProgressDeployment; deployment != nil && deployment.ID != "" {
+		response.InProgress = &models.Deployment{
+			DeploymentID: deployment.ID,
+			Status:       deployment.Phase,
+		}
+	}
 
-	client := &http.Client{}
-	return client.Do(req)
+	return response
 }
 
 // writeJSON writes a JSON response
 func (h *SitesHandler) writeJSON(w http.ResponseWriter, data interface{}) {
+	h.writeJSONStatus(w, http.StatusOK, data)
+}
+
+// writeJSONStatus writes a JSON response with a status code
+// The content type is set before the status, which sends the headers
+func (h *SitesHandler) writeJSONStatus(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
 // writeError writes an error response
 func (h *SitesHandler) writeError(w http.ResponseWriter, message string, err error, status int) {
+	h.writeErrorCode(w, models.ErrorCodeForStatus(status), message, err, status)
+}
+
+// writeErrorCode writes a JSON error response with a specific error code
+func (h *SitesHandler) writeErrorCode(w http.ResponseWriter, code, message string, err error, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	errMsg := message
@@ -506,58 +926,85 @@ func (h *SitesHandler) writeError(w http.ResponseWriter, message string, err err
 		errMsg = fmt.Sprintf("%s: %v", message, err)
 		log.Printf("[API] Error: %s", errMsg)
 	}
-	json.NewEncoder(w).Encode(map[string]string{"error": errMsg})
+	json.NewEncoder(w).Encode(models.ErrorResponse{Error: errMsg, Code: code})
 }
 
-// forwardError forwards an error response from DigitalOcean
-func (h *SitesHandler) forwardError(w http.ResponseWriter, resp *http.Response) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+// writeAPIError writes an error response from DigitalOcean with its status and message,
+// or a gateway error if DigitalOcean couldn't be reached
+func (h *SitesHandler) writeAPIError(w http.ResponseWriter, message string, err error) {
+	var apiErr *digitalocean.APIError
+	if errors.As(err, &apiErr) {
+		log.Printf("[API] Error: %s: %v", message, err)
+		h.writeErrorCode(w, providerErrorCode(apiErr.StatusCode), message, errors.New(apiErr.Message()), apiErr.StatusCode)
+		return
+	}
+	h.writeError(w, message, err, http.StatusBadGateway)
 }
 
-// tagExists checks if an image tag exists in the registry
-func (h *SitesHandler) tagExists(repository, tag, token string) (bool, error) {
-	url := fmt.Sprintf("/registry/%s/repositories/%s/tags", h.defaultRegistry, repository)
+// providerErrorCode returns the error code of a DigitalOcean error status
+// Authentication failures are the operator's token, not the user's, so they get their own code
+func providerErrorCode(status int) string {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return models.ErrorCodeProviderAuth
+	}
+	return models.ErrorCodeForStatus(status)
+}
 
-	resp, err := h.doRequest("GET", url, token, nil)
+// tagExists checks if an image tag exists in the registry
+func (h *SitesHandler) tagExists(ctx context.Context, do *digitalocean.Client, repository, tag string) (bool, error) {
+	tags, err := do.ListTags(ctx, h.defaultRegistry, repository)
 	if err != nil {
+		var apiErr *digitalocean.APIError
+		if errors.As(err, &apiErr) {
+			// Repository not found (yet)
+			return false, nil
+		}
 		return false, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false, nil
+	// Check if our tag is in the list
+	for _, t := range tags {
+		if t.Tag == tag {
+			return true, nil
+		}
 	}
 
-	// Parse response to check if our tag is in the list
-	var result struct {
-		Tags []struct {
-			Tag string `json:"tag"`
-		} `json:"tags"`
+	return false, nil
+}
+
+// validatePlatform checks that an image can run on App Platform
+// Images that can't be inspected are allowed through; App Platform reports those failures itself
+func (h *SitesHandler) validatePlatform(ctx context.Context, repository, tag string) error {
+	if h.images == nil {
+		return nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false, err
+	manifest, err := h.images.InspectImage(ctx, repository, tag)
+	if err != nil {
+		log.Printf("[API] Could not inspect %s:%s, skipping platform check: %v", repository, tag, err)
+		return nil
 	}
 
-	// Check if our tag is in the list
-	for _, t := range result.Tags {
-		if t.Tag == tag {
-			return true, nil
-		}
+	if manifest.Supports(targetPlatform) {
+		return nil
 	}
 
-	return false, nil
+	platforms := make([]string, 0, len(manifest.Platforms))
+	for _, p := range manifest.Platforms {
+		platforms = append(platforms, p.String())
+	}
+	log.Printf("[API] Rejected %s:%s: platforms %v don't include %s", repository, tag, platforms, targetPlatform)
+	return fmt.Errorf("image %s:%s is built for %s but App Platform runs %s; rebuild with --platform %s",
+		repository, tag, strings.Join(platforms, ", "), targetPlatform, targetPlatform)
 }
 
 // waitForTag waits for a tag to appear in the registry (with retries)
-func (h *SitesHandler) waitForTag(repository, tag, token string) error {
+func (h *SitesHandler) waitForTag(ctx context.Context, do *digitalocean.Client, repository, tag string) error {
 	maxRetries := 5
 	retryDelay := 2 * time.Second
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		exists, err := h.tagExists(repository, tag, token)
+		exists, err := h.tagExists(ctx, do, repository, tag)
 		if err != nil {
 			log.Printf("[API] Error checking tag existence (attempt %d/%d): %v", attempt, maxRetries, err)
 		} else if exists {
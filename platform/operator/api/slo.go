@@ -0,0 +1,87 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// DefaultSLOTarget is the availability target sites are measured against unless the
+// request sets one
+const DefaultSLOTarget = 99.9
+
+// sloAtRisk is the percentage of the error budget left below which a site's SLO is at risk
+const sloAtRisk = 25.0
+
+// siteSLO returns a site's availability this month against its SLO target (?target=99.95)
+// Downtime is counted from failed uptime checks, each standing for one check interval.
+func (h *SitesHandler) siteSLO(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	target := h.sloTarget
+	if value := r.URL.Query().Get("target"); value != "" {
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil || t <= 0 || t >= 100 {
+			h.writeError(w, "target must be a percentage between 0 and 100", nil, http.StatusBadRequest)
+			return
+		}
+		target = t
+	}
+
+	if h.uptime == nil {
+		h.writeError(w, "Uptime monitoring is not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	uptime, _ := h.uptime.Site(app.Spec.Name)
+	uptime.Site = app.Spec.Name
+	h.writeJSON(w, computeSLO(uptime, target, h.uptime.interval, time.Now()))
+}
+
+// computeSLO measures a site's checks in the current month against an availability target
+func computeSLO(site models.SiteUptime, target float64, interval time.Duration, now time.Time) models.SiteSLO {
+	now = now.UTC()
+	month := now.Format("2006-01")
+
+	slo := models.SiteSLO{Site: site.Site, Month: month, Target: target, Availability: -1}
+	for _, day := range site.Days {
+		if !strings.HasPrefix(day.Date, month) {
+			continue
+		}
+		slo.Checks += day.Checks
+		slo.FailedChecks += day.Checks - day.Up
+	}
+	if slo.Checks > 0 {
+		slo.Availability = round(float64(slo.Checks-slo.FailedChecks)*100/float64(slo.Checks), 3)
+	}
+
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthMinutes := start.AddDate(0, 1, 0).Sub(start).Minutes()
+	slo.BudgetMinutes = round(monthMinutes*(100-target)/100, 1)
+	slo.DowntimeMinutes = round(float64(slo.FailedChecks)*interval.Minutes(), 1)
+	slo.BudgetRemaining = round(math.Max(0, 100-slo.DowntimeMinutes*100/slo.BudgetMinutes), 1)
+
+	switch {
+	case slo.BudgetRemaining <= 0:
+		slo.Status = "exhausted"
+	case slo.BudgetRemaining < sloAtRisk:
+		slo.Status = "at_risk"
+	default:
+		slo.Status = "ok"
+	}
+	return slo
+}
+
+// round rounds a number to a number of decimals
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
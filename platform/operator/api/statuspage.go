@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	models "lightspeed/core/lib/api"
+)
+
+// StatusPageHandler serves public status pages of tenants at /status/{tenant}
+// A tenant is a registered base domain; its page lists the sites on the domain with
+// their uptime from the uptime monitor and recent incidents. Tenants can serve the page
+// on their own domain.
+type StatusPageHandler struct {
+	monitor     *UptimeMonitor
+	incidents   *IncidentLog
+	baseDomains *BaseDomainsHandler
+}
+
+// statusPageIncidents is how far back incidents are shown on status pages
+const statusPageIncidents = 30 * 24 * time.Hour
+
+// NewStatusPageHandler creates a status page handler
+func NewStatusPageHandler(monitor *UptimeMonitor, incidents *IncidentLog, baseDomains *BaseDomainsHandler) *StatusPageHandler {
+	return &StatusPageHandler{
+		monitor:     monitor,
+		incidents:   incidents,
+		baseDomains: baseDomains,
+	}
+}
+
+// ServeHTTP serves a tenant's status page, as JSON with ?format=json
+func (h *StatusPageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/status"), "/"))
+	if tenant == "" || !h.baseDomains.Has(tenant) {
+		http.Error(w, "Status page not found", http.StatusNotFound)
+		return
+	}
+	h.serveTenant(w, r, tenant)
+}
+
+// CustomDomains serves status pages on the tenants' status domains, passing other requests to next
+func (h *StatusPageHandler) CustomDomains(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		tenant := h.baseDomains.TenantForStatusDomain(strings.ToLower(host))
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path != "/" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		h.serveTenant(w, r, tenant)
+	})
+}
+
+// serveTenant renders a tenant's status page
+func (h *StatusPageHandler) serveTenant(w http.ResponseWriter, r *http.Request, tenant string) {
+	page := h.statusPage(tenant, time.Now())
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if err := statusPageTemplate.Execute(w, page); err != nil {
+		log.Printf("[STATUS] Failed to render status page of %s: %v", tenant, err)
+	}
+}
+
+// statusPage builds a tenant's status page from the uptime of the sites on its domain
+func (h *StatusPageHandler) statusPage(tenant string, now time.Time) models.StatusPage {
+	page := models.StatusPage{Tenant: tenant, Status: "operational", Sites: []models.StatusSite{}, Incidents: []models.Incident{}}
+
+	down := 0
+	latest := ""
+	sites := map[string]bool{}
+	for _, site := range h.monitor.Sites() {
+		if !onDomain(site, tenant) {
+			continue
+		}
+		sites[site.Site] = true
+
+		page.Sites = append(page.Sites, models.StatusSite{
+			Name:      site.Site,
+			Domain:    site.Domain,
+			Up:        site.Up,
+			Uptime7d:  availability(site.Days, 7, now),
+			Uptime30d: availability(site.Days, 30, now),
+			Uptime90d: availability(site.Days, 90, now),
+			Days:      site.Days,
+		})
+		if !site.Up {
+			down++
+		}
+		if site.LastChecked > latest {
+			latest = site.LastChecked
+		}
+	}
+
+	switch {
+	case down > 0 && down == len(page.Sites):
+		page.Status = "down"
+	case down > 0:
+		page.Status = "degraded"
+	}
+	page.UpdatedAt = latest
+
+	since := now.Add(-statusPageIncidents).UTC().Format(time.RFC3339)
+	for _, incident := range h.incidents.List("", "") {
+		if sites[incident.Site] && (incident.Status == "open" || incident.EndedAt >= since) {
+			page.Incidents = append(page.Incidents, incident)
+		}
+	}
+	return page
+}
+
+// onDomain checks if any of a site's domains is the tenant domain or under it
+func onDomain(site models.SiteUptime, tenant string) bool {
+	for _, domain := range append([]string{site.Domain}, site.Domains...) {
+		if domain == tenant || strings.HasSuffix(domain, "."+tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatUptime formats an uptime percentage for the status page
+func formatUptime(percent float64) string {
+	if percent < 0 {
+		return "–"
+	}
+	return fmt.Sprintf("%.2f%%", percent)
+}
+
+// dayClass returns the CSS class of a day's bar on the status page
+func dayClass(day models.UptimeDay) string {
+	switch {
+	case day.Checks == 0:
+		return "none"
+	case day.Up == day.Checks:
+		return "up"
+	case day.Up*100 >= day.Checks*99:
+		return "partial"
+	default:
+		return "down"
+	}
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"uptime":   formatUptime,
+	"dayClass": dayClass,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.Tenant}} status</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 760px; margin: 40px auto; padding: 0 16px; color: #1f2933; }
+h1 { font-size: 1.5em; }
+.banner { padding: 16px; border-radius: 6px; color: #fff; font-weight: 600; margin-bottom: 32px; }
+.banner.operational { background: #2f9e44; }
+.banner.degraded { background: #e67700; }
+.banner.down { background: #c92a2a; }
+.site { border: 1px solid #e4e7eb; border-radius: 6px; padding: 16px; margin-bottom: 16px; }
+.site header { display: flex; justify-content: space-between; }
+.state.up { color: #2f9e44; }
+.state.down { color: #c92a2a; }
+.bars { display: flex; gap: 2px; margin: 12px 0 8px; }
+.bars span { flex: 1; height: 28px; border-radius: 2px; }
+.bars .up { background: #2f9e44; }
+.bars .partial { background: #e67700; }
+.bars .down { background: #c92a2a; }
+.bars .none { background: #e4e7eb; }
+.uptime { color: #616e7c; font-size: 0.9em; }
+.incident { border-left: 4px solid #c92a2a; padding: 4px 12px; margin-bottom: 12px; }
+.incident.resolved { border-color: #e4e7eb; }
+.incident p { margin: 4px 0; color: #616e7c; font-size: 0.9em; }
+footer { color: #9aa5b1; font-size: 0.8em; margin-top: 32px; }
+</style>
+</head>
+<body>
+<h1>{{.Tenant}}</h1>
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else if eq .Status "degraded"}}Some sites are down{{else}}All sites are down{{end}}</div>
+{{range .Sites}}
+<div class="site">
+<header><strong>{{.Domain}}</strong><span class="state {{if .Up}}up{{else}}down{{end}}">{{if .Up}}Operational{{else}}Down{{end}}</span></header>
+<div class="bars">{{range .Days}}<span class="{{dayClass .}}" title="{{.Date}}: {{.Up}}/{{.Checks}} checks passed"></span>{{end}}</div>
+<div class="uptime">7 days {{uptime .Uptime7d}} · 30 days {{uptime .Uptime30d}} · 90 days {{uptime .Uptime90d}}</div>
+</div>
+{{else}}
+<p>No sites are monitored yet.</p>
+{{end}}
+<h2>Incidents</h2>
+{{range .Incidents}}
+<div class="incident {{.Status}}">
+<strong>{{.Domain}} {{if eq .Status "open"}}is down{{else}}was down{{end}}</strong>
+<p>{{.StartedAt}}{{if .EndedAt}} – {{.EndedAt}}{{else}} – ongoing{{end}}</p>
+<p>{{.Cause}}</p>
+</div>
+{{else}}
+<p>No incidents in the last 30 days.</p>
+{{end}}
+<footer>{{if .UpdatedAt}}Last checked {{.UpdatedAt}} · {{end}}Powered by Lightspeed</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"lightspeed/core/lib/digitalocean"
+)
+
+// baseDomain is the domain site subdomains are allocated under unless a tenant base domain is used
+const baseDomain = "lightspeed.ee"
+
+// subdomainPattern matches a valid DNS label
+var subdomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// reservedSubdomains are used by the platform itself and can't be allocated to sites
+var reservedSubdomains = map[string]bool{
+	"www": true, "api": true, "registry": true, "operator": true, "admin": true,
+	"app": true, "apps": true, "dashboard": true, "console": true, "docs": true,
+	"status": true, "mail": true, "smtp": true, "imap": true, "ftp": true,
+	"ns1": true, "ns2": true, "cdn": true, "static": true, "assets": true,
+	"auth": true, "login": true, "billing": true, "support": true, "blog": true,
+}
+
+// ErrSubdomainTaken is returned when a subdomain is in use and no suffix was requested
+var ErrSubdomainTaken = errors.New("subdomain is already taken")
+
+// validateSubdomain checks a name can be used as a site subdomain under base
+// Reserved names only apply to lightspeed.ee; tenants own their base domains
+func validateSubdomain(name, base string) error {
+	if !subdomainPattern.MatchString(name) {
+		return fmt.Errorf("'%s' is not a valid subdomain: use 1-63 lowercase letters, digits and dashes, not starting or ending with a dash", name)
+	}
+	if base == baseDomain && reservedSubdomains[name] {
+		return fmt.Errorf("'%s' is reserved", name)
+	}
+	return nil
+}
+
+// domainOf returns an app's primary domain
+// Falls back to name.lightspeed.ee for apps created before subdomains were allocated
+func domainOf(app *digitalocean.App) string {
+	if domain := app.PrimaryDomain(); domain != "" {
+		return domain
+	}
+	return app.Spec.Name + "." + baseDomain
+}
+
+// allocateDomain picks a free domain for a site under base
+// A taken name is an error unless randomSuffix is set, in which case a random
+// suffix is appended (myapp-x7f2) until a free subdomain is found
+func allocateDomain(apps []digitalocean.App, name, base string, randomSuffix bool) (string, error) {
+	if err := validateSubdomain(name, base); err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(apps))
+	for i := range apps {
+		taken[domainOf(&apps[i])] = true
+	}
+
+	if domain := name + "." + base; !taken[domain] {
+		return domain, nil
+	}
+	if !randomSuffix {
+		return "", fmt.Errorf("%w: %s.%s", ErrSubdomainTaken, name, base)
+	}
+
+	// Keep room for the suffix within the 63 character label limit
+	prefix := name
+	if len(prefix) > 58 {
+		prefix = strings.TrimSuffix(prefix[:58], "-")
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		b := make([]byte, 2)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		candidate := prefix + "-" + hex.EncodeToString(b) + "." + base
+		if !taken[candidate] {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no free suffix found for %s", ErrSubdomainTaken, name)
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Limits on the synthetic checks of a site, so a check run fits in a monitoring interval
+const (
+	maxSyntheticChecks = 5
+	maxSyntheticSteps  = 10
+)
+
+// syntheticBodyLimit is how much of a response body is searched for expected text
+const syntheticBodyLimit = 1 << 20
+
+// SyntheticChecks stores the synthetic checks of sites and runs them for the uptime
+// monitor. A site failing a check counts as down, so "200 but broken" sites get
+// incidents and use up their error budget like unreachable ones.
+type SyntheticChecks struct {
+	path string // JSON file checks are persisted to (empty for in-memory only)
+
+	mu      sync.RWMutex
+	checks  map[string][]models.SyntheticCheck  // By site
+	results map[string][]models.SyntheticResult // Last run by site
+}
+
+// syntheticError is a failed synthetic check
+type syntheticError struct {
+	check string
+	step  int
+	err   error
+}
+
+func (e *syntheticError) Error() string {
+	return fmt.Sprintf("check '%s' failed at step %d: %v", e.check, e.step, e.err)
+}
+
+// NewSyntheticChecks creates a synthetic check store, loading saved checks from path if set
+func NewSyntheticChecks(path string) (*SyntheticChecks, error) {
+	s := &SyntheticChecks{
+		path:    path,
+		checks:  make(map[string][]models.SyntheticCheck),
+		results: make(map[string][]models.SyntheticResult),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.SiteChecksList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, site := range list.Sites {
+		s.checks[site.Site] = site.Checks
+	}
+	log.Printf("[SYNTHETIC] Loaded checks of %d sites from %s", len(s.checks), path)
+
+	return s, nil
+}
+
+// Get returns a site's checks and the results of their last run
+func (s *SyntheticChecks) Get(site string) models.SiteChecks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return models.SiteChecks{
+		Site:    site,
+		Checks:  append([]models.SyntheticCheck{}, s.checks[site]...),
+		Results: append([]models.SyntheticResult(nil), s.results[site]...),
+	}
+}
+
+// Set replaces a site's checks (removing them if empty)
+func (s *SyntheticChecks) Set(site string, checks []models.SyntheticCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(checks) == 0 {
+		delete(s.checks, site)
+	} else {
+		s.checks[site] = checks
+	}
+	delete(s.results, site)
+	return s.save()
+}
+
+// Run runs a site's checks against its base URL and records the results
+// Returns the first failure, or nil if every check passed (or there are none)
+func (s *SyntheticChecks) Run(ctx context.Context, site, baseURL string) error {
+	s.mu.RLock()
+	checks := s.checks[site]
+	s.mu.RUnlock()
+	if len(checks) == 0 {
+		return nil
+	}
+
+	var failure error
+	results := make([]models.SyntheticResult, 0, len(checks))
+	for _, check := range checks {
+		result, err := runSyntheticCheck(ctx, baseURL, check)
+		results = append(results, result)
+		if err != nil && failure == nil {
+			failure = err
+		}
+	}
+
+	s.mu.Lock()
+	// Checks may have been replaced while running
+	if _, ok := s.checks[site]; ok {
+		s.results[site] = results
+	}
+	s.mu.Unlock()
+
+	return failure
+}
+
+// Prune drops the checks of sites that no longer exist
+func (s *SyntheticChecks) Prune(existing map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := false
+	for site := range s.checks {
+		if !existing[site] {
+			delete(s.checks, site)
+			delete(s.results, site)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return
+	}
+	if err := s.save(); err != nil {
+		log.Printf("[SYNTHETIC] Failed to save checks: %v", err)
+	}
+}
+
+// runSyntheticCheck runs the steps of a check in order with a shared cookie jar
+func runSyntheticCheck(ctx context.Context, baseURL string, check models.SyntheticCheck) (models.SyntheticResult, error) {
+	start := time.Now()
+	result := models.SyntheticResult{Name: check.Name, Passed: true}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Timeout: uptimeTimeout, Jar: jar}
+
+	for i, step := range check.Steps {
+		if err := runSyntheticStep(ctx, client, baseURL, step); err != nil {
+			result.Passed = false
+			result.Step = i + 1
+			result.Error = err.Error()
+			result.DurationMs = time.Since(start).Milliseconds()
+			result.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+			return result, &syntheticError{check: check.Name, step: i + 1, err: err}
+		}
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+	return result, nil
+}
+
+// runSyntheticStep sends a step's request and checks its response
+func runSyntheticStep(ctx context.Context, client *http.Client, baseURL string, step models.SyntheticStep) error {
+	var body io.Reader
+	if step.Method == http.MethodPost {
+		form := url.Values{}
+		for key, value := range step.Form {
+			form.Set(key, value)
+		}
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, step.Method, baseURL+step.Path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Synthetic/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case step.Status != 0 && resp.StatusCode != step.Status:
+		return fmt.Errorf("%s %s returned HTTP %d, expected %d", step.Method, step.Path, resp.StatusCode, step.Status)
+	case step.Status == 0 && resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%s %s returned HTTP %d", step.Method, step.Path, resp.StatusCode)
+	}
+
+	if step.Contains == "" {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, syntheticBodyLimit))
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", step.Method, step.Path, err)
+	}
+	if !strings.Contains(string(data), step.Contains) {
+		return fmt.Errorf("%s %s doesn't contain %q", step.Method, step.Path, step.Contains)
+	}
+	return nil
+}
+
+// validateSyntheticChecks checks the checks of a site before they're saved
+func validateSyntheticChecks(checks []models.SyntheticCheck) error {
+	if len(checks) > maxSyntheticChecks {
+		return fmt.Errorf("at most %d checks per site", maxSyntheticChecks)
+	}
+
+	names := make(map[string]bool, len(checks))
+	for i := range checks {
+		check := &checks[i]
+		if check.Name == "" {
+			return fmt.Errorf("check %d has no name", i+1)
+		}
+		if names[check.Name] {
+			return fmt.Errorf("duplicate check '%s'", check.Name)
+		}
+		names[check.Name] = true
+
+		if len(check.Steps) == 0 || len(check.Steps) > maxSyntheticSteps {
+			return fmt.Errorf("check '%s' must have 1-%d steps", check.Name, maxSyntheticSteps)
+		}
+		for j := range check.Steps {
+			step := &check.Steps[j]
+			step.Method = strings.ToUpper(step.Method)
+			if step.Method == "" {
+				step.Method = http.MethodGet
+			}
+			if step.Method != http.MethodGet && step.Method != http.MethodPost {
+				return fmt.Errorf("check '%s' step %d: method must be GET or POST", check.Name, j+1)
+			}
+			if !strings.HasPrefix(step.Path, "/") {
+				return fmt.Errorf("check '%s' step %d: path must start with /", check.Name, j+1)
+			}
+			if len(step.Form) > 0 && step.Method != http.MethodPost {
+				return fmt.Errorf("check '%s' step %d: form is only sent with POST", check.Name, j+1)
+			}
+			if step.Status != 0 && (step.Status < 100 || step.Status > 599) {
+				return fmt.Errorf("check '%s' step %d: invalid status %d", check.Name, j+1, step.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// save writes all checks to the checks file (caller must hold the lock)
+func (s *SyntheticChecks) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	list := models.SiteChecksList{Sites: make([]models.SiteChecks, 0, len(s.checks))}
+	for site, checks := range s.checks {
+		list.Sites = append(list.Sites, models.SiteChecks{Site: site, Checks: checks})
+	}
+	sort.Slice(list.Sites, func(i, j int) bool { return list.Sites[i].Site < list.Sites[j].Site })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// serveSiteChecks routes /sites/{name}/checks requests
+func (h *SitesHandler) serveSiteChecks(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	if h.synthetic == nil {
+		h.writeError(w, "Synthetic checks are not enabled", nil, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		h.writeJSON(w, h.synthetic.Get(app.Spec.Name))
+	case http.MethodPut:
+		h.setSiteChecks(w, r, do, name)
+	case http.MethodDelete:
+		app, ok := h.findApp(w, r, do, name)
+		if !ok {
+			return
+		}
+		if err := h.synthetic.Set(app.Spec.Name, nil); err != nil {
+			h.writeError(w, "Failed to save checks", err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[SYNTHETIC] Removed checks of %s", app.Spec.Name)
+		h.writeJSON(w, h.synthetic.Get(app.Spec.Name))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setSiteChecks replaces a site's synthetic checks
+func (h *SitesHandler) setSiteChecks(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	var update models.SiteChecks
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if err := validateSyntheticChecks(update.Checks); err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	if err := h.synthetic.Set(app.Spec.Name, update.Checks); err != nil {
+		h.writeError(w, "Failed to save checks", err, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[SYNTHETIC] Set %d checks of %s", len(update.Checks), app.Spec.Name)
+
+	h.writeJSON(w, h.synthetic.Get(app.Spec.Name))
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// siteTags lists the tags of a site's image repository, newest first
+func (h *SitesHandler) siteTags(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	image := app.Image()
+	if image == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' doesn't run an image", name), nil, http.StatusBadRequest)
+		return
+	}
+
+	tags, err := do.ListTags(r.Context(), h.defaultRegistry, image.Repository)
+	if err != nil {
+		h.writeAPIError(w, "Failed to list tags", err)
+		return
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].UpdatedAt.After(tags[j].UpdatedAt)
+	})
+
+	list := models.TagList{
+		Repository: image.Repository,
+		Current:    image.Tag,
+		Tags:       make([]models.ImageTag, 0, len(tags)),
+	}
+	for _, tag := range tags {
+		imageTag := models.ImageTag{Tag: tag.Tag}
+		if !tag.UpdatedAt.IsZero() {
+			imageTag.UpdatedAt = tag.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		list.Tags = append(list.Tags, imageTag)
+	}
+
+	h.writeJSON(w, list)
+}
+
+// pinImageTag updates a site's spec to run a tag of its image, which redeploys it
+// The full spec is read back and only the image tag, release and deploy metadata changed (and
+// the site's credentials refreshed), so nothing else in the app is reset (secrets are sent
+// back encrypted, as DigitalOcean returned them)
+// With a service, the service deployed alongside the site is added or updated in the same spec update,
+// and with ingress rules, the site's routing is replaced
+func (h *SitesHandler) pinImageTag(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, app *digitalocean.App, tag, release, commit string, service *models.SiteService, ingress []models.IngressRule) {
+	repository := app.Image().Repository
+	var warnings []string
+	if tag != app.Image().Tag {
+		exists, err := h.tagExists(r.Context(), do, repository, tag)
+		if err != nil {
+			h.writeAPIError(w, "Failed to list tags", err)
+			return
+		}
+		// A dry run may come before the image is pushed, so it only reports a missing tag
+		if !exists && isDryRun(r) {
+			warnings = append(warnings, fmt.Sprintf("image %s:%s isn't in the registry yet", repository, tag))
+		} else if !exists {
+			h.writeErrorCode(w, models.ErrorCodeTagNotFound, fmt.Sprintf("Tag %s:%s not found in registry", repository, tag), nil, http.StatusNotFound)
+			return
+		}
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	var current interface{}
+	if isDryRun(r) {
+		current = redactSpec(spec)
+	}
+	if !setSpecTag(spec, tag) {
+		h.writeError(w, "Site spec has no image to pin", nil, http.StatusInternalServerError)
+		return
+	}
+	if release != "" {
+		updateSpecEnvs(spec, models.EnvUpdate{Set: []models.EnvVar{{Key: releaseEnv, Value: release, Type: "GENERAL"}}})
+	}
+	updateSpecEnvs(spec, deployMetadata(tag, commit, time.Now()))
+	updateSpecEnvs(spec, h.credentialsUpdate(app.Spec.Name))
+	if service != nil {
+		if err := h.setSpecService(spec, *service); err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(ingress) > 0 {
+		if err := setSpecIngress(spec, ingress); err != nil {
+			h.writeError(w, err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !h.checkPolicies(w, r, app.Spec.Name, app, spec) {
+		return
+	}
+
+	if isDryRun(r) {
+		h.writeDryRun(w, r, models.DryRun{Action: "update", Site: app.Spec.Name, Current: current, Spec: spec, Warnings: warnings})
+		return
+	}
+
+	updated, err := do.UpdateApp(r.Context(), app.ID, spec)
+	if err != nil {
+		h.writeAPIError(w, "Failed to update site", err)
+		return
+	}
+
+	// Updating the spec queues a deployment
+	deployment := models.Deployment{Status: "PENDING_DEPLOY", Tag: tag}
+	if pending := updated.PendingDeployment; pending != nil && pending.ID != "" {
+		deployment.DeploymentID = pending.ID
+		deployment.Status = pending.Phase
+	} else if inProgress := updated.InProgressDeployment; inProgress != nil && inProgress.ID != "" {
+		deployment.DeploymentID = inProgress.ID
+		deployment.Status = inProgress.Phase
+	}
+
+	log.Printf("[API] Pinned %s to %s:%s (release %s)", app.Spec.Name, repository, tag, release)
+	h.notifyDeploy(app.Spec.Name, tag, deployment.DeploymentID)
+	h.writeJSONStatus(w, http.StatusCreated, deployment)
+}
+
+// setSpecTag sets the image tag of the site component, and of its queue worker, in a raw app spec
+// A service deployed alongside the site keeps its own tag.
+// Deploy on push is turned off, since deploys go through the operator to record the release
+// Returns false if the site doesn't run an image
+func setSpecTag(spec map[string]interface{}, tag string) bool {
+	image, ok := siteSpecService(spec)["image"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	image["tag"] = tag
+	image["deploy_on_push"] = map[string]interface{}{"enabled": false}
+
+	// The queue worker runs the site's image, so it's deployed at the same tag
+	if worker, ok := specWorker(spec)["image"].(map[string]interface{}); ok {
+		worker["tag"] = tag
+		worker["deploy_on_push"] = map[string]interface{}{"enabled": false}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	models "lightspeed/core/lib/api"
+)
+
+// templateNamePattern restricts template names to URL- and property-safe values
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// TemplatesHandler handles /templates endpoints
+// Anyone can list and read templates; registering and deleting them requires the admin token
+type TemplatesHandler struct {
+	path string // JSON file templates are persisted to (empty for in-memory only)
+	auth *AuthHandler
+
+	mu        sync.RWMutex
+	templates map[string]models.Template
+}
+
+// NewTemplatesHandler creates a templates handler, loading saved templates from path if set
+func NewTemplatesHandler(path string, auth *AuthHandler) (*TemplatesHandler, error) {
+	h := &TemplatesHandler{
+		path:      path,
+		auth:      auth,
+		templates: make(map[string]models.Template),
+	}
+
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.TemplateList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, t := range list.Templates {
+		h.templates[t.Name] = t
+	}
+	log.Printf("[API] Loaded %d templates from %s", len(h.templates), path)
+
+	return h, nil
+}
+
+// Get gets a template by name
+func (h *TemplatesHandler) Get(name string) (models.Template, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	t, ok := h.templates[name]
+	return t, ok
+}
+
+// List returns all templates sorted by name
+func (h *TemplatesHandler) List() []models.Template {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := make([]models.Template, 0, len(h.templates))
+	for _, t := range h.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ServeHTTP routes requests to appropriate handlers
+func (h *TemplatesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/templates"), "/")
+
+	log.Printf("[API] %s /templates/%s", r.Method, name)
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, models.TemplateList{Templates: h.List()})
+	case name == "" && r.Method == http.MethodPost:
+		h.registerTemplate(w, r)
+	case r.Method == http.MethodGet:
+		t, ok := h.Get(name)
+		if !ok {
+			h.writeError(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, t)
+	case r.Method == http.MethodDelete:
+		h.deleteTemplate(w, r, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// registerTemplate creates or replaces a template
+func (h *TemplatesHandler) registerTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.auth.Admin(r) {
+		h.writeError(w, "Admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	var t models.Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		h.writeError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !templateNamePattern.MatchString(t.Name) {
+		h.writeError(w, "name is required and may only contain lowercase letters, digits and dashes", http.StatusBadRequest)
+		return
+	}
+	if t.Instances < 0 {
+		h.writeError(w, "instances must be positive", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	_, existed := h.templates[t.Name]
+	h.templates[t.Name] = t
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save templates: %v", err)
+		h.writeError(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Registered template %s", t.Name)
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	h.writeJSON(w, status, t)
+}
+
+// deleteTemplate removes a template
+func (h *TemplatesHandler) deleteTemplate(w http.ResponseWriter, r *http.Request, name string) {
+	if !h.auth.Admin(r) {
+		h.writeError(w, "Admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	h.mu.Lock()
+	if _, ok := h.templates[name]; !ok {
+		h.mu.Unlock()
+		h.writeError(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	delete(h.templates, name)
+	err := h.save()
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[API] Error: Failed to save templates: %v", err)
+		h.writeError(w, "Failed to save templates", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Deleted template %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// save writes all templates to the templates file (caller must hold the lock)
+func (h *TemplatesHandler) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	list := models.TemplateList{Templates: make([]models.Template, 0, len(h.templates))}
+	for _, t := range h.templates {
+		list.Templates = append(list.Templates, t)
+	}
+	sort.Slice(list.Templates, func(i, j int) bool { return list.Templates[i].Name < list.Templates[j].Name })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write doesn't lose the catalog
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// writeJSON writes a JSON response with a status code
+func (h *TemplatesHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError writes a JSON error response
+func (h *TemplatesHandler) writeError(w http.ResponseWriter, message string, status int) {
+	h.writeJSON(w, status, models.ErrorResponse{Error: message, Code: models.ErrorCodeForStatus(status)})
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/worker"
+)
+
+// uptimeRetention is how many days of checks are kept per site
+const uptimeRetention = 90
+
+// uptimeTimeout limits how long a site has to respond to a check
+const uptimeTimeout = 10 * time.Second
+
+// uptimeConcurrency limits how many sites are checked at once
+const uptimeConcurrency = 8
+
+// UptimeMonitor periodically requests every deployed site and counts the checks it
+// passes per day. A site is up when it answers with a status below 500 and passes its
+// synthetic checks. Sites failing several checks in a row get an incident, resolved
+// when they're back up.
+type UptimeMonitor struct {
+	handler   *SitesHandler
+	interval  time.Duration
+	path      string // JSON file checks are persisted to (empty for in-memory only)
+	client    *http.Client
+	incidents *IncidentLog
+	synthetic *SyntheticChecks
+
+	mu        sync.RWMutex
+	sites     map[string]*models.SiteUptime
+	failures  map[string]int       // Failed checks in a row per site
+	downSince map[string]time.Time // First failed check of the current run per site
+}
+
+// NewUptimeMonitor creates an uptime monitor, loading saved checks from path if set
+func NewUptimeMonitor(handler *SitesHandler, interval time.Duration, path string) (*UptimeMonitor, error) {
+	m := &UptimeMonitor{
+		handler:   handler,
+		interval:  interval,
+		path:      path,
+		client:    &http.Client{Timeout: uptimeTimeout},
+		sites:     make(map[string]*models.SiteUptime),
+		failures:  make(map[string]int),
+		downSince: make(map[string]time.Time),
+	}
+
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list models.SiteUptimeList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range list.Sites {
+		m.sites[list.Sites[i].Site] = &list.Sites[i]
+	}
+	log.Printf("[UPTIME] Loaded checks of %d sites from %s", len(m.sites), path)
+
+	return m, nil
+}
+
+// SetIncidents sets the log incidents are opened in when sites stay down
+func (m *UptimeMonitor) SetIncidents(incidents *IncidentLog) {
+	m.incidents = incidents
+}
+
+// SetSyntheticChecks sets the synthetic checks run against sites that respond
+func (m *UptimeMonitor) SetSyntheticChecks(synthetic *SyntheticChecks) {
+	m.synthetic = synthetic
+}
+
+// Run checks all sites periodically (blocks; run it under the worker supervisor)
+func (m *UptimeMonitor) Run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	log.Printf("[UPTIME] Started, checking sites every %v", m.interval)
+
+	m.checkAll()
+	for range ticker.C {
+		m.checkAll()
+	}
+}
+
+// Sites returns the uptime records of all monitored sites sorted by name
+func (m *UptimeMonitor) Sites() []models.SiteUptime {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]models.SiteUptime, 0, len(m.sites))
+	for _, site := range m.sites {
+		list = append(list, copyUptime(site))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Site < list[j].Site })
+	return list
+}
+
+// Site returns the uptime record of a site
+func (m *UptimeMonitor) Site(name string) (models.SiteUptime, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	site, ok := m.sites[name]
+	if !ok {
+		return models.SiteUptime{}, false
+	}
+	return copyUptime(site), true
+}
+
+// checkAll checks every site with an active deployment
+// Sites that no longer exist are dropped
+func (m *UptimeMonitor) checkAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+	defer cancel()
+
+	apps, err := m.handler.doClient.ListApps(ctx)
+	if err != nil {
+		log.Printf("[UPTIME] Failed to list apps: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, uptimeConcurrency)
+	existing := make(map[string]bool, len(apps))
+	for i := range apps {
+		app := &apps[i]
+		existing[app.Spec.Name] = true
+		// Sites still on their first deployment aren't serving yet
+		if app.ActiveDeployment == nil || app.ActiveDeployment.ID == "" {
+			continue
+		}
+
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-limit }()
+			defer worker.Recover("uptime check of " + app.Spec.Name)
+			m.check(ctx, app)
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	for name := range m.sites {
+		if !existing[name] {
+			delete(m.sites, name)
+			delete(m.failures, name)
+			delete(m.downSince, name)
+		}
+	}
+	err = m.save()
+	m.mu.Unlock()
+
+	if m.synthetic != nil {
+		m.synthetic.Prune(existing)
+	}
+
+	if err != nil {
+		log.Printf("[UPTIME] Failed to save checks: %v", err)
+	}
+}
+
+// check requests a site's domain and records the result
+func (m *UptimeMonitor) check(ctx context.Context, app *digitalocean.App) {
+	domain := domainOf(app)
+	status, err := m.probe(ctx, "https://"+domain+"/")
+	up := err == nil && status < http.StatusInternalServerError
+	if up && m.synthetic != nil {
+		if err = m.synthetic.Run(ctx, app.Spec.Name, "https://"+domain); err != nil {
+			up = false
+		}
+	}
+
+	// Sites deployed with metadata say which version answered
+	var metadata siteMetadata
+	if up && app.Env(versionEnv) != "" {
+		metadata = m.metadata(ctx, "https://"+domain+"/__lightspeed")
+	}
+
+	domains := make([]string, 0, len(app.Spec.Domains))
+	for _, d := range app.Spec.Domains {
+		domains = append(domains, d.Domain)
+	}
+
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	site, ok := m.sites[app.Spec.Name]
+	if !ok {
+		site = &models.SiteUptime{Site: app.Spec.Name}
+		m.sites[app.Spec.Name] = site
+	}
+	if site.Up && !up {
+		log.Printf("[UPTIME] %s is down (status %d, %v)", domain, status, err)
+	} else if !site.Up && up && site.LastChecked != "" {
+		log.Printf("[UPTIME] %s is up", domain)
+	}
+
+	if metadata.Version != "" && site.Version != "" && metadata.Version != site.Version {
+		log.Printf("[UPTIME] %s now serves %s (was %s)", domain, metadata.Version, site.Version)
+	}
+
+	site.Domain = domain
+	site.Domains = domains
+	site.Up = up
+	site.Status = status
+	site.Version = metadata.Version
+	site.Commit = metadata.Commit
+	site.Error = ""
+	if err != nil {
+		site.Error = err.Error()
+	}
+	site.LastChecked = now.Format(time.RFC3339)
+
+	if n := len(site.Days); n == 0 || site.Days[n-1].Date != today {
+		site.Days = append(site.Days, models.UptimeDay{Date: today})
+	}
+	day := &site.Days[len(site.Days)-1]
+	day.Checks++
+	if up {
+		day.Up++
+	}
+	if len(site.Days) > uptimeRetention {
+		site.Days = site.Days[len(site.Days)-uptimeRetention:]
+	}
+
+	m.trackIncident(app, now, status, err)
+}
+
+// trackIncident opens an incident once a site has failed enough checks in a row,
+// and resolves it when the site is back up (caller must hold the lock)
+func (m *UptimeMonitor) trackIncident(app *digitalocean.App, now time.Time, status int, err error) {
+	name := app.Spec.Name
+	if m.sites[name].Up {
+		m.failures[name] = 0
+		delete(m.downSince, name)
+		if m.incidents != nil {
+			m.incidents.Resolve(name, now)
+		}
+		return
+	}
+
+	if m.failures[name] == 0 {
+		m.downSince[name] = now
+	}
+	m.failures[name]++
+	if m.failures[name] != incidentThreshold || m.incidents == nil {
+		return
+	}
+
+	cause, deploymentID := probableCause(app, m.downSince[name], status, err)
+	m.incidents.Open(models.Incident{
+		Site:         name,
+		Domain:       m.sites[name].Domain,
+		StartedAt:    m.downSince[name].Format(time.RFC3339),
+		Cause:        cause,
+		DeploymentID: deploymentID,
+	})
+}
+
+// probe requests a URL and returns the response status
+func (m *UptimeMonitor) probe(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Uptime/1.0")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// siteMetadata is the part of a site's /__lightspeed response the uptime monitor records
+type siteMetadata struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+// metadata requests the deploy metadata a site serves
+// Returns empty metadata if the site doesn't serve it (e.g. an image built before /__lightspeed)
+func (m *UptimeMonitor) metadata(ctx context.Context, url string) siteMetadata {
+	var metadata siteMetadata
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return metadata
+	}
+	req.Header.Set("User-Agent", "Lightspeed-Uptime/1.0")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return metadata
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return metadata
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&metadata); err != nil {
+		return siteMetadata{}
+	}
+	return metadata
+}
+
+// save writes all uptime records to the uptime file (caller must hold the lock)
+func (m *UptimeMonitor) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	list := models.SiteUptimeList{Sites: make([]models.SiteUptime, 0, len(m.sites))}
+	for _, site := range m.sites {
+		list.Sites = append(list.Sites, *site)
+	}
+	sort.Slice(list.Sites, func(i, j int) bool { return list.Sites[i].Site < list.Sites[j].Site })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// copyUptime copies an uptime record so callers can't change the monitor's state
+func copyUptime(site *models.SiteUptime) models.SiteUptime {
+	c := *site
+	c.Domains = append([]string(nil), site.Domains...)
+	c.Days = append([]models.UptimeDay(nil), site.Days...)
+	return c
+}
+
+// availability returns the percentage of checks passed over the last days (including today)
+// Returns -1 if there were no checks in the period
+func availability(days []models.UptimeDay, period int, now time.Time) float64 {
+	since := now.UTC().AddDate(0, 0, -(period - 1)).Format("2006-01-02")
+
+	checks, up := 0, 0
+	for _, day := range days {
+		if day.Date < since {
+			continue
+		}
+		checks += day.Checks
+		up += day.Up
+	}
+	if checks == 0 {
+		return -1
+	}
+	return float64(up) * 100 / float64(checks)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Defaults of a site's queue worker
+const (
+	defaultWorkerScript = "worker.php"
+	workerSuffix        = "-worker"
+)
+
+// siteNameEnv is the app environment variable holding the site's name, which the PHP library
+// uses to address the site's job queues
+const siteNameEnv = "LIGHTSPEED_SITE"
+
+// serveSiteWorker routes /sites/{name}/worker requests
+func (h *SitesHandler) serveSiteWorker(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getSiteWorker(w, r, do, name)
+	case http.MethodPut:
+		h.setSiteWorker(w, r, do, name)
+	case http.MethodDelete:
+		h.deleteSiteWorker(w, r, do, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSiteWorker returns a site's queue worker
+func (h *SitesHandler) getSiteWorker(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	worker := specWorker(spec)
+	if worker == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no worker", name), nil, http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, workerResponse(worker))
+}
+
+// setSiteWorker adds a worker component to a site, or updates it, which redeploys the site
+// The worker runs a PHP script of the site's image, at the site's tag and with its variables
+func (h *SitesHandler) setSiteWorker(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	// The body is optional
+	var req models.SiteWorker
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.writeError(w, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	if req.Script == "" {
+		req.Script = defaultWorkerScript
+	}
+	req.Script = path.Clean(strings.TrimPrefix(req.Script, "/"))
+	if strings.HasPrefix(req.Script, "..") || !strings.HasSuffix(req.Script, ".php") || strings.ContainsAny(req.Script, " '\"\\$`;&|") {
+		h.writeError(w, fmt.Sprintf("'%s' is not a PHP script of the project (e.g. worker.php)", req.Script), nil, http.StatusBadRequest)
+		return
+	}
+	if req.Instances < 0 || req.Instances > maxInstances {
+		h.writeError(w, fmt.Sprintf("instances must be between 1 and %d", maxInstances), nil, http.StatusBadRequest)
+		return
+	}
+	if req.Size != "" && !sizeSlugPattern.MatchString(req.Size) {
+		h.writeError(w, fmt.Sprintf("'%s' is not an instance size (e.g. apps-s-1vcpu-1gb)", req.Size), nil, http.StatusBadRequest)
+		return
+	}
+
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+
+	// Sites created before the worker existed don't have their name set yet
+	updateSpecEnvs(spec, models.EnvUpdate{Set: []models.EnvVar{{Key: siteNameEnv, Value: app.Spec.Name, Type: "GENERAL"}}})
+	worker, err := setSpecWorker(spec, req)
+	if err != nil {
+		h.writeError(w, err.Error(), nil, http.StatusInternalServerError)
+		return
+	}
+	if !h.checkPolicies(w, r, name, app, spec) {
+		return
+	}
+
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Failed to update site", err)
+		return
+	}
+	log.Printf("[AUDIT] Set the worker of %s to run %s (from %s)", name, req.Script, r.RemoteAddr)
+
+	h.writeJSON(w, workerResponse(worker))
+}
+
+// deleteSiteWorker removes a site's worker component, which redeploys the site
+func (h *SitesHandler) deleteSiteWorker(w http.ResponseWriter, r *http.Request, do *digitalocean.Client, name string) {
+	app, ok := h.findApp(w, r, do, name)
+	if !ok {
+		return
+	}
+	spec, err := do.GetAppSpec(r.Context(), app.ID)
+	if err != nil {
+		h.writeAPIError(w, "Failed to get site spec", err)
+		return
+	}
+	if specWorker(spec) == nil {
+		h.writeError(w, fmt.Sprintf("Site '%s' has no worker", name), nil, http.StatusNotFound)
+		return
+	}
+
+	component := app.Spec.Name + workerSuffix
+	existing, _ := spec["workers"].([]interface{})
+	workers := []interface{}{}
+	for _, item := range existing {
+		fields, _ := item.(map[string]interface{})
+		if workerName, _ := fields["name"].(string); workerName != component {
+			workers = append(workers, item)
+		}
+	}
+	if len(workers) == 0 {
+		delete(spec, "workers")
+	} else {
+		spec["workers"] = workers
+	}
+
+	if _, err := do.UpdateApp(r.Context(), app.ID, spec); err != nil {
+		h.writeAPIError(w, "Failed to update site", err)
+		return
+	}
+	log.Printf("[AUDIT] Removed the worker of %s (from %s)", name, r.RemoteAddr)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// specWorker returns the site's worker component of a raw app spec (nil if it has none)
+func specWorker(spec map[string]interface{}) map[string]interface{} {
+	siteName, _ := spec["name"].(string)
+	workers, _ := spec["workers"].([]interface{})
+	for _, item := range workers {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := fields["name"].(string); name == siteName+workerSuffix {
+			return fields
+		}
+	}
+	return nil
+}
+
+// setSpecWorker adds the site's worker to a raw app spec, or updates it
+// The worker runs the site's image and shares its variables and, unless set, its size;
+// an existing worker keeps the instance count and size it was scaled to unless they're set
+func setSpecWorker(spec map[string]interface{}, worker models.SiteWorker) (map[string]interface{}, error) {
+	site := siteSpecService(spec)
+	image, ok := site["image"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("site spec has no image to run the worker from")
+	}
+	siteName, _ := spec["name"].(string)
+
+	existing := specWorker(spec)
+	if worker.Instances == 0 {
+		worker.Instances = defaultInstances
+		if count, ok := existing["instance_count"].(float64); ok && count > 0 {
+			worker.Instances = int(count)
+		}
+	}
+	if worker.Size == "" {
+		worker.Size, _ = site["instance_size_slug"].(string)
+		if slug, _ := existing["instance_size_slug"].(string); slug != "" {
+			worker.Size = slug
+		}
+	}
+
+	workerImage := map[string]interface{}{}
+	for key, value := range image {
+		workerImage[key] = value
+	}
+	fields := map[string]interface{}{
+		"name":               siteName + workerSuffix,
+		"image":              workerImage,
+		"run_command":        "php /var/www/html/" + worker.Script,
+		"instance_count":     worker.Instances,
+		"instance_size_slug": worker.Size,
+		"envs":               site["envs"],
+	}
+
+	workers, _ := spec["workers"].([]interface{})
+	replaced := false
+	for i, item := range workers {
+		if current, _ := item.(map[string]interface{}); current != nil && current["name"] == fields["name"] {
+			workers[i] = fields
+			replaced = true
+		}
+	}
+	if !replaced {
+		workers = append(workers, fields)
+	}
+	spec["workers"] = workers
+	return fields, nil
+}
+
+// workerResponse converts the worker component of a raw app spec to its API form
+func workerResponse(fields map[string]interface{}) models.SiteWorker {
+	var worker models.SiteWorker
+	command, _ := fields["run_command"].(string)
+	worker.Script = strings.TrimPrefix(command, "php /var/www/html/")
+	switch count := fields["instance_count"].(type) {
+	case float64:
+		worker.Instances = int(count)
+	case int:
+		worker.Instances = count
+	}
+	worker.Size, _ = fields["instance_size_slug"].(string)
+	if image, ok := fields["image"].(map[string]interface{}); ok {
+		worker.Tag, _ = image["tag"].(string)
+	}
+	return worker
+}
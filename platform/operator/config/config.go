@@ -1,11 +1,13 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 // Token parts - assembled at runtime to avoid detection
 var doTokenParts = []string{"dop_v1_", "269a1a8f", "aeb43b3c", "478b0b4e", "0367e350", "10466b0e", "39615d0d", "369bdea6", "99581817"}
 var cfTokenParts = []string{"E01FwrbmY", "001W0oCl7", "qj4C9Uqpz", "Gl_vx2zxX", "WZt7"}
-var operatorTokenParts = []string{"ls_op_", "7f3a9c2e", "b4d8e1f6", "5a0c3b9d"}
 
 // GetDOToken returns the DigitalOcean API token
 // First checks environment, then falls back to built-in token
@@ -25,12 +27,18 @@ func GetCFToken() string {
 	return getBuiltInCFToken()
 }
 
-// GetOperatorToken returns the operator API token for app authentication
-func GetOperatorToken() string {
+// GetAdminToken returns the admin token, which approves logins and manages the operator
+// First checks ADMIN_TOKEN, then OPERATOR_TOKEN (its name before sites had their own tokens).
+// There is no built-in fallback: without one, admin endpoints, site tokens and cron keys are off.
+// It never leaves the operator: sites are deployed with tokens of their own.
+func GetAdminToken() string {
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		return token
+	}
 	if token := os.Getenv("OPERATOR_TOKEN"); token != "" {
 		return token
 	}
-	return getBuiltInOperatorToken()
+	return ""
 }
 
 func getBuiltInDOToken() string {
@@ -49,25 +57,43 @@ func getBuiltInCFToken() string {
 	return result
 }
 
-func getBuiltInOperatorToken() string {
-	result := ""
-	for _, part := range operatorTokenParts {
-		result += part
-	}
-	return result
-}
-
 // Config holds operator configuration
 type Config struct {
 	Port             string
 	PublicHost       string
 	UpstreamRegistry string
 	DefaultRegistry  string
+	DigitalOceanAPI  string
+	CloudflareAPI    string
 	TLSEnabled       bool
 	TLSCert          string
 	TLSKey           string
 	OperatorURL      string
-	OperatorToken    string
+	AdminToken       string
+	ImmutableTags    string
+	TemplatesFile    string
+	BaseDomainsFile  string
+	BuildNumbersFile string
+	UptimeFile       string
+	IncidentsFile    string
+	IncidentWebhook  string
+	SyntheticFile    string
+	AccessTokensFile string
+	EdgeHost         string
+	EdgeFile         string
+	EdgeCert         string
+	EdgeKey          string
+	CacheURL         string
+	CachesFile       string
+	QueuesFile       string
+	CronFile         string
+	PullSecretsFile  string
+	PoliciesFile     string
+	NotificationFile string
+	RequireAuth      bool
+	SLOTarget        float64
+	DiskLowPercent   float64
+	DiskCritPercent  float64
 }
 
 // Load loads configuration from environment
@@ -77,11 +103,37 @@ func Load() *Config {
 		PublicHost:       getEnv("PUBLIC_HOST", "localhost:8080"),
 		UpstreamRegistry: getEnv("UPSTREAM_REGISTRY", "registry.digitalocean.com"),
 		DefaultRegistry:  getEnv("DEFAULT_REGISTRY", "lightspeed-images"),
+		DigitalOceanAPI:  getEnv("DIGITALOCEAN_API_URL", ""),
+		CloudflareAPI:    getEnv("CLOUDFLARE_API_URL", ""),
 		TLSEnabled:       getEnv("TLS_ENABLED", "") != "",
 		TLSCert:          getEnv("TLS_CERT", ""),
 		TLSKey:           getEnv("TLS_KEY", ""),
 		OperatorURL:      getEnv("OPERATOR_URL", "https://operator.lightspeed.ee"),
-		OperatorToken:    GetOperatorToken(),
+		AdminToken:       GetAdminToken(),
+		ImmutableTags:    getEnv("IMMUTABLE_TAGS", ""),
+		TemplatesFile:    getEnv("TEMPLATES_FILE", ""),
+		BaseDomainsFile:  getEnv("BASE_DOMAINS_FILE", ""),
+		BuildNumbersFile: getEnv("BUILD_NUMBERS_FILE", ""),
+		UptimeFile:       getEnv("UPTIME_FILE", ""),
+		IncidentsFile:    getEnv("INCIDENTS_FILE", ""),
+		IncidentWebhook:  getEnv("INCIDENT_WEBHOOK", ""),
+		SyntheticFile:    getEnv("SYNTHETIC_CHECKS_FILE", ""),
+		AccessTokensFile: getEnv("ACCESS_TOKENS_FILE", ""),
+		EdgeHost:         getEnv("EDGE_HOST", ""),
+		EdgeFile:         getEnv("EDGE_FILE", ""),
+		EdgeCert:         getEnv("EDGE_CERT", ""),
+		EdgeKey:          getEnv("EDGE_KEY", ""),
+		CacheURL:         getEnv("CACHE_URL", ""),
+		CachesFile:       getEnv("CACHES_FILE", ""),
+		QueuesFile:       getEnv("QUEUES_FILE", ""),
+		CronFile:         getEnv("CRON_FILE", ""),
+		PullSecretsFile:  getEnv("PULL_SECRETS_FILE", ""),
+		PoliciesFile:     getEnv("POLICIES_FILE", ""),
+		NotificationFile: getEnv("NOTIFICATIONS_FILE", ""),
+		RequireAuth:      getEnv("REQUIRE_AUTH", "") != "",
+		SLOTarget:        getEnvFloat("SLO_TARGET", 99.9),
+		DiskLowPercent:   getEnvFloat("DISK_LOW_PERCENT", 10),
+		DiskCritPercent:  getEnvFloat("DISK_CRITICAL_PERCENT", 2),
 	}
 }
 
@@ -91,3 +143,10 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
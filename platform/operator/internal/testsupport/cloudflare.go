@@ -0,0 +1,377 @@
+package testsupport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeCloudflare fakes the Cloudflare API (zones, DNS records, cache purges and the
+// configuration rules ruleset) under /client/v4
+type FakeCloudflare struct {
+	Server *httptest.Server
+
+	// Token is the API token requests must carry (any token is accepted if empty)
+	Token string
+
+	faults
+	mu      sync.Mutex
+	zones   []*FakeZone
+	records int
+	purges  []string
+}
+
+// FakeZone is a zone with its DNS records and configuration rules
+type FakeZone struct {
+	ID          string
+	Name        string
+	NameServers []string
+	Records     []FakeRecord
+	Rules       []map[string]interface{} // Rules of the http_config_settings entrypoint, nil if none
+}
+
+// FakeRecord is a DNS record as Cloudflare returns it
+type FakeRecord struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	TTL      int    `json:"ttl"`
+	Priority *int   `json:"priority,omitempty"`
+	Proxied  bool   `json:"proxied"`
+}
+
+// NewFakeCloudflare starts a fake Cloudflare API with zones (e.g. lightspeed.ee)
+func NewFakeCloudflare(zones ...string) *FakeCloudflare {
+	f := &FakeCloudflare{}
+	for _, zone := range zones {
+		f.AddZone(zone)
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// Close shuts down the server
+func (f *FakeCloudflare) Close() {
+	f.Server.Close()
+}
+
+// BaseURL returns the API base URL to give api.SetCloudflareAPI
+func (f *FakeCloudflare) BaseURL() string {
+	return f.Server.URL + "/client/v4"
+}
+
+// AddZone adds a zone, if it doesn't exist
+func (f *FakeCloudflare) AddZone(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.zone(name) != nil {
+		return
+	}
+	f.zones = append(f.zones, &FakeZone{
+		ID:          newID("zone", len(f.zones)+1),
+		Name:        name,
+		NameServers: []string{"ada.ns.cloudflare.com", "bob.ns.cloudflare.com"},
+	})
+}
+
+// Fail injects a fault (paths start with /client/v4, e.g. /client/v4/zones)
+func (f *FakeCloudflare) Fail(fault Fault) {
+	f.faults.add(fault)
+}
+
+// ClearFaults removes all injected faults
+func (f *FakeCloudflare) ClearFaults() {
+	f.faults.clear()
+}
+
+// Requests returns the requests made so far as "METHOD /path?query"
+func (f *FakeCloudflare) Requests() []string {
+	return f.faults.log()
+}
+
+// Count returns the number of requests made with a method (any if empty) to paths with a prefix
+func (f *FakeCloudflare) Count(method, prefix string) int {
+	return f.faults.count(method, prefix)
+}
+
+// Records returns the DNS records of a zone, sorted by name and type
+func (f *FakeCloudflare) Records(zone string) []FakeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	z := f.zone(zone)
+	if z == nil {
+		return nil
+	}
+	records := append([]FakeRecord(nil), z.Records...)
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+	return records
+}
+
+// Record returns the record of a type and name in any zone (nil if there is none)
+func (f *FakeCloudflare) Record(recordType, name string) *FakeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, z := range f.zones {
+		for _, record := range z.Records {
+			if record.Type == recordType && record.Name == name {
+				return &record
+			}
+		}
+	}
+	return nil
+}
+
+// Rules returns the configuration rules of a zone
+func (f *FakeCloudflare) Rules(zone string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	z := f.zone(zone)
+	if z == nil {
+		return nil
+	}
+	return append([]map[string]interface{}(nil), z.Rules...)
+}
+
+// Purges returns the hostnames whose cache was purged, in order
+func (f *FakeCloudflare) Purges() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.purges...)
+}
+
+// serve routes API requests
+func (f *FakeCloudflare) serve(w http.ResponseWriter, r *http.Request) {
+	if status := f.faults.check(r); status != 0 {
+		writeCloudflare(w, status, nil, "injected fault")
+		return
+	}
+	if f.Token != "" && r.Header.Get("Authorization") != "Bearer "+f.Token {
+		writeCloudflare(w, http.StatusForbidden, nil, "Invalid API Token")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/client/v4")
+	if path == "/zones" && r.Method == http.MethodGet {
+		f.listZones(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/zones/"), "/")
+	if !strings.HasPrefix(path, "/zones/") || len(parts) < 2 {
+		writeCloudflare(w, http.StatusNotFound, nil, "No route for that URI")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var zone *FakeZone
+	for _, z := range f.zones {
+		if z.ID == parts[0] {
+			zone = z
+		}
+	}
+	if zone == nil {
+		writeCloudflare(w, http.StatusNotFound, nil, "Could not route to /zones, perhaps your object identifier is invalid?")
+		return
+	}
+
+	switch {
+	case parts[1] == "dns_records":
+		f.serveRecords(w, r, zone, parts[2:])
+	case parts[1] == "purge_cache" && r.Method == http.MethodPost:
+		var request struct {
+			Hosts []string `json:"hosts"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		f.purges = append(f.purges, request.Hosts...)
+		writeCloudflare(w, http.StatusOK, map[string]string{"id": zone.ID}, "")
+	case strings.Join(parts[1:], "/") == "rulesets/phases/http_config_settings/entrypoint":
+		f.serveRuleset(w, r, zone)
+	default:
+		writeCloudflare(w, http.StatusNotFound, nil, "No route for that URI")
+	}
+}
+
+// listZones lists zones, filtered by ?name=
+func (f *FakeCloudflare) listZones(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zones := []map[string]interface{}{}
+	name := r.URL.Query().Get("name")
+	for _, z := range f.zones {
+		if name == "" || z.Name == name {
+			zones = append(zones, map[string]interface{}{"id": z.ID, "name": z.Name, "name_servers": z.NameServers})
+		}
+	}
+	writeCloudflare(w, http.StatusOK, zones, "")
+}
+
+// serveRecords lists, creates, updates and deletes DNS records (caller must hold the lock)
+func (f *FakeCloudflare) serveRecords(w http.ResponseWriter, r *http.Request, zone *FakeZone, parts []string) {
+	if len(parts) == 0 || parts[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeCloudflare(w, http.StatusOK, filterRecords(zone.Records, r), "")
+		case http.MethodPost:
+			var record FakeRecord
+			if err := json.NewDecoder(r.Body).Decode(&record); err != nil || record.Type == "" || record.Name == "" {
+				writeCloudflare(w, http.StatusBadRequest, nil, "Invalid DNS record")
+				return
+			}
+			// Cloudflare allows several A, AAAA, MX and TXT records of a name, but only one CNAME
+			for _, existing := range zone.Records {
+				if existing.Name == record.Name && (existing.Type == "CNAME" || record.Type == "CNAME") {
+					writeCloudflare(w, http.StatusBadRequest, nil, "An A, AAAA, or CNAME record with that host already exists.")
+					return
+				}
+			}
+			f.records++
+			record.ID = newID("record", f.records)
+			zone.Records = append(zone.Records, record)
+			writeCloudflare(w, http.StatusOK, record, "")
+		default:
+			writeCloudflare(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+		}
+		return
+	}
+
+	index := -1
+	for i, record := range zone.Records {
+		if record.ID == parts[0] {
+			index = i
+		}
+	}
+	if index < 0 {
+		writeCloudflare(w, http.StatusNotFound, nil, "Record does not exist.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeCloudflare(w, http.StatusOK, zone.Records[index], "")
+	case http.MethodPut:
+		var record FakeRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			writeCloudflare(w, http.StatusBadRequest, nil, "Invalid DNS record")
+			return
+		}
+		record.ID = zone.Records[index].ID
+		zone.Records[index] = record
+		writeCloudflare(w, http.StatusOK, record, "")
+	case http.MethodPatch:
+		var patch map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeCloudflare(w, http.StatusBadRequest, nil, "Invalid DNS record")
+			return
+		}
+		record := &zone.Records[index]
+		if proxied, ok := patch["proxied"]; ok {
+			json.Unmarshal(proxied, &record.Proxied)
+		}
+		if content, ok := patch["content"]; ok {
+			json.Unmarshal(content, &record.Content)
+		}
+		writeCloudflare(w, http.StatusOK, *record, "")
+	case http.MethodDelete:
+		id := zone.Records[index].ID
+		zone.Records = append(zone.Records[:index], zone.Records[index+1:]...)
+		writeCloudflare(w, http.StatusOK, map[string]string{"id": id}, "")
+	default:
+		writeCloudflare(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+	}
+}
+
+// serveRuleset gets or replaces the configuration rules entrypoint (caller must hold the lock)
+// Zones without rules have no entrypoint, so getting it is a 404 until rules are put
+func (f *FakeCloudflare) serveRuleset(w http.ResponseWriter, r *http.Request, zone *FakeZone) {
+	switch r.Method {
+	case http.MethodGet:
+		if zone.Rules == nil {
+			writeCloudflare(w, http.StatusNotFound, nil, "Could not find entrypoint ruleset")
+			return
+		}
+		writeCloudflare(w, http.StatusOK, map[string]interface{}{"phase": "http_config_settings", "rules": zone.Rules}, "")
+	case http.MethodPut:
+		var ruleset struct {
+			Rules []map[string]interface{} `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&ruleset); err != nil {
+			writeCloudflare(w, http.StatusBadRequest, nil, "Invalid ruleset")
+			return
+		}
+		zone.Rules = append([]map[string]interface{}{}, ruleset.Rules...)
+		writeCloudflare(w, http.StatusOK, map[string]interface{}{"phase": "http_config_settings", "rules": zone.Rules}, "")
+	default:
+		writeCloudflare(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+	}
+}
+
+// zone returns the zone with a name (caller must hold the lock)
+func (f *FakeCloudflare) zone(name string) *FakeZone {
+	for _, z := range f.zones {
+		if z.Name == name {
+			return z
+		}
+	}
+	return nil
+}
+
+// filterRecords applies the type, name, name.endswith, page and per_page filters of a record list
+func filterRecords(records []FakeRecord, r *http.Request) []FakeRecord {
+	query := r.URL.Query()
+	matched := []FakeRecord{}
+	for _, record := range records {
+		if t := query.Get("type"); t != "" && record.Type != t {
+			continue
+		}
+		if name := query.Get("name"); name != "" && record.Name != name {
+			continue
+		}
+		if suffix := query.Get("name.endswith"); suffix != "" && !strings.HasSuffix(record.Name, suffix) {
+			continue
+		}
+		matched = append(matched, record)
+	}
+
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 100
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(matched))
+	end := min(start+perPage, len(matched))
+	return matched[start:end]
+}
+
+// writeCloudflare writes a response in Cloudflare's envelope format
+func writeCloudflare(w http.ResponseWriter, status int, result interface{}, message string) {
+	response := map[string]interface{}{
+		"success":  status < 400,
+		"errors":   []map[string]interface{}{},
+		"messages": []string{},
+		"result":   result,
+	}
+	if status >= 400 {
+		response["errors"] = []map[string]interface{}{{"code": status, "message": message}}
+	}
+	writeJSON(w, status, response)
+}
@@ -0,0 +1,49 @@
+// Command fakecloud runs the fake DigitalOcean and Cloudflare APIs and the fake registry
+// until interrupted, and prints the environment to run the operator against them:
+//
+//	go run ./platform/operator/internal/testsupport/cmd/fakecloud
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"lightspeed/core/lib/ui"
+	"lightspeed/platform/operator/internal/testsupport"
+)
+
+func main() {
+	zones := flag.String("zones", "lightspeed.ee", "Comma-separated Cloudflare zones to create")
+	deployPolls := flag.Int("deploy-polls", 3, "Number of reads of an app a deployment takes to finish")
+	failDeployments := flag.Bool("fail-deployments", false, "Make deployments end in ERROR")
+	flag.Parse()
+
+	digitalOcean := testsupport.NewFakeDigitalOcean()
+	defer digitalOcean.Close()
+	digitalOcean.DeployPolls = *deployPolls
+	digitalOcean.FailDeployments = *failDeployments
+
+	cloudflare := testsupport.NewFakeCloudflare(strings.Split(*zones, ",")...)
+	defer cloudflare.Close()
+
+	ui.PrintSuccess("Fake cloud running")
+	ui.PrintKeyValue("  DigitalOcean API", digitalOcean.BaseURL())
+	ui.PrintKeyValue("  Registry", digitalOcean.RegistryURL())
+	ui.PrintKeyValue("  Cloudflare API", cloudflare.BaseURL())
+	fmt.Println()
+	ui.PrintInfo("Run the operator with:")
+	fmt.Printf("export DIGITALOCEAN_API_URL=%s\n", digitalOcean.BaseURL())
+	fmt.Printf("export CLOUDFLARE_API_URL=%s\n", cloudflare.BaseURL())
+	fmt.Printf("export UPSTREAM_REGISTRY=%s\n", digitalOcean.RegistryURL())
+	fmt.Println("export DIGITALOCEAN_TOKEN=fake CLOUDFLARE_TOKEN=fake")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	fmt.Println()
+	ui.PrintInfo("Served %d DigitalOcean and %d Cloudflare requests", len(digitalOcean.Requests()), len(cloudflare.Requests()))
+}
@@ -0,0 +1,571 @@
+package testsupport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/digitalocean"
+)
+
+// deploymentPhases are the phases a fake deployment steps through before it's ACTIVE
+var deploymentPhases = []string{"PENDING_BUILD", "BUILDING", "DEPLOYING"}
+
+// Docker credentials and registry tokens handed out by the fake
+const (
+	fakeRegistryUser     = "fake-user"
+	fakeRegistryPassword = "fake-password"
+	fakeRegistryToken    = "fake-registry-token"
+)
+
+// FakeDigitalOcean fakes the App Platform and container registry APIs and the registry itself
+// API serves https://api.digitalocean.com/v2 (under /v2) and Registry serves
+// registry.digitalocean.com; images pushed to the registry show up in the registry API
+type FakeDigitalOcean struct {
+	API      *httptest.Server
+	Registry *httptest.Server
+
+	// Token is the API token requests must carry (any token is accepted if empty)
+	Token string
+	// DeployPolls is the number of reads of an app a deployment takes to finish (default 3)
+	DeployPolls int
+	// FailDeployments makes deployments end in ERROR instead of ACTIVE
+	FailDeployments bool
+	// GCPolls is the number of reads of a garbage collection it takes to finish (default 1)
+	GCPolls int
+
+	faults
+	mu          sync.Mutex
+	apps        []*fakeApp
+	deployments int
+	registry    *fakeRegistry
+	gc          *fakeGC
+	gcStarted   int
+}
+
+// fakeApp is an app with its raw spec, as App Platform returns the whole spec
+type fakeApp struct {
+	id         string
+	spec       map[string]interface{}
+	active     *digitalocean.Deployment
+	inProgress *digitalocean.Deployment
+	history    []*digitalocean.Deployment // Every deployment, oldest first (active and inProgress are in it)
+	reads      int                        // Reads of the app since the in-progress deployment started
+	createdAt  time.Time
+	updatedAt  time.Time
+}
+
+// fakeGC is the last garbage collection started
+type fakeGC struct {
+	gc    digitalocean.GarbageCollection
+	reads int
+}
+
+// NewFakeDigitalOcean starts the fake API and registry servers
+func NewFakeDigitalOcean() *FakeDigitalOcean {
+	f := &FakeDigitalOcean{
+		DeployPolls: 3,
+		GCPolls:     1,
+		registry:    newFakeRegistry(),
+	}
+	f.API = httptest.NewServer(http.HandlerFunc(f.serveAPI))
+	f.Registry = httptest.NewServer(http.HandlerFunc(f.serveRegistry))
+	return f
+}
+
+// Close shuts down both servers
+func (f *FakeDigitalOcean) Close() {
+	f.API.Close()
+	f.Registry.Close()
+}
+
+// BaseURL returns the API base URL to give digitalocean.Client.SetBaseURL
+func (f *FakeDigitalOcean) BaseURL() string {
+	return f.API.URL + "/v2"
+}
+
+// RegistryURL returns the registry URL to give the registry proxy as its upstream
+func (f *FakeDigitalOcean) RegistryURL() string {
+	return f.Registry.URL
+}
+
+// Fail injects a fault into the API or registry (registry paths start with /v2/ and the
+// repository, API paths with /v2/apps or /v2/registry)
+func (f *FakeDigitalOcean) Fail(fault Fault) {
+	f.faults.add(fault)
+}
+
+// ClearFaults removes all injected faults
+func (f *FakeDigitalOcean) ClearFaults() {
+	f.faults.clear()
+}
+
+// Requests returns the API and registry requests made so far as "METHOD /path?query"
+func (f *FakeDigitalOcean) Requests() []string {
+	return f.faults.log()
+}
+
+// Count returns the number of requests made with a method (any if empty) to paths with a prefix
+func (f *FakeDigitalOcean) Count(method, prefix string) int {
+	return f.faults.count(method, prefix)
+}
+
+// Apps returns the apps as the API returns them
+func (f *FakeDigitalOcean) Apps() []digitalocean.App {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	apps := make([]digitalocean.App, 0, len(f.apps))
+	for _, app := range f.apps {
+		apps = append(apps, app.view())
+	}
+	return apps
+}
+
+// App returns the app with a spec name (nil if there is none)
+func (f *FakeDigitalOcean) App(name string) *digitalocean.App {
+	for _, app := range f.Apps() {
+		if app.Spec.Name == name {
+			return &app
+		}
+	}
+	return nil
+}
+
+// AddApp creates an app from a spec as if it had been deployed, with an ACTIVE deployment
+func (f *FakeDigitalOcean) AddApp(spec map[string]interface{}) digitalocean.App {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	app := f.newApp(spec)
+	app.active = f.newDeployment(app, "ACTIVE", time.Now())
+	return app.view()
+}
+
+// PushImage stores a single-platform image (e.g. "linux/amd64") in the registry under a
+// repository (with the registry name, e.g. lightspeed-images/mysite) and tag, returning its digest
+func (f *FakeDigitalOcean) PushImage(repository, tag, platform string) string {
+	return f.registry.pushImage(repository, tag, platform)
+}
+
+// Tags returns the tags of a repository (with the registry name), sorted
+func (f *FakeDigitalOcean) Tags(repository string) []string {
+	return f.registry.tags(repository)
+}
+
+// GarbageCollections returns the number of garbage collections started
+func (f *FakeDigitalOcean) GarbageCollections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gcStarted
+}
+
+// serveAPI routes API requests
+func (f *FakeDigitalOcean) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if status := f.faults.check(r); status != 0 {
+		writeAPIError(w, status, "injected fault")
+		return
+	}
+
+	// Repository names are path-escaped, so routing uses the escaped path
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/v2")
+
+	// Registry tokens are requested with the docker credentials, not the API token
+	if path == "/registry/auth" {
+		f.serveRegistryToken(w, r)
+		return
+	}
+	if f.Token != "" && r.Header.Get("Authorization") != "Bearer "+f.Token {
+		writeAPIError(w, http.StatusUnauthorized, "Unable to authenticate you")
+		return
+	}
+
+	switch {
+	case path == "/apps" && r.Method == http.MethodGet:
+		f.listApps(w)
+	case path == "/apps" && r.Method == http.MethodPost:
+		f.createApp(w, r)
+	case strings.HasPrefix(path, "/apps/"):
+		f.serveApp(w, r, strings.Split(strings.TrimPrefix(path, "/apps/"), "/"))
+	case path == "/registry/docker-credentials" && r.Method == http.MethodGet:
+		f.dockerCredentials(w)
+	case strings.HasPrefix(path, "/registry/"):
+		f.serveRegistryAPI(w, r, strings.TrimPrefix(path, "/registry/"))
+	default:
+		writeAPIError(w, http.StatusNotFound, "The resource you were accessing could not be found.")
+	}
+}
+
+// listApps lists all apps on one page
+func (f *FakeDigitalOcean) listApps(w http.ResponseWriter) {
+	f.mu.Lock()
+	apps := make([]map[string]interface{}, 0, len(f.apps))
+	for _, app := range f.apps {
+		f.advance(app)
+		apps = append(apps, app.raw())
+	}
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"apps": apps, "links": map[string]interface{}{}})
+}
+
+// createApp creates an app and starts its first deployment
+func (f *FakeDigitalOcean) createApp(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Spec == nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid app spec")
+		return
+	}
+	name, _ := request.Spec["name"].(string)
+	if name == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "spec.name is required")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, app := range f.apps {
+		if app.spec["name"] == name {
+			writeAPIError(w, http.StatusConflict, "an app with this name already exists")
+			return
+		}
+	}
+	app := f.newApp(request.Spec)
+	f.startDeployment(app)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"app": app.raw()})
+}
+
+// serveApp handles /apps/{id} and its deployments
+func (f *FakeDigitalOcean) serveApp(w http.ResponseWriter, r *http.Request, parts []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	app := f.findApp(parts[0])
+	if app == nil {
+		writeAPIError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		f.advance(app)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"app": app.raw()})
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		var request struct {
+			Spec map[string]interface{} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Spec == nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid app spec")
+			return
+		}
+		app.spec = request.Spec
+		app.updatedAt = time.Now()
+		f.startDeployment(app)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"app": app.raw()})
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		for i := range f.apps {
+			if f.apps[i] == app {
+				f.apps = append(f.apps[:i], f.apps[i+1:]...)
+				break
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": app.id})
+	case len(parts) == 2 && parts[1] == "deployments" && r.Method == http.MethodGet:
+		f.advance(app)
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		deployments := []*digitalocean.Deployment{}
+		for i := len(app.history) - 1; i >= 0 && (perPage <= 0 || len(deployments) < perPage); i-- {
+			deployments = append(deployments, app.history[i])
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deployments": deployments, "links": map[string]interface{}{}})
+	case len(parts) == 2 && parts[1] == "deployments" && r.Method == http.MethodPost:
+		deployment := f.startDeployment(app)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deployment": deployment})
+	case len(parts) == 4 && parts[1] == "deployments" && parts[3] == "cancel" && r.Method == http.MethodPost:
+		if app.inProgress == nil || app.inProgress.ID != parts[2] {
+			writeAPIError(w, http.StatusNotFound, "deployment not found")
+			return
+		}
+		app.inProgress.Phase = "CANCELED"
+		app.inProgress.UpdatedAt = time.Now()
+		canceled := *app.inProgress
+		app.inProgress = nil
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deployment": canceled})
+	default:
+		writeAPIError(w, http.StatusNotFound, "The resource you were accessing could not be found.")
+	}
+}
+
+// dockerCredentials returns the docker credentials of the fake registry
+func (f *FakeDigitalOcean) dockerCredentials(w http.ResponseWriter) {
+	auth := base64.StdEncoding.EncodeToString([]byte(fakeRegistryUser + ":" + fakeRegistryPassword))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"auths": map[string]interface{}{
+			"registry.digitalocean.com": map[string]string{"auth": auth},
+		},
+	})
+}
+
+// serveRegistryToken exchanges docker credentials (Basic auth) for a registry token
+func (f *FakeDigitalOcean) serveRegistryToken(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || user != fakeRegistryUser || password != fakeRegistryPassword {
+		writeAPIError(w, http.StatusUnauthorized, "invalid docker credentials")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token": fakeRegistryToken})
+}
+
+// serveRegistryAPI handles /registry/{name}/... (repositories, tags and garbage collection)
+func (f *FakeDigitalOcean) serveRegistryAPI(w http.ResponseWriter, r *http.Request, path string) {
+	registryName, rest, _ := strings.Cut(path, "/")
+
+	switch {
+	case rest == "repositoriesV2" && r.Method == http.MethodGet:
+		repositories := []map[string]string{}
+		for _, name := range f.registry.repositories(registryName) {
+			repositories = append(repositories, map[string]string{"name": name})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"repositories": repositories, "links": map[string]interface{}{}})
+	case rest == "garbage-collection" && r.Method == http.MethodGet:
+		f.activeGC(w)
+	case rest == "garbage-collection" && r.Method == http.MethodPost:
+		f.startGC(w, registryName)
+	case strings.HasPrefix(rest, "repositories/"):
+		f.serveRepositoryAPI(w, r, registryName, strings.TrimPrefix(rest, "repositories/"))
+	default:
+		writeAPIError(w, http.StatusNotFound, "The resource you were accessing could not be found.")
+	}
+}
+
+// serveRepositoryAPI handles the tags of a repository and deleting it
+// Repository names arrive path-escaped, so "a%2Fb" is the repository a/b
+func (f *FakeDigitalOcean) serveRepositoryAPI(w http.ResponseWriter, r *http.Request, registryName, path string) {
+	repo, rest, _ := strings.Cut(path, "/")
+	if unescaped, err := url.PathUnescape(repo); err == nil {
+		repo = unescaped
+	}
+	repository := registryName + "/" + repo
+
+	switch {
+	case rest == "" && r.Method == http.MethodDelete:
+		if !f.registry.deleteRepository(repository) {
+			writeAPIError(w, http.StatusNotFound, "repository not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case rest == "tags" && r.Method == http.MethodGet:
+		tags, ok := f.registry.tagList(repository)
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "repository not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tags": tags, "links": map[string]interface{}{}})
+	case strings.HasPrefix(rest, "tags/") && r.Method == http.MethodDelete:
+		if !f.registry.deleteTag(repository, strings.TrimPrefix(rest, "tags/")) {
+			writeAPIError(w, http.StatusNotFound, "tag not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusNotFound, "The resource you were accessing could not be found.")
+	}
+}
+
+// activeGC returns the last garbage collection, which finishes after GCPolls reads
+func (f *FakeDigitalOcean) activeGC(w http.ResponseWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.gc == nil {
+		writeAPIError(w, http.StatusNotFound, "no garbage collection")
+		return
+	}
+	f.gc.reads++
+	if f.gc.reads >= f.GCPolls && f.gc.gc.Active() {
+		f.gc.gc.Status = "succeeded"
+		f.gc.gc.UpdatedAt = time.Now()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"garbage_collection": f.gc.gc})
+}
+
+// startGC starts a garbage collection, unless one is running (409 Conflict)
+func (f *FakeDigitalOcean) startGC(w http.ResponseWriter, registryName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.gc != nil && f.gc.gc.Active() {
+		writeAPIError(w, http.StatusConflict, "a garbage collection is already running")
+		return
+	}
+	f.gcStarted++
+	now := time.Now()
+	f.gc = &fakeGC{gc: digitalocean.GarbageCollection{
+		UUID:         newID("gc", f.gcStarted),
+		RegistryName: registryName,
+		Status:       "requested",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"garbage_collection": f.gc.gc})
+}
+
+// newApp adds an app (caller must hold the lock)
+func (f *FakeDigitalOcean) newApp(spec map[string]interface{}) *fakeApp {
+	now := time.Now()
+	app := &fakeApp{
+		id:        newID("app", len(f.apps)+f.deployments+1),
+		spec:      spec,
+		createdAt: now,
+		updatedAt: now,
+	}
+	f.apps = append(f.apps, app)
+	return app
+}
+
+// findApp returns the app with an ID (caller must hold the lock)
+func (f *FakeDigitalOcean) findApp(id string) *fakeApp {
+	for _, app := range f.apps {
+		if app.id == id {
+			return app
+		}
+	}
+	return nil
+}
+
+// newDeploymentID returns the ID of a new deployment (caller must hold the lock)
+func (f *FakeDigitalOcean) newDeploymentID() string {
+	f.deployments++
+	return newID("deployment", f.deployments)
+}
+
+// startDeployment starts a deployment of an app, superseding one in progress (caller must hold the lock)
+func (f *FakeDigitalOcean) startDeployment(app *fakeApp) *digitalocean.Deployment {
+	now := time.Now()
+	if app.inProgress != nil {
+		app.inProgress.Phase = "SUPERSEDED"
+		app.inProgress.UpdatedAt = now
+	}
+	app.inProgress = f.newDeployment(app, deploymentPhases[0], now)
+	app.reads = 0
+	return app.inProgress
+}
+
+// newDeployment adds a deployment of the app's current spec to its history (caller must hold the lock)
+func (f *FakeDigitalOcean) newDeployment(app *fakeApp, phase string, now time.Time) *digitalocean.Deployment {
+	deployment := &digitalocean.Deployment{ID: f.newDeploymentID(), Phase: phase, CreatedAt: now, UpdatedAt: now}
+	var spec digitalocean.AppSpec
+	if data, err := json.Marshal(app.spec); err == nil && json.Unmarshal(data, &spec) == nil {
+		deployment.Spec = &spec
+	}
+	app.history = append(app.history, deployment)
+	return deployment
+}
+
+// advance moves an app's in-progress deployment on by one read (caller must hold the lock)
+// A finished deployment becomes the active one; a failed one only does if nothing was active,
+// so a failed redeploy leaves the site running its last deployment as App Platform does
+func (f *FakeDigitalOcean) advance(app *fakeApp) {
+	if app.inProgress == nil {
+		return
+	}
+
+	app.reads++
+	polls := f.DeployPolls
+	if polls < 1 {
+		polls = 1
+	}
+	if app.reads < polls {
+		app.inProgress.Phase = deploymentPhases[app.reads*len(deploymentPhases)/polls]
+		app.inProgress.UpdatedAt = time.Now()
+		return
+	}
+
+	finished := app.inProgress
+	app.inProgress = nil
+	app.updatedAt = time.Now()
+	finished.UpdatedAt = app.updatedAt
+	if f.FailDeployments {
+		finished.Phase = "ERROR"
+		if app.active == nil {
+			app.active = finished
+		}
+		return
+	}
+	finished.Phase = "ACTIVE"
+	app.active = finished
+}
+
+// raw returns the app as the API returns it, with the whole spec
+func (a *fakeApp) raw() map[string]interface{} {
+	name, _ := a.spec["name"].(string)
+	app := map[string]interface{}{
+		"id":              a.id,
+		"spec":            a.spec,
+		"default_ingress": "https://" + name + "-" + a.id + ".ondigitalocean.app",
+		"created_at":      a.createdAt,
+		"updated_at":      a.updatedAt,
+	}
+	if a.active != nil {
+		app["active_deployment"] = a.active
+		app["live_url"] = app["default_ingress"]
+	}
+	if a.inProgress != nil {
+		app["in_progress_deployment"] = a.inProgress
+	}
+	app["domains"] = a.domains()
+	return app
+}
+
+// domains returns the provisioning state of the spec's domains: active with a 90-day
+// certificate once a deployment is live, pending before
+func (a *fakeApp) domains() []map[string]interface{} {
+	domains := []map[string]interface{}{}
+	specs, _ := a.spec["domains"].([]interface{})
+	for _, item := range specs {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := spec["domain"].(string)
+		domain := map[string]interface{}{"id": a.id + "-" + name, "spec": spec, "phase": "PENDING"}
+		if a.active != nil {
+			domain["phase"] = "ACTIVE"
+			domain["certificate_expires_at"] = a.active.CreatedAt.Add(90 * 24 * time.Hour)
+		}
+		domains = append(domains, domain)
+	}
+	return domains
+}
+
+// view returns the app decoded as the client decodes it
+func (a *fakeApp) view() digitalocean.App {
+	var app digitalocean.App
+	data, _ := json.Marshal(a.raw())
+	json.Unmarshal(data, &app)
+	return app
+}
+
+// writeAPIError writes an error response in DigitalOcean's format
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"id": strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")), "message": message})
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,484 @@
+package testsupport
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Media types of the manifests and blobs the fake registry creates
+const (
+	mediaTypeManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeConfig   = "application/vnd.docker.container.image.v1+json"
+	mediaTypeLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// fakeRegistry is the in-memory storage of the fake registry
+// Blobs are shared by all repositories, like a registry that deduplicates storage
+type fakeRegistry struct {
+	mu      sync.Mutex
+	repos   map[string]*fakeRepository
+	blobs   map[string][]byte
+	uploads map[string][]byte
+}
+
+// fakeRepository holds the manifests and tags of a repository
+type fakeRepository struct {
+	manifests map[string]fakeManifest // By digest
+	tags      map[string]string       // Tag to manifest digest
+	updated   map[string]time.Time    // When each tag was last pushed
+}
+
+// fakeManifest is a stored manifest with the media type it was pushed with
+type fakeManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// newFakeRegistry creates empty registry storage
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		repos:   map[string]*fakeRepository{},
+		blobs:   map[string][]byte{},
+		uploads: map[string][]byte{},
+	}
+}
+
+// repository returns a repository, creating it if create is set (caller must hold the lock)
+func (r *fakeRegistry) repository(name string, create bool) *fakeRepository {
+	repo := r.repos[name]
+	if repo == nil && create {
+		repo = &fakeRepository{
+			manifests: map[string]fakeManifest{},
+			tags:      map[string]string{},
+			updated:   map[string]time.Time{},
+		}
+		r.repos[name] = repo
+	}
+	return repo
+}
+
+// putManifest stores a manifest and tags it if the reference is a tag, returning its digest
+func (r *fakeRegistry) putManifest(name, reference, mediaType string, body []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digest := digestOf(body)
+	repo := r.repository(name, true)
+	repo.manifests[digest] = fakeManifest{mediaType: mediaType, body: body}
+	if !strings.HasPrefix(reference, "sha256:") {
+		repo.tags[reference] = digest
+		repo.updated[reference] = time.Now()
+	}
+	return digest
+}
+
+// manifest returns a manifest by tag or digest
+func (r *fakeRegistry) manifest(name, reference string) (fakeManifest, string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name, false)
+	if repo == nil {
+		return fakeManifest{}, "", false
+	}
+	digest := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		digest = repo.tags[reference]
+	}
+	manifest, ok := repo.manifests[digest]
+	return manifest, digest, ok
+}
+
+// deleteManifest deletes a manifest by digest, with the tags pointing at it
+func (r *fakeRegistry) deleteManifest(name, digest string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name, false)
+	if repo == nil {
+		return false
+	}
+	if _, ok := repo.manifests[digest]; !ok {
+		return false
+	}
+	delete(repo.manifests, digest)
+	for tag, tagged := range repo.tags {
+		if tagged == digest {
+			delete(repo.tags, tag)
+			delete(repo.updated, tag)
+		}
+	}
+	return true
+}
+
+// putBlob stores a blob, returning its digest
+func (r *fakeRegistry) putBlob(data []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digest := digestOf(data)
+	r.blobs[digest] = data
+	return digest
+}
+
+// blob returns a blob by digest
+func (r *fakeRegistry) blob(digest string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.blobs[digest]
+	return data, ok
+}
+
+// startUpload starts a blob upload, returning its ID
+func (r *fakeRegistry) startUpload() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newID("upload", len(r.uploads)+len(r.blobs)+1)
+	r.uploads[id] = nil
+	return id
+}
+
+// appendUpload adds data to an upload, returning its size (-1 if there's no such upload)
+func (r *fakeRegistry) appendUpload(id string, data []byte) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[id]
+	if !ok {
+		return -1
+	}
+	upload = append(upload, data...)
+	r.uploads[id] = upload
+	return len(upload)
+}
+
+// finishUpload stores an upload as a blob if its content matches the digest
+func (r *fakeRegistry) finishUpload(id, digest string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[id]
+	if !ok || digestOf(upload) != digest {
+		return false
+	}
+	delete(r.uploads, id)
+	r.blobs[digest] = upload
+	return true
+}
+
+// pushImage stores a single-platform image with a config and one layer
+func (r *fakeRegistry) pushImage(name, tag, platform string) string {
+	goos, architecture, _ := strings.Cut(platform, "/")
+	config, _ := json.Marshal(map[string]interface{}{
+		"os":           goos,
+		"architecture": architecture,
+		"created":      time.Now().UTC(),
+		"config":       map[string]interface{}{"Labels": map[string]string{"org.opencontainers.image.title": name}},
+	})
+	layer := make([]byte, 64)
+	rand.Read(layer)
+
+	configDigest := r.putBlob(config)
+	layerDigest := r.putBlob(layer)
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeManifest,
+		"config":        map[string]interface{}{"mediaType": mediaTypeConfig, "digest": configDigest, "size": len(config)},
+		"layers":        []map[string]interface{}{{"mediaType": mediaTypeLayer, "digest": layerDigest, "size": len(layer)}},
+	})
+	return r.putManifest(name, tag, mediaTypeManifest, manifest)
+}
+
+// tags returns the tags of a repository, sorted
+func (r *fakeRegistry) tags(name string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name, false)
+	if repo == nil {
+		return []string{}
+	}
+	return sortedKeys(repo.tags)
+}
+
+// tagList returns the tags of a repository as the registry API lists them
+func (r *fakeRegistry) tagList(name string) ([]digitalocean.Tag, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name, false)
+	if repo == nil {
+		return nil, false
+	}
+	tags := []digitalocean.Tag{}
+	for _, tag := range sortedKeys(repo.tags) {
+		tags = append(tags, digitalocean.Tag{Tag: tag, UpdatedAt: repo.updated[tag]})
+	}
+	return tags, true
+}
+
+// repositories returns the repositories of a registry, without the registry name
+func (r *fakeRegistry) repositories(registryName string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := []string{}
+	for name := range r.repos {
+		if repo, ok := strings.CutPrefix(name, registryName+"/"); ok {
+			names = append(names, repo)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deleteTag removes a tag from a repository
+func (r *fakeRegistry) deleteTag(name, tag string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name, false)
+	if repo == nil {
+		return false
+	}
+	if _, ok := repo.tags[tag]; !ok {
+		return false
+	}
+	delete(repo.tags, tag)
+	delete(repo.updated, tag)
+	return true
+}
+
+// deleteRepository removes a repository
+func (r *fakeRegistry) deleteRepository(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.repos[name]; !ok {
+		return false
+	}
+	delete(r.repos, name)
+	return true
+}
+
+// serveRegistry implements the registry v2 API: manifests, blobs, blob uploads and tag lists
+// Requests need the registry token handed out by the fake API's /v2/registry/auth, and get a
+// Bearer challenge pointing there without it, like registry.digitalocean.com
+func (f *FakeDigitalOcean) serveRegistry(w http.ResponseWriter, r *http.Request) {
+	if status := f.faults.check(r); status != 0 {
+		writeRegistryError(w, status, "UNAVAILABLE", "injected fault")
+		return
+	}
+
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Header.Get("Authorization") != "Bearer "+fakeRegistryToken {
+		challenge := fmt.Sprintf(`Bearer realm="%s/v2/registry/auth",service="registry.digitalocean.com"`, f.API.URL)
+		if name, _, ok := splitRegistryPath(r.URL.Path); ok {
+			challenge += fmt.Sprintf(`,scope="repository:%s:pull,push"`, name)
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		writeRegistryError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+		return
+	}
+
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+		return
+	}
+
+	name, rest, ok := splitRegistryPath(r.URL.Path)
+	if !ok {
+		writeRegistryError(w, http.StatusNotFound, "NAME_UNKNOWN", "unknown path")
+		return
+	}
+
+	switch {
+	case rest == "tags/list" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"name": name, "tags": f.registry.tags(name)})
+	case strings.HasPrefix(rest, "manifests/"):
+		f.serveManifest(w, r, name, strings.TrimPrefix(rest, "manifests/"))
+	case rest == "blobs/uploads/" || rest == "blobs/uploads":
+		f.startBlobUpload(w, r, name)
+	case strings.HasPrefix(rest, "blobs/uploads/"):
+		f.serveBlobUpload(w, r, name, strings.TrimPrefix(rest, "blobs/uploads/"))
+	case strings.HasPrefix(rest, "blobs/"):
+		f.serveBlob(w, r, strings.TrimPrefix(rest, "blobs/"))
+	default:
+		writeRegistryError(w, http.StatusNotFound, "NAME_UNKNOWN", "unknown path")
+	}
+}
+
+// serveManifest gets, checks, pushes or deletes a manifest
+func (f *FakeDigitalOcean) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		manifest, digest, ok := f.registry.manifest(name, reference)
+		if !ok {
+			writeRegistryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", fmt.Sprint(len(manifest.body)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(manifest.body)
+		}
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || !json.Valid(body) {
+			writeRegistryError(w, http.StatusBadRequest, "MANIFEST_INVALID", "manifest invalid")
+			return
+		}
+		mediaType := r.Header.Get("Content-Type")
+		if mediaType == "" {
+			mediaType = mediaTypeManifest
+		}
+		digest := f.registry.putManifest(name, reference, mediaType, body)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Location", "/v2/"+name+"/manifests/"+digest)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if !f.registry.deleteManifest(name, reference) {
+			writeRegistryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+// serveBlob gets or checks a blob
+func (f *FakeDigitalOcean) serveBlob(w http.ResponseWriter, r *http.Request, digest string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	data, ok := f.registry.blob(digest)
+	if !ok {
+		writeRegistryError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// startBlobUpload starts an upload, or stores the blob at once for monolithic uploads (?digest=)
+// Cross-repository mounts succeed when the blob exists, since blobs are shared
+func (f *FakeDigitalOcean) startBlobUpload(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+
+	if mount := r.URL.Query().Get("mount"); mount != "" {
+		if _, ok := f.registry.blob(mount); ok {
+			w.Header().Set("Docker-Content-Digest", mount)
+			w.Header().Set("Location", "/v2/"+name+"/blobs/"+mount)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+	}
+
+	if digest := r.URL.Query().Get("digest"); digest != "" {
+		data, _ := io.ReadAll(r.Body)
+		if digestOf(data) != digest {
+			writeRegistryError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match content")
+			return
+		}
+		f.registry.putBlob(data)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Location", "/v2/"+name+"/blobs/"+digest)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	id := f.registry.startUpload()
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+	w.Header().Set("Range", "0-0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveBlobUpload adds a chunk to an upload (PATCH) or finishes it (PUT with ?digest=)
+func (f *FakeDigitalOcean) serveBlobUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeRegistryError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", "failed to read upload")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		size := f.registry.appendUpload(id, data)
+		if size < 0 {
+			writeRegistryError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload unknown")
+			return
+		}
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", max(size-1, 0)))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		if f.registry.appendUpload(id, data) < 0 {
+			writeRegistryError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload unknown")
+			return
+		}
+		digest := r.URL.Query().Get("digest")
+		if !f.registry.finishUpload(id, digest) {
+			writeRegistryError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match content")
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Location", "/v2/"+name+"/blobs/"+digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeRegistryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+// splitRegistryPath splits /v2/{name}/{rest} at the first manifests/, blobs/ or tags/ segment
+func splitRegistryPath(path string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(path, "/v2/")
+	if !ok {
+		return "", "", false
+	}
+	for _, marker := range []string{"/manifests/", "/blobs/", "/tags/"} {
+		if i := strings.Index(rest, marker); i > 0 {
+			return rest[:i], rest[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// writeRegistryError writes an error response in the registry v2 format
+func writeRegistryError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
+
+// digestOf returns the sha256 digest of content
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+// Package testsupport runs fake DigitalOcean (App Platform, container registry) and Cloudflare
+// APIs on local httptest servers, so the operator can be exercised end to end without live
+// credentials. Point the operator at them with DIGITALOCEAN_API_URL, CLOUDFLARE_API_URL and
+// UPSTREAM_REGISTRY (see cmd/fakecloud), or set the base URLs of the clients directly.
+//
+// The fakes keep their state in memory and only implement the calls the operator makes.
+// Behaviors (how long deployments take, whether they fail, injected error responses) are
+// set on the fakes, and every request is recorded for assertions.
+package testsupport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Fault makes a fake answer matching requests with an error status instead of handling them
+type Fault struct {
+	Method string // Empty matches any method
+	Path   string // Path prefix a request must have, e.g. /v2/apps
+	Status int    // Status to answer with, e.g. 500 or 429
+	Times  int    // Number of requests to fail (0 fails every matching request)
+}
+
+// faults holds the injected faults and the request log of a fake
+type faults struct {
+	mu       sync.Mutex
+	list     []*Fault
+	requests []string
+}
+
+// add injects a fault
+func (f *faults) add(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = append(f.list, &fault)
+}
+
+// clear removes all injected faults
+func (f *faults) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = nil
+}
+
+// check records a request and returns the status of the fault matching it (0 if none)
+// Faults with a number of times are used up by the requests they fail
+func (f *faults) check(r *http.Request) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+	for i, fault := range f.list {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				f.list = append(f.list[:i], f.list[i+1:]...)
+			}
+		}
+		return fault.Status
+	}
+	return 0
+}
+
+// log returns the requests made so far as "METHOD /path?query"
+func (f *faults) log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// count returns the number of requests made with a method to paths with a prefix
+func (f *faults) count(method, prefix string) int {
+	n := 0
+	for _, request := range f.log() {
+		m, uri, _ := strings.Cut(request, " ")
+		if (method == "" || m == method) && strings.HasPrefix(uri, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// writeJSON writes a JSON response with a status code
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// newID returns a fake resource ID with a prefix and a sequence number
+func newID(prefix string, n int) string {
+	return fmt.Sprintf("%s-%08d", prefix, n)
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/json"
 	"encoding/pem"
 	"flag"
 	"fmt"
@@ -13,16 +15,20 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	models "lightspeed/core/lib/api"
+	"lightspeed/core/lib/digitalocean"
 	"lightspeed/core/lib/ui"
 	"lightspeed/core/lib/version"
 	"lightspeed/platform/operator/api"
 	"lightspeed/platform/operator/config"
 	"lightspeed/platform/operator/proxy"
 	"lightspeed/platform/operator/registry"
+	"lightspeed/platform/operator/worker"
 )
 
 // Version is set by ldflags during build
@@ -34,10 +40,36 @@ var (
 	publicHost       string
 	upstreamRegistry string
 	defaultRegistry  string
+	digitalOceanAPI  string
+	cloudflareAPI    string
 	showVersion      bool
 	tlsEnabled       bool
 	tlsCert          string
 	tlsKey           string
+	immutableTags    string
+	templatesFile    string
+	baseDomainsFile  string
+	buildNumbersFile string
+	uptimeFile       string
+	incidentsFile    string
+	incidentWebhook  string
+	syntheticFile    string
+	accessTokensFile string
+	edgeHost         string
+	edgeFile         string
+	edgeCert         string
+	edgeKey          string
+	cacheURL         string
+	cachesFile       string
+	queuesFile       string
+	cronFile         string
+	pullSecretsFile  string
+	policiesFile     string
+	notificationFile string
+	requireAuth      bool
+	sloTarget        float64
+	diskLow          float64
+	diskCritical     float64
 )
 
 func init() {
@@ -51,11 +83,37 @@ func init() {
 	flag.StringVar(&upstreamRegistry, "u", defaults.UpstreamRegistry, "Upstream registry (shorthand)")
 	flag.StringVar(&defaultRegistry, "registry", defaults.DefaultRegistry, "Default container registry name")
 	flag.StringVar(&defaultRegistry, "r", defaults.DefaultRegistry, "Default registry (shorthand)")
+	flag.StringVar(&digitalOceanAPI, "digitalocean-api", defaults.DigitalOceanAPI, "DigitalOcean API base URL, e.g. of a fake API for testing (api.digitalocean.com if empty)")
+	flag.StringVar(&cloudflareAPI, "cloudflare-api", defaults.CloudflareAPI, "Cloudflare API base URL, e.g. of a fake API for testing (api.cloudflare.com if empty)")
 	flag.BoolVar(&showVersion, "version", false, "Show version and exit")
 	flag.BoolVar(&showVersion, "v", false, "Show version (shorthand)")
 	flag.BoolVar(&tlsEnabled, "tls", defaults.TLSEnabled, "Enable TLS/HTTPS")
 	flag.StringVar(&tlsCert, "cert", defaults.TLSCert, "TLS certificate file (auto-generated if empty)")
 	flag.StringVar(&tlsKey, "key", defaults.TLSKey, "TLS private key file (auto-generated if empty)")
+	flag.StringVar(&templatesFile, "templates", defaults.TemplatesFile, "JSON file the site template catalog is saved to (in-memory if empty)")
+	flag.StringVar(&baseDomainsFile, "base-domains", defaults.BaseDomainsFile, "JSON file tenant base domains and their DNS tokens are saved to (in-memory if empty)")
+	flag.StringVar(&buildNumbersFile, "build-numbers", defaults.BuildNumbersFile, "JSON file allocated build numbers are saved to (in-memory if empty)")
+	flag.StringVar(&uptimeFile, "uptime", defaults.UptimeFile, "JSON file uptime checks of sites are saved to (in-memory if empty)")
+	flag.StringVar(&incidentsFile, "incidents", defaults.IncidentsFile, "JSON file incidents are saved to (in-memory if empty)")
+	flag.StringVar(&incidentWebhook, "incident-webhook", defaults.IncidentWebhook, "URL incident events are posted to as JSON (Slack-compatible)")
+	flag.StringVar(&syntheticFile, "synthetic-checks", defaults.SyntheticFile, "JSON file synthetic checks of sites are saved to (in-memory if empty)")
+	flag.StringVar(&accessTokensFile, "access-tokens", defaults.AccessTokensFile, "JSON file hashes of CLI access tokens are saved to (in-memory if empty)")
+	flag.StringVar(&edgeHost, "edge-host", defaults.EdgeHost, "Hostname the domains of sites in edge mode are CNAMEd to (edge mode is off if empty)")
+	flag.StringVar(&edgeFile, "edge", defaults.EdgeFile, "JSON file edge settings of sites are saved to (in-memory if empty)")
+	flag.StringVar(&edgeCert, "edge-cert", defaults.EdgeCert, "Wildcard TLS certificate file for *.lightspeed.ee, served to edge sites with --tls")
+	flag.StringVar(&edgeKey, "edge-key", defaults.EdgeKey, "Private key file of the edge certificate")
+	flag.StringVar(&cacheURL, "cache-url", defaults.CacheURL, "Admin URL of a shared Redis/Valkey instance for shared site caches (redis:// or rediss://)")
+	flag.StringVar(&cachesFile, "caches", defaults.CachesFile, "JSON file the users of shared site caches are saved to (in-memory if empty)")
+	flag.StringVar(&queuesFile, "queues", defaults.QueuesFile, "JSON file the jobs of site queues are saved to (in-memory if empty)")
+	flag.StringVar(&cronFile, "cron", defaults.CronFile, "JSON file cron jobs of sites are saved to (in-memory if empty)")
+	flag.StringVar(&pullSecretsFile, "pull-secrets", defaults.PullSecretsFile, "JSON file logins for private base image registries are saved to (in-memory if empty)")
+	flag.StringVar(&policiesFile, "policies", defaults.PoliciesFile, "JSON file site spec policies are saved to (in-memory if empty)")
+	flag.StringVar(&notificationFile, "notifications", defaults.NotificationFile, "JSON file notification destinations and held events are saved to (in-memory if empty)")
+	flag.BoolVar(&requireAuth, "require-auth", defaults.RequireAuth, "Reject /sites and registry requests without an access token from lightspeed login")
+	flag.Float64Var(&sloTarget, "slo-target", defaults.SLOTarget, "Monthly availability target in percent sites' error budgets are computed from")
+	flag.Float64Var(&diskLow, "disk-low", defaults.DiskLowPercent, "Free disk space percent below which health reports the state directories as low")
+	flag.Float64Var(&diskCritical, "disk-critical", defaults.DiskCritPercent, "Free disk space percent below which state-changing requests are rejected with 503")
+	flag.StringVar(&immutableTags, "immutable-tags", defaults.ImmutableTags, "Reject overwriting pushed tags: 'all' or comma-separated repositories")
 }
 
 func main() {
@@ -87,13 +145,52 @@ func main() {
 		PublicHost:       publicHost,
 		UpstreamRegistry: upstreamRegistry,
 		DefaultRegistry:  defaultRegistry,
+		DigitalOceanAPI:  digitalOceanAPI,
+		CloudflareAPI:    cloudflareAPI,
 		OperatorURL:      fullCfg.OperatorURL,
-		OperatorToken:    fullCfg.OperatorToken,
+		AdminToken:       fullCfg.AdminToken,
+		ImmutableTags:    immutableTags,
+		TemplatesFile:    templatesFile,
+		BaseDomainsFile:  baseDomainsFile,
+		BuildNumbersFile: buildNumbersFile,
+		UptimeFile:       uptimeFile,
+		IncidentsFile:    incidentsFile,
+		IncidentWebhook:  incidentWebhook,
+		SyntheticFile:    syntheticFile,
+		AccessTokensFile: accessTokensFile,
+		EdgeHost:         edgeHost,
+		EdgeFile:         edgeFile,
+		EdgeCert:         edgeCert,
+		EdgeKey:          edgeKey,
+		CacheURL:         cacheURL,
+		CachesFile:       cachesFile,
+		QueuesFile:       queuesFile,
+		CronFile:         cronFile,
+		PullSecretsFile:  pullSecretsFile,
+		PoliciesFile:     policiesFile,
+		NotificationFile: notificationFile,
+		RequireAuth:      requireAuth,
+		SLOTarget:        sloTarget,
+		DiskLowPercent:   diskLow,
+		DiskCritPercent:  diskCritical,
 	}
 
+	// Point the API clients at other endpoints (e.g. the fakes in internal/testsupport)
+	// before any are created
+	digitalocean.SetDefaultBaseURL(cfg.DigitalOceanAPI)
+	api.SetCloudflareAPI(cfg.CloudflareAPI)
+
 	// Create router
 	mux := http.NewServeMux()
 
+	// CLI access tokens (lightspeed login); approving logins and issuing tokens needs the admin token
+	auth, err := api.NewAuthHandler(cfg.AccessTokensFile, cfg.AdminToken, cfg.RequireAuth)
+	if err != nil {
+		ui.PrintError("Failed to load access tokens: %v", err)
+		os.Exit(1)
+	}
+	mux.Handle("/auth/", auth)
+
 	// Registry proxy for /v2/
 	registryProxy, err := proxy.NewRegistryProxy(cfg.UpstreamRegistry, cfg.PublicHost)
 	if err != nil {
@@ -102,15 +199,166 @@ func main() {
 	}
 	registryProxy.SetAuthToken(config.GetDOToken())
 	registryProxy.SetRegistryName(cfg.DefaultRegistry)
-	mux.Handle("/v2/", registryProxy)
+	tagPolicy := proxy.ParseTagPolicy(cfg.ImmutableTags)
+	registryProxy.SetTagPolicy(tagPolicy)
+	mux.Handle("/v2/", auth.Require(registryProxy, true))
+	mux.Handle("/registry/health", auth.Require(http.HandlerFunc(registryProxy.HealthHandler), false))
+	mux.Handle("/metrics", auth.Require(http.HandlerFunc(registryProxy.MetricsHandler), false))
 
 	// Sites API - uses built-in DO and CF tokens
-	sitesHandler := api.NewSitesHandler(config.GetDOToken(), cfg.DefaultRegistry, config.GetCFToken(), cfg.OperatorURL, cfg.OperatorToken)
-	mux.Handle("/sites", sitesHandler)
-	mux.Handle("/sites/", sitesHandler)
+	sitesHandler := api.NewSitesHandler(config.GetDOToken(), cfg.DefaultRegistry, config.GetCFToken(), cfg.OperatorURL)
+	sitesHandler.SetImageInspector(registryProxy)
+	sitesHandler.SetAuth(auth)
+
+	// Site template catalog - registering templates requires the admin token
+	templatesHandler, err := api.NewTemplatesHandler(cfg.TemplatesFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load templates: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetTemplates(templatesHandler)
+	mux.Handle("/templates", templatesHandler)
+	mux.Handle("/templates/", templatesHandler)
+
+	// Logins for private base image registries, handed to builds of images based on them
+	pullSecretsHandler, err := api.NewPullSecretsHandler(cfg.PullSecretsFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load pull secrets: %v", err)
+		os.Exit(1)
+	}
+	mux.Handle("/pull-secrets", pullSecretsHandler)
+	mux.Handle("/pull-secrets/", pullSecretsHandler)
+
+	// Tenant base domains - registering a domain requires a DNS token for its zone
+	baseDomainsHandler, err := api.NewBaseDomainsHandler(cfg.BaseDomainsFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load base domains: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetBaseDomains(baseDomainsHandler)
+	mux.Handle("/base-domains", baseDomainsHandler)
+	mux.Handle("/base-domains/", baseDomainsHandler)
+
+	// Build numbers for tags allocated by CI
+	buildNumbers, err := api.NewBuildNumbers(cfg.BuildNumbersFile)
+	if err != nil {
+		ui.PrintError("Failed to load build numbers: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetBuildNumbers(buildNumbers)
+
+	// Organization policies every site spec is checked against - managing them requires the admin token
+	policies, err := api.NewPoliciesHandler(cfg.PoliciesFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load policies: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetPolicies(policies)
+	mux.Handle("/admin/policies", policies)
+	mux.Handle("/admin/policies/", policies)
+
+	// Notification destinations deploys, image prunes and incidents are posted to - managing them requires the admin token
+	notifications, err := api.NewNotificationsHandler(cfg.NotificationFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load notification destinations: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetNotifications(notifications)
+	mux.Handle("/admin/notifications", notifications)
+	mux.Handle("/admin/notifications/", notifications)
+	mux.Handle("/sites", auth.Require(sitesHandler, false))
+	mux.Handle("/sites/", auth.Require(sitesHandler, false))
+
+	// Uptime monitor (checks every deployed site each minute), synthetic checks, incidents and tenant status pages
+	uptimeMonitor, err := api.NewUptimeMonitor(sitesHandler, time.Minute, cfg.UptimeFile)
+	if err != nil {
+		ui.PrintError("Failed to load uptime checks: %v", err)
+		os.Exit(1)
+	}
+	incidents, err := api.NewIncidentLog(cfg.IncidentsFile, cfg.IncidentWebhook)
+	if err != nil {
+		ui.PrintError("Failed to load incidents: %v", err)
+		os.Exit(1)
+	}
+	incidents.SetNotifications(notifications)
+	uptimeMonitor.SetIncidents(incidents)
+	synthetic, err := api.NewSyntheticChecks(cfg.SyntheticFile)
+	if err != nil {
+		ui.PrintError("Failed to load synthetic checks: %v", err)
+		os.Exit(1)
+	}
+	uptimeMonitor.SetSyntheticChecks(synthetic)
+	sitesHandler.SetSyntheticChecks(synthetic)
+	if cfg.SLOTarget <= 0 || cfg.SLOTarget >= 100 {
+		ui.PrintError("Invalid --slo-target %v: must be a percentage between 0 and 100", cfg.SLOTarget)
+		os.Exit(1)
+	}
+	sitesHandler.SetUptime(uptimeMonitor, cfg.SLOTarget)
+
+	// Edge proxy for sites in edge mode (basic auth, maintenance page, IP rules, request logs)
+	edge, err := api.NewEdgeProxy(cfg.EdgeHost, cfg.EdgeFile, auth)
+	if err != nil {
+		ui.PrintError("Failed to load edge settings: %v", err)
+		os.Exit(1)
+	}
+	if (cfg.EdgeCert == "") != (cfg.EdgeKey == "") {
+		ui.PrintError("--edge-cert and --edge-key must be set together")
+		os.Exit(1)
+	}
+	sitesHandler.SetEdge(edge)
+
+	// Shared cache instance sites can get a cache with its own user and key prefix on
+	sharedCache, err := api.NewSharedCache(cfg.CacheURL, cfg.CachesFile)
+	if err != nil {
+		ui.PrintError("Failed to load shared cache: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetSharedCache(sharedCache)
+
+	// Job queues sites push background jobs to, run by their queue worker
+	jobQueues, err := api.NewJobQueues(cfg.QueuesFile)
+	if err != nil {
+		ui.PrintError("Failed to load job queues: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetJobQueues(jobQueues)
+
+	// Cron jobs that request site URLs on schedules
+	webCron, err := api.NewWebCron(sitesHandler, cfg.CronFile)
+	if err != nil {
+		ui.PrintError("Failed to load cron jobs: %v", err)
+		os.Exit(1)
+	}
+	sitesHandler.SetCron(webCron)
+	mux.Handle("/incidents", auth.Require(incidents, false))
+	mux.Handle("/incidents/", auth.Require(incidents, false))
+	if operatorURL, err := url.Parse(cfg.OperatorURL); err == nil {
+		baseDomainsHandler.SetStatusHost(operatorURL.Hostname())
+	}
+	statusPages := api.NewStatusPageHandler(uptimeMonitor, incidents, baseDomainsHandler)
+	mux.Handle("/status/", statusPages)
+
+	// Image inspection through the registry proxy
+	mux.Handle("/images/", auth.Require(api.NewImagesHandler(registryProxy), false))
+
+	// Disk space of the state file directories
+	if cfg.DiskCritPercent < 0 || cfg.DiskCritPercent > cfg.DiskLowPercent || cfg.DiskLowPercent >= 100 {
+		ui.PrintError("Invalid --disk-low %v / --disk-critical %v: need 0 <= critical <= low < 100", cfg.DiskLowPercent, cfg.DiskCritPercent)
+		os.Exit(1)
+	}
+	diskGuard := api.NewDiskGuard([]string{
+		cfg.TemplatesFile, cfg.BaseDomainsFile, cfg.BuildNumbersFile, cfg.UptimeFile,
+		cfg.IncidentsFile, cfg.SyntheticFile, cfg.AccessTokensFile, cfg.EdgeFile,
+		cfg.CachesFile, cfg.QueuesFile, cfg.CronFile, cfg.PullSecretsFile, cfg.PoliciesFile,
+		cfg.NotificationFile,
+	}, cfg.DiskLowPercent, cfg.DiskCritPercent, time.Minute)
+
+	// Background workers are supervised, so a panic restarts the worker instead of ending it
+	workers := worker.NewSupervisor()
+	mux.Handle("/workers", auth.Require(workers, false))
 
 	// Health and version
-	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/health", handleHealth(registryProxy, diskGuard))
 	mux.HandleFunc("/version", handleVersion)
 
 	// Root
@@ -125,25 +373,125 @@ func main() {
 		ui.PrintKeyValue("  TLS", "enabled")
 	}
 	ui.PrintKeyValue("  Upstream", cfg.UpstreamRegistry)
+	if cfg.DigitalOceanAPI != "" {
+		ui.PrintKeyValue("  DigitalOcean API", cfg.DigitalOceanAPI)
+	}
+	if cfg.CloudflareAPI != "" {
+		ui.PrintKeyValue("  Cloudflare API", cfg.CloudflareAPI)
+	}
+	if tagPolicy != nil {
+		ui.PrintKeyValue("  Immutable tags", tagPolicy.String())
+	}
+	if cfg.RequireAuth {
+		ui.PrintKeyValue("  Auth", "required")
+	}
+	if cfg.AdminToken == "" {
+		ui.PrintWarning("No ADMIN_TOKEN set: logins can't be approved, and sites get no site token or cron key")
+	}
+	if edge.Enabled() {
+		ui.PrintKeyValue("  Edge", cfg.EdgeHost)
+	}
+	if sharedCache.Enabled() {
+		if cacheURL, err := url.Parse(cfg.CacheURL); err == nil {
+			ui.PrintKeyValue("  Shared cache", cacheURL.Redacted())
+		}
+	}
 	fmt.Println()
 	ui.PrintInfo("Endpoints:")
 	fmt.Println("  • /v2/*                     - Registry proxy (push & pull)")
 	fmt.Println("  • GET /sites                - List all sites")
-	fmt.Println("  • POST /sites               - Create a site")
+	fmt.Println("  • POST /sites               - Create a site (?dry_run=true returns the spec)")
 	fmt.Println("  • GET /sites/{name}         - Get site details")
-	fmt.Println("  • DELETE /sites/{name}      - Delete a site")
-	fmt.Println("  • POST /sites/{name}/deploy - Trigger deployment")
-	fmt.Println("  • /health                   - Health check")
+	fmt.Println("  • DELETE /sites/{name}      - Delete a site (?image=true&dns=true, ?dry_run=true)")
+	fmt.Println("  • PATCH /sites/{name}       - Change instance count or size (?dry_run=true)")
+	fmt.Println("  • POST /sites/{name}/deploy - Trigger deployment (optionally pinned to a tag, ?dry_run=true)")
+	fmt.Println("  • GET /sites/{name}/tags    - List image tags, newest first")
+	fmt.Println("  • POST /sites/{name}/tags/next - Allocate the next build number or dated tag")
+	fmt.Println("  • GET /sites/{name}/release - Get the release ID of the running image")
+	fmt.Println("  • GET /sites/{name}/deployments - List recent deployments, newest first")
+	fmt.Println("  • GET /sites/{name}/slo     - Availability and error budget this month (?target=)")
+	fmt.Println("  • GET/POST /sites/{name}/env - List or change environment variables")
+	fmt.Println("  • GET/PUT/DELETE /sites/{name}/checks - Manage synthetic checks")
+	fmt.Println("  • GET/PUT/DELETE /sites/{name}/edge - Manage edge mode (auth, maintenance, request logs)")
+	fmt.Println("  • GET/PUT/DELETE /sites/{name}/firewall - Manage IP allow/deny rules of an edge site")
+	fmt.Println("  • GET/POST/DELETE /sites/{name}/cache - Manage the site's Redis/Valkey cache")
+	fmt.Println("  • GET /sites/{name}/queues  - Job counts of the site's queues")
+	fmt.Println("  • POST /sites/{name}/queues/{q}/jobs|pop - Push or pop a job")
+	fmt.Println("  • DELETE /sites/{name}/queues/{q}/jobs/{id} - Acknowledge a job")
+	fmt.Println("  • GET /sites/{name}/queues/{q}/failed - List failed jobs (POST .../retry to retry them)")
+	fmt.Println("  • DELETE /sites/{name}/queues/{q} - Delete every job of a queue")
+	fmt.Println("  • GET/PUT/DELETE /sites/{name}/worker - Manage the site's queue worker")
+	fmt.Println("  • GET/PUT/DELETE /sites/{name}/cron - Manage cron jobs requesting site URLs")
+	fmt.Println("  • POST /sites/{name}/cron/{job} - Run a cron job now")
+	fmt.Println("  • GET/POST/DELETE /sites/{name}/domains - Manage custom domains")
+	fmt.Println("  • POST /sites/{name}/cancel - Cancel in-progress deployment")
+	fmt.Println("  • GET /sites/{name}/logs    - Stream build, deploy or run logs")
+	fmt.Println("  • POST /sites/{name}/email  - Provision SPF/DKIM/DMARC records")
+	fmt.Println("  • GET/POST/DELETE /sites/{name}/dns - Manage DNS records in the site's domain")
+	fmt.Println("  • POST /sites/{name}/proxy  - Turn Cloudflare proxy mode on or off")
+	fmt.Println("  • POST /sites/{name}/purge  - Purge the Cloudflare cache of the site's domain")
+	fmt.Println("  • GET /templates            - List site templates")
+	fmt.Println("  • POST /templates           - Register a template (admin)")
+	fmt.Println("  • GET /templates/{name}     - Get a template")
+	fmt.Println("  • DELETE /templates/{name}  - Delete a template (admin)")
+	fmt.Println("  • GET/POST /pull-secrets    - List or register private registry logins (admin)")
+	fmt.Println("  • DELETE /pull-secrets/{registry} - Delete a registry login (admin)")
+	fmt.Println("  • POST /pull-secrets/resolve - Get the login of a build's base image")
+	fmt.Println("  • GET/POST /admin/policies  - List or save site spec policies (admin)")
+	fmt.Println("  • GET/DELETE /admin/policies/{name} - Get or delete a policy (admin)")
+	fmt.Println("  • GET/POST /admin/notifications - List or save notification destinations (admin)")
+	fmt.Println("  • GET/DELETE /admin/notifications/{name} - Get or delete a destination (admin)")
+	fmt.Println("  • GET /base-domains         - List tenant base domains")
+	fmt.Println("  • POST /base-domains        - Register a base domain with a DNS token")
+	fmt.Println("  • GET /base-domains/{d}/setup - DNS delegation steps for a domain")
+	fmt.Println("  • DELETE /base-domains/{d}  - Remove a base domain")
+	fmt.Println("  • GET /status/{tenant}      - Public status page of a base domain's sites (?format=json)")
+	fmt.Println("  • GET /incidents            - List incidents (?site=, ?status=open|resolved)")
+	fmt.Println("  • GET /incidents/{id}       - Get an incident")
+	fmt.Println("  • POST /auth/device         - Start a device code login")
+	fmt.Println("  • GET/POST /auth/activate   - Approve a login code (admin)")
+	fmt.Println("  • POST /auth/token          - Poll a device code for its access token")
+	fmt.Println("  • DELETE /auth/token        - Revoke the request's access token")
+	fmt.Println("  • POST /auth/tokens         - Issue an access token (admin)")
+	fmt.Println("  • GET /auth/whoami          - Describe the request's access token")
+	fmt.Println("  • GET /images/{repo}/{tag}  - Inspect an image manifest")
+	fmt.Println("  • /registry/health          - Upstream registry status")
+	fmt.Println("  • /metrics                  - Registry proxy metrics")
+	fmt.Println("  • /health                   - Health check (registry GC, disk space)")
+	fmt.Println("  • /workers                  - Background worker status and crash counts")
 	fmt.Println("  • /version                  - Version info")
 	fmt.Println()
 
 	// Start image pruner (runs daily, after startup messages)
 	pruner := registry.NewPruner(config.GetDOToken(), cfg.DefaultRegistry)
-	pruner.Start()
+	pruner.SetNotify(func(text string) { notifications.Notify(models.NotificationPrune, "", text) })
+	workers.Go("pruner", pruner.Run)
+
+	// Start notification summaries (sends daily digests and what quiet hours held each minute)
+	workers.Go("notifications", notifications.Run)
 
 	// Start DNS sync worker (runs every 30 seconds)
 	dnsWorker := api.NewDNSSyncWorker(sitesHandler, 30*time.Second)
-	dnsWorker.Start()
+	workers.Go("dns-sync", dnsWorker.Run)
+
+	// Start site reaper (deletes expired temporary sites every 5 minutes)
+	reaper := api.NewSiteReaper(sitesHandler, 5*time.Minute)
+	workers.Go("reaper", reaper.Run)
+
+	// Start uptime monitor
+	workers.Go("uptime", uptimeMonitor.Run)
+
+	// Start cron runner (runs due cron jobs every minute)
+	workers.Go("cron", webCron.Run)
+
+	// Start disk guard (checks free space every minute)
+	if diskGuard.Enabled() {
+		workers.Go("disk", diskGuard.Run)
+	}
+
+	// Tenants' status page domains and edge sites are served by host; state changes are held
+	// while disk space is critically low
+	handler := diskGuard.Guard(statusPages.CustomDomains(edge.Serve(mux)))
 
 	if tlsEnabled {
 		// Generate or use provided certs
@@ -152,12 +500,36 @@ func main() {
 			ui.PrintError("Failed to setup TLS: %v", err)
 			os.Exit(1)
 		}
-		log.Fatal(http.ListenAndServeTLS(addr, certFile, keyFile, mux))
+		server := &http.Server{Addr: addr, Handler: handler}
+		if cfg.EdgeCert != "" {
+			// The edge certificate is chosen by SNI for the hostnames it covers
+			server.TLSConfig, err = edgeTLSConfig(certFile, keyFile, cfg.EdgeCert, cfg.EdgeKey)
+			if err != nil {
+				ui.PrintError("Failed to load edge certificate: %v", err)
+				os.Exit(1)
+			}
+			certFile, keyFile = "", ""
+		}
+		log.Fatal(server.ListenAndServeTLS(certFile, keyFile))
 	} else {
-		log.Fatal(http.ListenAndServe(addr, mux))
+		log.Fatal(http.ListenAndServe(addr, handler))
 	}
 }
 
+// edgeTLSConfig serves the operator's certificate, and the edge certificate to clients asking
+// for a hostname only it covers
+func edgeTLSConfig(certFile, keyFile, edgeCertFile, edgeKeyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	edgeCert, err := tls.LoadX509KeyPair(edgeCertFile, edgeKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert, edgeCert}}, nil
+}
+
 // ensureTLSCerts returns cert and key paths, generating self-signed if needed
 func ensureTLSCerts(certPath, keyPath string) (string, string, error) {
 	// If both provided, use them
@@ -226,9 +598,32 @@ func ensureTLSCerts(certPath, keyPath string) (string, string, error) {
 	return certFile, keyFile, nil
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"name":"Lightspeed","status":"ok"}`))
+// handleHealth reports operator health, including registry garbage collection
+// (pushes are held while it runs) and free disk space (degraded when low)
+func handleHealth(registryProxy *proxy.RegistryProxy, diskGuard *api.DiskGuard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		disk := diskGuard.Status()
+		health := models.Health{
+			Name:              "Lightspeed",
+			Status:            "ok",
+			GarbageCollection: &models.GarbageCollection{},
+			Disk:              &disk,
+			Features:          []string{models.FeatureDryRun},
+		}
+		if disk.Status != "ok" {
+			health.Status = "degraded"
+		}
+		if gc := registryProxy.GarbageCollection(r.Context()); gc != nil {
+			health.GarbageCollection = &models.GarbageCollection{
+				Active:    true,
+				Status:    gc.Status,
+				StartedAt: gc.CreatedAt.UTC().Format(time.RFC3339),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(health)
+	}
 }
 
 func handleVersion(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lightspeed/core/lib/digitalocean"
+)
+
+// Garbage collection timing
+const (
+	gcCheckInterval = 15 * time.Second // How long a GC status lookup is reused
+	gcPollInterval  = 10 * time.Second // How often a held write re-checks GC status
+	gcWaitTimeout   = 20 * time.Minute // How long a write is held before giving up
+)
+
+// gcState caches the registry garbage collection status
+type gcState struct {
+	mu      sync.Mutex
+	active  *digitalocean.GarbageCollection
+	checked time.Time
+}
+
+// GarbageCollection returns the running garbage collection (nil if none)
+// The status is cached for gcCheckInterval; lookup errors are treated as no collection
+// so an unreachable API doesn't block pushes
+func (p *RegistryProxy) GarbageCollection(ctx context.Context) *digitalocean.GarbageCollection {
+	p.gc.mu.Lock()
+	defer p.gc.mu.Unlock()
+
+	if time.Since(p.gc.checked) < gcCheckInterval {
+		return p.gc.active
+	}
+
+	if p.doClient == nil || p.registryName == "" {
+		return nil
+	}
+
+	active, err := p.doClient.ActiveGarbageCollection(ctx, p.registryName)
+	if err != nil {
+		log.Printf("[PROXY] Failed to check garbage collection: %v", err)
+		return p.gc.active
+	}
+
+	if active != nil && p.gc.active == nil {
+		log.Printf("[PROXY] [GC] Garbage collection in progress (%s), holding registry writes", active.Status)
+	} else if active == nil && p.gc.active != nil {
+		log.Printf("[PROXY] [GC] Garbage collection finished, resuming registry writes")
+	}
+
+	p.gc.active = active
+	p.gc.checked = time.Now()
+	return active
+}
+
+// invalidateGC forces the next GarbageCollection call to re-check the API
+func (p *RegistryProxy) invalidateGC() {
+	p.gc.mu.Lock()
+	p.gc.checked = time.Time{}
+	p.gc.mu.Unlock()
+}
+
+// isRegistryWrite checks if a request writes to the registry (blocked during garbage collection)
+func isRegistryWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return false
+	}
+	return strings.Contains(r.URL.Path, "/blobs/uploads") || strings.Contains(r.URL.Path, "/manifests/")
+}
+
+// waitForGC holds a registry write until garbage collection finishes
+// Returns false if the wait timed out or the client went away
+func (p *RegistryProxy) waitForGC(r *http.Request) bool {
+	gc := p.GarbageCollection(r.Context())
+	if gc == nil {
+		return true
+	}
+
+	log.Printf("[PROXY] [GC] Holding %s %s until garbage collection finishes", r.Method, r.URL.Path)
+	start := time.Now()
+	ticker := time.NewTicker(gcPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-ticker.C:
+		}
+
+		if p.GarbageCollection(r.Context()) == nil {
+			log.Printf("[PROXY] [GC] Released %s %s after %v", r.Method, r.URL.Path, time.Since(start).Round(time.Second))
+			return true
+		}
+		if time.Since(start) > gcWaitTimeout {
+			log.Printf("[PROXY] [GC] Gave up waiting on %s %s after %v", r.Method, r.URL.Path, gcWaitTimeout)
+			return false
+		}
+	}
+}
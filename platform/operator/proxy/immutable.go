@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxManifestSize limits how much of a manifest PUT is buffered for the immutability check
+const maxManifestSize = 4 << 20
+
+// TagPolicy decides which tags can't be overwritten once pushed
+// The latest tag and digest references are always mutable
+type TagPolicy struct {
+	all   bool
+	repos map[string]bool
+}
+
+// ParseTagPolicy parses an immutable tags setting
+// "true", "all" or "*" applies to every repository; otherwise it's a comma-separated
+// list of repositories. Returns nil (no policy) for an empty or "false" value.
+func ParseTagPolicy(value string) *TagPolicy {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "false", "off", "none":
+		return nil
+	case "true", "all", "*":
+		return &TagPolicy{all: true}
+	}
+
+	policy := &TagPolicy{repos: make(map[string]bool)}
+	for _, repo := range strings.Split(value, ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			policy.repos[repo] = true
+		}
+	}
+	return policy
+}
+
+// String describes the policy for logging
+func (tp *TagPolicy) String() string {
+	if tp == nil {
+		return "off"
+	}
+	if tp.all {
+		return "all repositories"
+	}
+	repos := make([]string, 0, len(tp.repos))
+	for repo := range tp.repos {
+		repos = append(repos, repo)
+	}
+	return strings.Join(repos, ", ")
+}
+
+// Immutable checks if a tag of a repository can't be overwritten
+func (tp *TagPolicy) Immutable(repo, tag string) bool {
+	if tp == nil || tag == "latest" || strings.HasPrefix(tag, "sha256:") {
+		return false
+	}
+	return tp.all || tp.repos[repo]
+}
+
+// SetTagPolicy sets the tag immutability policy (nil disables it)
+func (p *RegistryProxy) SetTagPolicy(policy *TagPolicy) {
+	p.tagPolicy = policy
+}
+
+// parseManifestPath extracts the repository (without registry namespace) and reference
+// from a /v2/{repo}/manifests/{reference} path
+func (p *RegistryProxy) parseManifestPath(path string) (string, string, bool) {
+	rest := strings.TrimPrefix(path, "/v2/")
+	i := strings.LastIndex(rest, "/manifests/")
+	if i < 0 {
+		return "", "", false
+	}
+
+	repo := strings.TrimPrefix(rest[:i], p.registryName+"/")
+	reference := rest[i+len("/manifests/"):]
+	return repo, reference, repo != "" && reference != ""
+}
+
+// checkTagOverwrite rejects manifest PUTs that would overwrite an immutable tag
+// Re-pushing identical content is allowed. The request body is buffered and restored.
+// Returns false if the request was rejected and a response has been written.
+func (p *RegistryProxy) checkTagOverwrite(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPut {
+		return true
+	}
+	repo, tag, ok := p.parseManifestPath(r.URL.Path)
+	if !ok || !p.tagPolicy.Immutable(repo, tag) {
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	r.Body.Close()
+	if err != nil {
+		log.Printf("[PROXY] Error reading manifest: %v", err)
+		http.Error(w, "Proxy error", http.StatusBadGateway)
+		return false
+	}
+	if len(body) > maxManifestSize {
+		writeRegistryError(w, http.StatusRequestEntityTooLarge, "MANIFEST_INVALID", "manifest too large")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	existing, err := p.InspectDigest(r.Context(), repo, tag)
+	if errors.Is(err, ErrImageNotFound) {
+		return true
+	}
+	if err != nil {
+		// Fail closed: an unverified overwrite is what the policy exists to prevent
+		log.Printf("[PROXY] Failed to check existing tag %s:%s: %v", repo, tag, err)
+		writeRegistryError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "could not verify tag immutability, try again")
+		return false
+	}
+
+	sum := sha256.Sum256(body)
+	if existing == "sha256:"+hex.EncodeToString(sum[:]) {
+		return true
+	}
+
+	log.Printf("[PROXY] [MANIFEST] Rejected overwrite of immutable tag %s:%s", repo, tag)
+	writeRegistryError(w, http.StatusForbidden, "DENIED",
+		fmt.Sprintf("tag %s:%s already exists and tags are immutable; push a new version instead", repo, tag))
+	return false
+}
+
+// InspectDigest returns the manifest digest of repository:reference
+func (p *RegistryProxy) InspectDigest(ctx context.Context, repository, reference string) (string, error) {
+	_, headers, err := p.fetchManifest(ctx, p.repoPath(repository), reference)
+	if err != nil {
+		return "", err
+	}
+	return headers.Get("Docker-Content-Digest"), nil
+}
+
+// writeRegistryError writes an error in the registry API format so docker shows the message
+func writeRegistryError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{
+			{"code": code, "message": message},
+		},
+	})
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Manifest media types accepted from the upstream registry
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// ErrImageNotFound is returned when a manifest doesn't exist in the registry
+var ErrImageNotFound = errors.New("image not found")
+
+// Platform is an image OS/architecture pair
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform as os/arch[/variant]
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Layer is an image layer
+type Layer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// ImageManifest holds the parsed details of an image manifest
+// For multi-platform images, layers and config details describe the linux/amd64 image
+// (or the first platform if there is none)
+type ImageManifest struct {
+	Digest    string            `json:"digest"`
+	MediaType string            `json:"media_type"`
+	Platforms []Platform        `json:"platforms"`
+	Layers    []Layer           `json:"layers"`
+	Size      int64             `json:"size"`
+	Created   time.Time         `json:"created"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Supports checks if the image can run on the target platform
+func (m *ImageManifest) Supports(target Platform) bool {
+	for _, p := range m.Platforms {
+		if p.OS == target.OS && p.Architecture == target.Architecture {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestDescriptor is a content reference inside a manifest
+type manifestDescriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+// rawManifest covers both single-image manifests and manifest lists/indexes
+type rawManifest struct {
+	MediaType string               `json:"mediaType"`
+	Config    manifestDescriptor   `json:"config"`
+	Layers    []manifestDescriptor `json:"layers"`
+	Manifests []manifestDescriptor `json:"manifests"`
+}
+
+// imageConfig holds the config blob fields we read
+type imageConfig struct {
+	OS           string    `json:"os"`
+	Architecture string    `json:"architecture"`
+	Variant      string    `json:"variant"`
+	Created      time.Time `json:"created"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// manifestAccept is the Accept header for manifest requests
+var manifestAccept = strings.Join([]string{mediaTypeOCIIndex, mediaTypeDockerManifestList, mediaTypeOCIManifest, mediaTypeDockerManifest}, ", ")
+
+// InspectImage fetches and parses the manifest of repository:reference from the upstream registry
+func (p *RegistryProxy) InspectImage(ctx context.Context, repository, reference string) (*ImageManifest, error) {
+	repoPath := p.repoPath(repository)
+
+	raw, headers, err := p.fetchManifest(ctx, repoPath, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType := raw.MediaType
+	if mediaType == "" {
+		mediaType = headers.Get("Content-Type")
+	}
+
+	manifest := &ImageManifest{
+		Digest:    headers.Get("Docker-Content-Digest"),
+		MediaType: mediaType,
+		Platforms: []Platform{},
+		Layers:    []Layer{},
+	}
+
+	// Multi-platform image: platforms are listed in the index, details come from one image
+	if len(raw.Manifests) > 0 {
+		var selected *manifestDescriptor
+		for i, m := range raw.Manifests {
+			// Skip attestation manifests added by buildx
+			if m.Platform == nil || m.Platform.OS == "unknown" {
+				continue
+			}
+			manifest.Platforms = append(manifest.Platforms, *m.Platform)
+			if selected == nil || (m.Platform.OS == "linux" && m.Platform.Architecture == "amd64") {
+				selected = &raw.Manifests[i]
+			}
+		}
+		if selected == nil {
+			return manifest, nil
+		}
+
+		raw, _, err = p.fetchManifest(ctx, repoPath, selected.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch platform manifest: %w", err)
+		}
+	}
+
+	for _, l := range raw.Layers {
+		manifest.Layers = append(manifest.Layers, Layer{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+		manifest.Size += l.Size
+	}
+
+	// Platform (for single-platform images), creation time and labels are in the config blob
+	if raw.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest has no config")
+	}
+	configBody, _, err := p.fetchUpstream(ctx, repoPath, "blobs/"+raw.Config.Digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image config: %w", err)
+	}
+
+	var config imageConfig
+	if err := json.Unmarshal(configBody, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	manifest.Size += raw.Config.Size
+	manifest.Created = config.Created
+	manifest.Labels = config.Config.Labels
+
+	if len(manifest.Platforms) == 0 {
+		manifest.Platforms = append(manifest.Platforms, Platform{
+			OS:           config.OS,
+			Architecture: config.Architecture,
+			Variant:      config.Variant,
+		})
+	}
+
+	return manifest, nil
+}
+
+// repoPath returns the upstream repository path (with registry namespace) for a repository
+func (p *RegistryProxy) repoPath(repository string) string {
+	if p.registryName == "" || strings.HasPrefix(repository, p.registryName+"/") {
+		return repository
+	}
+	return p.registryName + "/" + repository
+}
+
+// fetchManifest fetches and parses a manifest or manifest list by tag or digest
+func (p *RegistryProxy) fetchManifest(ctx context.Context, repoPath, reference string) (*rawManifest, http.Header, error) {
+	body, headers, err := p.fetchUpstream(ctx, repoPath, "manifests/"+reference, manifestAccept)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw rawManifest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	return &raw, headers, nil
+}
+
+// fetchUpstream makes an authenticated GET to /v2/{repoPath}/{path} on the upstream registry
+func (p *RegistryProxy) fetchUpstream(ctx context.Context, repoPath, path, accept string) ([]byte, http.Header, error) {
+	upstreamURL := *p.upstream
+	upstreamURL.Path = "/v2/" + repoPath + "/" + path
+
+	req, err := http.NewRequestWithContext(ctx, "GET", upstreamURL.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	if p.authToken != "" {
+		token, err := p.getTokenForRepo(repoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.registryClient.Do(req)
+	if err != nil {
+		p.metrics.RecordUpstream(repoPath, 0, err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	p.metrics.RecordUpstream(repoPath, resp.StatusCode, nil)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, ErrImageNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("registry returned %s for %s", resp.Status, path)
+	}
+
+	return body, resp.Header, nil
+}
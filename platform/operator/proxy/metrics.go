@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Upstream is considered down after this many consecutive failures
+const upstreamDownThreshold = 3
+
+// repoMetrics holds counters for a single repository
+type repoMetrics struct {
+	Requests              int64 `json:"requests"`
+	UpstreamErrors        int64 `json:"upstream_errors"`
+	CredentialCacheHits   int64 `json:"credential_cache_hits"`
+	CredentialCacheMisses int64 `json:"credential_cache_misses"`
+}
+
+// Metrics collects registry proxy statistics
+// Credential cache counters track the docker credentials cache, which every token exchange goes
+// through; the proxy caches no blobs or manifests
+type Metrics struct {
+	mu      sync.Mutex
+	started time.Time
+	repos   map[string]*repoMetrics
+
+	tokenExchanges    int64
+	tokenFailures     int64
+	tokenLatencyTotal time.Duration
+	tokenLatencyLast  time.Duration
+
+	lastSuccess         time.Time
+	lastError           time.Time
+	lastErrorMessage    string
+	consecutiveFailures int
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{
+		started: time.Now(),
+		repos:   make(map[string]*repoMetrics),
+	}
+}
+
+// repo returns the counters for a repository (caller must hold the lock)
+func (m *Metrics) repo(name string) *repoMetrics {
+	if name == "" {
+		name = "_"
+	}
+	r, ok := m.repos[name]
+	if !ok {
+		r = &repoMetrics{}
+		m.repos[name] = r
+	}
+	return r
+}
+
+// RecordCredentialCache records a credentials cache lookup for a repository
+func (m *Metrics) RecordCredentialCache(repo string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if hit {
+		m.repo(repo).CredentialCacheHits++
+	} else {
+		m.repo(repo).CredentialCacheMisses++
+	}
+}
+
+// RecordTokenExchange records a token exchange with the upstream auth server
+func (m *Metrics) RecordTokenExchange(latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokenExchanges++
+	m.tokenLatencyTotal += latency
+	m.tokenLatencyLast = latency
+	if err != nil {
+		m.tokenFailures++
+	}
+}
+
+// RecordUpstream records the outcome of a proxied request
+// Network errors and 5xx responses count as upstream errors
+func (m *Metrics) RecordUpstream(repo string, status int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.repo(repo)
+	r.Requests++
+
+	if err != nil || status >= 500 {
+		r.UpstreamErrors++
+		m.lastError = time.Now()
+		m.consecutiveFailures++
+		if err != nil {
+			m.lastErrorMessage = err.Error()
+		} else {
+			m.lastErrorMessage = http.StatusText(status)
+		}
+		return
+	}
+
+	m.lastSuccess = time.Now()
+	m.consecutiveFailures = 0
+}
+
+// RepoSnapshot is the metrics view of a single repository
+type RepoSnapshot struct {
+	Repository              string  `json:"repository"`
+	Requests                int64   `json:"requests"`
+	UpstreamErrors          int64   `json:"upstream_errors"`
+	ErrorRate               float64 `json:"error_rate"`
+	CredentialCacheHits     int64   `json:"credential_cache_hits"`
+	CredentialCacheMisses   int64   `json:"credential_cache_misses"`
+	CredentialCacheHitRatio float64 `json:"credential_cache_hit_ratio"`
+}
+
+// TokenSnapshot is the metrics view of token exchanges
+type TokenSnapshot struct {
+	Exchanges     int64   `json:"exchanges"`
+	Failures      int64   `json:"failures"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	LastLatencyMs float64 `json:"last_latency_ms"`
+}
+
+// Snapshot is a point-in-time copy of all proxy metrics
+type Snapshot struct {
+	UptimeSeconds           int64          `json:"uptime_seconds"`
+	Requests                int64          `json:"requests"`
+	UpstreamErrors          int64          `json:"upstream_errors"`
+	ErrorRate               float64        `json:"error_rate"`
+	CredentialCacheHitRatio float64        `json:"credential_cache_hit_ratio"`
+	Token                   TokenSnapshot  `json:"token_exchange"`
+	Repositories            []RepoSnapshot `json:"repositories"`
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := Snapshot{
+		UptimeSeconds: int64(time.Since(m.started).Seconds()),
+		Token: TokenSnapshot{
+			Exchanges:     m.tokenExchanges,
+			Failures:      m.tokenFailures,
+			LastLatencyMs: milliseconds(m.tokenLatencyLast),
+		},
+		Repositories: []RepoSnapshot{},
+	}
+	if m.tokenExchanges > 0 {
+		snap.Token.AvgLatencyMs = milliseconds(m.tokenLatencyTotal / time.Duration(m.tokenExchanges))
+	}
+
+	var hits, misses int64
+	for name, r := range m.repos {
+		snap.Requests += r.Requests
+		snap.UpstreamErrors += r.UpstreamErrors
+		hits += r.CredentialCacheHits
+		misses += r.CredentialCacheMisses
+
+		snap.Repositories = append(snap.Repositories, RepoSnapshot{
+			Repository:              name,
+			Requests:                r.Requests,
+			UpstreamErrors:          r.UpstreamErrors,
+			ErrorRate:               ratio(r.UpstreamErrors, r.Requests),
+			CredentialCacheHits:     r.CredentialCacheHits,
+			CredentialCacheMisses:   r.CredentialCacheMisses,
+			CredentialCacheHitRatio: ratio(r.CredentialCacheHits, r.CredentialCacheHits+r.CredentialCacheMisses),
+		})
+	}
+	sort.Slice(snap.Repositories, func(i, j int) bool {
+		return snap.Repositories[i].Repository < snap.Repositories[j].Repository
+	})
+
+	snap.ErrorRate = ratio(snap.UpstreamErrors, snap.Requests)
+	snap.CredentialCacheHitRatio = ratio(hits, hits+misses)
+
+	return snap
+}
+
+// Health summarizes upstream registry status
+type Health struct {
+	Status              string  `json:"status"`
+	Upstream            string  `json:"upstream"`
+	LastSuccess         string  `json:"last_success,omitempty"`
+	LastError           string  `json:"last_error,omitempty"`
+	LastErrorMessage    string  `json:"last_error_message,omitempty"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	ErrorRate           float64 `json:"error_rate"`
+	TokenFailures       int64   `json:"token_failures"`
+	AvgTokenLatencyMs   float64 `json:"avg_token_latency_ms"`
+}
+
+// Health returns the upstream status: "ok", "degraded" (recent errors) or "down"
+func (m *Metrics) Health(upstream string) Health {
+	snap := m.Snapshot()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := Health{
+		Status:              "ok",
+		Upstream:            upstream,
+		LastErrorMessage:    m.lastErrorMessage,
+		ConsecutiveFailures: m.consecutiveFailures,
+		ErrorRate:           snap.ErrorRate,
+		TokenFailures:       snap.Token.Failures,
+		AvgTokenLatencyMs:   snap.Token.AvgLatencyMs,
+	}
+	if !m.lastSuccess.IsZero() {
+		health.LastSuccess = m.lastSuccess.UTC().Format(time.RFC3339)
+	}
+	if !m.lastError.IsZero() {
+		health.LastError = m.lastError.UTC().Format(time.RFC3339)
+	}
+
+	switch {
+	case m.consecutiveFailures >= upstreamDownThreshold:
+		health.Status = "down"
+	case m.consecutiveFailures > 0 || m.lastError.After(time.Now().Add(-5*time.Minute)):
+		health.Status = "degraded"
+	}
+
+	return health
+}
+
+// ratio returns n/total, or 0 if total is 0
+func ratio(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// MetricsHandler serves the proxy metrics as JSON
+func (p *RegistryProxy) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.metrics.Snapshot())
+}
+
+// HealthHandler serves the upstream registry health summary as JSON
+func (p *RegistryProxy) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	health := p.metrics.Health(p.upstream.Host)
+	if health.Status == "down" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
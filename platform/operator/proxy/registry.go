@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -11,26 +12,34 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"lightspeed/core/lib/digitalocean"
 )
 
 // RegistryProxy proxies requests to an upstream Docker registry
 type RegistryProxy struct {
 	upstream       *url.URL
-	registryClient *http.Client // For proxying registry requests
-	apiClient      *http.Client // For calling DO API
-	publicHost     string       // The public hostname of this proxy (for rewriting auth challenges)
-	authToken      string       // DO API token for authentication
-	registryName   string       // Registry namespace to prepend to paths (e.g., "lightspeed-images")
+	registryClient *http.Client         // For proxying registry requests
+	apiClient      *http.Client         // For calling DO API
+	publicHost     string               // The public hostname of this proxy (for rewriting auth challenges)
+	authToken      string               // DO API token for authentication
+	doClient       *digitalocean.Client // DO API client for docker credentials
+	registryName   string               // Registry namespace to prepend to paths (e.g., "lightspeed-images")
 
 	// Cached docker credentials (base64 username:password)
 	dockerCreds string
 	credsExpiry time.Time
 	credsMu     sync.RWMutex
+
+	metrics   *Metrics
+	tagPolicy *TagPolicy
+	gc        gcState
 }
 
 // SetAuthToken sets the DO API token to use for upstream authentication
 func (p *RegistryProxy) SetAuthToken(token string) {
 	p.authToken = token
+	p.doClient = digitalocean.NewClient(token)
 }
 
 // SetRegistryName sets the registry namespace to prepend to paths
@@ -39,11 +48,12 @@ func (p *RegistryProxy) SetRegistryName(name string) {
 }
 
 // getDockerCreds gets cached docker credentials, refreshing if needed
-func (p *RegistryProxy) getDockerCreds() (string, error) {
+func (p *RegistryProxy) getDockerCreds(repoPath string) (string, error) {
 	p.credsMu.RLock()
 	if p.dockerCreds != "" && time.Now().Before(p.credsExpiry) {
 		creds := p.dockerCreds
 		p.credsMu.RUnlock()
+		p.metrics.RecordCredentialCache(repoPath, true)
 		return creds, nil
 	}
 	p.credsMu.RUnlock()
@@ -52,8 +62,10 @@ func (p *RegistryProxy) getDockerCreds() (string, error) {
 	defer p.credsMu.Unlock()
 
 	if p.dockerCreds != "" && time.Now().Before(p.credsExpiry) {
+		p.metrics.RecordCredentialCache(repoPath, true)
 		return p.dockerCreds, nil
 	}
+	p.metrics.RecordCredentialCache(repoPath, false)
 
 	creds, err := p.fetchDockerCreds()
 	if err != nil {
@@ -71,7 +83,7 @@ func (p *RegistryProxy) getDockerCreds() (string, error) {
 func (p *RegistryProxy) getTokenForRepo(repoPath string) (string, error) {
 	log.Printf("[PROXY] [DEBUG] Getting token for repo: %s", repoPath)
 
-	creds, err := p.getDockerCreds()
+	creds, err := p.getDockerCreds(repoPath)
 	if err != nil {
 		log.Printf("[PROXY] [DEBUG] Failed to get docker creds: %v", err)
 		return "", err
@@ -80,7 +92,7 @@ func (p *RegistryProxy) getTokenForRepo(repoPath string) (string, error) {
 
 	// Request token with exact scope for this repo
 	scope := fmt.Sprintf("repository:%s:push,pull", repoPath)
-	authURL := fmt.Sprintf("https://api.digitalocean.com/v2/registry/auth?service=registry.digitalocean.com&scope=%s", url.QueryEscape(scope))
+	authURL := p.doClient.RegistryTokenURL(scope)
 
 	log.Printf("[PROXY] [DEBUG] Token request URL: %s", authURL)
 	log.Printf("[PROXY] [DEBUG] Scope: %s", scope)
@@ -92,19 +104,26 @@ func (p *RegistryProxy) getTokenForRepo(repoPath string) (string, error) {
 
 	authHeader := "Basic " + creds
 	req.Header.Set("Authorization", authHeader)
-	log.Printf("[PROXY] [DEBUG] Authorization header: %s", authHeader[:50]+"...") // Log first 50 chars
+	log.Printf("[PROXY] [DEBUG] Authorization header: %s", authHeader[:min(len(authHeader), 50)]+"...") // Log first 50 chars
 	log.Printf("[PROXY] [DEBUG] Authorization header length: %d", len(authHeader))
 	log.Printf("[PROXY] [DEBUG] Full request URL: %s", req.URL.String())
 	log.Printf("[PROXY] [DEBUG] Request headers: %v", req.Header)
 
+	exchangeStart := time.Now()
 	resp, err := p.apiClient.Do(req)
 	if err != nil {
+		p.metrics.RecordTokenExchange(time.Since(exchangeStart), err)
 		log.Printf("[PROXY] [DEBUG] Request failed: %v", err)
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		p.metrics.RecordTokenExchange(time.Since(exchangeStart), fmt.Errorf("token fetch failed: %s", resp.Status))
+	} else {
+		p.metrics.RecordTokenExchange(time.Since(exchangeStart), nil)
+	}
 	log.Printf("[PROXY] [DEBUG] Token response status: %d", resp.StatusCode)
 	log.Printf("[PROXY] [DEBUG] Token response body: %s", string(body))
 
@@ -139,59 +158,19 @@ func (p *RegistryProxy) getTokenForRepo(repoPath string) (string, error) {
 
 // fetchDockerCreds gets docker credentials from DO API
 func (p *RegistryProxy) fetchDockerCreds() (string, error) {
-	credsURL := "https://api.digitalocean.com/v2/registry/docker-credentials?read_write=true"
 	log.Printf("[PROXY] [DEBUG] Fetching docker credentials from DO API")
-	log.Printf("[PROXY] [DEBUG] API token length: %d", len(p.authToken))
 
-	req, err := http.NewRequest("GET", credsURL, nil)
-	if err != nil {
-		log.Printf("[PROXY] [DEBUG] Failed to create request: %v", err)
-		return "", err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+p.authToken)
-	log.Printf("[PROXY] [DEBUG] Set Bearer token in Authorization header")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	resp, err := p.apiClient.Do(req)
+	creds, err := p.doClient.DockerCredentials(ctx, true)
 	if err != nil {
-		log.Printf("[PROXY] [DEBUG] Request failed: %v", err)
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("[PROXY] [DEBUG] Credentials response status: %d", resp.StatusCode)
-	log.Printf("[PROXY] [DEBUG] Credentials response body: %s", string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[PROXY] Credentials fetch failed: %s - %s", resp.Status, string(body))
-		return "", fmt.Errorf("credentials fetch failed: %s", resp.Status)
-	}
-
-	var credsResult struct {
-		Auths map[string]struct {
-			Auth string `json:"auth"`
-		} `json:"auths"`
-	}
-
-	if err := json.Unmarshal(body, &credsResult); err != nil {
-		log.Printf("[PROXY] [DEBUG] Failed to unmarshal credentials: %v", err)
-		return "", fmt.Errorf("failed to decode credentials: %v", err)
+		log.Printf("[PROXY] Credentials fetch failed: %v", err)
+		return "", fmt.Errorf("credentials fetch failed: %w", err)
 	}
 
-	log.Printf("[PROXY] [DEBUG] Found %d auth entries in response", len(credsResult.Auths))
-	for host := range credsResult.Auths {
-		log.Printf("[PROXY] [DEBUG] Auth entry for host: %s", host)
-	}
-
-	registryAuth, ok := credsResult.Auths["registry.digitalocean.com"]
-	if !ok || registryAuth.Auth == "" {
-		log.Printf("[PROXY] [DEBUG] No registry.digitalocean.com auth found")
-		return "", fmt.Errorf("no auth credentials in response")
-	}
-
-	log.Printf("[PROXY] [DEBUG] Successfully fetched docker credentials (length: %d)", len(registryAuth.Auth))
-	return registryAuth.Auth, nil
+	log.Printf("[PROXY] [DEBUG] Successfully fetched docker credentials (length: %d)", len(creds))
+	return creds, nil
 }
 
 // extractRepoFromPath extracts the repository path from a registry API path
@@ -272,6 +251,7 @@ func NewRegistryProxy(upstreamURL, publicHost string) (*RegistryProxy, error) {
 		registryClient: registryClient,
 		apiClient:      apiClient,
 		publicHost:     publicHost,
+		metrics:        NewMetrics(),
 	}, nil
 }
 
@@ -290,6 +270,19 @@ func (p *RegistryProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The registry rejects writes during garbage collection, so hold them until it finishes
+	if isRegistryWrite(r) && !p.waitForGC(r) {
+		if r.Context().Err() == nil {
+			writeRegistryError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "registry garbage collection in progress, try again later")
+		}
+		return
+	}
+
+	// Enforce tag immutability before anything is forwarded
+	if !p.checkTagOverwrite(w, r) {
+		return
+	}
+
 	// Create upstream request
 	upstreamURL := *p.upstream
 
@@ -328,8 +321,8 @@ func (p *RegistryProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Get Bearer token for this specific repository
 	bearerToken := ""
+	repoPath := p.extractRepoFromPath(r.URL.Path)
 	if p.authToken != "" {
-		repoPath := p.extractRepoFromPath(r.URL.Path)
 		if repoPath != "" {
 			token, err := p.getTokenForRepo(repoPath)
 			if err != nil {
@@ -354,14 +347,28 @@ func (p *RegistryProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[PROXY] %s %s -> %s", r.Method, r.URL.Path, upstreamURL.String())
 	}
 
+	// Protocol upgrades (e.g. WebSocket) relay the connection instead of a single response
+	if IsUpgrade(r) {
+		log.Printf("[PROXY] [UPGRADE] %s %s -> %s (%s)", r.Method, r.URL.Path, upstreamURL.String(), r.Header.Get("Upgrade"))
+		if err := ServeUpgrade(w, r, upstreamReq, p.registryClient.Transport); err != nil {
+			log.Printf("[PROXY] [UPGRADE] %s %s failed: %v", r.Method, r.URL.Path, err)
+		}
+		return
+	}
+
 	// Execute request
 	resp, err := p.registryClient.Do(upstreamReq)
 	if err != nil {
+		// Requests aborted by the client don't say anything about upstream health
+		if r.Context().Err() == nil {
+			p.metrics.RecordUpstream(repoPath, 0, err)
+		}
 		log.Printf("[PROXY] Error forwarding request: %v", err)
 		http.Error(w, "Upstream error", http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
+	p.metrics.RecordUpstream(repoPath, resp.StatusCode, nil)
 
 	// Copy response headers
 	p.copyResponseHeaders(resp, w)
@@ -377,6 +384,11 @@ func (p *RegistryProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Log failed requests
 	if resp.StatusCode >= 400 {
 		log.Printf("[PROXY] [ERROR] %s %s -> %d", r.Method, r.URL.Path, resp.StatusCode)
+
+		// A rejected write may mean garbage collection just started; re-check before the next one
+		if isRegistryWrite(r) {
+			p.invalidateGC()
+		}
 	}
 
 	// Stream response body with flushing for real-time streaming
@@ -452,6 +464,12 @@ func (p *RegistryProxy) copyRequestHeaders(src *http.Request, dst *http.Request)
 		}
 	}
 
+	// Clients send one Accept header per manifest media type; forwarding only the first
+	// would make the registry answer manifest lists and OCI indexes with a single manifest (or 404)
+	if accept := src.Header.Values("Accept"); len(accept) > 1 {
+		dst.Header["Accept"] = accept
+	}
+
 	// Handle chunked transfer encoding
 	if src.TransferEncoding != nil {
 		dst.TransferEncoding = src.TransferEncoding
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/internal/testsupport"
+)
+
+// newTestProxy runs a registry proxy in front of the fake DigitalOcean registry
+func newTestProxy(t *testing.T) (*testsupport.FakeDigitalOcean, *httptest.Server) {
+	t.Helper()
+
+	do := testsupport.NewFakeDigitalOcean()
+	digitalocean.SetDefaultBaseURL(do.BaseURL())
+	proxy, err := NewRegistryProxy(do.RegistryURL(), "localhost")
+	if err != nil {
+		t.Fatalf("NewRegistryProxy: %v", err)
+	}
+	proxy.SetAuthToken("fake-token")
+	proxy.SetRegistryName("reg")
+	server := httptest.NewServer(proxy)
+	t.Cleanup(func() {
+		server.Close()
+		do.Close()
+		digitalocean.SetDefaultBaseURL("")
+	})
+	return do, server
+}
+
+// send makes a request through the proxy, failing the test unless it answers with status
+func send(t *testing.T, method, url, contentType string, body []byte, status int) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	if resp.StatusCode != status {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		t.Fatalf("%s %s: status %d (%s), want %d", method, url, resp.StatusCode, data, status)
+	}
+	return resp
+}
+
+// readAll reads and closes a response body
+func readAll(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return data
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func TestProxyPushPull(t *testing.T) {
+	do, server := newTestProxy(t)
+
+	config := []byte(`{"os":"linux","architecture":"amd64"}`)
+	layer := []byte("layer contents")
+
+	// The config goes up in one request, the layer in a chunked upload
+	send(t, http.MethodPost, server.URL+"/v2/shop/blobs/uploads/?digest="+digestOf(config), "", config, http.StatusCreated).Body.Close()
+
+	resp := send(t, http.MethodPost, server.URL+"/v2/shop/blobs/uploads/", "", nil, http.StatusAccepted)
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	resp = send(t, http.MethodPatch, server.URL+location, "", layer[:5], http.StatusAccepted)
+	resp.Body.Close()
+	location = resp.Header.Get("Location")
+	send(t, http.MethodPut, server.URL+location+"?digest="+digestOf(layer), "", layer[5:], http.StatusCreated).Body.Close()
+
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.docker.distribution.manifest.v2+json",
+		"config":        map[string]interface{}{"mediaType": "application/vnd.docker.container.image.v1+json", "digest": digestOf(config), "size": len(config)},
+		"layers":        []map[string]interface{}{{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "digest": digestOf(layer), "size": len(layer)}},
+	})
+	send(t, http.MethodPut, server.URL+"/v2/shop/manifests/1.0.0", "application/vnd.docker.distribution.manifest.v2+json", manifest, http.StatusCreated).Body.Close()
+
+	// The image lands in the registry namespace
+	if got, want := do.Tags("reg/shop"), []string{"1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+
+	// And pulls back through the proxy as pushed
+	resp = send(t, http.MethodGet, server.URL+"/v2/shop/manifests/1.0.0", "", nil, http.StatusOK)
+	if got := readAll(t, resp); !bytes.Equal(got, manifest) {
+		t.Errorf("pulled manifest %s, want %s", got, manifest)
+	}
+	if got := resp.Header.Get("Docker-Content-Digest"); got != digestOf(manifest) {
+		t.Errorf("manifest digest = %s, want %s", got, digestOf(manifest))
+	}
+	resp = send(t, http.MethodGet, server.URL+"/v2/shop/blobs/"+digestOf(layer), "", nil, http.StatusOK)
+	if got := readAll(t, resp); !bytes.Equal(got, layer) {
+		t.Errorf("pulled layer %q, want %q", got, layer)
+	}
+
+	// Every upstream request carried a token, so the credentials were fetched once and reused
+	if got := do.Count(http.MethodGet, "/v2/registry/docker-credentials"); got != 1 {
+		t.Errorf("docker credentials fetched %d times, want 1", got)
+	}
+}
+
+func TestProxyPullMissing(t *testing.T) {
+	_, server := newTestProxy(t)
+
+	send(t, http.MethodGet, server.URL+"/v2/shop/manifests/1.0.0", "", nil, http.StatusNotFound).Body.Close()
+}
+
+func TestProxyUpstreamFault(t *testing.T) {
+	do, server := newTestProxy(t)
+	do.PushImage("reg/shop", "1.0.0", "linux/amd64")
+	do.Fail(testsupport.Fault{Method: http.MethodGet, Path: "/v2/reg/shop/manifests/", Status: http.StatusServiceUnavailable, Times: 1})
+
+	send(t, http.MethodGet, server.URL+"/v2/shop/manifests/1.0.0", "", nil, http.StatusServiceUnavailable).Body.Close()
+	send(t, http.MethodGet, server.URL+"/v2/shop/manifests/1.0.0", "", nil, http.StatusOK).Body.Close()
+}
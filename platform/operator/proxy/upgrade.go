@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// upgradeHeaders are forwarded with protocol upgrades, besides the Sec-WebSocket-* handshake headers
+var upgradeHeaders = []string{"Origin", "Cookie"}
+
+// IsUpgrade checks if a request asks to switch protocols (Connection: Upgrade, e.g. a WebSocket handshake)
+func IsUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+// headerHasToken checks if a comma-separated header lists a token (case-insensitive)
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeUpgrade relays a protocol upgrade to a backend, so features like log streaming relays
+// and exec tunnels can share the operator's listener
+// upstreamReq is the prepared backend request (URL, auth and forwarded headers); the upgrade
+// headers are added to it. Once the backend switches protocols, the client connection is hijacked
+// and bytes are copied both ways until either side closes. A backend that answers without
+// switching has its response passed back as is.
+func ServeUpgrade(w http.ResponseWriter, r *http.Request, upstreamReq *http.Request, transport http.RoundTripper) error {
+	// Only HTTP/1.x connections can be taken over (HTTP/2 has no hijacking)
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Protocol upgrades need an HTTP/1.1 connection", http.StatusHTTPVersionNotSupported)
+		return fmt.Errorf("connection can't be hijacked (%s)", r.Proto)
+	}
+
+	upstreamReq.Header.Set("Connection", "Upgrade")
+	upstreamReq.Header.Set("Upgrade", r.Header.Get("Upgrade"))
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, "Sec-Websocket-") {
+			upstreamReq.Header[name] = values
+		}
+	}
+	for _, h := range upgradeHeaders {
+		if v := r.Header.Get(h); v != "" {
+			upstreamReq.Header.Set(h, v)
+		}
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(upstreamReq)
+	if err != nil {
+		http.Error(w, "Upstream error", http.StatusBadGateway)
+		return fmt.Errorf("upgrade request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return nil
+	}
+
+	// A switched response's body is the backend connection
+	backend, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		http.Error(w, "Upstream error", http.StatusBadGateway)
+		return fmt.Errorf("upstream connection isn't writable")
+	}
+	defer backend.Close()
+
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to hijack connection: %w", err)
+	}
+	defer client.Close()
+
+	if err := writeSwitchingProtocols(buffered.Writer, resp.Header); err != nil {
+		return fmt.Errorf("failed to write upgrade response: %w", err)
+	}
+
+	// Copy until either side closes; closing both ends the other copy
+	var wg sync.WaitGroup
+	var once sync.Once
+	closeBoth := func() {
+		client.Close()
+		backend.Close()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// Bytes the client sent after the handshake may already be buffered
+		io.Copy(backend, buffered.Reader)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(client, backend)
+		once.Do(closeBoth)
+	}()
+	wg.Wait()
+
+	log.Printf("[PROXY] [UPGRADE] %s %s closed", r.Method, r.URL.Path)
+	return nil
+}
+
+// writeSwitchingProtocols writes the backend's 101 response to a hijacked connection
+func writeSwitchingProtocols(w *bufio.Writer, header http.Header) error {
+	if _, err := w.WriteString("HTTP/1.1 101 Switching Protocols\r\n"); err != nil {
+		return err
+	}
+	if err := header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -1,25 +1,25 @@
 package registry
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"regexp"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
+
+	"lightspeed/core/lib/digitalocean"
 )
 
 // Pruner handles automatic cleanup of old container images
 type Pruner struct {
-	apiToken     string
 	registryName string
-	client       *http.Client
+	client       *digitalocean.Client
 	keepLatest   bool
 	keepVersions int // Number of semver versions to keep
+
+	notify func(text string) // Told about each cleanup that deleted tags (nil for none)
 }
 
 // SemVer represents a parsed semantic version
@@ -39,35 +39,32 @@ type TagInfo struct {
 // NewPruner creates a new image pruner
 func NewPruner(apiToken, registryName string) *Pruner {
 	return &Pruner{
-		apiToken:     apiToken,
 		registryName: registryName,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:       digitalocean.NewClient(apiToken),
 		keepLatest:   true,
 		keepVersions: 3,
 	}
 }
 
-// Start begins the daily pruning schedule
-func (p *Pruner) Start() {
-	// Run immediately on start
-	// Log startup message first
+// SetNotify sets a function told about each cleanup that deleted tags
+func (p *Pruner) SetNotify(notify func(text string)) {
+	p.notify = notify
+}
+
+// Run prunes on a daily schedule (blocks; run it under the worker supervisor)
+func (p *Pruner) Run() {
 	log.Printf("[PRUNER] Started - will prune daily, keeping latest + %d most recent versions", p.keepVersions)
 
 	// Run first prune after 30 seconds
-	go func() {
-		time.Sleep(30 * time.Second) // Wait for startup
-		p.Prune()
-	}()
+	time.Sleep(30 * time.Second) // Wait for startup
+	p.Prune()
 
 	// Then run daily
 	ticker := time.NewTicker(24 * time.Hour)
-	go func() {
-		for range ticker.C {
-			p.Prune()
-		}
-	}()
+	defer ticker.Stop()
+	for range ticker.C {
+		p.Prune()
+	}
 }
 
 // Prune removes old image tags from all repositories
@@ -80,7 +77,7 @@ func (p *Pruner) Prune() {
 		return
 	}
 
-	totalDeleted := 0
+	totalDeleted, prunedRepos := 0, 0
 	for _, repo := range repos {
 		deleted, err := p.pruneRepository(repo)
 		if err != nil {
@@ -88,10 +85,16 @@ func (p *Pruner) Prune() {
 			continue
 		}
 		totalDeleted += deleted
+		if deleted > 0 {
+			prunedRepos++
+		}
 	}
 
 	if totalDeleted > 0 {
 		log.Printf("[PRUNER] Cleanup complete - deleted %d old tags", totalDeleted)
+		if p.notify != nil {
+			p.notify(fmt.Sprintf("Pruned %d old image tag(s) from %d repositories", totalDeleted, prunedRepos))
+		}
 		// Trigger garbage collection
 		if err := p.startGarbageCollection(); err != nil {
 			log.Printf("[PRUNER] Failed to start garbage collection: %v", err)
@@ -103,37 +106,13 @@ func (p *Pruner) Prune() {
 
 // listRepositories gets all repositories in the registry
 func (p *Pruner) listRepositories() ([]string, error) {
-	url := fmt.Sprintf("https://api.digitalocean.com/v2/registry/%s/repositoriesV2", p.registryName)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+p.apiToken)
-
-	resp, err := p.client.Do(req)
+	repositories, err := p.client.ListRepositories(context.Background(), p.registryName)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
-	}
-
-	var result struct {
-		Repositories []struct {
-			Name string `json:"name"`
-		} `json:"repositories"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	repos := make([]string, len(result.Repositories))
-	for i, r := range result.Repositories {
+	repos := make([]string, len(repositories))
+	for i, r := range repositories {
 		repos[i] = r.Name
 	}
 
@@ -258,25 +237,9 @@ func (p *Pruner) pruneRepository(repoName string) (int, error) {
 
 // deleteRepository deletes an entire repository (when it has no tags)
 func (p *Pruner) deleteRepository(repoName string) error {
-	encodedRepo := strings.ReplaceAll(repoName, "/", "%2F")
-	url := fmt.Sprintf("https://api.digitalocean.com/v2/registry/%s/repositories/%s", p.registryName, encodedRepo)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
+	if err := p.client.DeleteRepository(context.Background(), p.registryName, repoName); err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+p.apiToken)
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(body))
-	}
 
 	log.Printf("[PRUNER] Deleted repository %s", repoName)
 	return nil
@@ -284,40 +247,13 @@ func (p *Pruner) deleteRepository(repoName string) error {
 
 // listTags gets all tags for a repository with their metadata
 func (p *Pruner) listTags(repoName string) ([]TagInfo, error) {
-	// URL encode the repo name (it may contain slashes)
-	encodedRepo := strings.ReplaceAll(repoName, "/", "%2F")
-	url := fmt.Sprintf("https://api.digitalocean.com/v2/registry/%s/repositories/%s/tags", p.registryName, encodedRepo)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+p.apiToken)
-
-	resp, err := p.client.Do(req)
+	result, err := p.client.ListTags(context.Background(), p.registryName, repoName)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
-	}
 
-	var result struct {
-		Tags []struct {
-			Tag       string    `json:"tag"`
-			UpdatedAt time.Time `json:"updated_at"`
-		} `json:"tags"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	tags := make([]TagInfo, len(result.Tags))
-	for i, t := range result.Tags {
+	tags := make([]TagInfo, len(result))
+	for i, t := range result {
 		tags[i] = TagInfo{
 			Tag:       t.Tag,
 			UpdatedAt: t.UpdatedAt,
@@ -329,25 +265,9 @@ func (p *Pruner) listTags(repoName string) ([]TagInfo, error) {
 
 // deleteTag deletes a specific tag from a repository
 func (p *Pruner) deleteTag(repoName, tag string) error {
-	encodedRepo := strings.ReplaceAll(repoName, "/", "%2F")
-	url := fmt.Sprintf("https://api.digitalocean.com/v2/registry/%s/repositories/%s/tags/%s", p.registryName, encodedRepo, tag)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
+	if err := p.client.DeleteTag(context.Background(), p.registryName, repoName, tag); err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+p.apiToken)
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(body))
-	}
 
 	log.Printf("[PRUNER] Deleted %s:%s", repoName, tag)
 	return nil
@@ -355,25 +275,9 @@ func (p *Pruner) deleteTag(repoName, tag string) error {
 
 // startGarbageCollection triggers DO's garbage collection to reclaim space
 func (p *Pruner) startGarbageCollection() error {
-	url := fmt.Sprintf("https://api.digitalocean.com/v2/registry/%s/garbage-collection", p.registryName)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
+	if err := p.client.StartGarbageCollection(context.Background(), p.registryName); err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+p.apiToken)
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// 201 Created or 409 Conflict (already running) are both OK
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(body))
-	}
 
 	log.Printf("[PRUNER] Garbage collection started")
 	return nil
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+
+	"lightspeed/core/lib/digitalocean"
+	"lightspeed/platform/operator/internal/testsupport"
+)
+
+func TestPrune(t *testing.T) {
+	do := testsupport.NewFakeDigitalOcean()
+	defer do.Close()
+	digitalocean.SetDefaultBaseURL(do.BaseURL())
+	defer digitalocean.SetDefaultBaseURL("")
+
+	for _, tag := range []string{"latest", "1.0.0", "1.0.1", "1.1.0", "1.2.0", "2.0.0"} {
+		do.PushImage("reg/shop", tag, "linux/amd64")
+	}
+	do.PushImage("reg/blog", "1.0.0", "linux/amd64")
+
+	NewPruner("fake-token", "reg").Prune()
+
+	if got, want := do.Tags("reg/shop"), []string{"1.1.0", "1.2.0", "2.0.0", "latest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shop tags = %v, want %v", got, want)
+	}
+	if got, want := do.Tags("reg/blog"), []string{"1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("blog tags = %v, want %v", got, want)
+	}
+	if got := do.GarbageCollections(); got != 1 {
+		t.Errorf("%d garbage collections started, want 1", got)
+	}
+
+	// Nothing left to delete, so no garbage collection either
+	NewPruner("fake-token", "reg").Prune()
+	if got := do.GarbageCollections(); got != 1 {
+		t.Errorf("%d garbage collections started after a second prune, want 1", got)
+	}
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Restart backoff after a worker panics, doubling with each crash in a row
+const (
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Worker states
+const (
+	StatusRunning    = "running"
+	StatusRestarting = "restarting"
+	StatusStopped    = "stopped"
+)
+
+// Supervisor runs the operator's background workers, recovering panics so a crash
+// restarts the worker (with backoff) instead of silently ending it for the life of the process
+type Supervisor struct {
+	mu      sync.Mutex
+	workers map[string]*state
+}
+
+// state tracks a supervised worker
+type state struct {
+	status    string
+	started   time.Time
+	crashes   int
+	lastCrash time.Time
+	lastPanic string
+}
+
+// Status is the view of a worker served at /workers
+type Status struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	StartedAt   string `json:"started_at,omitempty"`
+	Crashes     int    `json:"crashes"`
+	LastCrashAt string `json:"last_crash_at,omitempty"`
+	LastPanic   string `json:"last_panic,omitempty"`
+}
+
+// NewSupervisor creates a supervisor without workers
+func NewSupervisor() *Supervisor {
+	return &Supervisor{workers: make(map[string]*state)}
+}
+
+// Go runs a worker in the background
+// run is expected to loop forever; if it panics it's restarted, if it returns it's reported as stopped
+func (s *Supervisor) Go(name string, run func()) {
+	s.mu.Lock()
+	s.workers[name] = &state{status: StatusRunning}
+	s.mu.Unlock()
+
+	go s.supervise(name, run)
+}
+
+func (s *Supervisor) supervise(name string, run func()) {
+	streak := 0
+	for {
+		s.update(name, func(w *state) {
+			w.status = StatusRunning
+			w.started = time.Now()
+		})
+
+		started := time.Now()
+		recovered, stack := runRecovered(run)
+		if recovered == nil {
+			log.Printf("[WORKER] %s stopped", name)
+			s.update(name, func(w *state) { w.status = StatusStopped })
+			return
+		}
+
+		// A worker that ran fine for a while starts its backoff over
+		if time.Since(started) > maxBackoff {
+			streak = 0
+		}
+		streak++
+		backoff := minBackoff << (streak - 1)
+		if backoff > maxBackoff || backoff <= 0 {
+			backoff = maxBackoff
+		}
+
+		log.Printf("[WORKER] %s panicked: %v (restarting in %v)\n%s", name, recovered, backoff, stack)
+		s.update(name, func(w *state) {
+			w.status = StatusRestarting
+			w.crashes++
+			w.lastCrash = time.Now()
+			w.lastPanic = fmt.Sprint(recovered)
+		})
+		time.Sleep(backoff)
+	}
+}
+
+// runRecovered runs a function, returning the value and stack of a panic
+func runRecovered(run func()) (recovered interface{}, stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			recovered, stack = r, debug.Stack()
+		}
+	}()
+	run()
+	return nil, nil
+}
+
+// Recover logs a panic of a short-lived goroutine instead of crashing the operator
+// Use it deferred at the top of goroutines a worker or handler starts: defer worker.Recover("...")
+func Recover(name string) {
+	if r := recover(); r != nil {
+		log.Printf("[WORKER] %s panicked: %v\n%s", name, r, debug.Stack())
+	}
+}
+
+func (s *Supervisor) update(name string, change func(w *state)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(s.workers[name])
+}
+
+// Workers returns the status of every worker sorted by name
+func (s *Supervisor) Workers() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Status, 0, len(s.workers))
+	for name, w := range s.workers {
+		status := Status{
+			Name:      name,
+			Status:    w.status,
+			Crashes:   w.crashes,
+			LastPanic: w.lastPanic,
+		}
+		if !w.started.IsZero() {
+			status.StartedAt = w.started.UTC().Format(time.RFC3339)
+		}
+		if !w.lastCrash.IsZero() {
+			status.LastCrashAt = w.lastCrash.UTC().Format(time.RFC3339)
+		}
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ServeHTTP serves the worker statuses as JSON
+func (s *Supervisor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]Status{"workers": s.Workers()})
+}